@@ -0,0 +1,164 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/imds"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// AttestationProvider cloud provider which signs instance identity documents
+type AttestationProvider string
+
+const (
+	// AttestationNone don't include instance identity in the handshake
+	AttestationNone AttestationProvider = "none"
+	// AttestationAWS EC2 instance identity document signed with PKCS7
+	AttestationAWS AttestationProvider = "aws"
+	// AttestationGCP GCE instance identity token issued by google
+	AttestationGCP AttestationProvider = "gcp"
+	// AttestationAzure Azure attested data document
+	AttestationAzure AttestationProvider = "azure"
+
+	attestationTimeout = 2 * time.Second
+)
+
+// ParseAttestationProvider parses and validates attestation provider
+func ParseAttestationProvider(value string) (AttestationProvider, error) {
+	provider := AttestationProvider(value)
+	switch provider {
+	case AttestationNone, AttestationAWS, AttestationGCP, AttestationAzure:
+		return provider, nil
+	}
+
+	return "", karma.Format(
+		nil,
+		"unsupported identity attestation provider %q, expected one of: "+
+			"none, aws, gcp, azure",
+		value,
+	)
+}
+
+// getInstanceIdentity requests a signed identity document from instance
+// metadata, the document is requested on every handshake as some providers
+// issue short-lived documents
+func (client *Client) getInstanceIdentity() (*proto.PacketInstanceIdentity, error) {
+	httpClient := &http.Client{Timeout: attestationTimeout}
+
+	// NOTE: nonce binds the document to the current agent run where the
+	// provider supports it, azure accepts only numeric nonces
+	nonce := fmt.Sprint(time.Now().Unix())
+
+	identity := &proto.PacketInstanceIdentity{
+		Provider: string(client.attestation),
+		Nonce:    nonce,
+	}
+
+	var err error
+	switch client.attestation {
+	case AttestationAWS:
+		identity.Document, identity.Signature, err = getAWSInstanceIdentity(httpClient)
+	case AttestationGCP:
+		identity.Document, err = getGCPInstanceIdentity(
+			httpClient,
+			fmt.Sprintf("magalix/%s/%s", client.AccountID, client.ClusterID),
+		)
+		// NOTE: audience of the token is verified instead of the nonce
+		identity.Nonce = ""
+	case AttestationAzure:
+		identity.Document, identity.Signature, err = getAzureInstanceIdentity(
+			httpClient,
+			nonce,
+		)
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to get %s instance identity", client.attestation,
+		)
+	}
+
+	return identity, nil
+}
+
+func getAWSInstanceIdentity(httpClient *http.Client) ([]byte, []byte, error) {
+	headers := imds.GetAWSHeaders(httpClient)
+
+	document, err := imds.Request(
+		httpClient,
+		http.MethodGet,
+		imds.AWSURL+"/dynamic/instance-identity/document",
+		headers,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signature, err := imds.Request(
+		httpClient,
+		http.MethodGet,
+		imds.AWSURL+"/dynamic/instance-identity/pkcs7",
+		headers,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return document, signature, nil
+}
+
+func getGCPInstanceIdentity(
+	httpClient *http.Client,
+	audience string,
+) ([]byte, error) {
+	query := url.Values{}
+	query.Set("audience", audience)
+	query.Set("format", "full")
+
+	return imds.Request(
+		httpClient,
+		http.MethodGet,
+		imds.GCPURL+"/instance/service-accounts/default/identity?"+
+			query.Encode(),
+		map[string]string{"Metadata-Flavor": "Google"},
+	)
+}
+
+func getAzureInstanceIdentity(
+	httpClient *http.Client,
+	nonce string,
+) ([]byte, []byte, error) {
+	query := url.Values{}
+	query.Set("api-version", "2020-09-01")
+	query.Set("nonce", nonce)
+
+	body, err := imds.Request(
+		httpClient,
+		http.MethodGet,
+		imds.AzureURL+"/attested/document?"+query.Encode(),
+		map[string]string{"Metadata": "true"},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var attested struct {
+		Encoding  string `json:"encoding"`
+		Signature string `json:"signature"`
+	}
+
+	err = json.Unmarshal(body, &attested)
+	if err != nil {
+		return nil, nil, karma.Format(err, "unable to decode attested document")
+	}
+
+	return body, []byte(attested.Signature), nil
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"fmt"
 	"net/url"
 	"os"
 	"sync"
@@ -8,17 +9,19 @@ import (
 	"time"
 
 	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/replay"
 	"github.com/MagalixCorp/magalix-agent/utils"
 	"github.com/MagalixTechnologies/channel"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/kovetskiy/lorg"
 	"github.com/reconquest/karma-go"
 	"github.com/reconquest/sign-go"
 )
 
 const (
 	ProtocolMajorVersion = 1
-	ProtocolMinorVersion = 5
+	ProtocolMinorVersion = 6
 
 	logsQueueSize = 1024
 )
@@ -44,15 +47,25 @@ type Client struct {
 	ClusterID uuid.UUID
 	secret    []byte
 
+	attestation AttestationProvider
+
 	channel *channel.Client
 
+	// stateMutex guards connection state and time of the last sent packet
+	stateMutex sync.Mutex
 	connected  bool
 	authorized bool
 
+	// protocolMinor negotiated protocol minor version
+	protocolMinor uint32
+
 	shouldSendLogs  bool
 	logsQueue       chan proto.PacketLogItem
 	logsQueueWorker *sync.WaitGroup
 
+	logLevel      lorg.Level
+	logEscalation *logEscalation
+
 	exit chan int
 
 	// for thread blocked on connection
@@ -63,8 +76,26 @@ type Client struct {
 
 	lastSent time.Time
 
+	healthChecks      map[string]func() proto.PacketComponentHealth
+	healthChecksMutex sync.Mutex
+
+	heartbeatInterval time.Duration
+
+	// parent client of the connection if the client is an attached cluster
+	parent   *Client
+	clusters clusters
+
+	// listeners listeners of an attached cluster
+	listeners      map[proto.PacketKind]func(in []byte) ([]byte, error)
+	listenersMutex sync.Mutex
+
 	pipe       *Pipe
 	pipeStatus *Pipe
+
+	// shutdownHooks hooks flushing components on shutdown
+	shutdownHooks      []shutdownHook
+	shutdownHooksMutex sync.Mutex
+	shutdownOnce       sync.Once
 }
 
 // newClient creates a new client
@@ -75,9 +106,11 @@ func newClient(
 	accountID uuid.UUID,
 	clusterID uuid.UUID,
 	secret []byte,
+	attestation AttestationProvider,
 	timeouts timeouts,
 	parentLogger *log.Logger,
 	shouldSendLogs bool,
+	logLevel lorg.Level,
 ) *Client {
 	url, err := url.Parse(address)
 	if err != nil {
@@ -92,8 +125,12 @@ func newClient(
 		AccountID:      accountID,
 		ClusterID:      clusterID,
 		secret:         secret,
+		attestation:    attestation,
 		shouldSendLogs: shouldSendLogs,
 
+		logLevel:      logLevel,
+		logEscalation: &logEscalation{},
+
 		channel: channel.NewClient(*url, channel.ChannelOptions{
 			ProtoHandshake: timeouts.protoHandshake,
 			ProtoWrite:     timeouts.protoWrite,
@@ -106,6 +143,10 @@ func newClient(
 		blockedM: sync.Mutex{},
 
 		timeouts: timeouts,
+
+		protocolMinor: ProtocolMinorVersion,
+
+		healthChecks: map[string]func() proto.PacketComponentHealth{},
 	}
 
 	client.pipe = NewPipe(client, client.parentLogger)
@@ -123,7 +164,7 @@ func newClient(
 // Example:
 //   WaitForConnection(time.Second * 10)
 func (client *Client) WaitForConnection(timeout time.Duration) bool {
-	if client.authorized {
+	if client.IsReady() {
 		return true
 	}
 	c := make(chan struct{})
@@ -186,16 +227,38 @@ func (client *Client) send(kind proto.PacketKind, in interface{}, out interface{
 	if err != nil {
 		return err
 	}
+	if client.parent != nil {
+		req, err = proto.Encode(proto.PacketClusterEnvelope{
+			ClusterID: client.ClusterID,
+			Kind:      kind,
+			Data:      req,
+		})
+		if err != nil {
+			return err
+		}
+		kind = proto.PacketKindClusterPacket
+	}
 	res, err := client.channel.Send(kind.String(), req)
 	if err != nil {
 		return err
 	}
-	client.lastSent = time.Now()
+	now := time.Now()
+	client.setLastSent(now)
+	if client.parent != nil {
+		client.parent.setLastSent(now)
+	}
 	return proto.Decode(res, out)
 }
 
 // Send sends a packet to the agent-gateway if there is an established connection it internally uses client.send
 func (client *Client) Send(kind proto.PacketKind, in interface{}, out interface{}) error {
+	if !client.IsPacketKindSupported(kind) {
+		return karma.
+			Describe("kind", kind).
+			Describe("protocol/minor", client.getProtocolMinor()).
+			Reason("packet kind is not supported by the gateway")
+	}
+
 	client.parentLogger.Debugf(karma.Describe("kind", kind), "sending package")
 	defer client.parentLogger.Debugf(karma.Describe("kind", kind), "package sent")
 	client.WaitForConnection(time.Minute)
@@ -219,14 +282,53 @@ func (client *Client) Pipe(pack Package) {
 	if client.pipe == nil {
 		panic("client pipe not defined")
 	}
+	if !client.IsPacketKindSupported(pack.Kind) {
+		client.Logger.Debugf(
+			karma.
+				Describe("kind", pack.Kind).
+				Describe("protocol/minor", client.getProtocolMinor()),
+			"packet kind is not supported by the gateway, discarding packet",
+		)
+		return
+	}
 	i := client.pipe.Send(pack)
 	if i > 0 {
 		client.Logger.Errorf(nil, "discarded %d packets to agent-gateway", i)
 	}
 }
 
+// GetState returns internal state of the client for debugging
+func (client *Client) GetState() interface{} {
+	client.stateMutex.Lock()
+	connected, authorized, lastSent := client.connected, client.authorized, client.lastSent
+	client.stateMutex.Unlock()
+
+	state := map[string]interface{}{
+		"connected":   connected,
+		"authorized":  authorized,
+		"last_sent":   lastSent,
+		"protocol":    fmt.Sprintf("%d.%d", ProtocolMajorVersion, client.getProtocolMinor()),
+		"pipe":        client.pipe.Len(),
+		"pipe_status": client.pipeStatus.Len(),
+	}
+
+	if client.logsQueue != nil {
+		state["logs_queue"] = len(client.logsQueue)
+	}
+
+	return state
+}
+
 // AddListener adds a listener for a specific packet kind
 func (client *Client) AddListener(kind proto.PacketKind, listener func(in []byte) ([]byte, error)) {
+	listener = replay.Listener(kind, listener)
+	if client.parent != nil {
+		client.listenersMutex.Lock()
+		defer client.listenersMutex.Unlock()
+
+		client.listeners[kind] = listener
+		return
+	}
 	if err := client.channel.AddListener(kind.String(), listener); err != nil {
 		panic(err)
 	}
@@ -237,21 +339,17 @@ func InitClient(
 	args map[string]interface{},
 	version string,
 	startID string,
-	accountID, clusterID uuid.UUID,
-	secret []byte,
+	credentials Credentials,
 	parentLogger *log.Logger,
 ) (*Client, error) {
 	client := newClient(
-		args["--gateway"].(string), version, startID, accountID, clusterID, secret,
-		timeouts{
-			protoHandshake: utils.MustParseDuration(args, "--timeout-proto-handshake"),
-			protoWrite:     utils.MustParseDuration(args, "--timeout-proto-write"),
-			protoRead:      utils.MustParseDuration(args, "--timeout-proto-read"),
-			protoReconnect: utils.MustParseDuration(args, "--timeout-proto-reconnect"),
-			protoBackoff:   utils.MustParseDuration(args, "--timeout-proto-backoff"),
-		},
+		args["--gateway"].(string), version, startID,
+		credentials.AccountID, credentials.ClusterID, credentials.Secret,
+		credentials.Attestation,
+		getTimeouts(args),
 		parentLogger,
 		!args["--no-send-logs"].(bool),
+		getLogLevel(args),
 	)
 	go sign.Notify(func(os.Signal) bool {
 		if !client.IsReady() {
@@ -273,6 +371,11 @@ func InitClient(
 	}, syscall.SIGHUP)
 
 	err := client.Connect()
+	if err != nil {
+		return client, err
+	}
+
+	client.startHeartbeat(utils.MustParseDuration(args, "--heartbeat-interval"))
 
-	return client, err
+	return client, nil
 }
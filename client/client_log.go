@@ -16,6 +16,10 @@ var _ structured.Sender = ((*Client)(nil)).sendLogs
 func (client *Client) sendLogs(
 	level lorg.Level, hierarchy karma.Hierarchical,
 ) error {
+	if !client.isSendingLogs() {
+		return nil
+	}
+
 	client.logsQueue <- proto.PacketLogItem{
 		Level: level,
 		Date:  time.Now().UTC(),
@@ -33,11 +37,10 @@ func (client *Client) initLogger() {
 	// Note that parentLogger is the global stderr
 	client.Logger.SetDisplayer(client.parentLogger.Display)
 
-	if client.shouldSendLogs {
-		// as sender we will use client's packet logs
-		client.Logger.SetSender(client.sendLogs)
-		client.initLogsQueue()
-	}
+	// NOTE: logs queue is always initialized as sending logs can be enabled
+	// temporarily by log level escalation
+	client.Logger.SetSender(client.sendLogs)
+	client.initLogsQueue()
 
 	client.Logger.Log.SetExiter(func(int) {
 		return
@@ -81,7 +84,7 @@ func (client *Client) watchLogsQueue() {
 
 	flush:
 
-		if client.shouldSendLogs {
+		if client.shouldSendLogs || len(logs) > 0 {
 			client.parentLogger.Tracef(nil, "sending %v log entries", len(logs))
 			client.Pipe(Package{
 				Kind:        proto.PacketKindLogs,
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// clusters clients of additional clusters multiplexed over the connection
+type clusters struct {
+	sync.Mutex
+
+	items map[uuid.UUID]*Client
+}
+
+// AttachCluster creates a client of an additional cluster of the account
+// which shares the connection with the client, the cluster is attached
+// after every authorization and its packets are wrapped into cluster
+// envelopes, so it behaves as a separate agent for the gateway
+func (client *Client) AttachCluster(clusterID uuid.UUID) *Client {
+	cluster := &Client{
+		parentLogger: client.parentLogger,
+		parent:       client,
+
+		address:        client.address,
+		version:        client.version,
+		startID:        client.startID,
+		AccountID:      client.AccountID,
+		ClusterID:      clusterID,
+		secret:         client.secret,
+		attestation:    client.attestation,
+		shouldSendLogs: client.shouldSendLogs,
+
+		logLevel:      client.logLevel,
+		logEscalation: &logEscalation{},
+
+		channel: client.channel,
+		exit:    client.exit,
+
+		blocked:  sync.Map{},
+		blockedM: sync.Mutex{},
+
+		timeouts: client.timeouts,
+
+		protocolMinor: ProtocolMinorVersion,
+
+		healthChecks: map[string]func() proto.PacketComponentHealth{},
+		listeners:    map[proto.PacketKind]func(in []byte) ([]byte, error){},
+	}
+
+	cluster.pipe = NewPipe(cluster, cluster.parentLogger)
+	cluster.pipeStatus = NewPipe(cluster, cluster.parentLogger)
+
+	cluster.initLogger()
+
+	client.clusters.Lock()
+	if client.clusters.items == nil {
+		client.clusters.items = map[uuid.UUID]*Client{}
+
+		// NOTE: the listener is not wrapped into replay listener, packets
+		// are recorded by listeners of attached clusters
+		err := client.channel.AddListener(
+			proto.PacketKindClusterPacket.String(),
+			client.clusterListener,
+		)
+		if err != nil {
+			panic(err)
+		}
+	}
+	client.clusters.items[clusterID] = cluster
+	client.clusters.Unlock()
+
+	cluster.pipe.Start(10)
+	cluster.pipeStatus.Start(1)
+
+	if client.heartbeatInterval > 0 {
+		cluster.startHeartbeat(client.heartbeatInterval)
+	}
+
+	if client.IsReady() {
+		go client.WithBackoff(func() error {
+			return client.attachCluster(cluster)
+		})
+	}
+
+	return cluster
+}
+
+// attachClusters attaches all clusters to the authorized connection
+func (client *Client) attachClusters() {
+	client.clusters.Lock()
+	defer client.clusters.Unlock()
+
+	for _, cluster := range client.clusters.items {
+		cluster := cluster
+		go client.WithBackoff(func() error {
+			return client.attachCluster(cluster)
+		})
+	}
+}
+
+func (client *Client) attachCluster(cluster *Client) error {
+	// NOTE: clusters are attached again once the client is authorized
+	if !client.IsReady() {
+		return nil
+	}
+
+	var response proto.PacketClusterAttachResponse
+	err := client.send(proto.PacketKindClusterAttach, proto.PacketClusterAttach{
+		ClusterID: cluster.ClusterID,
+	}, &response)
+	if err != nil {
+		return karma.Describe("cluster_id", cluster.ClusterID).Format(
+			err,
+			"unable to attach cluster",
+		)
+	}
+
+	cluster.setConnected(true)
+	cluster.setAuthorized(true)
+	cluster.releaseBlocked()
+
+	client.Infof(nil, "cluster %s has been attached", cluster.ClusterID)
+
+	return nil
+}
+
+// detachClusters marks attached clusters as disconnected
+func (client *Client) detachClusters() {
+	client.clusters.Lock()
+	defer client.clusters.Unlock()
+
+	for _, cluster := range client.clusters.items {
+		cluster.setConnected(false)
+		cluster.setAuthorized(false)
+	}
+}
+
+// clusterListener passes packets of attached clusters to their listeners
+func (client *Client) clusterListener(in []byte) ([]byte, error) {
+	var envelope proto.PacketClusterEnvelope
+	err := proto.Decode(in, &envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := karma.
+		Describe("cluster_id", envelope.ClusterID).
+		Describe("kind", envelope.Kind)
+
+	client.clusters.Lock()
+	cluster, ok := client.clusters.items[envelope.ClusterID]
+	client.clusters.Unlock()
+	if !ok {
+		return nil, ctx.Format(nil, "cluster is not attached")
+	}
+
+	cluster.listenersMutex.Lock()
+	listener, ok := cluster.listeners[envelope.Kind]
+	cluster.listenersMutex.Unlock()
+	if !ok {
+		return nil, ctx.Format(nil, "no listener for packet kind")
+	}
+
+	return listener(envelope.Data)
+}
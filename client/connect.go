@@ -8,10 +8,10 @@ import (
 )
 
 func (client *Client) onConnect() error {
-	client.connected = true
+	client.setConnected(true)
 	expire := time.Now().Add(time.Minute * 10)
 	for try := 0; try < 1000; try++ {
-		if !client.connected {
+		if !client.isConnected() {
 			return nil
 		}
 		err := client.hello()
@@ -34,15 +34,10 @@ func (client *Client) onConnect() error {
 			)
 			continue
 		}
-		client.authorized = true
+		client.setAuthorized(true)
+		client.releaseBlocked()
 
-		client.blockedM.Lock()
-		defer client.blockedM.Unlock()
-		client.blocked.Range(func(k, v interface{}) bool {
-			k.(chan struct{}) <- struct{}{}
-			return true
-		})
-		client.blocked = sync.Map{}
+		client.attachClusters()
 
 		return nil
 
@@ -52,9 +47,24 @@ func (client *Client) onConnect() error {
 	return nil
 }
 
+// releaseBlocked releases threads blocked on connection
+func (client *Client) releaseBlocked() {
+	client.blockedM.Lock()
+	defer client.blockedM.Unlock()
+	client.blocked.Range(func(k, v interface{}) bool {
+		k.(chan struct{}) <- struct{}{}
+		return true
+	})
+	client.blocked = sync.Map{}
+}
+
 func (client *Client) onDisconnect() {
+	client.stateMutex.Lock()
 	client.connected = false
 	client.authorized = false
+	client.stateMutex.Unlock()
+
+	client.detachClusters()
 }
 
 // Connect starts the client
@@ -71,18 +81,57 @@ func (client *Client) Connect() error {
 
 // IsReady returns true if the agent is connected and authenticated
 func (client *Client) IsReady() bool {
+	client.stateMutex.Lock()
+	defer client.stateMutex.Unlock()
+
 	return client.authorized
 }
 
+func (client *Client) isConnected() bool {
+	client.stateMutex.Lock()
+	defer client.stateMutex.Unlock()
+
+	return client.connected
+}
+
+func (client *Client) setConnected(connected bool) {
+	client.stateMutex.Lock()
+	defer client.stateMutex.Unlock()
+
+	client.connected = connected
+}
+
+func (client *Client) setAuthorized(authorized bool) {
+	client.stateMutex.Lock()
+	defer client.stateMutex.Unlock()
+
+	client.authorized = authorized
+}
+
+func (client *Client) getLastSent() time.Time {
+	client.stateMutex.Lock()
+	defer client.stateMutex.Unlock()
+
+	return client.lastSent
+}
+
+func (client *Client) setLastSent(lastSent time.Time) {
+	client.stateMutex.Lock()
+	defer client.stateMutex.Unlock()
+
+	client.lastSent = lastSent
+}
+
 func (client *Client) StartWatchdog() {
 	startTime := time.Now()
 	for {
 		// it didn't sent anything before
-		if (client.lastSent == time.Time{}) {
+		lastSent := client.getLastSent()
+		if (lastSent == time.Time{}) {
 			if startTime.Add(10 * time.Minute).Before(time.Now()) {
 				break
 			}
-		} else if client.lastSent.Add(10 * time.Minute).Before(time.Now()) {
+		} else if lastSent.Add(10 * time.Minute).Before(time.Now()) {
 			break
 		}
 		time.Sleep(time.Minute)
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/base64"
+
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// Credentials credentials used to authorize in the agent gateway
+type Credentials struct {
+	AccountID   uuid.UUID
+	ClusterID   uuid.UUID
+	Secret      []byte
+	Attestation AttestationProvider
+}
+
+// ParseCredentials reads credentials from flags, values starting with $ are
+// read from environment variables
+func ParseCredentials(args map[string]interface{}) (Credentials, error) {
+	secret, err := base64.StdEncoding.DecodeString(
+		utils.ExpandEnv(args, "--client-secret", false),
+	)
+	if err != nil {
+		return Credentials{}, karma.Format(
+			err,
+			"unable to decode base64 secret specified as --client-secret flag",
+		)
+	}
+
+	attestation, err := ParseAttestationProvider(
+		args["--identity-attestation"].(string),
+	)
+	if err != nil {
+		return Credentials{}, karma.Format(
+			err,
+			"unable to parse identity attestation provider",
+		)
+	}
+
+	return Credentials{
+		AccountID:   utils.ExpandEnvUUID(args, "--account-id"),
+		ClusterID:   utils.ExpandEnvUUID(args, "--cluster-id"),
+		Secret:      secret,
+		Attestation: attestation,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+)
+
+// RegisterHealthCheck registers a function reporting health of a component,
+// it is called on every heartbeat
+func (client *Client) RegisterHealthCheck(
+	name string,
+	check func() proto.PacketComponentHealth,
+) {
+	client.healthChecksMutex.Lock()
+	defer client.healthChecksMutex.Unlock()
+
+	client.healthChecks[name] = check
+}
+
+func (client *Client) getHealth() map[string]proto.PacketComponentHealth {
+	client.healthChecksMutex.Lock()
+	defer client.healthChecksMutex.Unlock()
+
+	health := map[string]proto.PacketComponentHealth{}
+	for name, check := range client.healthChecks {
+		health[name] = check()
+	}
+
+	return health
+}
+
+// startHeartbeat periodically sends ping packets with health of components
+// so the gateway can tell a dead agent from an agent with failing components
+func (client *Client) startHeartbeat(interval time.Duration) {
+	client.heartbeatInterval = interval
+
+	ticker := utils.NewTicker(client.Logger, "heartbeat", interval, func(tickTime time.Time) {
+		if !client.IsReady() {
+			return
+		}
+
+		err := client.heartbeat()
+		if err != nil {
+			client.Errorf(err, "unable to send heartbeat")
+		}
+	})
+	ticker.Start(false, false, false)
+}
+
+func (client *Client) heartbeat() error {
+	health := client.getHealth()
+
+	var pong proto.PacketPong
+	err := client.Send(proto.PacketKindPing, proto.PacketPing{
+		Started: time.Now().UTC(),
+		Health:  health,
+	}, &pong)
+	if err != nil {
+		return err
+	}
+
+	unhealthy := []string{}
+	for name, component := range health {
+		if !component.Healthy {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+
+	client.Debugf(
+		karma.Describe("unhealthy", unhealthy),
+		"heartbeat has been sent",
+	)
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/kovetskiy/lorg"
+	"github.com/reconquest/karma-go"
+)
+
+const maxLogEscalationDuration = time.Hour
+
+// logEscalation temporarily raised log level requested by the gateway
+type logEscalation struct {
+	mutex sync.Mutex
+	until time.Time
+	timer *time.Timer
+
+	// generation identifies the latest escalation, so timers of replaced
+	// escalations don't revert the current one
+	generation uint64
+}
+
+func getLogLevel(args map[string]interface{}) lorg.Level {
+	switch {
+	case args["--trace"].(bool):
+		return lorg.LevelTrace
+	case args["--debug"].(bool):
+		return lorg.LevelDebug
+	default:
+		return lorg.LevelInfo
+	}
+}
+
+// isSendingLogs checks whether logs should be sent to the gateway, logs are
+// sent while the log level is escalated even if sending logs is disabled
+func (client *Client) isSendingLogs() bool {
+	if client.shouldSendLogs {
+		return true
+	}
+
+	client.logEscalation.mutex.Lock()
+	defer client.logEscalation.mutex.Unlock()
+
+	return time.Now().Before(client.logEscalation.until)
+}
+
+// EscalateLogLevel raises log level for the specified duration then reverts
+// it to the original level, a new escalation replaces the previous one
+func (client *Client) EscalateLogLevel(level lorg.Level, duration time.Duration) {
+	if duration > maxLogEscalationDuration {
+		duration = maxLogEscalationDuration
+	}
+
+	// NOTE: higher lorg levels are more verbose, escalation never makes logs
+	// less verbose than configured by flags
+	if level < client.logLevel {
+		level = client.logLevel
+	}
+
+	escalation := client.logEscalation
+	escalation.mutex.Lock()
+
+	if escalation.timer != nil {
+		escalation.timer.Stop()
+	}
+
+	escalation.generation++
+	generation := escalation.generation
+
+	client.setLogLevel(level)
+	escalation.until = time.Now().Add(duration)
+	escalation.timer = time.AfterFunc(duration, func() {
+		client.expireLogEscalation(generation)
+	})
+
+	// NOTE: logging locks the escalation to check whether logs are sent,
+	// so it has to be released first
+	escalation.mutex.Unlock()
+
+	client.Infof(
+		karma.
+			Describe("level", level.String()).
+			Describe("duration", duration),
+		"escalating log level",
+	)
+}
+
+// expireLogEscalation reverts log level to the original one unless the
+// escalation has been already replaced by a newer one
+func (client *Client) expireLogEscalation(generation uint64) {
+	escalation := client.logEscalation
+	escalation.mutex.Lock()
+
+	if escalation.generation != generation {
+		escalation.mutex.Unlock()
+		return
+	}
+
+	client.setLogLevel(client.logLevel)
+	escalation.timer = nil
+
+	escalation.mutex.Unlock()
+
+	client.Infof(
+		karma.Describe("level", client.logLevel.String()),
+		"log level escalation expired",
+	)
+}
+
+func (client *Client) setLogLevel(level lorg.Level) {
+	client.parentLogger.Log.SetLevel(level)
+	client.Logger.Log.SetLevel(level)
+}
+
+// LogLevelListener handles log level escalation packets
+func (client *Client) LogLevelListener(in []byte) (out []byte, err error) {
+	var packet proto.PacketLogLevel
+	if err = proto.Decode(in, &packet); err != nil {
+		return
+	}
+
+	client.EscalateLogLevel(packet.Level, packet.Duration)
+
+	return proto.Encode(proto.PacketLogLevelResponse{})
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// ProtocolMinMinorVersion the lowest protocol minor version the client can
+// downgrade to
+const ProtocolMinMinorVersion = 5
+
+// packetKindsMinorVersions protocol minor versions which introduced packet
+// kinds, packets of kinds unknown to the gateway are not sent
+var packetKindsMinorVersions = map[proto.PacketKind]uint{
+	proto.PacketKindMetricsStoreChunkRequest:       6,
+	proto.PacketKindVPARecommendationsStoreRequest: 6,
+	proto.PacketKindNamespacesSummaryStoreRequest:  6,
+	proto.PacketKindClusterCapacity:                6,
+	proto.PacketKindKubernetesThrottling:           6,
+	proto.PacketKindDecisionDryRunResult:           6,
+	proto.PacketKindDecisionsQueue:                 6,
+	proto.PacketKindDecisionsSummary:               6,
+	proto.PacketKindDecisionsResumed:               6,
+	proto.PacketKindDecisionsRetried:               6,
+	proto.PacketKindDecisionsStaged:                6,
+	proto.PacketKindDecisionProgress:               6,
+	proto.PacketKindDecisionReverted:               6,
+	proto.PacketKindClusterAttach:                  6,
+	proto.PacketKindClusterPacket:                  6,
+	proto.PacketKindApplicationsDeltaRequest:       6,
+	proto.PacketKindEntitiesDeleted:                6,
+	proto.PacketKindEntitiesWarmup:                 6,
+	proto.PacketKindKubernetesCapabilities:         6,
+	proto.PacketKindAgentConfig:                    6,
+	proto.PacketKindScalarStatus:                   6,
+}
+
+// negotiateProtocol returns the protocol minor version supported by both the
+// client and the gateway, fields introduced in newer versions are ignored by
+// gateways decoding packets
+func negotiateProtocol(serverMajor, serverMinor uint) (uint, error) {
+	// NOTE: gateways which don't report their version are assumed to support
+	// only the lowest version
+	if serverMajor == 0 && serverMinor == 0 {
+		return ProtocolMinMinorVersion, nil
+	}
+
+	if serverMajor != ProtocolMajorVersion {
+		return 0, karma.
+			Describe("client/protocol/major", ProtocolMajorVersion).
+			Describe("server/protocol/major", serverMajor).
+			Reason("incompatible protocol major version")
+	}
+
+	if serverMinor < ProtocolMinMinorVersion {
+		return 0, karma.
+			Describe("client/protocol/min-minor", ProtocolMinMinorVersion).
+			Describe("server/protocol/minor", serverMinor).
+			Reason("gateway protocol minor version is too old")
+	}
+
+	if serverMinor < ProtocolMinorVersion {
+		return serverMinor, nil
+	}
+
+	return ProtocolMinorVersion, nil
+}
+
+// getProtocolMinor returns negotiated protocol minor version
+func (client *Client) getProtocolMinor() uint {
+	if client.parent != nil {
+		return client.parent.getProtocolMinor()
+	}
+
+	return uint(atomic.LoadUint32(&client.protocolMinor))
+}
+
+func (client *Client) setProtocolMinor(minor uint) {
+	atomic.StoreUint32(&client.protocolMinor, uint32(minor))
+}
+
+// IsPacketKindSupported checks whether the gateway supports packets of the
+// kind according to the negotiated protocol version
+func (client *Client) IsPacketKindSupported(kind proto.PacketKind) bool {
+	minor, ok := packetKindsMinorVersions[kind]
+	if !ok {
+		return true
+	}
+
+	return client.getProtocolMinor() >= minor
+}
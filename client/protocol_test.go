@@ -0,0 +1,58 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	testcases := []struct {
+		major, minor uint
+		want         uint
+		fail         bool
+	}{
+		{ProtocolMajorVersion, ProtocolMinorVersion, ProtocolMinorVersion, false},
+		{ProtocolMajorVersion, ProtocolMinorVersion + 1, ProtocolMinorVersion, false},
+		{ProtocolMajorVersion, ProtocolMinMinorVersion, ProtocolMinMinorVersion, false},
+		{ProtocolMajorVersion, ProtocolMinMinorVersion - 1, 0, true},
+		{ProtocolMajorVersion + 1, ProtocolMinorVersion, 0, true},
+		{0, 0, ProtocolMinMinorVersion, false},
+	}
+
+	for _, testcase := range testcases {
+		minor, err := negotiateProtocol(testcase.major, testcase.minor)
+		if testcase.fail {
+			if err == nil {
+				t.Errorf(
+					"negotiateProtocol(%d, %d) succeeded, want error",
+					testcase.major, testcase.minor,
+				)
+			}
+			continue
+		}
+
+		if err != nil || minor != testcase.want {
+			t.Errorf(
+				"negotiateProtocol(%d, %d) = %d, %v, want %d",
+				testcase.major, testcase.minor, minor, err, testcase.want,
+			)
+		}
+	}
+}
+
+func TestIsPacketKindSupported(t *testing.T) {
+	client := &Client{}
+
+	client.setProtocolMinor(ProtocolMinMinorVersion)
+	for kind := range packetKindsMinorVersions {
+		if client.IsPacketKindSupported(kind) {
+			t.Errorf("IsPacketKindSupported(%q) = true for the lowest protocol", kind)
+		}
+	}
+
+	client.setProtocolMinor(ProtocolMinorVersion)
+	for kind := range packetKindsMinorVersions {
+		if !client.IsPacketKindSupported(kind) {
+			t.Errorf("IsPacketKindSupported(%q) = false for latest protocol", kind)
+		}
+	}
+}
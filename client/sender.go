@@ -12,27 +12,52 @@ import (
 
 // hello Sends hello package
 func (client *Client) hello() error {
+	identity, err := client.getInstanceIdentity()
+	if err != nil {
+		// NOTE: attestation is optional, the gateway decides whether to
+		// accept agents without instance identity
+		client.Warningf(err, "sending hello without instance identity")
+	}
+
 	var hello proto.PacketHello
-	err := client.send(proto.PacketKindHello, proto.PacketHello{
+	err = client.send(proto.PacketKindHello, proto.PacketHello{
 		Major:     ProtocolMajorVersion,
 		Minor:     ProtocolMinorVersion,
+		MinMinor:  ProtocolMinMinorVersion,
 		Build:     client.version,
 		StartID:   client.startID,
 		AccountID: client.AccountID,
 		ClusterID: client.ClusterID,
+
+		InstanceIdentity: identity,
 	}, &hello)
 	if err != nil {
 		return err
 	}
 
-	client.Infof(
-		karma.
-			Describe("client/protocol/major", ProtocolMajorVersion).
-			Describe("client/protocol/minor", ProtocolMinorVersion).
-			Describe("server/protocol/major", hello.Major).
-			Describe("server/protocol/minor", hello.Minor),
-		"hello phase has been finished",
-	)
+	ctx := karma.
+		Describe("client/protocol/major", ProtocolMajorVersion).
+		Describe("client/protocol/minor", ProtocolMinorVersion).
+		Describe("server/protocol/major", hello.Major).
+		Describe("server/protocol/minor", hello.Minor)
+
+	minor, err := negotiateProtocol(hello.Major, hello.Minor)
+	if err != nil {
+		return ctx.Format(err, "unable to negotiate protocol version")
+	}
+
+	if minor < ProtocolMinorVersion {
+		client.Warningf(
+			ctx,
+			"gateway supports older protocol, downgrading to %d.%d",
+			ProtocolMajorVersion,
+			minor,
+		)
+	}
+
+	client.setProtocolMinor(minor)
+
+	client.Infof(ctx, "hello phase has been finished")
 
 	return nil
 }
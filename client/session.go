@@ -0,0 +1,129 @@
+package client
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+// Session short-lived authorized connection to the agent gateway, it is
+// used by subcommands which need to talk to the gateway without running the
+// agent: no logs, pipes or watchdog are started and the process doesn't exit
+// if the gateway is unreachable
+type Session struct {
+	client *Client
+}
+
+// NewSession connects to the gateway and authorizes using the same
+// handshake as the agent, it blocks until the session is authorized or the
+// timeout is exceeded
+func NewSession(
+	args map[string]interface{},
+	version string,
+	startID string,
+	credentials Credentials,
+	parentLogger *log.Logger,
+	timeout time.Duration,
+) (*Session, error) {
+	client := newClient(
+		args["--gateway"].(string), version, startID,
+		credentials.AccountID, credentials.ClusterID, credentials.Secret,
+		credentials.Attestation,
+		getTimeouts(args),
+		parentLogger,
+		false,
+		getLogLevel(args),
+	)
+
+	handshakes := make(chan error, 1)
+	onConnect := func() error {
+		client.setConnected(true)
+
+		err := client.hello()
+		if err == nil {
+			err = client.authorize()
+		}
+
+		if err == nil {
+			client.setAuthorized(true)
+		}
+
+		select {
+		case handshakes <- err:
+		default:
+		}
+
+		return err
+	}
+	onDisconnect := client.onDisconnect
+
+	client.channel.SetHooks(&onConnect, &onDisconnect)
+	go client.channel.Listen()
+
+	select {
+	case err := <-handshakes:
+		if err != nil {
+			return nil, karma.Format(err, "unable to establish gateway session")
+		}
+	case <-time.After(timeout):
+		return nil, karma.
+			Describe("timeout", timeout).
+			Format(nil, "timeout establishing gateway session")
+	}
+
+	return &Session{client: client}, nil
+}
+
+// Send sends a packet and decodes the response into out
+func (session *Session) Send(
+	kind proto.PacketKind,
+	in interface{},
+	out interface{},
+) error {
+	if !session.client.IsReady() {
+		return karma.Format(nil, "gateway session is disconnected")
+	}
+
+	return session.client.send(kind, in, out)
+}
+
+// Ping measures latency between the agent and the gateway
+func (session *Session) Ping() (time.Duration, error) {
+	started := time.Now().UTC()
+
+	var pong proto.PacketPong
+	err := session.Send(proto.PacketKindPing, proto.PacketPing{
+		Started: started,
+	}, &pong)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Now().UTC().Sub(started), nil
+}
+
+// Close says bye to the gateway, the connection is dropped when the
+// process exits
+func (session *Session) Close(reason string) error {
+	if !session.client.IsReady() {
+		return nil
+	}
+
+	var response proto.PacketBye
+	return session.client.send(proto.PacketKindBye, proto.PacketBye{
+		Reason: reason,
+	}, &response)
+}
+
+func getTimeouts(args map[string]interface{}) timeouts {
+	return timeouts{
+		protoHandshake: utils.MustParseDuration(args, "--timeout-proto-handshake"),
+		protoWrite:     utils.MustParseDuration(args, "--timeout-proto-write"),
+		protoRead:      utils.MustParseDuration(args, "--timeout-proto-read"),
+		protoReconnect: utils.MustParseDuration(args, "--timeout-proto-reconnect"),
+		protoBackoff:   utils.MustParseDuration(args, "--timeout-proto-backoff"),
+	}
+}
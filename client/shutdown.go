@@ -0,0 +1,139 @@
+package client
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/sign-go"
+)
+
+// shutdownPollInterval interval of checking whether pipes are drained
+const shutdownPollInterval = 100 * time.Millisecond
+
+type shutdownHook struct {
+	name string
+	fn   func()
+}
+
+// AddShutdownHook adds a hook called on shutdown before pending packets are
+// flushed, hooks are called in reverse order of adding, so components
+// depending on each other should add hooks in order of initialization.
+// Hooks of attached clusters are called on shutdown of the connection.
+func (client *Client) AddShutdownHook(name string, fn func()) {
+	if client.parent != nil {
+		client.parent.AddShutdownHook(name, fn)
+		return
+	}
+
+	client.shutdownHooksMutex.Lock()
+	defer client.shutdownHooksMutex.Unlock()
+
+	client.shutdownHooks = append(client.shutdownHooks, shutdownHook{
+		name: name,
+		fn:   fn,
+	})
+}
+
+// HandleSignals shuts the agent down gracefully on SIGTERM or SIGINT, the
+// second signal kills the agent immediately
+func (client *Client) HandleSignals(timeout time.Duration) {
+	go sign.Notify(func(signal os.Signal) bool {
+		client.Shutdown("received signal "+signal.String(), timeout)
+		return false
+	}, syscall.SIGTERM, syscall.SIGINT)
+}
+
+// Shutdown stops components, flushes pending packets and sends bye packet
+// with the reason, the agent exits once done or the timeout is exceeded
+func (client *Client) Shutdown(reason string, timeout time.Duration) {
+	if client.parent != nil {
+		client.parent.Shutdown(reason, timeout)
+		return
+	}
+
+	client.shutdownOnce.Do(func() {
+		deadline := time.Now().Add(timeout)
+		context := karma.
+			Describe("reason", reason).
+			Describe("timeout", timeout)
+
+		client.Infof(context, "shutting down")
+
+		if !client.runShutdownHooks(deadline) {
+			client.Warningf(
+				context,
+				"shutdown hooks didn't finish in time, exiting anyway",
+			)
+		}
+
+		if !client.waitPipes(deadline) {
+			client.Warningf(
+				context.Describe("pending", client.getPipesLen()),
+				"pending packets weren't sent in time, exiting anyway",
+			)
+		}
+
+		if client.IsReady() {
+			client.halt(reason)
+		}
+
+		client.Done(0)
+	})
+}
+
+// runShutdownHooks calls hooks in reverse order, returns false if hooks
+// didn't finish before the deadline
+func (client *Client) runShutdownHooks(deadline time.Time) bool {
+	client.shutdownHooksMutex.Lock()
+	hooks := make([]shutdownHook, len(client.shutdownHooks))
+	copy(hooks, client.shutdownHooks)
+	client.shutdownHooksMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := len(hooks) - 1; i >= 0; i-- {
+			client.Debugf(nil, "{shutdown} running %s hook", hooks[i].name)
+			hooks[i].fn()
+		}
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Until(deadline)):
+		return false
+	}
+}
+
+// waitPipes waits for pipes of the client and attached clusters to be
+// drained, the client may reconnect meanwhile, returns false if packets are still pending at the deadline
+func (client *Client) waitPipes(deadline time.Time) bool {
+	for client.getPipesLen() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(shutdownPollInterval)
+	}
+
+	return true
+}
+
+// getPipesLen returns count of pending packets of the client and attached
+// clusters
+func (client *Client) getPipesLen() int {
+	pending := client.pipe.Len() + client.pipeStatus.Len()
+
+	client.clusters.Lock()
+	defer client.clusters.Unlock()
+
+	for _, cluster := range client.clusters.items {
+		pending += cluster.pipe.Len() + cluster.pipeStatus.Len()
+	}
+
+	return pending
+}
@@ -0,0 +1,138 @@
+package cloud
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+// providers as they are specified in provider ids of nodes
+const (
+	ProviderAWS   = "aws"
+	ProviderGCE   = "gce"
+	ProviderAzure = "azure"
+)
+
+// lifecycles of instances of nodes
+const (
+	LifecycleOnDemand = "on-demand"
+	LifecycleSpot     = "spot"
+)
+
+// Instance metadata of the cloud instance the agent runs on
+type Instance struct {
+	Provider     string
+	Hostname     string
+	Region       string
+	Zone         string
+	InstanceType string
+	Lifecycle    string
+}
+
+var (
+	instance      *Instance
+	instanceMutex sync.Mutex
+)
+
+// SetInstance sets metadata of the instance the agent runs on, the node of
+// the instance is enriched by the metadata where labels are missing
+func SetInstance(value *Instance) {
+	instanceMutex.Lock()
+	defer instanceMutex.Unlock()
+
+	instance = value
+}
+
+func getInstance() *Instance {
+	instanceMutex.Lock()
+	defer instanceMutex.Unlock()
+
+	return instance
+}
+
+// lifecycleLabels labels of lifecycles of nodes and their values of spot
+// instances, values of on-demand instances differ across providers
+var lifecycleLabels = []struct {
+	Name string
+	Spot string
+}{
+	{"eks.amazonaws.com/capacityType", "SPOT"},
+	{"karpenter.sh/capacity-type", "spot"},
+	{"node.kubernetes.io/lifecycle", "spot"},
+	{"lifecycle", "Ec2Spot"},
+	{"cloud.google.com/gke-spot", "true"},
+	{"cloud.google.com/gke-preemptible", "true"},
+	{"kubernetes.azure.com/scalesetpriority", "spot"},
+}
+
+// getLifecycle returns lifecycle of the node by its labels, an empty string
+// is returned if no lifecycle label is set
+func getLifecycle(labels map[string]string) string {
+	for _, label := range lifecycleLabels {
+		value, ok := labels[label.Name]
+		if !ok {
+			continue
+		}
+
+		if strings.EqualFold(value, label.Spot) {
+			return LifecycleSpot
+		}
+
+		return LifecycleOnDemand
+	}
+
+	return ""
+}
+
+// isInstanceNode checks whether the node is the instance, names of nodes
+// are hostnames of instances which may be fully qualified
+func isInstanceNode(node kuber.Node, instance *Instance) bool {
+	if instance.Hostname == "" {
+		return false
+	}
+
+	if node.Provider != "" && node.Provider != instance.Provider {
+		return false
+	}
+
+	return node.Name == instance.Hostname ||
+		strings.HasPrefix(node.Name, instance.Hostname+".") ||
+		strings.HasPrefix(instance.Hostname, node.Name+".")
+}
+
+// EnrichNodes sets lifecycles and on-demand price hints of nodes by their
+// labels, the node of the instance the agent runs on is completed by the
+// instance metadata
+func EnrichNodes(nodes []kuber.Node) []kuber.Node {
+	instance := getInstance()
+
+	for i := range nodes {
+		node := &nodes[i]
+
+		instanceType := kuber.GetInstanceType(node.Labels)
+		node.Lifecycle = getLifecycle(node.Labels)
+
+		if instance != nil && isInstanceNode(*node, instance) {
+			if node.Region == "" {
+				node.Region = instance.Region
+			}
+
+			if node.Zone == "" {
+				node.Zone = instance.Zone
+			}
+
+			if node.Lifecycle == "" {
+				node.Lifecycle = instance.Lifecycle
+			}
+
+			if instanceType == "" {
+				instanceType = instance.InstanceType
+			}
+		}
+
+		node.OnDemandPrice = GetOnDemandPrice(node.Provider, instanceType)
+	}
+
+	return nodes
+}
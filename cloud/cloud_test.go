@@ -0,0 +1,105 @@
+package cloud
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+func TestGetLifecycle(t *testing.T) {
+	for expected, labels := range map[string][]map[string]string{
+		LifecycleSpot: {
+			{"eks.amazonaws.com/capacityType": "SPOT"},
+			{"karpenter.sh/capacity-type": "spot"},
+			{"cloud.google.com/gke-preemptible": "true"},
+			{"kubernetes.azure.com/scalesetpriority": "spot"},
+		},
+		LifecycleOnDemand: {
+			{"eks.amazonaws.com/capacityType": "ON_DEMAND"},
+			{"karpenter.sh/capacity-type": "on-demand"},
+			{"kubernetes.azure.com/scalesetpriority": "regular"},
+		},
+		"": {
+			{},
+			{"kubernetes.io/os": "linux"},
+		},
+	} {
+		for _, item := range labels {
+			if lifecycle := getLifecycle(item); lifecycle != expected {
+				t.Errorf(
+					"expected lifecycle %q of %v, got %q",
+					expected, item, lifecycle,
+				)
+			}
+		}
+	}
+}
+
+func TestEnrichNodes(t *testing.T) {
+	SetInstance(&Instance{
+		Provider:     ProviderAWS,
+		Hostname:     "ip-10-0-0-1.ec2.internal",
+		Region:       "us-east-1",
+		Zone:         "us-east-1a",
+		InstanceType: "m5.large",
+		Lifecycle:    LifecycleSpot,
+	})
+	defer SetInstance(nil)
+
+	nodes := EnrichNodes([]kuber.Node{
+		{
+			Name:     "ip-10-0-0-1.ec2.internal",
+			Provider: ProviderAWS,
+		},
+		{
+			Name:     "ip-10-0-0-2.ec2.internal",
+			Provider: ProviderAWS,
+			Region:   "us-east-1",
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "c5.xlarge",
+				"eks.amazonaws.com/capacityType":   "ON_DEMAND",
+			},
+		},
+		{
+			Name:     "gke-pool-1",
+			Provider: ProviderGCE,
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "unknown-type",
+			},
+		},
+	})
+
+	expected := []kuber.Node{
+		{
+			Name:          "ip-10-0-0-1.ec2.internal",
+			Provider:      ProviderAWS,
+			Region:        "us-east-1",
+			Zone:          "us-east-1a",
+			Lifecycle:     LifecycleSpot,
+			OnDemandPrice: 0.096,
+		},
+		{
+			Name:          "ip-10-0-0-2.ec2.internal",
+			Provider:      ProviderAWS,
+			Region:        "us-east-1",
+			Lifecycle:     LifecycleOnDemand,
+			OnDemandPrice: 0.17,
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "c5.xlarge",
+				"eks.amazonaws.com/capacityType":   "ON_DEMAND",
+			},
+		},
+		{
+			Name:     "gke-pool-1",
+			Provider: ProviderGCE,
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "unknown-type",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected nodes\n%+v\ngot\n%+v", expected, nodes)
+	}
+}
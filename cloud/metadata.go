@@ -0,0 +1,175 @@
+package cloud
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/imds"
+	"github.com/reconquest/karma-go"
+)
+
+const metadataTimeout = 2 * time.Second
+
+// GetInstance requests metadata of the instance the agent runs on, provider
+// is one of aws, gcp and azure
+func GetInstance(provider string) (*Instance, error) {
+	httpClient := &http.Client{Timeout: metadataTimeout}
+
+	var instance *Instance
+	var err error
+	switch provider {
+	case "aws":
+		instance, err = getAWSInstance(httpClient)
+	case "gcp":
+		instance, err = getGCPInstance(httpClient)
+	case "azure":
+		instance, err = getAzureInstance(httpClient)
+	default:
+		return nil, karma.Format(
+			nil,
+			"unsupported cloud metadata provider %q, expected one of: "+
+				"aws, gcp, azure",
+			provider,
+		)
+	}
+
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to get %s instance metadata", provider,
+		)
+	}
+
+	return instance, nil
+}
+
+func getAWSInstance(httpClient *http.Client) (*Instance, error) {
+	headers := imds.GetAWSHeaders(httpClient)
+
+	instance := &Instance{Provider: ProviderAWS}
+
+	for _, item := range []struct {
+		Path  string
+		Value *string
+	}{
+		{"placement/region", &instance.Region},
+		{"placement/availability-zone", &instance.Zone},
+		{"instance-type", &instance.InstanceType},
+		{"instance-life-cycle", &instance.Lifecycle},
+		{"local-hostname", &instance.Hostname},
+	} {
+		value, err := imds.Request(
+			httpClient,
+			http.MethodGet,
+			imds.AWSURL+"/meta-data/"+item.Path,
+			headers,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		*item.Value = strings.TrimSpace(string(value))
+	}
+
+	return instance, nil
+}
+
+func getGCPInstance(httpClient *http.Client) (*Instance, error) {
+	headers := map[string]string{"Metadata-Flavor": "Google"}
+
+	var zone, machineType, preemptible string
+
+	instance := &Instance{Provider: ProviderGCE}
+
+	for _, item := range []struct {
+		Path  string
+		Value *string
+	}{
+		// NOTE: zone and machine type are returned as resource paths, e.g.
+		// projects/123/zones/us-central1-a
+		{"instance/zone", &zone},
+		{"instance/machine-type", &machineType},
+		{"instance/scheduling/preemptible", &preemptible},
+		{"instance/name", &instance.Hostname},
+	} {
+		value, err := imds.Request(
+			httpClient,
+			http.MethodGet,
+			imds.GCPURL+"/"+item.Path,
+			headers,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		*item.Value = strings.TrimSpace(string(value))
+	}
+
+	instance.Zone = path.Base(zone)
+	if index := strings.LastIndex(instance.Zone, "-"); index > 0 {
+		instance.Region = instance.Zone[:index]
+	}
+
+	instance.InstanceType = path.Base(machineType)
+
+	instance.Lifecycle = LifecycleOnDemand
+	if strings.EqualFold(preemptible, "true") {
+		instance.Lifecycle = LifecycleSpot
+	}
+
+	return instance, nil
+}
+
+func getAzureInstance(httpClient *http.Client) (*Instance, error) {
+	query := url.Values{}
+	query.Set("api-version", "2021-02-01")
+
+	body, err := imds.Request(
+		httpClient,
+		http.MethodGet,
+		imds.AzureURL+"/instance/compute?"+query.Encode(),
+		map[string]string{"Metadata": "true"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var compute struct {
+		Location  string `json:"location"`
+		Zone      string `json:"zone"`
+		VMSize    string `json:"vmSize"`
+		Priority  string `json:"priority"`
+		OSProfile struct {
+			ComputerName string `json:"computerName"`
+		} `json:"osProfile"`
+	}
+
+	err = json.Unmarshal(body, &compute)
+	if err != nil {
+		return nil, karma.Format(err, "unable to decode instance metadata")
+	}
+
+	instance := &Instance{
+		Provider:     ProviderAzure,
+		Hostname:     compute.OSProfile.ComputerName,
+		Region:       compute.Location,
+		InstanceType: compute.VMSize,
+		Lifecycle:    LifecycleOnDemand,
+	}
+
+	// NOTE: zones of azure are numbers within the location, nodes are
+	// labeled by zones prefixed with the location
+	if compute.Zone != "" {
+		instance.Zone = compute.Location + "-" + compute.Zone
+	}
+
+	if strings.EqualFold(compute.Priority, "spot") {
+		instance.Lifecycle = LifecycleSpot
+	}
+
+	return instance, nil
+}
@@ -0,0 +1,56 @@
+package cloud
+
+// onDemandPrices hourly prices (USD) of on-demand linux instances of common
+// types in us-east-1, us-central1 and eastus, prices vary across regions so
+// they're hints to rank nodes by cost rather than bills
+var onDemandPrices = map[string]map[string]float64{
+	ProviderAWS: {
+		"t3.medium":  0.0416,
+		"t3.large":   0.0832,
+		"t3.xlarge":  0.1664,
+		"t3.2xlarge": 0.3328,
+		"m5.large":   0.096,
+		"m5.xlarge":  0.192,
+		"m5.2xlarge": 0.384,
+		"m5.4xlarge": 0.768,
+		"c5.large":   0.085,
+		"c5.xlarge":  0.17,
+		"c5.2xlarge": 0.34,
+		"c5.4xlarge": 0.68,
+		"r5.large":   0.126,
+		"r5.xlarge":  0.252,
+		"r5.2xlarge": 0.504,
+		"r5.4xlarge": 1.008,
+	},
+	ProviderGCE: {
+		"e2-standard-2":  0.067,
+		"e2-standard-4":  0.134,
+		"e2-standard-8":  0.268,
+		"e2-standard-16": 0.536,
+		"n1-standard-1":  0.0475,
+		"n1-standard-2":  0.095,
+		"n1-standard-4":  0.19,
+		"n1-standard-8":  0.38,
+		"n2-standard-2":  0.0971,
+		"n2-standard-4":  0.1942,
+		"n2-standard-8":  0.3885,
+	},
+	ProviderAzure: {
+		"Standard_B2s":     0.0416,
+		"Standard_B2ms":    0.0832,
+		"Standard_D2s_v3":  0.096,
+		"Standard_D4s_v3":  0.192,
+		"Standard_D8s_v3":  0.384,
+		"Standard_D16s_v3": 0.768,
+		"Standard_DS2_v2":  0.146,
+		"Standard_DS3_v2":  0.293,
+		"Standard_E2s_v3":  0.126,
+		"Standard_E4s_v3":  0.252,
+	},
+}
+
+// GetOnDemandPrice returns the hourly on-demand price hint of the instance
+// type, zero is returned for unknown types
+func GetOnDemandPrice(provider string, instanceType string) float64 {
+	return onDemandPrices[provider][instanceType]
+}
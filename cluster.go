@@ -0,0 +1,321 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/events"
+	"github.com/MagalixCorp/magalix-agent/executor"
+	"github.com/MagalixCorp/magalix-agent/freeze"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/metrics"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scalar"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/shard"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// clusterStateFlags flags of state files which can't be shared by clusters
+var clusterStateFlags = []string{
+	"--executions-state",
+	"--metrics-state",
+	"--entities-state",
+	"--execution-retries-state",
+	"--freeze-state",
+	"--deferred-decisions-state",
+}
+
+// clusterOptions options shared by pipelines of all clusters
+type clusterOptions struct {
+	skipNamespaces          []string
+	environmentRules        []scanner.EnvironmentRule
+	optInAnalysisData       bool
+	analysisDataInterval    time.Duration
+	entitiesResyncInterval  time.Duration
+	kubeAPIBudget           int
+	maxConcurrentExecutions int
+
+	metricsEnabled bool
+	eventsEnabled  bool
+	scalarEnabled  bool
+	summaryEnabled bool
+	dryRunExecutor bool
+	scalarOptions  scalar.Options
+}
+
+// cluster scanner, metrics and executor pipeline of a single cluster
+type cluster struct {
+	client   *client.Client
+	scanner  *scanner.Scanner
+	executor *executor.Executor
+	freezer  *freeze.Freezer
+}
+
+// startCluster starts pipeline of the cluster
+func startCluster(
+	args map[string]interface{},
+	logger *log.Logger,
+	gwClient *client.Client,
+	kube *kuber.Kube,
+	executorKube *kuber.Kube,
+	freezer *freeze.Freezer,
+	options clusterOptions,
+) *cluster {
+	// NOTE: the agent runs with all features assumed available if the
+	// cluster can't be inspected
+	capabilities, err := kube.DetectCapabilities()
+	if err != nil {
+		gwClient.Errorf(err, "unable to detect kubernetes capabilities")
+	} else {
+		err = capabilities.Validate()
+		if err != nil {
+			gwClient.Fatalf(err, "unsupported kubernetes cluster")
+			os.Exit(1)
+		}
+
+		gwClient.Infof(
+			karma.
+				Describe("version", capabilities.Version).
+				Describe("platform", capabilities.Platform).
+				Describe("unsupported", capabilities.GetUnsupported()),
+			"detected kubernetes capabilities",
+		)
+	}
+
+	// NOTE: the executor kube is created before capabilities are detected,
+	// it may also use a separate identity which never detects them
+	executorKube.ShareCapabilities(kube)
+
+	entitiesState, _ := args["--entities-state"].(string)
+
+	entityScanner := scanner.InitScanner(
+		gwClient,
+		kube,
+		options.skipNamespaces,
+		gwClient.AccountID,
+		gwClient.ClusterID,
+		options.environmentRules,
+		options.optInAnalysisData,
+		options.analysisDataInterval,
+		options.entitiesResyncInterval,
+		options.kubeAPIBudget,
+		entitiesState,
+	)
+
+	executionsState, _ := args["--executions-state"].(string)
+	retriesState, _ := args["--execution-retries-state"].(string)
+	deferredState, _ := args["--deferred-decisions-state"].(string)
+
+	e, err := executor.InitExecutor(
+		gwClient,
+		executorKube,
+		entityScanner,
+		options.dryRunExecutor,
+		args["--execute-increases-only"].(bool),
+		utils.MustParseDuration(args, "--decisions-coalescing-window"),
+		options.maxConcurrentExecutions,
+		executionsState,
+		executor.RetryOptions{
+			MaxAttempts: utils.MustParseInt(args, "--execution-retries"),
+			Backoff:     utils.MustParseDuration(args, "--execution-retry-backoff"),
+			StatePath:   retriesState,
+		},
+		getWritebackOptions(args),
+		utils.MustParseDuration(args, "--reversion-window"),
+		freezer,
+		deferredState,
+	)
+	if err != nil {
+		gwClient.Fatalf(err, "unable to initialize executor")
+		os.Exit(1)
+	}
+
+	// NOTE: decisions, events and scalars aren't partitioned by namespaces,
+	// so they are handled by the primary shard only
+	if shard.IsPrimary() {
+		gwClient.AddListener(proto.PacketKindDecision, e.Listener)
+	}
+	gwClient.AddListener(proto.PacketKindLogLevel, gwClient.LogLevelListener)
+	gwClient.AddListener(proto.PacketKindFreeze, freezer.Listener)
+
+	if options.eventsEnabled && shard.IsPrimary() {
+		events.InitEvents(
+			gwClient,
+			kube,
+			options.skipNamespaces,
+			entityScanner,
+			args,
+		)
+	}
+
+	if options.metricsEnabled {
+		err := metrics.InitMetrics(
+			gwClient,
+			entityScanner,
+			kube,
+			options.optInAnalysisData,
+			args,
+		)
+		if err != nil {
+			gwClient.Fatalf(err, "unable to initialize metrics sources")
+			os.Exit(1)
+		}
+	}
+
+	// NOTE: capabilities are sent once metrics sources detected kubelet
+	// features
+	entityScanner.SendCapabilities()
+
+	if options.scalarEnabled && shard.IsPrimary() {
+		scalarOptions := options.scalarOptions
+		scalarOptions.Freezer = freezer
+
+		scalar.InitScalars(
+			logger, gwClient, entityScanner, executorKube, scalarOptions,
+		)
+	}
+
+	if options.summaryEnabled {
+		entityScanner.StartNamespacesSummary(
+			utils.MustParseDuration(args, "--namespaces-summary-interval"),
+		)
+	}
+
+	return &cluster{
+		client:   gwClient,
+		scanner:  entityScanner,
+		executor: e,
+		freezer:  freezer,
+	}
+}
+
+// startKubeconfigClusters starts pipelines of all contexts of kubeconfig
+// files in the directory, contexts are named by cluster IDs, clusters other
+// than the cluster of the client are attached to its connection and have
+// freezers of their own
+func startKubeconfigClusters(
+	args map[string]interface{},
+	logger *log.Logger,
+	gwClient *client.Client,
+	dir string,
+	freezer *freeze.Freezer,
+	options clusterOptions,
+) ([]*cluster, error) {
+	contexts, err := kuber.ListKubeconfigContexts(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contexts) == 0 {
+		return nil, karma.
+			Describe("dir", dir).
+			Format(nil, "no kubeconfig contexts found")
+	}
+
+	clusters := []*cluster{}
+	clients := map[uuid.UUID]*client.Client{}
+	for _, context := range contexts {
+		clusterID, err := uuid.FromString(context.Name)
+		if err != nil {
+			return nil, karma.
+				Describe("path", context.Path).
+				Describe("context", context.Name).
+				Format(err, "kubeconfig context should be named by cluster ID")
+		}
+
+		if _, ok := clients[clusterID]; ok {
+			return nil, karma.
+				Describe("path", context.Path).
+				Describe("context", context.Name).
+				Format(nil, "duplicate kubeconfig context")
+		}
+
+		clusterClient := gwClient
+		clusterArgs := args
+		clusterFreezer := freezer
+		if clusterID != gwClient.ClusterID {
+			clusterClient = gwClient.AttachCluster(clusterID)
+			clusterArgs = getClusterArgs(args, clusterID)
+
+			freezeState, _ := clusterArgs["--freeze-state"].(string)
+			clusterFreezer, err = freeze.NewFreezer(clusterClient.Logger, freezeState)
+			if err != nil {
+				return nil, karma.
+					Describe("context", context.Name).
+					Format(err, "unable to restore freeze state of cluster")
+			}
+		}
+
+		clients[clusterID] = clusterClient
+
+		kube, err := kuber.InitKubeconfigKubernetes(clusterArgs, clusterClient, context)
+		if err != nil {
+			return nil, err
+		}
+
+		executorKube, err := kube.WithPriority(kuber.PriorityNormal)
+		if err != nil {
+			return nil, err
+		}
+
+		clusters = append(clusters, startCluster(
+			clusterArgs,
+			logger,
+			clusterClient,
+			kube,
+			executorKube,
+			clusterFreezer,
+			options,
+		))
+	}
+
+	return clusters, nil
+}
+
+// getClusterArgs returns a copy of args with state files specific to the
+// attached cluster
+func getClusterArgs(
+	args map[string]interface{},
+	clusterID uuid.UUID,
+) map[string]interface{} {
+	clusterArgs := map[string]interface{}{}
+	for key, value := range args {
+		clusterArgs[key] = value
+	}
+
+	for _, flag := range clusterStateFlags {
+		if path, ok := args[flag].(string); ok && path != "" {
+			clusterArgs[flag] = path + "." + clusterID.String()
+		}
+	}
+
+	return clusterArgs
+}
+
+// getWritebackOptions returns options of git writeback of the executor,
+// writeback is disabled unless a repository is specified
+func getWritebackOptions(args map[string]interface{}) executor.WritebackOptions {
+	repository, _ := args["--git-writeback"].(string)
+	if repository == "" {
+		return executor.WritebackOptions{}
+	}
+
+	provider, _ := args["--git-writeback-provider"].(string)
+	apiURL, _ := args["--git-writeback-api-url"].(string)
+
+	return executor.WritebackOptions{
+		Repository:  repository,
+		Branch:      args["--git-writeback-branch"].(string),
+		Path:        args["--git-writeback-path"].(string),
+		AuthorName:  args["--git-writeback-author"].(string),
+		AuthorEmail: args["--git-writeback-email"].(string),
+		Provider:    provider,
+		APIURL:      apiURL,
+		Token:       utils.ExpandEnv(args, "--git-writeback-token", true),
+	}
+}
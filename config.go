@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+)
+
+// configEnvFallbacks environment variables used if flags are not specified
+var configEnvFallbacks = map[string]string{
+	"--kube-token": "KUBE_TOKEN",
+}
+
+// configIgnoredFlags flags which don't configure the agent
+var configIgnoredFlags = map[string]struct{}{
+	"--help":      {},
+	"--version":   {},
+	"--effective": {},
+}
+
+// getEffectiveConfig returns values of all flags with environment variables
+// resolved and sensitive values redacted
+func getEffectiveConfig(args map[string]interface{}) map[string]interface{} {
+	config := map[string]interface{}{}
+	for flag, value := range args {
+		if !strings.HasPrefix(flag, "--") {
+			continue
+		}
+
+		if _, ok := configIgnoredFlags[flag]; ok {
+			continue
+		}
+
+		if text, ok := value.(string); ok && strings.HasPrefix(text, "$") {
+			value = os.Getenv(text[1:])
+		}
+
+		if env, ok := configEnvFallbacks[flag]; ok && (value == nil || value == "") {
+			value = os.Getenv(env)
+		}
+
+		switch typed := value.(type) {
+		case string:
+			value = redactConfigValue(flag, typed)
+		case []string:
+			values := make([]string, len(typed))
+			for i, item := range typed {
+				values[i] = redactConfigValue(flag, item)
+			}
+			value = values
+		}
+
+		config[flag] = value
+	}
+
+	return config
+}
+
+// configSensitiveParameters parts of names of url query parameters which
+// values are redacted, e.g. token or access_token
+var configSensitiveParameters = []string{
+	"token",
+	"secret",
+	"password",
+	"key",
+	"signature",
+}
+
+// redactConfigValue hides values of secrets and tokens, passwords of urls
+// and sensitive query parameters of urls, only length of the hidden value is
+// kept
+func redactConfigValue(flag string, value string) string {
+	if value == "" {
+		return value
+	}
+
+	if strings.Contains(flag, "secret") || strings.Contains(flag, "token") {
+		return getSensitivePlaceholder(value)
+	}
+
+	// NOTE: urls are not reencoded, so redacted urls look as specified
+	scheme := strings.Index(value, "://")
+	if scheme < 0 {
+		return value
+	}
+
+	return redactURLQuery(redactURLPassword(value, scheme))
+}
+
+func getSensitivePlaceholder(value string) string {
+	return "<sensitive:" + fmt.Sprint(len(value)) + ">"
+}
+
+// redactURLPassword hides password of userinfo of the url
+func redactURLPassword(value string, scheme int) string {
+	rest := value[scheme+len("://"):]
+	at := strings.Index(rest, "@")
+	slash := strings.Index(rest, "/")
+	if at < 0 || (slash >= 0 && slash < at) {
+		return value
+	}
+
+	userinfo := rest[:at]
+	colon := strings.Index(userinfo, ":")
+	if colon < 0 {
+		return value
+	}
+
+	return value[:scheme+len("://")] + userinfo[:colon+1] +
+		getSensitivePlaceholder(userinfo[colon+1:]) +
+		rest[at:]
+}
+
+// redactURLQuery hides values of query parameters which names look
+// sensitive, e.g. ?token= or ?access_token=
+func redactURLQuery(value string) string {
+	question := strings.Index(value, "?")
+	if question < 0 {
+		return value
+	}
+
+	query := value[question+1:]
+	fragment := ""
+	if hash := strings.Index(query, "#"); hash >= 0 {
+		query, fragment = query[:hash], query[hash:]
+	}
+
+	parameters := strings.Split(query, "&")
+	for i, parameter := range parameters {
+		equal := strings.Index(parameter, "=")
+		if equal < 0 || equal == len(parameter)-1 {
+			continue
+		}
+
+		name := strings.ToLower(parameter[:equal])
+		for _, sensitive := range configSensitiveParameters {
+			if strings.Contains(name, sensitive) {
+				parameters[i] = parameter[:equal+1] +
+					getSensitivePlaceholder(parameter[equal+1:])
+				break
+			}
+		}
+	}
+
+	return value[:question+1] + strings.Join(parameters, "&") + fragment
+}
+
+// runConfig prints effective configuration of the agent
+func runConfig(args map[string]interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(getEffectiveConfig(args))
+}
+
+// sendEffectiveConfig reports effective configuration of the agent to the
+// gateway
+func sendEffectiveConfig(gwClient *client.Client, args map[string]interface{}) {
+	gwClient.Pipe(client.Package{
+		Kind:        proto.PacketKindAgentConfig,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    3,
+		Retries:     10,
+		Data: proto.PacketAgentConfig{
+			Timestamp: time.Now().UTC(),
+			Version:   version,
+			Config:    getEffectiveConfig(args),
+		},
+	})
+}
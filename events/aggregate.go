@@ -0,0 +1,88 @@
+package events
+
+import (
+	"encoding/json"
+	"hash/fnv"
+
+	"github.com/MagalixCorp/magalix-agent/watcher"
+)
+
+// aggregationKey events of the same entity with the same kind and the same
+// value and meta are repeated occurrences of one event
+type aggregationKey struct {
+	Entity   string
+	EntityID string
+	Kind     string
+	Hash     uint64
+}
+
+// aggregateEvents merges repeated occurrences of events within a batch into
+// a single event with the count of occurrences, the timestamp of the event
+// is the timestamp of its last occurrence, order of first occurrences is
+// kept
+func aggregateEvents(events []watcher.Event) []watcher.Event {
+	if len(events) < 2 {
+		return events
+	}
+
+	aggregated := make([]watcher.Event, 0, len(events))
+	indexes := map[aggregationKey]int{}
+
+	for _, event := range events {
+		key, ok := getAggregationKey(event)
+		if !ok {
+			aggregated = append(aggregated, event)
+			continue
+		}
+
+		index, ok := indexes[key]
+		if !ok {
+			indexes[key] = len(aggregated)
+			aggregated = append(aggregated, event)
+			continue
+		}
+
+		first := &aggregated[index]
+		if first.Count == 0 {
+			first.Count = 1
+			firstAt := first.Timestamp
+			first.FirstTimestamp = &firstAt
+		}
+
+		first.Count++
+		if event.Timestamp.Before(*first.FirstTimestamp) {
+			firstAt := event.Timestamp
+			first.FirstTimestamp = &firstAt
+		}
+
+		if event.Timestamp.After(first.Timestamp) {
+			first.Timestamp = event.Timestamp
+		}
+
+		lastAt := first.Timestamp
+		first.LastTimestamp = &lastAt
+	}
+
+	return aggregated
+}
+
+// getAggregationKey returns the key of the event, events which value or meta
+// can't be encoded are never aggregated
+func getAggregationKey(event watcher.Event) (aggregationKey, bool) {
+	hash := fnv.New64a()
+	for _, item := range []interface{}{event.Value, event.Meta, event.Source} {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return aggregationKey{}, false
+		}
+
+		hash.Write(data)
+	}
+
+	return aggregationKey{
+		Entity:   event.Entity,
+		EntityID: event.EntityID,
+		Kind:     event.Kind,
+		Hash:     hash.Sum64(),
+	}, true
+}
@@ -28,17 +28,35 @@ type Eventer struct {
 	observer *proc.Observer
 	proc     *proc.Proc
 	buffer   chan watcher.Event
+	// flush requests to flush buffered events, closed once flushed
+	flush chan chan struct{}
 
 	last map[EventIdentifier]interface{}
 
 	bufferFlushInterval time.Duration
 	bufferSize          int
 
+	// crashLogsLines count of lines of logs captured of crashed containers,
+	// zero disables capturing
+	crashLogsLines int
+
 	skipNamespaces []string
 	scanner        *scanner.Scanner
+	kube           *kuber.Kube
 
 	oomKilled chan uuid.UUID
 
+	// restarts last observed restart counts of pods containers
+	restarts      map[string]int32
+	restartsMutex sync.Mutex
+
+	// bootIDs last observed boot ids of nodes, systemOOMs last observed
+	// counts of nodes system oom events
+	bootIDs          map[string]string
+	systemOOMs       map[string]int32
+	systemOOMsSynced bool
+	nodesMutex       sync.Mutex
+
 	m sync.Mutex
 }
 
@@ -53,6 +71,7 @@ func InitEvents(
 	eventsBufferFlushInterval := utils.MustParseDuration(args, "--events-buffer-flush-interval")
 	eventsBufferSize := utils.MustParseInt(args, "--events-buffer-size")
 	eventer := NewEventer(client, kube, skipNamespaces, scanner, eventsBufferFlushInterval, eventsBufferSize)
+	eventer.crashLogsLines = utils.MustParseInt(args, "--capture-crash-logs-lines")
 	eventer.Start()
 	return eventer
 }
@@ -75,6 +94,12 @@ func NewEventer(
 
 		skipNamespaces: skipNamespaces,
 		scanner:        scanner,
+		kube:           kube,
+
+		restarts: map[string]int32{},
+
+		bootIDs:    map[string]string{},
+		systemOOMs: map[string]int32{},
 
 		m: sync.Mutex{},
 	}
@@ -92,7 +117,8 @@ func NewEventer(
 
 	observer := proc.NewObserver(
 		kube.Clientset,
-		kube.ClientV1Beta2,
+		kube.GetAppsClient(),
+		kube.GetAppsVersion(),
 		kube.ClientBatch,
 		eventer,
 		health,
@@ -120,6 +146,9 @@ func (eventer *Eventer) Start() {
 	go eventer.observer.Start()
 	eventer.proc.Start()
 	eventer.startBatchWriter()
+	eventer.client.AddShutdownHook("events", eventer.flushEvents)
+	eventer.startRestartsWatcher()
+	eventer.startNodesWatcher()
 }
 
 // GetApplicationDesiredServices returns desired services of an application
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixCorp/magalix-agent/watcher"
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+const (
+	nodesCheckInterval = time.Minute
+
+	// EventsOriginNodes origin of events detected from nodes statuses and
+	// kubernetes events of nodes
+	EventsOriginNodes = "nodes"
+
+	// EventKindRebooted node was rebooted, its boot id changed
+	EventKindRebooted = "rebooted"
+	// EventKindSystemOOM kernel OOM killer killed a process on the node
+	EventKindSystemOOM = "system_oom"
+
+	systemOOMReason = "SystemOOM"
+)
+
+// NodeReboot details of a node reboot
+type NodeReboot struct {
+	Name           string `json:"name"`
+	PreviousBootID string `json:"previous_boot_id"`
+	BootID         string `json:"boot_id"`
+}
+
+// NodeSystemOOM details of a kernel OOM kill on a node
+type NodeSystemOOM struct {
+	Name    string    `json:"name"`
+	Message string    `json:"message"`
+	Count   int32     `json:"count"`
+	LastAt  time.Time `json:"last_at"`
+}
+
+func (eventer *Eventer) startNodesWatcher() {
+	ticker := utils.NewTicker(eventer.client.Logger, "nodes-events", nodesCheckInterval, func(tickTime time.Time) {
+		eventer.checkReboots(tickTime)
+		eventer.checkSystemOOMs(tickTime)
+	})
+
+	ticker.Start(false, false, false)
+}
+
+// checkReboots compares boot ids of nodes with the previous check and writes
+// an event for every rebooted node
+func (eventer *Eventer) checkReboots(tickTime time.Time) {
+	eventer.nodesMutex.Lock()
+	defer eventer.nodesMutex.Unlock()
+
+	seen := map[string]struct{}{}
+
+	for _, node := range eventer.scanner.GetNodes() {
+		if node.BootID == "" {
+			continue
+		}
+
+		seen[node.Name] = struct{}{}
+
+		last, ok := eventer.bootIDs[node.Name]
+		eventer.bootIDs[node.Name] = node.BootID
+
+		if !ok || last == node.BootID {
+			continue
+		}
+
+		event := watcher.NewEvent(
+			tickTime,
+			watcher.Identity{
+				AccountID: eventer.client.AccountID,
+			},
+			"node", node.ID.String(),
+			EventKindRebooted, node.BootID,
+			EventsOriginNodes,
+		)
+		event.Meta = NodeReboot{
+			Name:           node.Name,
+			PreviousBootID: last,
+			BootID:         node.BootID,
+		}
+
+		_ = eventer.WriteEvent(&event)
+	}
+
+	for name := range eventer.bootIDs {
+		if _, ok := seen[name]; !ok {
+			delete(eventer.bootIDs, name)
+		}
+	}
+}
+
+// checkSystemOOMs writes an event for every kernel OOM kill reported by
+// kubelets since the previous check
+func (eventer *Eventer) checkSystemOOMs(tickTime time.Time) {
+	events, err := eventer.kube.GetNodesEvents(systemOOMReason)
+	if err != nil {
+		eventer.client.Errorf(err, "{eventer} unable to get system oom events")
+		return
+	}
+
+	nodes := map[string]string{}
+	for _, node := range eventer.scanner.GetNodes() {
+		nodes[node.Name] = node.ID.String()
+	}
+
+	eventer.nodesMutex.Lock()
+	defer eventer.nodesMutex.Unlock()
+
+	seen := map[string]struct{}{}
+
+	for _, kevent := range events.Items {
+		key := string(kevent.UID)
+		seen[key] = struct{}{}
+
+		last, ok := eventer.systemOOMs[key]
+		eventer.systemOOMs[key] = kevent.Count
+
+		// NOTE: events happened before the agent started are skipped, an
+		// event is counted again when the kubelet increments its count
+		if !eventer.systemOOMsSynced || (ok && kevent.Count <= last) {
+			continue
+		}
+
+		nodeID, ok := nodes[kevent.InvolvedObject.Name]
+		if !ok {
+			eventer.client.Debugf(
+				karma.Describe("node", kevent.InvolvedObject.Name),
+				"{eventer} unable to identify node of system oom event",
+			)
+			continue
+		}
+
+		event := watcher.NewEvent(
+			getEventTime(kevent, tickTime),
+			watcher.Identity{
+				AccountID: eventer.client.AccountID,
+			},
+			"node", nodeID,
+			EventKindSystemOOM, kevent.Count,
+			EventsOriginNodes,
+		)
+		event.Meta = NodeSystemOOM{
+			Name:    kevent.InvolvedObject.Name,
+			Message: kevent.Message,
+			Count:   kevent.Count,
+			LastAt:  getEventTime(kevent, tickTime),
+		}
+
+		_ = eventer.WriteEvent(&event)
+	}
+
+	eventer.systemOOMsSynced = true
+
+	for key := range eventer.systemOOMs {
+		if _, ok := seen[key]; !ok {
+			delete(eventer.systemOOMs, key)
+		}
+	}
+}
+
+func getEventTime(event kv1.Event, fallback time.Time) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	return fallback
+}
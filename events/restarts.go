@@ -0,0 +1,208 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/metrics"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixCorp/magalix-agent/watcher"
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+const (
+	restartsCheckInterval = time.Minute
+
+	// EventsOriginRestarts origin of events enriched from pods statuses
+	EventsOriginRestarts = "restarts"
+
+	// EventKindOOMKilled container was terminated by the OOM killer
+	EventKindOOMKilled = "oom_killed"
+	// EventKindRestarted container was restarted for any other reason
+	EventKindRestarted = "restarted"
+
+	oomKilledReason = "OOMKilled"
+)
+
+// ContainerRestart details of a container restart correlated with its
+// resources and last collected metrics
+type ContainerRestart struct {
+	PodName      string    `json:"pod_name"`
+	RestartCount int32     `json:"restart_count"`
+	Reason       string    `json:"reason,omitempty"`
+	ExitCode     int32     `json:"exit_code"`
+	FinishedAt   time.Time `json:"finished_at,omitempty"`
+
+	// Logs last lines of logs of the crashed container, captured only if
+	// the container was OOM killed or exited with non-zero code
+	Logs string `json:"logs,omitempty"`
+
+	// MemoryLimit memory limit of the container when it was killed
+	MemoryLimit *int64 `json:"memory_limit,omitempty"`
+	// MemoryRSS last memory/rss sample collected before the restart
+	MemoryRSS          *int64     `json:"memory_rss,omitempty"`
+	MemoryRSSTimestamp *time.Time `json:"memory_rss_timestamp,omitempty"`
+}
+
+func (eventer *Eventer) startRestartsWatcher() {
+	ticker := utils.NewTicker(eventer.client.Logger, "restarts", restartsCheckInterval, func(tickTime time.Time) {
+		eventer.checkRestarts(tickTime)
+	})
+
+	ticker.Start(false, false, false)
+}
+
+// restartedContainer a container which restart count grew since the
+// previous check
+type restartedContainer struct {
+	pod    kv1.Pod
+	status kv1.ContainerStatus
+}
+
+// checkRestarts compares restart counts of containers with the previous
+// check and writes an enriched event for every restarted container, crash
+// logs are fetched without holding the lock of restart counts
+func (eventer *Eventer) checkRestarts(tickTime time.Time) {
+	for _, restarted := range eventer.getRestartedContainers() {
+		pod, status := restarted.pod, restarted.status
+
+		event, ok := eventer.getRestartEvent(tickTime, pod, status)
+		if !ok {
+			eventer.client.Debugf(
+				karma.
+					Describe("namespace", pod.Namespace).
+					Describe("pod", pod.Name).
+					Describe("container", status.Name),
+				"{eventer} unable to identify restarted container",
+			)
+			continue
+		}
+
+		_ = eventer.WriteEvent(event)
+	}
+}
+
+// getRestartedContainers updates restart counts of containers and returns
+// containers restarted since the previous check
+func (eventer *Eventer) getRestartedContainers() []restartedContainer {
+	eventer.restartsMutex.Lock()
+	defer eventer.restartsMutex.Unlock()
+
+	var restarted []restartedContainer
+	seen := map[string]struct{}{}
+
+	for _, pod := range eventer.scanner.GetPods() {
+		for _, status := range pod.Status.ContainerStatuses {
+			key := fmt.Sprintf("%s:%s", pod.UID, status.Name)
+			seen[key] = struct{}{}
+
+			last, ok := eventer.restarts[key]
+			eventer.restarts[key] = status.RestartCount
+
+			// NOTE: restarts happened before the agent started are already
+			// reported by the pods statuses
+			if !ok || status.RestartCount <= last {
+				continue
+			}
+
+			restarted = append(restarted, restartedContainer{
+				pod:    pod,
+				status: status,
+			})
+		}
+	}
+
+	for key := range eventer.restarts {
+		if _, ok := seen[key]; !ok {
+			delete(eventer.restarts, key)
+		}
+	}
+
+	return restarted
+}
+
+func (eventer *Eventer) getRestartEvent(
+	timestamp time.Time,
+	pod kv1.Pod,
+	status kv1.ContainerStatus,
+) (*watcher.Event, bool) {
+	container, service, app, ok := eventer.scanner.FindContainerWithParents(
+		pod.Namespace,
+		pod.Name,
+		status.Name,
+	)
+	if !ok {
+		return nil, false
+	}
+
+	restart := ContainerRestart{
+		PodName:      pod.Name,
+		RestartCount: status.RestartCount,
+	}
+
+	kind := EventKindRestarted
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		restart.Reason = terminated.Reason
+		restart.ExitCode = terminated.ExitCode
+		restart.FinishedAt = terminated.FinishedAt.Time
+
+		if terminated.Reason == oomKilledReason {
+			kind = EventKindOOMKilled
+		}
+
+		if terminated.ExitCode != 0 || terminated.Reason == oomKilledReason {
+			restart.Logs = eventer.getCrashLogs(pod, status.Name)
+		}
+	}
+
+	if container.Resources != nil {
+		limits := container.Resources.SpecResourceRequirements.Limits
+		if memory, ok := limits[kv1.ResourceMemory]; ok {
+			value := memory.Value()
+			restart.MemoryLimit = &value
+		}
+	}
+
+	if sample, ok := metrics.GetLastContainerSample(container.ID, pod.Name); ok {
+		restart.MemoryRSS = &sample.Value
+		restart.MemoryRSSTimestamp = &sample.Timestamp
+	}
+
+	event := watcher.NewEvent(
+		timestamp,
+		watcher.Identity{
+			AccountID:     eventer.client.AccountID,
+			ApplicationID: app.ID,
+			ServiceID:     service.ID,
+		},
+		"container", container.ID.String(),
+		kind, status.RestartCount,
+		EventsOriginRestarts,
+	)
+	event.ContainerID = &container.ID
+	event.Meta = restart
+
+	return &event, true
+}
+
+// getCrashLogs returns last lines of logs of the previous container if
+// capturing is enabled, failures are not fatal for the event
+func (eventer *Eventer) getCrashLogs(pod kv1.Pod, container string) string {
+	if eventer.crashLogsLines <= 0 {
+		return ""
+	}
+
+	logs, err := eventer.kube.GetPreviousContainerLogs(
+		pod.Namespace,
+		pod.Name,
+		container,
+		int64(eventer.crashLogsLines),
+	)
+	if err != nil {
+		eventer.client.Warningf(err, "{eventer} unable to capture crash logs")
+		return ""
+	}
+
+	return logs
+}
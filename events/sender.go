@@ -13,6 +13,7 @@ import (
 
 func (eventer *Eventer) startBatchWriter() {
 	eventer.buffer = make(chan watcher.Event, eventer.bufferSize)
+	eventer.flush = make(chan chan struct{})
 
 	go func() {
 		ticker := time.NewTicker(eventer.bufferFlushInterval)
@@ -25,6 +26,18 @@ func (eventer *Eventer) startBatchWriter() {
 				events = append(events, event)
 			case <-ticker.C:
 				timeout = true
+			case flushed := <-eventer.flush:
+				// NOTE: events are sent synchronously, so they are piped
+				// by the time the flush is done
+				for len(eventer.buffer) > 0 {
+					events = append(events, <-eventer.buffer)
+				}
+
+				eventer.sendEvents(events)
+
+				events = []watcher.Event{}
+				close(flushed)
+				continue
 			}
 
 			if len(events) == 0 {
@@ -40,7 +53,16 @@ func (eventer *Eventer) startBatchWriter() {
 	}()
 }
 
+// flushEvents sends buffered events
+func (eventer *Eventer) flushEvents() {
+	flushed := make(chan struct{})
+	eventer.flush <- flushed
+	<-flushed
+}
+
 func (eventer *Eventer) sendEvents(events []watcher.Event) {
+	events = aggregateEvents(events)
+
 	newEvents := make([]watcher.Event, 0, len(events))
 	eventer.m.Lock()
 	defer eventer.m.Unlock()
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// reasonAdmissionRejected reason of events of decisions denied by admission
+// webhooks
+const reasonAdmissionRejected = "MagalixDecisionRejected"
+
+// reportAdmissionRejection describes the failed response if changes were
+// denied by an admission webhook and creates a warning event of the
+// workload, so users know that the change was blocked by a policy of the
+// cluster rather than by the agent
+func (executor *Executor) reportAdmissionRejection(
+	ctx *karma.Context,
+	decision proto.Decision,
+	response *proto.DecisionExecutionResponse,
+	namespace, name, kind string,
+	err error,
+) {
+	rejection, ok := kuber.GetAdmissionRejection(err)
+	if !ok {
+		return
+	}
+
+	response.AdmissionRejection = &proto.AdmissionRejection{
+		Webhook: rejection.Webhook,
+		Message: rejection.Message,
+	}
+	response.Message = fmt.Sprintf(
+		"changes were denied by admission webhook %s: %s",
+		rejection.Webhook, rejection.Message,
+	)
+
+	workload := kuber.WorkloadReference{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+	}
+	if service := findService(executor.scanner.GetApplications(), decision); service != nil {
+		workload.UID = service.GetUID()
+	}
+
+	err = executor.kube.CreateWarningEvent(
+		workload,
+		reasonAdmissionRejected,
+		fmt.Sprintf(
+			"decision %s was denied by admission webhook %s: %s",
+			decision.ID, rejection.Webhook, rejection.Message,
+		),
+	)
+	if err != nil {
+		executor.logger.Errorf(
+			ctx.Reason(err),
+			"unable to create event of admission rejection",
+		)
+	}
+}
@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+// coalescedDecision decisions of the same service received within the
+// coalescing window merged into a single decision
+type coalescedDecision struct {
+	decision proto.Decision
+	ids      []uuid.UUID
+
+	done      chan struct{}
+	responses proto.PacketDecisionsResponse
+}
+
+// decisionsCoalescer merges decisions of the same service received within a
+// window to avoid back-to-back rollouts of the same workload
+type decisionsCoalescer struct {
+	window  time.Duration
+	execute func(decision proto.Decision) proto.PacketDecisionsResponse
+
+	mutex   sync.Mutex
+	pending map[uuid.UUID]*coalescedDecision
+}
+
+func newDecisionsCoalescer(
+	window time.Duration,
+	execute func(decision proto.Decision) proto.PacketDecisionsResponse,
+) *decisionsCoalescer {
+	return &decisionsCoalescer{
+		window:  window,
+		execute: execute,
+		pending: map[uuid.UUID]*coalescedDecision{},
+	}
+}
+
+// add adds the decision to the pending decision of its service, the
+// returned decision is executed once the window of the service expires
+func (coalescer *decisionsCoalescer) add(
+	decision proto.Decision,
+) *coalescedDecision {
+	// NOTE: only resources decisions are merged, rollout and cron job
+	// decisions are executed right away
+	if decision.Type != proto.DecisionTypeResources {
+		coalesced := &coalescedDecision{
+			decision: decision,
+			ids:      []uuid.UUID{decision.ID},
+			done:     make(chan struct{}),
+		}
+
+		go func() {
+			coalesced.responses = coalescer.execute(decision)
+			close(coalesced.done)
+		}()
+
+		return coalesced
+	}
+
+	coalescer.mutex.Lock()
+	defer coalescer.mutex.Unlock()
+
+	coalesced, ok := coalescer.pending[decision.ServiceId]
+	if !ok {
+		coalesced = &coalescedDecision{
+			decision: decision,
+			ids:      []uuid.UUID{decision.ID},
+			done:     make(chan struct{}),
+		}
+		coalescer.pending[decision.ServiceId] = coalesced
+
+		time.AfterFunc(coalescer.window, func() {
+			coalescer.flush(decision.ServiceId)
+		})
+
+		return coalesced
+	}
+
+	coalesced.decision = mergeDecisions(coalesced.decision, decision)
+	coalesced.ids = append(coalesced.ids, decision.ID)
+
+	return coalesced
+}
+
+func (coalescer *decisionsCoalescer) flush(serviceID uuid.UUID) {
+	coalescer.mutex.Lock()
+	coalesced := coalescer.pending[serviceID]
+	delete(coalescer.pending, serviceID)
+	coalescer.mutex.Unlock()
+
+	if coalesced == nil {
+		return
+	}
+
+	// NOTE: concurrency of executions is limited by the executor queue
+	coalesced.responses = coalescer.execute(coalesced.decision)
+
+	close(coalesced.done)
+}
+
+// wait blocks until the coalesced decision is executed, it returns the
+// responses for the given decision id
+func (coalesced *coalescedDecision) wait(
+	id uuid.UUID,
+) proto.PacketDecisionsResponse {
+	<-coalesced.done
+
+	responses := make(proto.PacketDecisionsResponse, len(coalesced.responses))
+	for i, response := range coalesced.responses {
+		response.ID = id
+		if len(coalesced.ids) > 1 {
+			response.CoalescedIds = coalesced.ids
+		}
+
+		responses[i] = response
+	}
+
+	return responses
+}
+
+// mergeDecisions merges the next decision into the previous one, every set
+// field of the next decision overrides the previous one
+func mergeDecisions(previous, next proto.Decision) proto.Decision {
+	merged := proto.Decision{
+		ID:        next.ID,
+		ServiceId: next.ServiceId,
+		TotalResources: proto.TotalResources{
+			Replicas: previous.TotalResources.Replicas,
+		},
+	}
+
+	if next.TotalResources.Replicas != nil {
+		merged.TotalResources.Replicas = next.TotalResources.Replicas
+	}
+
+	containers := map[uuid.UUID]int{}
+	for _, container := range previous.TotalResources.Containers {
+		containers[container.ContainerId] = len(merged.TotalResources.Containers)
+		merged.TotalResources.Containers = append(
+			merged.TotalResources.Containers,
+			container,
+		)
+	}
+
+	for _, container := range next.TotalResources.Containers {
+		index, ok := containers[container.ContainerId]
+		if !ok {
+			merged.TotalResources.Containers = append(
+				merged.TotalResources.Containers,
+				container,
+			)
+			continue
+		}
+
+		current := &merged.TotalResources.Containers[index]
+		mergeRequestLimit(&current.Requests, container.Requests)
+		mergeRequestLimit(&current.Limits, container.Limits)
+	}
+
+	return merged
+}
+
+func mergeRequestLimit(target *proto.RequestLimit, next proto.RequestLimit) {
+	if next.CPU != nil {
+		target.CPU = next.CPU
+	}
+
+	if next.Memory != nil {
+		target.Memory = next.Memory
+	}
+}
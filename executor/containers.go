@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+// containerBounds min and max resources of a container allowed by limit
+// ranges of the namespace, cpu is in millicores and memory in mebibytes
+type containerBounds struct {
+	min map[kv1.ResourceName]int64
+	max map[kv1.ResourceName]int64
+}
+
+// getContainerBounds merges container limits of all limit ranges, the
+// strictest bound wins
+func getContainerBounds(limitRanges []kv1.LimitRange) containerBounds {
+	bounds := containerBounds{
+		min: map[kv1.ResourceName]int64{},
+		max: map[kv1.ResourceName]int64{},
+	}
+
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != kv1.LimitTypeContainer {
+				continue
+			}
+
+			for name, quantity := range item.Min {
+				value := getQuantityValueCeil(name, quantity)
+				if current, ok := bounds.min[name]; !ok || value > current {
+					bounds.min[name] = value
+				}
+			}
+
+			for name, quantity := range item.Max {
+				value := getQuantityValue(name, quantity)
+				if current, ok := bounds.max[name]; !ok || value < current {
+					bounds.max[name] = value
+				}
+			}
+		}
+	}
+
+	return bounds
+}
+
+// clamp changes resources of the container to fit the bounds and describes
+// every changed value, values of the container aren't modified in place
+// since they are shared with the decision
+func (bounds containerBounds) clamp(
+	container *kuber.ContainerResourcesRequirements,
+) []string {
+	var clamped []string
+
+	for _, resource := range []struct {
+		Name     string
+		Resource kv1.ResourceName
+		Value    **int64
+	}{
+		{"requests.cpu", kv1.ResourceCPU, &container.Requests.CPU},
+		{"requests.memory", kv1.ResourceMemory, &container.Requests.Memory},
+		{"limits.cpu", kv1.ResourceCPU, &container.Limits.CPU},
+		{"limits.memory", kv1.ResourceMemory, &container.Limits.Memory},
+	} {
+		if *resource.Value == nil {
+			continue
+		}
+
+		original := **resource.Value
+		value := original
+		if min, ok := bounds.min[resource.Resource]; ok && value < min {
+			value = min
+		}
+		if max, ok := bounds.max[resource.Resource]; ok && value > max {
+			value = max
+		}
+
+		if value != original {
+			*resource.Value = &value
+			clamped = append(
+				clamped,
+				fmt.Sprintf("%s %d -> %d", resource.Name, original, value),
+			)
+		}
+	}
+
+	return clamped
+}
+
+// prepareContainers clamps resources of containers to limit ranges and
+// drops containers which the workload has already, it returns resources to
+// apply and outcomes of containers, outcomes of applied containers are
+// finished by finishContainers
+func prepareContainers(
+	spec *kuber.WorkloadSpec,
+	limitRanges []kv1.LimitRange,
+	totalResources kuber.TotalResources,
+	containers []proto.ContainerExecutionResult,
+) (kuber.TotalResources, []proto.ContainerExecutionResult) {
+	bounds := getContainerBounds(limitRanges)
+
+	prepared := kuber.TotalResources{
+		Replicas:   totalResources.Replicas,
+		Containers: make([]kuber.ContainerResourcesRequirements, 0, len(totalResources.Containers)),
+	}
+
+	results := make([]proto.ContainerExecutionResult, len(containers))
+	copy(results, containers)
+
+	for i, container := range totalResources.Containers {
+		clamped := bounds.clamp(&container)
+
+		results[i].Requests = proto.RequestLimit{
+			CPU:    container.Requests.CPU,
+			Memory: container.Requests.Memory,
+		}
+		results[i].Limits = proto.RequestLimit{
+			CPU:    container.Limits.CPU,
+			Memory: container.Limits.Memory,
+		}
+
+		if len(clamped) > 0 {
+			results[i].Status = proto.ContainerExecutionStatusClamped
+			results[i].Message = "clamped by limit range: " + strings.Join(clamped, ", ")
+		}
+
+		single := kuber.TotalResources{
+			Containers: []kuber.ContainerResourcesRequirements{container},
+		}
+		if len(getDifferences(spec, single)) == 0 {
+			results[i].Status = proto.ContainerExecutionStatusSkipped
+			results[i].Message = "container has decided resources already"
+			continue
+		}
+
+		prepared.Containers = append(prepared.Containers, container)
+	}
+
+	return prepared, results
+}
+
+// finishContainers marks containers which were sent to the cluster as
+// applied or failed with the error
+func finishContainers(containers []proto.ContainerExecutionResult, err error) {
+	for i, container := range containers {
+		if container.Status == proto.ContainerExecutionStatusSkipped ||
+			container.Status == proto.ContainerExecutionStatusFailed {
+			continue
+		}
+
+		if err != nil {
+			containers[i].Status = proto.ContainerExecutionStatusFailed
+			containers[i].Message = err.Error()
+			continue
+		}
+
+		if container.Status == "" {
+			containers[i].Status = proto.ContainerExecutionStatusApplied
+		}
+	}
+}
+
+// hasChanges checks whether applying the resources changes the workload
+func hasChanges(spec *kuber.WorkloadSpec, totalResources kuber.TotalResources) bool {
+	return len(getDifferences(spec, totalResources)) > 0
+}
+
+// findApplication returns the application of the service of the decision
+func findApplication(
+	apps []*scanner.Application,
+	decision proto.Decision,
+) *scanner.Application {
+	for _, app := range apps {
+		for _, service := range app.Services {
+			if service.ID == decision.ServiceId {
+				return app
+			}
+		}
+	}
+
+	return nil
+}
+
+// getQuantityValueCeil converts quantity to decision units like
+// getQuantityValue, fractions of mebibytes are rounded up so the value
+// satisfies minimums
+func getQuantityValueCeil(name kv1.ResourceName, quantity kresource.Quantity) int64 {
+	if name == kv1.ResourceMemory {
+		return (quantity.Value() + 1024*1024 - 1) / 1024 / 1024
+	}
+
+	return quantity.MilliValue()
+}
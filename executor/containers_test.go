@@ -0,0 +1,143 @@
+package executor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPrepareContainers(t *testing.T) {
+	int64Pointer := func(value int64) *int64 { return &value }
+
+	spec := &kuber.WorkloadSpec{
+		Containers: []kv1.Container{
+			{
+				Name: "app",
+				Resources: kv1.ResourceRequirements{
+					Requests: kv1.ResourceList{
+						kv1.ResourceCPU:    kresource.MustParse("500m"),
+						kv1.ResourceMemory: kresource.MustParse("256Mi"),
+					},
+				},
+			},
+			{
+				Name: "sidecar",
+				Resources: kv1.ResourceRequirements{
+					Requests: kv1.ResourceList{
+						kv1.ResourceCPU: kresource.MustParse("100m"),
+					},
+				},
+			},
+		},
+	}
+
+	limitRanges := []kv1.LimitRange{
+		{
+			Spec: kv1.LimitRangeSpec{
+				Limits: []kv1.LimitRangeItem{
+					{
+						Type: kv1.LimitTypeContainer,
+						Min: kv1.ResourceList{
+							kv1.ResourceMemory: kresource.MustParse("100M"),
+						},
+						Max: kv1.ResourceList{
+							kv1.ResourceCPU: kresource.MustParse("2"),
+						},
+					},
+					{
+						Type: kv1.LimitTypePod,
+						Max: kv1.ResourceList{
+							kv1.ResourceCPU: kresource.MustParse("1"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	totalResources := kuber.TotalResources{
+		Containers: []kuber.ContainerResourcesRequirements{
+			{
+				Name:     "app",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(500), Memory: int64Pointer(256)},
+			},
+			{
+				Name:     "sidecar",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(3000), Memory: int64Pointer(64)},
+			},
+		},
+	}
+
+	containers := []proto.ContainerExecutionResult{
+		{Name: "app"},
+		{Name: "sidecar"},
+	}
+
+	prepared, results := prepareContainers(spec, limitRanges, totalResources, containers)
+
+	if len(prepared.Containers) != 1 || prepared.Containers[0].Name != "sidecar" {
+		t.Fatalf("unexpected prepared containers: %+v", prepared.Containers)
+	}
+
+	expected := kuber.RequestLimit{CPU: int64Pointer(2000), Memory: int64Pointer(96)}
+	if !reflect.DeepEqual(prepared.Containers[0].Requests, expected) {
+		t.Fatalf(
+			"unexpected clamped requests: %d %d",
+			*prepared.Containers[0].Requests.CPU,
+			*prepared.Containers[0].Requests.Memory,
+		)
+	}
+
+	if *totalResources.Containers[1].Requests.CPU != 3000 {
+		t.Fatalf("resources of the decision are modified")
+	}
+
+	if results[0].Status != proto.ContainerExecutionStatusSkipped {
+		t.Fatalf("unexpected status of app: %s", results[0].Status)
+	}
+
+	if results[1].Status != proto.ContainerExecutionStatusClamped {
+		t.Fatalf("unexpected status of sidecar: %s", results[1].Status)
+	}
+
+	if containers[0].Status != "" {
+		t.Fatalf("outcomes of containers are modified")
+	}
+
+	finishContainers(results, errors.New("forbidden"))
+
+	if results[0].Status != proto.ContainerExecutionStatusSkipped ||
+		results[1].Status != proto.ContainerExecutionStatusFailed ||
+		results[1].Message != "forbidden" {
+		t.Fatalf("unexpected outcomes: %+v", results)
+	}
+}
+
+func TestFinishContainers(t *testing.T) {
+	containers := []proto.ContainerExecutionResult{
+		{Name: "app"},
+		{Name: "sidecar", Status: proto.ContainerExecutionStatusClamped},
+		{Name: "init", Status: proto.ContainerExecutionStatusSkipped},
+	}
+
+	finishContainers(containers, nil)
+
+	statuses := []proto.ContainerExecutionStatus{}
+	for _, container := range containers {
+		statuses = append(statuses, container.Status)
+	}
+
+	expected := []proto.ContainerExecutionStatus{
+		proto.ContainerExecutionStatusApplied,
+		proto.ContainerExecutionStatusClamped,
+		proto.ContainerExecutionStatusSkipped,
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("expected %v, got %v", expected, statuses)
+	}
+}
@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// isCronJobDecision checks whether the decision suspends or resumes a cron
+// job
+func isCronJobDecision(decision proto.Decision) bool {
+	return decision.Type == proto.DecisionTypeSuspendCronJob ||
+		decision.Type == proto.DecisionTypeResumeCronJob
+}
+
+// executeCronJob suspends or resumes schedules of a cron job
+func (executor *Executor) executeCronJob(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name, kind string,
+) *proto.DecisionExecutionResponse {
+	suspended := decision.Type == proto.DecisionTypeSuspendCronJob
+
+	ctx = ctx.
+		Describe("type", decision.Type).
+		Describe("dry run", executor.dryRun)
+
+	if kind != "CronJob" {
+		return executor.handleExecutionSkipping(
+			ctx,
+			decision,
+			"only cron jobs can be suspended, got "+kind,
+		)
+	}
+
+	if executor.dryRun {
+		return executor.handleExecutionSkipping(ctx, decision, "dry run enabled")
+	}
+
+	err := executor.kube.SetCronJobSuspended(namespace, name, suspended)
+	if err != nil {
+		response := executor.handleExecutionError(ctx, decision, err, nil)
+		executor.reportAdmissionRejection(
+			ctx, decision, response, namespace, name, kind, err,
+		)
+		return response
+	}
+
+	msg := "cron job resumed successfully"
+	if suspended {
+		msg = "cron job suspended successfully"
+	}
+
+	executor.logger.Infof(ctx, msg)
+
+	return &proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusSucceed,
+		Message:   msg,
+	}
+}
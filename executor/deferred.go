@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+const deferredCheckInterval = time.Minute
+
+// deferredEntry a decision deferred by quiet hours
+type deferredEntry struct {
+	Decision   proto.Decision `json:"decision"`
+	Namespace  string         `json:"namespace"`
+	DeferredAt time.Time      `json:"deferred_at"`
+}
+
+// deferredQueue keeps decisions deferred by quiet hours until changes are
+// not frozen anymore, entries are persisted to the file if it's specified,
+// so deferred decisions survive restarts
+type deferredQueue struct {
+	mutex   sync.Mutex
+	path    string
+	entries map[uuid.UUID]deferredEntry
+}
+
+// loadDeferredQueue creates a new queue restoring its entries from the file,
+// a missing file is an empty queue
+func loadDeferredQueue(path string) (*deferredQueue, error) {
+	queue := &deferredQueue{
+		path:    path,
+		entries: map[uuid.UUID]deferredEntry{},
+	}
+
+	if path == "" {
+		return queue, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queue, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to read deferred decisions state file %s",
+			path,
+		)
+	}
+
+	var entries []deferredEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode deferred decisions state file %s",
+			path,
+		)
+	}
+
+	for _, entry := range entries {
+		queue.entries[entry.Decision.ID] = entry
+	}
+
+	return queue, nil
+}
+
+// add queues the decision, a decision deferred again keeps its entry
+func (queue *deferredQueue) add(entry deferredEntry) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if _, ok := queue.entries[entry.Decision.ID]; ok {
+		return nil
+	}
+
+	queue.entries[entry.Decision.ID] = entry
+
+	return queue.save()
+}
+
+// finish removes the decision once it's taken for execution
+func (queue *deferredQueue) finish(id uuid.UUID) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if _, ok := queue.entries[id]; !ok {
+		return nil
+	}
+
+	delete(queue.entries, id)
+
+	return queue.save()
+}
+
+// pending returns deferred decisions in order of deferring
+func (queue *deferredQueue) pending() []deferredEntry {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	entries := make([]deferredEntry, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeferredAt.Before(entries[j].DeferredAt)
+	})
+
+	return entries
+}
+
+// save persists deferred decisions to the state file
+func (queue *deferredQueue) save() error {
+	if queue.path == "" {
+		return nil
+	}
+
+	entries := make([]deferredEntry, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		entries = append(entries, entry)
+	}
+
+	return utils.WriteStateFile(queue.path, entries, "deferred decisions")
+}
+
+// deferDecision queues the decision deferred by quiet hours
+func (executor *Executor) deferDecision(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace string,
+) {
+	err := executor.deferred.add(deferredEntry{
+		Decision:   decision,
+		Namespace:  namespace,
+		DeferredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		executor.logger.Errorf(
+			ctx.Reason(err),
+			"unable to persist deferred decisions state",
+		)
+	}
+}
+
+// watchDeferred executes deferred decisions once changes of the cluster are
+// not frozen anymore and reports their outcomes to the gateway
+func (executor *Executor) watchDeferred() {
+	ticker := utils.NewTicker(
+		executor.logger,
+		"executions-deferred",
+		deferredCheckInterval,
+		func(tickTime time.Time) {
+			if _, frozen := executor.freezer.IsFrozen(); frozen {
+				return
+			}
+
+			for _, entry := range executor.deferred.pending() {
+				executor.executeDeferred(entry)
+			}
+		},
+	)
+	ticker.Start(false, false, false)
+}
+
+func (executor *Executor) executeDeferred(entry deferredEntry) {
+	ctx := karma.
+		Describe("decision-id", entry.Decision.ID).
+		Describe("namespace", entry.Namespace).
+		Describe("deferred-at", entry.DeferredAt)
+
+	executor.logger.Infof(ctx, "executing decision deferred by quiet hours")
+
+	// NOTE: the decision is queued again if it's deferred by quiet hours
+	// once more
+	err := executor.deferred.finish(entry.Decision.ID)
+	if err != nil {
+		executor.logger.Errorf(
+			ctx.Reason(err),
+			"unable to persist deferred decisions state",
+		)
+	}
+
+	responses := executor.execute(entry.Decision)
+	if len(responses) == 0 {
+		return
+	}
+
+	executor.reportResponses(entry.Namespace, responses)
+
+	executor.client.Pipe(client.Package{
+		Kind:        proto.PacketKindDecisionsResumed,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 100,
+		Priority:    3,
+		Retries:     10,
+		Data:        responses,
+	})
+}
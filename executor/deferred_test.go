@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestDeferredQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deferred-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	queue, err := loadDeferredQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	first := proto.Decision{ID: uuid.NewV4()}
+	second := proto.Decision{ID: uuid.NewV4()}
+	third := proto.Decision{ID: uuid.NewV4()}
+
+	for i, decision := range []proto.Decision{second, first, third} {
+		err := queue.add(deferredEntry{
+			Decision:   decision,
+			Namespace:  "default",
+			DeferredAt: now.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// NOTE: deferring the decision again keeps its place in the queue
+	err = queue.add(deferredEntry{Decision: second, DeferredAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.finish(third.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadDeferredQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := loaded.pending()
+	if len(pending) != 2 ||
+		pending[0].Decision.ID != second.ID ||
+		pending[1].Decision.ID != first.ID {
+		t.Errorf("expected second and first decisions pending, got %+v", pending)
+	}
+}
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+// sendDryRunResult sends changes which the decision would apply to the
+// gateway instead of executing it
+func (executor *Executor) sendDryRunResult(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name, kind string,
+	totalResources kuber.TotalResources,
+) {
+	result, err := GetDryRunResult(
+		executor.scanner,
+		decision,
+		namespace, name, kind,
+		totalResources,
+	)
+	if err != nil {
+		executor.logger.Errorf(ctx.Reason(err), "unable to predict decision changes")
+		return
+	}
+
+	executor.logger.Debugf(
+		ctx.Describe("patch", result.Patch),
+		"predicted decision changes",
+	)
+
+	// NOTE: there is no gateway connection while replaying recordings
+	if executor.client == nil {
+		return
+	}
+
+	executor.client.Pipe(client.Package{
+		Kind:        proto.PacketKindDecisionDryRunResult,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 100,
+		Priority:    3,
+		Retries:     10,
+		Data:        result,
+	})
+}
+
+// GetDryRunResult returns changes which the decision would apply to the
+// workload, current resources of containers are taken from the scanner
+func GetDryRunResult(
+	scanner *scanner.Scanner,
+	decision proto.Decision,
+	namespace, name, kind string,
+	totalResources kuber.TotalResources,
+) (*proto.PacketDecisionDryRunResult, error) {
+	patch, err := kuber.GetResourcesPatch(kind, totalResources)
+	if err != nil {
+		return nil, karma.Format(err, "unable to build resources patch")
+	}
+
+	result := &proto.PacketDecisionDryRunResult{
+		ID:          decision.ID,
+		ServiceId:   decision.ServiceId,
+		Namespace:   namespace,
+		Name:        name,
+		Kind:        kind,
+		NewReplicas: decision.TotalResources.Replicas,
+		Containers:  []proto.DecisionDryRunContainer{},
+		Patch:       string(patch),
+	}
+
+	apps := scanner.GetApplications()
+	for _, app := range apps {
+		for _, service := range app.Services {
+			if service.ID != decision.ServiceId {
+				continue
+			}
+
+			if service.ReplicasStatus.Desired != nil {
+				replicas := int(*service.ReplicasStatus.Desired)
+				result.OldReplicas = &replicas
+			}
+
+			result.Rollout = getRolloutEstimate(service, totalResources)
+		}
+	}
+
+	for _, container := range decision.TotalResources.Containers {
+		identified, _, _, ok := scanner.FindContainerByID(
+			apps,
+			container.ContainerId,
+		)
+		if !ok {
+			// NOTE: unknown containers are already reported as failed
+			continue
+		}
+
+		item := proto.DecisionDryRunContainer{
+			ContainerId: container.ContainerId,
+			Name:        identified.Name,
+			NewRequests: container.Requests,
+			NewLimits:   container.Limits,
+		}
+
+		if identified.Resources != nil {
+			spec := identified.Resources.SpecResourceRequirements
+			item.OldRequests = getRequestLimit(spec.Requests)
+			item.OldLimits = getRequestLimit(spec.Limits)
+		}
+
+		result.Containers = append(result.Containers, item)
+	}
+
+	return result, nil
+}
+
+// getRequestLimit converts resources to decision units, cpu in millicores
+// and memory in mebibytes
+func getRequestLimit(resources kv1.ResourceList) proto.RequestLimit {
+	result := proto.RequestLimit{}
+
+	if cpu, ok := resources[kv1.ResourceCPU]; ok {
+		value := cpu.MilliValue()
+		result.CPU = &value
+	}
+
+	if memory, ok := resources[kv1.ResourceMemory]; ok {
+		value := memory.Value() / 1024 / 1024
+		result.Memory = &value
+	}
+
+	return result
+}
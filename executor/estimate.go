@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	kapps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultRollingUpdateValue default max surge and max unavailable of
+// rolling updates of deployments
+const defaultRollingUpdateValue = "25%"
+
+// getRolloutEstimate estimates pods replaced by applying changes of
+// containers resources according to rollout strategy of the service, nil is
+// returned if the service doesn't replace its pods or only replicas change
+func getRolloutEstimate(
+	service *scanner.Service,
+	totalResources kuber.TotalResources,
+) *proto.RolloutEstimate {
+	if service == nil || service.RolloutStrategy == nil {
+		return nil
+	}
+
+	if len(totalResources.Containers) == 0 {
+		return nil
+	}
+
+	var replicas int32
+	if service.ReplicasStatus.Desired != nil {
+		replicas = *service.ReplicasStatus.Desired
+	}
+
+	strategy := service.RolloutStrategy
+	estimate := &proto.RolloutEstimate{
+		Strategy: strategy.Type,
+	}
+
+	switch strategy.Type {
+	case string(kapps.RecreateDeploymentStrategyType):
+		estimate.PodsReplaced = replicas
+		estimate.MaxUnavailable = replicas
+
+	case string(kapps.RollingUpdateDeploymentStrategyType):
+		estimate.PodsReplaced = replicas
+
+		switch service.Kind {
+		case "StatefulSet":
+			// NOTE: pods of stateful sets are replaced one by one in
+			// reverse ordinal order down to the partition
+			if strategy.Partition != nil {
+				estimate.PodsReplaced -= *strategy.Partition
+			}
+			if estimate.PodsReplaced < 0 {
+				estimate.PodsReplaced = 0
+			}
+			estimate.MaxUnavailable = 1
+
+		case "DaemonSet":
+			estimate.MaxUnavailable = getRollingUpdateValue(
+				strategy.MaxUnavailable, "1", replicas, false,
+			)
+
+		default:
+			estimate.MaxSurge = getRollingUpdateValue(
+				strategy.MaxSurge, defaultRollingUpdateValue, replicas, true,
+			)
+			estimate.MaxUnavailable = getRollingUpdateValue(
+				strategy.MaxUnavailable, defaultRollingUpdateValue, replicas, false,
+			)
+		}
+
+		// NOTE: kubernetes doesn't allow both values to be zero
+		if estimate.MaxSurge == 0 && estimate.MaxUnavailable == 0 {
+			estimate.MaxUnavailable = 1
+		}
+
+	default:
+		// NOTE: OnDelete strategy replaces pods only when they are deleted
+		return estimate
+	}
+
+	if estimate.PodsReplaced == 0 {
+		return estimate
+	}
+
+	if strategy.Type == string(kapps.RecreateDeploymentStrategyType) {
+		estimate.Batches = 1
+		return estimate
+	}
+
+	step := estimate.MaxSurge + estimate.MaxUnavailable
+	if service.Kind == "StatefulSet" {
+		step = 1
+	}
+
+	estimate.Batches = (estimate.PodsReplaced + step - 1) / step
+
+	return estimate
+}
+
+// getRollingUpdateValue resolves max surge or max unavailable specified as
+// a number or a percentage of replicas
+func getRollingUpdateValue(
+	value string,
+	defaultValue string,
+	replicas int32,
+	roundUp bool,
+) int32 {
+	if value == "" {
+		value = defaultValue
+	}
+
+	parsed := intstr.Parse(value)
+	resolved, err := intstr.GetValueFromIntOrPercent(&parsed, int(replicas), roundUp)
+	if err != nil || resolved < 0 {
+		return 0
+	}
+
+	return int32(resolved)
+}
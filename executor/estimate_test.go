@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+)
+
+func TestGetRolloutEstimate(t *testing.T) {
+	int32Pointer := func(value int32) *int32 { return &value }
+
+	containers := kuber.TotalResources{
+		Containers: []kuber.ContainerResourcesRequirements{{Name: "app"}},
+	}
+
+	testcases := []struct {
+		kind     string
+		strategy *proto.RolloutStrategy
+		expected *proto.RolloutEstimate
+	}{
+		{
+			kind:     "Deployment",
+			strategy: &proto.RolloutStrategy{Type: "RollingUpdate"},
+			expected: &proto.RolloutEstimate{
+				Strategy:       "RollingUpdate",
+				PodsReplaced:   10,
+				MaxSurge:       3,
+				MaxUnavailable: 2,
+				Batches:        2,
+			},
+		},
+		{
+			kind: "Deployment",
+			strategy: &proto.RolloutStrategy{
+				Type:           "RollingUpdate",
+				MaxSurge:       "0",
+				MaxUnavailable: "0",
+			},
+			expected: &proto.RolloutEstimate{
+				Strategy:       "RollingUpdate",
+				PodsReplaced:   10,
+				MaxUnavailable: 1,
+				Batches:        10,
+			},
+		},
+		{
+			kind:     "Deployment",
+			strategy: &proto.RolloutStrategy{Type: "Recreate"},
+			expected: &proto.RolloutEstimate{
+				Strategy:       "Recreate",
+				PodsReplaced:   10,
+				MaxUnavailable: 10,
+				Batches:        1,
+			},
+		},
+		{
+			kind: "StatefulSet",
+			strategy: &proto.RolloutStrategy{
+				Type:      "RollingUpdate",
+				Partition: int32Pointer(4),
+			},
+			expected: &proto.RolloutEstimate{
+				Strategy:       "RollingUpdate",
+				PodsReplaced:   6,
+				MaxUnavailable: 1,
+				Batches:        6,
+			},
+		},
+		{
+			kind:     "DaemonSet",
+			strategy: &proto.RolloutStrategy{Type: "OnDelete"},
+			expected: &proto.RolloutEstimate{Strategy: "OnDelete"},
+		},
+		{
+			kind:     "CronJob",
+			strategy: nil,
+			expected: nil,
+		},
+	}
+
+	for _, testcase := range testcases {
+		service := &scanner.Service{
+			Entity:          scanner.Entity{Kind: testcase.kind},
+			ReplicasStatus:  proto.ReplicasStatus{Desired: int32Pointer(10)},
+			RolloutStrategy: testcase.strategy,
+		}
+
+		estimate := getRolloutEstimate(service, containers)
+		if (estimate == nil) != (testcase.expected == nil) ||
+			estimate != nil && *estimate != *testcase.expected {
+			t.Errorf(
+				"%s %+v: expected %+v, got %+v",
+				testcase.kind, testcase.strategy, testcase.expected, estimate,
+			)
+		}
+	}
+
+	service := &scanner.Service{
+		Entity:          scanner.Entity{Kind: "Deployment"},
+		RolloutStrategy: &proto.RolloutStrategy{Type: "RollingUpdate"},
+	}
+	if estimate := getRolloutEstimate(service, kuber.TotalResources{}); estimate != nil {
+		t.Errorf("expected no rollout for replicas changes, got %+v", estimate)
+	}
+}
@@ -2,13 +2,24 @@ package executor
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/freeze"
 	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/policy"
 	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/replay"
 	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/tracing"
+	"github.com/MagalixCorp/magalix-agent/webhook"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/MagalixTechnologies/uuid-go"
 	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
 )
 
 // Executor decision executor
@@ -20,8 +31,20 @@ type Executor struct {
 	dryRun    bool
 	oomKilled chan uuid.UUID
 
-	// TODO: remove
-	changed map[uuid.UUID]struct{}
+	// increasesOnly decisions reducing resources are reported as dry-run
+	increasesOnly bool
+
+	history   *decisionsHistory
+	journal   *executionsJournal
+	retries   *retryQueue
+	summaries *executionSummaries
+	coalescer *decisionsCoalescer
+	queue     *executionQueue
+	writeback *gitWriteback
+	freezer   *freeze.Freezer
+	deferred  *deferredQueue
+
+	reversions *reversionWatches
 }
 
 // InitExecutor creates a new excecutor then starts it
@@ -30,16 +53,117 @@ func InitExecutor(
 	kube *kuber.Kube,
 	scanner *scanner.Scanner,
 	dryRun bool,
-) *Executor {
-	return NewExecutor(client, kube, scanner, dryRun)
+	increasesOnly bool,
+	coalescingWindow time.Duration,
+	maxConcurrency int,
+	statePath string,
+	retryOptions RetryOptions,
+	writebackOptions WritebackOptions,
+	reversionWindow time.Duration,
+	freezer *freeze.Freezer,
+	deferredState string,
+) (*Executor, error) {
+	executor := NewExecutor(
+		client, kube, scanner, dryRun, increasesOnly, coalescingWindow, maxConcurrency,
+	)
+
+	writeback, err := newGitWriteback(executor.logger, writebackOptions)
+	if err != nil {
+		return nil, karma.Format(err, "unable to initialize git writeback")
+	}
+
+	executor.writeback = writeback
+	executor.freezer = freezer
+
+	if statePath != "" {
+		journal, err := loadExecutionsJournal(statePath)
+		if err != nil {
+			executor.logger.Errorf(
+				err,
+				"unable to load executions state, interrupted executions won't be verified",
+			)
+
+			journal = &executionsJournal{
+				path:    statePath,
+				entries: map[uuid.UUID]journalEntry{},
+			}
+		}
+
+		executor.journal = journal
+	}
+
+	retries, err := loadRetryQueue(retryOptions)
+	if err != nil {
+		executor.logger.Errorf(
+			err,
+			"unable to load retries state, previous retries are dropped",
+		)
+
+		retries = &retryQueue{
+			options: retryOptions,
+			entries: map[uuid.UUID]retryEntry{},
+		}
+	}
+
+	if retries != nil {
+		for _, entry := range retries.pending() {
+			executor.history.add(entry.Decision)
+		}
+
+		executor.retries = retries
+		executor.watchRetries()
+	}
+
+	deferred, err := loadDeferredQueue(deferredState)
+	if err != nil {
+		executor.logger.Errorf(
+			err,
+			"unable to load deferred decisions state, previously deferred decisions are dropped",
+		)
+
+		deferred = &deferredQueue{
+			path:    deferredState,
+			entries: map[uuid.UUID]deferredEntry{},
+		}
+	}
+
+	for _, entry := range deferred.pending() {
+		executor.history.add(entry.Decision)
+	}
+
+	executor.deferred = deferred
+	executor.watchDeferred()
+
+	executor.reversions = newReversionWatches(reversionWindow)
+	if executor.reversions != nil {
+		executor.watchReversions()
+	}
+
+	executor.watchQueue()
+	executor.watchSummaries()
+	client.RegisterHealthCheck("executor", executor.getHealth)
+
+	// NOTE: interrupted executions are resumed once the executor is built
+	// since they're executed with retries, deferrals and reversion watches
+	if executor.journal != nil {
+		go executor.resumeInterrupted()
+	}
+
+	return executor, nil
 }
 
-// NewExecutor creates a new excecutor
+// NewExecutor creates a new excecutor, decisions of the same service
+// received within the coalescing window are merged, zero window disables
+// coalescing, at most maxConcurrency decisions are executed at once, if
+// increasesOnly is set decisions reducing resources are not executed
 func NewExecutor(
 	client *client.Client,
 	kube *kuber.Kube,
 	scanner *scanner.Scanner,
 	dryRun bool,
+	increasesOnly bool,
+	coalescingWindow time.Duration,
+	maxConcurrency int,
 ) *Executor {
 	executor := &Executor{
 		client:  client,
@@ -48,12 +172,37 @@ func NewExecutor(
 		scanner: scanner,
 		dryRun:  dryRun,
 
-		changed: map[uuid.UUID]struct{}{},
+		increasesOnly: increasesOnly,
+
+		history:   newDecisionsHistory(decisionsHistorySize),
+		summaries: newExecutionSummaries(),
+		queue:     newExecutionQueue(maxConcurrency),
+	}
+
+	if coalescingWindow > 0 {
+		executor.coalescer = newDecisionsCoalescer(
+			coalescingWindow,
+			executor.execute,
+		)
 	}
 
 	return executor
 }
 
+// NewDryRunExecutor creates an executor which never executes decisions and
+// doesn't need access to the cluster, used to replay recordings
+func NewDryRunExecutor(logger *log.Logger, scanner *scanner.Scanner) *Executor {
+	return &Executor{
+		logger:  logger,
+		scanner: scanner,
+		dryRun:  true,
+
+		history:   newDecisionsHistory(decisionsHistorySize),
+		summaries: newExecutionSummaries(),
+		queue:     newExecutionQueue(1),
+	}
+}
+
 func (executor *Executor) handleExecutionError(
 	ctx *karma.Context, decision proto.Decision, err error, containerId *uuid.UUID,
 ) *proto.DecisionExecutionResponse {
@@ -81,96 +230,444 @@ func (executor *Executor) handleExecutionSkipping(
 	}
 }
 
+func (executor *Executor) handleExecutionDeferring(
+	ctx *karma.Context, decision proto.Decision, reason string,
+) *proto.DecisionExecutionResponse {
+
+	executor.logger.Infof(ctx, "deferring execution: %s", reason)
+
+	return &proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusDeferred,
+		Message:   reason,
+	}
+}
+
 func (executor *Executor) Listener(in []byte) (out []byte, err error) {
 	var decisions proto.PacketDecisions
 	if err = proto.Decode(in, &decisions); err != nil {
 		return
 	}
 
-	var responses proto.PacketDecisionsResponse
 	for _, decision := range decisions {
-		ctx := karma.
-			Describe("decision-id", decision.ID).
-			Describe("service-id", decision.ServiceId)
+		executor.history.add(decision)
+	}
+
+	var responses proto.PacketDecisionsResponse
+	defer func() {
+		for _, response := range responses {
+			record, ok := executor.history.update(response)
+			if ok && response.Status == proto.DecisionExecutionStatusSucceed {
+				webhook.Publish(webhook.EventDecisionApplied, record)
+			}
+
+			// NOTE: responses of failed containers are not counted, the
+			// decision has its own response
+			if ok && response.ContainerId == nil {
+				executor.summaries.add(record.Namespace, response.Status)
+			}
+		}
+
+		replay.Transition(replay.TransitionDecisionsResponses, responses)
+	}()
+
+	if executor.coalescer == nil {
+		executed := make([]proto.PacketDecisionsResponse, len(decisions))
+
+		wg := &sync.WaitGroup{}
+		for i, decision := range decisions {
+			wg.Add(1)
+			go func(i int, decision proto.Decision) {
+				defer wg.Done()
+				executed[i] = executor.execute(decision)
+			}(i, decision)
+		}
+
+		wg.Wait()
+
+		for _, decisionResponses := range executed {
+			responses = append(responses, decisionResponses...)
+		}
+
+		return proto.Encode(responses)
+	}
+
+	coalesced := make([]*coalescedDecision, len(decisions))
+	for i, decision := range decisions {
+		coalesced[i] = executor.coalescer.add(decision)
+	}
+
+	for i, decision := range decisions {
+		responses = append(responses, coalesced[i].wait(decision.ID)...)
+	}
+
+	return proto.Encode(responses)
+}
+
+// execute executes a single decision, it returns a response for the decision
+// and a response for each container which failed
+func (executor *Executor) execute(
+	decision proto.Decision,
+) proto.PacketDecisionsResponse {
+	var responses proto.PacketDecisionsResponse
+
+	span := tracing.Start("executor/decision")
+	span.SetAttribute("decision.id", decision.ID)
+	span.SetAttribute("service.id", decision.ServiceId)
+	defer func() {
+		// NOTE: the response of the decision follows responses of failed
+		// containers
+		if len(responses) > 0 {
+			span.SetAttribute("decision.status", responses[len(responses)-1].Status)
+		}
+		span.End()
+	}()
+
+	ctx := karma.
+		Describe("decision-id", decision.ID).
+		Describe("service-id", decision.ServiceId)
+
+	namespace, name, kind, err := executor.getServiceDetails(decision.ServiceId)
+	if err != nil {
+		response := executor.handleExecutionError(ctx, decision, err, nil)
+		responses = append(responses, *response)
+		return responses
+	}
+
+	ctx = ctx.Describe("namespace", namespace).
+		Describe("service-name", name).
+		Describe("kind", kind)
+
+	span.SetAttribute("namespace", namespace)
+	span.SetAttribute("kind", kind)
 
-		namespace, name, kind, err := executor.getServiceDetails(decision.ServiceId)
+	queued := span.Child("executor/queue")
+	release := executor.queue.acquire(namespace)
+	queued.End()
+	defer release()
+
+	service := findService(executor.scanner.GetApplications(), decision)
+	if service != nil && service.AutomationDisabled {
+		response := executor.handleExecutionSkipping(
+			ctx,
+			decision,
+			fmt.Sprintf(
+				"automation is disabled by %s annotation",
+				scanner.AnnotationAutomation,
+			),
+		)
+		responses = append(responses, *response)
+		return responses
+	}
+
+	if state, frozen := executor.freezer.IsFrozen(); frozen && !executor.dryRun {
+		reason := fmt.Sprintf("cluster changes are frozen: %s", state.Reason)
+		if state.Source == freeze.SourceQuietHours {
+			executor.deferDecision(ctx, decision, namespace)
+			reason += ", decision is queued until quiet hours end"
+		}
+
+		response := executor.handleExecutionDeferring(ctx, decision, reason)
+		responses = append(responses, *response)
+		return responses
+	}
+
+	executor.history.describe(decision.ID, namespace, name, kind)
+
+	rules := policy.Get()
+	if rules != nil {
+		violation := getPolicyViolation(rules, namespace, time.Now())
+		if violation != "" {
+			response := executor.handlePolicyViolation(ctx, decision, violation)
+			responses = append(responses, *response)
+			return responses
+		}
+	}
+
+	if executor.writeback != nil && (isRolloutDecision(decision) || isCronJobDecision(decision)) {
+		response := executor.handleExecutionSkipping(
+			ctx,
+			decision,
+			"decision can't be written to manifests, git writeback is enabled",
+		)
+		responses = append(responses, *response)
+		return responses
+	}
+
+	if isRolloutDecision(decision) {
+		response := executor.executeRollout(ctx, decision, namespace, name, kind)
+		responses = append(responses, *response)
+		return responses
+	}
+
+	if isCronJobDecision(decision) {
+		response := executor.executeCronJob(ctx, decision, namespace, name, kind)
+		responses = append(responses, *response)
+		return responses
+	}
+
+	totalResources := kuber.TotalResources{
+		Replicas:   decision.TotalResources.Replicas,
+		Containers: make([]kuber.ContainerResourcesRequirements, 0, len(decision.TotalResources.Containers)),
+	}
+	// containers outcomes of identified containers in order of
+	// totalResources, missing outcomes of containers which aren't found
+	var containers, missing []proto.ContainerExecutionResult
+	for _, container := range decision.TotalResources.Containers {
+		identified, err := executor.getContainerDetails(container.ContainerId)
 		if err != nil {
-			response := executor.handleExecutionError(ctx, decision, err, nil)
+			containerCtx := ctx.Describe("container-id", container.ContainerId)
+			response := executor.handleExecutionError(containerCtx, decision, err, &container.ContainerId)
 			responses = append(responses, *response)
+			missing = append(missing, proto.ContainerExecutionResult{
+				ContainerId: container.ContainerId,
+				Status:      proto.ContainerExecutionStatusFailed,
+				Message:     err.Error(),
+				Requests:    container.Requests,
+				Limits:      container.Limits,
+			})
 			continue
 		}
+		containers = append(containers, proto.ContainerExecutionResult{
+			ContainerId: container.ContainerId,
+			Name:        identified.Name,
+		})
+		totalResources.Containers = append(totalResources.Containers, kuber.ContainerResourcesRequirements{
+			Name: identified.Name,
+			Init: identified.Init,
+			Limits: kuber.RequestLimit{
+				Memory: container.Limits.Memory,
+				CPU:    container.Limits.CPU,
+			},
+			Requests: kuber.RequestLimit{
+				Memory: container.Requests.Memory,
+				CPU:    container.Requests.CPU,
+			},
+		})
+	}
+
+	if rules != nil {
+		violation := getResourcesPolicyViolation(rules, totalResources)
+		if violation != "" {
+			response := executor.handlePolicyViolation(ctx, decision, violation)
+			response.Containers = missing
+			responses = append(responses, *response)
+			return responses
+		}
+	}
+
+	rollout := getRolloutEstimate(service, totalResources)
+
+	trace, _ := json.Marshal(totalResources)
+	executor.logger.Debugf(
+		ctx.
+			Describe("dry run", executor.dryRun).
+			Describe("rollout", rollout).
+			Describe("cpu unit", "milliCore").
+			Describe("memory unit", "mibiByte").
+			Describe("trace", string(trace)),
+		"executing decision",
+	)
+
+	if executor.dryRun {
+		executor.sendDryRunResult(ctx, decision, namespace, name, kind, totalResources)
+
+		response := executor.handleExecutionSkipping(ctx, decision, "dry run enabled")
+		responses = append(responses, *response)
+		return responses
+	} else {
+		spec, err := executor.kube.GetWorkloadSpec(kind, namespace, name)
+		if err != nil {
+			response := executor.handleExecutionError(ctx, decision, err, nil)
+			response.Containers = missing
+			responses = append(responses, *response)
+			return responses
+		}
 
-		ctx = ctx.Describe("namespace", namespace).
-			Describe("service-name", name).
-			Describe("kind", kind)
+		var limitRanges []kv1.LimitRange
+		if app := findApplication(executor.scanner.GetApplications(), decision); app != nil {
+			limitRanges = app.LimitRanges
+		}
+
+		totalResources, containers = prepareContainers(
+			spec, limitRanges, totalResources, containers,
+		)
+		containers = append(containers, missing...)
 
-		totalResources := kuber.TotalResources{
-			Replicas:   decision.TotalResources.Replicas,
-			Containers: make([]kuber.ContainerResourcesRequirements, 0, len(decision.TotalResources.Containers)),
+		if !hasChanges(spec, totalResources) {
+			response := executor.handleExecutionSkipping(
+				ctx,
+				decision,
+				"workload has decided resources already",
+			)
+			response.Containers = containers
+			responses = append(responses, *response)
+			return responses
 		}
-		for _, container := range decision.TotalResources.Containers {
-			executor.changed[container.ContainerId] = struct{}{}
-			containerName, err := executor.getContainerDetails(container.ContainerId)
-			if err != nil {
-				containerCtx := ctx.Describe("container-name", containerName)
-				response := executor.handleExecutionError(containerCtx, decision, err, &container.ContainerId)
+
+		if rules != nil {
+			violation := getChangesPolicyViolation(rules, spec, totalResources)
+			if violation != "" {
+				response := executor.handlePolicyViolation(ctx, decision, violation)
+				response.Containers = containers
 				responses = append(responses, *response)
-				continue
+				return responses
 			}
-			totalResources.Containers = append(totalResources.Containers, kuber.ContainerResourcesRequirements{
-				Name: containerName,
-				Limits: kuber.RequestLimit{
-					Memory: container.Limits.Memory,
-					CPU:    container.Limits.CPU,
-				},
-				Requests: kuber.RequestLimit{
-					Memory: container.Requests.Memory,
-					CPU:    container.Requests.CPU,
-				},
-			})
 		}
 
-		trace, _ := json.Marshal(totalResources)
-		executor.logger.Debugf(
-			ctx.
-				Describe("dry run", executor.dryRun).
-				Describe("cpu unit", "milliCore").
-				Describe("memory unit", "mibiByte").
-				Describe("trace", string(trace)),
-			"executing decision",
-		)
+		if executor.increasesOnly {
+			decreases := getDecreases(spec, totalResources)
+			if len(decreases) > 0 {
+				executor.sendDryRunResult(ctx, decision, namespace, name, kind, totalResources)
 
-		if executor.dryRun {
-			response := executor.handleExecutionSkipping(ctx, decision, "dry run enabled")
+				response := executor.handleExecutionSkipping(
+					ctx,
+					decision,
+					"execution of increases only enabled, decision reduces "+
+						strings.Join(decreases, ", "),
+				)
+				responses = append(responses, *response)
+				return responses
+			}
+		}
+
+		reason, err := executor.getDeferralReason(decision, namespace)
+		if err != nil {
+			response := executor.handleExecutionError(ctx, decision, err, nil)
 			responses = append(responses, *response)
-			continue
-		} else {
-			skipped, err := executor.kube.SetResources(kind, name, namespace, totalResources)
+			return responses
+		}
+
+		if reason != "" {
+			response := executor.handleExecutionPostponing(ctx, decision, namespace, reason)
+			responses = append(responses, *response)
+			return responses
+		}
+
+		if executor.writeback != nil {
+			response := executor.executeWriteback(
+				ctx, decision, namespace, name, kind, totalResources, containers,
+			)
+			responses = append(responses, *response)
+			return responses
+		}
+
+		partition, staged, stagedErr := executor.getStagedPartition(kind, namespace, name)
+
+		entry := journalEntry{
+			Decision:       decision,
+			Namespace:      namespace,
+			Name:           name,
+			Kind:           kind,
+			TotalResources: totalResources,
+			StartedAt:      time.Now().UTC(),
+		}
+		entry.Previous, entry.Unset = getPreviousResources(spec, totalResources)
+		if staged {
+			entry.Partition = &partition
+		}
+
+		err = executor.journal.begin(entry)
+		if err != nil {
+			executor.logger.Errorf(ctx.Reason(err), "unable to persist execution state")
+		}
+
+		// NOTE: the entry of a staged rollout is finished once all stages
+		// are done
+		var inProgress bool
+		defer func() {
+			if inProgress {
+				return
+			}
+
+			err := executor.journal.finish(decision.ID)
 			if err != nil {
-				var response *proto.DecisionExecutionResponse
-				if skipped {
-					response = executor.handleExecutionSkipping(ctx, decision, err.Error())
-				} else {
-					response = executor.handleExecutionError(ctx, decision, err, nil)
-				}
-				responses = append(responses, *response)
-				continue
+				executor.logger.Errorf(ctx.Reason(err), "unable to persist execution state")
 			}
-			msg := "decision executed successfully"
+		}()
 
-			executor.logger.Infof(ctx, msg)
+		apply := span.Child("executor/apply")
+		var skipped bool
+		err = stagedErr
+		if err == nil {
+			if staged {
+				err = executor.executeStaged(
+					ctx, decision, namespace, name, kind, totalResources, partition,
+				)
+				inProgress = err == nil
+			} else {
+				skipped, err = executor.kube.SetResources(kind, name, namespace, totalResources)
+			}
+		}
+		apply.SetError(err)
+		apply.End()
+		if err != nil {
+			var response *proto.DecisionExecutionResponse
+			if skipped {
+				response = executor.handleExecutionSkipping(ctx, decision, err.Error())
+			} else {
+				finishContainers(containers, err)
+				response = executor.handleExecutionFailure(ctx, decision, namespace, err)
+				response.Containers = containers
+				executor.reportAdmissionRejection(
+					ctx, decision, response, namespace, name, kind, err,
+				)
+			}
+			responses = append(responses, *response)
+			return responses
+		}
 
-			responses = append(responses, proto.DecisionExecutionResponse{
-				ID:        decision.ID,
-				ServiceId: decision.ServiceId,
-				Status:    proto.DecisionExecutionStatusSucceed,
-				Message:   msg,
-			})
+		executor.finishRetries(ctx, decision.ID)
+		executor.watchReversion(
+			ctx, decision, namespace, name, kind, spec, totalResources,
+		)
+
+		finishContainers(containers, nil)
+		msg := "decision executed successfully"
+		status := proto.DecisionExecutionStatusSucceed
+		if inProgress {
+			msg = "decision is being rolled out in stages"
+			status = proto.DecisionExecutionStatusInProgress
 		}
 
+		executor.logger.Infof(ctx, msg)
+
+		responses = append(responses, proto.DecisionExecutionResponse{
+			ID:         decision.ID,
+			ServiceId:  decision.ServiceId,
+			Status:     status,
+			Message:    msg,
+			Rollout:    rollout,
+			Containers: containers,
+		})
 	}
 
-	return proto.Encode(responses)
+	return responses
+}
+
+// reportResponses records responses of decisions executed out of the
+// decisions listener, e.g. retried or deferred ones, responses of failed
+// containers are not counted
+func (executor *Executor) reportResponses(
+	namespace string,
+	responses proto.PacketDecisionsResponse,
+) {
+	for _, response := range responses {
+		record, ok := executor.history.update(response)
+		if ok && response.Status == proto.DecisionExecutionStatusSucceed {
+			webhook.Publish(webhook.EventDecisionApplied, record)
+		}
+
+		if response.ContainerId == nil {
+			executor.summaries.add(namespace, response.Status)
+		}
+	}
+}
+
+// GetDecisions returns recently received decisions and their statuses
+func (executor *Executor) GetDecisions() []DecisionRecord {
+	return executor.history.list()
 }
 
 func (executor *Executor) getServiceDetails(serviceID uuid.UUID) (namespace, name, kind string, err error) {
@@ -182,8 +679,8 @@ func (executor *Executor) getServiceDetails(serviceID uuid.UUID) (namespace, nam
 	return
 }
 
-func (executor *Executor) getContainerDetails(containerID uuid.UUID) (name string, err error) {
-	name, ok := executor.scanner.FindContainerNameByID(executor.scanner.GetApplications(), containerID)
+func (executor *Executor) getContainerDetails(containerID uuid.UUID) (container *scanner.Container, err error) {
+	container, _, _, ok := executor.scanner.FindContainerByID(executor.scanner.GetApplications(), containerID)
 	if !ok {
 		err = karma.Describe("id", containerID).
 			Reason("container not found")
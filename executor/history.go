@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+const decisionsHistorySize = 100
+
+// DecisionStatusPending status of a decision which is being executed
+const DecisionStatusPending = "pending"
+
+// DecisionRecord a decision received by the executor and its status
+type DecisionRecord struct {
+	ID        uuid.UUID `json:"id"`
+	ServiceID uuid.UUID `json:"service_id"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+
+	Type           proto.DecisionType   `json:"type,omitempty"`
+	TotalResources proto.TotalResources `json:"total_resources"`
+
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+
+	ReceivedAt time.Time `json:"received_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// decisionsHistory keeps a limited number of recent decisions
+type decisionsHistory struct {
+	mutex   sync.Mutex
+	records []*DecisionRecord
+	limit   int
+}
+
+func newDecisionsHistory(limit int) *decisionsHistory {
+	return &decisionsHistory{
+		records: []*DecisionRecord{},
+		limit:   limit,
+	}
+}
+
+func (history *decisionsHistory) add(decision proto.Decision) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	now := time.Now().UTC()
+	history.records = append(history.records, &DecisionRecord{
+		ID:             decision.ID,
+		ServiceID:      decision.ServiceId,
+		Type:           decision.Type,
+		TotalResources: decision.TotalResources,
+		Status:         DecisionStatusPending,
+		ReceivedAt:     now,
+		UpdatedAt:      now,
+	})
+
+	if len(history.records) > history.limit {
+		history.records = history.records[len(history.records)-history.limit:]
+	}
+}
+
+func (history *decisionsHistory) describe(
+	id uuid.UUID, namespace, name, kind string,
+) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	if record := history.find(id); record != nil {
+		record.Namespace = namespace
+		record.Name = name
+		record.Kind = kind
+	}
+}
+
+// update updates status of the decision, it returns a copy of the updated
+// record
+func (history *decisionsHistory) update(
+	response proto.DecisionExecutionResponse,
+) (DecisionRecord, bool) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	record := history.find(response.ID)
+	if record == nil {
+		return DecisionRecord{}, false
+	}
+
+	record.Status = string(response.Status)
+	record.Message = response.Message
+	record.UpdatedAt = time.Now().UTC()
+
+	return *record, true
+}
+
+func (history *decisionsHistory) find(id uuid.UUID) *DecisionRecord {
+	for i := len(history.records) - 1; i >= 0; i-- {
+		if history.records[i].ID == id {
+			return history.records[i]
+		}
+	}
+	return nil
+}
+
+// list returns copies of the records, the most recent first
+func (history *decisionsHistory) list() []DecisionRecord {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	records := make([]DecisionRecord, 0, len(history.records))
+	for i := len(history.records) - 1; i >= 0; i-- {
+		records = append(records, *history.records[i])
+	}
+	return records
+}
@@ -0,0 +1,207 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+// resourceComparison a value set by a decision and the live value of the
+// workload, the live value is nil if it's not set
+type resourceComparison struct {
+	Name    string
+	Current *int64
+	Value   int64
+	Limit   bool
+}
+
+func (comparison resourceComparison) String() string {
+	current := "unset"
+	if comparison.Current != nil {
+		current = fmt.Sprint(*comparison.Current)
+	}
+
+	return fmt.Sprintf("%s %s -> %d", comparison.Name, current, comparison.Value)
+}
+
+// compareWorkloadSpec compares replicas and resources set by a decision with
+// the live spec of the workload, cpu is in millicores and memory in mebibytes
+func compareWorkloadSpec(
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) []resourceComparison {
+	var comparisons []resourceComparison
+
+	if totalResources.Replicas != nil && spec.Replicas != nil {
+		current := int64(*spec.Replicas)
+		comparisons = append(comparisons, resourceComparison{
+			Name:    "replicas",
+			Current: &current,
+			Value:   int64(*totalResources.Replicas),
+		})
+	}
+
+	for _, container := range totalResources.Containers {
+		containers := spec.Containers
+		if container.Init {
+			containers = spec.InitContainers
+		}
+
+		var current kv1.ResourceRequirements
+		for _, item := range containers {
+			if item.Name == container.Name {
+				current = item.Resources
+				break
+			}
+		}
+
+		for _, resource := range []struct {
+			Name     string
+			Resource kv1.ResourceName
+			Current  kv1.ResourceList
+			Value    *int64
+			Limit    bool
+		}{
+			{"requests.cpu", kv1.ResourceCPU, current.Requests, container.Requests.CPU, false},
+			{"requests.memory", kv1.ResourceMemory, current.Requests, container.Requests.Memory, false},
+			{"limits.cpu", kv1.ResourceCPU, current.Limits, container.Limits.CPU, true},
+			{"limits.memory", kv1.ResourceMemory, current.Limits, container.Limits.Memory, true},
+		} {
+			if resource.Value == nil {
+				continue
+			}
+
+			comparison := resourceComparison{
+				Name:  container.Name + " " + resource.Name,
+				Value: *resource.Value,
+				Limit: resource.Limit,
+			}
+
+			if quantity, ok := resource.Current[resource.Resource]; ok {
+				value := getQuantityValue(resource.Resource, quantity)
+				comparison.Current = &value
+			}
+
+			comparisons = append(comparisons, comparison)
+		}
+	}
+
+	return comparisons
+}
+
+// getDecreases describes every change of the decision which reduces replicas
+// or resources of the workload, setting a limit on a container without a
+// limit is a decrease as well
+func getDecreases(
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) []string {
+	var decreases []string
+
+	for _, comparison := range compareWorkloadSpec(spec, totalResources) {
+		if comparison.Current == nil && comparison.Limit ||
+			comparison.Current != nil && *comparison.Current > comparison.Value {
+			decreases = append(decreases, comparison.String())
+		}
+	}
+
+	return decreases
+}
+
+// getDifferences describes every value of the decision which doesn't match
+// the live spec of the workload
+func getDifferences(
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) []string {
+	var differences []string
+
+	for _, comparison := range compareWorkloadSpec(spec, totalResources) {
+		if comparison.Current == nil || *comparison.Current != comparison.Value {
+			differences = append(differences, comparison.String())
+		}
+	}
+
+	return differences
+}
+
+// getQuantityValue converts quantity to decision units, cpu in millicores
+// and memory in mebibytes, fractions of mebibytes are dropped since
+// decisions can't express them
+func getQuantityValue(name kv1.ResourceName, quantity kresource.Quantity) int64 {
+	if name == kv1.ResourceMemory {
+		return quantity.Value() / 1024 / 1024
+	}
+
+	return quantity.MilliValue()
+}
+
+// getChangedResources returns values of the decision which differ from the
+// live spec of the workload, values matching the spec are dropped
+func getChangedResources(
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) kuber.TotalResources {
+	var changed kuber.TotalResources
+
+	if totalResources.Replicas != nil && spec.Replicas != nil &&
+		int(*spec.Replicas) != *totalResources.Replicas {
+		changed.Replicas = totalResources.Replicas
+	}
+
+	for _, container := range totalResources.Containers {
+		containers := spec.Containers
+		if container.Init {
+			containers = spec.InitContainers
+		}
+
+		var current kv1.ResourceRequirements
+		for _, item := range containers {
+			if item.Name == container.Name {
+				current = item.Resources
+				break
+			}
+		}
+
+		getValue := func(
+			resources kv1.ResourceList,
+			name kv1.ResourceName,
+			decided *int64,
+		) *int64 {
+			if decided == nil {
+				return nil
+			}
+
+			quantity, ok := resources[name]
+			if ok && getQuantityValue(name, quantity) == *decided {
+				return nil
+			}
+
+			return decided
+		}
+
+		requirements := kuber.ContainerResourcesRequirements{
+			Name: container.Name,
+			Init: container.Init,
+			Requests: kuber.RequestLimit{
+				CPU:    getValue(current.Requests, kv1.ResourceCPU, container.Requests.CPU),
+				Memory: getValue(current.Requests, kv1.ResourceMemory, container.Requests.Memory),
+			},
+			Limits: kuber.RequestLimit{
+				CPU:    getValue(current.Limits, kv1.ResourceCPU, container.Limits.CPU),
+				Memory: getValue(current.Limits, kv1.ResourceMemory, container.Limits.Memory),
+			},
+		}
+
+		if requirements.Requests.CPU == nil && requirements.Requests.Memory == nil &&
+			requirements.Limits.CPU == nil && requirements.Limits.Memory == nil {
+			continue
+		}
+
+		changed.Containers = append(changed.Containers, requirements)
+	}
+
+	return changed
+}
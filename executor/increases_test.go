@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetDecreases(t *testing.T) {
+	int64Pointer := func(value int64) *int64 { return &value }
+	intPointer := func(value int) *int { return &value }
+	replicas := int32(3)
+
+	spec := &kuber.WorkloadSpec{
+		Replicas: &replicas,
+		Containers: []kv1.Container{
+			{
+				Name: "app",
+				Resources: kv1.ResourceRequirements{
+					Requests: kv1.ResourceList{
+						kv1.ResourceCPU:    kresource.MustParse("500m"),
+						kv1.ResourceMemory: kresource.MustParse("256Mi"),
+					},
+					Limits: kv1.ResourceList{
+						kv1.ResourceMemory: kresource.MustParse("512Mi"),
+					},
+				},
+			},
+		},
+	}
+
+	testcases := []struct {
+		name      string
+		resources kuber.TotalResources
+		decreases int
+	}{
+		{
+			"increases",
+			kuber.TotalResources{
+				Replicas: intPointer(4),
+				Containers: []kuber.ContainerResourcesRequirements{
+					{
+						Name:     "app",
+						Requests: kuber.RequestLimit{CPU: int64Pointer(600), Memory: int64Pointer(256)},
+						Limits:   kuber.RequestLimit{Memory: int64Pointer(1024)},
+					},
+				},
+			},
+			0,
+		},
+		{
+			"fewer replicas",
+			kuber.TotalResources{Replicas: intPointer(2)},
+			1,
+		},
+		{
+			"lower requests",
+			kuber.TotalResources{
+				Containers: []kuber.ContainerResourcesRequirements{
+					{
+						Name:     "app",
+						Requests: kuber.RequestLimit{CPU: int64Pointer(400), Memory: int64Pointer(128)},
+					},
+				},
+			},
+			2,
+		},
+		{
+			"new cpu limit",
+			kuber.TotalResources{
+				Containers: []kuber.ContainerResourcesRequirements{
+					{
+						Name:   "app",
+						Limits: kuber.RequestLimit{CPU: int64Pointer(1000)},
+					},
+				},
+			},
+			1,
+		},
+	}
+
+	for _, testcase := range testcases {
+		decreases := getDecreases(spec, testcase.resources)
+		if len(decreases) != testcase.decreases {
+			t.Errorf(
+				"%s: expected %d decreases, got %v",
+				testcase.name,
+				testcase.decreases,
+				decreases,
+			)
+		}
+	}
+}
+
+func TestGetChangedResources(t *testing.T) {
+	int64Pointer := func(value int64) *int64 { return &value }
+	intPointer := func(value int) *int { return &value }
+	replicas := int32(3)
+
+	spec := &kuber.WorkloadSpec{
+		Replicas: &replicas,
+		Containers: []kv1.Container{
+			{
+				Name: "app",
+				Resources: kv1.ResourceRequirements{
+					Requests: kv1.ResourceList{
+						kv1.ResourceCPU:    kresource.MustParse("500m"),
+						kv1.ResourceMemory: kresource.MustParse("256Mi"),
+					},
+				},
+			},
+			{
+				Name: "sidecar",
+				Resources: kv1.ResourceRequirements{
+					Requests: kv1.ResourceList{
+						kv1.ResourceCPU: kresource.MustParse("100m"),
+					},
+				},
+			},
+		},
+	}
+
+	changed := getChangedResources(spec, kuber.TotalResources{
+		Replicas: intPointer(3),
+		Containers: []kuber.ContainerResourcesRequirements{
+			{
+				Name:     "app",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(500), Memory: int64Pointer(512)},
+				Limits:   kuber.RequestLimit{Memory: int64Pointer(1024)},
+			},
+			{
+				Name:     "sidecar",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(100)},
+			},
+		},
+	})
+
+	if changed.Replicas != nil {
+		t.Errorf("unchanged replicas are kept")
+	}
+
+	if len(changed.Containers) != 1 {
+		t.Fatalf("expected 1 changed container, got %+v", changed.Containers)
+	}
+
+	container := changed.Containers[0]
+	if container.Name != "app" || container.Requests.CPU != nil ||
+		container.Requests.Memory == nil || *container.Requests.Memory != 512 ||
+		container.Limits.Memory == nil || *container.Limits.Memory != 1024 {
+		t.Errorf("unexpected changed resources %+v", container)
+	}
+}
@@ -0,0 +1,440 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+// journalEntry a decision which is being applied to the cluster
+type journalEntry struct {
+	Decision       proto.Decision       `json:"decision"`
+	Namespace      string               `json:"namespace"`
+	Name           string               `json:"name"`
+	Kind           string               `json:"kind"`
+	TotalResources kuber.TotalResources `json:"total_resources"`
+	StartedAt      time.Time            `json:"started_at"`
+
+	// Previous live values of replicas and resources changed by the
+	// decision, values which were not set are listed in Unset
+	Previous kuber.TotalResources `json:"previous"`
+
+	// Unset resources changed by the decision which were not set before,
+	// they're removed when previous resources are restored
+	Unset []kuber.UnsetResource `json:"unset,omitempty"`
+
+	// Partition original partition of the statefulset which is rolled out
+	// in stages, nil for executions which aren't staged
+	Partition *int32 `json:"partition,omitempty"`
+}
+
+// scanWaitInterval interval of checks whether applications are scanned
+// before interrupted executions are executed again
+const scanWaitInterval = 5 * time.Second
+
+// executionsJournal persists in-flight executions to a file, so executions
+// interrupted by a restart can be verified once the agent is started again,
+// a nil journal persists nothing
+type executionsJournal struct {
+	mutex   sync.Mutex
+	path    string
+	entries map[uuid.UUID]journalEntry
+}
+
+// loadExecutionsJournal reads the journal from the file, a missing file is
+// an empty journal
+func loadExecutionsJournal(path string) (*executionsJournal, error) {
+	journal := &executionsJournal{
+		path:    path,
+		entries: map[uuid.UUID]journalEntry{},
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return journal, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to read executions state file %s",
+			path,
+		)
+	}
+
+	var entries []journalEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode executions state file %s",
+			path,
+		)
+	}
+
+	for _, entry := range entries {
+		journal.entries[entry.Decision.ID] = entry
+	}
+
+	return journal, nil
+}
+
+// begin records the execution before changes are applied
+func (journal *executionsJournal) begin(entry journalEntry) error {
+	if journal == nil {
+		return nil
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	journal.entries[entry.Decision.ID] = entry
+
+	return journal.save()
+}
+
+// finish removes the execution once its outcome is known
+func (journal *executionsJournal) finish(id uuid.UUID) error {
+	if journal == nil {
+		return nil
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	if _, ok := journal.entries[id]; !ok {
+		return nil
+	}
+
+	delete(journal.entries, id)
+
+	return journal.save()
+}
+
+// pending returns executions which were not finished
+func (journal *executionsJournal) pending() []journalEntry {
+	if journal == nil {
+		return nil
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+
+	entries := make([]journalEntry, 0, len(journal.entries))
+	for _, entry := range journal.entries {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// save persists in-flight executions to the state file
+func (journal *executionsJournal) save() error {
+	entries := make([]journalEntry, 0, len(journal.entries))
+	for _, entry := range journal.entries {
+		entries = append(entries, entry)
+	}
+
+	return utils.WriteStateFile(journal.path, entries, "executions")
+}
+
+// getPreviousResources returns live values of replicas and resources the
+// decision changes and resources which weren't set, so they can be restored
+// if the execution is interrupted
+func getPreviousResources(
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) (kuber.TotalResources, []kuber.UnsetResource) {
+	var (
+		previous kuber.TotalResources
+		unset    []kuber.UnsetResource
+	)
+
+	if totalResources.Replicas != nil && spec.Replicas != nil {
+		replicas := int(*spec.Replicas)
+		previous.Replicas = &replicas
+	}
+
+	for _, container := range totalResources.Containers {
+		containers := spec.Containers
+		if container.Init {
+			containers = spec.InitContainers
+		}
+
+		var current kv1.ResourceRequirements
+		for _, item := range containers {
+			if item.Name == container.Name {
+				current = item.Resources
+				break
+			}
+		}
+
+		getValue := func(
+			resources kv1.ResourceList,
+			limit bool,
+			name kv1.ResourceName,
+			decided *int64,
+		) *int64 {
+			if decided == nil {
+				return nil
+			}
+
+			quantity, ok := resources[name]
+			if !ok {
+				unset = append(unset, kuber.UnsetResource{
+					Container: container.Name,
+					Init:      container.Init,
+					Limit:     limit,
+					Resource:  name,
+				})
+				return nil
+			}
+
+			value := getQuantityValue(name, quantity)
+			return &value
+		}
+
+		requirements := kuber.ContainerResourcesRequirements{
+			Name: container.Name,
+			Init: container.Init,
+			Requests: kuber.RequestLimit{
+				CPU:    getValue(current.Requests, false, kv1.ResourceCPU, container.Requests.CPU),
+				Memory: getValue(current.Requests, false, kv1.ResourceMemory, container.Requests.Memory),
+			},
+			Limits: kuber.RequestLimit{
+				CPU:    getValue(current.Limits, true, kv1.ResourceCPU, container.Limits.CPU),
+				Memory: getValue(current.Limits, true, kv1.ResourceMemory, container.Limits.Memory),
+			},
+		}
+
+		if requirements.Requests.CPU == nil && requirements.Requests.Memory == nil &&
+			requirements.Limits.CPU == nil && requirements.Limits.Memory == nil {
+			continue
+		}
+
+		previous.Containers = append(previous.Containers, requirements)
+	}
+
+	return previous, unset
+}
+
+// getSetResources returns resources which weren't set before the decision
+// but are set in the live spec of the workload
+func getSetResources(spec *kuber.WorkloadSpec, unset []kuber.UnsetResource) []string {
+	var set []string
+
+	for _, item := range unset {
+		containers := spec.Containers
+		if item.Init {
+			containers = spec.InitContainers
+		}
+
+		for _, container := range containers {
+			if container.Name != item.Container {
+				continue
+			}
+
+			resources := container.Resources.Requests
+			field := "requests"
+			if item.Limit {
+				resources = container.Resources.Limits
+				field = "limits"
+			}
+
+			if _, ok := resources[item.Resource]; ok {
+				set = append(set, fmt.Sprintf(
+					"%s %s/%s is set", item.Container, field, item.Resource,
+				))
+			}
+		}
+	}
+
+	return set
+}
+
+// resumeInterrupted recovers executions interrupted by a restart and reports
+// their outcomes to the gateway
+func (executor *Executor) resumeInterrupted() {
+	for _, entry := range executor.journal.pending() {
+		ctx := karma.
+			Describe("decision-id", entry.Decision.ID).
+			Describe("namespace", entry.Namespace).
+			Describe("service-name", entry.Name).
+			Describe("kind", entry.Kind).
+			Describe("started-at", entry.StartedAt)
+
+		executor.history.add(entry.Decision)
+		executor.history.describe(entry.Decision.ID, entry.Namespace, entry.Name, entry.Kind)
+
+		responses := executor.recoverInterrupted(ctx, entry)
+
+		executor.reportResponses(entry.Namespace, responses)
+
+		executor.client.Pipe(client.Package{
+			Kind:        proto.PacketKindDecisionsResumed,
+			ExpiryTime:  utils.After(time.Hour),
+			ExpiryCount: 100,
+			Priority:    3,
+			Retries:     10,
+			Data:        responses,
+		})
+
+		// NOTE: the entry of a resumed staged rollout is finished once all
+		// stages are done
+		if isInProgress(responses) {
+			continue
+		}
+
+		err := executor.journal.finish(entry.Decision.ID)
+		if err != nil {
+			executor.logger.Errorf(ctx.Reason(err), "unable to update executions state")
+		}
+	}
+}
+
+// restorePrevious restores previous resources of the interrupted execution
+// and removes resources which weren't set before it
+func (executor *Executor) restorePrevious(entry journalEntry) error {
+	if entry.Previous.Replicas != nil || len(entry.Previous.Containers) > 0 {
+		err := executor.kube.PatchResources(
+			entry.Kind, entry.Name, entry.Namespace, entry.Previous,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(entry.Unset) > 0 {
+		err := executor.kube.UnsetResources(
+			entry.Kind, entry.Name, entry.Namespace, entry.Unset,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isInProgress checks whether any of the decisions is still being executed
+func isInProgress(responses proto.PacketDecisionsResponse) bool {
+	for _, response := range responses {
+		if response.Status == proto.DecisionExecutionStatusInProgress {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recoverInterrupted verifies the interrupted execution against the live
+// spec of its workload, executions which changed nothing are executed again
+// and partially applied executions are rolled back to previous resources
+func (executor *Executor) recoverInterrupted(
+	ctx *karma.Context,
+	entry journalEntry,
+) proto.PacketDecisionsResponse {
+	response := proto.DecisionExecutionResponse{
+		ID:        entry.Decision.ID,
+		ServiceId: entry.Decision.ServiceId,
+	}
+
+	spec, err := executor.kube.GetWorkloadSpec(entry.Kind, entry.Namespace, entry.Name)
+	if err != nil {
+		executor.logger.Errorf(ctx.Reason(err), "unable to verify interrupted execution")
+
+		response.Status = proto.DecisionExecutionStatusFailed
+		response.Message = fmt.Sprintf(
+			"execution was interrupted by agent restart and can't be verified: %s",
+			err,
+		)
+		return proto.PacketDecisionsResponse{response}
+	}
+
+	differences := getDifferences(spec, entry.TotalResources)
+	if len(differences) == 0 && entry.Partition != nil {
+		executor.logger.Infof(
+			ctx.Describe("partition", *entry.Partition),
+			"interrupted staged rollout was applied, resuming stages",
+		)
+
+		// NOTE: the partition is restored first, so pods aren't updated
+		// beyond the original partition if the rollout fails again
+		err := executor.kube.SetStatefulSetPartition(
+			entry.Namespace, entry.Name, *entry.Partition,
+		)
+		if err != nil {
+			executor.logger.Errorf(
+				ctx.Reason(err),
+				"unable to restore partition of statefulset",
+			)
+		}
+
+		executor.rolloutInBackground(
+			ctx, entry.Decision, entry.Namespace, entry.Name, *entry.Partition,
+		)
+
+		response.Status = proto.DecisionExecutionStatusInProgress
+		response.Message = "decision is being rolled out in stages, resumed after agent restart"
+		return proto.PacketDecisionsResponse{response}
+	}
+
+	if len(differences) == 0 {
+		executor.logger.Infof(ctx, "interrupted execution was applied")
+
+		response.Status = proto.DecisionExecutionStatusSucceed
+		response.Message = "decision executed successfully, verified after agent restart"
+		return proto.PacketDecisionsResponse{response}
+	}
+
+	if len(getDifferences(spec, entry.Previous)) == 0 &&
+		len(getSetResources(spec, entry.Unset)) == 0 {
+		executor.logger.Infof(
+			ctx.Describe("differences", differences),
+			"interrupted execution wasn't applied, executing it again",
+		)
+
+		// NOTE: decisions are executed against scanned applications
+		for executor.scanner.AppsLastScanTime().IsZero() {
+			time.Sleep(scanWaitInterval)
+		}
+
+		return executor.execute(entry.Decision)
+	}
+
+	executor.logger.Warningf(
+		ctx.Describe("differences", differences),
+		"interrupted execution was partially applied, restoring previous resources",
+	)
+
+	response.Status = proto.DecisionExecutionStatusFailed
+
+	err = executor.restorePrevious(entry)
+	if err != nil {
+		executor.logger.Errorf(ctx.Reason(err), "unable to restore previous resources")
+
+		response.Message = fmt.Sprintf(
+			"execution was interrupted by agent restart and partially applied, "+
+				"previous resources can't be restored: %s",
+			err,
+		)
+		return proto.PacketDecisionsResponse{response}
+	}
+
+	response.Message = "execution was interrupted by agent restart and partially applied, " +
+		"previous resources are restored: " + strings.Join(differences, ", ")
+
+	return proto.PacketDecisionsResponse{response}
+}
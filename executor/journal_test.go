@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestExecutionsJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "executions-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	journal, err := loadExecutionsJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.pending()) != 0 {
+		t.Fatalf("expected empty journal")
+	}
+
+	first := proto.Decision{ID: uuid.NewV4()}
+	second := proto.Decision{ID: uuid.NewV4()}
+
+	partition := int32(2)
+	for _, entry := range []journalEntry{
+		{Decision: first, Name: "api"},
+		{Decision: second, Name: "db", Kind: "StatefulSet", Partition: &partition},
+	} {
+		err := journal.begin(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = journal.finish(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadExecutionsJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := loaded.pending()
+	if len(pending) != 1 || pending[0].Decision.ID != second.ID {
+		t.Fatalf("expected only the second decision pending, got %v", pending)
+	}
+
+	if pending[0].Partition == nil || *pending[0].Partition != partition {
+		t.Errorf("partition of staged rollout is not persisted")
+	}
+
+	var disabled *executionsJournal
+	if disabled.begin(journalEntry{Decision: first}) != nil || disabled.pending() != nil {
+		t.Errorf("nil journal should persist nothing")
+	}
+}
+
+func TestGetPreviousResources(t *testing.T) {
+	int64Pointer := func(value int64) *int64 { return &value }
+	intPointer := func(value int) *int { return &value }
+	replicas := int32(2)
+
+	spec := &kuber.WorkloadSpec{
+		Replicas: &replicas,
+		Containers: []kv1.Container{
+			{
+				Name: "app",
+				Resources: kv1.ResourceRequirements{
+					Requests: kv1.ResourceList{
+						kv1.ResourceCPU:    kresource.MustParse("100m"),
+						kv1.ResourceMemory: kresource.MustParse("64Mi"),
+					},
+				},
+			},
+			{Name: "sidecar"},
+		},
+	}
+
+	previous, unset := getPreviousResources(spec, kuber.TotalResources{
+		Replicas: intPointer(3),
+		Containers: []kuber.ContainerResourcesRequirements{
+			{
+				Name:     "app",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(250)},
+				Limits:   kuber.RequestLimit{Memory: int64Pointer(256)},
+			},
+			{
+				Name:     "sidecar",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(50)},
+			},
+		},
+	})
+
+	if previous.Replicas == nil || *previous.Replicas != 2 {
+		t.Errorf("expected previous replicas 2, got %v", previous.Replicas)
+	}
+
+	if len(previous.Containers) != 1 {
+		t.Fatalf("expected only container with set values, got %+v", previous.Containers)
+	}
+
+	app := previous.Containers[0]
+	if app.Name != "app" || app.Requests.CPU == nil || *app.Requests.CPU != 100 {
+		t.Errorf("expected previous cpu request 100 of app, got %+v", app)
+	}
+
+	if app.Requests.Memory != nil || app.Limits.Memory != nil {
+		t.Errorf("values not changed by decision or not set are kept: %+v", app)
+	}
+
+	expected := []kuber.UnsetResource{
+		{Container: "app", Limit: true, Resource: kv1.ResourceMemory},
+		{Container: "sidecar", Resource: kv1.ResourceCPU},
+	}
+	if !reflect.DeepEqual(unset, expected) {
+		t.Errorf("expected unset values %+v, got %+v", expected, unset)
+	}
+
+	spec.Containers[1].Resources.Requests = kv1.ResourceList{
+		kv1.ResourceCPU: kresource.MustParse("50m"),
+	}
+	set := getSetResources(spec, unset)
+	if len(set) != 1 || set[0] != "sidecar requests/cpu is set" {
+		t.Errorf("expected set sidecar cpu request, got %v", set)
+	}
+}
@@ -0,0 +1,377 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"gopkg.in/yaml.v3"
+)
+
+// errManifestNotPatchable the manifest can't be patched in place, e.g. its
+// resources are written in flow style, so it has to be encoded again
+var errManifestNotPatchable = errors.New("manifest can't be patched in place")
+
+// manifestValue value of the manifest set by a decision, values with
+// children are mappings
+type manifestValue struct {
+	key      string
+	value    string
+	children []manifestValue
+}
+
+// manifestEdit replacement of the text of a line, or lines inserted after
+// the line if insert is set, lines and columns are 1-based as positions of
+// yaml nodes
+type manifestEdit struct {
+	line   int
+	column int
+	length int
+	text   string
+
+	insert bool
+	indent int
+	lines  []string
+}
+
+// manifestPatcher edits only lines of values changed by a decision, so
+// comments, order of keys and formatting of the manifest are kept
+type manifestPatcher struct {
+	lines []string
+	edits []manifestEdit
+}
+
+// patchManifestDocument writes the resources to the yaml document of the
+// workload in place, false is returned if the document has the resources
+// already, errManifestNotPatchable is returned if the document can't be
+// patched in place
+func patchManifestDocument(
+	document string,
+	kind string,
+	totalResources kuber.TotalResources,
+) (string, bool, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal([]byte(document), &root)
+	if err != nil || len(root.Content) == 0 {
+		return "", false, errManifestNotPatchable
+	}
+
+	spec := getMappingValue(root.Content[0], "spec")
+	if spec == nil || spec.Kind != yaml.MappingNode {
+		return "", false, fmt.Errorf("manifest has no spec")
+	}
+
+	patcher := &manifestPatcher{
+		lines: strings.Split(document, "\n"),
+	}
+
+	if totalResources.Replicas != nil {
+		err = patcher.set(spec, []manifestValue{
+			{key: "replicas", value: strconv.Itoa(*totalResources.Replicas)},
+		})
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	if len(totalResources.Containers) > 0 {
+		path := []string{"template", "spec"}
+		if kind == "CronJob" {
+			path = []string{"jobTemplate", "spec", "template", "spec"}
+		}
+
+		podSpec := spec
+		for _, key := range path {
+			podSpec = getMappingValue(podSpec, key)
+			if podSpec == nil || podSpec.Kind != yaml.MappingNode {
+				return "", false, fmt.Errorf("manifest has no pod template")
+			}
+		}
+
+		for _, container := range totalResources.Containers {
+			field := "containers"
+			if container.Init {
+				field = "initContainers"
+			}
+
+			item := findContainerNode(getMappingValue(podSpec, field), container.Name)
+			if item == nil {
+				return "", false, fmt.Errorf("manifest has no container %s", container.Name)
+			}
+
+			err = patcher.set(item, getContainerValues(container))
+			if err != nil {
+				return "", false, err
+			}
+		}
+	}
+
+	if len(patcher.edits) == 0 {
+		return document, false, nil
+	}
+
+	return patcher.apply(), true, nil
+}
+
+// getContainerValues returns resources of the container formatted the same
+// way as patches of the cluster
+func getContainerValues(
+	container kuber.ContainerResourcesRequirements,
+) []manifestValue {
+	var resources []manifestValue
+	for _, values := range []struct {
+		Field string
+		Value kuber.RequestLimit
+	}{
+		{"requests", container.Requests},
+		{"limits", container.Limits},
+	} {
+		var children []manifestValue
+		if values.Value.CPU != nil {
+			children = append(children, manifestValue{
+				key:   "cpu",
+				value: fmt.Sprintf("%dm", *values.Value.CPU),
+			})
+		}
+
+		if values.Value.Memory != nil {
+			children = append(children, manifestValue{
+				key:   "memory",
+				value: fmt.Sprintf("%dMi", *values.Value.Memory),
+			})
+		}
+
+		if len(children) > 0 {
+			resources = append(resources, manifestValue{
+				key:      values.Field,
+				children: children,
+			})
+		}
+	}
+
+	if len(resources) == 0 {
+		return nil
+	}
+
+	return []manifestValue{{key: "resources", children: resources}}
+}
+
+// set replaces scalars of the mapping which differ from the values and
+// inserts values which are missing after the last line of the mapping
+func (patcher *manifestPatcher) set(
+	mapping *yaml.Node,
+	values []manifestValue,
+) error {
+	var missing []manifestValue
+	for _, value := range values {
+		node := getMappingValue(mapping, value.key)
+		if node == nil {
+			missing = append(missing, value)
+			continue
+		}
+
+		if value.children == nil {
+			err := patcher.replace(node, value.value)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !isBlockMapping(node) {
+			return errManifestNotPatchable
+		}
+
+		err := patcher.set(node, value.children)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if !isBlockMapping(mapping) {
+		return errManifestNotPatchable
+	}
+
+	line, err := getLastLine(mapping)
+	if err != nil {
+		return err
+	}
+
+	indent := mapping.Content[0].Column - 1
+
+	patcher.edits = append(patcher.edits, manifestEdit{
+		line:   line,
+		insert: true,
+		indent: indent,
+		lines:  formatManifestValues(missing, indent),
+	})
+
+	return nil
+}
+
+// replace replaces the scalar if its value differs, the quoting style of
+// the scalar is kept
+func (patcher *manifestPatcher) replace(node *yaml.Node, value string) error {
+	if node.Kind != yaml.ScalarNode {
+		return errManifestNotPatchable
+	}
+
+	if node.Value == value {
+		return nil
+	}
+
+	var quote string
+	switch node.Style {
+	case 0:
+	case yaml.DoubleQuotedStyle:
+		quote = `"`
+	case yaml.SingleQuotedStyle:
+		quote = `'`
+	default:
+		return errManifestNotPatchable
+	}
+
+	source := quote + node.Value + quote
+
+	// NOTE: the source of the scalar may differ from its value, e.g. if it
+	// has escaped characters or a tag, such scalars aren't replaced in place
+	if node.Line > len(patcher.lines) {
+		return errManifestNotPatchable
+	}
+
+	line := []rune(patcher.lines[node.Line-1])
+	start := node.Column - 1
+	end := start + len([]rune(source))
+	if start < 0 || end > len(line) || string(line[start:end]) != source {
+		return errManifestNotPatchable
+	}
+
+	patcher.edits = append(patcher.edits, manifestEdit{
+		line:   node.Line,
+		column: node.Column,
+		length: len([]rune(source)),
+		text:   quote + value + quote,
+	})
+
+	return nil
+}
+
+// apply applies edits from the end of the document, so positions of
+// following edits are not shifted, lines inserted after the same line are
+// ordered by their indentation, so values of nested mappings precede values
+// of outer ones
+func (patcher *manifestPatcher) apply() string {
+	edits := append([]manifestEdit{}, patcher.edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line > edits[j].line
+		}
+
+		return edits[i].indent < edits[j].indent
+	})
+
+	lines := append([]string{}, patcher.lines...)
+	for _, edit := range edits {
+		if edit.insert {
+			inserted := append([]string{}, edit.lines...)
+			lines = append(lines[:edit.line], append(inserted, lines[edit.line:]...)...)
+			continue
+		}
+
+		line := []rune(lines[edit.line-1])
+		start := edit.column - 1
+		lines[edit.line-1] = string(line[:start]) + edit.text +
+			string(line[start+edit.length:])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatManifestValues formats values as lines of a block mapping with the
+// given indentation
+func formatManifestValues(values []manifestValue, indent int) []string {
+	prefix := strings.Repeat(" ", indent)
+
+	var lines []string
+	for _, value := range values {
+		if value.children == nil {
+			lines = append(lines, prefix+value.key+": "+value.value)
+			continue
+		}
+
+		lines = append(lines, prefix+value.key+":")
+		lines = append(lines, formatManifestValues(value.children, indent+2)...)
+	}
+
+	return lines
+}
+
+// getLastLine returns the last line of the node, nodes ending with
+// multi-line scalars or aliases can't be patched in place since their last
+// line isn't known
+func getLastLine(node *yaml.Node) (int, error) {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		if len(node.Content) == 0 || node.Style&yaml.FlowStyle != 0 {
+			return 0, errManifestNotPatchable
+		}
+
+		return getLastLine(node.Content[len(node.Content)-1])
+	case yaml.ScalarNode:
+		if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 ||
+			strings.Contains(node.Value, "\n") {
+			return 0, errManifestNotPatchable
+		}
+
+		return node.Line, nil
+	default:
+		return 0, errManifestNotPatchable
+	}
+}
+
+// getMappingValue returns the value of the key of the mapping, nil is
+// returned if the node isn't a mapping or has no such key
+func getMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// findContainerNode returns the mapping of the container within the
+// sequence of containers
+func findContainerNode(containers *yaml.Node, name string) *yaml.Node {
+	if containers == nil || containers.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	for _, item := range containers.Content {
+		value := getMappingValue(item, "name")
+		if value != nil && value.Value == name {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func isBlockMapping(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode &&
+		node.Style&yaml.FlowStyle == 0 &&
+		len(node.Content) > 0
+}
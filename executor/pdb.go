@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// getDeferralReason returns the reason to defer the decision execution,
+// changing containers resources restarts pods of the service which may
+// violate its disruption budget. Empty reason means the decision can be
+// executed now.
+func (executor *Executor) getDeferralReason(
+	decision proto.Decision,
+	namespace string,
+) (string, error) {
+	// NOTE: changing replicas only doesn't restart running pods
+	if len(decision.TotalResources.Containers) == 0 {
+		return "", nil
+	}
+
+	apps := executor.scanner.GetApplications()
+
+	app := findApplication(apps, decision)
+	service := findService(apps, decision)
+	if app == nil || service == nil {
+		return "", nil
+	}
+
+	status := service.ReplicasStatus
+	if status.Desired != nil && status.Ready != nil && *status.Ready < *status.Desired {
+		return fmt.Sprintf(
+			"service isn't fully ready, ready replicas %d of %d",
+			*status.Ready, *status.Desired,
+		), nil
+	}
+
+	budgets, err := executor.kube.GetPodDisruptionBudgets(namespace)
+	if err != nil {
+		return "", err
+	}
+
+	pods := []kv1.Pod{}
+	for _, pod := range executor.scanner.GetPods() {
+		if pod.Namespace == namespace && app.OwnsPod(service, pod.Name) {
+			pods = append(pods, pod)
+		}
+	}
+
+	return getBudgetsDeferralReason(pods, budgets)
+}
+
+// getBudgetsDeferralReason checks disruption budgets selecting the given
+// pods, the execution is deferred if any of them allows no disruptions
+func getBudgetsDeferralReason(
+	pods []kv1.Pod,
+	budgets []kuber.PodDisruptionBudget,
+) (string, error) {
+	for _, budget := range budgets {
+		if budget.Spec.Selector == nil {
+			continue
+		}
+
+		selector, err := kmeta.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err != nil {
+			return "", karma.Format(
+				err,
+				"unable to parse selector of pod disruption budget %s",
+				budget.Metadata.Name,
+			)
+		}
+
+		if selector.Empty() || !selectsAny(selector, pods) {
+			continue
+		}
+
+		if budget.Status.DisruptionsAllowed < 1 {
+			return fmt.Sprintf(
+				"pod disruption budget %s allows no disruptions, "+
+					"healthy pods %d, desired healthy pods %d",
+				budget.Metadata.Name,
+				budget.Status.CurrentHealthy,
+				budget.Status.DesiredHealthy,
+			), nil
+		}
+	}
+
+	return "", nil
+}
+
+func selectsAny(selector labels.Selector, pods []kv1.Pod) bool {
+	for _, pod := range pods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findService(apps []*scanner.Application, decision proto.Decision) *scanner.Service {
+	for _, app := range apps {
+		for _, service := range app.Services {
+			if service.ID == decision.ServiceId {
+				return service
+			}
+		}
+	}
+
+	return nil
+}
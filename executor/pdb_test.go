@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetBudgetsDeferralReason(t *testing.T) {
+	pods := []kv1.Pod{
+		{ObjectMeta: kmeta.ObjectMeta{Labels: map[string]string{"app": "web"}}},
+	}
+
+	budget := func(app string, allowed int32) kuber.PodDisruptionBudget {
+		var budget kuber.PodDisruptionBudget
+		budget.Metadata.Name = app
+		budget.Spec.Selector = &kmeta.LabelSelector{
+			MatchLabels: map[string]string{"app": app},
+		}
+		budget.Status.DisruptionsAllowed = allowed
+		return budget
+	}
+
+	testcases := []struct {
+		budgets  []kuber.PodDisruptionBudget
+		deferred bool
+	}{
+		{nil, false},
+		{[]kuber.PodDisruptionBudget{budget("web", 1)}, false},
+		{[]kuber.PodDisruptionBudget{budget("db", 0)}, false},
+		{[]kuber.PodDisruptionBudget{budget("db", 0), budget("web", 0)}, true},
+	}
+
+	for i, testcase := range testcases {
+		reason, err := getBudgetsDeferralReason(pods, testcase.budgets)
+		if err != nil {
+			t.Fatalf("testcase %d: unexpected error: %s", i, err)
+		}
+
+		if (reason != "") != testcase.deferred {
+			t.Errorf("testcase %d: reason = %q, deferred = %v", i, reason, testcase.deferred)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/policy"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// getPolicyViolation checks the namespace of the decision and the time of
+// execution against the policy
+func getPolicyViolation(rules *policy.Policy, namespace string, now time.Time) string {
+	if violation := rules.CheckNamespace(namespace); violation != "" {
+		return violation
+	}
+
+	return rules.CheckTime(now)
+}
+
+// getResourcesPolicyViolation checks decided resources of containers against
+// bounds of the policy
+func getResourcesPolicyViolation(
+	rules *policy.Policy,
+	totalResources kuber.TotalResources,
+) string {
+	for _, container := range totalResources.Containers {
+		for _, resource := range []struct {
+			Name     string
+			Resource string
+			Value    *int64
+		}{
+			{"requests.cpu", policy.ResourceCPU, container.Requests.CPU},
+			{"requests.memory", policy.ResourceMemory, container.Requests.Memory},
+			{"limits.cpu", policy.ResourceCPU, container.Limits.CPU},
+			{"limits.memory", policy.ResourceMemory, container.Limits.Memory},
+		} {
+			if resource.Value == nil {
+				continue
+			}
+
+			violation := rules.CheckResource(
+				container.Name+" "+resource.Name,
+				resource.Resource,
+				*resource.Value,
+			)
+			if violation != "" {
+				return violation
+			}
+		}
+	}
+
+	return ""
+}
+
+// getChangesPolicyViolation checks changes of the decision relative to the
+// live spec of the workload against the max change of the policy
+func getChangesPolicyViolation(
+	rules *policy.Policy,
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) string {
+	for _, comparison := range compareWorkloadSpec(spec, totalResources) {
+		if comparison.Current == nil {
+			continue
+		}
+
+		violation := rules.CheckChange(
+			comparison.Name,
+			*comparison.Current,
+			comparison.Value,
+		)
+		if violation != "" {
+			return violation
+		}
+	}
+
+	return ""
+}
+
+func (executor *Executor) handlePolicyViolation(
+	ctx *karma.Context, decision proto.Decision, violation string,
+) *proto.DecisionExecutionResponse {
+
+	executor.logger.Warningf(ctx, "rejecting execution: %s", violation)
+
+	return &proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusPolicyViolation,
+		Message:   "policy violation: " + violation,
+	}
+}
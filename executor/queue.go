@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+)
+
+const queueReportInterval = 10 * time.Second
+
+// executionQueue limits count of concurrent executions, executions of the
+// same namespace are serialized to avoid rollout storms
+type executionQueue struct {
+	maxConcurrency int
+	semaphore      chan struct{}
+
+	mutex      sync.Mutex
+	namespaces map[string]*namespaceLock
+	waiting    int
+	running    int
+}
+
+// namespaceLock serializes executions of a namespace, it is removed once no
+// executions of the namespace are waiting or running
+type namespaceLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// ExecutionQueueState state of the executions queue
+type ExecutionQueueState struct {
+	Depth          int `json:"depth"`
+	Running        int `json:"running"`
+	MaxConcurrency int `json:"max_concurrency"`
+}
+
+func newExecutionQueue(maxConcurrency int) *executionQueue {
+	return &executionQueue{
+		maxConcurrency: maxConcurrency,
+		semaphore:      make(chan struct{}, maxConcurrency),
+		namespaces:     map[string]*namespaceLock{},
+	}
+}
+
+// acquire blocks until an execution in the namespace can start, the returned
+// function must be called once the execution is finished
+func (queue *executionQueue) acquire(namespace string) func() {
+	queue.mutex.Lock()
+	lock, ok := queue.namespaces[namespace]
+	if !ok {
+		lock = &namespaceLock{}
+		queue.namespaces[namespace] = lock
+	}
+	lock.refs++
+	queue.waiting++
+	queue.mutex.Unlock()
+
+	// NOTE: the namespace lock is taken first so executions waiting for
+	// their namespace don't occupy slots of other namespaces
+	lock.mutex.Lock()
+	queue.semaphore <- struct{}{}
+
+	queue.mutex.Lock()
+	queue.waiting--
+	queue.running++
+	queue.mutex.Unlock()
+
+	return func() {
+		<-queue.semaphore
+		lock.mutex.Unlock()
+
+		queue.mutex.Lock()
+		defer queue.mutex.Unlock()
+
+		queue.running--
+		lock.refs--
+		if lock.refs == 0 {
+			delete(queue.namespaces, namespace)
+		}
+	}
+}
+
+// GetState returns the current state of the queue
+func (queue *executionQueue) GetState() ExecutionQueueState {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	return ExecutionQueueState{
+		Depth:          queue.waiting,
+		Running:        queue.running,
+		MaxConcurrency: queue.maxConcurrency,
+	}
+}
+
+// watchQueue reports the queue state to the gateway whenever it changes
+func (executor *Executor) watchQueue() {
+	var last ExecutionQueueState
+
+	ticker := utils.NewTicker(
+		executor.logger,
+		"executions-queue",
+		queueReportInterval,
+		func(tickTime time.Time) {
+			state := executor.queue.GetState()
+			if state == last {
+				return
+			}
+
+			last = state
+
+			executor.client.Pipe(client.Package{
+				Kind:        proto.PacketKindDecisionsQueue,
+				ExpiryTime:  utils.After(queueReportInterval),
+				ExpiryCount: 1,
+				Priority:    5,
+				Retries:     1,
+				Data: proto.PacketDecisionsQueue{
+					Timestamp:      tickTime,
+					Depth:          state.Depth,
+					Running:        state.Running,
+					MaxConcurrency: state.MaxConcurrency,
+				},
+			})
+		},
+	)
+	ticker.Start(false, false, false)
+}
+
+// getHealth reports executor health with the queue state, the executor has
+// no failure modes of its own so it is always healthy
+func (executor *Executor) getHealth() proto.PacketComponentHealth {
+	state := executor.queue.GetState()
+
+	return proto.PacketComponentHealth{
+		Healthy: true,
+		Values: map[string]int64{
+			"queue_depth": int64(state.Depth),
+			"running":     int64(state.Running),
+		},
+	}
+}
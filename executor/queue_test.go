@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecutionQueue(t *testing.T) {
+	queue := newExecutionQueue(2)
+
+	var (
+		mutex      sync.Mutex
+		running    = map[string]int{}
+		total      int
+		maxRunning = map[string]int{}
+		maxTotal   int
+	)
+
+	wg := &sync.WaitGroup{}
+	for _, namespace := range []string{"a", "a", "a", "b", "b", "c"} {
+		wg.Add(1)
+		go func(namespace string) {
+			defer wg.Done()
+
+			release := queue.acquire(namespace)
+			defer release()
+
+			mutex.Lock()
+			running[namespace]++
+			total++
+			if running[namespace] > maxRunning[namespace] {
+				maxRunning[namespace] = running[namespace]
+			}
+			if total > maxTotal {
+				maxTotal = total
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			running[namespace]--
+			total--
+			mutex.Unlock()
+		}(namespace)
+	}
+
+	wg.Wait()
+
+	if maxTotal > 2 {
+		t.Errorf("queue ran %d executions at once, want at most 2", maxTotal)
+	}
+
+	for namespace, count := range maxRunning {
+		if count > 1 {
+			t.Errorf(
+				"queue ran %d executions of namespace %q at once, want 1",
+				count, namespace,
+			)
+		}
+	}
+
+	state := queue.GetState()
+	if state.Depth != 0 || state.Running != 0 || len(queue.namespaces) != 0 {
+		t.Errorf("queue state = %+v after all executions, want empty", state)
+	}
+}
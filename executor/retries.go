@@ -0,0 +1,407 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	retriesCheckInterval = 10 * time.Second
+	retriesMaxBackoff    = 15 * time.Minute
+)
+
+// RetryOptions retries of executions failed with transient errors, zero
+// attempts disables retries, the backoff is doubled after every attempt
+type RetryOptions struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	StatePath   string
+}
+
+// retryEntry a decision which execution failed with a transient error
+type retryEntry struct {
+	Decision  proto.Decision `json:"decision"`
+	Namespace string         `json:"namespace"`
+	Attempts  int            `json:"attempts"`
+	NextAt    time.Time      `json:"next_at"`
+	LastError string         `json:"last_error"`
+}
+
+// retryQueue keeps executions waiting for retries, entries are persisted to
+// the file if it's specified, so retries survive restarts, a nil queue never
+// retries
+type retryQueue struct {
+	mutex   sync.Mutex
+	options RetryOptions
+	entries map[uuid.UUID]retryEntry
+}
+
+// loadRetryQueue creates a new queue restoring its entries from the file of
+// the options, nil is returned if retries are disabled
+func loadRetryQueue(options RetryOptions) (*retryQueue, error) {
+	if options.MaxAttempts <= 0 {
+		return nil, nil
+	}
+
+	queue := &retryQueue{
+		options: options,
+		entries: map[uuid.UUID]retryEntry{},
+	}
+
+	if options.StatePath == "" {
+		return queue, nil
+	}
+
+	data, err := ioutil.ReadFile(options.StatePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queue, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to read retries state file %s",
+			options.StatePath,
+		)
+	}
+
+	var entries []retryEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode retries state file %s",
+			options.StatePath,
+		)
+	}
+
+	for _, entry := range entries {
+		queue.entries[entry.Decision.ID] = entry
+	}
+
+	return queue, nil
+}
+
+// isTransientError checks whether the execution may succeed if it's retried
+// later, e.g. on conflicts and throttling of the api-server, reasons of
+// errors wrapped by karma are checked as well
+func isTransientError(err error) bool {
+	for err != nil {
+		if kerrors.IsConflict(err) ||
+			kerrors.IsTooManyRequests(err) ||
+			kerrors.IsServerTimeout(err) ||
+			kerrors.IsTimeout(err) ||
+			kerrors.IsInternalError(err) {
+			return true
+		}
+
+		if _, ok := err.(net.Error); ok {
+			return true
+		}
+
+		err = getErrorReason(err)
+	}
+
+	return false
+}
+
+// getErrorReason returns the error wrapped by karma, nil is returned if the
+// error doesn't wrap another error
+func getErrorReason(err error) error {
+	var reason interface{}
+	switch value := err.(type) {
+	case karma.Karma:
+		reason = value.Reason
+	case *karma.Karma:
+		reason = value.Reason
+	default:
+		return nil
+	}
+
+	wrapped, _ := reason.(error)
+	return wrapped
+}
+
+// schedule schedules the next attempt of the decision, false is returned if
+// attempts are exhausted and the failure is terminal
+func (queue *retryQueue) schedule(
+	decision proto.Decision,
+	namespace string,
+	err error,
+	now time.Time,
+) (retryEntry, bool, error) {
+	if queue == nil {
+		return retryEntry{Attempts: 1}, false, nil
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	entry, ok := queue.entries[decision.ID]
+	if !ok {
+		entry = retryEntry{
+			Decision:  decision,
+			Namespace: namespace,
+		}
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+
+	if entry.Attempts > queue.options.MaxAttempts {
+		delete(queue.entries, decision.ID)
+		return entry, false, queue.save()
+	}
+
+	entry.NextAt = now.Add(getRetryBackoff(queue.options.Backoff, entry.Attempts))
+	queue.entries[decision.ID] = entry
+
+	return entry, true, queue.save()
+}
+
+// finish removes the decision once its execution has a final outcome
+func (queue *retryQueue) finish(id uuid.UUID) error {
+	if queue == nil {
+		return nil
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if _, ok := queue.entries[id]; !ok {
+		return nil
+	}
+
+	delete(queue.entries, id)
+
+	return queue.save()
+}
+
+// pending returns all entries waiting for retries
+func (queue *retryQueue) pending() []retryEntry {
+	if queue == nil {
+		return nil
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	entries := make([]retryEntry, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// due returns entries which next attempt is due
+func (queue *retryQueue) due(now time.Time) []retryEntry {
+	if queue == nil {
+		return nil
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	var entries []retryEntry
+	for _, entry := range queue.entries {
+		if !entry.NextAt.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// save persists pending retries to the state file
+func (queue *retryQueue) save() error {
+	if queue.options.StatePath == "" {
+		return nil
+	}
+
+	entries := make([]retryEntry, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		entries = append(entries, entry)
+	}
+
+	return utils.WriteStateFile(queue.options.StatePath, entries, "retries")
+}
+
+// getRetryBackoff returns delay before the attempt following the given count
+// of failed attempts
+func getRetryBackoff(backoff time.Duration, attempts int) time.Duration {
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= retriesMaxBackoff {
+			return retriesMaxBackoff
+		}
+	}
+
+	return backoff
+}
+
+// handleExecutionFailure reports the failure of applying changes, transient
+// failures are retried until attempts are exhausted
+func (executor *Executor) handleExecutionFailure(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace string,
+	err error,
+) *proto.DecisionExecutionResponse {
+	if !isTransientError(err) {
+		executor.finishRetries(ctx, decision.ID)
+		return executor.handleExecutionError(ctx, decision, err, nil)
+	}
+
+	entry, scheduled, saveErr := executor.retries.schedule(
+		decision, namespace, err, time.Now(),
+	)
+	if saveErr != nil {
+		executor.logger.Errorf(ctx.Reason(saveErr), "unable to persist retries state")
+	}
+
+	if !scheduled {
+		response := executor.handleExecutionError(ctx, decision, err, nil)
+		response.Attempts = entry.Attempts
+		if entry.Attempts > 1 {
+			response.Status = proto.DecisionExecutionStatusRetriesExhausted
+			response.Message = fmt.Sprintf(
+				"giving up after %d attempts: %s",
+				entry.Attempts, err,
+			)
+		}
+		return response
+	}
+
+	msg := fmt.Sprintf(
+		"transient failure, retrying at %s: %s",
+		entry.NextAt.UTC().Format(time.RFC3339), err,
+	)
+
+	executor.logger.Warningf(
+		ctx.Describe("attempts", entry.Attempts).Reason(err),
+		"execution failed, retrying at %s",
+		entry.NextAt.UTC().Format(time.RFC3339),
+	)
+
+	return &proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusRetrying,
+		Message:   msg,
+		Attempts:  entry.Attempts,
+	}
+}
+
+// handleExecutionPostponing retries the decision which can't be executed
+// now without disrupting its service, e.g. its pod disruption budget allows
+// no disruptions, the decision is skipped if retries are disabled or
+// exhausted
+func (executor *Executor) handleExecutionPostponing(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace string,
+	reason string,
+) *proto.DecisionExecutionResponse {
+	entry, scheduled, err := executor.retries.schedule(
+		decision, namespace, errors.New(reason), time.Now(),
+	)
+	if err != nil {
+		executor.logger.Errorf(ctx.Reason(err), "unable to persist retries state")
+	}
+
+	if !scheduled {
+		msg := reason
+		if entry.Attempts > 1 {
+			msg = fmt.Sprintf("giving up after %d attempts: %s", entry.Attempts, reason)
+		}
+
+		response := executor.handleExecutionSkipping(ctx, decision, msg)
+		response.Attempts = entry.Attempts
+		return response
+	}
+
+	executor.logger.Infof(
+		ctx.Describe("attempts", entry.Attempts),
+		"postponing execution until %s: %s",
+		entry.NextAt.UTC().Format(time.RFC3339), reason,
+	)
+
+	return &proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusRetrying,
+		Message: fmt.Sprintf(
+			"execution is postponed, retrying at %s: %s",
+			entry.NextAt.UTC().Format(time.RFC3339), reason,
+		),
+		Attempts: entry.Attempts,
+	}
+}
+
+// finishRetries removes the decision from retries once it has an outcome
+func (executor *Executor) finishRetries(ctx *karma.Context, id uuid.UUID) {
+	err := executor.retries.finish(id)
+	if err != nil {
+		executor.logger.Errorf(ctx.Reason(err), "unable to persist retries state")
+	}
+}
+
+// watchRetries executes decisions which next attempts are due and reports
+// their outcomes to the gateway
+func (executor *Executor) watchRetries() {
+	ticker := utils.NewTicker(
+		executor.logger,
+		"executions-retries",
+		retriesCheckInterval,
+		func(tickTime time.Time) {
+			for _, entry := range executor.retries.due(tickTime) {
+				executor.retry(entry)
+			}
+		},
+	)
+	ticker.Start(false, false, false)
+}
+
+func (executor *Executor) retry(entry retryEntry) {
+	responses := executor.execute(entry.Decision)
+	if len(responses) == 0 {
+		return
+	}
+
+	// NOTE: the response of the decision follows responses of failed
+	// containers
+	last := responses[len(responses)-1]
+	if last.Status != proto.DecisionExecutionStatusRetrying {
+		// NOTE: the decision may be skipped or deferred before changes are
+		// applied, it's not retried anymore
+		executor.finishRetries(
+			karma.Describe("decision-id", entry.Decision.ID),
+			entry.Decision.ID,
+		)
+	}
+
+	executor.reportResponses(entry.Namespace, responses)
+
+	executor.client.Pipe(client.Package{
+		Kind:        proto.PacketKindDecisionsRetried,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 100,
+		Priority:    3,
+		Retries:     10,
+		Data:        responses,
+	})
+}
@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetryQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retries-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	options := RetryOptions{
+		MaxAttempts: 2,
+		Backoff:     time.Minute,
+		StatePath:   filepath.Join(dir, "state.json"),
+	}
+
+	queue, err := loadRetryQueue(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decision := proto.Decision{ID: uuid.NewV4()}
+	now := time.Now()
+	failure := errors.New("conflict")
+
+	entry, scheduled, err := queue.schedule(decision, "default", failure, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheduled || entry.Attempts != 1 || !entry.NextAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected first retry: %+v", entry)
+	}
+
+	if len(queue.due(now)) != 0 || len(queue.due(now.Add(time.Minute))) != 1 {
+		t.Fatalf("unexpected due retries")
+	}
+
+	restored, err := loadRetryQueue(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.pending()) != 1 {
+		t.Fatalf("retries are not restored")
+	}
+
+	entry, scheduled, _ = queue.schedule(decision, "default", failure, now)
+	if !scheduled || !entry.NextAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("unexpected second retry: %+v", entry)
+	}
+
+	entry, scheduled, _ = queue.schedule(decision, "default", failure, now)
+	if scheduled || entry.Attempts != 3 || len(queue.pending()) != 0 {
+		t.Fatalf("attempts are not exhausted: %+v", entry)
+	}
+}
+
+func TestRetryQueueDisabled(t *testing.T) {
+	queue, err := loadRetryQueue(RetryOptions{})
+	if err != nil || queue != nil {
+		t.Fatalf("expected disabled retries")
+	}
+
+	_, scheduled, _ := queue.schedule(
+		proto.Decision{ID: uuid.NewV4()},
+		"default",
+		errors.New("conflict"),
+		time.Now(),
+	)
+	if scheduled {
+		t.Fatalf("disabled retries scheduled the decision")
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	resource := kschema.GroupResource{Resource: "deployments"}
+
+	if !isTransientError(kerrors.NewConflict(resource, "api", errors.New("modified"))) {
+		t.Errorf("conflict is not transient")
+	}
+
+	if !isTransientError(kerrors.NewTooManyRequests("throttled", 1)) {
+		t.Errorf("throttling is not transient")
+	}
+
+	if isTransientError(kerrors.NewForbidden(resource, "api", errors.New("denied"))) {
+		t.Errorf("forbidden is transient")
+	}
+
+	wrapped := karma.Format(
+		kerrors.NewConflict(resource, "api", errors.New("modified")),
+		"unable to patch deployment",
+	)
+	if !isTransientError(wrapped) {
+		t.Errorf("conflict wrapped by karma is not transient")
+	}
+
+	if isTransientError(karma.Format(nil, "invalid decision")) {
+		t.Errorf("error without reason is transient")
+	}
+}
+
+func TestGetRetryBackoff(t *testing.T) {
+	if backoff := getRetryBackoff(time.Minute, 3); backoff != 4*time.Minute {
+		t.Errorf("unexpected backoff: %s", backoff)
+	}
+
+	if backoff := getRetryBackoff(time.Minute, 10); backoff != retriesMaxBackoff {
+		t.Errorf("backoff is not capped: %s", backoff)
+	}
+}
@@ -0,0 +1,285 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	reversionsCheckInterval = 15 * time.Second
+
+	// reasonChangesReverted reason of events of decisions which changes
+	// were reverted by another controller
+	reasonChangesReverted = "MagalixDecisionReverted"
+)
+
+// gitOpsControllers labels and annotations set by GitOps controllers on
+// objects they manage
+var gitOpsControllers = []struct {
+	Name       string
+	Label      string
+	Annotation string
+}{
+	{Name: "Argo CD", Annotation: "argocd.argoproj.io/tracking-id"},
+	{Name: "Argo CD", Label: "argocd.argoproj.io/instance"},
+	{Name: "Flux", Label: "kustomize.toolkit.fluxcd.io/name"},
+	{Name: "Flux", Label: "helm.toolkit.fluxcd.io/name"},
+	{Name: "Flux", Annotation: "fluxcd.io/sync-checksum"},
+}
+
+// reversionWatch a workload which changes were applied by the decision and
+// are watched until the deadline
+type reversionWatch struct {
+	Decision       proto.Decision
+	Namespace      string
+	Name           string
+	Kind           string
+	TotalResources kuber.TotalResources
+	Deadline       time.Time
+}
+
+// reversionWatches keeps workloads watched for reversion of applied changes,
+// a watch is replaced by a newer decision of the same workload, nil watches
+// never watch anything
+type reversionWatches struct {
+	mutex   sync.Mutex
+	window  time.Duration
+	watches map[string]reversionWatch
+}
+
+// newReversionWatches creates new watches, nil is returned if the window is
+// zero
+func newReversionWatches(window time.Duration) *reversionWatches {
+	if window <= 0 {
+		return nil
+	}
+
+	return &reversionWatches{
+		window:  window,
+		watches: map[string]reversionWatch{},
+	}
+}
+
+func getReversionKey(namespace, name, kind string) string {
+	return strings.ToLower(kind) + "/" + namespace + "/" + name
+}
+
+// add starts watching the workload changed by the decision
+func (watches *reversionWatches) add(
+	decision proto.Decision,
+	namespace, name, kind string,
+	totalResources kuber.TotalResources,
+	now time.Time,
+) {
+	if watches == nil {
+		return
+	}
+
+	watches.mutex.Lock()
+	defer watches.mutex.Unlock()
+
+	watches.watches[getReversionKey(namespace, name, kind)] = reversionWatch{
+		Decision:       decision,
+		Namespace:      namespace,
+		Name:           name,
+		Kind:           kind,
+		TotalResources: totalResources,
+		Deadline:       now.Add(watches.window),
+	}
+}
+
+// active returns watches which deadline isn't passed, expired watches are
+// removed
+func (watches *reversionWatches) active(now time.Time) []reversionWatch {
+	if watches == nil {
+		return nil
+	}
+
+	watches.mutex.Lock()
+	defer watches.mutex.Unlock()
+
+	var active []reversionWatch
+	for key, watch := range watches.watches {
+		if now.After(watch.Deadline) {
+			delete(watches.watches, key)
+			continue
+		}
+
+		active = append(active, watch)
+	}
+
+	return active
+}
+
+// finish removes the watch unless it's replaced by a newer decision
+func (watches *reversionWatches) finish(watch reversionWatch) {
+	if watches == nil {
+		return
+	}
+
+	watches.mutex.Lock()
+	defer watches.mutex.Unlock()
+
+	key := getReversionKey(watch.Namespace, watch.Name, watch.Kind)
+	if current, ok := watches.watches[key]; ok && current.Decision.ID == watch.Decision.ID {
+		delete(watches.watches, key)
+	}
+}
+
+// getGitOpsController returns name of the GitOps controller managing the
+// workload object, empty string is returned if it's unknown
+func getGitOpsController(labels, annotations map[string]string) string {
+	for _, controller := range gitOpsControllers {
+		if controller.Label != "" {
+			if _, ok := labels[controller.Label]; ok {
+				return controller.Name
+			}
+		}
+
+		if controller.Annotation != "" {
+			if _, ok := annotations[controller.Annotation]; ok {
+				return controller.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// watchReversion starts watching values of the workload changed by the
+// decision, replicas of workloads scaled by horizontal pod autoscalers
+// aren't watched since autoscalers change them
+func (executor *Executor) watchReversion(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name, kind string,
+	spec *kuber.WorkloadSpec,
+	totalResources kuber.TotalResources,
+) {
+	if executor.reversions == nil {
+		return
+	}
+
+	changed := getChangedResources(spec, totalResources)
+	if changed.Replicas != nil {
+		autoscaled, err := executor.kube.HasHorizontalPodAutoscaler(kind, namespace, name)
+		if err != nil {
+			executor.logger.Warningf(
+				ctx.Reason(err),
+				"unable to check horizontal pod autoscalers, "+
+					"replicas aren't watched for reversion",
+			)
+		}
+
+		if autoscaled || err != nil {
+			changed.Replicas = nil
+		}
+	}
+
+	if changed.Replicas == nil && len(changed.Containers) == 0 {
+		return
+	}
+
+	executor.reversions.add(decision, namespace, name, kind, changed, time.Now())
+}
+
+// watchReversions checks workloads changed by decisions against their live
+// specs and reports decisions which changes were reverted
+func (executor *Executor) watchReversions() {
+	ticker := utils.NewTicker(
+		executor.logger,
+		"executions-reversions",
+		reversionsCheckInterval,
+		func(tickTime time.Time) {
+			for _, watch := range executor.reversions.active(tickTime) {
+				executor.checkReversion(watch)
+			}
+		},
+	)
+	ticker.Start(false, false, false)
+}
+
+func (executor *Executor) checkReversion(watch reversionWatch) {
+	ctx := karma.
+		Describe("decision-id", watch.Decision.ID).
+		Describe("namespace", watch.Namespace).
+		Describe("service-name", watch.Name).
+		Describe("kind", watch.Kind)
+
+	spec, err := executor.kube.GetWorkloadSpec(watch.Kind, watch.Namespace, watch.Name)
+	if err != nil {
+		executor.logger.Warningf(ctx.Reason(err), "unable to check reversion of changes")
+		return
+	}
+
+	differences := getDifferences(spec, watch.TotalResources)
+	if len(differences) == 0 {
+		return
+	}
+
+	executor.reversions.finish(watch)
+
+	controller := getGitOpsController(spec.Labels, spec.Annotations)
+	if controller == "" {
+		controller = "an external controller"
+	}
+
+	msg := fmt.Sprintf(
+		"changes were reverted by %s: %s",
+		controller, strings.Join(differences, ", "),
+	)
+
+	executor.logger.Warningf(
+		ctx.Describe("differences", differences),
+		"applied changes were reverted by %s",
+		controller,
+	)
+
+	response := proto.DecisionExecutionResponse{
+		ID:        watch.Decision.ID,
+		ServiceId: watch.Decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusReverted,
+		Message:   msg,
+	}
+
+	executor.history.update(response)
+	executor.summaries.add(watch.Namespace, response.Status)
+
+	workload := kuber.WorkloadReference{
+		Kind:      watch.Kind,
+		Namespace: watch.Namespace,
+		Name:      watch.Name,
+	}
+	if service := findService(executor.scanner.GetApplications(), watch.Decision); service != nil {
+		workload.UID = service.GetUID()
+	}
+
+	err = executor.kube.CreateWarningEvent(
+		workload,
+		reasonChangesReverted,
+		fmt.Sprintf("decision %s: %s", watch.Decision.ID, msg),
+	)
+	if err != nil {
+		executor.logger.Errorf(
+			ctx.Reason(err),
+			"unable to create event of reverted changes",
+		)
+	}
+
+	executor.client.Pipe(client.Package{
+		Kind:        proto.PacketKindDecisionReverted,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 100,
+		Priority:    3,
+		Retries:     10,
+		Data:        proto.PacketDecisionsResponse{response},
+	})
+}
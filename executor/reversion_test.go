@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestReversionWatches(t *testing.T) {
+	now := time.Now()
+	watches := newReversionWatches(5 * time.Minute)
+
+	first := proto.Decision{ID: uuid.NewV4()}
+	second := proto.Decision{ID: uuid.NewV4()}
+
+	watches.add(first, "default", "api", "Deployment", kuber.TotalResources{}, now)
+	watches.add(first, "default", "db", "StatefulSet", kuber.TotalResources{}, now)
+	watches.add(second, "default", "api", "Deployment", kuber.TotalResources{}, now.Add(time.Minute))
+
+	active := watches.active(now.Add(2 * time.Minute))
+	if len(active) != 2 {
+		t.Fatalf("expected 2 active watches, got %d", len(active))
+	}
+
+	// NOTE: the watch replaced by a newer decision isn't removed by the
+	// older one
+	watches.finish(reversionWatch{
+		Decision:  first,
+		Namespace: "default",
+		Name:      "api",
+		Kind:      "Deployment",
+	})
+	if len(watches.active(now.Add(2*time.Minute))) != 2 {
+		t.Errorf("watch of newer decision is removed")
+	}
+
+	active = watches.active(now.Add(5*time.Minute + 30*time.Second))
+	if len(active) != 1 || active[0].Decision.ID != second.ID {
+		t.Errorf("expected only watch of newer decision, got %+v", active)
+	}
+
+	if newReversionWatches(0) != nil {
+		t.Errorf("watches with zero window are enabled")
+	}
+
+	var disabled *reversionWatches
+	disabled.add(first, "default", "api", "Deployment", kuber.TotalResources{}, now)
+	if len(disabled.active(now)) != 0 {
+		t.Errorf("disabled watches have active watches")
+	}
+}
+
+func TestGetGitOpsController(t *testing.T) {
+	testcases := []struct {
+		labels      map[string]string
+		annotations map[string]string
+		controller  string
+	}{
+		{
+			annotations: map[string]string{
+				"argocd.argoproj.io/tracking-id": "api:apps/Deployment:default/api",
+			},
+			controller: "Argo CD",
+		},
+		{
+			labels:     map[string]string{"kustomize.toolkit.fluxcd.io/name": "apps"},
+			controller: "Flux",
+		},
+		{
+			labels:     map[string]string{"app": "api"},
+			controller: "",
+		},
+	}
+
+	for _, testcase := range testcases {
+		controller := getGitOpsController(testcase.labels, testcase.annotations)
+		if controller != testcase.controller {
+			t.Errorf(
+				"expected controller of %v %v %q, got %q",
+				testcase.labels, testcase.annotations, testcase.controller, controller,
+			)
+		}
+	}
+}
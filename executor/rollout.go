@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// isRolloutDecision checks whether the decision pauses or resumes rollouts
+func isRolloutDecision(decision proto.Decision) bool {
+	return decision.Type == proto.DecisionTypePauseRollout ||
+		decision.Type == proto.DecisionTypeResumeRollout
+}
+
+// executeRollout pauses or resumes rollouts of a deployment
+func (executor *Executor) executeRollout(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name, kind string,
+) *proto.DecisionExecutionResponse {
+	paused := decision.Type == proto.DecisionTypePauseRollout
+
+	ctx = ctx.
+		Describe("type", decision.Type).
+		Describe("dry run", executor.dryRun)
+
+	if kind != "Deployment" {
+		return executor.handleExecutionSkipping(
+			ctx,
+			decision,
+			"only rollouts of deployments can be paused, got "+kind,
+		)
+	}
+
+	if executor.dryRun {
+		return executor.handleExecutionSkipping(ctx, decision, "dry run enabled")
+	}
+
+	err := executor.kube.SetDeploymentPaused(namespace, name, paused)
+	if err != nil {
+		response := executor.handleExecutionError(ctx, decision, err, nil)
+		executor.reportAdmissionRejection(
+			ctx, decision, response, namespace, name, kind, err,
+		)
+		return response
+	}
+
+	msg := "deployment rollout resumed successfully"
+	if paused {
+		msg = "deployment rollout paused successfully"
+	}
+
+	executor.logger.Infof(ctx, msg)
+
+	return &proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusSucceed,
+		Message:   msg,
+	}
+}
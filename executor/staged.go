@@ -0,0 +1,261 @@
+package executor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+	kapps "k8s.io/api/apps/v1"
+)
+
+const (
+	stagedPollInterval = 5 * time.Second
+	stagedStepTimeout  = 10 * time.Minute
+)
+
+// getStagedPartition returns partition of rolling updates of a statefulset
+// with multiple replicas, such statefulsets are updated in stages by
+// lowering the partition pod by pod instead of being skipped
+func (executor *Executor) getStagedPartition(
+	kind, namespace, name string,
+) (int32, bool, error) {
+	if strings.ToLower(kind) != "statefulset" {
+		return 0, false, nil
+	}
+
+	statefulSet, err := executor.kube.GetStatefulSet(namespace, name)
+	if err != nil {
+		return 0, false, err
+	}
+
+	spec := statefulSet.Spec
+	if spec.Replicas == nil || *spec.Replicas <= 1 {
+		return 0, false, nil
+	}
+
+	if spec.UpdateStrategy.Type != kapps.RollingUpdateStatefulSetStrategyType ||
+		spec.UpdateStrategy.RollingUpdate == nil ||
+		spec.UpdateStrategy.RollingUpdate.Partition == nil ||
+		*spec.UpdateStrategy.RollingUpdate.Partition == 0 {
+		return 0, false, nil
+	}
+
+	return *spec.UpdateStrategy.RollingUpdate.Partition, true, nil
+}
+
+// executeStaged patches resources of the statefulset and rolls the changes
+// out in stages in background, the outcome of the rollout is reported once
+// all stages are done
+func (executor *Executor) executeStaged(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name, kind string,
+	totalResources kuber.TotalResources,
+	original int32,
+) error {
+	executor.logger.Infof(
+		ctx.Describe("partition", original),
+		"executing decision in stages",
+	)
+
+	err := executor.kube.PatchResources(kind, name, namespace, totalResources)
+	if err != nil {
+		return err
+	}
+
+	executor.rolloutInBackground(ctx, decision, namespace, name, original)
+
+	return nil
+}
+
+// rolloutInBackground rolls the statefulset out in stages without blocking
+// the caller, the journal entry is finished and the outcome is reported to
+// the gateway once all stages are done
+func (executor *Executor) rolloutInBackground(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name string,
+	original int32,
+) {
+	go func() {
+		response := executor.rolloutStages(ctx, decision, namespace, name, original)
+
+		err := executor.journal.finish(decision.ID)
+		if err != nil {
+			executor.logger.Errorf(ctx.Reason(err), "unable to persist execution state")
+		}
+
+		responses := proto.PacketDecisionsResponse{response}
+
+		executor.reportResponses(namespace, responses)
+
+		executor.client.Pipe(client.Package{
+			Kind:        proto.PacketKindDecisionsStaged,
+			ExpiryTime:  utils.After(time.Hour),
+			ExpiryCount: 100,
+			Priority:    3,
+			Retries:     10,
+			Data:        responses,
+		})
+	}()
+}
+
+// rolloutStages rolls the patched statefulset out in stages and returns the
+// outcome of the decision
+func (executor *Executor) rolloutStages(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name string,
+	original int32,
+) proto.DecisionExecutionResponse {
+	ctx = ctx.Describe("partition", original)
+
+	err := executor.advanceStages(ctx, decision, namespace, name, original)
+	if err != nil {
+		return *executor.handleExecutionError(ctx, decision, err, nil)
+	}
+
+	msg := "decision executed successfully in stages"
+
+	executor.logger.Infof(ctx, msg)
+
+	return proto.DecisionExecutionResponse{
+		ID:        decision.ID,
+		ServiceId: decision.ServiceId,
+		Status:    proto.DecisionExecutionStatusSucceed,
+		Message:   msg,
+	}
+}
+
+// advanceStages lowers the partition of the statefulset by one pod once
+// updated pods are ready, the original partition is restored when all pods
+// are updated or a stage fails, so pods are not updated beyond the failed
+// stage and the partition is kept for following rollouts
+func (executor *Executor) advanceStages(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace, name string,
+	original int32,
+) error {
+	defer func() {
+		err := executor.kube.SetStatefulSetPartition(namespace, name, original)
+		if err != nil {
+			executor.logger.Errorf(
+				ctx.Reason(err),
+				"unable to restore partition of statefulset",
+			)
+		}
+	}()
+
+	partition := original
+	for {
+		err := executor.waitStage(decision, namespace, name, partition)
+		if err != nil {
+			return karma.
+				Describe("partition", partition).
+				Format(err, "stage of statefulset rollout failed")
+		}
+
+		if partition == 0 {
+			return nil
+		}
+
+		partition--
+
+		executor.logger.Infof(
+			ctx.Describe("stage-partition", partition),
+			"advancing partition of statefulset",
+		)
+
+		err = executor.kube.SetStatefulSetPartition(namespace, name, partition)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitStage waits until pods with ordinals above the partition are updated
+// and all pods are ready
+func (executor *Executor) waitStage(
+	decision proto.Decision,
+	namespace, name string,
+	partition int32,
+) error {
+	timeout := time.After(stagedStepTimeout)
+	for {
+		statefulSet, err := executor.kube.GetStatefulSet(namespace, name)
+		if err != nil {
+			return err
+		}
+
+		if isStageReady(statefulSet, partition) {
+			executor.sendProgress(decision, statefulSet, partition)
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			return karma.
+				Describe("updated", statefulSet.Status.UpdatedReplicas).
+				Describe("ready", statefulSet.Status.ReadyReplicas).
+				Format(nil, "pods are not updated and ready within %s", stagedStepTimeout)
+		case <-time.After(stagedPollInterval):
+		}
+	}
+}
+
+// isStageReady checks whether the controller observed the latest spec,
+// pods with ordinals not below the partition are updated and all pods are
+// ready
+func isStageReady(statefulSet *kapps.StatefulSet, partition int32) bool {
+	status := statefulSet.Status
+	if status.ObservedGeneration < statefulSet.Generation {
+		return false
+	}
+
+	var replicas int32 = 1
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	return status.UpdatedReplicas >= replicas-partition &&
+		status.ReadyReplicas >= replicas
+}
+
+func (executor *Executor) sendProgress(
+	decision proto.Decision,
+	statefulSet *kapps.StatefulSet,
+	partition int32,
+) {
+	if executor.client == nil ||
+		!executor.client.IsPacketKindSupported(proto.PacketKindDecisionProgress) {
+		return
+	}
+
+	var replicas int32
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	executor.client.Pipe(client.Package{
+		Kind:        proto.PacketKindDecisionProgress,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 100,
+		Priority:    3,
+		Retries:     10,
+		Data: proto.PacketDecisionProgress{
+			ID:              decision.ID,
+			ServiceId:       decision.ServiceId,
+			Timestamp:       time.Now().UTC(),
+			Partition:       partition,
+			Replicas:        replicas,
+			UpdatedReplicas: statefulSet.Status.UpdatedReplicas,
+			ReadyReplicas:   statefulSet.Status.ReadyReplicas,
+			Done:            partition == 0,
+		},
+	})
+}
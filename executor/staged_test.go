@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"testing"
+
+	kapps "k8s.io/api/apps/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsStageReady(t *testing.T) {
+	replicas := int32(3)
+
+	statefulSet := &kapps.StatefulSet{
+		ObjectMeta: kmeta.ObjectMeta{Generation: 2},
+		Spec:       kapps.StatefulSetSpec{Replicas: &replicas},
+		Status: kapps.StatefulSetStatus{
+			ObservedGeneration: 2,
+			UpdatedReplicas:    1,
+			ReadyReplicas:      3,
+		},
+	}
+
+	if !isStageReady(statefulSet, 2) {
+		t.Errorf("stage with updated pods above partition is not ready")
+	}
+
+	if isStageReady(statefulSet, 1) {
+		t.Errorf("stage with pods not updated yet is ready")
+	}
+
+	statefulSet.Status.ReadyReplicas = 2
+	if isStageReady(statefulSet, 2) {
+		t.Errorf("stage with pods not ready is ready")
+	}
+
+	statefulSet.Status.ReadyReplicas = 3
+	statefulSet.Status.ObservedGeneration = 1
+	if isStageReady(statefulSet, 2) {
+		t.Errorf("stage of not observed spec is ready")
+	}
+}
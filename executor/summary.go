@@ -0,0 +1,145 @@
+package executor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+)
+
+const summaryReportInterval = 5 * time.Minute
+
+// executionSummaries counts outcomes of executed decisions per namespace,
+// the window is reset on every report while totals are kept since start
+type executionSummaries struct {
+	mutex       sync.Mutex
+	window      map[string]*proto.NamespaceExecutionSummary
+	windowStart time.Time
+	total       map[string]*proto.NamespaceExecutionSummary
+}
+
+func newExecutionSummaries() *executionSummaries {
+	return &executionSummaries{
+		window:      map[string]*proto.NamespaceExecutionSummary{},
+		windowStart: time.Now().UTC(),
+		total:       map[string]*proto.NamespaceExecutionSummary{},
+	}
+}
+
+// add counts an outcome of a decision executed in the namespace
+func (summaries *executionSummaries) add(
+	namespace string,
+	status proto.DecisionExecutionStatus,
+) {
+	summaries.mutex.Lock()
+	defer summaries.mutex.Unlock()
+
+	for _, counts := range []map[string]*proto.NamespaceExecutionSummary{
+		summaries.window,
+		summaries.total,
+	} {
+		summary, ok := counts[namespace]
+		if !ok {
+			summary = &proto.NamespaceExecutionSummary{Namespace: namespace}
+			counts[namespace] = summary
+		}
+
+		switch status {
+		case proto.DecisionExecutionStatusSucceed:
+			summary.Applied++
+		case proto.DecisionExecutionStatusFailed,
+			proto.DecisionExecutionStatusRetriesExhausted:
+			summary.Failed++
+		case proto.DecisionExecutionStatusSkipped:
+			summary.Skipped++
+		case proto.DecisionExecutionStatusDeferred:
+			summary.Deferred++
+		case proto.DecisionExecutionStatusPolicyViolation:
+			summary.Rejected++
+		case proto.DecisionExecutionStatusRetrying:
+			summary.Retrying++
+		case proto.DecisionExecutionStatusProposed:
+			summary.Proposed++
+		case proto.DecisionExecutionStatusReverted:
+			summary.Reverted++
+		}
+	}
+}
+
+// flush returns summaries of the current window and starts a new one
+func (summaries *executionSummaries) flush(
+	now time.Time,
+) (time.Time, []proto.NamespaceExecutionSummary) {
+	summaries.mutex.Lock()
+	defer summaries.mutex.Unlock()
+
+	start := summaries.windowStart
+	window := sortSummaries(summaries.window)
+
+	summaries.window = map[string]*proto.NamespaceExecutionSummary{}
+	summaries.windowStart = now
+
+	return start, window
+}
+
+// list returns summaries since start
+func (summaries *executionSummaries) list() []proto.NamespaceExecutionSummary {
+	summaries.mutex.Lock()
+	defer summaries.mutex.Unlock()
+
+	return sortSummaries(summaries.total)
+}
+
+func sortSummaries(
+	counts map[string]*proto.NamespaceExecutionSummary,
+) []proto.NamespaceExecutionSummary {
+	list := make([]proto.NamespaceExecutionSummary, 0, len(counts))
+	for _, summary := range counts {
+		list = append(list, *summary)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Namespace < list[j].Namespace
+	})
+
+	return list
+}
+
+// watchSummaries reports summaries of executions to the gateway, nothing is
+// sent for windows without executions
+func (executor *Executor) watchSummaries() {
+	ticker := utils.NewTicker(
+		executor.logger,
+		"executions-summary",
+		summaryReportInterval,
+		func(tickTime time.Time) {
+			start, window := executor.summaries.flush(tickTime)
+			if len(window) == 0 {
+				return
+			}
+
+			executor.client.Pipe(client.Package{
+				Kind:        proto.PacketKindDecisionsSummary,
+				ExpiryTime:  utils.After(time.Hour),
+				ExpiryCount: 10,
+				Priority:    5,
+				Retries:     5,
+				Data: proto.PacketDecisionsSummary{
+					From:       start,
+					To:         tickTime,
+					Namespaces: window,
+				},
+			})
+		},
+	)
+	ticker.Start(false, false, false)
+}
+
+// GetSummaries returns counts of executions outcomes per namespace since
+// the executor started
+func (executor *Executor) GetSummaries() []proto.NamespaceExecutionSummary {
+	return executor.summaries.list()
+}
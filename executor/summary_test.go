@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+)
+
+func TestExecutionSummaries(t *testing.T) {
+	summaries := newExecutionSummaries()
+
+	summaries.add("default", proto.DecisionExecutionStatusSucceed)
+	summaries.add("default", proto.DecisionExecutionStatusFailed)
+	summaries.add("apps", proto.DecisionExecutionStatusSkipped)
+
+	_, window := summaries.flush(time.Now())
+	expected := []proto.NamespaceExecutionSummary{
+		{Namespace: "apps", Skipped: 1},
+		{Namespace: "default", Applied: 1, Failed: 1},
+	}
+	if len(window) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, window)
+	}
+	for i := range expected {
+		if window[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], window[i])
+		}
+	}
+
+	summaries.add("default", proto.DecisionExecutionStatusDeferred)
+
+	_, window = summaries.flush(time.Now())
+	if len(window) != 1 || window[0].Deferred != 1 || window[0].Applied != 0 {
+		t.Errorf("window wasn't reset: %v", window)
+	}
+
+	total := summaries.list()
+	if len(total) != 2 || total[1].Applied != 1 || total[1].Deferred != 1 {
+		t.Errorf("unexpected totals: %v", total)
+	}
+}
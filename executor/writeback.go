@@ -0,0 +1,890 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/ghodss/yaml"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// WritebackProviderGitHub opens pull requests via GitHub api
+	WritebackProviderGitHub = "github"
+	// WritebackProviderGitLab opens merge requests via GitLab api
+	WritebackProviderGitLab = "gitlab"
+
+	writebackBranchPrefix = "magalix/"
+	writebackTimeout      = 30 * time.Second
+)
+
+// writebackCredentialHelper credential helper of git reading the username
+// and the token from environment of git commands
+const writebackCredentialHelper = `!f() { test "$1" = get && ` +
+	`echo "username=$MAGALIX_WRITEBACK_USERNAME" && ` +
+	`echo "password=$MAGALIX_WRITEBACK_TOKEN"; }; f`
+
+var reDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*\n`)
+
+// WritebackOptions git repository which manifests of workloads are changed
+// instead of patching the cluster, so GitOps controllers such as Flux or
+// Argo CD remain the source of truth, empty repository disables writeback
+type WritebackOptions struct {
+	Repository string
+	Branch     string
+	Path       string
+
+	AuthorName  string
+	AuthorEmail string
+
+	// Provider opens a pull request of changes if it's specified, changes
+	// are pushed to the branch directly otherwise
+	Provider string
+	APIURL   string
+	Token    string
+}
+
+// gitWriteback commits decided resources to manifests of the repository, a
+// nil writeback never writes anything
+type gitWriteback struct {
+	logger  *log.Logger
+	options WritebackOptions
+	client  *http.Client
+
+	mutex     sync.Mutex
+	directory string
+}
+
+// newGitWriteback creates a new writeback cloning the repository into a
+// temporary directory on the first write, nil is returned if writeback is
+// disabled
+func newGitWriteback(
+	logger *log.Logger,
+	options WritebackOptions,
+) (*gitWriteback, error) {
+	if options.Repository == "" {
+		return nil, nil
+	}
+
+	switch options.Provider {
+	case "", WritebackProviderGitHub, WritebackProviderGitLab:
+	default:
+		return nil, fmt.Errorf(
+			"unsupported writeback provider %q, expected %s or %s",
+			options.Provider, WritebackProviderGitHub, WritebackProviderGitLab,
+		)
+	}
+
+	if options.Provider != "" && options.Token == "" {
+		return nil, fmt.Errorf(
+			"token is required to open pull requests via %s",
+			options.Provider,
+		)
+	}
+
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"git is not found, it should be installed and available in PATH",
+		)
+	}
+
+	directory, err := ioutil.TempDir("", "magalix-agent-writeback")
+	if err != nil {
+		return nil, karma.Format(err, "unable to create writeback directory")
+	}
+
+	return &gitWriteback{
+		logger:  logger,
+		options: options,
+		client: &http.Client{
+			Timeout: writebackTimeout,
+		},
+		directory: directory,
+	}, nil
+}
+
+// write commits the resources to the manifest of the workload and pushes
+// them, the message describes the commit or the pull request, false is
+// returned if the manifest or the open pull request of the workload has the
+// resources already
+func (writeback *gitWriteback) write(
+	decision proto.Decision,
+	namespace string,
+	name string,
+	kind string,
+	totalResources kuber.TotalResources,
+) (string, bool, error) {
+	writeback.mutex.Lock()
+	defer writeback.mutex.Unlock()
+
+	err := writeback.sync()
+	if err != nil {
+		return "", false, err
+	}
+
+	var (
+		head   = writeback.options.Branch
+		base   = "origin/" + writeback.options.Branch
+		opened string
+	)
+	if writeback.options.Provider != "" {
+		head = getWritebackBranch(namespace, name, kind)
+
+		// NOTE: the pull request of the workload opened by a previous
+		// decision is updated instead of opening another one
+		opened, err = writeback.findPullRequest(head)
+		if err != nil {
+			return "", false, err
+		}
+
+		if opened != "" {
+			_, err = writeback.git("fetch", "origin", head)
+			if err != nil {
+				return "", false, err
+			}
+
+			base = "origin/" + head
+		}
+	}
+
+	_, err = writeback.git("checkout", "--force", "-B", head, base)
+	if err != nil {
+		return "", false, err
+	}
+
+	path, changed, err := writeback.patch(namespace, name, kind, totalResources)
+	if err != nil {
+		return "", false, err
+	}
+
+	if !changed {
+		if opened != "" {
+			return fmt.Sprintf(
+				"pull request has decided resources already: %s",
+				opened,
+			), false, nil
+		}
+
+		return "manifest of the workload has decided resources already", false, nil
+	}
+
+	title := fmt.Sprintf(
+		"Set resources of %s %s/%s",
+		strings.ToLower(kind), namespace, name,
+	)
+	message := fmt.Sprintf("%s\n\nDecision: %s", title, decision.ID)
+
+	_, err = writeback.git("add", "--", path)
+	if err != nil {
+		return "", false, err
+	}
+
+	_, err = writeback.git(
+		"-c", "user.name="+writeback.options.AuthorName,
+		"-c", "user.email="+writeback.options.AuthorEmail,
+		"commit", "--message", message,
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	if writeback.options.Provider == "" {
+		_, err = writeback.git("push", "origin", head)
+		if err != nil {
+			return "", false, err
+		}
+
+		commit, err := writeback.git("rev-parse", "--short", "HEAD")
+		if err != nil {
+			return "", false, err
+		}
+
+		return fmt.Sprintf(
+			"changes are committed to %s as %s",
+			head, strings.TrimSpace(string(commit)),
+		), true, nil
+	}
+
+	if opened != "" {
+		_, err = writeback.git("push", "origin", head)
+		if err != nil {
+			return "", false, err
+		}
+
+		return fmt.Sprintf("pull request is updated: %s", opened), true, nil
+	}
+
+	// NOTE: the branch of a closed pull request of the workload is replaced
+	_, err = writeback.git("push", "--force", "origin", head)
+	if err != nil {
+		return "", false, err
+	}
+
+	url, err := writeback.openPullRequest(head, title, message)
+	if err != nil {
+		return "", false, err
+	}
+
+	return fmt.Sprintf("pull request is opened: %s", url), true, nil
+}
+
+// executeWriteback commits the decided resources to the repository instead
+// of patching the cluster
+func (executor *Executor) executeWriteback(
+	ctx *karma.Context,
+	decision proto.Decision,
+	namespace string,
+	name string,
+	kind string,
+	totalResources kuber.TotalResources,
+	containers []proto.ContainerExecutionResult,
+) *proto.DecisionExecutionResponse {
+	msg, changed, err := executor.writeback.write(
+		decision, namespace, name, kind, totalResources,
+	)
+	if err != nil {
+		finishContainers(containers, err)
+		response := executor.handleExecutionError(ctx, decision, err, nil)
+		response.Containers = containers
+		return response
+	}
+
+	if !changed {
+		response := executor.handleExecutionSkipping(ctx, decision, msg)
+		response.Containers = containers
+		return response
+	}
+
+	finishContainers(containers, nil)
+
+	executor.logger.Infof(ctx, "decision written back: %s", msg)
+
+	return &proto.DecisionExecutionResponse{
+		ID:         decision.ID,
+		ServiceId:  decision.ServiceId,
+		Status:     proto.DecisionExecutionStatusProposed,
+		Message:    msg,
+		Containers: containers,
+	}
+}
+
+// sync clones the repository or fetches the branch if it's cloned already
+func (writeback *gitWriteback) sync() error {
+	_, err := os.Stat(filepath.Join(writeback.directory, ".git"))
+	if os.IsNotExist(err) {
+		_, err = writeback.git(
+			"clone",
+			"--branch", writeback.options.Branch,
+			writeback.options.Repository,
+			".",
+		)
+		return err
+	}
+
+	_, err = writeback.git("fetch", "origin", writeback.options.Branch)
+	return err
+}
+
+// patch finds the manifest of the workload within the path of the
+// repository and writes the resources to it, the path of the manifest is
+// returned relative to the repository
+func (writeback *gitWriteback) patch(
+	namespace string,
+	name string,
+	kind string,
+	totalResources kuber.TotalResources,
+) (string, bool, error) {
+	root := filepath.Join(writeback.directory, writeback.options.Path)
+
+	var (
+		found   string
+		changed bool
+	)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if found != "" {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		extension := filepath.Ext(path)
+		if extension != ".yaml" && extension != ".yml" {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return karma.Format(err, "unable to read manifest %s", path)
+		}
+
+		patched, ok, err := patchManifests(data, namespace, name, kind, totalResources)
+		if err != nil {
+			return karma.Format(err, "unable to patch manifest %s", path)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		found = path
+		if bytes.Equal(data, patched) {
+			return nil
+		}
+
+		changed = true
+
+		err = ioutil.WriteFile(path, patched, info.Mode())
+		if err != nil {
+			return karma.Format(err, "unable to write manifest %s", path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	if found == "" {
+		return "", false, karma.
+			Describe("kind", kind).
+			Describe("namespace", namespace).
+			Describe("name", name).
+			Format(
+				nil,
+				"manifest of the workload is not found in %s",
+				writeback.options.Path,
+			)
+	}
+
+	relative, err := filepath.Rel(writeback.directory, found)
+	if err != nil {
+		return "", false, karma.Format(err, "unable to get path of manifest")
+	}
+
+	return relative, changed, nil
+}
+
+// git runs the git command within the repository directory, the command is
+// killed if it doesn't complete within the timeout, so a hung remote doesn't
+// block executions, the token is given to git by the credential helper via
+// environment of the command, so it's never stored in the repository config
+// or visible in arguments of the process
+func (writeback *gitWriteback) git(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), writebackTimeout)
+	defer cancel()
+
+	command := args
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if username := getWritebackUsername(writeback.options); username != "" {
+		command = append(
+			[]string{"-c", "credential.helper=" + writebackCredentialHelper},
+			args...,
+		)
+		env = append(
+			env,
+			"MAGALIX_WRITEBACK_USERNAME="+username,
+			"MAGALIX_WRITEBACK_TOKEN="+writeback.options.Token,
+		)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", command...)
+	cmd.Dir = writeback.directory
+	cmd.Env = env
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = karma.Format(ctx.Err(), "git command timed out after %s", writebackTimeout)
+	}
+
+	if err != nil {
+		text := strings.TrimSpace(string(output))
+		if writeback.options.Token != "" {
+			text = strings.Replace(text, writeback.options.Token, "<token>", -1)
+		}
+
+		return nil, karma.
+			Describe("command", args[0]).
+			Describe("output", text).
+			Format(err, "git command failed")
+	}
+
+	return output, nil
+}
+
+// openPullRequest opens a pull request of the branch via api of the
+// provider, url of the pull request is returned
+func (writeback *gitWriteback) openPullRequest(
+	head string,
+	title string,
+	description string,
+) (string, error) {
+	path, err := getRepositoryPath(writeback.options.Repository)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		endpoint string
+		body     interface{}
+		result   struct {
+			HTMLURL string `json:"html_url"`
+			WebURL  string `json:"web_url"`
+		}
+	)
+
+	switch writeback.options.Provider {
+	case WritebackProviderGitHub:
+		endpoint = "/repos/" + path + "/pulls"
+		body = map[string]string{
+			"title": title,
+			"body":  description,
+			"head":  head,
+			"base":  writeback.options.Branch,
+		}
+
+	case WritebackProviderGitLab:
+		endpoint = "/projects/" + neturl.PathEscape(path) + "/merge_requests"
+		body = map[string]string{
+			"title":         title,
+			"description":   description,
+			"source_branch": head,
+			"target_branch": writeback.options.Branch,
+		}
+	}
+
+	err = writeback.request(http.MethodPost, endpoint, body, &result)
+	if err != nil {
+		return "", karma.Format(err, "unable to open pull request")
+	}
+
+	if result.HTMLURL != "" {
+		return result.HTMLURL, nil
+	}
+
+	return result.WebURL, nil
+}
+
+// findPullRequest returns url of the open pull request of the branch via
+// api of the provider, empty string is returned if there is no such pull
+// request
+func (writeback *gitWriteback) findPullRequest(head string) (string, error) {
+	path, err := getRepositoryPath(writeback.options.Repository)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		endpoint string
+		result   []struct {
+			HTMLURL string `json:"html_url"`
+			WebURL  string `json:"web_url"`
+		}
+	)
+
+	switch writeback.options.Provider {
+	case WritebackProviderGitHub:
+		owner := strings.SplitN(path, "/", 2)[0]
+		endpoint = "/repos/" + path + "/pulls?state=open&head=" +
+			neturl.QueryEscape(owner+":"+head)
+
+	case WritebackProviderGitLab:
+		endpoint = "/projects/" + neturl.PathEscape(path) +
+			"/merge_requests?state=opened&source_branch=" +
+			neturl.QueryEscape(head)
+	}
+
+	err = writeback.request(http.MethodGet, endpoint, nil, &result)
+	if err != nil {
+		return "", karma.Format(err, "unable to find open pull request")
+	}
+
+	if len(result) == 0 {
+		return "", nil
+	}
+
+	if result[0].HTMLURL != "" {
+		return result[0].HTMLURL, nil
+	}
+
+	return result[0].WebURL, nil
+}
+
+// request sends the request to the endpoint of api of the provider and
+// decodes its response into the result
+func (writeback *gitWriteback) request(
+	method string,
+	endpoint string,
+	body interface{},
+	result interface{},
+) error {
+	var (
+		api    = writeback.options.APIURL
+		header string
+		value  string
+	)
+
+	switch writeback.options.Provider {
+	case WritebackProviderGitHub:
+		if api == "" {
+			api = "https://api.github.com"
+		}
+
+		header, value = "Authorization", "token "+writeback.options.Token
+
+	case WritebackProviderGitLab:
+		if api == "" {
+			api = "https://gitlab.com/api/v4"
+		}
+
+		header, value = "PRIVATE-TOKEN", writeback.options.Token
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return karma.Format(err, "unable to encode request")
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, strings.TrimRight(api, "/")+endpoint, reader)
+	if err != nil {
+		return karma.Format(err, "unable to create request")
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	request.Header.Set("User-Agent", "magalix-agent")
+	request.Header.Set(header, value)
+
+	response, err := writeback.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return karma.Format(err, "unable to read response")
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return karma.
+			Describe("response", string(data)).
+			Format(nil, "unexpected status code %d", response.StatusCode)
+	}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return karma.Format(err, "unable to decode response")
+	}
+
+	return nil
+}
+
+// getWritebackBranch returns the branch of pull requests of the workload
+func getWritebackBranch(namespace, name, kind string) string {
+	return writebackBranchPrefix + strings.ToLower(kind) + "/" + namespace + "/" + name
+}
+
+// getWritebackUsername returns the username of git requests authorized by
+// the token if the repository is accessed via https, otherwise credentials
+// of git, e.g. a mounted ssh key, are used and empty username is returned
+func getWritebackUsername(options WritebackOptions) string {
+	if options.Token == "" {
+		return ""
+	}
+
+	url, err := neturl.Parse(options.Repository)
+	if err != nil || url.Scheme != "https" {
+		return ""
+	}
+
+	switch options.Provider {
+	case WritebackProviderGitHub:
+		return "x-access-token"
+	case WritebackProviderGitLab:
+		return "oauth2"
+	default:
+		return "git"
+	}
+}
+
+// getRepositoryPath returns path of the repository in format <owner>/<name>
+// of https and ssh urls
+func getRepositoryPath(repository string) (string, error) {
+	path := repository
+	if url, err := neturl.Parse(repository); err == nil && url.Host != "" {
+		path = url.Path
+	} else if index := strings.Index(repository, ":"); index >= 0 {
+		// NOTE: scp-like syntax, e.g. git@github.com:owner/name.git
+		path = repository[index+1:]
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if !strings.Contains(path, "/") {
+		return "", fmt.Errorf(
+			"unable to get path of repository %q, expected <owner>/<name>",
+			repository,
+		)
+	}
+
+	return path, nil
+}
+
+// patchManifests writes the resources to the document of the workload
+// within multi-document yaml, other documents are kept as is and documents
+// which can't be decoded are skipped, only values of the patched document
+// are changed, so its comments and order of keys are kept, documents which
+// can't be patched in place are encoded again, false is returned if there
+// is no document of the workload
+func patchManifests(
+	data []byte,
+	namespace string,
+	name string,
+	kind string,
+	totalResources kuber.TotalResources,
+) ([]byte, bool, error) {
+	documents := reDocumentSeparator.Split(string(data), -1)
+
+	for i, document := range documents {
+		// NOTE: documents which aren't valid yaml, e.g. templates of helm
+		// charts, can't be manifests of the workload
+		encoded, err := yaml.YAMLToJSON([]byte(document))
+		if err != nil {
+			continue
+		}
+
+		var object map[string]interface{}
+		err = json.Unmarshal(encoded, &object)
+		if err != nil || object == nil {
+			continue
+		}
+
+		if !isManifestOf(object, namespace, name, kind) {
+			continue
+		}
+
+		patched, changed, err := patchManifestDocument(document, kind, totalResources)
+		if err == errManifestNotPatchable {
+			patched, changed, err = encodeManifestDocument(object, kind, totalResources)
+		}
+		if err != nil {
+			return nil, false, karma.Format(err, "unable to patch document %d", i)
+		}
+
+		if !changed {
+			return data, true, nil
+		}
+
+		documents[i] = patched
+
+		return []byte(strings.Join(documents, "---\n")), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// encodeManifestDocument writes the resources to the decoded document and
+// encodes it again, comments of the document are not preserved, false is
+// returned if the document has the resources already
+func encodeManifestDocument(
+	object map[string]interface{},
+	kind string,
+	totalResources kuber.TotalResources,
+) (string, bool, error) {
+	if !hasManifestChanges(object, kind, totalResources) {
+		return "", false, nil
+	}
+
+	err := setManifestResources(object, kind, totalResources)
+	if err != nil {
+		return "", false, err
+	}
+
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return "", false, karma.Format(err, "unable to encode document")
+	}
+
+	patched, err := yaml.JSONToYAML(encoded)
+	if err != nil {
+		return "", false, karma.Format(err, "unable to encode document")
+	}
+
+	return string(patched), true, nil
+}
+
+// isManifestOf checks whether the object is the workload, objects without
+// namespace match any namespace since it's often set by kustomize or helm
+func isManifestOf(
+	object map[string]interface{},
+	namespace string,
+	name string,
+	kind string,
+) bool {
+	if value, _ := object["kind"].(string); value != kind {
+		return false
+	}
+
+	metadata, _ := object["metadata"].(map[string]interface{})
+	if value, _ := metadata["name"].(string); value != name {
+		return false
+	}
+
+	value, _ := metadata["namespace"].(string)
+	return value == "" || value == namespace
+}
+
+// hasManifestChanges checks whether the resources differ from resources of
+// the manifest
+func hasManifestChanges(
+	object map[string]interface{},
+	kind string,
+	totalResources kuber.TotalResources,
+) bool {
+	original, err := json.Marshal(object)
+	if err != nil {
+		return true
+	}
+
+	var copied map[string]interface{}
+	err = json.Unmarshal(original, &copied)
+	if err != nil {
+		return true
+	}
+
+	err = setManifestResources(copied, kind, totalResources)
+	if err != nil {
+		return true
+	}
+
+	patched, err := json.Marshal(copied)
+	if err != nil {
+		return true
+	}
+
+	return !bytes.Equal(original, patched)
+}
+
+// setManifestResources sets replicas and resources of containers of the
+// workload object, values are formatted the same way as patches of the
+// cluster
+func setManifestResources(
+	object map[string]interface{},
+	kind string,
+	totalResources kuber.TotalResources,
+) error {
+	spec, ok := object["spec"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("manifest has no spec")
+	}
+
+	if totalResources.Replicas != nil {
+		spec["replicas"] = *totalResources.Replicas
+	}
+
+	if len(totalResources.Containers) == 0 {
+		return nil
+	}
+
+	path := []string{"template", "spec"}
+	if kind == "CronJob" {
+		path = []string{"jobTemplate", "spec", "template", "spec"}
+	}
+
+	podSpec := spec
+	for _, key := range path {
+		podSpec, ok = podSpec[key].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("manifest has no pod template")
+		}
+	}
+
+	for _, container := range totalResources.Containers {
+		field := "containers"
+		if container.Init {
+			field = "initContainers"
+		}
+
+		items, _ := podSpec[field].([]interface{})
+
+		var resources map[string]interface{}
+		for _, item := range items {
+			item, ok := item.(map[string]interface{})
+			if !ok || item["name"] != container.Name {
+				continue
+			}
+
+			resources, ok = item["resources"].(map[string]interface{})
+			if !ok {
+				resources = map[string]interface{}{}
+				item["resources"] = resources
+			}
+		}
+
+		if resources == nil {
+			return fmt.Errorf("manifest has no container %s", container.Name)
+		}
+
+		for _, values := range []struct {
+			Field string
+			Value kuber.RequestLimit
+		}{
+			{"requests", container.Requests},
+			{"limits", container.Limits},
+		} {
+			if values.Value.CPU == nil && values.Value.Memory == nil {
+				continue
+			}
+
+			target, ok := resources[values.Field].(map[string]interface{})
+			if !ok {
+				target = map[string]interface{}{}
+				resources[values.Field] = target
+			}
+
+			if values.Value.CPU != nil {
+				target["cpu"] = fmt.Sprintf("%dm", *values.Value.CPU)
+			}
+
+			if values.Value.Memory != nil {
+				target["memory"] = fmt.Sprintf("%dMi", *values.Value.Memory)
+			}
+		}
+	}
+
+	return nil
+}
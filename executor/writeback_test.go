@@ -0,0 +1,188 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+func TestPatchManifests(t *testing.T) {
+	int64Pointer := func(value int64) *int64 { return &value }
+	intPointer := func(value int) *int { return &value }
+
+	manifests := `apiVersion: v1
+kind: Service
+metadata:
+  name: api
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 2 # scaled by decisions
+  template:
+    spec:
+      containers:
+      - name: app
+        image: api:1.0
+        resources:
+          requests:
+            cpu: "100m"
+        ports:
+        - containerPort: 8080
+`
+
+	totalResources := kuber.TotalResources{
+		Replicas: intPointer(3),
+		Containers: []kuber.ContainerResourcesRequirements{
+			{
+				Name:     "app",
+				Requests: kuber.RequestLimit{CPU: int64Pointer(250), Memory: int64Pointer(128)},
+				Limits:   kuber.RequestLimit{Memory: int64Pointer(256)},
+			},
+		},
+	}
+
+	patched, ok, err := patchManifests(
+		[]byte(manifests), "default", "api", "Deployment", totalResources,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatalf("manifest of deployment is not found")
+	}
+
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  name: api
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 3 # scaled by decisions
+  template:
+    spec:
+      containers:
+      - name: app
+        image: api:1.0
+        resources:
+          requests:
+            cpu: "250m"
+            memory: 128Mi
+          limits:
+            memory: 256Mi
+        ports:
+        - containerPort: 8080
+`
+	if string(patched) != expected {
+		t.Errorf("expected manifests:\n%s\ngot:\n%s", expected, patched)
+	}
+
+	again, ok, err := patchManifests(
+		patched, "default", "api", "Deployment", totalResources,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || string(again) != string(patched) {
+		t.Errorf("manifest with decided resources is changed again")
+	}
+
+	_, ok, err = patchManifests(
+		[]byte(manifests), "default", "api", "StatefulSet", totalResources,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Errorf("manifest of another kind is patched")
+	}
+
+	_, _, err = patchManifests(
+		[]byte(manifests), "default", "api", "Deployment",
+		kuber.TotalResources{
+			Containers: []kuber.ContainerResourcesRequirements{
+				{Name: "sidecar", Limits: kuber.RequestLimit{CPU: int64Pointer(100)}},
+			},
+		},
+	)
+	if err == nil {
+		t.Errorf("expected error of missing container")
+	}
+
+	templated := "replicas: {{ .Values.replicas }}\n  - {\n---\n" + manifests
+	_, ok, err = patchManifests(
+		[]byte(templated), "default", "api", "Deployment", totalResources,
+	)
+	if err != nil {
+		t.Errorf("unexpected error of document which can't be decoded: %s", err)
+	}
+
+	if !ok {
+		t.Errorf("manifest after document which can't be decoded is not patched")
+	}
+
+	flow := strings.Replace(manifests, `requests:
+            cpu: "100m"`, `requests: {cpu: 100m}`, 1)
+	patched, ok, err = patchManifests(
+		[]byte(flow), "default", "api", "Deployment", totalResources,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || !strings.Contains(string(patched), "memory: 256Mi") {
+		t.Errorf("manifest in flow style is not patched:\n%s", patched)
+	}
+}
+
+func TestGetWritebackUsername(t *testing.T) {
+	username := getWritebackUsername(WritebackOptions{
+		Repository: "https://github.com/acme/manifests.git",
+		Provider:   WritebackProviderGitHub,
+		Token:      "secret",
+	})
+	if username != "x-access-token" {
+		t.Errorf("unexpected username %q", username)
+	}
+
+	username = getWritebackUsername(WritebackOptions{
+		Repository: "git@github.com:acme/manifests.git",
+		Token:      "secret",
+	})
+	if username != "" {
+		t.Errorf("unexpected username of ssh repository %q", username)
+	}
+}
+
+func TestGetRepositoryPath(t *testing.T) {
+	for repository, expected := range map[string]string{
+		"https://github.com/acme/manifests.git": "acme/manifests",
+		"https://gitlab.com/acme/infra/apps":    "acme/infra/apps",
+		"git@github.com:acme/manifests.git":     "acme/manifests",
+		"ssh://git@gitlab.com/acme/apps.git":    "acme/apps",
+	} {
+		path, err := getRepositoryPath(repository)
+		if err != nil {
+			t.Errorf("unexpected error of %q: %s", repository, err)
+			continue
+		}
+
+		if path != expected {
+			t.Errorf("expected path of %q %q, got %q", repository, expected, path)
+		}
+	}
+
+	if _, err := getRepositoryPath("manifests"); err == nil {
+		t.Errorf("expected error of repository without owner")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/replay"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+// runExport writes entities and metrics recorded within the --since window
+// to a portable archive, which can be attached to support tickets
+func runExport(args map[string]interface{}, logger *log.Logger) error {
+	path, _ := args["--record"].(string)
+	if path == "" {
+		return karma.Format(nil, "--record is required to export a recording")
+	}
+
+	output := args["--output"].(string)
+	until := time.Now().UTC()
+	since := until.Add(-utils.MustParseDuration(args, "--since"))
+
+	records, err := replay.ReadRecords(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to create archive file %s",
+			output,
+		)
+	}
+	defer file.Close()
+
+	manifest, err := replay.WriteArchive(file, records, since, until)
+	if err != nil {
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to close archive file %s",
+			output,
+		)
+	}
+
+	logger.Infof(
+		karma.
+			Describe("path", output).
+			Describe("since", since).
+			Describe("until", until),
+		"exported %d entities snapshots and %d metrics batches",
+		manifest.Entities,
+		manifest.Metrics,
+	)
+
+	return nil
+}
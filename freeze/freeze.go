@@ -0,0 +1,234 @@
+package freeze
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// SourceGateway freeze requested by the backend
+	SourceGateway = "gateway"
+	// SourceLocal freeze requested by a local operator
+	SourceLocal = "local"
+	// SourceQuietHours freeze during configured quiet hours
+	SourceQuietHours = "quiet-hours"
+)
+
+// State state of the change freeze, a freeze without Until lasts until an
+// explicit unfreeze
+type State struct {
+	Frozen bool       `json:"frozen"`
+	Reason string     `json:"reason,omitempty"`
+	Source string     `json:"source,omitempty"`
+	Since  time.Time  `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// Freezer keeps the change freeze state, the state is persisted to a file so
+// a freeze survives restarts
+type Freezer struct {
+	logger *log.Logger
+	path   string
+
+	mutex sync.Mutex
+	state State
+}
+
+// IsFrozen checks whether changes of the cluster are frozen either by an
+// explicit freeze or by quiet hours, a nil freezer checks quiet hours only
+func (freezer *Freezer) IsFrozen() (State, bool) {
+	if freezer != nil {
+		state := freezer.GetState()
+		if state.Frozen {
+			return state, true
+		}
+	}
+
+	if quietHours != nil && quietHours.Contains(time.Now()) {
+		return State{
+			Frozen: true,
+			Reason: "quiet hours " + quietHours.String(),
+			Source: SourceQuietHours,
+		}, true
+	}
+
+	return State{}, false
+}
+
+// NewFreezer creates a new freezer restoring its state from the file, an
+// empty path disables persistence
+func NewFreezer(logger *log.Logger, path string) (*Freezer, error) {
+	freezer := &Freezer{
+		logger: logger,
+		path:   path,
+	}
+
+	if path == "" {
+		return freezer, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return freezer, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to read freeze state file %s",
+			path,
+		)
+	}
+
+	err = json.Unmarshal(data, &freezer.state)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode freeze state file %s",
+			path,
+		)
+	}
+
+	if state := freezer.GetState(); state.Frozen {
+		logger.Warningf(
+			karma.
+				Describe("reason", state.Reason).
+				Describe("source", state.Source).
+				Describe("until", state.Until),
+			"{freeze} cluster changes are frozen",
+		)
+	}
+
+	return freezer, nil
+}
+
+// GetState returns the current state, an expired freeze is not frozen
+func (freezer *Freezer) GetState() State {
+	freezer.mutex.Lock()
+	defer freezer.mutex.Unlock()
+
+	state := freezer.state
+	if state.Frozen && state.Until != nil && time.Now().After(*state.Until) {
+		return State{}
+	}
+
+	return state
+}
+
+// Freeze halts changes of the cluster, zero ttl freezes until an explicit
+// unfreeze
+func (freezer *Freezer) Freeze(reason, source string, ttl time.Duration) error {
+	now := time.Now().UTC()
+	state := State{
+		Frozen: true,
+		Reason: reason,
+		Source: source,
+		Since:  now,
+	}
+
+	if ttl > 0 {
+		until := now.Add(ttl)
+		state.Until = &until
+	}
+
+	freezer.logger.Warningf(
+		karma.
+			Describe("reason", reason).
+			Describe("source", source).
+			Describe("until", state.Until),
+		"{freeze} freezing cluster changes",
+	)
+
+	return freezer.set(state)
+}
+
+// Unfreeze resumes changes of the cluster
+func (freezer *Freezer) Unfreeze(source string) error {
+	freezer.logger.Infof(
+		karma.Describe("source", source),
+		"{freeze} unfreezing cluster changes",
+	)
+
+	return freezer.set(State{})
+}
+
+func (freezer *Freezer) set(state State) error {
+	freezer.mutex.Lock()
+	defer freezer.mutex.Unlock()
+
+	freezer.state = state
+
+	if freezer.path == "" {
+		return nil
+	}
+
+	return utils.WriteStateFile(freezer.path, state, "freeze")
+}
+
+// Listener handles freeze packets sent by the gateway
+func (freezer *Freezer) Listener(in []byte) ([]byte, error) {
+	var packet proto.PacketFreeze
+	err := proto.Decode(in, &packet)
+	if err != nil {
+		return nil, err
+	}
+
+	if packet.Frozen {
+		err = freezer.Freeze(packet.Reason, SourceGateway, packet.TTL)
+	} else {
+		err = freezer.Unfreeze(SourceGateway)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Encode(proto.PacketFreezeResponse{})
+}
+
+// ServeHTTP serves the freeze state to local operators, GET returns the
+// state, POST freezes changes with optional reason and ttl (e.g. 48h) query
+// parameters and DELETE unfreezes them
+func (freezer *Freezer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var err error
+
+	switch request.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var ttl time.Duration
+		if value := request.URL.Query().Get("ttl"); value != "" {
+			ttl, err = time.ParseDuration(value)
+			if err != nil {
+				http.Error(writer, "invalid ttl: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		err = freezer.Freeze(request.URL.Query().Get("reason"), SourceLocal, ttl)
+	case http.MethodDelete:
+		err = freezer.Unfreeze(SourceLocal)
+	default:
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		freezer.logger.Errorf(err, "{freeze} unable to change freeze state")
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(writer).Encode(freezer.GetState())
+	if err != nil {
+		freezer.logger.Errorf(err, "{freeze} unable to write response")
+	}
+}
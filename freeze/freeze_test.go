@@ -0,0 +1,104 @@
+package freeze
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MagalixTechnologies/log-go"
+)
+
+func TestFreezerPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freeze-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := log.New(true, false, "/dev/stderr")
+	path := filepath.Join(dir, "freeze.json")
+
+	freezer, err := NewFreezer(logger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freezer.GetState().Frozen {
+		t.Fatalf("expected new freezer to be unfrozen")
+	}
+
+	err = freezer.Freeze("release window", SourceLocal, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewFreezer(logger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := restored.GetState()
+	if !state.Frozen || state.Reason != "release window" || state.Source != SourceLocal {
+		t.Fatalf("unexpected restored state: %+v", state)
+	}
+
+	err = restored.Unfreeze(SourceGateway)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err = NewFreezer(logger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetState().Frozen {
+		t.Fatalf("expected unfreeze to be persisted")
+	}
+}
+
+func TestFreezerTTL(t *testing.T) {
+	freezer, err := NewFreezer(log.New(true, false, "/dev/stderr"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = freezer.Freeze("", SourceGateway, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !freezer.GetState().Frozen {
+		t.Fatalf("expected freezer to be frozen")
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	freezer.state.Until = &expired
+	if freezer.GetState().Frozen {
+		t.Fatalf("expected expired freeze to be unfrozen")
+	}
+}
+
+func TestFreezerIsFrozen(t *testing.T) {
+	freezer, err := NewFreezer(log.New(true, false, "/dev/stderr"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var disabled *Freezer
+	if _, frozen := disabled.IsFrozen(); frozen {
+		t.Fatalf("expected nil freezer to be unfrozen")
+	}
+
+	err = freezer.Freeze("incident", SourceLocal, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, frozen := freezer.IsFrozen()
+	if !frozen || state.Reason != "incident" {
+		t.Fatalf("expected freezer to be frozen by incident, got %+v", state)
+	}
+
+	if _, frozen := disabled.IsFrozen(); frozen {
+		t.Fatalf("freeze of another freezer affects nil freezer")
+	}
+}
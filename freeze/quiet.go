@@ -0,0 +1,190 @@
+package freeze
+
+import (
+	"strings"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// QuietHours recurring window in which cluster changes are frozen
+// regardless of the backend, a window crossing midnight belongs to the day
+// it starts on
+type QuietHours struct {
+	spec     string
+	start    time.Duration
+	end      time.Duration
+	days     [7]bool
+	location *time.Location
+}
+
+var quietHours *QuietHours
+
+// SetQuietHours sets quiet hours checked by IsFrozen of all freezers
+func SetQuietHours(value *QuietHours) {
+	quietHours = value
+}
+
+// ParseQuietHours parses quiet hours specified as a time range followed by
+// optional days and time zone, e.g. "22:00-06:00 Mon-Fri TZ=Europe/Berlin",
+// every day and the local time zone are used by default
+func ParseQuietHours(spec string) (*QuietHours, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, karma.Format(nil, "empty quiet hours")
+	}
+
+	quiet := &QuietHours{
+		spec:     spec,
+		location: time.Local,
+	}
+
+	bounds := strings.Split(fields[0], "-")
+	if len(bounds) != 2 {
+		return nil, karma.Format(
+			nil,
+			"invalid quiet hours range %q, expected HH:MM-HH:MM",
+			fields[0],
+		)
+	}
+
+	var err error
+	quiet.start, err = parseClock(bounds[0])
+	if err != nil {
+		return nil, err
+	}
+
+	quiet.end, err = parseClock(bounds[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if quiet.start == quiet.end {
+		return nil, karma.Format(
+			nil,
+			"invalid quiet hours range %q, start and end are equal",
+			fields[0],
+		)
+	}
+
+	daysSpecified := false
+	for _, field := range fields[1:] {
+		if strings.HasPrefix(field, "TZ=") {
+			quiet.location, err = time.LoadLocation(strings.TrimPrefix(field, "TZ="))
+			if err != nil {
+				return nil, karma.Format(err, "invalid quiet hours time zone")
+			}
+
+			continue
+		}
+
+		if daysSpecified {
+			return nil, karma.Format(
+				nil,
+				"unexpected quiet hours field %q",
+				field,
+			)
+		}
+
+		err = quiet.parseDays(field)
+		if err != nil {
+			return nil, err
+		}
+
+		daysSpecified = true
+	}
+
+	if !daysSpecified {
+		for day := range quiet.days {
+			quiet.days[day] = true
+		}
+	}
+
+	return quiet, nil
+}
+
+// parseDays parses comma separated days or ranges of days, e.g. Mon-Fri or
+// Sat,Sun, a range may wrap around the week, e.g. Fri-Mon
+func (quiet *QuietHours) parseDays(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return karma.Format(nil, "invalid quiet hours days %q", item)
+		}
+
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return karma.Format(nil, "unknown day %q", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[strings.ToLower(bounds[1])]
+			if !ok {
+				return karma.Format(nil, "unknown day %q", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			quiet.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, karma.Format(
+			err,
+			"invalid quiet hours time %q, expected HH:MM",
+			value,
+		)
+	}
+
+	return time.Duration(clock.Hour())*time.Hour +
+		time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Contains checks whether the given time is within quiet hours
+func (quiet *QuietHours) Contains(now time.Time) bool {
+	now = now.In(quiet.location)
+
+	offset := time.Duration(now.Hour())*time.Hour +
+		time.Duration(now.Minute())*time.Minute
+	day := now.Weekday()
+
+	if quiet.start < quiet.end {
+		return quiet.days[day] && offset >= quiet.start && offset < quiet.end
+	}
+
+	// NOTE: the window crosses midnight, early hours belong to the window
+	// started the day before
+	if offset >= quiet.start {
+		return quiet.days[day]
+	}
+
+	if offset < quiet.end {
+		return quiet.days[(day+6)%7]
+	}
+
+	return false
+}
+
+func (quiet *QuietHours) String() string {
+	return quiet.spec
+}
@@ -0,0 +1,71 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursContains(t *testing.T) {
+	quiet, err := ParseQuietHours("22:00-06:00 Mon-Fri TZ=UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for value, expected := range map[string]bool{
+		// Monday
+		"2021-03-01T21:59:00Z": false,
+		"2021-03-01T22:00:00Z": true,
+		"2021-03-01T12:00:00Z": false,
+		// Tuesday early hours belong to Monday window
+		"2021-03-02T05:59:00Z": true,
+		"2021-03-02T06:00:00Z": false,
+		// Saturday early hours belong to Friday window
+		"2021-03-06T03:00:00Z": true,
+		"2021-03-06T23:00:00Z": false,
+		// Monday early hours belong to Sunday which is not quiet
+		"2021-03-01T03:00:00Z": false,
+	} {
+		now, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if contains := quiet.Contains(now); contains != expected {
+			t.Errorf("%s: expected %v, got %v", value, expected, contains)
+		}
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	quiet, err := ParseQuietHours("09:00-17:00 Fri-Mon,Wed TZ=UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [7]bool{
+		time.Sunday:    true,
+		time.Monday:    true,
+		time.Tuesday:   false,
+		time.Wednesday: true,
+		time.Thursday:  false,
+		time.Friday:    true,
+		time.Saturday:  true,
+	}
+	if quiet.days != expected {
+		t.Errorf("expected days %v, got %v", expected, quiet.days)
+	}
+
+	for _, spec := range []string{
+		"",
+		"22:00",
+		"25:00-06:00",
+		"06:00-06:00",
+		"22:00-06:00 Funday",
+		"22:00-06:00 Mon-Fri Sat",
+		"22:00-06:00 TZ=Nowhere/Land",
+	} {
+		if _, err := ParseQuietHours(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package imds
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/reconquest/karma-go"
+)
+
+// URLs of instance metadata services of cloud providers
+const (
+	AWSURL   = "http://169.254.169.254/latest"
+	GCPURL   = "http://metadata.google.internal/computeMetadata/v1"
+	AzureURL = "http://169.254.169.254/metadata"
+)
+
+// GetAWSHeaders returns headers of requests to the instance metadata service
+// of aws with a session token of IMDSv2, no token is set if IMDSv2 isn't
+// available since IMDSv1 works without it
+func GetAWSHeaders(httpClient *http.Client) map[string]string {
+	token, _ := Request(
+		httpClient,
+		http.MethodPut,
+		AWSURL+"/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"},
+	)
+
+	headers := map[string]string{}
+	if len(token) > 0 {
+		headers["X-aws-ec2-metadata-token"] = string(token)
+	}
+
+	return headers
+}
+
+// Request requests the address of an instance metadata service and returns
+// the body of the response
+func Request(
+	httpClient *http.Client,
+	method string,
+	address string,
+	headers map[string]string,
+) ([]byte, error) {
+	ctx := karma.Describe("url", address)
+
+	request, err := http.NewRequest(method, address, nil)
+	if err != nil {
+		return nil, ctx.Format(err, "unable to create request")
+	}
+
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, ctx.Format(err, "unable to request instance metadata")
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, ctx.Format(err, "unable to read instance metadata")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, ctx.
+			Describe("status", response.StatusCode).
+			Describe("body", string(body)).
+			Format(nil, "unexpected instance metadata response")
+	}
+
+	return body, nil
+}
@@ -0,0 +1,101 @@
+package kuber
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// eventsComponent source component of events created by the agent
+const eventsComponent = "magalix-agent"
+
+var reAdmissionRejection = regexp.MustCompile(
+	`admission webhook "([^"]+)" denied the request:?\s*(.*)`,
+)
+
+// AdmissionRejection rejection of a change by a validating admission
+// webhook, e.g. OPA Gatekeeper
+type AdmissionRejection struct {
+	Webhook string
+	Message string
+}
+
+// GetAdmissionRejection returns the rejection if the error of a request is
+// caused by an admission webhook which denied the request
+func GetAdmissionRejection(err error) (AdmissionRejection, bool) {
+	if err == nil {
+		return AdmissionRejection{}, false
+	}
+
+	message := err.Error()
+	if status, ok := err.(kerrors.APIStatus); ok {
+		message = status.Status().Message
+	}
+
+	matches := reAdmissionRejection.FindStringSubmatch(message)
+	if matches == nil {
+		return AdmissionRejection{}, false
+	}
+
+	return AdmissionRejection{
+		Webhook: matches[1],
+		Message: matches[2],
+	}, true
+}
+
+// WorkloadReference reference of a workload which events are about, the uid
+// is optional but events without it are not shown by kubectl describe
+type WorkloadReference struct {
+	Kind      string
+	Namespace string
+	Name      string
+	UID       types.UID
+}
+
+// CreateWarningEvent creates a warning event of the workload
+func (kube *Kube) CreateWarningEvent(
+	workload WorkloadReference,
+	reason string,
+	message string,
+) error {
+	now := kmeta.NewTime(time.Now())
+
+	event := &kv1.Event{
+		ObjectMeta: kmeta.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", workload.Name, now.UnixNano()),
+			Namespace: workload.Namespace,
+		},
+		InvolvedObject: kv1.ObjectReference{
+			Kind:      workload.Kind,
+			Namespace: workload.Namespace,
+			Name:      workload.Name,
+			UID:       workload.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           kv1.EventTypeWarning,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source: kv1.EventSource{
+			Component: eventsComponent,
+		},
+	}
+
+	_, err := kube.core.Events(workload.Namespace).Create(event)
+	if err != nil {
+		return karma.
+			Describe("namespace", workload.Namespace).
+			Describe("name", workload.Name).
+			Describe("reason", reason).
+			Format(err, "unable to create event")
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package kuber
+
+import (
+	"errors"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetAdmissionRejection(t *testing.T) {
+	err := kerrors.NewForbidden(
+		kschema.GroupResource{Group: "apps", Resource: "deployments"},
+		"api",
+		errors.New(`admission webhook "validation.gatekeeper.sh" denied the request: `+
+			`[container-limits] container <api> cpu limit <4> is higher than the maximum allowed of <2>`),
+	)
+
+	rejection, ok := GetAdmissionRejection(err)
+	if !ok {
+		t.Fatalf("rejection is not detected")
+	}
+
+	if rejection.Webhook != "validation.gatekeeper.sh" {
+		t.Errorf("unexpected webhook: %q", rejection.Webhook)
+	}
+
+	expected := "[container-limits] container <api> cpu limit <4> is higher than the maximum allowed of <2>"
+	if rejection.Message != expected {
+		t.Errorf("expected message %q, got %q", expected, rejection.Message)
+	}
+
+	_, ok = GetAdmissionRejection(kerrors.NewConflict(
+		kschema.GroupResource{Group: "apps", Resource: "deployments"},
+		"api",
+		errors.New("the object has been modified"),
+	))
+	if ok {
+		t.Errorf("conflict is detected as rejection")
+	}
+}
@@ -0,0 +1,92 @@
+package kuber
+
+import (
+	"encoding/json"
+	"sync"
+
+	krest "k8s.io/client-go/rest"
+)
+
+// servedVersion group version of an api served by the cluster, the
+// preferred version is used until capabilities are detected, it's shared by
+// copies of kube
+type servedVersion struct {
+	mutex     sync.Mutex
+	preferred string
+	version   string
+}
+
+func newServedVersion(preferred string) *servedVersion {
+	return &servedVersion{preferred: preferred}
+}
+
+func (served *servedVersion) get() string {
+	served.mutex.Lock()
+	defer served.mutex.Unlock()
+
+	if served.version == "" {
+		return served.preferred
+	}
+
+	return served.version
+}
+
+func (served *servedVersion) set(version string) {
+	served.mutex.Lock()
+	defer served.mutex.Unlock()
+
+	served.version = version
+}
+
+// GetAppsVersion returns group version of workloads apis used by kube
+func (kube *Kube) GetAppsVersion() string {
+	return kube.appsVersion.get()
+}
+
+// GetAppsClient returns rest client of the served group version of
+// workloads apis, apps/v1beta2 is used only by clusters without apps/v1
+func (kube *Kube) GetAppsClient() krest.Interface {
+	if kube.appsVersion.get() == FeatureWorkloadsV1beta2 {
+		return kube.appsV1beta2
+	}
+
+	return kube.appsV1
+}
+
+// getApps retrieves workloads of the resource, all workloads of the
+// namespace are listed if the name is empty, objects are decoded into
+// apps/v1 types as older group versions share the same schema
+func (kube *Kube) getApps(
+	namespace string,
+	resource string,
+	name string,
+	result interface{},
+) error {
+	return getObjects(kube.GetAppsClient(), namespace, resource, name, result)
+}
+
+// getObjects retrieves objects of the resource decoding them from json into
+// the result, so objects of any group version can be decoded into a single
+// type
+func getObjects(
+	client krest.Interface,
+	namespace string,
+	resource string,
+	name string,
+	result interface{},
+) error {
+	request := client.
+		Get().
+		Namespace(namespace).
+		Resource(resource)
+	if name != "" {
+		request = request.Name(name)
+	}
+
+	body, err := request.Do().Raw()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, result)
+}
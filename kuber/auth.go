@@ -0,0 +1,57 @@
+package kuber
+
+import (
+	"os/exec"
+
+	"github.com/reconquest/karma-go"
+	krest "k8s.io/client-go/rest"
+
+	// NOTE: auth providers of kubeconfig files (gcp, azure, oidc) are
+	// registered by the plugin package, exec credential plugins are handled
+	// by the transport
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+// describeAuth describes how the config authenticates, credentials are never
+// included
+func describeAuth(ctx *karma.Context, config *krest.Config) *karma.Context {
+	switch {
+	case config.ExecProvider != nil:
+		return ctx.
+			Describe("auth", "exec").
+			Describe("auth-command", config.ExecProvider.Command)
+	case config.AuthProvider != nil:
+		return ctx.
+			Describe("auth", "auth-provider").
+			Describe("auth-provider", config.AuthProvider.Name)
+	case config.BearerToken != "":
+		return ctx.Describe("auth", "token")
+	case config.CertFile != "" || len(config.CertData) > 0:
+		return ctx.Describe("auth", "certificate")
+	case config.Username != "":
+		return ctx.Describe("auth", "basic")
+	}
+
+	return ctx.Describe("auth", "none")
+}
+
+// validateAuth checks that the exec credential plugin of the config, e.g.
+// aws-iam-authenticator or gke-gcloud-auth-plugin, can be run, otherwise
+// every request to the cluster would fail
+func validateAuth(config *krest.Config) error {
+	if config.ExecProvider == nil {
+		return nil
+	}
+
+	_, err := exec.LookPath(config.ExecProvider.Command)
+	if err != nil {
+		return karma.
+			Describe("command", config.ExecProvider.Command).
+			Format(
+				err,
+				"exec credential plugin is not found, it should be installed and available in PATH",
+			)
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package kuber
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+	kdiscovery "k8s.io/client-go/discovery"
+)
+
+// features of the cluster the agent relies on, api features are named by
+// group versions, cron jobs of batch/v1 are named by the resource as
+// batch/v1 is served by all clusters
+const (
+	FeatureWorkloads        = "apps/v1"
+	FeatureWorkloadsV1beta2 = "apps/v1beta2"
+	FeatureCronJobs         = "batch/v1/cronjobs"
+	FeatureCronJobsV1beta1  = "batch/v1beta1"
+	FeatureNetworkPolicies  = "networking.k8s.io/v1"
+	FeaturePolicy           = "policy/v1"
+	FeaturePolicyV1beta1    = "policy/v1beta1"
+	FeatureMetricsAPI       = "metrics.k8s.io/v1beta1"
+	FeatureVPA              = "autoscaling.k8s.io/v1"
+	FeatureKubeletSummary   = "kubelet/stats/summary"
+	FeatureKubeletCAdvisor  = "kubelet/metrics/cadvisor"
+)
+
+// apiFeatures features detected by api groups served by the api-server
+var apiFeatures = []string{
+	FeatureWorkloads,
+	FeatureWorkloadsV1beta2,
+	FeatureCronJobsV1beta1,
+	FeatureNetworkPolicies,
+	FeaturePolicy,
+	FeaturePolicyV1beta1,
+	FeatureMetricsAPI,
+	FeatureVPA,
+}
+
+// minSupportedMinorVersion the oldest supported kubernetes 1.x version
+const minSupportedMinorVersion = 8
+
+var versionNumberRegexp = regexp.MustCompile(`^\d+`)
+
+// Capabilities version and features of the cluster, features which were
+// not detected are assumed to be supported
+type Capabilities struct {
+	Version  string
+	Major    int
+	Minor    int
+	Platform string
+
+	mutex    sync.Mutex
+	features map[string]bool
+}
+
+// DetectCapabilities detects version of the api-server and api groups it
+// serves, kubelet features are set by metrics sources, older group versions
+// of workloads, cron jobs and policy apis are used only if the cluster
+// doesn't serve the stable ones
+func (kube *Kube) DetectCapabilities() (*Capabilities, error) {
+	discovery := kube.Clientset.Discovery()
+
+	version, err := discovery.ServerVersion()
+	if err != nil {
+		return nil, karma.Format(err, "unable to get kubernetes version")
+	}
+
+	groups, err := discovery.ServerGroups()
+	if err != nil {
+		return nil, karma.Format(err, "unable to get kubernetes api groups")
+	}
+
+	served := map[string]bool{}
+	for _, group := range groups.Groups {
+		for _, groupVersion := range group.Versions {
+			served[groupVersion.GroupVersion] = true
+		}
+	}
+
+	capabilities := &Capabilities{
+		Version:  version.GitVersion,
+		Major:    parseVersionNumber(version.Major),
+		Minor:    parseVersionNumber(version.Minor),
+		Platform: version.Platform,
+		features: map[string]bool{},
+	}
+
+	for _, feature := range apiFeatures {
+		capabilities.features[feature] = served[feature]
+	}
+
+	capabilities.features[FeatureCronJobs] = isResourceServed(
+		discovery, "batch/v1", "cronjobs",
+	)
+
+	kube.Capabilities = capabilities
+
+	if !served[FeatureWorkloads] && served[FeatureWorkloadsV1beta2] {
+		kube.appsVersion.set(FeatureWorkloadsV1beta2)
+	} else {
+		kube.appsVersion.set(FeatureWorkloads)
+	}
+
+	if !capabilities.features[FeatureCronJobs] && served[FeatureCronJobsV1beta1] {
+		kube.cronJobsVersion.set(FeatureCronJobsV1beta1)
+	} else {
+		kube.cronJobsVersion.set(FeatureCronJobs)
+	}
+
+	if !served[FeaturePolicy] && served[FeaturePolicyV1beta1] {
+		kube.policyVersion.set(FeaturePolicyV1beta1)
+	} else {
+		kube.policyVersion.set(FeaturePolicy)
+	}
+
+	return capabilities, nil
+}
+
+// ShareCapabilities shares capabilities and served group versions detected
+// by the source kube, e.g. with the kube of the executor identity which
+// doesn't detect them itself
+func (kube *Kube) ShareCapabilities(source *Kube) {
+	kube.Capabilities = source.Capabilities
+	kube.appsVersion = source.appsVersion
+	kube.cronJobsVersion = source.cronJobsVersion
+	kube.policyVersion = source.policyVersion
+}
+
+// isResourceServed checks whether the group version serves the resource,
+// the resource is considered not served if it can't be discovered
+func isResourceServed(
+	discovery kdiscovery.ServerResourcesInterface,
+	groupVersion string,
+	resource string,
+) bool {
+	resources, err := discovery.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return false
+	}
+
+	for _, item := range resources.APIResources {
+		if item.Name == resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseVersionNumber parses version numbers reported by managed clusters
+// such as 18+
+func parseVersionNumber(value string) int {
+	number, _ := strconv.Atoi(versionNumberRegexp.FindString(value))
+	return number
+}
+
+// Validate checks whether the agent can run in the cluster
+func (capabilities *Capabilities) Validate() error {
+	if capabilities.Major < 1 ||
+		(capabilities.Major == 1 && capabilities.Minor < minSupportedMinorVersion) {
+		return karma.Format(
+			nil,
+			"kubernetes %s is not supported, the oldest supported version is 1.%d",
+			capabilities.Version,
+			minSupportedMinorVersion,
+		)
+	}
+
+	if !capabilities.IsSupported(FeatureWorkloads) &&
+		!capabilities.IsSupported(FeatureWorkloadsV1beta2) {
+		return karma.Format(
+			nil,
+			"kubernetes %s serves neither %s nor %s api which are required to scan workloads",
+			capabilities.Version,
+			FeatureWorkloads,
+			FeatureWorkloadsV1beta2,
+		)
+	}
+
+	return nil
+}
+
+// IsSupported checks whether the feature is available, unknown features and
+// features of undetected clusters are assumed to be available
+func (capabilities *Capabilities) IsSupported(feature string) bool {
+	if capabilities == nil {
+		return true
+	}
+
+	capabilities.mutex.Lock()
+	defer capabilities.mutex.Unlock()
+
+	supported, ok := capabilities.features[feature]
+	return !ok || supported
+}
+
+// Set sets availability of the feature
+func (capabilities *Capabilities) Set(feature string, supported bool) {
+	if capabilities == nil {
+		return
+	}
+
+	capabilities.mutex.Lock()
+	defer capabilities.mutex.Unlock()
+
+	capabilities.features[feature] = supported
+}
+
+// GetFeatures returns availability of detected features
+func (capabilities *Capabilities) GetFeatures() map[string]bool {
+	capabilities.mutex.Lock()
+	defer capabilities.mutex.Unlock()
+
+	features := make(map[string]bool, len(capabilities.features))
+	for feature, supported := range capabilities.features {
+		features[feature] = supported
+	}
+
+	return features
+}
+
+// GetUnsupported returns sorted list of unavailable features
+func (capabilities *Capabilities) GetUnsupported() []string {
+	unsupported := []string{}
+	for feature, supported := range capabilities.GetFeatures() {
+		if !supported {
+			unsupported = append(unsupported, feature)
+		}
+	}
+
+	sort.Strings(unsupported)
+
+	return unsupported
+}
@@ -0,0 +1,86 @@
+package kuber
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapabilitiesValidate(t *testing.T) {
+	newCapabilities := func(minor int, features map[string]bool) *Capabilities {
+		return &Capabilities{
+			Version:  "v1.x",
+			Major:    1,
+			Minor:    minor,
+			features: features,
+		}
+	}
+
+	err := newCapabilities(15, map[string]bool{FeatureWorkloads: true}).Validate()
+	if err != nil {
+		t.Errorf("expected supported cluster, got %s", err)
+	}
+
+	err = newCapabilities(7, map[string]bool{FeatureWorkloads: true}).Validate()
+	if err == nil {
+		t.Errorf("expected error for old version")
+	}
+
+	err = newCapabilities(8, map[string]bool{
+		FeatureWorkloads:        false,
+		FeatureWorkloadsV1beta2: true,
+	}).Validate()
+	if err != nil {
+		t.Errorf("expected supported cluster serving %s, got %s", FeatureWorkloadsV1beta2, err)
+	}
+
+	err = newCapabilities(16, map[string]bool{
+		FeatureWorkloads:        false,
+		FeatureWorkloadsV1beta2: false,
+	}).Validate()
+	if err == nil {
+		t.Errorf("expected error for cluster without workloads apis")
+	}
+}
+
+func TestCapabilitiesIsSupported(t *testing.T) {
+	var undetected *Capabilities
+	if !undetected.IsSupported(FeatureCronJobs) {
+		t.Errorf("features of undetected cluster should be supported")
+	}
+
+	capabilities := &Capabilities{
+		features: map[string]bool{
+			FeatureCronJobs: false,
+			FeatureVPA:      true,
+		},
+	}
+	capabilities.Set(FeatureKubeletCAdvisor, false)
+
+	for feature, expected := range map[string]bool{
+		FeatureCronJobs:        false,
+		FeatureVPA:             true,
+		FeatureKubeletCAdvisor: false,
+		FeatureKubeletSummary:  true,
+	} {
+		if supported := capabilities.IsSupported(feature); supported != expected {
+			t.Errorf("%s: expected %v, got %v", feature, expected, supported)
+		}
+	}
+
+	expected := []string{FeatureCronJobs, FeatureKubeletCAdvisor}
+	if unsupported := capabilities.GetUnsupported(); !reflect.DeepEqual(unsupported, expected) {
+		t.Errorf("expected unsupported %v, got %v", expected, unsupported)
+	}
+}
+
+func TestParseVersionNumber(t *testing.T) {
+	for value, expected := range map[string]int{
+		"1":   1,
+		"18+": 18,
+		"":    0,
+	} {
+		if number := parseVersionNumber(value); number != expected {
+			t.Errorf("%q: expected %d, got %d", value, expected, number)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package kuber
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextRoundTripper binds requests to the context, so they are cancelled
+// once the context is done
+type contextRoundTripper struct {
+	next    http.RoundTripper
+	context context.Context
+}
+
+func (roundTripper *contextRoundTripper) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	return roundTripper.next.RoundTrip(request.WithContext(roundTripper.context))
+}
+
+// WithContext returns a copy of kube which requests are cancelled once the
+// context is done, throttling, usage and capabilities are shared with kube.
+// The copy uses its own connections, so it's intended for bounded
+// operations, e.g. a single scan of a command line tool.
+func (kube *Kube) WithContext(ctx context.Context) (*Kube, error) {
+	config := *kube.config
+
+	// NOTE: the config is wrapped already by counting and throttling
+	// round trippers of kube
+	wrap := config.WrapTransport
+	config.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		return wrap(&contextRoundTripper{
+			next:    next,
+			context: ctx,
+		})
+	}
+
+	contextKube, err := newKubeClients(&config, kube.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	contextKube.Throttling = kube.Throttling
+	contextKube.Usage = kube.Usage
+	contextKube.ShareCapabilities(kube)
+	contextKube.wrapTransport = kube.wrapTransport
+
+	return contextKube, nil
+}
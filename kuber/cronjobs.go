@@ -0,0 +1,83 @@
+package kuber
+
+import (
+	"encoding/gob"
+
+	kbatch "k8s.io/api/batch/v1"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	krest "k8s.io/client-go/rest"
+)
+
+func init() {
+	// NOTE: raw resources are encoded by gob, cron jobs are sent as the
+	// internal model
+	gob.Register(new(CronJobList))
+}
+
+// CronJob cron job of batch/v1 or batch/v1beta1, both group versions share
+// the schema of fields used by the agent
+type CronJob struct {
+	kmeta.TypeMeta   `json:",inline"`
+	kmeta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CronJobSpec   `json:"spec"`
+	Status CronJobStatus `json:"status"`
+}
+
+// CronJobSpec schedule and job template of a cron job
+type CronJobSpec struct {
+	Schedule          string             `json:"schedule"`
+	Suspend           *bool              `json:"suspend,omitempty"`
+	ConcurrencyPolicy string             `json:"concurrencyPolicy,omitempty"`
+	JobTemplate       CronJobJobTemplate `json:"jobTemplate"`
+}
+
+// CronJobJobTemplate template of jobs created by a cron job
+type CronJobJobTemplate struct {
+	kmeta.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec kbatch.JobSpec `json:"spec"`
+}
+
+// CronJobStatus currently running jobs of a cron job
+type CronJobStatus struct {
+	Active           []kv1.ObjectReference `json:"active,omitempty"`
+	LastScheduleTime *kmeta.Time           `json:"lastScheduleTime,omitempty"`
+}
+
+// CronJobList list of cron jobs
+type CronJobList struct {
+	kmeta.TypeMeta `json:",inline"`
+	kmeta.ListMeta `json:"metadata,omitempty"`
+
+	Items []CronJob `json:"items"`
+}
+
+// isCronJobsSupported checks whether the cluster serves cron jobs by any
+// group version
+func (kube *Kube) isCronJobsSupported() bool {
+	return kube.Capabilities.IsSupported(FeatureCronJobs) ||
+		kube.Capabilities.IsSupported(FeatureCronJobsV1beta1)
+}
+
+// GetCronJobsVersion returns group version of cron jobs apis used by kube
+func (kube *Kube) GetCronJobsVersion() string {
+	return kube.cronJobsVersion.get()
+}
+
+// getCronJobsClient returns rest client of the served group version of cron
+// jobs, batch/v1beta1 is used only by clusters older than 1.21
+func (kube *Kube) getCronJobsClient() krest.Interface {
+	if kube.cronJobsVersion.get() == FeatureCronJobsV1beta1 {
+		return kube.batchV1beta1
+	}
+
+	return kube.batchV1
+}
+
+// getCronJobs retrieves cron jobs, all cron jobs of the namespace are listed
+// if the name is empty
+func (kube *Kube) getCronJobs(namespace, name string, result interface{}) error {
+	return getObjects(kube.getCronJobsClient(), namespace, "cronjobs", name, result)
+}
@@ -0,0 +1,47 @@
+package kuber
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCronJobDecoding(t *testing.T) {
+	for _, apiVersion := range []string{"batch/v1", "batch/v1beta1"} {
+		data := []byte(`{
+			"apiVersion": "` + apiVersion + `",
+			"kind": "CronJobList",
+			"items": [{
+				"metadata": {"name": "report", "namespace": "default"},
+				"spec": {
+					"schedule": "0 * * * *",
+					"suspend": true,
+					"jobTemplate": {"spec": {"template": {"spec": {
+						"containers": [{"name": "report", "image": "report:1"}]
+					}}}}
+				},
+				"status": {"active": [{"kind": "Job", "name": "report-1"}]}
+			}]
+		}`)
+
+		var cronJobs CronJobList
+		err := json.Unmarshal(data, &cronJobs)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", apiVersion, err)
+			continue
+		}
+
+		if len(cronJobs.Items) != 1 {
+			t.Errorf("%s: expected single cron job, got %d", apiVersion, len(cronJobs.Items))
+			continue
+		}
+
+		cronJob := cronJobs.Items[0]
+		containers := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers
+		if cronJob.Name != "report" ||
+			cronJob.Spec.Suspend == nil || !*cronJob.Spec.Suspend ||
+			len(containers) != 1 || containers[0].Image != "report:1" ||
+			len(cronJob.Status.Active) != 1 {
+			t.Errorf("%s: unexpected cron job: %+v", apiVersion, cronJob)
+		}
+	}
+}
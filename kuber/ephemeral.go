@@ -0,0 +1,98 @@
+package kuber
+
+import (
+	"encoding/json"
+
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+const podsPath = "/api/v1/pods"
+
+// EphemeralContainer ephemeral (debug) container attached to a running pod,
+// such containers are not part of the pod template so they are decoded from
+// raw pods which makes them available regardless of the client version
+type EphemeralContainer struct {
+	Namespace           string
+	PodName             string
+	Name                string
+	Image               string
+	TargetContainerName string
+}
+
+// getPodsWithEphemeralContainers get pods in all namespaces along with their
+// ephemeral containers decoded from the same list
+func (kube *Kube) getPodsWithEphemeralContainers() (
+	*kv1.PodList, []EphemeralContainer, error,
+) {
+	kube.logger.Debugf(nil, "{kubernetes} retrieving list of pods")
+	body, err := kube.core.RESTClient().
+		Get().
+		AbsPath(podsPath).
+		DoRaw()
+	if err != nil {
+		return nil, nil, karma.Format(
+			err,
+			"unable to retrieve pods from all namespaces",
+		)
+	}
+
+	var podList kv1.PodList
+	err = json.Unmarshal(body, &podList)
+	if err != nil {
+		return nil, nil, karma.Format(
+			err,
+			"unable to unmarshal pods",
+		)
+	}
+
+	containers, err := decodeEphemeralContainers(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &podList, containers, nil
+}
+
+// decodeEphemeralContainers decodes ephemeral containers of the raw list of
+// pods
+func decodeEphemeralContainers(body []byte) ([]EphemeralContainer, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+
+			Spec struct {
+				EphemeralContainers []struct {
+					Name                string `json:"name"`
+					Image               string `json:"image"`
+					TargetContainerName string `json:"targetContainerName"`
+				} `json:"ephemeralContainers"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	err := json.Unmarshal(body, &list)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to unmarshal ephemeral containers of pods",
+		)
+	}
+
+	containers := []EphemeralContainer{}
+	for _, pod := range list.Items {
+		for _, container := range pod.Spec.EphemeralContainers {
+			containers = append(containers, EphemeralContainer{
+				Namespace:           pod.Metadata.Namespace,
+				PodName:             pod.Metadata.Name,
+				Name:                container.Name,
+				Image:               container.Image,
+				TargetContainerName: container.TargetContainerName,
+			})
+		}
+	}
+
+	return containers, nil
+}
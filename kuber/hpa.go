@@ -0,0 +1,87 @@
+package kuber
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/reconquest/karma-go"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const horizontalPodAutoscalersPath = "/apis/autoscaling/v1/namespaces/%s/horizontalpodautoscalers"
+
+// HorizontalPodAutoscaler minimal representation of HorizontalPodAutoscaler
+// object, only fields needed by the agent are decoded
+type HorizontalPodAutoscaler struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+
+	Spec struct {
+		ScaleTargetRef struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"scaleTargetRef"`
+	} `json:"spec"`
+}
+
+// GetHorizontalPodAutoscalers get horizontal pod autoscalers of a namespace,
+// returns nil list without an error if the autoscaling api isn't available
+func (kube *Kube) GetHorizontalPodAutoscalers(
+	namespace string,
+) ([]HorizontalPodAutoscaler, error) {
+	kube.logger.Debugf(
+		karma.Describe("namespace", namespace),
+		"{kubernetes} retrieving list of horizontal pod autoscalers",
+	)
+
+	body, err := kube.core.RESTClient().
+		Get().
+		AbsPath(fmt.Sprintf(horizontalPodAutoscalersPath, namespace)).
+		DoRaw()
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to retrieve horizontal pod autoscalers of namespace %s",
+			namespace,
+		)
+	}
+
+	var list struct {
+		Items []HorizontalPodAutoscaler `json:"items"`
+	}
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to unmarshal horizontal pod autoscalers",
+		)
+	}
+
+	return list.Items, nil
+}
+
+// HasHorizontalPodAutoscaler checks whether replicas of the workload are
+// scaled by a horizontal pod autoscaler
+func (kube *Kube) HasHorizontalPodAutoscaler(
+	kind, namespace, name string,
+) (bool, error) {
+	autoscalers, err := kube.GetHorizontalPodAutoscalers(namespace)
+	if err != nil {
+		return false, err
+	}
+
+	for _, autoscaler := range autoscalers {
+		target := autoscaler.Spec.ScaleTargetRef
+		if target.Kind == kind && target.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
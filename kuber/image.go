@@ -0,0 +1,57 @@
+package kuber
+
+import (
+	"strings"
+)
+
+// defaultImageTag tag pulled if the image reference has neither tag nor
+// digest
+const defaultImageTag = "latest"
+
+// GetImageTag returns tag of the image reference, e.g. 1.19 of
+// registry:5000/nginx:1.19, an image referenced by digest only has no tag
+func GetImageTag(image string) string {
+	reference := image
+	digested := false
+	if index := strings.Index(reference, "@"); index >= 0 {
+		reference = reference[:index]
+		digested = true
+	}
+
+	// NOTE: a colon before the last slash separates the registry port
+	if index := strings.LastIndex(reference, ":"); index >= 0 &&
+		index > strings.LastIndex(reference, "/") {
+		return reference[index+1:]
+	}
+
+	if digested {
+		return ""
+	}
+
+	return defaultImageTag
+}
+
+// GetImageDigest returns digest of the image from image ID reported by the
+// container runtime, e.g. docker-pullable://nginx@sha256:... or sha256:...
+func GetImageDigest(imageID string) string {
+	imageID = TrimRuntimeScheme(imageID)
+
+	if index := strings.LastIndex(imageID, "@"); index >= 0 {
+		return imageID[index+1:]
+	}
+
+	if strings.HasPrefix(imageID, "sha256:") {
+		return imageID
+	}
+
+	return ""
+}
+
+// TrimRuntimeScheme trims runtime scheme such as docker:// or containerd://
+func TrimRuntimeScheme(id string) string {
+	if index := strings.Index(id, "://"); index >= 0 {
+		return id[index+len("://"):]
+	}
+
+	return id
+}
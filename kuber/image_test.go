@@ -0,0 +1,36 @@
+package kuber
+
+import (
+	"testing"
+)
+
+func TestGetImageTag(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                          "latest",
+		"nginx:1.19":                     "1.19",
+		"registry:5000/nginx":            "latest",
+		"registry:5000/team/nginx:1.19":  "1.19",
+		"nginx@sha256:abc":               "",
+		"nginx:1.19@sha256:abc":          "1.19",
+		"registry:5000/nginx@sha256:abc": "",
+	} {
+		if tag := GetImageTag(image); tag != expected {
+			t.Errorf("image %q: expected tag %q, got %q", image, expected, tag)
+		}
+	}
+}
+
+func TestGetImageDigest(t *testing.T) {
+	for imageID, expected := range map[string]string{
+		"docker-pullable://nginx@sha256:abc": "sha256:abc",
+		"docker.io/library/nginx@sha256:abc": "sha256:abc",
+		"sha256:abc":                         "sha256:abc",
+		"docker://sha256:abc":                "sha256:abc",
+		"nginx:latest":                       "",
+		"":                                   "",
+	} {
+		if digest := GetImageDigest(imageID); digest != expected {
+			t.Errorf("image %q: expected %q, got %q", imageID, expected, digest)
+		}
+	}
+}
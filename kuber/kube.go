@@ -2,30 +2,33 @@ package kuber
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/MagalixCorp/magalix-agent/utils"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/reconquest/karma-go"
 	"golang.org/x/sync/errgroup"
 	"k8s.io/api/apps/v1"
-	kbeta2 "k8s.io/api/apps/v1beta2"
-	kbeta1 "k8s.io/api/batch/v1beta1"
 	kv1 "k8s.io/api/core/v1"
+	knetworkingv1 "k8s.io/api/networking/v1"
 	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	beta2client "k8s.io/client-go/kubernetes/typed/apps/v1beta2"
-	kapps "k8s.io/client-go/kubernetes/typed/apps/v1beta2"
 	batch "k8s.io/client-go/kubernetes/typed/batch/v1beta1"
 	kcore "k8s.io/client-go/kubernetes/typed/core/v1"
+	knetworking "k8s.io/client-go/kubernetes/typed/networking/v1"
 	krest "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	certutil "k8s.io/client-go/util/cert"
 
 	"github.com/MagalixCorp/magalix-agent/client"
@@ -39,15 +42,38 @@ const (
 
 // Kube kube struct
 type Kube struct {
-	Clientset     *kubernetes.Clientset
-	ClientV1Beta2 *beta2client.AppsV1beta2Client
-	ClientBatch   *batch.BatchV1beta1Client
+	Clientset   *kubernetes.Clientset
+	ClientBatch *batch.BatchV1beta1Client
 
 	core   kcore.CoreV1Interface
-	apps   kapps.AppsV1beta2Interface
-	batch  batch.BatchV1beta1Interface
+	net    knetworking.NetworkingV1Interface
 	config *krest.Config
 	logger *log.Logger
+
+	// appsV1 and appsV1beta2 rest clients of workloads apis, the client of
+	// the group version served by the cluster is used
+	appsV1      krest.Interface
+	appsV1beta2 krest.Interface
+	appsVersion *servedVersion
+
+	// batchV1 and batchV1beta1 rest clients of cron jobs apis
+	batchV1         krest.Interface
+	batchV1beta1    krest.Interface
+	cronJobsVersion *servedVersion
+
+	// policyVersion group version of pod disruption budgets api
+	policyVersion *servedVersion
+
+	// Throttling throttled responses of the api-server
+	Throttling *Throttling
+	// Usage requests sent to the api-server
+	Usage *Usage
+	// Capabilities version and features of the cluster, nil until detected
+	Capabilities *Capabilities
+
+	// wrapTransport transport wrapper of the config given to NewKube, e.g.
+	// authentication by token files
+	wrapTransport func(http.RoundTripper) http.RoundTripper
 }
 
 // RequestLimit request limit
@@ -59,6 +85,7 @@ type RequestLimit struct {
 // ContainerResources container resources
 type ContainerResourcesRequirements struct {
 	Name     string
+	Init     bool
 	Requests RequestLimit
 	Limits   RequestLimit
 }
@@ -69,26 +96,53 @@ type TotalResources struct {
 	Containers []ContainerResourcesRequirements
 }
 
+// UnsetResource resource value of a container which is removed from the
+// spec, e.g. a value added by a decision which wasn't set before
+type UnsetResource struct {
+	Container string           `json:"container"`
+	Init      bool             `json:"init"`
+	Limit     bool             `json:"limit"`
+	Resource  kv1.ResourceName `json:"resource"`
+}
+
 type Resource struct {
 	Namespace      string
 	Name           string
+	UID            types.UID
 	Kind           string
+	Labels         map[string]string
 	Annotations    map[string]string
 	ReplicasStatus proto.ReplicasStatus
 	Containers     []kv1.Container
+	InitContainers []kv1.Container
 	PodRegexp      *regexp.Regexp
+
+	// PodLabels labels of pods created by the workload
+	PodLabels map[string]string
+	// PriorityClassName priority class of pods created by the workload
+	PriorityClassName string
+	// RolloutStrategy strategy used to replace pods on changes, nil if pods
+	// are not replaced by the workload
+	RolloutStrategy *proto.RolloutStrategy
+
+	// CreatedAt creation time of the workload
+	CreatedAt time.Time
+	// ResourceVersion resource version of the workload
+	ResourceVersion string
+	// TemplateHash hash of the pod template, it changes on every deploy
+	TemplateHash string
 }
 
 type RawResources struct {
 	PodList        *kv1.PodList
 	LimitRangeList *kv1.LimitRangeList
 
-	CronJobList *kbeta1.CronJobList
+	CronJobList *CronJobList
 
-	DeploymentList  *kbeta2.DeploymentList
-	StatefulSetList *kbeta2.StatefulSetList
-	DaemonSetList   *kbeta2.DaemonSetList
-	ReplicaSetList  *kbeta2.ReplicaSetList
+	DeploymentList  *v1.DeploymentList
+	StatefulSetList *v1.StatefulSetList
+	DaemonSetList   *v1.DaemonSetList
+	ReplicaSetList  *v1.ReplicaSetList
 }
 
 func InitKubernetes(
@@ -98,6 +152,8 @@ func InitKubernetes(
 	var config *krest.Config
 	var err error
 
+	kubeconfig, _ := args["--kubeconfig"].(string)
+
 	if args["--kube-incluster"].(bool) {
 		client.Infof(nil, "initializing kubernetes incluster config")
 
@@ -109,6 +165,37 @@ func InitKubernetes(
 			)
 		}
 
+		// NOTE: bound service account tokens expire, so the token is
+		// re-read once kubelet rotates it
+		useTokenFile(config, serviceAccountTokenPath)
+
+	} else if kubeconfig != "" {
+		kubeContext, _ := args["--kube-context"].(string)
+
+		ctx := karma.
+			Describe("path", kubeconfig).
+			Describe("context", kubeContext)
+
+		client.Infof(ctx, "initializing kubernetes kubeconfig")
+
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+		).ClientConfig()
+		if err != nil {
+			return nil, ctx.Format(
+				err,
+				"unable to load kubeconfig",
+			)
+		}
+
+		err = validateAuth(config)
+		if err != nil {
+			return nil, ctx.Reason(err)
+		}
+
+		client.Infof(describeAuth(ctx, config), "kubeconfig is loaded")
+
 	} else {
 		client.Infof(
 			nil,
@@ -142,9 +229,14 @@ func InitKubernetes(
 		if args["--kube-insecure"].(bool) {
 			config.Insecure = true
 		}
+
+		if tokenFile, _ := args["--kube-token-file"].(string); tokenFile != "" {
+			useTokenFile(config, tokenFile)
+		}
 	}
 
 	config.Timeout = utils.MustParseDuration(args, "--kube-timeout")
+	setRateLimit(config, args)
 
 	client.Debugf(
 		karma.
@@ -154,38 +246,115 @@ func InitKubernetes(
 		"initializing kubernetes Clientset",
 	)
 
-	clientset, err := kubernetes.NewForConfig(config)
+	return NewKube(config, client.Logger)
+}
+
+// InitExecutorKubernetes creates kubernetes client used for mutations, it
+// uses a separate identity if --executor-kubeconfig is specified, otherwise
+// a copy of the given kube with the normal priority is returned
+func InitExecutorKubernetes(
+	args map[string]interface{},
+	client *client.Client,
+	kube *Kube,
+) (*Kube, error) {
+	path, ok := args["--executor-kubeconfig"].(string)
+	if !ok || path == "" {
+		return kube.WithPriority(PriorityNormal)
+	}
+
+	client.Infof(
+		karma.Describe("path", path),
+		"initializing executor kubernetes config",
+	)
+
+	config, err := clientcmd.BuildConfigFromFlags("", path)
 	if err != nil {
-		return nil, karma.Format(
+		return nil, karma.Describe("path", path).Format(
 			err,
-			"unable to create Clientset",
+			"unable to load executor kubeconfig",
 		)
 	}
 
-	clientV1Beta2, err := beta2client.NewForConfig(config)
+	err = validateAuth(config)
 	if err != nil {
-		return nil, karma.Format(
-			err,
-			"unable to create ClientV1Beta2",
-		)
+		return nil, karma.Describe("path", path).Reason(err)
+	}
+
+	config.Timeout = utils.MustParseDuration(args, "--kube-timeout")
+	setRateLimit(config, args)
+
+	return NewKube(config, client.Logger)
+}
+
+// NewKube creates kubernetes client using specified config, requests are
+// rate limited by priorities, counted and throttled responses are tracked,
+// a transport wrapper of the config is kept
+func NewKube(config *krest.Config, logger *log.Logger) (*Kube, error) {
+	throttling := newThrottling()
+	usage := &Usage{}
+	limiter := useRateLimit(config)
+
+	wrap := config.WrapTransport
+	config.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			next = wrap(next)
+		}
+
+		next = &usageRoundTripper{
+			next: &throttlingRoundTripper{
+				next:       next,
+				throttling: throttling,
+			},
+			usage: usage,
+		}
+
+		if limiter != nil {
+			next = &rateLimitRoundTripper{
+				next:    next,
+				limiter: limiter,
+			}
+		}
+
+		return next
+	}
+
+	kube, err := newKubeClients(config, logger)
+	if err != nil {
+		return nil, err
 	}
 
-	clientV1Beta1, err := batch.NewForConfig(config)
+	kube.Throttling = throttling
+	kube.Usage = usage
+	kube.wrapTransport = wrap
+
+	return kube, nil
+}
+
+func newKubeClients(config *krest.Config, logger *log.Logger) (*Kube, error) {
+	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, karma.Format(
 			err,
-			"unable to create batch clientV1Beta1",
+			"unable to create Clientset",
 		)
 	}
 
 	kube := &Kube{
-		Clientset:     clientset,
-		ClientV1Beta2: clientV1Beta2,
-		core:          clientset.CoreV1(),
-		apps:          clientset.AppsV1beta2(),
-		batch:         clientV1Beta1,
-		config:        config,
-		logger:        client.Logger,
+		Clientset: clientset,
+		core:      clientset.CoreV1(),
+		net:       clientset.NetworkingV1(),
+		config:    config,
+		logger:    logger,
+
+		appsV1:      clientset.AppsV1().RESTClient(),
+		appsV1beta2: clientset.AppsV1beta2().RESTClient(),
+		appsVersion: newServedVersion(FeatureWorkloads),
+
+		batchV1:         clientset.BatchV1().RESTClient(),
+		batchV1beta1:    clientset.BatchV1beta1().RESTClient(),
+		cronJobsVersion: newServedVersion(FeatureCronJobs),
+
+		policyVersion: newServedVersion(FeaturePolicy),
 	}
 
 	return kube, nil
@@ -205,14 +374,33 @@ func (kube *Kube) GetNodes() (*kv1.NodeList, error) {
 	return nodes, nil
 }
 
+// GetNode get kubernetes node by name
+func (kube *Kube) GetNode(name string) (*kv1.Node, error) {
+	kube.logger.Debugf(nil, "{kubernetes} retrieving node %s", name)
+	node, err := kube.core.Nodes().Get(name, kmeta.GetOptions{})
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve node %s",
+			name,
+		)
+	}
+
+	return node, nil
+}
+
 func (kube *Kube) GetResources() (
 	pods []kv1.Pod,
 	limitRanges []kv1.LimitRange,
+	resourceQuotas []kv1.ResourceQuota,
 	resources []Resource,
 	rawResources map[string]interface{},
+	owners OwnersIndex,
+	ephemeralContainers []EphemeralContainer,
 	err error,
 ) {
 	rawResources = map[string]interface{}{}
+	owners = OwnersIndex{}
 
 	m := sync.Mutex{}
 	group := errgroup.Group{}
@@ -234,11 +422,19 @@ func (kube *Kube) GetResources() (
 
 			for _, controller := range controllers.Items {
 				resources = append(resources, Resource{
-					Kind:        "ReplicationController",
-					Annotations: controller.Annotations,
-					Namespace:   controller.Namespace,
-					Name:        controller.Name,
-					Containers:  controller.Spec.Template.Spec.Containers,
+					Kind:              "ReplicationController",
+					Labels:            controller.Labels,
+					Annotations:       controller.Annotations,
+					PodLabels:         controller.Spec.Template.Labels,
+					Namespace:         controller.Namespace,
+					Name:              controller.Name,
+					UID:               controller.UID,
+					Containers:        controller.Spec.Template.Spec.Containers,
+					InitContainers:    controller.Spec.Template.Spec.InitContainers,
+					PriorityClassName: controller.Spec.Template.Spec.PriorityClassName,
+					CreatedAt:         controller.CreationTimestamp.Time,
+					ResourceVersion:   controller.ResourceVersion,
+					TemplateHash:      getTemplateHash(controller.Spec.Template),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s-[^-]+$",
@@ -258,7 +454,9 @@ func (kube *Kube) GetResources() (
 	})
 
 	group.Go(func() error {
-		podList, err := kube.GetPods()
+		// NOTE: ephemeral containers are decoded from the same list, so
+		// they don't cost another request
+		podList, podsEphemeralContainers, err := kube.getPodsWithEphemeralContainers()
 		if err != nil {
 			return karma.Format(
 				err,
@@ -272,6 +470,8 @@ func (kube *Kube) GetResources() (
 			m.Lock()
 			defer m.Unlock()
 
+			ephemeralContainers = podsEphemeralContainers
+
 			rawResources["pods"] = podList
 
 			for _, pod := range pods {
@@ -279,11 +479,19 @@ func (kube *Kube) GetResources() (
 					continue
 				}
 				resources = append(resources, Resource{
-					Kind:        "OrphanPod",
-					Annotations: pod.Annotations,
-					Namespace:   pod.Namespace,
-					Name:        pod.Name,
-					Containers:  pod.Spec.Containers,
+					Kind:              "OrphanPod",
+					Labels:            pod.Labels,
+					Annotations:       pod.Annotations,
+					PodLabels:         pod.Labels,
+					Namespace:         pod.Namespace,
+					Name:              pod.Name,
+					UID:               pod.UID,
+					Containers:        pod.Spec.Containers,
+					InitContainers:    pod.Spec.InitContainers,
+					PriorityClassName: pod.Spec.PriorityClassName,
+					CreatedAt:         pod.CreationTimestamp.Time,
+					ResourceVersion:   pod.ResourceVersion,
+					TemplateHash:      getTemplateHash(pod.Spec),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s$",
@@ -320,11 +528,20 @@ func (kube *Kube) GetResources() (
 
 			for _, deployment := range deployments.Items {
 				resources = append(resources, Resource{
-					Kind:        "Deployment",
-					Annotations: deployment.Annotations,
-					Namespace:   deployment.Namespace,
-					Name:        deployment.Name,
-					Containers:  deployment.Spec.Template.Spec.Containers,
+					Kind:              "Deployment",
+					Labels:            deployment.Labels,
+					Annotations:       deployment.Annotations,
+					PodLabels:         deployment.Spec.Template.Labels,
+					Namespace:         deployment.Namespace,
+					Name:              deployment.Name,
+					UID:               deployment.UID,
+					Containers:        deployment.Spec.Template.Spec.Containers,
+					InitContainers:    deployment.Spec.Template.Spec.InitContainers,
+					PriorityClassName: deployment.Spec.Template.Spec.PriorityClassName,
+					RolloutStrategy:   getDeploymentStrategy(deployment.Spec.Strategy),
+					CreatedAt:         deployment.CreationTimestamp.Time,
+					ResourceVersion:   deployment.ResourceVersion,
+					TemplateHash:      getTemplateHash(deployment.Spec.Template),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s-[^-]+-[^-]+$",
@@ -361,11 +578,20 @@ func (kube *Kube) GetResources() (
 
 			for _, set := range statefulSets.Items {
 				resources = append(resources, Resource{
-					Kind:        "StatefulSet",
-					Annotations: set.Annotations,
-					Namespace:   set.Namespace,
-					Name:        set.Name,
-					Containers:  set.Spec.Template.Spec.Containers,
+					Kind:              "StatefulSet",
+					Labels:            set.Labels,
+					Annotations:       set.Annotations,
+					PodLabels:         set.Spec.Template.Labels,
+					Namespace:         set.Namespace,
+					Name:              set.Name,
+					UID:               set.UID,
+					Containers:        set.Spec.Template.Spec.Containers,
+					InitContainers:    set.Spec.Template.Spec.InitContainers,
+					PriorityClassName: set.Spec.Template.Spec.PriorityClassName,
+					RolloutStrategy:   getStatefulSetStrategy(set.Spec.UpdateStrategy),
+					CreatedAt:         set.CreationTimestamp.Time,
+					ResourceVersion:   set.ResourceVersion,
+					TemplateHash:      getTemplateHash(set.Spec.Template),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s-([0-9]+)$",
@@ -402,11 +628,20 @@ func (kube *Kube) GetResources() (
 
 			for _, daemon := range daemonSets.Items {
 				resources = append(resources, Resource{
-					Kind:        "DaemonSet",
-					Annotations: daemon.Annotations,
-					Namespace:   daemon.Namespace,
-					Name:        daemon.Name,
-					Containers:  daemon.Spec.Template.Spec.Containers,
+					Kind:              "DaemonSet",
+					Labels:            daemon.Labels,
+					Annotations:       daemon.Annotations,
+					PodLabels:         daemon.Spec.Template.Labels,
+					Namespace:         daemon.Namespace,
+					Name:              daemon.Name,
+					UID:               daemon.UID,
+					Containers:        daemon.Spec.Template.Spec.Containers,
+					InitContainers:    daemon.Spec.Template.Spec.InitContainers,
+					PriorityClassName: daemon.Spec.Template.Spec.PriorityClassName,
+					RolloutStrategy:   getDaemonSetStrategy(daemon.Spec.UpdateStrategy),
+					CreatedAt:         daemon.CreationTimestamp.Time,
+					ResourceVersion:   daemon.ResourceVersion,
+					TemplateHash:      getTemplateHash(daemon.Spec.Template),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s-[^-]+$",
@@ -444,14 +679,23 @@ func (kube *Kube) GetResources() (
 			for _, replicaSet := range replicaSets.Items {
 				// skipping when it is a part of another service
 				if len(replicaSet.GetOwnerReferences()) > 0 {
+					owners.add(&replicaSet)
 					continue
 				}
 				resources = append(resources, Resource{
-					Kind:        "ReplicaSet",
-					Annotations: replicaSet.Annotations,
-					Namespace:   replicaSet.Namespace,
-					Name:        replicaSet.Name,
-					Containers:  replicaSet.Spec.Template.Spec.Containers,
+					Kind:              "ReplicaSet",
+					Labels:            replicaSet.Labels,
+					Annotations:       replicaSet.Annotations,
+					PodLabels:         replicaSet.Spec.Template.Labels,
+					Namespace:         replicaSet.Namespace,
+					Name:              replicaSet.Name,
+					UID:               replicaSet.UID,
+					Containers:        replicaSet.Spec.Template.Spec.Containers,
+					InitContainers:    replicaSet.Spec.Template.Spec.InitContainers,
+					PriorityClassName: replicaSet.Spec.Template.Spec.PriorityClassName,
+					CreatedAt:         replicaSet.CreationTimestamp.Time,
+					ResourceVersion:   replicaSet.ResourceVersion,
+					TemplateHash:      getTemplateHash(replicaSet.Spec.Template),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s-[^-]+$",
@@ -489,11 +733,19 @@ func (kube *Kube) GetResources() (
 			for _, cronJob := range cronJobs.Items {
 				activeCount := int32(len(cronJob.Status.Active))
 				resources = append(resources, Resource{
-					Kind:        "CronJob",
-					Annotations: cronJob.Annotations,
-					Namespace:   cronJob.Namespace,
-					Name:        cronJob.Name,
-					Containers:  cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers,
+					Kind:              "CronJob",
+					Labels:            cronJob.Labels,
+					Annotations:       cronJob.Annotations,
+					PodLabels:         cronJob.Spec.JobTemplate.Spec.Template.Labels,
+					Namespace:         cronJob.Namespace,
+					Name:              cronJob.Name,
+					UID:               cronJob.UID,
+					Containers:        cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers,
+					InitContainers:    cronJob.Spec.JobTemplate.Spec.Template.Spec.InitContainers,
+					PriorityClassName: cronJob.Spec.JobTemplate.Spec.Template.Spec.PriorityClassName,
+					CreatedAt:         cronJob.CreationTimestamp.Time,
+					ResourceVersion:   cronJob.ResourceVersion,
+					TemplateHash:      getTemplateHash(cronJob.Spec.JobTemplate.Spec.Template),
 					PodRegexp: regexp.MustCompile(
 						fmt.Sprintf(
 							"^%s-[^-]+-[^-]+$",
@@ -531,11 +783,47 @@ func (kube *Kube) GetResources() (
 		return nil
 	})
 
+	group.Go(func() error {
+		// NOTE: resource quotas are optional, e.g. RBAC of older installs
+		// doesn't allow listing them, so scans continue without quotas
+		resourceQuotaList, err := kube.GetResourceQuotas()
+		if err != nil {
+			kube.logger.Warningf(
+				err,
+				"{kubernetes} unable to get resourceQuotas, scanning without them",
+			)
+			return nil
+		}
+
+		if resourceQuotaList != nil {
+			resourceQuotas = resourceQuotaList.Items
+
+			m.Lock()
+			defer m.Unlock()
+
+			rawResources["resourceQuotas"] = resourceQuotaList
+		}
+
+		return nil
+	})
+
 	err = group.Wait()
 
 	return
 }
 
+// getTemplateHash returns a hash of a pod template, it is used to detect
+// deploys of workloads
+func getTemplateHash(template interface{}) string {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8])
+}
+
 func newInt32Pointer(val int32) *int32 {
 	res := new(int32)
 	*res = val
@@ -556,6 +844,25 @@ func (kube *Kube) GetPods() (*kv1.PodList, error) {
 	return podList, nil
 }
 
+// GetNodesEvents get kubernetes events of nodes with the specified reason
+func (kube *Kube) GetNodesEvents(reason string) (*kv1.EventList, error) {
+	kube.logger.Debugf(
+		karma.Describe("reason", reason),
+		"{kubernetes} retrieving list of nodes events",
+	)
+	events, err := kube.core.Events("").List(kmeta.ListOptions{
+		FieldSelector: "involvedObject.kind=Node,reason=" + reason,
+	})
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve nodes events",
+		)
+	}
+
+	return events, nil
+}
+
 // GetReplicationControllers get replication controllers
 func (kube *Kube) GetReplicationControllers() (
 	*kv1.ReplicationControllerList, error,
@@ -580,9 +887,10 @@ func (kube *Kube) GetReplicationControllers() (
 }
 
 // GetDeployments get deployments
-func (kube *Kube) GetDeployments() (*kbeta2.DeploymentList, error) {
+func (kube *Kube) GetDeployments() (*v1.DeploymentList, error) {
 	kube.logger.Debugf(nil, "{kubernetes} retrieving list of deployments")
-	deployments, err := kube.apps.Deployments("").List(kmeta.ListOptions{})
+	deployments := &v1.DeploymentList{}
+	err := kube.getApps("", "deployments", "", deployments)
 	if err != nil {
 		return nil, karma.Format(
 			err,
@@ -601,12 +909,11 @@ func (kube *Kube) GetDeployments() (*kbeta2.DeploymentList, error) {
 
 // GetStatefulSets get statuful sets
 func (kube *Kube) GetStatefulSets() (
-	*kbeta2.StatefulSetList, error,
+	*v1.StatefulSetList, error,
 ) {
 	kube.logger.Debugf(nil, "{kubernetes} retrieving list of stateful sets")
-	statefulSets, err := kube.apps.
-		StatefulSets("").
-		List(kmeta.ListOptions{})
+	statefulSets := &v1.StatefulSetList{}
+	err := kube.getApps("", "statefulsets", "", statefulSets)
 	if err != nil {
 		return nil, karma.Format(
 			err,
@@ -625,12 +932,11 @@ func (kube *Kube) GetStatefulSets() (
 
 // GetDaemonSets get daemon sets
 func (kube *Kube) GetDaemonSets() (
-	*kbeta2.DaemonSetList, error,
+	*v1.DaemonSetList, error,
 ) {
 	kube.logger.Debugf(nil, "{kubernetes} retrieving list of daemon sets")
-	daemonSets, err := kube.apps.
-		DaemonSets("").
-		List(kmeta.ListOptions{})
+	daemonSets := &v1.DaemonSetList{}
+	err := kube.getApps("", "daemonsets", "", daemonSets)
 	if err != nil {
 		return nil, karma.Format(
 			err,
@@ -649,12 +955,11 @@ func (kube *Kube) GetDaemonSets() (
 
 // GetReplicaSets get replicasets
 func (kube *Kube) GetReplicaSets() (
-	*kbeta2.ReplicaSetList, error,
+	*v1.ReplicaSetList, error,
 ) {
 	kube.logger.Debugf(nil, "{kubernetes} retrieving list of replica sets")
-	replicaSets, err := kube.apps.
-		ReplicaSets("").
-		List(kmeta.ListOptions{})
+	replicaSets := &v1.ReplicaSetList{}
+	err := kube.getApps("", "replicasets", "", replicaSets)
 	if err != nil {
 		return nil, karma.Format(
 			err,
@@ -671,14 +976,18 @@ func (kube *Kube) GetReplicaSets() (
 	return replicaSets, nil
 }
 
-// GetCronJobs get cron jobs
+// GetCronJobs get cron jobs, returns nil list without an error if the
+// cluster doesn't serve cron jobs
 func (kube *Kube) GetCronJobs() (
-	*kbeta1.CronJobList, error,
+	*CronJobList, error,
 ) {
+	if !kube.isCronJobsSupported() {
+		return nil, nil
+	}
+
 	kube.logger.Debugf(nil, "{kubernetes} retrieving list of cron jobs")
-	cronJobs, err := kube.batch.
-		CronJobs("").
-		List(kmeta.ListOptions{})
+	cronJobs := &CronJobList{}
+	err := kube.getCronJobs("", "", cronJobs)
 	if err != nil {
 		return nil, karma.Format(
 			err,
@@ -712,12 +1021,59 @@ func (kube *Kube) GetLimitRanges() (
 	return limitRanges, nil
 }
 
+// GetResourceQuotas get resource quotas for namespaces
+func (kube *Kube) GetResourceQuotas() (
+	*kv1.ResourceQuotaList, error,
+) {
+	kube.logger.Debugf(nil, "{kubernetes} retrieving list of resourceQuotas from all namespaces")
+	resourceQuotas, err := kube.core.ResourceQuotas("").
+		List(kmeta.ListOptions{})
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve list of resourceQuotas from all namespaces",
+		)
+	}
+
+	return resourceQuotas, nil
+}
+
+// GetNetworkPolicies get network policies for namespaces
+func (kube *Kube) GetNetworkPolicies() (
+	*knetworkingv1.NetworkPolicyList, error,
+) {
+	kube.logger.Debugf(nil, "{kubernetes} retrieving list of networkPolicies from all namespaces")
+	networkPolicies, err := kube.net.NetworkPolicies("").
+		List(kmeta.ListOptions{})
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve list of networkPolicies from all namespaces",
+		)
+	}
+
+	return networkPolicies, nil
+}
+
+// GetNamespaces get kubernetes namespaces
+func (kube *Kube) GetNamespaces() (*kv1.NamespaceList, error) {
+	kube.logger.Debugf(nil, "{kubernetes} retrieving list of namespaces")
+	namespaces, err := kube.core.Namespaces().List(kmeta.ListOptions{})
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve namespaces",
+		)
+	}
+
+	return namespaces, nil
+}
+
 func (kube *Kube) GetStatefulSet(namespace, name string) (
 	*v1.StatefulSet, error,
 ) {
-	statefulSet, err := kube.Clientset.AppsV1().
-		StatefulSets(namespace).
-		Get(name, kmeta.GetOptions{})
+	statefulSet := &v1.StatefulSet{}
+	err := kube.getApps(namespace, "statefulsets", name, statefulSet)
 	if err != nil {
 		return nil, karma.Format(
 			err,
@@ -726,13 +1082,96 @@ func (kube *Kube) GetStatefulSet(namespace, name string) (
 		)
 	}
 
-	if statefulSet != nil {
-		maskPodSpec(&statefulSet.Spec.Template.Spec)
-	}
+	maskPodSpec(&statefulSet.Spec.Template.Spec)
 
 	return statefulSet, nil
 }
 
+// WorkloadSpec replicas and containers of a workload's pod template
+type WorkloadSpec struct {
+	Replicas       *int32
+	Containers     []kv1.Container
+	InitContainers []kv1.Container
+
+	// Labels and Annotations of the workload object, e.g. to identify
+	// controllers managing it
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// GetWorkloadSpec retrieves the live spec of a workload, replicas are nil
+// for kinds which are not scaled by replicas
+func (kube *Kube) GetWorkloadSpec(kind, namespace, name string) (
+	*WorkloadSpec, error,
+) {
+	var (
+		metadata kmeta.ObjectMeta
+		template kv1.PodTemplateSpec
+		replicas *int32
+		err      error
+	)
+
+	switch strings.ToLower(kind) {
+	case "deployment":
+		deployment := &v1.Deployment{}
+		err = kube.getApps(namespace, "deployments", name, deployment)
+		if err == nil {
+			metadata = deployment.ObjectMeta
+			template, replicas = deployment.Spec.Template, deployment.Spec.Replicas
+		}
+	case "statefulset":
+		statefulSet := &v1.StatefulSet{}
+		err = kube.getApps(namespace, "statefulsets", name, statefulSet)
+		if err == nil {
+			metadata = statefulSet.ObjectMeta
+			template, replicas = statefulSet.Spec.Template, statefulSet.Spec.Replicas
+		}
+	case "daemonset":
+		daemonSet := &v1.DaemonSet{}
+		err = kube.getApps(namespace, "daemonsets", name, daemonSet)
+		if err == nil {
+			metadata = daemonSet.ObjectMeta
+			template = daemonSet.Spec.Template
+		}
+	case "replicaset":
+		replicaSet := &v1.ReplicaSet{}
+		err = kube.getApps(namespace, "replicasets", name, replicaSet)
+		if err == nil {
+			metadata = replicaSet.ObjectMeta
+			template, replicas = replicaSet.Spec.Template, replicaSet.Spec.Replicas
+		}
+	case "cronjob":
+		cronJob := &CronJob{}
+		err = kube.getCronJobs(namespace, name, cronJob)
+		if err == nil {
+			metadata = cronJob.ObjectMeta
+			template = cronJob.Spec.JobTemplate.Spec.Template
+		}
+	default:
+		return nil, karma.
+			Describe("kind", kind).
+			Format(nil, "unsupported workload kind")
+	}
+
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to retrieve %s %s/%s",
+			kind, namespace, name,
+		)
+	}
+
+	maskPodSpec(&template.Spec)
+
+	return &WorkloadSpec{
+		Replicas:       replicas,
+		Containers:     template.Spec.Containers,
+		InitContainers: template.Spec.InitContainers,
+		Labels:         metadata.Labels,
+		Annotations:    metadata.Annotations,
+	}, nil
+}
+
 // SetResources set resources for a service
 func (kube *Kube) SetResources(
 	kind string,
@@ -785,7 +1224,161 @@ func (kube *Kube) SetResources(
 		}
 	}
 
-	var containerSpecs = make([]map[string]interface{}, len(totalResources.Containers))
+	return false, kube.PatchResources(kind, name, namespace, totalResources)
+}
+
+// PatchResources patches resources of the workload without checks of its
+// update strategy
+func (kube *Kube) PatchResources(
+	kind string,
+	name string,
+	namespace string,
+	totalResources TotalResources,
+) error {
+	b, err := GetResourcesPatch(kind, totalResources)
+	if err != nil {
+		return err
+	}
+
+	req := kube.GetAppsClient().Patch(types.StrategicMergePatchType).
+		Resource(kind + "s").
+		Namespace(namespace).
+		Name(name).
+		Body(bytes.NewBuffer(b))
+
+	res := req.Do()
+
+	_, err = res.Get()
+	return err
+}
+
+// UnsetResources removes resource values from containers of the workload
+func (kube *Kube) UnsetResources(
+	kind string,
+	name string,
+	namespace string,
+	unset []UnsetResource,
+) error {
+	b, err := GetUnsetResourcesPatch(kind, unset)
+	if err != nil {
+		return err
+	}
+
+	_, err = kube.GetAppsClient().Patch(types.StrategicMergePatchType).
+		Resource(kind + "s").
+		Namespace(namespace).
+		Name(name).
+		Body(bytes.NewBuffer(b)).
+		Do().
+		Get()
+	return err
+}
+
+// SetStatefulSetPartition sets partition of rolling updates of the
+// statefulset, pods with ordinals below the partition are not updated
+func (kube *Kube) SetStatefulSetPartition(namespace, name string, partition int32) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"updateStrategy": map[string]interface{}{
+				"type": v1.RollingUpdateStatefulSetStrategyType,
+				"rollingUpdate": map[string]interface{}{
+					"partition": partition,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return karma.Format(err, "unable to encode statefulset patch")
+	}
+
+	_, err = kube.GetAppsClient().Patch(types.StrategicMergePatchType).
+		Resource("statefulsets").
+		Namespace(namespace).
+		Name(name).
+		Body(bytes.NewBuffer(patch)).
+		Do().
+		Get()
+	if err != nil {
+		return karma.
+			Describe("namespace", namespace).
+			Describe("name", name).
+			Describe("partition", partition).
+			Format(err, "unable to patch statefulset partition")
+	}
+
+	return nil
+}
+
+// SetDeploymentPaused pauses or resumes rollouts of the deployment
+func (kube *Kube) SetDeploymentPaused(namespace, name string, paused bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"paused": paused,
+		},
+	})
+	if err != nil {
+		return karma.Format(err, "unable to encode deployment patch")
+	}
+
+	_, err = kube.GetAppsClient().Patch(types.StrategicMergePatchType).
+		Resource("deployments").
+		Namespace(namespace).
+		Name(name).
+		Body(bytes.NewBuffer(patch)).
+		Do().
+		Get()
+	if err != nil {
+		return karma.
+			Describe("namespace", namespace).
+			Describe("name", name).
+			Describe("paused", paused).
+			Format(err, "unable to patch deployment")
+	}
+
+	return nil
+}
+
+// SetCronJobSuspended suspends or resumes schedules of a cron job
+func (kube *Kube) SetCronJobSuspended(namespace, name string, suspended bool) error {
+	if !kube.isCronJobsSupported() {
+		return karma.
+			Describe("feature", FeatureCronJobs).
+			Format(nil, "cron jobs are not supported by the cluster")
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"suspend": suspended,
+		},
+	})
+	if err != nil {
+		return karma.Format(err, "unable to encode cron job patch")
+	}
+
+	_, err = kube.getCronJobsClient().Patch(types.StrategicMergePatchType).
+		Resource("cronjobs").
+		Namespace(namespace).
+		Name(name).
+		Body(bytes.NewBuffer(patch)).
+		Do().
+		Get()
+	if err != nil {
+		return karma.
+			Describe("namespace", namespace).
+			Describe("name", name).
+			Describe("suspended", suspended).
+			Format(err, "unable to patch cron job")
+	}
+
+	return nil
+}
+
+// GetResourcesPatch returns strategic merge patch applying the resources
+func GetResourcesPatch(kind string, totalResources TotalResources) ([]byte, error) {
+	var (
+		containerSpecs     = []map[string]interface{}{}
+		initContainerSpecs = []map[string]interface{}{}
+	)
 	for i := range totalResources.Containers {
 
 		container := totalResources.Containers[i]
@@ -822,7 +1415,7 @@ func (kube *Kube) SetResources(
 		}
 
 		if len(resources) == 0 {
-			return false, fmt.Errorf(
+			return nil, fmt.Errorf(
 				"invalid resources for container: %s",
 				container.Name,
 			)
@@ -832,7 +1425,11 @@ func (kube *Kube) SetResources(
 			"name":      container.Name,
 			"resources": resources,
 		}
-		containerSpecs[i] = spec
+		if container.Init {
+			initContainerSpecs = append(initContainerSpecs, spec)
+		} else {
+			containerSpecs = append(containerSpecs, spec)
+		}
 	}
 
 	body := map[string]interface{}{
@@ -840,12 +1437,18 @@ func (kube *Kube) SetResources(
 		"spec": map[string]interface{}{},
 	}
 
-	if len(containerSpecs) > 0 {
+	if len(containerSpecs) > 0 || len(initContainerSpecs) > 0 {
+		podSpec := map[string]interface{}{}
+		if len(containerSpecs) > 0 {
+			podSpec["containers"] = containerSpecs
+		}
+		if len(initContainerSpecs) > 0 {
+			podSpec["initContainers"] = initContainerSpecs
+		}
+
 		spec := body["spec"].(map[string]interface{})
 		spec["template"] = map[string]interface{}{
-			"spec": map[string]interface{}{
-				"containers": containerSpecs,
-			},
+			"spec": podSpec,
 		}
 	}
 
@@ -855,20 +1458,65 @@ func (kube *Kube) SetResources(
 		spec["replicas"] = totalResources.Replicas
 	}
 
-	b, err := json.Marshal(body)
-	if err != nil {
-		return false, err
+	return json.Marshal(body)
+}
+
+// GetUnsetResourcesPatch returns strategic merge patch removing resource
+// values of containers, removed values are set to null
+func GetUnsetResourcesPatch(kind string, unset []UnsetResource) ([]byte, error) {
+	var (
+		containerSpecs     = []map[string]interface{}{}
+		initContainerSpecs = []map[string]interface{}{}
+		specs              = map[string]map[string]map[string]interface{}{}
+	)
+	for _, item := range unset {
+		key := item.Container
+		if item.Init {
+			key = "init/" + key
+		}
+
+		resources, ok := specs[key]
+		if !ok {
+			resources = map[string]map[string]interface{}{}
+			specs[key] = resources
+
+			spec := map[string]interface{}{
+				"name":      item.Container,
+				"resources": resources,
+			}
+			if item.Init {
+				initContainerSpecs = append(initContainerSpecs, spec)
+			} else {
+				containerSpecs = append(containerSpecs, spec)
+			}
+		}
+
+		field := "requests"
+		if item.Limit {
+			field = "limits"
+		}
+		if _, ok := resources[field]; !ok {
+			resources[field] = map[string]interface{}{}
+		}
+		resources[field][string(item.Resource)] = nil
 	}
-	req := kube.ClientV1Beta2.RESTClient().Patch(types.StrategicMergePatchType).
-		Resource(kind + "s").
-		Namespace(namespace).
-		Name(name).
-		Body(bytes.NewBuffer(b))
 
-	res := req.Do()
+	podSpec := map[string]interface{}{}
+	if len(containerSpecs) > 0 {
+		podSpec["containers"] = containerSpecs
+	}
+	if len(initContainerSpecs) > 0 {
+		podSpec["initContainers"] = initContainerSpecs
+	}
 
-	_, err = res.Get()
-	return false, err
+	return json.Marshal(map[string]interface{}{
+		"kind": kind,
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": podSpec,
+			},
+		},
+	})
 }
 
 func maskPodSpec(podSpec *kv1.PodSpec) {
@@ -0,0 +1,103 @@
+package kuber
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeconfigContext context of a kubeconfig file
+type KubeconfigContext struct {
+	Path string
+	Name string
+}
+
+// ListKubeconfigContexts lists contexts of all kubeconfig files in the
+// directory, hidden files and subdirectories are skipped
+func ListKubeconfigContexts(dir string) ([]KubeconfigContext, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to read kubeconfig directory %s",
+			dir,
+		)
+	}
+
+	contexts := []KubeconfigContext{}
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name())
+
+		config, err := clientcmd.LoadFromFile(path)
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to load kubeconfig %s",
+				path,
+			)
+		}
+
+		names := []string{}
+		for name := range config.Contexts {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			contexts = append(contexts, KubeconfigContext{
+				Path: path,
+				Name: name,
+			})
+		}
+	}
+
+	return contexts, nil
+}
+
+// InitKubeconfigKubernetes creates kubernetes client for the context of a
+// kubeconfig file
+func InitKubeconfigKubernetes(
+	args map[string]interface{},
+	client *client.Client,
+	context KubeconfigContext,
+) (*Kube, error) {
+	ctx := karma.
+		Describe("path", context.Path).
+		Describe("context", context.Name)
+
+	client.Infof(ctx, "initializing kubernetes kubeconfig context")
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: context.Path},
+		&clientcmd.ConfigOverrides{CurrentContext: context.Name},
+	).ClientConfig()
+	if err != nil {
+		return nil, ctx.Format(
+			err,
+			"unable to load kubeconfig context",
+		)
+	}
+
+	err = validateAuth(config)
+	if err != nil {
+		return nil, ctx.Reason(err)
+	}
+
+	client.Debugf(describeAuth(ctx, config), "kubeconfig context is loaded")
+
+	config.Timeout = utils.MustParseDuration(args, "--kube-timeout")
+	setRateLimit(config, args)
+
+	return NewKube(config, client.Logger)
+}
@@ -0,0 +1,42 @@
+package kuber
+
+import (
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+// containerLogsLimitBytes limit of logs of a container to avoid sending huge
+// lines of binary output
+const containerLogsLimitBytes = 64 * 1024
+
+// GetPreviousContainerLogs get last lines of logs of the previous
+// (terminated) instance of the container
+func (kube *Kube) GetPreviousContainerLogs(
+	namespace string,
+	pod string,
+	container string,
+	lines int64,
+) (string, error) {
+	ctx := karma.
+		Describe("namespace", namespace).
+		Describe("pod", pod).
+		Describe("container", container)
+
+	kube.logger.Debugf(ctx, "{kubernetes} retrieving logs of previous container")
+
+	limitBytes := int64(containerLogsLimitBytes)
+	logs, err := kube.core.Pods(namespace).GetLogs(pod, &kv1.PodLogOptions{
+		Container:  container,
+		Previous:   true,
+		TailLines:  &lines,
+		LimitBytes: &limitBytes,
+	}).Do().Raw()
+	if err != nil {
+		return "", ctx.Format(
+			err,
+			"unable to retrieve logs of previous container",
+		)
+	}
+
+	return string(logs), nil
+}
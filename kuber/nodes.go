@@ -4,23 +4,42 @@ import (
 	"strings"
 
 	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
 	kapi "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 type Node struct {
-	ID            uuid.UUID    `json:"id,omitempty"`
-	Name          string       `json:"name"`
-	IP            string       `json:"ip"`
-	KubeletPort   int32        `json:"port"`
-	Provider      string       `json:"provider,omitempty"`
-	Region        string       `json:"region,omitempty"`
-	InstanceType  string       `json:"instance_type,omitempty"`
-	InstanceSize  string       `json:"instance_size,omitempty"`
-	Capacity      NodeCapacity `json:"capacity"`
-	Allocatable   NodeCapacity `json:"allocatable"`
-	Containers    int          `json:"containers,omitempty"`
-	ContainerList []*Container `json:"container_list,omitempty"`
+	ID            uuid.UUID         `json:"id,omitempty"`
+	Name          string            `json:"name"`
+	IP            string            `json:"ip"`
+	Addresses     map[string]string `json:"addresses,omitempty"`
+	KubeletPort   int32             `json:"port"`
+	Provider      string            `json:"provider,omitempty"`
+	OS            string            `json:"os,omitempty"`
+	Region        string            `json:"region,omitempty"`
+	Zone          string            `json:"zone,omitempty"`
+	InstanceType  string            `json:"instance_type,omitempty"`
+	InstanceSize  string            `json:"instance_size,omitempty"`
+	Pool          string            `json:"pool,omitempty"`
+	Lifecycle     string            `json:"lifecycle,omitempty"`
+	OnDemandPrice float64           `json:"on_demand_price,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Taints        []NodeTaint       `json:"taints,omitempty"`
+	Capacity      NodeCapacity      `json:"capacity"`
+	Allocatable   NodeCapacity      `json:"allocatable"`
+	Conditions    NodeConditions    `json:"conditions"`
+	BootID        string            `json:"boot_id,omitempty"`
+	Containers    int               `json:"containers,omitempty"`
+	ContainerList []*Container      `json:"container_list,omitempty"`
+}
+
+// NodeTaint taint of a node, pods without matching tolerations are not
+// scheduled or not executed on the node depending on the effect
+type NodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
 }
 
 // Container user type.
@@ -72,6 +91,14 @@ type NodeCapacity struct {
 	Pods             int `json:"pods"`
 }
 
+// NodeConditions health conditions of a node reported by its kubelet
+type NodeConditions struct {
+	MemoryPressure bool `json:"memory_pressure"`
+	DiskPressure   bool `json:"disk_pressure"`
+	PIDPressure    bool `json:"pid_pressure"`
+	NotReady       bool `json:"not_ready"`
+}
+
 func GetContainersByNode(pods []kapi.Pod) map[string]int {
 	containers := map[string]int{}
 	for _, pod := range pods {
@@ -189,13 +216,18 @@ func GetNodes(nodes []kapi.Node) []Node {
 		labels := node.Labels
 
 		var address string
+		addresses := map[string]string{}
 		for _, addr := range node.Status.Addresses {
 			if addr.Type == kapi.NodeInternalIP {
 				address = addr.Address
 			}
+
+			if _, ok := addresses[string(addr.Type)]; !ok {
+				addresses[string(addr.Type)] = addr.Address
+			}
 		}
 
-		instanceType := labels["beta.kubernetes.io/instance-type"]
+		instanceType := getNodeLabel(labels, instanceTypeLabels)
 		instanceSize := ""
 
 		_, gcloud := labels["cloud.google.com/gke-nodepool"]
@@ -216,19 +248,153 @@ func GetNodes(nodes []kapi.Node) []Node {
 		result = append(result, Node{
 			Name:         node.ObjectMeta.Name,
 			IP:           address,
+			Addresses:    addresses,
 			KubeletPort:  node.Status.DaemonEndpoints.KubeletEndpoint.Port,
-			Region:       labels["failure-domain.beta.kubernetes.io/region"],
+			Region:       getNodeLabel(labels, regionLabels),
+			Zone:         getNodeLabel(labels, zoneLabels),
 			InstanceType: instanceType,
 			InstanceSize: instanceSize,
+			Pool:         getNodePool(labels),
+			Labels:       labels,
+			Taints:       getNodeTaints(node.Spec.Taints),
 			Provider:     provider,
+			OS:           node.Status.NodeInfo.OperatingSystem,
 			Capacity:     GetNodeCapacity(node.Status.Capacity),
 			Allocatable:  GetNodeCapacity(node.Status.Allocatable),
+			Conditions:   GetNodeConditions(node.Status.Conditions),
+			BootID:       node.Status.NodeInfo.BootID,
 		})
 	}
 
 	return result
 }
 
+var (
+	// regionLabels labels of the region of a node, stable labels first
+	regionLabels = []string{
+		"topology.kubernetes.io/region",
+		"failure-domain.beta.kubernetes.io/region",
+	}
+	// zoneLabels labels of the availability zone of a node
+	zoneLabels = []string{
+		"topology.kubernetes.io/zone",
+		"failure-domain.beta.kubernetes.io/zone",
+	}
+	// instanceTypeLabels labels of the instance type of a node
+	instanceTypeLabels = []string{
+		"node.kubernetes.io/instance-type",
+		"beta.kubernetes.io/instance-type",
+	}
+)
+
+// GetInstanceType returns instance type of the node by its labels
+func GetInstanceType(labels map[string]string) string {
+	return getNodeLabel(labels, instanceTypeLabels)
+}
+
+// getNodeLabel returns value of the first set label
+func getNodeLabel(labels map[string]string, names []string) string {
+	for _, name := range names {
+		if value := labels[name]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func getNodeTaints(taints []kapi.Taint) []NodeTaint {
+	if len(taints) == 0 {
+		return nil
+	}
+
+	result := make([]NodeTaint, 0, len(taints))
+	for _, taint := range taints {
+		result = append(result, NodeTaint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: string(taint.Effect),
+		})
+	}
+
+	return result
+}
+
+// nodeAddressTypes known types of node addresses
+var nodeAddressTypes = []kapi.NodeAddressType{
+	kapi.NodeInternalIP,
+	kapi.NodeExternalIP,
+	kapi.NodeHostName,
+	kapi.NodeInternalDNS,
+	kapi.NodeExternalDNS,
+}
+
+// ParseNodeAddressTypes parses comma separated types of node addresses in
+// order of priority, e.g. InternalIP,Hostname,ExternalIP
+func ParseNodeAddressTypes(value string) ([]string, error) {
+	types := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		known := false
+		for _, addressType := range nodeAddressTypes {
+			if name == string(addressType) {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return nil, karma.Format(nil, "unknown node address type: %s", name)
+		}
+
+		types = append(types, name)
+	}
+
+	if len(types) == 0 {
+		return nil, karma.Format(nil, "no node address types specified")
+	}
+
+	return types, nil
+}
+
+// GetNodeAddress returns address of the node of the first type it has, the
+// internal ip is returned if the node has none of the types
+func GetNodeAddress(node Node, types []string) string {
+	for _, addressType := range types {
+		if address := node.Addresses[addressType]; address != "" {
+			return address
+		}
+	}
+
+	return node.IP
+}
+
+// nodePoolLabels labels of node pools and instance groups of providers
+var nodePoolLabels = []string{
+	"cloud.google.com/gke-nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"alpha.eksctl.io/nodegroup-name",
+	"kubernetes.azure.com/agentpool",
+	"agentpool",
+	"kops.k8s.io/instancegroup",
+}
+
+// getNodePool returns node pool of the node, nodes of unknown pools are
+// grouped by instance type
+func getNodePool(labels map[string]string) string {
+	for _, label := range nodePoolLabels {
+		if pool := labels[label]; pool != "" {
+			return pool
+		}
+	}
+
+	return getNodeLabel(labels, instanceTypeLabels)
+}
+
 func GetNodeCapacity(resources kapi.ResourceList) NodeCapacity {
 	capacity := NodeCapacity{
 		CPU:              int(resources.Cpu().MilliValue()),
@@ -239,3 +405,28 @@ func GetNodeCapacity(resources kapi.ResourceList) NodeCapacity {
 
 	return capacity
 }
+
+// GetNodeConditions converts node status conditions, a node without a ready
+// condition is considered not ready
+func GetNodeConditions(conditions []kapi.NodeCondition) NodeConditions {
+	result := NodeConditions{
+		NotReady: true,
+	}
+
+	for _, condition := range conditions {
+		status := condition.Status == kapi.ConditionTrue
+
+		switch condition.Type {
+		case kapi.NodeMemoryPressure:
+			result.MemoryPressure = status
+		case kapi.NodeDiskPressure:
+			result.DiskPressure = status
+		case kapi.NodePIDPressure:
+			result.PIDPressure = status
+		case kapi.NodeReady:
+			result.NotReady = !status
+		}
+	}
+
+	return result
+}
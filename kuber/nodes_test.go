@@ -0,0 +1,82 @@
+package kuber
+
+import (
+	"reflect"
+	"testing"
+
+	kapi "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetNodesTopology(t *testing.T) {
+	nodes := GetNodes([]kapi.Node{
+		{
+			ObjectMeta: kmeta.ObjectMeta{
+				Name: "node-1",
+				Labels: map[string]string{
+					"topology.kubernetes.io/region":    "us-east-1",
+					"topology.kubernetes.io/zone":      "us-east-1a",
+					"node.kubernetes.io/instance-type": "m5.large",
+					"eks.amazonaws.com/nodegroup":      "workers",
+				},
+			},
+			Spec: kapi.NodeSpec{
+				Taints: []kapi.Taint{
+					{Key: "dedicated", Value: "batch", Effect: kapi.TaintEffectNoSchedule},
+				},
+			},
+		},
+	})
+
+	node := nodes[0]
+	if node.Region != "us-east-1" || node.Zone != "us-east-1a" {
+		t.Errorf("unexpected topology: %q %q", node.Region, node.Zone)
+	}
+
+	if node.InstanceType != "m5" || node.InstanceSize != "large" {
+		t.Errorf("unexpected instance: %q %q", node.InstanceType, node.InstanceSize)
+	}
+
+	if node.Pool != "workers" {
+		t.Errorf("unexpected pool: %q", node.Pool)
+	}
+
+	expected := []NodeTaint{{Key: "dedicated", Value: "batch", Effect: "NoSchedule"}}
+	if !reflect.DeepEqual(node.Taints, expected) {
+		t.Errorf("expected taints %+v, got %+v", expected, node.Taints)
+	}
+}
+
+func TestGetNodeAddress(t *testing.T) {
+	nodes := GetNodes([]kapi.Node{
+		{
+			ObjectMeta: kmeta.ObjectMeta{Name: "node-1"},
+			Status: kapi.NodeStatus{
+				Addresses: []kapi.NodeAddress{
+					{Type: kapi.NodeHostName, Address: "node-1"},
+					{Type: kapi.NodeExternalIP, Address: "203.0.113.10"},
+					{Type: kapi.NodeExternalIP, Address: "203.0.113.11"},
+				},
+			},
+		},
+	})
+
+	types, err := ParseNodeAddressTypes("InternalIP, ExternalIP,Hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address := GetNodeAddress(nodes[0], types); address != "203.0.113.10" {
+		t.Errorf("unexpected address: %q", address)
+	}
+
+	if address := GetNodeAddress(nodes[0], []string{"InternalDNS"}); address != "" {
+		t.Errorf("unexpected address of missing type: %q", address)
+	}
+
+	for _, value := range []string{"", "InternalIP,PublicIP"} {
+		if _, err := ParseNodeAddressTypes(value); err == nil {
+			t.Errorf("expected error of %q", value)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package kuber
+
+import (
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxOwnersDepth guards resolution against cycles of owner references
+const maxOwnersDepth = 8
+
+// OwnersIndex owner references of intermediate objects by their uids, e.g.
+// replica sets owned by deployments
+type OwnersIndex map[types.UID][]kmeta.OwnerReference
+
+func (index OwnersIndex) add(object kmeta.Object) {
+	references := object.GetOwnerReferences()
+	if len(references) > 0 {
+		index[object.GetUID()] = references
+	}
+}
+
+// ResolvePod returns uid of the top-level controller of the pod following
+// controller references through the index, e.g. Pod -> ReplicaSet ->
+// Deployment, uid of the pod is returned if it has no controller
+func (index OwnersIndex) ResolvePod(pod kv1.Pod) types.UID {
+	uid := pod.UID
+	references := pod.OwnerReferences
+
+	for depth := 0; depth < maxOwnersDepth; depth++ {
+		controller := getController(references)
+		if controller == nil {
+			break
+		}
+
+		uid = controller.UID
+
+		references = index[uid]
+	}
+
+	return uid
+}
+
+// getController returns the managing controller reference, controllers of
+// objects created by old clusters may be not marked, the first reference is
+// used then
+func getController(references []kmeta.OwnerReference) *kmeta.OwnerReference {
+	if len(references) == 0 {
+		return nil
+	}
+
+	for i := range references {
+		if references[i].Controller != nil && *references[i].Controller {
+			return &references[i]
+		}
+	}
+
+	return &references[0]
+}
@@ -0,0 +1,93 @@
+package kuber
+
+import (
+	"testing"
+
+	kapps "k8s.io/api/apps/v1"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOwnersIndex_ResolvePod(t *testing.T) {
+	controller := true
+
+	replicaSet := &kapps.ReplicaSet{
+		ObjectMeta: kmeta.ObjectMeta{
+			UID: "replicaset",
+			OwnerReferences: []kmeta.OwnerReference{
+				{Kind: "Deployment", UID: "deployment", Controller: &controller},
+			},
+		},
+	}
+
+	owners := OwnersIndex{}
+	owners.add(replicaSet)
+
+	testcases := []struct {
+		name       string
+		references []kmeta.OwnerReference
+		expected   types.UID
+	}{
+		{
+			"deployment",
+			[]kmeta.OwnerReference{
+				{Kind: "ReplicaSet", UID: "replicaset", Controller: &controller},
+			},
+			"deployment",
+		},
+		{
+			"statefulset",
+			[]kmeta.OwnerReference{
+				{Kind: "StatefulSet", UID: "statefulset", Controller: &controller},
+			},
+			"statefulset",
+		},
+		{
+			"controller among owners",
+			[]kmeta.OwnerReference{
+				{Kind: "ConfigMap", UID: "configmap"},
+				{Kind: "ReplicaSet", UID: "replicaset", Controller: &controller},
+			},
+			"deployment",
+		},
+		{
+			"orphan",
+			nil,
+			"pod",
+		},
+	}
+
+	for _, testcase := range testcases {
+		pod := kv1.Pod{
+			ObjectMeta: kmeta.ObjectMeta{
+				UID:             "pod",
+				OwnerReferences: testcase.references,
+			},
+		}
+
+		uid := owners.ResolvePod(pod)
+		if uid != testcase.expected {
+			t.Errorf("%s: expected %q, got %q", testcase.name, testcase.expected, uid)
+		}
+	}
+}
+
+func TestOwnersIndex_ResolvePodCycle(t *testing.T) {
+	owners := OwnersIndex{
+		"a": {{UID: "b"}},
+		"b": {{UID: "a"}},
+	}
+
+	pod := kv1.Pod{
+		ObjectMeta: kmeta.ObjectMeta{
+			UID:             "pod",
+			OwnerReferences: []kmeta.OwnerReference{{UID: "a"}},
+		},
+	}
+
+	uid := owners.ResolvePod(pod)
+	if uid != "a" && uid != "b" {
+		t.Fatalf("unexpected uid %q", uid)
+	}
+}
@@ -0,0 +1,74 @@
+package kuber
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/reconquest/karma-go"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const podDisruptionBudgetsPath = "/apis/%s/namespaces/%s/poddisruptionbudgets"
+
+// PodDisruptionBudget minimal representation of PodDisruptionBudget object
+type PodDisruptionBudget struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+
+	Spec struct {
+		Selector *kmeta.LabelSelector `json:"selector"`
+	} `json:"spec"`
+
+	Status struct {
+		DisruptionsAllowed int32 `json:"disruptionsAllowed"`
+		CurrentHealthy     int32 `json:"currentHealthy"`
+		DesiredHealthy     int32 `json:"desiredHealthy"`
+		ExpectedPods       int32 `json:"expectedPods"`
+	} `json:"status"`
+}
+
+// GetPodDisruptionBudgets get pod disruption budgets of a namespace by the
+// policy group version served by the cluster, returns nil list without an
+// error if the policy api isn't available
+func (kube *Kube) GetPodDisruptionBudgets(
+	namespace string,
+) ([]PodDisruptionBudget, error) {
+	kube.logger.Debugf(
+		karma.Describe("namespace", namespace),
+		"{kubernetes} retrieving list of pod disruption budgets",
+	)
+
+	path := fmt.Sprintf(podDisruptionBudgetsPath, kube.policyVersion.get(), namespace)
+
+	body, err := kube.core.RESTClient().
+		Get().
+		AbsPath(path).
+		DoRaw()
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to retrieve pod disruption budgets of namespace %s",
+			namespace,
+		)
+	}
+
+	var list struct {
+		Items []PodDisruptionBudget `json:"items"`
+	}
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to unmarshal pod disruption budgets",
+		)
+	}
+
+	return list.Items, nil
+}
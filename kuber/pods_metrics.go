@@ -0,0 +1,65 @@
+package kuber
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const podsMetricsPath = "/apis/metrics.k8s.io/v1beta1/pods"
+
+// PodMetrics minimal representation of metrics.k8s.io PodMetrics object
+type PodMetrics struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+
+	Timestamp time.Time `json:"timestamp"`
+
+	Containers []struct {
+		Name  string           `json:"name"`
+		Usage kv1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+// GetPodsMetrics get current usage of pods in all namespaces from the
+// metrics api, returns nil list without an error if metrics-server isn't
+// installed in the cluster
+func (kube *Kube) GetPodsMetrics() ([]PodMetrics, error) {
+	if !kube.Capabilities.IsSupported(FeatureMetricsAPI) {
+		return nil, nil
+	}
+
+	kube.logger.Debugf(nil, "{kubernetes} retrieving list of pods metrics")
+	body, err := kube.core.RESTClient().
+		Get().
+		AbsPath(podsMetricsPath).
+		DoRaw()
+	if err != nil {
+		if kerrors.IsNotFound(err) || kerrors.IsServiceUnavailable(err) {
+			return nil, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to retrieve pods metrics from all namespaces",
+		)
+	}
+
+	var list struct {
+		Items []PodMetrics `json:"items"`
+	}
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to unmarshal pods metrics",
+		)
+	}
+
+	return list.Items, nil
+}
@@ -0,0 +1,209 @@
+package kuber
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/utils"
+	krest "k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Priority priority tier of requests to the api-server, requests of higher
+// tiers are sent first while the rate limit is exceeded
+type Priority int
+
+const (
+	// PriorityHigh lists and watches of the scanner, events and metrics
+	PriorityHigh Priority = iota
+	// PriorityNormal patches of the executor and scalars
+	PriorityNormal
+	// PriorityLow optional snapshots, e.g. network policies and ephemeral
+	// containers, which are skipped anyway while the scanner is degraded
+	PriorityLow
+
+	prioritiesCount
+)
+
+type priorityKey struct{}
+
+// rateLimiter token bucket shared by all tiers, a request waits while
+// requests of higher tiers wait, so low tiers yield to high tiers once the
+// bucket is exhausted
+type rateLimiter struct {
+	qps   float64
+	burst float64
+
+	mutex     sync.Mutex
+	tokens    float64
+	updatedAt time.Time
+	waiting   [prioritiesCount]int
+}
+
+func newRateLimiter(qps float32, burst int) *rateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		qps:       float64(qps),
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		updatedAt: time.Now(),
+	}
+}
+
+// take takes a token if it's available and no requests of higher tiers
+// wait, otherwise it returns the delay until the next token
+func (limiter *rateLimiter) take(priority Priority, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.tokens += now.Sub(limiter.updatedAt).Seconds() * limiter.qps
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.updatedAt = now
+
+	blocked := false
+	for tier := PriorityHigh; tier < priority; tier++ {
+		if limiter.waiting[tier] > 0 {
+			blocked = true
+			break
+		}
+	}
+
+	if !blocked && limiter.tokens >= 1 {
+		limiter.tokens--
+		return true, 0
+	}
+
+	delay := time.Duration((1 - limiter.tokens) / limiter.qps * float64(time.Second))
+	if delay < 10*time.Millisecond {
+		delay = 10 * time.Millisecond
+	}
+
+	return false, delay
+}
+
+func (limiter *rateLimiter) setWaiting(priority Priority, delta int) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.waiting[priority] += delta
+}
+
+// wait blocks until the request of the priority may be sent or the context
+// is done
+func (limiter *rateLimiter) wait(ctx context.Context, priority Priority) error {
+	ok, delay := limiter.take(priority, time.Now())
+	if ok {
+		return nil
+	}
+
+	limiter.setWaiting(priority, 1)
+	defer limiter.setWaiting(priority, -1)
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		ok, delay = limiter.take(priority, time.Now())
+		if ok {
+			return nil
+		}
+	}
+}
+
+// rateLimitRoundTripper delays requests exceeding the rate limit, requests
+// without a priority are of the high tier
+type rateLimitRoundTripper struct {
+	next    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (roundTripper *rateLimitRoundTripper) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	priority, _ := request.Context().Value(priorityKey{}).(Priority)
+
+	err := roundTripper.limiter.wait(request.Context(), priority)
+	if err != nil {
+		return nil, err
+	}
+
+	return roundTripper.next.RoundTrip(request)
+}
+
+// priorityRoundTripper sets the priority of requests
+type priorityRoundTripper struct {
+	next     http.RoundTripper
+	priority Priority
+}
+
+func (roundTripper *priorityRoundTripper) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	ctx := context.WithValue(request.Context(), priorityKey{}, roundTripper.priority)
+
+	return roundTripper.next.RoundTrip(request.WithContext(ctx))
+}
+
+// WithPriority returns a copy of kube which requests are of the priority
+// tier, throttling, usage and the rate limiter are shared with kube
+func (kube *Kube) WithPriority(priority Priority) (*Kube, error) {
+	config := *kube.config
+
+	// NOTE: the priority is set before the request reaches the rate limiter
+	// of kube
+	wrap := config.WrapTransport
+	config.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		return &priorityRoundTripper{
+			next:     wrap(next),
+			priority: priority,
+		}
+	}
+
+	priorityKube, err := newKubeClients(&config, kube.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	priorityKube.Throttling = kube.Throttling
+	priorityKube.Usage = kube.Usage
+	priorityKube.ShareCapabilities(kube)
+	priorityKube.wrapTransport = kube.wrapTransport
+
+	return priorityKube, nil
+}
+
+// setRateLimit sets the client-side rate limit of requests of the config
+func setRateLimit(config *krest.Config, args map[string]interface{}) {
+	config.QPS = float32(utils.MustParseInt(args, "--kube-qps"))
+	config.Burst = utils.MustParseInt(args, "--kube-burst")
+}
+
+// useRateLimit replaces per client rate limiters of client-go by a rate
+// limiter shared by all clients of the config, so requests are prioritized
+// across clients, nil is returned if the rate limit is disabled
+func useRateLimit(config *krest.Config) *rateLimiter {
+	limiter := newRateLimiter(config.QPS, config.Burst)
+	if limiter == nil {
+		return nil
+	}
+
+	config.RateLimiter = flowcontrol.NewFakeAlwaysRateLimiter()
+
+	return limiter
+}
@@ -0,0 +1,47 @@
+package kuber
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPriorities(t *testing.T) {
+	if newRateLimiter(0, 10) != nil {
+		t.Errorf("rate limiter without qps is created")
+	}
+
+	limiter := newRateLimiter(10, 2)
+	now := limiter.updatedAt
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.take(PriorityLow, now); !ok {
+			t.Fatalf("request %d within burst is delayed", i)
+		}
+	}
+
+	ok, delay := limiter.take(PriorityHigh, now)
+	if ok {
+		t.Fatalf("request above burst is not delayed")
+	}
+
+	if delay != 100*time.Millisecond {
+		t.Errorf("unexpected delay: %s", delay)
+	}
+
+	limiter.setWaiting(PriorityHigh, 1)
+	now = now.Add(200 * time.Millisecond)
+
+	if ok, _ := limiter.take(PriorityLow, now); ok {
+		t.Errorf("low priority request is sent while high priority waits")
+	}
+
+	if ok, _ := limiter.take(PriorityHigh, now); !ok {
+		t.Errorf("high priority request is delayed")
+	}
+
+	limiter.setWaiting(PriorityHigh, -1)
+
+	if ok, _ := limiter.take(PriorityLow, now); !ok {
+		t.Errorf("low priority request is delayed once high priority is sent")
+	}
+}
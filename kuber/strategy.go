@@ -0,0 +1,52 @@
+package kuber
+
+import (
+	"github.com/MagalixCorp/magalix-agent/proto"
+	kapps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func getDeploymentStrategy(strategy kapps.DeploymentStrategy) *proto.RolloutStrategy {
+	result := &proto.RolloutStrategy{
+		Type: string(strategy.Type),
+	}
+
+	if strategy.RollingUpdate != nil {
+		result.MaxSurge = getIntOrStringValue(strategy.RollingUpdate.MaxSurge)
+		result.MaxUnavailable = getIntOrStringValue(strategy.RollingUpdate.MaxUnavailable)
+	}
+
+	return result
+}
+
+func getStatefulSetStrategy(strategy kapps.StatefulSetUpdateStrategy) *proto.RolloutStrategy {
+	result := &proto.RolloutStrategy{
+		Type: string(strategy.Type),
+	}
+
+	if strategy.RollingUpdate != nil {
+		result.Partition = strategy.RollingUpdate.Partition
+	}
+
+	return result
+}
+
+func getDaemonSetStrategy(strategy kapps.DaemonSetUpdateStrategy) *proto.RolloutStrategy {
+	result := &proto.RolloutStrategy{
+		Type: string(strategy.Type),
+	}
+
+	if strategy.RollingUpdate != nil {
+		result.MaxUnavailable = getIntOrStringValue(strategy.RollingUpdate.MaxUnavailable)
+	}
+
+	return result
+}
+
+func getIntOrStringValue(value *intstr.IntOrString) string {
+	if value == nil {
+		return ""
+	}
+
+	return value.String()
+}
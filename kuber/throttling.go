@@ -0,0 +1,119 @@
+package kuber
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxThrottlingFactor = 8
+
+	// throttlingCooldown period without throttled responses after which the
+	// throttling factor is halved
+	throttlingCooldown = 5 * time.Minute
+)
+
+// Throttling tracks throttled responses of the api-server, periodic list
+// calls should be done less frequently while the api-server sheds load
+type Throttling struct {
+	mutex sync.Mutex
+
+	factor      int
+	responses   int
+	throttledAt time.Time
+	raisedAt    time.Time
+	since       time.Time
+}
+
+// ThrottlingState current throttling state
+type ThrottlingState struct {
+	Throttled bool
+	Factor    int
+	Responses int
+	Since     time.Time
+}
+
+func newThrottling() *Throttling {
+	return &Throttling{factor: 1}
+}
+
+// observe doubles the throttling factor on a throttled response
+func (throttling *Throttling) observe(statusCode int, now time.Time) {
+	if statusCode != http.StatusTooManyRequests {
+		return
+	}
+
+	throttling.mutex.Lock()
+	defer throttling.mutex.Unlock()
+
+	throttling.decay(now)
+
+	if throttling.factor == 1 {
+		throttling.since = now
+	}
+
+	throttling.responses++
+	throttling.throttledAt = now
+
+	// NOTE: the factor is raised at most once per second as concurrent
+	// requests usually get throttled together
+	if throttling.factor < maxThrottlingFactor &&
+		now.Sub(throttling.raisedAt) >= time.Second {
+		throttling.factor *= 2
+		throttling.raisedAt = now
+	}
+}
+
+// decay halves the factor for every cooldown period passed since the last
+// throttled response
+func (throttling *Throttling) decay(now time.Time) {
+	if throttling.factor == 1 {
+		return
+	}
+
+	elapsed := now.Sub(throttling.throttledAt)
+	for elapsed >= throttlingCooldown && throttling.factor > 1 {
+		throttling.factor /= 2
+		throttling.throttledAt = throttling.throttledAt.Add(throttlingCooldown)
+		elapsed -= throttlingCooldown
+	}
+
+	if throttling.factor == 1 {
+		throttling.responses = 0
+		throttling.since = time.Time{}
+	}
+}
+
+// GetState returns current throttling state, the factor is the multiplier
+// of periodic list calls intervals
+func (throttling *Throttling) GetState() ThrottlingState {
+	throttling.mutex.Lock()
+	defer throttling.mutex.Unlock()
+
+	throttling.decay(time.Now())
+
+	return ThrottlingState{
+		Throttled: throttling.factor > 1,
+		Factor:    throttling.factor,
+		Responses: throttling.responses,
+		Since:     throttling.since,
+	}
+}
+
+// throttlingRoundTripper observes responses of the api-server
+type throttlingRoundTripper struct {
+	next       http.RoundTripper
+	throttling *Throttling
+}
+
+func (roundTripper *throttlingRoundTripper) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	response, err := roundTripper.next.RoundTrip(request)
+	if err == nil {
+		roundTripper.throttling.observe(response.StatusCode, time.Now())
+	}
+
+	return response, err
+}
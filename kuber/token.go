@@ -0,0 +1,110 @@
+package kuber
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	krest "k8s.io/client-go/rest"
+)
+
+// serviceAccountTokenPath path of the token of the service account mounted
+// into pods, bound tokens of projected volumes are rotated by kubelet
+const serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// tokenFile bearer token read from a file, the file is read again once it's
+// modified, so rotated tokens are used before the previous ones expire
+type tokenFile struct {
+	path string
+
+	mutex   sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+func (file *tokenFile) get() (string, error) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	stat, err := os.Stat(file.path)
+	if err != nil {
+		if file.token != "" {
+			// NOTE: the file may be missing for a moment while kubelet
+			// replaces it, the previous token is still valid
+			return file.token, nil
+		}
+
+		return "", karma.Format(err, "unable to stat token file %s", file.path)
+	}
+
+	if file.token != "" && stat.ModTime().Equal(file.modTime) {
+		return file.token, nil
+	}
+
+	data, err := ioutil.ReadFile(file.path)
+	if err != nil {
+		if file.token != "" {
+			return file.token, nil
+		}
+
+		return "", karma.Format(err, "unable to read token file %s", file.path)
+	}
+
+	file.token = strings.TrimSpace(string(data))
+	file.modTime = stat.ModTime()
+
+	return file.token, nil
+}
+
+// tokenFileRoundTripper authenticates requests by the current token of the
+// file, the request is copied as round trippers must not modify requests
+type tokenFileRoundTripper struct {
+	file *tokenFile
+	next http.RoundTripper
+}
+
+func (roundTripper *tokenFileRoundTripper) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	token, err := roundTripper.file.get()
+	if err != nil {
+		return nil, err
+	}
+
+	clone := new(http.Request)
+	*clone = *request
+
+	clone.Header = make(http.Header, len(request.Header)+1)
+	for key, values := range request.Header {
+		clone.Header[key] = append([]string(nil), values...)
+	}
+
+	clone.Header.Set("Authorization", "Bearer "+token)
+
+	return roundTripper.next.RoundTrip(clone)
+}
+
+// useTokenFile makes requests of the config, including requests of direct
+// http clients, authenticated by the token of the file read on every request
+// instead of the token read once
+func useTokenFile(config *krest.Config, path string) {
+	file := &tokenFile{path: path}
+
+	config.BearerToken = ""
+
+	wrap := config.WrapTransport
+	config.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			next = wrap(next)
+		}
+
+		return &tokenFileRoundTripper{
+			file: file,
+			next: next,
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package kuber
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenFileRoundTripper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+
+	write := func(token string, modTime time.Time) {
+		err := ioutil.WriteFile(path, []byte(token+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			authorization = request.Header.Get("Authorization")
+		},
+	))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &tokenFileRoundTripper{
+			file: &tokenFile{path: path},
+			next: http.DefaultTransport,
+		},
+	}
+
+	now := time.Now()
+	for _, token := range []string{"first", "second"} {
+		write(token, now)
+		now = now.Add(time.Minute)
+
+		request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		if authorization != "Bearer "+token {
+			t.Errorf("expected token %q, got authorization %q", token, authorization)
+		}
+
+		if request.Header.Get("Authorization") != "" {
+			t.Errorf("original request is modified")
+		}
+	}
+
+	os.Remove(path)
+
+	token, err := (&tokenFile{path: path}).get()
+	if err == nil {
+		t.Errorf("expected error of missing file, got token %q", token)
+	}
+}
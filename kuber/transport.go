@@ -0,0 +1,63 @@
+package kuber
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/reconquest/karma-go"
+	krest "k8s.io/client-go/rest"
+)
+
+// TransportOptions tuning of connections of a direct http client
+type TransportOptions struct {
+	// MaxIdleConnsPerHost idle connections kept per host for reuse
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout idle connections are closed after the timeout
+	IdleConnTimeout time.Duration
+	// KeepAlive interval of tcp keep-alive probes
+	KeepAlive time.Duration
+}
+
+// NewDirectHTTPClient creates http client which requests are authenticated
+// like requests of kube, e.g. for kubelets of nodes. The client has its own
+// pool of connections, its requests aren't rate limited, counted or tracked
+// for throttling since they aren't sent to the api-server. Tokens of token
+// files are re-read like for requests to the api-server.
+func (kube *Kube) NewDirectHTTPClient(options TransportOptions) (*http.Client, error) {
+	// NOTE: the transport wrapper of kube config limits and counts requests
+	// to the api-server, only the wrapper of the original config is kept
+	config := *kube.config
+	config.WrapTransport = nil
+
+	tlsConfig, err := krest.TLSConfigFor(&config)
+	if err != nil {
+		return nil, karma.Format(err, "unable to get tls config of kubernetes")
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: options.KeepAlive,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+		IdleConnTimeout:     options.IdleConnTimeout,
+	}
+
+	roundTripper, err := krest.HTTPWrappersForConfig(&config, transport)
+	if err != nil {
+		return nil, karma.Format(err, "unable to get authentication of kubernetes")
+	}
+
+	if kube.wrapTransport != nil {
+		roundTripper = kube.wrapTransport(roundTripper)
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   config.Timeout,
+	}, nil
+}
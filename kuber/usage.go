@@ -0,0 +1,58 @@
+package kuber
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Usage counts requests sent to the api-server
+type Usage struct {
+	requests int64
+}
+
+// Requests returns count of requests sent since the start
+func (usage *Usage) Requests() int64 {
+	return atomic.LoadInt64(&usage.requests)
+}
+
+// usageRoundTripper counts requests sent to the api-server
+type usageRoundTripper struct {
+	next  http.RoundTripper
+	usage *Usage
+}
+
+func (roundTripper *usageRoundTripper) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	atomic.AddInt64(&roundTripper.usage.requests, 1)
+
+	return roundTripper.next.RoundTrip(request)
+}
+
+// WithUsage returns a copy of kube which requests are counted by a usage of
+// its own besides the usage of kube, e.g. to count requests of a single
+// component, throttling and the rate limiter are shared with kube
+func (kube *Kube) WithUsage() (*Kube, error) {
+	config := *kube.config
+	usage := &Usage{}
+
+	wrap := config.WrapTransport
+	config.WrapTransport = func(next http.RoundTripper) http.RoundTripper {
+		return &usageRoundTripper{
+			next:  wrap(next),
+			usage: usage,
+		}
+	}
+
+	usageKube, err := newKubeClients(&config, kube.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	usageKube.Throttling = kube.Throttling
+	usageKube.Usage = usage
+	usageKube.ShareCapabilities(kube)
+	usageKube.wrapTransport = kube.wrapTransport
+
+	return usageKube, nil
+}
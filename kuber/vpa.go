@@ -0,0 +1,81 @@
+package kuber
+
+import (
+	"encoding/json"
+
+	"github.com/reconquest/karma-go"
+	kv1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const verticalPodAutoscalersPath = "/apis/autoscaling.k8s.io/v1/verticalpodautoscalers"
+
+// VerticalPodAutoscaler minimal representation of autoscaling.k8s.io
+// VerticalPodAutoscaler object, only fields needed by the agent are decoded
+type VerticalPodAutoscaler struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+
+	Spec struct {
+		TargetRef struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"targetRef"`
+	} `json:"spec"`
+
+	Status struct {
+		Recommendation *struct {
+			ContainerRecommendations []VerticalPodAutoscalerRecommendation `json:"containerRecommendations"`
+		} `json:"recommendation"`
+	} `json:"status"`
+}
+
+// VerticalPodAutoscalerRecommendation recommendation of a single container
+type VerticalPodAutoscalerRecommendation struct {
+	ContainerName  string           `json:"containerName"`
+	Target         kv1.ResourceList `json:"target"`
+	LowerBound     kv1.ResourceList `json:"lowerBound"`
+	UpperBound     kv1.ResourceList `json:"upperBound"`
+	UncappedTarget kv1.ResourceList `json:"uncappedTarget"`
+}
+
+// GetVerticalPodAutoscalers get vertical pod autoscalers from all namespaces
+// returns nil list without an error if VPA isn't installed in the cluster
+func (kube *Kube) GetVerticalPodAutoscalers() (
+	[]VerticalPodAutoscaler, error,
+) {
+	if !kube.Capabilities.IsSupported(FeatureVPA) {
+		return nil, nil
+	}
+
+	kube.logger.Debugf(nil, "{kubernetes} retrieving list of vertical pod autoscalers")
+	body, err := kube.core.RESTClient().
+		Get().
+		AbsPath(verticalPodAutoscalersPath).
+		DoRaw()
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to retrieve vertical pod autoscalers from all namespaces",
+		)
+	}
+
+	var list struct {
+		Items []VerticalPodAutoscaler `json:"items"`
+	}
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to unmarshal vertical pod autoscalers",
+		)
+	}
+
+	return list.Items, nil
+}
@@ -1,22 +1,25 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/MagalixCorp/magalix-agent/client"
-	"github.com/MagalixCorp/magalix-agent/events"
-	"github.com/MagalixCorp/magalix-agent/executor"
+	"github.com/MagalixCorp/magalix-agent/cloud"
+	"github.com/MagalixCorp/magalix-agent/freeze"
 	"github.com/MagalixCorp/magalix-agent/kuber"
-	"github.com/MagalixCorp/magalix-agent/metrics"
+	"github.com/MagalixCorp/magalix-agent/policy"
 	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/replay"
 	"github.com/MagalixCorp/magalix-agent/scalar"
 	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/shard"
+	"github.com/MagalixCorp/magalix-agent/status"
+	"github.com/MagalixCorp/magalix-agent/tracing"
 	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixCorp/magalix-agent/webhook"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/MagalixTechnologies/uuid-go"
 	"github.com/docopt/docopt-go"
@@ -27,7 +30,11 @@ var usage = `agent - magalix services agent.
 
 Usage:
   agent -h | --help
-  agent [options] (--kube-url= | --kube-incluster) [--skip-namespace=]... [--source=]...
+  agent [options] (--kube-url= | --kube-incluster | --kubeconfig= | --kubeconfig-dir=) [--skip-namespace=]... [--source=]... [--smooth-rate=]... [--metric-include=]... [--metric-exclude=]... [--environment-rule=]... [--webhook-url=]... [--sink=]...
+  agent [options] replay <recording>
+  agent [options] export
+  agent [options] ping
+  agent [options] config --effective [--skip-namespace=]... [--source=]... [--smooth-rate=]... [--metric-include=]... [--metric-exclude=]... [--environment-rule=]... [--webhook-url=]... [--sink=]...
 
 Options:
   --gateway <address>                        Connect to specified Magalix Kubernetes Agent gateway.
@@ -38,36 +45,210 @@ Options:
                                               [default: $CLUSTER_ID]
   --client-secret <secret>                   Unique and secret client token.
                                               [default: $SECRET]
+  --identity-attestation <provider>          Include signed cloud instance identity document
+                                              in the handshake to prove where the agent runs.
+                                              Supported providers are aws, gcp, azure and none.
+                                              [default: none]
+  --cloud-metadata <provider>                Enrich the node the agent runs on by instance
+                                              metadata where node labels are missing, e.g.
+                                              region, zone and lifecycle. Supported providers
+                                              are aws, gcp, azure and none.
+                                              [default: none]
   --kube-url <url>                           Use specified URL and token for access to kubernetes
                                               cluster.
   --kube-insecure                            Insecure skip SSL verify.
   --kube-root-ca-cert <filepath>             Filepath to root CA cert.
   --kube-token <token>                        Use specified token for access to kubernetes cluster.
+  --kube-token-file <path>                   Use token of specified file for access to kubernetes
+                                              cluster, the file is re-read once it's modified.
   --kube-incluster                           Automatically determine kubernetes clientset
                                               configuration. Works only if program is
                                               running inside kubernetes cluster.
+  --kubeconfig <path>                        Use specified kubeconfig file for access to
+                                              kubernetes cluster, e.g. to run the agent out
+                                              of the cluster. Exec credential plugins, e.g.
+                                              aws-iam-authenticator or
+                                              gke-gcloud-auth-plugin, should be available
+                                              in PATH.
+  --kube-context <name>                      Use specified context of --kubeconfig instead of
+                                              its current context.
+  --kubeconfig-dir <path>                    Run a separate pipeline for every context of
+                                              kubeconfig files in the directory, contexts
+                                              should be named by cluster IDs of the account.
+                                              Clusters share the gateway connection of
+                                              --cluster-id, state files are suffixed by
+                                              cluster ID for other clusters.
   --kube-timeout <duration>                  Timeout of requests to kubernetes apis.
                                               [default: 20s]
+  --kube-qps <qps>                           Max requests per second to kubernetes apis shared
+                                              by scanner, executor and metrics, requests of the
+                                              scanner are sent first, patches of the executor
+                                              next and lists of optional kinds last. Zero keeps
+                                              default limits of kubernetes clients.
+                                              [default: 20]
+  --kube-burst <requests>                    Max burst of requests to kubernetes apis above
+                                              --kube-qps.
+                                              [default: 40]
+  --kube-api-budget <requests>               Max kubernetes api requests per scan, scans are
+                                              done less often and optional kinds are skipped
+                                              while the budget is exceeded. Zero means no
+                                              limit.
+                                              [default: 0]
+  --executor-kubeconfig <path>               Use a separate kubeconfig for changing workloads
+                                              resources, so scanning and metrics can use a
+                                              read-only identity. The identity needs get and
+                                              patch access to workloads. Can't be used with
+                                              --kubeconfig-dir.
   --skip-namespace <pattern>                 Skip namespace matching a pattern (e.g. system-*),
                                               can be specified multiple times.
+  --environment-rule <rule>                  Classify namespaces and workloads into an environment
+                                              by label or annotation, e.g. production:env=prod*,
+                                              can be specified multiple times.
   --source <source>                          Specify source for metrics instead of
-                                              automatically detected.
+                                              automatically detected, can be specified
+                                              multiple times to run several sources, a
+                                              measurement reported by several sources is
+                                              taken from the most accurate one.
                                               Supported sources are:
                                               * kubelet;
+                                              * cri - container runtime stats from kubelet
+                                                resource metrics, for nodes without cAdvisor;
+                                              * metrics-server.
   --kubelet-port <port>                      Override kubelet port for
                                               automatically discovered nodes.
                                               [default: 10255]
+  --kubelet-access <mode>                    How to access kubelet apis:
+                                              * auto - api-server proxy or direct access, direct
+                                                requests fallback to api-server proxy;
+                                              * proxy - only api-server proxy, for clusters where
+                                                nodes are unreachable from pods;
+                                              * direct - only direct access to nodes.
+                                              [default: auto]
+  --kubelet-secure                           Access kubelet directly only through its secure
+                                              port using service account token and cluster CA.
+                                              By default the secure port is autodetected per node
+                                              with a fallback to the read-only port.
   --kubelet-backoff-sleep <duration>         Timeout of backoff policy.
                                               Timeout will be multiplied from 1 to 10.
                                               [default: 300ms]
   --kubelet-backoff-max-retries <retries>    Max reties of backoff policy, then consider failed.
                                               [default: 5]
+  --kubelet-max-concurrency <count>          Max number of nodes scraped concurrently, scrapes
+                                              are spread with jitter over the metrics interval.
+                                              Zero means no limit.
+                                              [default: 20]
+  --kubelet-request-timeout <duration>       Timeout of a single kubelet request including
+                                              reading of the response, retries are
+                                              controlled by --kubelet-backoff-*.
+                                              [default: 15s]
+  --kubelet-breaker-failures <count>         Skip scrapes of a node after specified count of
+                                              consecutive failed scrapes, zero never skips
+                                              nodes.
+                                              [default: 3]
+  --kubelet-breaker-skip-ticks <count>       Count of metrics ticks a node is skipped for once
+                                              it failed repeatedly.
+                                              [default: 5]
+  --kubelet-resolve-failures <count>         Re-resolve kubelet endpoint of a node after
+                                              specified count of consecutive failed requests,
+                                              zero never re-resolves nodes.
+                                              [default: 2]
+  --kubelet-resolve-interval <duration>      Min interval between re-resolutions of kubelet
+                                              endpoint of a node.
+                                              [default: 1m]
+  --kubelet-idle-connections <count>         Idle connections kept per kubelet for reuse by
+                                              next scrapes.
+                                              [default: 2]
+  --kubelet-idle-timeout <duration>          Close idle connections to kubelets after the
+                                              duration, it should exceed --metrics-interval
+                                              so connections are reused by next ticks.
+                                              [default: 3m]
+  --kubelet-preferred-address-types <types>  Comma separated types of node addresses kubelets
+                                              are accessed directly by in order of priority,
+                                              e.g. for kubelets exposed only on external ips.
+                                              [default: InternalIP,Hostname,ExternalIP]
+  --smooth-rate <family>                     Send exponentially smoothed rates in addition to
+                                              last interval rates for a metrics family, cpu or
+                                              network, the weight of the last interval can be
+                                              specified as family:factor, e.g. cpu:0.5, and
+                                              is 0.3 by default, can be specified multiple
+                                              times.
+  --metric-include <pattern>                 Collect only measurements matching the glob
+                                              pattern, e.g. cpu/*, can be specified multiple
+                                              times. All measurements are collected by default.
+  --metric-exclude <pattern>                 Drop measurements matching the glob pattern,
+                                              e.g. network/*errors* or filesystem/*, takes
+                                              precedence over --metric-include, can be
+                                              specified multiple times.
+  --metrics-mapping <path>                   JSON file with a translation table renaming or
+                                              aliasing metrics and tags before sending, e.g.
+                                              {"rename": {"cpu/usage_rate": "cpu/rate"},
+                                              "aliases": {"memory/rss": ["memory/usage"]},
+                                              "tags": {"instance_group": "node_group"}}.
   --metrics-interval <duration>              Metrics request and send interval.
                                               [default: 1m]
+  --metrics-batch-size <size>                Max number of metrics sent in a single packet,
+                                              bigger ticks are split into multiple packets.
+                                              [default: 1000]
+  --capacity-interval <duration>             Interval of cluster capacity snapshots aggregated
+                                              per node pool and namespace from metrics, zero
+                                              disables snapshots.
+                                              [default: 5m]
+  --cost-metrics                             Estimate hourly costs of requested and used
+                                              resources per namespace and service, reported
+                                              in micro USD as cost/requests and cost/usage.
+  --cost-pricing <path>                      JSON file with a pricing table of nodes, e.g. a
+                                              mounted ConfigMap, reloaded when it changes:
+                                              {"pools": {"pool-a": 0.2}, "instance_types":
+                                              {"m5.large": 0.096}, "cpu_core_hour": 0.03,
+                                              "memory_gb_hour": 0.004}.
+  --shard-index <index>                      Index of the shard handled by this replica of the
+                                              agent, starting from zero.
+                                              [default: 0]
+  --shard-total <total>                      Number of replicas the cluster is partitioned
+                                              across, nodes are scraped and namespaces are
+                                              reported only by replicas of their shards,
+                                              decisions, events and deletions are handled
+                                              by the replica of the shard 0 only. The
+                                              gateway should support sharded packets.
+                                              [default: 1]
+  --metrics-state <path>                     Persist previous values of rate metrics to
+                                              specified file, so rates are calculated on the
+                                              first tick after restart.
+  --entities-state <path>                    Persist entities of the last scan to specified
+                                              file, they are sent marked as stale on start
+                                              before the first scan completes.
+  --sink <sink>                              Send metrics to specified sink instead of the
+                                              gateway, can be specified multiple times.
+                                              Supported sinks are:
+                                              * gateway;
+                                              * file - JSON lines written to --sink-file;
+                                              * influxdb - line protocol written to
+                                                --sink-influxdb-url;
+                                              * otlp - OTLP/HTTP JSON exported to
+                                                --sink-otlp-url.
+  --sink-file <path>                         File used by file metrics sink.
+  --sink-influxdb-url <url>                  InfluxDB write endpoint used by influxdb metrics
+                                              sink, e.g. http://influxdb:8086/write?db=magalix.
+  --sink-otlp-url <url>                      OTLP collector metrics endpoint used by otlp
+                                              metrics sink, e.g.
+                                              http://collector:4318/v1/metrics.
+  --sink-timeout <duration>                  Timeout of requests of metrics sinks.
+                                              [default: 10s]
   --events-buffer-flush-interval <duration>  Events batch writer flush interval.
                                               [default: 10s]
   --events-buffer-size <size>                Events batch writer buffer size.
                                               [default: 20]
+  --capture-crash-logs-lines <count>         Count of last lines of logs of previous
+                                              containers attached to events of
+                                              containers crashed or OOM killed,
+                                              zero disables capturing.
+                                              [default: 0]
+  --heartbeat-interval <duration>            Interval of heartbeats carrying health of agent
+                                              components.
+                                              [default: 1m]
+  --shutdown-timeout <duration>              Max time to flush pending events, metrics and
+                                              packets on SIGTERM or SIGINT before exit.
+                                              [default: 20s]
   --timeout-proto-handshake <duration>       Timeout to do a websocket handshake.
                                               [default: 10s]
   --timeout-proto-write <duration>           Timeout to write a message to websocket channel.
@@ -82,11 +263,172 @@ Options:
   --opt-in-analysis-data                     Send anonymous data for analysis.
   --analysis-data-interval <duration>        Analysis data send interval.
                                               [default: 5m]
+  --entities-resync-interval <duration>      Interval of full applications snapshots, only
+                                              changed entities are sent in between, zero
+                                              sends full snapshots on every scan.
+                                              [default: 1h]
   --disable-metrics                          Disable metrics collecting and sending.
   --disable-events                           Disable events collecting and sending.
   --disable-scalar                           Disable in-agent scalar.
-  --dry-run                                  Disable decision execution.
+  --disable-namespaces-summary               Disable sending namespaces requested vs used
+                                              resources summary.
+  --namespaces-summary-interval <duration>   Namespaces summary send interval.
+                                              [default: 15m]
+  --dry-run                                  Disable decision execution and in-agent scalar
+                                              changes, same as both --dry-run-executor and
+                                              --dry-run-scalar.
+  --dry-run-executor                         Disable execution of decisions received from the
+                                              backend, predicted changes are reported instead,
+                                              implies --dry-run-scalar.
+  --dry-run-scalar                           Disable changes made by in-agent scalar,
+                                              predicted changes are reported instead.
+  --scalar-memory-increase <percent>         Increase of memory limits of containers killed
+                                              by the OOM killer made by in-agent scalar.
+                                              [default: 50]
+  --scalar-max-memory <Mi>                   Maximum of memory limits set by in-agent scalar,
+                                              zero means no maximum.
+                                              [default: 0]
+  --scalar-memory-threshold <percent>        Usage of memory limits by growing memory rss of
+                                              containers which makes in-agent scalar raise
+                                              their limits, zero disables scaling by memory
+                                              usage trends.
+                                              [default: 0]
+  --scalar-memory-growth <Mi>                Minimal growth of memory rss of containers per
+                                              hour which makes in-agent scalar raise their
+                                              limits by memory usage trends.
+                                              [default: 10]
+  --scalar-weights <list>                    Weights of increases of limits made by in-agent
+                                              scalar in format <target>=<weight>, targets
+                                              are namespaces or workloads in format
+                                              <namespace>/<name>, zero weight disables
+                                              scaling of the target.
+  --scalar-min-restarts <count>              Restarts of OOM killed containers before
+                                              in-agent scalar raises their limits.
+                                              [default: 3]
+  --scalar-cooldown <duration>               Don't handle containers again by in-agent
+                                              scalar within the cooldown, zero disables the
+                                              cooldown.
+                                              [default: 0s]
+  --scalar-targets <list>                    Handle only specified comma separated
+                                              namespaces and workloads in format
+                                              <namespace>/<name> by in-agent scalar.
+  --scalar-status-interval <duration>        Interval of reporting decisions of in-agent
+                                              scalar.
+                                              [default: 5m]
+  --execute-increases-only                   Execute only decisions which raise requests,
+                                              limits or replicas, decisions reducing them
+                                              are reported as dry-run results.
+  --decisions-coalescing-window <duration>   Merge decisions of the same workload received
+                                              within the window into a single change, the
+                                              window should be less than
+                                              --timeout-proto-read, zero disables coalescing.
+                                              [default: 0s]
+  --max-concurrent-executions <number>       Max count of decisions executed at once,
+                                              decisions of the same namespace are
+                                              always executed one by one.
+                                              [default: 2]
+  --executions-state <path>                  Persist in-flight executions to specified file,
+                                              executions interrupted by a restart are verified
+                                              against the cluster on start and reported.
+  --execution-retries <count>                Retry executions failed with transient errors
+                                              of the api-server, e.g. conflicts or throttling,
+                                              or postponed since the service isn't ready or
+                                              its pod disruption budget allows no disruptions,
+                                              up to specified count of times, zero disables.
+                                              [default: 3]
+  --execution-retry-backoff <duration>       Delay before the first retry of an execution,
+                                              the delay is doubled after every retry.
+                                              [default: 30s]
+  --execution-retries-state <path>           Persist executions waiting for retries to
+                                              specified file, so they are retried after a
+                                              restart.
+  --reversion-window <duration>              Watch workloads within the window after
+                                              applying decisions and report changes reverted
+                                              by other controllers, e.g. Argo CD or Flux, so
+                                              they are not applied again, zero disables.
+                                              [default: 5m]
+  --git-writeback <repository>               Commit decided resources to manifests of
+                                              workloads in specified git repository instead
+                                              of patching the cluster, so GitOps controllers
+                                              like Flux or Argo CD remain the source of truth.
+  --git-writeback-branch <branch>            Branch of the git writeback repository.
+                                              [default: main]
+  --git-writeback-path <path>                Directory of manifests within the git writeback
+                                              repository.
+                                              [default: .]
+  --git-writeback-author <name>              Author name of git writeback commits.
+                                              [default: Magalix Agent]
+  --git-writeback-email <email>              Author email of git writeback commits.
+                                              [default: agent@magalix.com]
+  --git-writeback-provider <provider>        Open pull requests of git writeback changes
+                                              via api of specified provider instead of
+                                              pushing to the branch, github or gitlab. An
+                                              open pull request of a workload is updated
+                                              by later decisions.
+  --git-writeback-api-url <url>              Use specified api url of the git writeback
+                                              provider, e.g. of GitHub Enterprise.
+  --git-writeback-token <token>              Token of the git writeback provider, also used
+                                              for pushing to https repositories, can be
+                                              specified as $ENV_VAR.
+  --freeze-state <path>                      Persist change freeze requested by the backend
+                                              or via /freeze status endpoint to specified
+                                              file, a freeze survives restarts. Every
+                                              cluster of --kubeconfig-dir is frozen on its
+                                              own, state files are suffixed by cluster ID
+                                              for other clusters.
+  --quiet-hours <spec>                       Never apply decisions or OOM handler changes
+                                              within quiet hours regardless of the backend,
+                                              decisions are queued instead and executed once
+                                              quiet hours end, e.g.
+                                              "22:00-06:00 Mon-Fri TZ=Europe/Berlin".
+  --deferred-decisions-state <path>          Persist decisions queued by --quiet-hours to
+                                              specified file, so they are executed after a
+                                              restart.
+  --policy-file <path>                       Read policy of decisions from specified JSON
+                                              file, e.g. a mounted ConfigMap, the file is
+                                              reloaded on changes and replaces --policy-*
+                                              flags.
+  --policy-max-change <percent>              Reject decisions changing replicas or resources
+                                              by more than specified percent, zero disables.
+                                              [default: 0]
+  --policy-cpu <range>                       Reject decisions setting cpu out of specified
+                                              range of millicores, e.g. 100-4000.
+  --policy-memory <range>                    Reject decisions setting memory out of
+                                              specified range of mebibytes, e.g. 64-8192.
+  --policy-forbidden-namespaces <list>       Reject decisions of workloads in specified
+                                              comma separated namespaces.
+  --policy-windows <spec>                    Reject decisions out of specified windows, same
+                                              format as --quiet-hours, e.g.
+                                              "09:00-17:00 Mon-Fri".
+  --webhook-url <url>                        Post entities snapshots and applied decisions
+                                              to an in-cluster webhook as JSON, can be
+                                              specified multiple times.
+  --webhook-timeout <duration>               Timeout of webhook requests.
+                                              [default: 10s]
+  --otel-endpoint <url>                      Export traces of metrics and decisions pipelines
+                                              to an OpenTelemetry collector using OTLP/HTTP,
+                                              e.g. http://collector:4318.
+  --otel-timeout <duration>                  Timeout of traces export requests.
+                                              [default: 10s]
   --no-send-logs                             Disable sending logs to the backend.
+  --status-address <address>                 Serve local status endpoints (e.g. /decisions)
+                                              on specified address, e.g. :8080.
+  --status-token <token>                     Bearer token required by status endpoints.
+                                              [default: $STATUS_TOKEN]
+  --enable-pprof                             Serve pprof and /debug/state endpoints on
+                                              the status address.
+  --record <path>                            Record inbound gateway packets and internal
+                                              transitions to specified file, the recording
+                                              can be reproduced using agent replay or
+                                              archived using agent export.
+  --since <duration>                         Export records of specified period.
+                                              [default: 24h]
+  --output <path>                            Write exported archive to specified file.
+                                              [default: magalix-agent-export.tar.gz]
+  --effective                                Print configuration the agent runs with, defaults
+                                              and environment variables resolved, secrets
+                                              redacted. The same configuration is reported
+                                              to the gateway on start.
   --debug                                    Enable debug messages.
   --trace                                    Enable debug and trace messages.
   --trace-log <path>                         Write log messages to specified file
@@ -126,46 +468,137 @@ func main() {
 	stderr.SetExiter(func(int) {})
 	utils.SetLogger(stderr)
 
+	if args["replay"].(bool) {
+		err := runReplay(stderr, args["<recording>"].(string))
+		if err != nil {
+			stderr.Fatalf(err, "unable to replay recording")
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if args["config"].(bool) {
+		err := runConfig(args)
+		if err != nil {
+			stderr.Fatalf(err, "unable to print configuration")
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if args["export"].(bool) {
+		err := runExport(args, stderr)
+		if err != nil {
+			stderr.Fatalf(err, "unable to export recording")
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	stderr.Infof(
 		karma.Describe("version", version).
 			Describe("args", fmt.Sprintf("%q", utils.GetSanitizedArgs())),
 		"magalix agent started",
 	)
 
-	secret, err := base64.StdEncoding.DecodeString(
-		utils.ExpandEnv(args, "--client-secret", false),
-	)
+	credentials, err := client.ParseCredentials(args)
 	if err != nil {
-		stderr.Fatalf(
-			err,
-			"unable to decode base64 secret specified as --client-secret flag",
-		)
+		stderr.Fatalf(err, "unable to parse credentials")
 		os.Exit(1)
 	}
 
-	// TODO: remove
-	// a hack to set default timeout for all http requests
-	http.DefaultClient = &http.Client{
-		Timeout: 20 * time.Second,
+	if args["ping"].(bool) {
+		err := runPing(args, stderr, credentials)
+		if err != nil {
+			stderr.Fatalf(err, "unable to ping gateway")
+			os.Exit(1)
+		}
+
+		return
 	}
 
 	var (
-		accountID = utils.ExpandEnvUUID(args, "--account-id")
-		clusterID = utils.ExpandEnvUUID(args, "--cluster-id")
+		accountID = credentials.AccountID
+		clusterID = credentials.ClusterID
 
 		metricsEnabled = !args["--disable-metrics"].(bool)
 		eventsEnabled  = !args["--disable-events"].(bool)
 		scalarEnabled  = !args["--disable-scalar"].(bool)
+		summaryEnabled = !args["--disable-namespaces-summary"].(bool)
 		dryRun         = args["--dry-run"].(bool)
+		dryRunExecutor = dryRun || args["--dry-run-executor"].(bool)
+		// NOTE: the scalar never changes workloads while decisions of the
+		// backend are not executed
+		dryRunScalar = dryRunExecutor || args["--dry-run-scalar"].(bool)
 
-		skipNamespaces []string
+		skipNamespaces   []string
+		environmentRules []scanner.EnvironmentRule
 	)
 
 	if namespaces, ok := args["--skip-namespace"].([]string); ok {
 		skipNamespaces = namespaces
 	}
 
-	gwClient, err := client.InitClient(args, version, startID, accountID, clusterID, secret, stderr)
+	if rules, ok := args["--environment-rule"].([]string); ok {
+		environmentRules, err = scanner.ParseEnvironmentRules(rules)
+		if err != nil {
+			stderr.Fatalf(err, "unable to parse environment rules")
+			os.Exit(1)
+		}
+	}
+
+	if path, ok := args["--record"].(string); ok && path != "" {
+		recorder, err := replay.NewRecorder(stderr, path)
+		if err != nil {
+			stderr.Fatalf(err, "unable to start recording")
+			os.Exit(1)
+		}
+
+		defer recorder.Close()
+
+		replay.SetRecorder(recorder)
+	}
+
+	if urls, ok := args["--webhook-url"].([]string); ok && len(urls) > 0 {
+		urls, err := webhook.ParseURLs(urls)
+		if err != nil {
+			stderr.Fatalf(err, "unable to parse webhook urls")
+			os.Exit(1)
+		}
+
+		webhook.SetPublisher(webhook.NewPublisher(
+			stderr,
+			urls,
+			utils.MustParseDuration(args, "--webhook-timeout"),
+			accountID,
+			clusterID,
+		))
+	}
+
+	if endpoint, ok := args["--otel-endpoint"].(string); ok && endpoint != "" {
+		tracer := tracing.NewTracer(
+			stderr,
+			endpoint,
+			utils.MustParseDuration(args, "--otel-timeout"),
+			map[string]string{
+				"service.name":        "magalix-agent",
+				"service.version":     version,
+				"service.instance.id": startID,
+				"magalix.account_id":  accountID.String(),
+				"magalix.cluster_id":  clusterID.String(),
+			},
+		)
+		tracer.Start()
+
+		tracing.SetTracer(tracer)
+	}
+
+	gwClient, err := client.InitClient(
+		args, version, startID, credentials, stderr,
+	)
 
 	defer gwClient.WaitExit()
 	defer gwClient.Recover()
@@ -175,11 +608,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	kube, err := kuber.InitKubernetes(args, gwClient)
-	if err != nil {
-		stderr.Fatalf(err, "unable to initialize Kubernetes")
-		os.Exit(1)
-	}
+	gwClient.HandleSignals(utils.MustParseDuration(args, "--shutdown-timeout"))
+
+	sendEffectiveConfig(gwClient, args)
 
 	optInAnalysisData := args["--opt-in-analysis-data"].(bool)
 	analysisDataInterval := utils.MustParseDuration(
@@ -187,24 +618,167 @@ func main() {
 		"--analysis-data-interval",
 	)
 
-	entityScanner := scanner.InitScanner(
-		gwClient,
-		kube,
-		skipNamespaces,
-		accountID,
-		clusterID,
-		optInAnalysisData,
-		analysisDataInterval,
-	)
+	maxConcurrentExecutions := utils.MustParseInt(args, "--max-concurrent-executions")
+	if maxConcurrentExecutions <= 0 {
+		gwClient.Fatalf(
+			nil,
+			"--max-concurrent-executions should be positive, got %d",
+			maxConcurrentExecutions,
+		)
+		os.Exit(1)
+	}
 
-	e := executor.InitExecutor(
-		gwClient,
-		kube,
-		entityScanner,
-		dryRun,
-	)
+	freezeState, _ := args["--freeze-state"].(string)
+	freezer, err := freeze.NewFreezer(gwClient.Logger, freezeState)
+	if err != nil {
+		gwClient.Fatalf(err, "unable to restore freeze state")
+		os.Exit(1)
+	}
+
+	if spec, ok := args["--quiet-hours"].(string); ok && spec != "" {
+		quietHours, err := freeze.ParseQuietHours(spec)
+		if err != nil {
+			gwClient.Fatalf(err, "unable to parse --quiet-hours")
+			os.Exit(1)
+		}
+
+		gwClient.Infof(
+			karma.Describe("quiet-hours", quietHours),
+			"cluster changes will be frozen during quiet hours",
+		)
+
+		freeze.SetQuietHours(quietHours)
+	}
+
+	decisionsPolicy, err := getDecisionsPolicy(args)
+	if err != nil {
+		gwClient.Fatalf(err, "unable to parse policy flags")
+		os.Exit(1)
+	}
+
+	policyFile, _ := args["--policy-file"].(string)
+	policyEngine, err := policy.NewEngine(gwClient.Logger, decisionsPolicy, policyFile)
+	if err != nil {
+		gwClient.Fatalf(err, "unable to load policy of decisions")
+		os.Exit(1)
+	}
+
+	policy.SetEngine(policyEngine)
+
+	shardIndex := utils.MustParseInt(args, "--shard-index")
+	shardTotal := utils.MustParseInt(args, "--shard-total")
+	if shardTotal <= 0 || shardIndex < 0 || shardIndex >= shardTotal {
+		gwClient.Fatalf(
+			nil,
+			"--shard-index should be in range [0, %d), got %d",
+			shardTotal,
+			shardIndex,
+		)
+		os.Exit(1)
+	}
+
+	shard.SetShard(shard.Shard{Index: shardIndex, Total: shardTotal})
+	if shard.IsSharded() {
+		gwClient.Infof(
+			karma.
+				Describe("index", shardIndex).
+				Describe("total", shardTotal),
+			"nodes and namespaces are partitioned across replicas",
+		)
+
+		if !gwClient.WaitForConnection(time.Minute) {
+			gwClient.Fatalf(nil, "unable to connect to the gateway")
+			os.Exit(1)
+		}
+
+		if !gwClient.IsPacketKindSupported(proto.PacketKindMetricsStoreChunkRequest) {
+			gwClient.Fatalf(
+				nil,
+				"--shard-total is greater than 1, but the gateway doesn't "+
+					"support sharded packets",
+			)
+			os.Exit(1)
+		}
+	}
+
+	if provider := args["--cloud-metadata"].(string); provider != "none" {
+		instance, err := cloud.GetInstance(provider)
+		if err != nil {
+			gwClient.Errorf(err, "unable to get cloud instance metadata")
+		} else {
+			gwClient.Infof(
+				karma.
+					Describe("hostname", instance.Hostname).
+					Describe("region", instance.Region).
+					Describe("zone", instance.Zone).
+					Describe("instance-type", instance.InstanceType).
+					Describe("lifecycle", instance.Lifecycle),
+				"cloud instance metadata is retrieved",
+			)
+
+			cloud.SetInstance(instance)
+		}
+	}
+
+	scalarOptions, err := getScalarOptions(args, dryRunScalar)
+	if err != nil {
+		gwClient.Fatalf(err, "unable to parse in-agent scalar flags")
+		os.Exit(1)
+	}
+
+	options := clusterOptions{
+		skipNamespaces:          skipNamespaces,
+		environmentRules:        environmentRules,
+		optInAnalysisData:       optInAnalysisData,
+		analysisDataInterval:    analysisDataInterval,
+		entitiesResyncInterval:  utils.MustParseDuration(args, "--entities-resync-interval"),
+		kubeAPIBudget:           utils.MustParseInt(args, "--kube-api-budget"),
+		maxConcurrentExecutions: maxConcurrentExecutions,
+
+		metricsEnabled: metricsEnabled,
+		eventsEnabled:  eventsEnabled,
+		scalarEnabled:  scalarEnabled,
+		summaryEnabled: summaryEnabled,
+		dryRunExecutor: dryRunExecutor,
+		scalarOptions:  scalarOptions,
+	}
+
+	var clusters []*cluster
+	if dir, ok := args["--kubeconfig-dir"].(string); ok && dir != "" {
+		if path, ok := args["--executor-kubeconfig"].(string); ok && path != "" {
+			stderr.Fatalf(
+				nil,
+				"--executor-kubeconfig can't be used with --kubeconfig-dir, "+
+					"kubeconfig contexts are used for changing workloads",
+			)
+			os.Exit(1)
+		}
+
+		clusters, err = startKubeconfigClusters(
+			args, stderr, gwClient, dir, freezer, options,
+		)
+		if err != nil {
+			stderr.Fatalf(err, "unable to start clusters")
+			os.Exit(1)
+		}
+	} else {
+		kube, err := kuber.InitKubernetes(args, gwClient)
+		if err != nil {
+			stderr.Fatalf(err, "unable to initialize Kubernetes")
+			os.Exit(1)
+		}
+
+		executorKube, err := kuber.InitExecutorKubernetes(args, gwClient, kube)
+		if err != nil {
+			stderr.Fatalf(err, "unable to initialize executor Kubernetes")
+			os.Exit(1)
+		}
+
+		clusters = []*cluster{
+			startCluster(args, stderr, gwClient, kube, executorKube, freezer, options),
+		}
+	}
 
-	gwClient.AddListener(proto.PacketKindDecision, e.Listener)
 	gwClient.AddListener(proto.PacketKindRestart, func(in []byte) (out []byte, err error) {
 		var restart proto.PacketRestart
 		if err = proto.Decode(in, &restart); err != nil {
@@ -214,32 +788,142 @@ func main() {
 		return nil, nil
 	})
 
-	if eventsEnabled {
-		events.InitEvents(
-			gwClient,
-			kube,
-			skipNamespaces,
-			entityScanner,
-			args,
-		)
+	if args["--enable-pprof"].(bool) && args["--status-address"] == nil {
+		gwClient.Fatalf(nil, "--enable-pprof requires --status-address")
+		os.Exit(1)
 	}
 
-	if metricsEnabled {
-		err := metrics.InitMetrics(
-			gwClient,
-			entityScanner,
-			kube,
-			optInAnalysisData,
-			args,
-		)
-		if err != nil {
-			gwClient.Fatalf(err, "unable to initialize metrics sources")
-			os.Exit(1)
+	if address, ok := args["--status-address"].(string); ok && address != "" {
+		token := utils.ExpandEnv(args, "--status-token", false)
+
+		statusServer := status.NewServer(gwClient.Logger, address, token)
+
+		if args["--enable-pprof"].(bool) {
+			status.RegisterState("client", gwClient.GetState)
+		}
+
+		for _, cluster := range clusters {
+			e := cluster.executor
+
+			// NOTE: endpoints of attached clusters are prefixed by cluster ID
+			prefix, suffix := "", ""
+			if cluster.client != gwClient {
+				prefix = "/clusters/" + cluster.client.ClusterID.String()
+				suffix = "/" + cluster.client.ClusterID.String()
+			}
+
+			statusServer.Handle(prefix+"/freeze", cluster.freezer)
+			statusServer.HandleJSON(prefix+"/decisions", func() (interface{}, error) {
+				return e.GetDecisions(), nil
+			})
+			statusServer.HandleJSON(prefix+"/decisions/summary", func() (interface{}, error) {
+				return e.GetSummaries(), nil
+			})
+
+			if args["--enable-pprof"].(bool) {
+				if cluster.client != gwClient {
+					status.RegisterState("client"+suffix, cluster.client.GetState)
+				}
+				status.RegisterState("scanner"+suffix, cluster.scanner.GetState)
+			}
+		}
+
+		if args["--enable-pprof"].(bool) {
+			statusServer.HandleDebug()
+		}
+
+		go func() {
+			err := statusServer.Start()
+			if err != nil {
+				gwClient.Errorf(err, "status server stopped")
+			}
+		}()
+	}
+
+}
+
+// getDecisionsPolicy returns policy of decisions specified by flags
+func getDecisionsPolicy(args map[string]interface{}) (policy.Policy, error) {
+	var err error
+
+	rules := policy.Policy{
+		MaxChangePercent: int64(utils.MustParseInt(args, "--policy-max-change")),
+	}
+
+	spec, _ := args["--policy-cpu"].(string)
+	rules.CPU, err = policy.ParseRange(spec)
+	if err != nil {
+		return rules, karma.Format(err, "invalid --policy-cpu")
+	}
+
+	spec, _ = args["--policy-memory"].(string)
+	rules.Memory, err = policy.ParseRange(spec)
+	if err != nil {
+		return rules, karma.Format(err, "invalid --policy-memory")
+	}
+
+	namespaces, _ := args["--policy-forbidden-namespaces"].(string)
+	for _, namespace := range strings.Split(namespaces, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace != "" {
+			rules.ForbiddenNamespaces = append(rules.ForbiddenNamespaces, namespace)
 		}
 	}
 
-	if scalarEnabled {
-		scalar.InitScalars(stderr, entityScanner, kube, dryRun)
+	rules.Windows, _ = args["--policy-windows"].(string)
+
+	return rules, nil
+}
+
+// getScalarOptions returns options of the in-agent scalar specified by flags
+func getScalarOptions(
+	args map[string]interface{},
+	dryRun bool,
+) (scalar.Options, error) {
+	options := scalar.Options{
+		DryRun:                dryRun,
+		MemoryIncreasePercent: int64(utils.MustParseInt(args, "--scalar-memory-increase")),
+		MaxMemory:             int64(utils.MustParseInt(args, "--scalar-max-memory")),
+		MemoryThreshold:       int64(utils.MustParseInt(args, "--scalar-memory-threshold")),
+		MemoryGrowth:          int64(utils.MustParseInt(args, "--scalar-memory-growth")),
+		MinRestarts:           int32(utils.MustParseInt(args, "--scalar-min-restarts")),
+		Cooldown:              utils.MustParseDuration(args, "--scalar-cooldown"),
+		StatusInterval:        utils.MustParseDuration(args, "--scalar-status-interval"),
+	}
+
+	if options.MemoryIncreasePercent <= 0 {
+		return options, karma.
+			Describe("value", options.MemoryIncreasePercent).
+			Reason("--scalar-memory-increase should be positive")
+	}
+
+	if options.StatusInterval <= 0 {
+		return options, karma.
+			Describe("value", options.StatusInterval).
+			Reason("--scalar-status-interval should be positive")
+	}
+
+	if options.MemoryGrowth <= 0 {
+		return options, karma.
+			Describe("value", options.MemoryGrowth).
+			Reason("--scalar-memory-growth should be positive")
+	}
+
+	spec, _ := args["--scalar-targets"].(string)
+	targets, err := scalar.ParseTargets(spec)
+	if err != nil {
+		return options, karma.Format(err, "invalid --scalar-targets")
 	}
 
+	options.Targets = targets
+
+	spec, _ = args["--scalar-weights"].(string)
+	weights, err := scalar.ParseWeights(spec)
+	if err != nil {
+		return options, karma.Format(err, "invalid --scalar-weights")
+	}
+
+	options.Weights = weights
+
+	return options, nil
 }
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+// nodeCircuit state of scrapes of a node
+type nodeCircuit struct {
+	// failures consecutive failed scrapes
+	failures int
+	// skip count of ticks the node is skipped yet
+	skip int
+}
+
+// nodesBreaker skips scrapes of a node for several ticks after consecutive
+// failures, so a flapping node doesn't consume retries and delay metrics of
+// other nodes every tick
+type nodesBreaker struct {
+	// threshold consecutive failures which open the circuit, zero disables
+	// the breaker
+	threshold int
+	// skipTicks count of ticks the node is skipped once the circuit is open
+	skipTicks int
+
+	mutex sync.Mutex
+	nodes map[string]*nodeCircuit
+	// total count of nodes of the cluster
+	total int
+}
+
+func newNodesBreaker(threshold int, skipTicks int) *nodesBreaker {
+	return &nodesBreaker{
+		threshold: threshold,
+		skipTicks: skipTicks,
+		nodes:     map[string]*nodeCircuit{},
+	}
+}
+
+// allow returns nodes which should be scraped at the tick, the node is tried
+// again once it was skipped for the configured count of ticks, a single
+// failure opens the circuit again
+func (breaker *nodesBreaker) allow(nodes []kuber.Node) []kuber.Node {
+	if breaker.threshold <= 0 {
+		return nodes
+	}
+
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	allowed := []kuber.Node{}
+	for _, node := range nodes {
+		circuit, ok := breaker.nodes[node.Name]
+		if ok && circuit.skip > 0 {
+			circuit.skip--
+			continue
+		}
+
+		allowed = append(allowed, node)
+	}
+
+	return allowed
+}
+
+// observe records results of scrapes of nodes
+func (breaker *nodesBreaker) observe(nodes []kuber.Node, errs scrapeErrors) {
+	if breaker.threshold <= 0 {
+		return
+	}
+
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	for i, node := range nodes {
+		if errs[i] == nil {
+			delete(breaker.nodes, node.Name)
+			continue
+		}
+
+		circuit, ok := breaker.nodes[node.Name]
+		if !ok {
+			circuit = &nodeCircuit{}
+			breaker.nodes[node.Name] = circuit
+		}
+
+		circuit.failures++
+		if circuit.failures >= breaker.threshold {
+			circuit.skip = breaker.skipTicks
+		}
+	}
+}
+
+// forget drops state of nodes which aren't in the cluster anymore
+func (breaker *nodesBreaker) forget(nodes []kuber.Node) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.total = len(nodes)
+
+	existing := map[string]struct{}{}
+	for _, node := range nodes {
+		existing[node.Name] = struct{}{}
+	}
+
+	for name := range breaker.nodes {
+		if _, ok := existing[name]; !ok {
+			delete(breaker.nodes, name)
+		}
+	}
+}
+
+// getOpen returns sorted names of nodes with open circuits
+func (breaker *nodesBreaker) getOpen() []string {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	open := []string{}
+	for name, circuit := range breaker.nodes {
+		if breaker.threshold > 0 && circuit.failures >= breaker.threshold {
+			open = append(open, name)
+		}
+	}
+
+	sort.Strings(open)
+
+	return open
+}
+
+// isTripped checks whether circuits of all nodes are open
+func (breaker *nodesBreaker) isTripped() bool {
+	open := breaker.getOpen()
+
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	return len(open) > 0 && len(open) >= breaker.total
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+func TestNodesBreaker(t *testing.T) {
+	breaker := newNodesBreaker(2, 2)
+
+	nodes := []kuber.Node{{Name: "a"}, {Name: "b"}}
+	failed := errors.New("failed")
+
+	// tick scrapes allowed nodes, node a fails if failA is true
+	tick := func(failA bool) []string {
+		breaker.forget(nodes)
+
+		allowed := breaker.allow(nodes)
+
+		names := []string{}
+		errs := scrapeErrors{}
+		for _, node := range allowed {
+			names = append(names, node.Name)
+			if node.Name == "a" && failA {
+				errs = append(errs, failed)
+			} else {
+				errs = append(errs, nil)
+			}
+		}
+
+		breaker.observe(allowed, errs)
+
+		return names
+	}
+
+	for i, testcase := range []struct {
+		failA   bool
+		scraped []string
+		open    []string
+	}{
+		{true, []string{"a", "b"}, []string{}},
+		{true, []string{"a", "b"}, []string{"a"}},
+		// skipped for two ticks
+		{false, []string{"b"}, []string{"a"}},
+		{false, []string{"b"}, []string{"a"}},
+		// a single failure of the tried node opens the circuit again
+		{true, []string{"a", "b"}, []string{"a"}},
+		{false, []string{"b"}, []string{"a"}},
+		{false, []string{"b"}, []string{"a"}},
+		{false, []string{"a", "b"}, []string{}},
+	} {
+		scraped := tick(testcase.failA)
+		if !reflect.DeepEqual(scraped, testcase.scraped) {
+			t.Errorf("tick %d: expected scraped %v, got %v", i, testcase.scraped, scraped)
+		}
+
+		if open := breaker.getOpen(); !reflect.DeepEqual(open, testcase.open) {
+			t.Errorf("tick %d: expected open %v, got %v", i, testcase.open, open)
+		}
+
+		if breaker.isTripped() {
+			t.Errorf("tick %d: breaker should not be tripped", i)
+		}
+	}
+}
+
+func TestNodesBreaker_Tripped(t *testing.T) {
+	breaker := newNodesBreaker(1, 5)
+
+	nodes := []kuber.Node{{Name: "a"}}
+	breaker.forget(nodes)
+	breaker.observe(nodes, scrapeErrors{errors.New("failed")})
+
+	if !breaker.isTripped() {
+		t.Errorf("breaker should be tripped if all nodes are skipped")
+	}
+
+	// the node is removed from the cluster
+	breaker.forget(nil)
+	if breaker.isTripped() || len(breaker.getOpen()) > 0 {
+		t.Errorf("state of removed nodes should be dropped")
+	}
+}
+
+func TestNodesBreaker_Disabled(t *testing.T) {
+	breaker := newNodesBreaker(0, 5)
+
+	nodes := []kuber.Node{{Name: "a"}}
+	for i := 0; i < 3; i++ {
+		if len(breaker.allow(nodes)) != 1 {
+			t.Fatalf("disabled breaker should not skip nodes")
+		}
+		breaker.observe(nodes, scrapeErrors{errors.New("failed")})
+	}
+
+	if len(breaker.getOpen()) > 0 {
+		t.Errorf("disabled breaker should not open circuits")
+	}
+}
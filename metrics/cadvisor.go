@@ -111,6 +111,54 @@ func getCAdvisorContainerValue(t TagsValue) (podUID string, containerName string
 	return
 }
 
+// cadvisorContainerKey identifies a container in cAdvisor series
+type cadvisorContainerKey struct {
+	PodUID        string
+	Namespace     string
+	ContainerName string
+}
+
+// getCAdvisorContainersTotals sums series of containers over all network
+// interfaces or disk devices, series of pod sandboxes and cgroups without a
+// container name are skipped since they are reported on pod level
+func getCAdvisorContainersTotals(values []TagsValue) map[cadvisorContainerKey]float64 {
+	totals := map[cadvisorContainerKey]float64{}
+	for _, tagsValue := range values {
+		podUID, containerName, namespace, value, ok := getCAdvisorContainerValue(tagsValue)
+		if !ok || containerName == "" || containerName == "POD" {
+			continue
+		}
+
+		totals[cadvisorContainerKey{
+			PodUID:        podUID,
+			Namespace:     namespace,
+			ContainerName: containerName,
+		}] += value
+	}
+
+	return totals
+}
+
+// getCAdvisorNodeTotal sums series of the root cgroup over all network
+// interfaces or disk devices, it returns false if the node isn't reported
+func getCAdvisorNodeTotal(values []TagsValue) (float64, bool) {
+	var (
+		total float64
+		found bool
+	)
+
+	for _, tagsValue := range values {
+		if tagsValue.Tags["id"] != "/" {
+			continue
+		}
+
+		total += tagsValue.Value
+		found = true
+	}
+
+	return total, found
+}
+
 // decodeCAdvisorResponse decode cAdvisor response to CAdvisorMetrics
 func decodeCAdvisorResponse(r io.Reader) (CAdvisorMetrics, error) {
 	bufScanner := bufio.NewScanner(r)
@@ -273,7 +321,7 @@ func (cAdvisor *CAdvisor) GetMetrics(tickTime time.Time) (
 		defer close(batchPipe)
 
 		// don't wait for the tickTime and assume latest nodes definitions are good
-		nodes := cAdvisor.scanner.GetNodes()
+		nodes := cAdvisor.scanner.GetShardNodes()
 
 		ctx := karma.Describe("tick_time", tickTime.Format(time.RFC3339))
 		cAdvisor.Infof(
@@ -335,6 +383,9 @@ func (cAdvisor *CAdvisor) bind(labels map[string]string) (
 			)
 			if ok {
 				containerID = container.ID
+				if container.Environment != "" {
+					labels[EnvironmentTag] = container.Environment
+				}
 			}
 		} else if podName != "" && containerName == "POD" {
 			metricType = TypePod
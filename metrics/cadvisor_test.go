@@ -176,3 +176,47 @@ container_cpu_cfs_throttled_seconds_total{container_name="",id="/kubepods/bursta
 		})
 	}
 }
+
+func TestGetCAdvisorContainersTotals(t *testing.T) {
+	metrics, err := decodeCAdvisorResponse(strings.NewReader(`
+container_network_receive_bytes_total{container_name="POD",id="/kubepods/burstable/pod6b6035fb-e6a9-11e8-a8ed-42010a8e0004",interface="eth0",namespace="default",pod_name="api"} 900
+container_network_receive_bytes_total{container_name="app",id="/kubepods/burstable/pod6b6035fb-e6a9-11e8-a8ed-42010a8e0004/abc",interface="eth0",namespace="default",pod_name="api"} 100
+container_network_receive_bytes_total{container_name="app",id="/kubepods/burstable/pod6b6035fb-e6a9-11e8-a8ed-42010a8e0004/abc",interface="eth1",namespace="default",pod_name="api"} 50
+container_network_receive_bytes_total{container_name="proxy",id="/kubepods/burstable/pod6b6035fb-e6a9-11e8-a8ed-42010a8e0004/def",interface="eth0",namespace="default",pod_name="api"} 700
+container_network_receive_bytes_total{container_name="",id="/",interface="eth0",namespace="",pod_name=""} 10000
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[cadvisorContainerKey]float64{
+		{"6b6035fb-e6a9-11e8-a8ed-42010a8e0004", "default", "app"}:   150,
+		{"6b6035fb-e6a9-11e8-a8ed-42010a8e0004", "default", "proxy"}: 700,
+	}
+
+	got := getCAdvisorContainersTotals(metrics["container_network_receive_bytes_total"])
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getCAdvisorContainersTotals() = %v, want %v", got, want)
+	}
+}
+
+func TestGetCAdvisorNodeTotal(t *testing.T) {
+	metrics, err := decodeCAdvisorResponse(strings.NewReader(`
+container_fs_reads_bytes_total{container_name="",device="/dev/sda",id="/",namespace="",pod_name=""} 1000
+container_fs_reads_bytes_total{container_name="",device="/dev/sdb",id="/",namespace="",pod_name=""} 500
+container_fs_reads_bytes_total{container_name="app",device="/dev/sda",id="/kubepods/burstable/pod6b6035fb-e6a9-11e8-a8ed-42010a8e0004/abc",namespace="default",pod_name="api"} 100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total, ok := getCAdvisorNodeTotal(metrics["container_fs_reads_bytes_total"])
+	if !ok || total != 1500 {
+		t.Errorf("getCAdvisorNodeTotal() = %v %v, want 1500 true", total, ok)
+	}
+
+	_, ok = getCAdvisorNodeTotal(metrics["container_fs_writes_bytes_total"])
+	if ok {
+		t.Errorf("getCAdvisorNodeTotal() of missing series should not be found")
+	}
+}
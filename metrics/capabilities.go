@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+// kubeletFeaturesPaths kubelet endpoints of kubelet features
+var kubeletFeaturesPaths = map[string]string{
+	kuber.FeatureKubeletSummary:  "stats/summary",
+	kuber.FeatureKubeletCAdvisor: "metrics/cadvisor",
+}
+
+// detectKubeletFeatures checks kubelet endpoints of the first reachable
+// linux node, endpoints are assumed available if no node can be reached
+func detectKubeletFeatures(kubeletClient *KubeletClient, nodes []kuber.Node) {
+	if kubeletClient == nil || kubeletClient.kube.Capabilities == nil {
+		return
+	}
+
+	capabilities := kubeletClient.kube.Capabilities
+
+	for _, node := range nodes {
+		if isWindowsNode(node) {
+			continue
+		}
+
+		reached := false
+		for feature, path := range kubeletFeaturesPaths {
+			_, err := kubeletClient.GetBytes(&node, path)
+			switch {
+			case err == nil:
+				capabilities.Set(feature, true)
+				reached = true
+			case isKubeletNotFound(err):
+				capabilities.Set(feature, false)
+				reached = true
+			default:
+				kubeletClient.Warningf(
+					err,
+					"{kubelet} unable to check %s endpoint of node %q",
+					path,
+					node.Name,
+				)
+			}
+		}
+
+		if reached {
+			return
+		}
+	}
+}
+
+// isKubeletNotFound checks whether the kubelet doesn't serve the endpoint
+func isKubeletNotFound(err error) bool {
+	return strings.Contains(err.Error(), "404 Not Found") ||
+		strings.Contains(err.Error(), "the server could not find the requested resource")
+}
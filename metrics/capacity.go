@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+// defaultNodePool pool of nodes without pool labels and instance type
+const defaultNodePool = "default"
+
+// capacityReporter sends capacity planning snapshots aggregated from
+// metrics ticks, at most one snapshot is sent per interval
+type capacityReporter struct {
+	client   *client.Client
+	interval time.Duration
+	last     time.Time
+
+	// requests requested resources are reported unless request metrics
+	// are excluded
+	requests bool
+}
+
+func newCapacityReporter(
+	client *client.Client,
+	interval time.Duration,
+	filter *MeasurementsFilter,
+) *capacityReporter {
+	if interval <= 0 {
+		return nil
+	}
+
+	return &capacityReporter{
+		client:   client,
+		interval: interval,
+		requests: filter.Allows("cpu/request") && filter.Allows("memory/request"),
+	}
+}
+
+// observe sends the snapshot of the tick if the interval has passed since
+// the last sent snapshot
+func (reporter *capacityReporter) observe(
+	metrics []*Metrics,
+	scanner *scanner.Scanner,
+	tickTime time.Time,
+) {
+	if reporter == nil || tickTime.Sub(reporter.last) < reporter.interval {
+		return
+	}
+
+	if !reporter.client.IsPacketKindSupported(proto.PacketKindClusterCapacity) {
+		return
+	}
+
+	reporter.last = tickTime
+
+	packet := getClusterCapacity(
+		metrics,
+		scanner.GetShardNodes(),
+		scanner.GetShardApplications(),
+		reporter.requests,
+	)
+	packet.Timestamp = tickTime
+	packet.Shard = getPacketShard()
+
+	reporter.client.Pipe(client.Package{
+		Kind:        proto.PacketKindClusterCapacity,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    5,
+		Retries:     10,
+		Data:        packet,
+	})
+}
+
+// getClusterCapacity aggregates allocatable resources of nodes and requested
+// and used resources of containers per node pool and per namespace,
+// requested resources are left unset unless requests are reported
+func getClusterCapacity(
+	metrics []*Metrics,
+	nodes []kuber.Node,
+	apps []*scanner.Application,
+	requests bool,
+) proto.PacketClusterCapacity {
+	pools := map[string]*proto.PacketCapacityPoolItem{}
+	nodesPools := map[uuid.UUID]*proto.PacketCapacityPoolItem{}
+	for _, node := range nodes {
+		name := node.Pool
+		if name == "" {
+			name = defaultNodePool
+		}
+
+		pool, ok := pools[name]
+		if !ok {
+			pool = &proto.PacketCapacityPoolItem{Name: name}
+			if requests {
+				pool.Requested = &proto.CapacityResources{}
+			}
+			pools[name] = pool
+		}
+
+		pool.Nodes++
+		pool.Allocatable.CPU += int64(node.Allocatable.CPU)
+		pool.Allocatable.Memory += int64(node.Allocatable.Memory)
+
+		nodesPools[node.ID] = pool
+	}
+
+	namespaces := map[uuid.UUID]*proto.PacketCapacityNamespaceItem{}
+	for _, app := range apps {
+		namespace := &proto.PacketCapacityNamespaceItem{
+			ApplicationID: app.ID,
+			Namespace:     app.Name,
+		}
+		if requests {
+			namespace.Requested = &proto.CapacityResources{}
+		}
+		namespaces[app.ID] = namespace
+	}
+
+	for _, metric := range metrics {
+		switch metric.Type {
+		case TypeNode:
+			pool, ok := nodesPools[metric.Node]
+			if !ok {
+				continue
+			}
+
+			addCapacityUsage(&pool.Used, metric)
+
+		case TypePodContainer:
+			// NOTE: totals of services are reported without pods as well,
+			// only samples of pods are summed
+			if metric.PodName == "" {
+				continue
+			}
+
+			if pool, ok := nodesPools[metric.Node]; ok {
+				addCapacityRequest(pool.Requested, metric)
+			}
+
+			if namespace, ok := namespaces[metric.Application]; ok {
+				addCapacityRequest(namespace.Requested, metric)
+				addCapacityUsage(&namespace.Used, metric)
+			}
+		}
+	}
+
+	packet := proto.PacketClusterCapacity{
+		Pools:      []proto.PacketCapacityPoolItem{},
+		Namespaces: []proto.PacketCapacityNamespaceItem{},
+	}
+
+	for _, pool := range pools {
+		packet.Pools = append(packet.Pools, *pool)
+	}
+
+	for _, namespace := range namespaces {
+		packet.Namespaces = append(packet.Namespaces, *namespace)
+	}
+
+	sort.Slice(packet.Pools, func(i, j int) bool {
+		return packet.Pools[i].Name < packet.Pools[j].Name
+	})
+	sort.Slice(packet.Namespaces, func(i, j int) bool {
+		return packet.Namespaces[i].Namespace < packet.Namespaces[j].Namespace
+	})
+
+	return packet
+}
+
+func addCapacityUsage(resources *proto.CapacityResources, metric *Metrics) {
+	switch metric.Name {
+	case "cpu/usage_rate":
+		resources.CPU += metric.Value
+	case "memory/rss":
+		resources.Memory += metric.Value
+	}
+}
+
+func addCapacityRequest(resources *proto.CapacityResources, metric *Metrics) {
+	if resources == nil {
+		return
+	}
+
+	switch metric.Name {
+	case "cpu/request":
+		resources.CPU += metric.Value
+	case "memory/request":
+		resources.Memory += metric.Value
+	}
+}
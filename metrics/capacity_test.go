@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestGetClusterCapacity(t *testing.T) {
+	nodeA, nodeB := uuid.NewV4(), uuid.NewV4()
+	appID := uuid.NewV4()
+
+	nodes := []kuber.Node{
+		{ID: nodeA, Pool: "pool-1", Allocatable: kuber.NodeCapacity{CPU: 2000, Memory: 4096}},
+		{ID: nodeB, Allocatable: kuber.NodeCapacity{CPU: 1000, Memory: 2048}},
+	}
+
+	apps := []*scanner.Application{
+		{Entity: scanner.Entity{ID: appID, Name: "default"}},
+	}
+
+	metrics := []*Metrics{
+		{Type: TypeNode, Name: "cpu/usage_rate", Node: nodeA, Value: 700},
+		{Type: TypeNode, Name: "memory/rss", Node: nodeA, Value: 1024},
+		{Type: TypeNode, Name: "cpu/usage_rate", Node: nodeB, Value: 100},
+		{Type: TypeNode, Name: "cpu/node_allocatable", Node: nodeB, Value: 1000},
+		{Type: TypePodContainer, Name: "cpu/request", Node: nodeA, Application: appID, PodName: "api-1", Value: 500},
+		{Type: TypePodContainer, Name: "memory/request", Node: nodeA, Application: appID, PodName: "api-1", Value: 512},
+		{Type: TypePodContainer, Name: "cpu/usage_rate", Node: nodeA, Application: appID, PodName: "api-1", Value: 300},
+		{Type: TypePodContainer, Name: "memory/rss", Node: nodeA, Application: appID, PodName: "api-1", Value: 256},
+		// NOTE: totals of services are not summed
+		{Type: TypePodContainer, Name: "cpu/request", Application: appID, Value: 1500},
+	}
+
+	packet := getClusterCapacity(metrics, nodes, apps, true)
+
+	expectedPools := []proto.PacketCapacityPoolItem{
+		{
+			Name:        defaultNodePool,
+			Nodes:       1,
+			Allocatable: proto.CapacityResources{CPU: 1000, Memory: 2048},
+			Requested:   &proto.CapacityResources{},
+			Used:        proto.CapacityResources{CPU: 100},
+		},
+		{
+			Name:        "pool-1",
+			Nodes:       1,
+			Allocatable: proto.CapacityResources{CPU: 2000, Memory: 4096},
+			Requested:   &proto.CapacityResources{CPU: 500, Memory: 512},
+			Used:        proto.CapacityResources{CPU: 700, Memory: 1024},
+		},
+	}
+	if !reflect.DeepEqual(packet.Pools, expectedPools) {
+		t.Errorf("expected pools %+v, got %+v", expectedPools, packet.Pools)
+	}
+
+	expectedNamespaces := []proto.PacketCapacityNamespaceItem{
+		{
+			ApplicationID: appID,
+			Namespace:     "default",
+			Requested:     &proto.CapacityResources{CPU: 500, Memory: 512},
+			Used:          proto.CapacityResources{CPU: 300, Memory: 256},
+		},
+	}
+	if !reflect.DeepEqual(packet.Namespaces, expectedNamespaces) {
+		t.Errorf("expected namespaces %+v, got %+v", expectedNamespaces, packet.Namespaces)
+	}
+
+	// NOTE: requests are not reported if request metrics are excluded
+	packet = getClusterCapacity(metrics, nodes, apps, false)
+	for _, pool := range packet.Pools {
+		if pool.Requested != nil {
+			t.Errorf("expected unset requests of pool %s, got %+v", pool.Name, pool.Requested)
+		}
+	}
+	for _, namespace := range packet.Namespaces {
+		if namespace.Requested != nil {
+			t.Errorf(
+				"expected unset requests of namespace %s, got %+v",
+				namespace.Namespace, namespace.Requested,
+			)
+		}
+	}
+}
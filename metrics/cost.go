@@ -0,0 +1,310 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// default hourly prices of a cpu core and a gigabyte of memory, nodes
+	// without known prices are priced by them and prices of nodes are split
+	// between cpu and memory in their proportion
+	defaultCPUCoreHour  = 0.031611
+	defaultMemoryGBHour = 0.004237
+
+	// costMicros cost metrics are reported in millionths of USD per hour
+	costMicros = 1000000
+
+	gigabyte = 1024 * 1024 * 1024
+)
+
+// PricingTable hourly prices (USD) of nodes, prices of pools take precedence
+// over prices of instance types, nodes of unknown pools and types are priced
+// by cloud price hints or by prices of cores and gigabytes, e.g. on-prem
+type PricingTable struct {
+	Pools         map[string]float64 `json:"pools,omitempty"`
+	InstanceTypes map[string]float64 `json:"instance_types,omitempty"`
+	CPUCoreHour   float64            `json:"cpu_core_hour,omitempty"`
+	MemoryGBHour  float64            `json:"memory_gb_hour,omitempty"`
+}
+
+// nodePrices hourly prices of a millicore and a byte of memory of a node
+type nodePrices struct {
+	CPU    float64
+	Memory float64
+}
+
+func (table PricingTable) getNodePrices(node kuber.Node) (nodePrices, bool) {
+	cores := float64(node.Allocatable.CPU) / 1000
+	gigabytes := float64(node.Allocatable.Memory) / gigabyte
+	if cores <= 0 || gigabytes <= 0 {
+		return nodePrices{}, false
+	}
+
+	cpuCoreHour := defaultCPUCoreHour
+	if table.CPUCoreHour > 0 {
+		cpuCoreHour = table.CPUCoreHour
+	}
+
+	memoryGBHour := defaultMemoryGBHour
+	if table.MemoryGBHour > 0 {
+		memoryGBHour = table.MemoryGBHour
+	}
+
+	price, ok := table.Pools[node.Pool]
+	if !ok {
+		price, ok = table.InstanceTypes[kuber.GetInstanceType(node.Labels)]
+	}
+
+	if !ok && node.OnDemandPrice > 0 {
+		price, ok = node.OnDemandPrice, true
+	}
+
+	if !ok {
+		return nodePrices{
+			CPU:    cpuCoreHour / 1000,
+			Memory: memoryGBHour / gigabyte,
+		}, true
+	}
+
+	share := cpuCoreHour * cores / (cpuCoreHour*cores + memoryGBHour*gigabytes)
+
+	return nodePrices{
+		CPU:    price * share / float64(node.Allocatable.CPU),
+		Memory: price * (1 - share) / float64(node.Allocatable.Memory),
+	}, true
+}
+
+// costValue costs of requested and used resources
+type costValue struct {
+	application uuid.UUID
+	requests    float64
+	usage       float64
+}
+
+func (value *costValue) add(metric *Metrics, prices nodePrices) {
+	switch metric.Name {
+	case "cpu/request":
+		value.requests += float64(metric.Value) * prices.CPU
+	case "memory/request":
+		value.requests += float64(metric.Value) * prices.Memory
+	case "cpu/usage_rate":
+		value.usage += float64(metric.Value) * prices.CPU
+	case "memory/rss":
+		value.usage += float64(metric.Value) * prices.Memory
+	}
+}
+
+// getCostMetrics estimates hourly costs of requested and used resources of
+// containers per service and per namespace by prices of their nodes
+func getCostMetrics(
+	table PricingTable,
+	metrics []*Metrics,
+	nodes []kuber.Node,
+	apps []*scanner.Application,
+	tickTime time.Time,
+) []*Metrics {
+	prices := map[uuid.UUID]nodePrices{}
+	for _, node := range nodes {
+		if value, ok := table.getNodePrices(node); ok {
+			prices[node.ID] = value
+		}
+	}
+
+	services := map[uuid.UUID]*costValue{}
+	namespaces := map[uuid.UUID]*costValue{}
+
+	for _, metric := range metrics {
+		// NOTE: totals of services are reported without pods as well,
+		// only samples of pods are summed
+		if metric.Type != TypePodContainer || metric.PodName == "" {
+			continue
+		}
+
+		nodePrices, ok := prices[metric.Node]
+		if !ok {
+			continue
+		}
+
+		service, ok := services[metric.Service]
+		if !ok {
+			service = &costValue{application: metric.Application}
+			services[metric.Service] = service
+		}
+
+		namespace, ok := namespaces[metric.Application]
+		if !ok {
+			namespace = &costValue{application: metric.Application}
+			namespaces[metric.Application] = namespace
+		}
+
+		service.add(metric, nodePrices)
+		namespace.add(metric, nodePrices)
+	}
+
+	names := map[uuid.UUID]string{}
+	for _, app := range apps {
+		names[app.ID] = app.Name
+	}
+
+	result := []*Metrics{}
+	for id, value := range services {
+		for name, cost := range map[string]float64{
+			"cost/requests": value.requests,
+			"cost/usage":    value.usage,
+		} {
+			result = append(result, &Metrics{
+				Name:        name,
+				Type:        TypeService,
+				Application: value.application,
+				Service:     id,
+				Timestamp:   tickTime,
+				Value:       int64(math.Round(cost * costMicros)),
+			})
+		}
+	}
+
+	for id, value := range namespaces {
+		for name, cost := range map[string]float64{
+			"cost/requests": value.requests,
+			"cost/usage":    value.usage,
+		} {
+			result = append(result, &Metrics{
+				Name:        name,
+				Type:        TypeCluster,
+				Application: id,
+				Timestamp:   tickTime,
+				Value:       int64(math.Round(cost * costMicros)),
+
+				AdditionalTags: map[string]interface{}{
+					"namespace": names[id],
+				},
+			})
+		}
+	}
+
+	return result
+}
+
+// costEstimator emits cost metrics of every metrics tick, the pricing table
+// is read from the file if it's specified, e.g. a mounted ConfigMap, and
+// reloaded when the file changes
+type costEstimator struct {
+	logger *log.Logger
+	path   string
+
+	modTime time.Time
+	table   PricingTable
+}
+
+func newCostEstimator(
+	logger *log.Logger,
+	enabled bool,
+	path string,
+) (*costEstimator, error) {
+	if !enabled {
+		return nil, nil
+	}
+
+	estimator := &costEstimator{
+		logger: logger,
+		path:   path,
+	}
+
+	if path == "" {
+		return estimator, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, karma.Format(err, "unable to stat pricing table %s", path)
+	}
+
+	estimator.table, err = readPricingTable(path)
+	if err != nil {
+		return nil, err
+	}
+
+	estimator.modTime = stat.ModTime()
+
+	return estimator, nil
+}
+
+// getTable returns the current pricing table, the previous table is kept if
+// the file is broken
+func (estimator *costEstimator) getTable() PricingTable {
+	if estimator.path == "" {
+		return estimator.table
+	}
+
+	stat, err := os.Stat(estimator.path)
+	if err != nil {
+		estimator.logger.Errorf(err, "unable to stat pricing table, using previous table")
+		return estimator.table
+	}
+
+	if stat.ModTime().Equal(estimator.modTime) {
+		return estimator.table
+	}
+
+	table, err := readPricingTable(estimator.path)
+	if err != nil {
+		estimator.logger.Errorf(err, "using previous pricing table")
+		return estimator.table
+	}
+
+	estimator.logger.Infof(
+		karma.Describe("path", estimator.path),
+		"pricing table is reloaded",
+	)
+
+	estimator.table = table
+	estimator.modTime = stat.ModTime()
+
+	return estimator.table
+}
+
+func (estimator *costEstimator) estimate(
+	metrics []*Metrics,
+	scanner *scanner.Scanner,
+	tickTime time.Time,
+) []*Metrics {
+	if estimator == nil {
+		return nil
+	}
+
+	// NOTE: pods of namespaces of the shard run on nodes of other shards
+	// as well, so prices of all nodes are needed
+	return getCostMetrics(
+		estimator.getTable(),
+		metrics,
+		scanner.GetNodes(),
+		scanner.GetShardApplications(),
+		tickTime,
+	)
+}
+
+func readPricingTable(path string) (PricingTable, error) {
+	var table PricingTable
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return table, karma.Format(err, "unable to read pricing table %s", path)
+	}
+
+	err = json.Unmarshal(data, &table)
+	if err != nil {
+		return table, karma.Format(err, "unable to decode pricing table %s", path)
+	}
+
+	return table, nil
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestGetNodePrices(t *testing.T) {
+	table := PricingTable{
+		Pools:         map[string]float64{"pool-1": 3},
+		InstanceTypes: map[string]float64{"m5.large": 2},
+		CPUCoreHour:   1,
+		MemoryGBHour:  1,
+	}
+
+	for name, testcase := range map[string]struct {
+		node     kuber.Node
+		expected nodePrices
+	}{
+		"pool": {
+			node: kuber.Node{
+				Pool:        "pool-1",
+				Labels:      map[string]string{"beta.kubernetes.io/instance-type": "m5.large"},
+				Allocatable: kuber.NodeCapacity{CPU: 1000, Memory: gigabyte},
+			},
+			expected: nodePrices{CPU: 1.5 / 1000, Memory: 1.5 / gigabyte},
+		},
+		"instance type": {
+			node: kuber.Node{
+				Labels:      map[string]string{"beta.kubernetes.io/instance-type": "m5.large"},
+				Allocatable: kuber.NodeCapacity{CPU: 1000, Memory: gigabyte},
+			},
+			expected: nodePrices{CPU: 1.0 / 1000, Memory: 1.0 / gigabyte},
+		},
+		"price hint": {
+			node: kuber.Node{
+				OnDemandPrice: 4,
+				Allocatable:   kuber.NodeCapacity{CPU: 1000, Memory: gigabyte},
+			},
+			expected: nodePrices{CPU: 2.0 / 1000, Memory: 2.0 / gigabyte},
+		},
+		"unit prices": {
+			node: kuber.Node{
+				Allocatable: kuber.NodeCapacity{CPU: 2000, Memory: 2 * gigabyte},
+			},
+			expected: nodePrices{CPU: 1.0 / 1000, Memory: 1.0 / gigabyte},
+		},
+	} {
+		prices, ok := table.getNodePrices(testcase.node)
+		if !ok {
+			t.Errorf("%s: node is not priced", name)
+			continue
+		}
+
+		if !reflect.DeepEqual(prices, testcase.expected) {
+			t.Errorf("%s: expected prices %+v, got %+v", name, testcase.expected, prices)
+		}
+	}
+
+	if _, ok := table.getNodePrices(kuber.Node{}); ok {
+		t.Errorf("node without allocatable resources is priced")
+	}
+}
+
+func TestGetCostMetrics(t *testing.T) {
+	nodeA, nodeB := uuid.NewV4(), uuid.NewV4()
+	appID := uuid.NewV4()
+	serviceA, serviceB := uuid.NewV4(), uuid.NewV4()
+
+	table := PricingTable{
+		Pools:        map[string]float64{"pool-1": 3},
+		CPUCoreHour:  1,
+		MemoryGBHour: 1,
+	}
+
+	nodes := []kuber.Node{
+		{ID: nodeA, Allocatable: kuber.NodeCapacity{CPU: 2000, Memory: 2 * gigabyte}},
+		{ID: nodeB, Pool: "pool-1", Allocatable: kuber.NodeCapacity{CPU: 1000, Memory: gigabyte}},
+	}
+
+	apps := []*scanner.Application{
+		{Entity: scanner.Entity{ID: appID, Name: "default"}},
+	}
+
+	metrics := []*Metrics{
+		{Type: TypePodContainer, Name: "cpu/request", Node: nodeA, Application: appID, Service: serviceA, PodName: "api-1", Value: 500},
+		{Type: TypePodContainer, Name: "memory/request", Node: nodeA, Application: appID, Service: serviceA, PodName: "api-1", Value: gigabyte / 2},
+		{Type: TypePodContainer, Name: "cpu/usage_rate", Node: nodeA, Application: appID, Service: serviceA, PodName: "api-1", Value: 250},
+		{Type: TypePodContainer, Name: "cpu/request", Node: nodeB, Application: appID, Service: serviceB, PodName: "db-1", Value: 1000},
+		// NOTE: totals of services are not summed
+		{Type: TypePodContainer, Name: "cpu/request", Application: appID, Service: serviceA, Value: 1500},
+		{Type: TypeNode, Name: "cpu/usage_rate", Node: nodeA, Value: 700},
+	}
+
+	type key struct {
+		Type    string
+		Service uuid.UUID
+		Name    string
+	}
+
+	values := map[key]int64{}
+	for _, metric := range getCostMetrics(table, metrics, nodes, apps, time.Now()) {
+		if metric.Application != appID {
+			t.Errorf("unexpected application of %s: %s", metric.Name, metric.Application)
+		}
+
+		if metric.Type == TypeCluster && metric.AdditionalTags["namespace"] != "default" {
+			t.Errorf("unexpected tags of %s: %v", metric.Name, metric.AdditionalTags)
+		}
+
+		values[key{metric.Type, metric.Service, metric.Name}] = metric.Value
+	}
+
+	expected := map[key]int64{
+		{TypeService, serviceA, "cost/requests"}: 1000000,
+		{TypeService, serviceA, "cost/usage"}:    250000,
+		{TypeService, serviceB, "cost/requests"}: 1500000,
+		{TypeService, serviceB, "cost/usage"}:    0,
+		{TypeCluster, uuid.Nil, "cost/requests"}: 2500000,
+		{TypeCluster, uuid.Nil, "cost/usage"}:    250000,
+	}
+
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected cost metrics %v, got %v", expected, values)
+	}
+}
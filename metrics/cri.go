@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/reconquest/karma-go"
+)
+
+// kubelet resource metrics endpoints, v1alpha1 is served by older kubelets
+var criMetricsPaths = []string{"metrics/resource", "metrics/resource/v1alpha1"}
+
+// criValue previous cumulative cpu usage of an entity
+type criValue struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// CRI metrics source which reads stats collected by the container runtime
+// from the kubelet resource metrics endpoint, it doesn't depend on cAdvisor
+// so it works on nodes where cAdvisor container metrics are unavailable
+type CRI struct {
+	*log.Logger
+
+	kubeletClient *KubeletClient
+	scheduler     *nodesScheduler
+	dedup         *utils.LogDeduplicator
+	filter        *MeasurementsFilter
+
+	previous      map[string]criValue
+	previousMutex sync.Mutex
+}
+
+// NewCRI creates a new CRI stats source
+func NewCRI(
+	kubeletClient *KubeletClient,
+	logger *log.Logger,
+	resolution time.Duration,
+	maxConcurrency int,
+	filter *MeasurementsFilter,
+) *CRI {
+	return &CRI{
+		Logger: logger,
+
+		kubeletClient: kubeletClient,
+		scheduler:     newNodesScheduler(maxConcurrency, resolution/2),
+		dedup:         utils.NewLogDeduplicator(logger, 0, 0),
+		filter:        filter,
+
+		previous: map[string]criValue{},
+	}
+}
+
+// GetMetrics gets containers and nodes cpu and memory usage
+func (cri *CRI) GetMetrics(
+	scanner *scanner.Scanner,
+	tickTime time.Time,
+) ([]*Metrics, map[string]interface{}, error) {
+	var (
+		metrics      = []*Metrics{}
+		metricsMutex = &sync.Mutex{}
+	)
+
+	nodes := scanner.GetShardNodes()
+	errs := cri.scheduler.schedule(nodes, func(node kuber.Node) error {
+		nodeMetrics, err := cri.getNodeMetrics(scanner, node, tickTime)
+		if err != nil {
+			return err
+		}
+
+		metricsMutex.Lock()
+		defer metricsMutex.Unlock()
+
+		metrics = append(metrics, nodeMetrics...)
+
+		return nil
+	}).Do()
+
+	cri.dedup.Flush()
+
+	var foundErrors []error
+	for _, err := range errs {
+		if err != nil {
+			foundErrors = append(foundErrors, err)
+		}
+	}
+
+	if len(foundErrors) > 0 && len(foundErrors) == len(nodes) {
+		return nil, nil, karma.Format(foundErrors, "{cri} unable to get metrics")
+	}
+
+	for _, err := range foundErrors {
+		cri.Errorf(err, "{cri} unable to get node metrics")
+	}
+
+	return metrics, nil, nil
+}
+
+func (cri *CRI) getNodeMetrics(
+	scanner *scanner.Scanner,
+	node kuber.Node,
+	tickTime time.Time,
+) ([]*Metrics, error) {
+	var (
+		body []byte
+		err  error
+	)
+
+	for _, path := range criMetricsPaths {
+		body, err = cri.kubeletClient.GetBytes(&node, path)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"{cri} unable to get resource metrics from node %q",
+			node.Name,
+		)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"{cri} unable to parse resource metrics of node %q",
+			node.Name,
+		)
+	}
+
+	metrics := []*Metrics{}
+	add := func(
+		measurementType string,
+		name string,
+		applicationID, serviceID, containerID uuid.UUID,
+		pod string,
+		timestamp time.Time,
+		value int64,
+	) {
+		if !cri.filter.Allows(name) {
+			return
+		}
+
+		metrics = append(metrics, &Metrics{
+			Name:        name,
+			Type:        measurementType,
+			Node:        node.ID,
+			Application: applicationID,
+			Service:     serviceID,
+			Container:   containerID,
+			Timestamp:   timestamp,
+			Value:       value,
+			PodName:     pod,
+		})
+	}
+
+	for name, family := range families {
+		for _, metric := range family.Metric {
+			labels := makeLabels(metric)
+			value := getValue(metric)
+
+			timestamp := tickTime
+			if metric.TimestampMs != nil {
+				timestamp = time.Unix(0, metric.GetTimestampMs()*int64(time.Millisecond))
+			}
+
+			switch name {
+			case "node_cpu_usage_seconds_total":
+				add(TypeNode, "cpu/usage", uuid.Nil, uuid.Nil, uuid.Nil, "", timestamp, int64(value*1e9))
+				if rate, ok := cri.getRate(node.ID.String(), timestamp, value); ok {
+					add(TypeNode, "cpu/usage_rate", uuid.Nil, uuid.Nil, uuid.Nil, "", timestamp, rate)
+				}
+
+			case "node_memory_working_set_bytes":
+				add(TypeNode, "memory/rss", uuid.Nil, uuid.Nil, uuid.Nil, "", timestamp, int64(value))
+
+			case "container_cpu_usage_seconds_total", "container_memory_working_set_bytes":
+				namespace, pod, container := labels["namespace"], labels["pod"], labels["container"]
+				if pod == "" {
+					// NOTE: v1alpha1 endpoint uses pod_name and container_name
+					pod, container = labels["pod_name"], labels["container_name"]
+				}
+
+				if scanner.IsEphemeralContainer(namespace, pod, container) {
+					continue
+				}
+
+				applicationID, serviceID, identified, ok := scanner.FindContainer(
+					namespace, pod, container,
+				)
+				if !ok {
+					cri.dedup.Warningf(
+						karma.Describe("namespace", namespace).
+							Describe("pod_name", pod).
+							Describe("container_name", container).
+							Reason("not found"),
+						"{cri} can't find container for container %s:%s:%s",
+						namespace, pod, container,
+					)
+					continue
+				}
+
+				if name == "container_memory_working_set_bytes" {
+					add(TypePodContainer, "memory/rss", applicationID, serviceID, identified.ID, pod, timestamp, int64(value))
+					continue
+				}
+
+				add(TypePodContainer, "cpu/usage", applicationID, serviceID, identified.ID, pod, timestamp, int64(value*1e9))
+
+				key := fmt.Sprintf("%s:%s:%s", namespace, pod, container)
+				if rate, ok := cri.getRate(key, timestamp, value); ok {
+					add(TypePodContainer, "cpu/usage_rate", applicationID, serviceID, identified.ID, pod, timestamp, rate)
+				}
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// getRate calculates cpu usage rate in millicores from cumulative usage in
+// seconds
+func (cri *CRI) getRate(key string, timestamp time.Time, value float64) (int64, bool) {
+	cri.previousMutex.Lock()
+	defer cri.previousMutex.Unlock()
+
+	previous, ok := cri.previous[key]
+	cri.previous[key] = criValue{Timestamp: timestamp, Value: value}
+
+	if !ok || !timestamp.After(previous.Timestamp) || value < previous.Value {
+		return 0, false
+	}
+
+	seconds := timestamp.Sub(previous.Timestamp).Seconds()
+	return int64((value - previous.Value) / seconds * 1000), true
+}
+
+// purgeDeletedPods drops previous values of containers of deleted pods, so
+// values of pods which are gone don't pile up
+func (cri *CRI) purgeDeletedPods(deletion scanner.Deletion) {
+	if len(deletion.Pods) == 0 {
+		return
+	}
+
+	pods := map[string]struct{}{}
+	for _, pod := range deletion.Pods {
+		pods[pod.Namespace+":"+pod.Name] = struct{}{}
+	}
+
+	cri.previousMutex.Lock()
+	defer cri.previousMutex.Unlock()
+
+	for key := range cri.previous {
+		// NOTE: keys of containers are formatted as namespace:pod:container,
+		// keys of nodes are their ids
+		index := strings.LastIndex(key, ":")
+		if index < 0 {
+			continue
+		}
+
+		if _, ok := pods[key[:index]]; ok {
+			delete(cri.previous, key)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/reconquest/karma-go"
+	"github.com/ryanuber/go-glob"
+)
+
+// MeasurementsFilter filters measurements by name using glob patterns, a
+// measurement is collected if it matches any include pattern, or there are
+// no include patterns, and matches no exclude pattern
+type MeasurementsFilter struct {
+	include []string
+	exclude []string
+}
+
+// NewMeasurementsFilter creates a filter of include and exclude patterns,
+// e.g. network/* or */errors_rate
+func NewMeasurementsFilter(include, exclude []string) (*MeasurementsFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if pattern == "" {
+			return nil, karma.Format(nil, "empty measurement pattern")
+		}
+	}
+
+	return &MeasurementsFilter{
+		include: include,
+		exclude: exclude,
+	}, nil
+}
+
+// Allows checks whether the measurement should be collected, nil filter
+// allows all measurements
+func (filter *MeasurementsFilter) Allows(measurement string) bool {
+	if filter == nil {
+		return true
+	}
+
+	for _, pattern := range filter.exclude {
+		if glob.Glob(pattern, measurement) {
+			return false
+		}
+	}
+
+	if len(filter.include) == 0 {
+		return true
+	}
+
+	for _, pattern := range filter.include {
+		if glob.Glob(pattern, measurement) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestMeasurementsFilter(t *testing.T) {
+	var empty *MeasurementsFilter
+	if !empty.Allows("cpu/usage_rate") {
+		t.Errorf("nil filter should allow all measurements")
+	}
+
+	filter, err := NewMeasurementsFilter(
+		[]string{"cpu/*", "network/*"},
+		[]string{"network/*errors*"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for measurement, expected := range map[string]bool{
+		"cpu/usage_rate":         true,
+		"network/rx_rate":        true,
+		"network/rx_errors_rate": false,
+		"filesystem/usage":       false,
+		"memory/rss":             false,
+	} {
+		if allowed := filter.Allows(measurement); allowed != expected {
+			t.Errorf(
+				"measurement %q: expected allowed %v, got %v",
+				measurement, expected, allowed,
+			)
+		}
+	}
+
+	filter, err = NewMeasurementsFilter(nil, []string{"filesystem/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !filter.Allows("memory/rss") || filter.Allows("filesystem/usage") {
+		t.Errorf("exclude only filter should drop excluded measurements only")
+	}
+
+	_, err = NewMeasurementsFilter([]string{""}, nil)
+	if err == nil {
+		t.Errorf("expected error for empty pattern")
+	}
+}
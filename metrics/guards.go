@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+const (
+	// maxCapacityFactor usage samples exceeding node capacity by this factor
+	// are rejected
+	maxCapacityFactor = 10
+	// maxClockSkew samples timestamped further in the future are rejected
+	maxClockSkew = time.Minute
+
+	// RejectReasonNegative negative value
+	RejectReasonNegative = "negative"
+	// RejectReasonAboveCapacity usage far above node capacity
+	RejectReasonAboveCapacity = "above_capacity"
+	// RejectReasonFutureTimestamp timestamp in the future
+	RejectReasonFutureTimestamp = "future_timestamp"
+)
+
+// capacityBoundMetrics usage metrics which can't exceed node capacity
+var capacityBoundMetrics = map[string]func(capacity kuber.NodeCapacity) int64{
+	"cpu/usage_rate": func(capacity kuber.NodeCapacity) int64 {
+		return int64(capacity.CPU)
+	},
+	"memory/rss": func(capacity kuber.NodeCapacity) int64 {
+		return int64(capacity.Memory)
+	},
+}
+
+var (
+	rejectedSamples      = map[string]int64{}
+	rejectedSamplesMutex sync.Mutex
+)
+
+// getRejectReason checks whether the sample is physically impossible, it
+// returns an empty string for valid samples
+func getRejectReason(
+	metric *Metrics,
+	capacities map[uuid.UUID]kuber.NodeCapacity,
+	now time.Time,
+) string {
+	if metric.Value < 0 {
+		return RejectReasonNegative
+	}
+
+	if metric.Timestamp.After(now.Add(maxClockSkew)) {
+		return RejectReasonFutureTimestamp
+	}
+
+	getCapacity, ok := capacityBoundMetrics[metric.Name]
+	if !ok {
+		return ""
+	}
+
+	capacity, ok := capacities[metric.Node]
+	if !ok {
+		return ""
+	}
+
+	// NOTE: nodes with unknown capacity are not checked
+	limit := getCapacity(capacity)
+	if limit > 0 && metric.Value > limit*maxCapacityFactor {
+		return RejectReasonAboveCapacity
+	}
+
+	return ""
+}
+
+// filterAnomalies drops physically impossible samples, it returns accepted
+// samples and count of rejected samples by reason
+func filterAnomalies(
+	metrics []*Metrics,
+	nodes []kuber.Node,
+	now time.Time,
+) ([]*Metrics, map[string]int64) {
+	capacities := map[uuid.UUID]kuber.NodeCapacity{}
+	for _, node := range nodes {
+		capacities[node.ID] = node.Capacity
+	}
+
+	accepted := make([]*Metrics, 0, len(metrics))
+	rejected := map[string]int64{}
+
+	for _, metric := range metrics {
+		reason := getRejectReason(metric, capacities, now)
+		if reason != "" {
+			rejected[reason]++
+			continue
+		}
+
+		accepted = append(accepted, metric)
+	}
+
+	rejectedSamplesMutex.Lock()
+	defer rejectedSamplesMutex.Unlock()
+
+	for reason, count := range rejected {
+		rejectedSamples[reason] += count
+	}
+
+	return accepted, rejected
+}
+
+// getRejectedSamplesState returns total count of rejected samples by reason
+func getRejectedSamplesState() interface{} {
+	rejectedSamplesMutex.Lock()
+	defer rejectedSamplesMutex.Unlock()
+
+	state := map[string]int64{}
+	for reason, count := range rejectedSamples {
+		state[reason] = count
+	}
+
+	return state
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestFilterAnomalies(t *testing.T) {
+	node := kuber.Node{
+		ID: uuid.NewV4(),
+		Capacity: kuber.NodeCapacity{
+			CPU:    2000,
+			Memory: 1024,
+		},
+	}
+	now := time.Now()
+
+	metrics := []*Metrics{
+		{Name: "cpu/usage_rate", Node: node.ID, Timestamp: now, Value: 1500},
+		{Name: "cpu/usage_rate", Node: node.ID, Timestamp: now, Value: 30000},
+		{Name: "memory/rss", Node: node.ID, Timestamp: now, Value: -1},
+		{Name: "memory/rss", Node: node.ID, Timestamp: now.Add(time.Hour), Value: 512},
+		{Name: "memory/rss", Node: uuid.NewV4(), Timestamp: now, Value: 1 << 40},
+		{Name: "network/rx", Node: node.ID, Timestamp: now, Value: 1 << 40},
+	}
+
+	accepted, rejected := filterAnomalies(metrics, []kuber.Node{node}, now)
+	if len(accepted) != 3 {
+		t.Errorf("filterAnomalies() accepted %d samples, want 3", len(accepted))
+	}
+
+	for reason, want := range map[string]int64{
+		RejectReasonNegative:        1,
+		RejectReasonAboveCapacity:   1,
+		RejectReasonFutureTimestamp: 1,
+	} {
+		if rejected[reason] != want {
+			t.Errorf(
+				"filterAnomalies() rejected %d samples as %s, want %d",
+				rejected[reason], reason, want,
+			)
+		}
+	}
+}
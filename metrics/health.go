@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+)
+
+// maxMissedTicks count of failed ticks after which metrics collection is
+// unhealthy
+const maxMissedTicks = 3
+
+// collectionHealth tracks results of metrics collection ticks
+type collectionHealth struct {
+	interval time.Duration
+
+	mutex       sync.Mutex
+	startedAt   time.Time
+	lastSuccess time.Time
+	lastError   error
+}
+
+func newCollectionHealth(interval time.Duration) *collectionHealth {
+	return &collectionHealth{
+		interval:  interval,
+		startedAt: time.Now().UTC(),
+	}
+}
+
+// done records result of a collection tick
+func (health *collectionHealth) done(err error) {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+
+	health.lastError = err
+	if err == nil {
+		health.lastSuccess = time.Now().UTC()
+	}
+}
+
+// get reports health of metrics collection, collection is unhealthy if no
+// tick succeeded for several intervals
+func (health *collectionHealth) get() proto.PacketComponentHealth {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+
+	since := health.lastSuccess
+	if since.IsZero() {
+		since = health.startedAt
+	}
+
+	result := proto.PacketComponentHealth{
+		Healthy:     time.Since(since) <= health.interval*maxMissedTicks,
+		LastSuccess: health.lastSuccess,
+	}
+
+	if health.lastError != nil {
+		result.Message = health.lastError.Error()
+	}
+
+	return result
+}
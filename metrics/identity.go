@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/uuid-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ImageDigestTag digest of the image the container runs
+	ImageDigestTag = "image_digest"
+	// RuntimeIDTag ID of the container assigned by the container runtime
+	RuntimeIDTag = "runtime_id"
+)
+
+// containerIdentity identity of a running container instance
+type containerIdentity struct {
+	ImageDigest string
+	RuntimeID   string
+}
+
+type containerIdentityKey struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// getContainersIdentities returns identities of running containers of pods
+func getContainersIdentities(
+	pods []kv1.Pod,
+) map[containerIdentityKey]containerIdentity {
+	identities := map[containerIdentityKey]containerIdentity{}
+	for _, pod := range pods {
+		statuses := [][]kv1.ContainerStatus{
+			pod.Status.InitContainerStatuses,
+			pod.Status.ContainerStatuses,
+		}
+
+		for _, statuses := range statuses {
+			for _, status := range statuses {
+				if status.ContainerID == "" {
+					continue
+				}
+
+				key := containerIdentityKey{
+					Namespace: pod.Namespace,
+					Pod:       pod.Name,
+					Container: status.Name,
+				}
+
+				identities[key] = containerIdentity{
+					ImageDigest: kuber.GetImageDigest(status.ImageID),
+					RuntimeID:   kuber.TrimRuntimeScheme(status.ContainerID),
+				}
+			}
+		}
+	}
+
+	return identities
+}
+
+// tagContainersIdentities adds image digest and runtime ID tags to metrics
+// of containers, so metrics of different image versions of the same
+// container can be told apart
+func tagContainersIdentities(
+	metrics []*Metrics,
+	apps []*scanner.Application,
+	pods []kv1.Pod,
+) {
+	identities := getContainersIdentities(pods)
+	if len(identities) == 0 {
+		return
+	}
+
+	containers := map[uuid.UUID]containerIdentityKey{}
+	for _, app := range apps {
+		for _, service := range app.Services {
+			for _, container := range service.Containers {
+				containers[container.ID] = containerIdentityKey{
+					Namespace: app.Name,
+					Container: container.Name,
+				}
+			}
+		}
+	}
+
+	for _, metric := range metrics {
+		if metric.Container == uuid.Nil || metric.PodName == "" {
+			continue
+		}
+
+		key, ok := containers[metric.Container]
+		if !ok {
+			continue
+		}
+
+		key.Pod = metric.PodName
+
+		identity, ok := identities[key]
+		if !ok {
+			continue
+		}
+
+		if metric.AdditionalTags == nil {
+			metric.AdditionalTags = map[string]interface{}{}
+		}
+
+		if identity.ImageDigest != "" {
+			metric.AdditionalTags[ImageDigestTag] = identity.ImageDigest
+		}
+
+		metric.AdditionalTags[RuntimeIDTag] = identity.RuntimeID
+	}
+}
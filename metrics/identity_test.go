@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/uuid-go"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTagContainersIdentities(t *testing.T) {
+	containerID := uuid.NewV4()
+
+	apps := []*scanner.Application{
+		{
+			Entity: scanner.Entity{Name: "default"},
+			Services: []*scanner.Service{
+				{
+					Containers: []*scanner.Container{
+						{Entity: scanner.Entity{ID: containerID, Name: "api"}},
+					},
+				},
+			},
+		},
+	}
+
+	pods := []kv1.Pod{
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default", Name: "api-1"},
+			Status: kv1.PodStatus{
+				ContainerStatuses: []kv1.ContainerStatus{
+					{
+						Name:        "api",
+						ImageID:     "docker-pullable://api@sha256:one",
+						ContainerID: "docker://first",
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default", Name: "api-2"},
+			Status: kv1.PodStatus{
+				ContainerStatuses: []kv1.ContainerStatus{
+					{
+						Name:        "api",
+						ImageID:     "docker-pullable://api@sha256:two",
+						ContainerID: "containerd://second",
+					},
+				},
+			},
+		},
+	}
+
+	metrics := []*Metrics{
+		{Container: containerID, PodName: "api-1"},
+		{Container: containerID, PodName: "api-2"},
+		{Container: containerID},
+	}
+
+	tagContainersIdentities(metrics, apps, pods)
+
+	for i, expected := range []map[string]interface{}{
+		{ImageDigestTag: "sha256:one", RuntimeIDTag: "first"},
+		{ImageDigestTag: "sha256:two", RuntimeIDTag: "second"},
+		nil,
+	} {
+		tags := metrics[i].AdditionalTags
+		if len(tags) != len(expected) {
+			t.Fatalf("metric %d: expected tags %v, got %v", i, expected, tags)
+		}
+		for key, value := range expected {
+			if tags[key] != value {
+				t.Fatalf("metric %d: expected tags %v, got %v", i, expected, tags)
+			}
+		}
+	}
+}
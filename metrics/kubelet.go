@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
 	"github.com/MagalixCorp/magalix-agent/scanner"
-	"github.com/MagalixTechnologies/alltogether-go"
+	"github.com/MagalixCorp/magalix-agent/shard"
+	"github.com/MagalixCorp/magalix-agent/utils"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/MagalixTechnologies/uuid-go"
 	"github.com/reconquest/karma-go"
@@ -33,11 +35,13 @@ type KubeletSummaryContainer struct {
 	CPU struct {
 		Time                 time.Time
 		UsageCoreNanoSeconds int64
+		UsageNanoCores       int64
 	}
 
 	Memory struct {
-		Time     time.Time
-		RSSBytes int64
+		Time            time.Time
+		RSSBytes        int64
+		WorkingSetBytes int64
 	}
 
 	RootFS struct {
@@ -52,11 +56,13 @@ type KubeletSummary struct {
 		CPU struct {
 			Time                 time.Time
 			UsageCoreNanoSeconds int64
+			UsageNanoCores       int64
 		}
 
 		Memory struct {
-			Time     time.Time
-			RSSBytes int64
+			Time            time.Time
+			RSSBytes        int64
+			WorkingSetBytes int64
 		}
 
 		FS struct {
@@ -79,6 +85,19 @@ type KubeletSummary struct {
 			Namespace string
 		}
 
+		// CPU and Memory pod cgroup usage, it includes the usage of the
+		// sandbox which isn't accounted to any container
+		CPU struct {
+			Time           time.Time
+			UsageNanoCores int64
+		}
+
+		Memory struct {
+			Time            time.Time
+			RSSBytes        int64
+			WorkingSetBytes int64
+		}
+
 		Containers []KubeletSummaryContainer
 		Network    struct {
 			Time     time.Time
@@ -112,31 +131,75 @@ type Kubelet struct {
 	resolution    time.Duration
 	previous      map[string]KubeletValue
 	previousMutex *sync.Mutex
+	previousPath  string
 	timeouts      kubeletTimeouts
 	kubeletClient *KubeletClient
+	dedup         *utils.LogDeduplicator
+	scheduler     *nodesScheduler
+	breaker       *nodesBreaker
+	smoothing     rateSmoothing
+	filter        *MeasurementsFilter
 
 	optInAnalysisData bool
 }
 
+// KubeletOptions options of kubelet metrics source
+type KubeletOptions struct {
+	// Resolution interval of metrics collection
+	Resolution time.Duration
+	// BackoffSleep sleep between retries of failed kubelet requests,
+	// multiplied by the retry number
+	BackoffSleep      time.Duration
+	BackoffMaxRetries int
+	// MaxConcurrency max count of nodes scraped at once
+	MaxConcurrency int
+	// BreakerFailures consecutive failed scrapes of a node after which the
+	// node is skipped for BreakerSkipTicks ticks, zero disables skipping
+	BreakerFailures  int
+	BreakerSkipTicks int
+	// Smoothing smoothing of rate metrics formatted as family[:factor]
+	Smoothing []string
+	// Filter filter of reported measurements, nil allows all measurements
+	Filter            *MeasurementsFilter
+	OptInAnalysisData bool
+}
+
 // NewKubelet returns new kubelet
 func NewKubelet(
 	kubeletClient *KubeletClient,
 	log *log.Logger,
-	resolution time.Duration,
-	timeouts kubeletTimeouts,
-	optInAnalysisData bool,
+	options KubeletOptions,
 ) (*Kubelet, error) {
+	smoothing, err := parseRateSmoothing(options.Smoothing)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := options.Resolution
+
 	kubelet := &Kubelet{
 		Logger: log,
 
 		kubeletClient: kubeletClient,
+		dedup:         utils.NewLogDeduplicator(log, 0, 0),
+		// NOTE: scrapes are spread over the first half of the interval to
+		// leave enough time for sending metrics before the next tick
+		scheduler: newNodesScheduler(options.MaxConcurrency, resolution/2),
+		breaker:   newNodesBreaker(options.BreakerFailures, options.BreakerSkipTicks),
+		smoothing: smoothing,
+		filter:    options.Filter,
 
 		resolution:    resolution,
 		previous:      map[string]KubeletValue{},
 		previousMutex: &sync.Mutex{},
-		timeouts:      timeouts,
+		timeouts: kubeletTimeouts{
+			backoff: backOff{
+				sleep:      options.BackoffSleep,
+				maxRetries: options.BackoffMaxRetries,
+			},
+		},
 
-		optInAnalysisData: optInAnalysisData,
+		optInAnalysisData: options.OptInAnalysisData,
 	}
 
 	return kubelet, nil
@@ -213,6 +276,10 @@ func (kubelet *Kubelet) GetMetrics(
 		timestamp time.Time,
 		value int64,
 	) {
+		if !kubelet.filter.Allows(measurement) {
+			return
+		}
+
 		metricsMutex.Lock()
 		defer metricsMutex.Unlock()
 		if timestamp.Equal(time.Time{}) {
@@ -250,6 +317,10 @@ func (kubelet *Kubelet) GetMetrics(
 		value int64,
 		additionalTags map[string]interface{},
 	) {
+		if !kubelet.filter.Allows(measurement) {
+			return
+		}
+
 		metricsMutex.Lock()
 		defer metricsMutex.Unlock()
 		metrics = append(metrics, &Metrics{
@@ -267,6 +338,50 @@ func (kubelet *Kubelet) GetMetrics(
 		})
 	}
 
+	// addSmoothedRate adds exponentially smoothed rate in addition to the
+	// last interval rate if smoothing is enabled for the measurement family
+	addSmoothedRate := func(
+		key string,
+		measurementType string,
+		measurement string,
+		nodeID uuid.UUID,
+		applicationID uuid.UUID,
+		serviceID uuid.UUID,
+		containerID uuid.UUID,
+		pod string,
+		timestamp time.Time,
+		rate int64,
+	) {
+		factor, ok := kubelet.smoothing.getFactor(measurement)
+		if !ok {
+			return
+		}
+
+		key = key + ":smoothed"
+
+		smoothed := rate
+		if previous, err := kubelet.getPreviousValue(key); err == nil {
+			smoothed = smoothRate(previous.Value, rate, factor)
+		}
+
+		kubelet.updatePreviousValue(key, &KubeletValue{
+			Timestamp: timestamp,
+			Value:     smoothed,
+		})
+
+		addMetricValue(
+			measurementType,
+			measurement+"_smoothed",
+			nodeID,
+			applicationID,
+			serviceID,
+			containerID,
+			pod,
+			timestamp,
+			smoothed,
+		)
+	}
+
 	addMetricValueRate := func(
 		measurementType string,
 		parentKey string,
@@ -300,7 +415,7 @@ func (kubelet *Kubelet) GetMetrics(
 		})
 
 		if err != nil {
-			kubelet.Warningf(
+			kubelet.dedup.Warningf(
 				karma.Describe("metric", measurement).
 					Describe("type", measurementType).
 					Describe("timestamp", timestamp).
@@ -320,7 +435,76 @@ func (kubelet *Kubelet) GetMetrics(
 			timestamp,
 			rate,
 		)
+		addSmoothedRate(
+			key,
+			measurementType,
+			measurement,
+			nodeID,
+			applicationID,
+			serviceID,
+			containerID,
+			pod,
+			timestamp,
+			rate,
+		)
+	}
 
+	// windows nodes may report only the instant cpu usage in nanocores
+	// instead of the cumulative usage, so it is used as the rate directly
+	addCPUUsageRate := func(
+		measurementType string,
+		parentKey string,
+		entityKey string,
+		nodeID uuid.UUID,
+		applicationID uuid.UUID,
+		serviceID uuid.UUID,
+		containerID uuid.UUID,
+		pod string,
+		timestamp time.Time,
+		usageCoreNanoSeconds int64,
+		usageNanoCores int64,
+	) {
+		if usageCoreNanoSeconds == 0 && usageNanoCores > 0 {
+			addMetricValue(
+				measurementType,
+				"cpu/usage_rate",
+				nodeID,
+				applicationID,
+				serviceID,
+				containerID,
+				pod,
+				timestamp,
+				usageNanoCores/1e6, // cpu_rate is in millicore
+			)
+			addSmoothedRate(
+				getKey(measurementType, parentKey, entityKey, "cpu/usage_rate"),
+				measurementType,
+				"cpu/usage_rate",
+				nodeID,
+				applicationID,
+				serviceID,
+				containerID,
+				pod,
+				timestamp,
+				usageNanoCores/1e6,
+			)
+			return
+		}
+
+		addMetricValueRate(
+			measurementType,
+			parentKey,
+			entityKey,
+			"cpu/usage_rate",
+			nodeID,
+			applicationID,
+			serviceID,
+			containerID,
+			pod,
+			timestamp,
+			usageCoreNanoSeconds,
+			1000, // cpu_rate is in millicore
+		)
 	}
 
 	addRawResponse := func(nodeID uuid.UUID, data interface{}) {
@@ -330,7 +514,7 @@ func (kubelet *Kubelet) GetMetrics(
 	}
 
 	// scanner scans the nodes every 1m, so assume latest value is up to date
-	nodes := scanner.GetNodes()
+	nodes := scanner.GetShardNodes()
 	nodesScanTime := scanner.NodesLastScanTime()
 
 	addMetricValue(
@@ -379,6 +563,52 @@ func (kubelet *Kubelet) GetMetrics(
 		)
 	}
 
+	// NOTE: pods are not known until the first applications scan
+	appsScanTime := scanner.AppsLastScanTime()
+	if !appsScanTime.IsZero() {
+		for key, count := range getPodsStateCounts(filterShardPods(scanner.GetPods())) {
+			tags := map[string]interface{}{
+				"state": key.State,
+			}
+			if key.Namespace != "" {
+				tags["namespace"] = key.Namespace
+			}
+
+			addMetricValueWithTags(
+				TypeCluster,
+				key.Metric,
+				uuid.Nil,
+				uuid.Nil,
+				uuid.Nil,
+				uuid.Nil,
+				"",
+				appsScanTime,
+				count,
+				tags,
+			)
+		}
+
+		for _, app := range scanner.GetShardApplications() {
+			for key, value := range getQuotasValues(app.ResourceQuotas) {
+				addMetricValueWithTags(
+					TypeCluster,
+					key.Metric,
+					uuid.Nil,
+					uuid.Nil,
+					uuid.Nil,
+					uuid.Nil,
+					"",
+					appsScanTime,
+					value,
+					map[string]interface{}{
+						"namespace": key.Namespace,
+						"quota":     key.Quota,
+					},
+				)
+			}
+		}
+	}
+
 	for _, node := range nodes {
 		for _, measurement := range []struct {
 			Name  string
@@ -389,6 +619,10 @@ func (kubelet *Kubelet) GetMetrics(
 			{"cpu/node_allocatable", nodesScanTime, int64(node.Allocatable.CPU)},
 			{"memory/node_capacity", nodesScanTime, int64(node.Capacity.Memory)},
 			{"memory/node_allocatable", nodesScanTime, int64(node.Allocatable.Memory)},
+			{"node/memory_pressure", nodesScanTime, boolToInt64(node.Conditions.MemoryPressure)},
+			{"node/disk_pressure", nodesScanTime, boolToInt64(node.Conditions.DiskPressure)},
+			{"node/pid_pressure", nodesScanTime, boolToInt64(node.Conditions.PIDPressure)},
+			{"node/not_ready", nodesScanTime, boolToInt64(node.Conditions.NotReady)},
 		} {
 			addMetricValue(
 				TypeNode,
@@ -404,8 +638,20 @@ func (kubelet *Kubelet) GetMetrics(
 		}
 	}
 
-	pr, err := alltogether.NewConcurrentProcessor(
-		nodes,
+	kubelet.breaker.forget(nodes)
+	kubelet.kubeletClient.forgetNodes(nodes)
+
+	scrapedNodes := kubelet.breaker.allow(nodes)
+	if len(scrapedNodes) < len(nodes) {
+		kubelet.Warningf(
+			karma.Describe("open", kubelet.breaker.getOpen()),
+			"{kubelet} skipping %d nodes failed repeatedly",
+			len(nodes)-len(scrapedNodes),
+		)
+	}
+
+	scrapes := kubelet.scheduler.schedule(
+		scrapedNodes,
 		func(node kuber.Node) error {
 			kubelet.Infof(
 				nil,
@@ -468,7 +714,11 @@ func (kubelet *Kubelet) GetMetrics(
 				Value int64
 			}{
 				{"cpu/usage", summary.Node.CPU.Time, summary.Node.CPU.UsageCoreNanoSeconds},
-				{"memory/rss", summary.Node.Memory.Time, summary.Node.Memory.RSSBytes},
+				{"memory/rss", summary.Node.Memory.Time, getMemoryUsage(
+					node,
+					summary.Node.Memory.RSSBytes,
+					summary.Node.Memory.WorkingSetBytes,
+				)},
 				{"filesystem/usage", summary.Node.FS.Time, summary.Node.FS.UsedBytes},
 				{"filesystem/node_capacity", summary.Node.FS.Time, summary.Node.FS.CapacityBytes},
 				{"filesystem/node_allocatable", summary.Node.FS.Time, summary.Node.FS.CapacityBytes},
@@ -496,7 +746,6 @@ func (kubelet *Kubelet) GetMetrics(
 				Value      int64
 				Multiplier int64
 			}{
-				{"cpu/usage_rate", summary.Node.CPU.Time, summary.Node.CPU.UsageCoreNanoSeconds, 1000},
 				{"network/tx_rate", summary.Node.Network.Time, summary.Node.Network.TxBytes, 1e9},
 				{"network/rx_rate", summary.Node.Network.Time, summary.Node.Network.RxBytes, 1e9},
 				{"network/tx_errors_rate", summary.Node.Network.Time, summary.Node.Network.TxErrors, 1e9},
@@ -519,6 +768,20 @@ func (kubelet *Kubelet) GetMetrics(
 				)
 			}
 
+			addCPUUsageRate(
+				TypeNode,
+				"",
+				node.ID.String(),
+				node.ID,
+				uuid.Nil,
+				uuid.Nil,
+				uuid.Nil,
+				"",
+				summary.Node.CPU.Time,
+				summary.Node.CPU.UsageCoreNanoSeconds,
+				summary.Node.CPU.UsageNanoCores,
+			)
+
 			throttleMetrics := map[uuid.UUID]map[string]*containerMetricStore{}
 
 			for _, pod := range summary.Pods {
@@ -527,7 +790,7 @@ func (kubelet *Kubelet) GetMetrics(
 				)
 
 				if !ok {
-					kubelet.Logger.Warningf(
+					kubelet.dedup.Warningf(
 						karma.Describe("namespace", pod.PodRef.Namespace).
 							Describe("pod_name", pod.PodRef.Name).
 							Reason("not found"),
@@ -609,13 +872,21 @@ func (kubelet *Kubelet) GetMetrics(
 				}
 
 				for _, container := range podContainers {
+					if scanner.IsEphemeralContainer(
+						pod.PodRef.Namespace,
+						pod.PodRef.Name,
+						container.Name,
+					) {
+						continue
+					}
+
 					applicationID, serviceID, identifiedContainer, ok := scanner.FindContainer(
 						pod.PodRef.Namespace,
 						pod.PodRef.Name,
 						container.Name,
 					)
 					if !ok {
-						kubelet.Logger.Warningf(
+						kubelet.dedup.Warningf(
 							karma.Describe("namespace", pod.PodRef.Namespace).
 								Describe("pod_name", pod.PodRef.Name).
 								Describe("container_name", container.Name).
@@ -632,7 +903,11 @@ func (kubelet *Kubelet) GetMetrics(
 						Value int64
 					}{
 						{"cpu/usage", container.CPU.Time, container.CPU.UsageCoreNanoSeconds},
-						{"memory/rss", container.Memory.Time, container.Memory.RSSBytes},
+						{"memory/rss", container.Memory.Time, getMemoryUsage(
+							node,
+							container.Memory.RSSBytes,
+							container.Memory.WorkingSetBytes,
+						)},
 						{"filesystem/usage", container.RootFS.Time, container.RootFS.UsedBytes},
 
 						{"cpu/request", container.CPU.Time, identifiedContainer.Resources.SpecResourceRequirements.Requests.Cpu().MilliValue()},
@@ -654,11 +929,10 @@ func (kubelet *Kubelet) GetMetrics(
 						)
 					}
 
-					addMetricValueRate(
+					addCPUUsageRate(
 						TypePodContainer,
 						fmt.Sprintf("%s:%s", pod.PodRef.Namespace, pod.PodRef.Name),
 						container.Name,
-						"cpu/usage_rate",
 						node.ID,
 						applicationID,
 						serviceID,
@@ -666,7 +940,7 @@ func (kubelet *Kubelet) GetMetrics(
 						pod.PodRef.Name,
 						container.CPU.Time,
 						container.CPU.UsageCoreNanoSeconds,
-						1000, // cpu_rate is in millicore
+						container.CPU.UsageNanoCores,
 					)
 
 					throttleMetrics[identifiedContainer.ID] = map[string]*containerMetricStore{}
@@ -674,9 +948,102 @@ func (kubelet *Kubelet) GetMetrics(
 					throttleMetrics[identifiedContainer.ID]["container_cpu_cfs_throttled/seconds_total"] = defaultMetricStore(applicationID, serviceID, identifiedContainer, pod.PodRef.Namespace, pod.PodRef.Name, container)
 					throttleMetrics[identifiedContainer.ID]["container_cpu_cfs_throttled/periods_total"] = defaultMetricStore(applicationID, serviceID, identifiedContainer, pod.PodRef.Namespace, pod.PodRef.Name, container)
 				}
+
+				// NOTE: kubelets prior to pod-level stats don't report pods
+				// usage, overhead is unknown for them
+				if pod.CPU.UsageNanoCores > 0 {
+					overhead := pod.CPU.UsageNanoCores
+					for _, container := range podContainers {
+						overhead -= container.CPU.UsageNanoCores
+					}
+
+					addMetricValue(
+						TypePod,
+						"cpu/overhead_rate",
+						node.ID,
+						applicationID,
+						serviceID,
+						uuid.Nil,
+						pod.PodRef.Name,
+						pod.CPU.Time,
+						getSandboxOverhead(overhead)/1e6, // cpu_rate is in millicore
+					)
+				}
+
+				if !pod.Memory.Time.IsZero() {
+					overhead := getMemoryUsage(
+						node,
+						pod.Memory.RSSBytes,
+						pod.Memory.WorkingSetBytes,
+					)
+					for _, container := range podContainers {
+						overhead -= getMemoryUsage(
+							node,
+							container.Memory.RSSBytes,
+							container.Memory.WorkingSetBytes,
+						)
+					}
+
+					addMetricValue(
+						TypePod,
+						"memory/overhead",
+						node.ID,
+						applicationID,
+						serviceID,
+						uuid.Nil,
+						pod.PodRef.Name,
+						pod.Memory.Time,
+						getSandboxOverhead(overhead),
+					)
+				}
+
+				// NOTE: completed init containers aren't reported in the
+				// summary, their requests and limits still reserve resources
+				// while pods are initialized
+				for _, initContainer := range scanner.FindInitContainers(
+					pod.PodRef.Namespace,
+					pod.PodRef.Name,
+				) {
+					if _, ok := podContainers[initContainer.Name]; ok {
+						continue
+					}
+
+					spec := initContainer.Resources.SpecResourceRequirements
+					for _, measurement := range []struct {
+						Name  string
+						Value int64
+					}{
+						{"cpu/request", spec.Requests.Cpu().MilliValue()},
+						{"cpu/limit", spec.Limits.Cpu().MilliValue()},
+
+						{"memory/request", spec.Requests.Memory().Value()},
+						{"memory/limit", spec.Limits.Memory().Value()},
+					} {
+						addMetricValue(
+							TypePodContainer,
+							measurement.Name,
+							node.ID,
+							applicationID,
+							serviceID,
+							initContainer.ID,
+							pod.PodRef.Name,
+							tickTime,
+							measurement.Value,
+						)
+					}
+				}
 			}
 
 			err = kubelet.withBackoff(func() error {
+				// NOTE: kubelet on windows nodes doesn't expose cAdvisor
+				if isWindowsNode(node) ||
+					!kubelet.kubeletClient.kube.Capabilities.IsSupported(
+						kuber.FeatureKubeletCAdvisor,
+					) {
+					cadvisorResponse = []byte{}
+					return nil
+				}
+
 				cadvisorResponse, err = kubelet.kubeletClient.GetBytes(
 					&node,
 					"metrics/cadvisor",
@@ -739,6 +1106,102 @@ func (kubelet *Kubelet) GetMetrics(
 				}
 			}
 
+			// NOTE: disk io is reported by cAdvisor only, io of the node is
+			// io of the root cgroup
+			for _, metric := range []struct {
+				Name string
+				Ref  string
+			}{
+				{"disk/read_bytes", "container_fs_reads_bytes_total"},
+				{"disk/write_bytes", "container_fs_writes_bytes_total"},
+				{"disk/reads", "container_fs_reads_total"},
+				{"disk/writes", "container_fs_writes_total"},
+			} {
+				value, ok := getCAdvisorNodeTotal(cadvisor[metric.Ref])
+				if !ok {
+					continue
+				}
+
+				addMetricValue(
+					TypeNode,
+					metric.Name,
+					node.ID,
+					uuid.Nil,
+					uuid.Nil,
+					uuid.Nil,
+					"",
+					now,
+					int64(value),
+				)
+
+				addMetricValueRate(
+					TypeNode,
+					"",
+					node.ID.String(),
+					metric.Name+"_rate",
+					node.ID,
+					uuid.Nil,
+					uuid.Nil,
+					uuid.Nil,
+					"",
+					now,
+					int64(value),
+					1e9,
+				)
+			}
+
+			// NOTE: containers share network namespace of the pod, runtimes
+			// which account traffic per container report it in cAdvisor
+			// only, the same applies to disk io of containers
+			for _, metric := range []struct {
+				Name string
+				Ref  string
+			}{
+				{"network/rx", "container_network_receive_bytes_total"},
+				{"network/tx", "container_network_transmit_bytes_total"},
+				{"disk/read_bytes", "container_fs_reads_bytes_total"},
+				{"disk/write_bytes", "container_fs_writes_bytes_total"},
+				{"disk/reads", "container_fs_reads_total"},
+				{"disk/writes", "container_fs_writes_total"},
+			} {
+				for key, value := range getCAdvisorContainersTotals(cadvisor[metric.Ref]) {
+					applicationID, serviceID, containerID, podName, ok := scanner.FindContainerByPodUIDContainerName(
+						key.PodUID,
+						key.ContainerName,
+					)
+					if !ok {
+						continue
+					}
+
+					addMetricValue(
+						TypePodContainer,
+						metric.Name,
+						node.ID,
+						applicationID,
+						serviceID,
+						containerID,
+						podName,
+						now,
+						int64(value),
+					)
+
+					addMetricValueRate(
+						TypePodContainer,
+						fmt.Sprintf("%s:%s", key.Namespace, podName),
+						key.ContainerName,
+						metric.Name+"_rate",
+						node.ID,
+						applicationID,
+						serviceID,
+						containerID,
+						podName,
+						now,
+						int64(value),
+						1e9,
+					)
+				}
+			}
+
 			for _, storedMetrics := range throttleMetrics {
 				for metricName, storedMetric := range storedMetrics {
 					addMetricValue(
@@ -785,6 +1248,12 @@ func (kubelet *Kubelet) GetMetrics(
 	apps := scanner.GetApplications()
 	scanTime := scanner.AppsLastScanTime()
 	for _, app := range apps {
+		// NOTE: all applications are needed to identify containers of
+		// scraped nodes but resources are reported by owners of namespaces
+		if !shard.OwnsNamespace(app.Name) {
+			continue
+		}
+
 		for _, service := range app.Services {
 			for _, container := range service.Containers {
 				for _, measurement := range []struct {
@@ -815,12 +1284,9 @@ func (kubelet *Kubelet) GetMetrics(
 		}
 	}
 
-	if err != nil {
-		panic(err)
-	}
-
 	// Start concurrent getter of details:
-	errs := pr.Do()
+	errs := scrapes.Do()
+	kubelet.breaker.observe(scrapedNodes, errs)
 	if !errs.AllNil() {
 		// Note: if one node fails we fail safe to allow other node metrics to flow.
 		// Note: In cases where pods are replicated across nodes,
@@ -838,10 +1304,25 @@ func (kubelet *Kubelet) GetMetrics(
 		}
 	}
 
+	kubelet.dedup.Flush()
+	kubelet.persistPreviousValues()
+
+	metrics = append(metrics, rollupServices(metrics, tickTime)...)
+
+	tagContainersIdentities(metrics, apps, scanner.GetPods())
+
 	result := []*Metrics{}
 
+	environments := getServicesEnvironments(apps)
+
 	var context *karma.Context
 	for _, metrics := range metrics {
+		if environment, ok := environments[metrics.Service]; ok {
+			if metrics.AdditionalTags == nil {
+				metrics.AdditionalTags = map[string]interface{}{}
+			}
+			metrics.AdditionalTags[EnvironmentTag] = environment
+		}
 
 		/*
 			context = context.Describe(
@@ -881,6 +1362,36 @@ func (kubelet *Kubelet) GetMetrics(
 	return result, rawResponses, nil
 }
 
+// getServicesEnvironments returns environments of classified services
+func getServicesEnvironments(apps []*scanner.Application) map[uuid.UUID]string {
+	environments := map[uuid.UUID]string{}
+	for _, app := range apps {
+		for _, service := range app.Services {
+			if service.Environment != "" {
+				environments[service.ID] = service.Environment
+			}
+		}
+	}
+	return environments
+}
+
+// getSandboxOverhead returns the pod usage which isn't accounted to its
+// containers, pod and containers stats are collected at slightly different
+// moments so the difference can be negative for pods without overhead
+func getSandboxOverhead(difference int64) int64 {
+	if difference < 0 {
+		return 0
+	}
+	return difference
+}
+
+func boolToInt64(value bool) int64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
 func defaultMetricStore(
 	applicationID uuid.UUID, serviceID uuid.UUID,
 	identifiedContainer *scanner.Container, namespace, podName string,
@@ -898,6 +1409,83 @@ func defaultMetricStore(
 	}
 }
 
+// GetState returns internal state of the kubelet source for debugging
+func (kubelet *Kubelet) GetState() interface{} {
+	kubelet.previousMutex.Lock()
+	defer kubelet.previousMutex.Unlock()
+
+	return map[string]interface{}{
+		"previous_values": len(kubelet.previous),
+		"max_concurrency": kubelet.scheduler.maxConcurrency,
+		"open_circuits":   kubelet.breaker.getOpen(),
+	}
+}
+
+// getHealth reports nodes skipped due to consecutive failed scrapes,
+// scrapes are unhealthy only if all nodes are skipped
+func (kubelet *Kubelet) getHealth() proto.PacketComponentHealth {
+	open := kubelet.breaker.getOpen()
+
+	health := proto.PacketComponentHealth{
+		Healthy: !kubelet.breaker.isTripped(),
+		Values: map[string]int64{
+			"open_circuits": int64(len(open)),
+		},
+	}
+
+	if len(open) > 0 {
+		health.Message = "skipped nodes: " + strings.Join(open, ", ")
+	}
+
+	return health
+}
+
+// purgeDeletedPods drops previous values of deleted pods and their
+// containers right away instead of waiting for them to expire
+func (kubelet *Kubelet) purgeDeletedPods(deletion scanner.Deletion) {
+	if len(deletion.Pods) == 0 {
+		return
+	}
+
+	pods := map[string]struct{}{}
+	for _, pod := range deletion.Pods {
+		pods[pod.Namespace+":"+pod.Name] = struct{}{}
+	}
+
+	kubelet.previousMutex.Lock()
+	defer kubelet.previousMutex.Unlock()
+
+	purged := 0
+	for key := range kubelet.previous {
+		if _, ok := pods[getPreviousValuePod(key)]; ok {
+			delete(kubelet.previous, key)
+			purged++
+		}
+	}
+
+	kubelet.Debugf(
+		karma.Describe("pods", len(deletion.Pods)),
+		"{kubelet} purged %d previous values of deleted pods",
+		purged,
+	)
+}
+
+// getPreviousValuePod returns namespace:pod part of the previous value key
+// of pods and containers formatted as type-measurement:namespace:pod[:...]
+func getPreviousValuePod(key string) string {
+	if !strings.HasPrefix(key, TypePod+"-") &&
+		!strings.HasPrefix(key, TypePodContainer+"-") {
+		return ""
+	}
+
+	parts := strings.SplitN(key, ":", 4)
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return parts[1] + ":" + parts[2]
+}
+
 func (kubelet *Kubelet) collectGarbage() {
 	for key, previous := range kubelet.previous {
 		if time.Now().Sub(previous.Timestamp) > time.Hour {
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/MagalixCorp/magalix-agent/kuber"
 	"github.com/MagalixCorp/magalix-agent/scanner"
@@ -18,6 +19,7 @@ import (
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/reconquest/karma-go"
 	"golang.org/x/sync/errgroup"
+	kv1 "k8s.io/api/core/v1"
 )
 
 const noPortHelp = `
@@ -30,10 +32,34 @@ Please verify that one of the following is correct:
 See this for more info https://kubernetes.io/docs/reference/command-line-tools-reference/kubelet-authentication-authorization/#kubelet-authorization
 You can just rerun the connect cluster command you got from Magalix console to apply those rules. If this doesn't help please contact Magalix support.
 
-2. the cluster has the http readonly port enabled and set to the default 10255 or the custom port is passed correctly to the agent container as argument '--kubelet-port=<your-port>'
+2. the kubelet secure port (the node daemon endpoint, 10250 by default) is reachable from the agent pod and the agent service account is allowed to access ["nodes/stats", "nodes/metrics"]. Pass '--kubelet-secure' to the agent container to always use it.
+
+3. the cluster has the http readonly port enabled and set to the default 10255 or the custom port is passed correctly to the agent container as argument '--kubelet-port=<your-port>'
 Note that http port is deprecated in k8s v11 and above, so please make sure to use the api-server method above for best compatibility.
 `
 
+const (
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+
+	defaultKubeletSecurePort = 10250
+
+	// kubeletKeepAlive interval of keep-alive probes of connections to
+	// kubelets
+	kubeletKeepAlive = 30 * time.Second
+)
+
+const (
+	// kubeletAccessAuto tries api-server proxy first then direct access,
+	// requests directly to nodes fallback to api-server proxy
+	kubeletAccessAuto = "auto"
+	// kubeletAccessProxy uses only api-server proxy, for clusters where
+	// nodes aren't reachable from pods
+	kubeletAccessProxy = "proxy"
+	// kubeletAccessDirect uses only direct connections to nodes
+	kubeletAccessDirect = "direct"
+)
+
 func joinUrl(address, path string) string {
 	u, _ := url.Parse(address)
 	u.Path = path
@@ -88,9 +114,40 @@ type KubeletClient struct {
 	kube       *kuber.Kube
 	restClient *rest.RESTClient
 
+	// httpClient client of direct requests to kubelets with connections
+	// kept alive per node, requests through api-server proxy use the pool
+	// of restClient
+	httpClient *http.Client
+	// apiServerHost host of api-server proxy requests
+	apiServerHost string
+
 	httpPort string
+	secure   bool
+	access   string
+
+	// addressTypes types of node addresses kubelets are accessed directly
+	// by, in order of priority
+	addressTypes []string
+
+	// requestTimeout timeout of a request including reading of the
+	// response, zero means timeout of the kubernetes client
+	requestTimeout time.Duration
+
+	// base addresses of nodes kubelets with detected scheme
+	nodesAddresses      map[string]nodeAddress
+	nodesAddressesMutex *sync.Mutex
+
+	resolver *nodesResolver
 
 	getNodeUrl NodePathGetter
+	// getNodeFallbackUrl used if request to getNodeUrl failed
+	getNodeFallbackUrl NodePathGetter
+}
+
+// nodeAddress base address of node kubelet detected for the endpoint
+type nodeAddress struct {
+	address  string
+	endpoint nodeEndpoint
 }
 
 func (client *KubeletClient) init() (err error) {
@@ -138,9 +195,13 @@ func (client *KubeletClient) discoverNodesAddress() (
 			} else {
 				client.Infof(
 					karma.
-						Describe("port", client.httpPort),
-					"using direct kubelet api through http port",
+						Describe("port", client.httpPort).
+						Describe("secure", client.secure),
+					"using direct kubelet api",
 				)
+				if client.access == kubeletAccessAuto {
+					client.getNodeFallbackUrl = client.getNodeProxyUrl
+				}
 			}
 			nodeGet = fn
 		}
@@ -148,6 +209,7 @@ func (client *KubeletClient) discoverNodesAddress() (
 	}
 
 	processNode := func(n kuber.Node) {
+		n = client.preferAddress(n)
 		group.Go(func() error {
 			getAddr, isApiServer, err := client.discoverNodeAddress(&n)
 			if err == nil {
@@ -187,16 +249,20 @@ func (client *KubeletClient) discoverNodeAddress(
 		Describe("node", node.Name).
 		Describe("ip", node.IP)
 
-	*isApiServer = true
-	nodeGet, err = client.tryApiServerProxy(ctx, node)
-	if err == nil {
-		return
+	if client.access != kubeletAccessDirect {
+		*isApiServer = true
+		nodeGet, err = client.tryApiServerProxy(ctx, node)
+		if err == nil {
+			return
+		}
 	}
 
-	*isApiServer = false
-	nodeGet, err = client.tryDirectAccess(ctx, node)
-	if err == nil {
-		return
+	if client.access != kubeletAccessProxy {
+		*isApiServer = false
+		nodeGet, err = client.tryDirectAccess(ctx, node)
+		if err == nil {
+			return
+		}
 	}
 
 	isApiServer = nil
@@ -208,20 +274,7 @@ func (client *KubeletClient) tryApiServerProxy(
 	ctx *karma.Context,
 	node *kuber.Node,
 ) (NodePathGetter, error) {
-	getNodeUrl := func(node *kuber.Node, path string) string {
-		subResources := []string{"proxy"}
-		subResources = append(subResources, strings.Split(path, "/")...)
-
-		return client.kube.Clientset.
-			CoreV1().
-			RESTClient().
-			Get().
-			Resource("nodes").
-			Name(node.Name).
-			SubResource(subResources...).
-			URL().
-			String()
-	}
+	getNodeUrl := client.getNodeProxyUrl
 	err := client.testNodeAccess(ctx, node, getNodeUrl)
 	if err != nil {
 		// can't use api-server proxy
@@ -237,22 +290,39 @@ func (client *KubeletClient) tryApiServerProxy(
 	return getNodeUrl, nil
 }
 
+// getNodeProxyUrl returns url of kubelet path through api-server node proxy,
+// e.g. /api/v1/nodes/<node>/proxy/stats/summary
+func (client *KubeletClient) getNodeProxyUrl(node *kuber.Node, path string) string {
+	subResources := []string{"proxy"}
+	subResources = append(subResources, strings.Split(path, "/")...)
+
+	return client.kube.Clientset.
+		CoreV1().
+		RESTClient().
+		Get().
+		Resource("nodes").
+		Name(node.Name).
+		SubResource(subResources...).
+		URL().
+		String()
+}
+
 func (client *KubeletClient) tryDirectAccess(
 	ctx *karma.Context,
 	node *kuber.Node,
 ) (NodePathGetter, error) {
 	getNodeUrl := func(node *kuber.Node, path_ string) string {
-		base := fmt.Sprintf("http://%s:%v", node.IP, client.httpPort)
-		return joinUrl(base, path_)
+		return joinUrl(client.getNodeAddress(node), path_)
 	}
 	err := client.testNodeAccess(ctx, node, getNodeUrl)
 	if err != nil {
 		client.Warning(
 			ctx.
 				Describe("port", client.httpPort).
+				Describe("secure", client.secure).
 				Format(
 					err,
-					"can't use direct kubelet http port.",
+					"can't use direct kubelet access.",
 				),
 		)
 		return nil, err
@@ -260,19 +330,151 @@ func (client *KubeletClient) tryDirectAccess(
 	return getNodeUrl, nil
 }
 
+// getNodeAddress returns base address of node kubelet. The scheme is detected
+// once per node endpoint: the secure port is tried first, then the read-only
+// http port unless secure access is forced.
+func (client *KubeletClient) getNodeAddress(node *kuber.Node) string {
+	client.nodesAddressesMutex.Lock()
+	cached, ok := client.nodesAddresses[node.Name]
+	client.nodesAddressesMutex.Unlock()
+	if ok && cached.endpoint == getNodeEndpoint(*node) {
+		return cached.address
+	}
+
+	address, detected := client.detectNodeAddress(node)
+	if detected {
+		client.nodesAddressesMutex.Lock()
+		client.nodesAddresses[node.Name] = nodeAddress{
+			address:  address,
+			endpoint: getNodeEndpoint(*node),
+		}
+		client.nodesAddressesMutex.Unlock()
+	}
+
+	return address
+}
+
+// resolveNode retrieves the actual kubelet endpoint of the node
+func (client *KubeletClient) resolveNode(name string) (nodeEndpoint, error) {
+	node, err := client.kube.GetNode(name)
+	if err != nil {
+		return nodeEndpoint{}, err
+	}
+
+	nodes := kuber.GetNodes([]kv1.Node{*node})
+
+	return getNodeEndpoint(client.preferAddress(nodes[0])), nil
+}
+
+// preferAddress returns the node with the address of the most preferred
+// type used as its ip
+func (client *KubeletClient) preferAddress(node kuber.Node) kuber.Node {
+	node.IP = kuber.GetNodeAddress(node, client.addressTypes)
+	return node
+}
+
+// observeNode re-resolves the node after repeated failures of requests to
+// its kubelet, cached address of the node is invalidated if its endpoint
+// has changed
+func (client *KubeletClient) observeNode(node *kuber.Node, requestErr error) {
+	endpoint, err := client.resolver.observe(*node, requestErr)
+
+	ctx := karma.
+		Describe("node", node.Name).
+		Describe("ip", node.IP)
+
+	if err != nil {
+		client.Warningf(ctx.Reason(err), "unable to re-resolve kubelet endpoint")
+		return
+	}
+
+	if endpoint == nil {
+		return
+	}
+
+	client.nodesAddressesMutex.Lock()
+	delete(client.nodesAddresses, node.Name)
+	client.nodesAddressesMutex.Unlock()
+
+	client.Infof(
+		ctx.
+			Describe("resolved ip", endpoint.IP).
+			Describe("resolved port", endpoint.Port),
+		"kubelet endpoint of node has changed",
+	)
+}
+
+// forgetNodes drops state of nodes which aren't in the cluster anymore
+func (client *KubeletClient) forgetNodes(nodes []kuber.Node) {
+	client.resolver.forget(nodes)
+
+	existing := map[string]struct{}{}
+	for _, node := range nodes {
+		existing[node.Name] = struct{}{}
+	}
+
+	client.nodesAddressesMutex.Lock()
+	defer client.nodesAddressesMutex.Unlock()
+
+	for name := range client.nodesAddresses {
+		if _, ok := existing[name]; !ok {
+			delete(client.nodesAddresses, name)
+		}
+	}
+}
+
+func (client *KubeletClient) detectNodeAddress(
+	node *kuber.Node,
+) (address string, detected bool) {
+	port := node.KubeletPort
+	if port == 0 {
+		port = defaultKubeletSecurePort
+	}
+
+	secureAddress := fmt.Sprintf("%s://%s:%v", schemeHTTPS, node.IP, port)
+	if client.secure {
+		return secureAddress, true
+	}
+
+	ctx := karma.
+		Describe("node", node.Name).
+		Describe("ip", node.IP)
+
+	err := client.testUrl(ctx, joinUrl(secureAddress, "stats/summary"))
+	if err == nil {
+		client.Debugf(ctx, "using kubelet secure port %v", port)
+		return secureAddress, true
+	}
+
+	httpAddress := fmt.Sprintf("%s://%s:%v", schemeHTTP, node.IP, client.httpPort)
+	err = client.testUrl(ctx, joinUrl(httpAddress, "stats/summary"))
+	if err == nil {
+		client.Debugf(ctx, "using kubelet read-only port %v", client.httpPort)
+		return httpAddress, true
+	}
+
+	return httpAddress, false
+}
+
 func (client *KubeletClient) testNodeAccess(
 	ctx *karma.Context, node *kuber.Node, getNodeUrl NodePathGetter,
 ) error {
 	ctx = ctx.
 		Describe("path", "stats/summary")
 
-	url_ := getNodeUrl(node, "stats/summary")
+	return client.testUrl(ctx, getNodeUrl(node, "stats/summary"))
+}
+
+func (client *KubeletClient) testUrl(ctx *karma.Context, url_ string) error {
 	resp, err := client.get(url_)
 	if err != nil {
 		return ctx.Format(err, "node access test failed")
 	}
 
 	b, err := readResponseBytes(resp, client.Logger)
+	if err != nil {
+		return ctx.Format(err, "node access test failed")
+	}
 
 	var response interface{}
 	err = parseJSON(b, &response)
@@ -282,13 +484,50 @@ func (client *KubeletClient) testNodeAccess(
 	return nil
 }
 
+// cancelBody cancels the request once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
+
 func (client *KubeletClient) get(url_ string) (*http.Response, error) {
 	ctx := karma.Describe("url", url_)
-	resp, err := client.restClient.Client.Get(url_)
+
+	request, err := http.NewRequest(http.MethodGet, url_, nil)
 	if err != nil {
 		return nil, ctx.Reason(err)
 	}
+
+	cancel := context.CancelFunc(func() {})
+	if client.requestTimeout > 0 {
+		var timeout context.Context
+		timeout, cancel = context.WithTimeout(
+			context.Background(),
+			client.requestTimeout,
+		)
+		request = request.WithContext(timeout)
+	}
+
+	httpClient := client.httpClient
+	if request.URL.Host == client.apiServerHost {
+		httpClient = client.restClient.Client
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		cancel()
+		return nil, ctx.Reason(err)
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, ctx.Format(
 			"GET request returned non OK status %s",
 			resp.Status,
@@ -301,8 +540,20 @@ func (client *KubeletClient) Get(
 	node *kuber.Node,
 	path string,
 ) (*http.Response, error) {
+	resolved := client.resolver.apply(client.preferAddress(*node))
+	node = &resolved
+
 	url_ := client.getNodeUrl(node, path)
-	return client.get(url_)
+	resp, err := client.get(url_)
+	client.observeNode(node, err)
+	if err != nil && client.getNodeFallbackUrl != nil {
+		client.Warningf(
+			karma.Describe("node", node.Name).Reason(err),
+			"unable to access kubelet directly, falling back to api-server proxy",
+		)
+		return client.get(client.getNodeFallbackUrl(node, path))
+	}
+	return resp, err
 }
 
 func (client *KubeletClient) GetBytes(
@@ -326,17 +577,56 @@ func (client *KubeletClient) GetJson(
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	return parseJSONStream(resp.Body, &response)
 }
 
+// KubeletClientOptions options of kubelet client
+type KubeletClientOptions struct {
+	// Port port of kubelets used if accessed directly
+	Port string
+	// Secure use https if accessed directly
+	Secure bool
+	// Access access mode of kubelets: auto, proxy or direct
+	Access string
+	// RequestTimeout timeout of a request including reading of the
+	// response, zero means timeout of the kubernetes client
+	RequestTimeout time.Duration
+	// ResolveFailures consecutive failed requests to a node which trigger
+	// re-resolution of its kubelet endpoint, zero disables re-resolution
+	ResolveFailures int
+	// ResolveInterval min interval between re-resolutions of a node
+	ResolveInterval time.Duration
+	// IdleConnections idle connections kept per kubelet for reuse by next
+	// requests
+	IdleConnections int
+	// IdleTimeout idle connections to kubelets are closed after the timeout
+	IdleTimeout time.Duration
+	// AddressTypes types of node addresses used for direct access in order
+	// of priority, the internal ip is used if a node has none of them
+	AddressTypes []string
+}
+
+// NewKubeletClient creates kubelet client, addresses of kubelets are
+// discovered using nodes of the scanner
 func NewKubeletClient(
 	logger *log.Logger,
 	scanner *scanner.Scanner,
 	kube *kuber.Kube,
-	args map[string]interface{},
+	options KubeletClientOptions,
 ) (*KubeletClient, error) {
 
+	switch access := options.Access; access {
+	case kubeletAccessAuto, kubeletAccessProxy, kubeletAccessDirect:
+	default:
+		return nil, karma.Format(
+			nil,
+			"unsupported kubelet access mode: %s",
+			access,
+		)
+	}
+
 	restClient, ok := kube.Clientset.RESTClient().(*rest.RESTClient)
 	if !ok {
 		return nil, karma.Format(
@@ -345,6 +635,15 @@ func NewKubeletClient(
 		)
 	}
 
+	httpClient, err := kube.NewDirectHTTPClient(kuber.TransportOptions{
+		MaxIdleConnsPerHost: options.IdleConnections,
+		IdleConnTimeout:     options.IdleTimeout,
+		KeepAlive:           kubeletKeepAlive,
+	})
+	if err != nil {
+		return nil, karma.Format(err, "unable to create kubelet http client")
+	}
+
 	client := &KubeletClient{
 		Logger: logger,
 
@@ -353,10 +652,28 @@ func NewKubeletClient(
 		kube:       kube,
 		restClient: restClient,
 
-		httpPort: args["--kubelet-port"].(string),
+		httpClient:    httpClient,
+		apiServerHost: restClient.Get().URL().Host,
+
+		httpPort: options.Port,
+		secure:   options.Secure,
+		access:   options.Access,
+
+		addressTypes: options.AddressTypes,
+
+		requestTimeout: options.RequestTimeout,
+
+		nodesAddresses:      map[string]nodeAddress{},
+		nodesAddressesMutex: &sync.Mutex{},
 	}
 
-	err := client.init()
+	client.resolver = newNodesResolver(
+		options.ResolveFailures,
+		options.ResolveInterval,
+		client.resolveNode,
+	)
+
+	err = client.init()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+const nodeOSWindows = "windows"
+
+func isWindowsNode(node kuber.Node) bool {
+	return node.OS == nodeOSWindows
+}
+
+// getMemoryUsage returns memory usage reported as memory/rss, windows nodes
+// don't report rss so the working set is used instead
+func getMemoryUsage(node kuber.Node, rssBytes, workingSetBytes int64) int64 {
+	if isWindowsNode(node) && rssBytes == 0 {
+		return workingSetBytes
+	}
+
+	return rssBytes
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/reconquest/karma-go"
+)
+
+// MetricsMapping translation table applied to outgoing metrics, it renames
+// metrics and tags and sends metrics under additional alias names
+type MetricsMapping struct {
+	// Rename new names of metrics by their original names
+	Rename map[string]string `json:"rename"`
+	// Aliases additional names of metrics by their original names, metrics
+	// are sent under all their aliases in addition to their names
+	Aliases map[string][]string `json:"aliases"`
+	// Tags new names of tags by their original names
+	Tags map[string]string `json:"tags"`
+}
+
+// LoadMetricsMapping reads metrics mapping from a JSON file, e.g. mounted
+// from a ConfigMap
+func LoadMetricsMapping(path string) (*MetricsMapping, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, karma.Format(err, "unable to read metrics mapping %s", path)
+	}
+
+	var mapping MetricsMapping
+	err = json.Unmarshal(data, &mapping)
+	if err != nil {
+		return nil, karma.Format(err, "unable to decode metrics mapping %s", path)
+	}
+
+	return &mapping, nil
+}
+
+func (mapping *MetricsMapping) getNames(name string) []string {
+	names := []string{name}
+	if renamed, ok := mapping.Rename[name]; ok {
+		names[0] = renamed
+	}
+
+	return append(names, mapping.Aliases[name]...)
+}
+
+func (mapping *MetricsMapping) getTag(tag string) string {
+	if renamed, ok := mapping.Tags[tag]; ok {
+		return renamed
+	}
+	return tag
+}
+
+// apply translates metrics, nil mapping keeps metrics as is
+func (mapping *MetricsMapping) apply(metrics []*Metrics) []*Metrics {
+	if mapping == nil {
+		return metrics
+	}
+
+	result := make([]*Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		var tags map[string]interface{}
+		if metric.AdditionalTags != nil {
+			tags = map[string]interface{}{}
+			for tag, value := range metric.AdditionalTags {
+				tags[mapping.getTag(tag)] = value
+			}
+		}
+
+		for _, name := range mapping.getNames(metric.Name) {
+			mapped := *metric
+			mapped.Name = name
+			mapped.AdditionalTags = tags
+
+			result = append(result, &mapped)
+		}
+	}
+
+	return result
+}
+
+// applyFamilies translates metrics families, nil mapping keeps families as is
+func (mapping *MetricsMapping) applyFamilies(
+	families map[string]*MetricFamily,
+) map[string]*MetricFamily {
+	if mapping == nil {
+		return families
+	}
+
+	result := map[string]*MetricFamily{}
+	for _, family := range families {
+		tags := make([]string, len(family.Tags))
+		for i, tag := range family.Tags {
+			tags[i] = mapping.getTag(tag)
+		}
+
+		values := make([]*MetricValue, len(family.Values))
+		for i, value := range family.Values {
+			mapped := *value
+			if value.Tags != nil {
+				mapped.Tags = map[string]string{}
+				for tag, tagValue := range value.Tags {
+					mapped.Tags[mapping.getTag(tag)] = tagValue
+				}
+			}
+
+			values[i] = &mapped
+		}
+
+		for _, name := range mapping.getNames(family.Name) {
+			mapped := *family
+			mapped.Name = name
+			mapped.Tags = tags
+			mapped.Values = values
+
+			result = appendFamily(result, &mapped)
+		}
+	}
+
+	return result
+}
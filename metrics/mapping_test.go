@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestMetricsMappingApply(t *testing.T) {
+	mapping := &MetricsMapping{
+		Rename:  map[string]string{"cpu/usage_rate": "cpu/rate"},
+		Aliases: map[string][]string{"memory/rss": {"memory/usage"}},
+		Tags:    map[string]string{"instance_group": "node_group"},
+	}
+
+	metrics := mapping.apply([]*Metrics{
+		{Name: "cpu/usage_rate", Value: 1},
+		{Name: "memory/rss", Value: 2},
+		{
+			Name:           "nodes/count",
+			Value:          3,
+			AdditionalTags: map[string]interface{}{"instance_group": "m5.large"},
+		},
+	})
+
+	names := []string{}
+	for _, metric := range metrics {
+		names = append(names, metric.Name)
+	}
+
+	want := []string{"cpu/rate", "memory/rss", "memory/usage", "nodes/count"}
+	if len(names) != len(want) {
+		t.Fatalf("apply() returned %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("apply() returned %v, want %v", names, want)
+			break
+		}
+	}
+
+	if metrics[3].AdditionalTags["node_group"] != "m5.large" {
+		t.Errorf("apply() tags = %v, want node_group tag", metrics[3].AdditionalTags)
+	}
+
+	var nilMapping *MetricsMapping
+	if result := nilMapping.apply(metrics); len(result) != len(metrics) {
+		t.Errorf("nil mapping apply() changed metrics")
+	}
+}
@@ -7,14 +7,16 @@ import (
 	"github.com/MagalixCorp/magalix-agent/client"
 	"github.com/MagalixCorp/magalix-agent/kuber"
 	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/replay"
 	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/shard"
+	"github.com/MagalixCorp/magalix-agent/status"
+	"github.com/MagalixCorp/magalix-agent/tracing"
 	"github.com/MagalixCorp/magalix-agent/utils"
 	"github.com/MagalixTechnologies/uuid-go"
 	"github.com/reconquest/karma-go"
 )
 
-const limit = 1000
-
 type Entities struct {
 	Node        *uuid.UUID
 	Application *uuid.UUID
@@ -84,6 +86,8 @@ const (
 	TypeCluster = "cluster"
 	// TypeNode node
 	TypeNode = "node"
+	// TypeService service, aggregated from its pods containers
+	TypeService = "service"
 	// TypePod pod
 	TypePod = "pod"
 	// TypePodContainer container in a pod
@@ -92,6 +96,19 @@ const (
 	TypeSysContainer = "sys_container"
 )
 
+// MetricsChunk a part of metrics collected at the same tick
+type MetricsChunk struct {
+	Batch     uuid.UUID
+	Timestamp time.Time
+	Sequence  int
+	Total     int
+
+	Metrics []*Metrics
+
+	// span span of the tick the chunk was collected at
+	span *tracing.Span
+}
+
 // Deprecated: watchMetrics is deprecated and will be removed in future releases.
 // Please consider using watchMetricsProm instead.
 func watchMetrics(
@@ -99,21 +116,78 @@ func watchMetrics(
 	source MetricsSource,
 	scanner *scanner.Scanner,
 	interval time.Duration,
+	batchSize int,
+	mapping *MetricsMapping,
+	sinks map[string]Sink,
+	capacity *capacityReporter,
+	costs *costEstimator,
 ) {
-	metricsPipe := make(chan []*Metrics)
-	go sendMetrics(client, metricsPipe)
+	metricsPipe := make(chan *MetricsChunk)
+	sent := make(chan struct{})
+	go func() {
+		sendMetrics(client, sinks, metricsPipe)
+		close(sent)
+	}()
 	defer close(metricsPipe)
 
-	ticker := utils.NewTicker("metrics", interval, func(tickTime time.Time) {
+	health := newCollectionHealth(interval)
+	client.RegisterHealthCheck("metrics", health.get)
+
+	ticker := utils.NewTicker(client.Logger, "metrics", interval, func(tickTime time.Time) {
+		span := tracing.Start("metrics/tick")
+		defer span.End()
+
+		scrape := span.Child("metrics/scrape")
 		metrics, raw, err := source.GetMetrics(scanner, tickTime)
+		health.done(err)
+
+		scrape.SetAttribute("metrics", len(metrics))
+		scrape.SetError(err)
+		scrape.End()
 
 		if err != nil {
 			client.Errorf(err, "unable to retrieve metrics from sink")
 		}
-		client.Infof(karma.Describe("timestamp", metrics[0].Timestamp), "finished getting metrics")
+		client.Infof(karma.Describe("timestamp", tickTime), "finished getting metrics")
+
+		metrics, rejected := filterAnomalies(metrics, scanner.GetNodes(), time.Now())
+		if len(rejected) > 0 {
+			client.Warningf(
+				karma.
+					Describe("timestamp", tickTime).
+					Describe("rejected", rejected),
+				"rejected physically impossible metrics samples",
+			)
+
+			for reason, count := range rejected {
+				metrics = append(metrics, &Metrics{
+					Name:      "metrics/rejected_samples",
+					Type:      TypeCluster,
+					Timestamp: tickTime,
+					Value:     count,
+
+					AdditionalTags: map[string]interface{}{
+						"reason": reason,
+					},
+				})
+			}
+		}
+
+		storeLastSamples(metrics)
+
+		capacity.observe(metrics, scanner, tickTime)
+
+		metrics = append(metrics, costs.estimate(metrics, scanner, tickTime)...)
+
+		metrics = mapping.apply(metrics)
+
+		replay.Transition(replay.TransitionMetrics, metrics)
+
+		span.SetAttribute("metrics", len(metrics))
 
-		for i := 0; i < len(metrics); i += limit {
-			metricsPipe <- metrics[i:min(i+limit, len(metrics))]
+		for _, chunk := range chunkMetrics(metrics, tickTime, batchSize) {
+			chunk.span = span
+			metricsPipe <- chunk
 		}
 
 		if raw != nil {
@@ -122,6 +196,13 @@ func watchMetrics(
 			})
 		}
 	})
+
+	// NOTE: chunks of the running tick are sent before exit
+	client.AddShutdownHook("metrics", func() {
+		ticker.Stop()
+		<-sent
+	})
+
 	ticker.Start(false, true, true)
 }
 
@@ -129,9 +210,21 @@ func watchMetricsProm(
 	c *client.Client,
 	sources map[string]Source,
 	interval time.Duration,
+	mapping *MetricsMapping,
+	sinks map[string]Sink,
 ) {
-	scrapeSource := func(tickTime time.Time, sourceName string, source Source) {
+	scrapeSource := func(
+		tickSpan *tracing.Span,
+		tickTime time.Time,
+		sourceName string,
+		source Source,
+	) {
+		span := tickSpan.Child("metrics/scrape")
+		span.SetAttribute("source", sourceName)
+		defer span.End()
+
 		batches, err := source.GetMetrics(tickTime)
+		span.SetError(err)
 		if err != nil {
 			c.Errorf(err,
 				"unable to retrieve metrics from %s source",
@@ -141,23 +234,35 @@ func watchMetricsProm(
 		}
 
 		for batch := range batches {
-			packet := packetMetricsProm(batch)
-
-			c.Pipe(client.Package{
-				Kind:        proto.PacketKindMetricsPromStoreRequest,
-				ExpiryTime:  utils.After(2 * time.Hour),
-				ExpiryCount: 100,
-				Priority:    4,
-				Retries:     10,
-				Data:        packet,
-			})
+			batch.Metrics = mapping.applyFamilies(batch.Metrics)
+
+			for sinkName, sink := range sinks {
+				send := span.Child("metrics/send")
+				send.SetAttribute("sink", sinkName)
+				send.SetAttribute("families", len(batch.Metrics))
+
+				err := sink.SendBatch(batch)
+				send.SetError(err)
+				send.End()
+				if err != nil {
+					c.Errorf(
+						karma.Describe("sink", sinkName).Reason(err),
+						"unable to send metrics from %s source",
+						sourceName,
+					)
+				}
+			}
 		}
 	}
 
 	ticker := utils.NewTicker(
+		c.Logger,
 		"prom-metrics",
 		interval,
 		func(tickTime time.Time) {
+			span := tracing.Start("metrics/prom/tick")
+			defer span.End()
+
 			ctx := karma.Describe("tick", tickTime.Format(time.RFC3339))
 			c.Infof(
 				ctx,
@@ -169,7 +274,7 @@ func watchMetricsProm(
 
 			for sourceName, source := range sources {
 				go func(sourceName string, source Source) {
-					scrapeSource(tickTime, sourceName, source)
+					scrapeSource(span, tickTime, sourceName, source)
 					wg.Done()
 				}(sourceName, source)
 			}
@@ -182,6 +287,9 @@ func watchMetricsProm(
 			)
 		},
 	)
+
+	c.AddShutdownHook("prom-metrics", ticker.Stop)
+
 	ticker.Start(false, true, true)
 }
 
@@ -226,32 +334,88 @@ func min(a, b int) int {
 	return b
 }
 
-func sendMetrics(client *client.Client, pipe chan []*Metrics) {
+// chunkMetrics splits metrics of a tick to chunks of batchSize at most
+func chunkMetrics(
+	metrics []*Metrics,
+	tickTime time.Time,
+	batchSize int,
+) []*MetricsChunk {
+	batch := uuid.NewV4()
+	total := (len(metrics) + batchSize - 1) / batchSize
+
+	chunks := make([]*MetricsChunk, 0, total)
+	for i := 0; i < len(metrics); i += batchSize {
+		chunks = append(chunks, &MetricsChunk{
+			Batch:     batch,
+			Timestamp: tickTime,
+			Sequence:  len(chunks),
+			Total:     total,
+			Metrics:   metrics[i:min(i+batchSize, len(metrics))],
+		})
+	}
+
+	return chunks
+}
+
+func sendMetrics(
+	client *client.Client,
+	sinks map[string]Sink,
+	pipe chan *MetricsChunk,
+) {
 	queueLimit := 100
-	queue := make(chan []*Metrics, queueLimit)
-	defer close(queue)
+	queue := make(chan *MetricsChunk, queueLimit)
+	done := make(chan struct{})
 	go func() {
-		for metrics := range queue {
-			if len(metrics) > 0 {
-				client.Infof(karma.Describe("timestamp", metrics[0].Timestamp), "sending metrics")
-				sendMetricsBatch(client, metrics)
-				client.Infof(karma.Describe("timestamp", metrics[0].Timestamp), "metrics sent")
+		defer close(done)
+
+		for chunk := range queue {
+			if len(chunk.Metrics) > 0 {
+				ctx := karma.
+					Describe("timestamp", chunk.Timestamp).
+					Describe("batch", chunk.Batch).
+					Describe("sequence", chunk.Sequence).
+					Describe("total", chunk.Total)
+				client.Infof(ctx, "sending metrics")
+				for sinkName, sink := range sinks {
+					span := chunk.span.Child("metrics/send")
+					span.SetAttribute("sink", sinkName)
+					span.SetAttribute("sequence", chunk.Sequence)
+					span.SetAttribute("metrics", len(chunk.Metrics))
+
+					err := sink.SendChunk(chunk)
+					span.SetError(err)
+					span.End()
+					if err != nil {
+						client.Errorf(
+							ctx.Describe("sink", sinkName).Reason(err),
+							"unable to send metrics",
+						)
+					}
+				}
+				client.Infof(ctx, "metrics sent")
 			}
 		}
 	}()
-	for metrics := range pipe {
+	for chunk := range pipe {
 		if len(queue) >= queueLimit-1 {
 			// Discard the oldest value
 			<-queue
 		}
-		queue <- metrics
+		queue <- chunk
 	}
+
+	// wait for queued chunks to be sent
+	close(queue)
+	<-done
 }
 
 // SendMetrics bulk send metrics
-func sendMetricsBatch(c *client.Client, metrics []*Metrics) {
+func sendMetricsBatch(c *client.Client, chunk *MetricsChunk) {
+	span := chunk.span.Child("metrics/encode")
+	defer span.End()
+
 	var req proto.PacketMetricsStoreRequest
-	for _, metrics := range metrics {
+	for _, metrics := range chunk.Metrics {
 		req = append(req, proto.MetricStoreRequest{
 			Name:        metrics.Name,
 			Type:        metrics.Type,
@@ -267,16 +431,50 @@ func sendMetricsBatch(c *client.Client, metrics []*Metrics) {
 		})
 
 	}
+	// NOTE: gateways prior to chunks support only whole ticks of metrics,
+	// shards of such ticks can't be told apart
+	if !c.IsPacketKindSupported(proto.PacketKindMetricsStoreChunkRequest) {
+		c.Pipe(client.Package{
+			Kind:        proto.PacketKindMetricsStoreRequest,
+			ExpiryTime:  utils.After(2 * time.Hour),
+			ExpiryCount: 100,
+			Priority:    4,
+			Retries:     10,
+			Data:        req,
+		})
+		return
+	}
+
 	c.Pipe(client.Package{
-		Kind:        proto.PacketKindMetricsStoreRequest,
+		Kind:        proto.PacketKindMetricsStoreChunkRequest,
 		ExpiryTime:  utils.After(2 * time.Hour),
 		ExpiryCount: 100,
 		Priority:    4,
 		Retries:     10,
-		Data:        req,
+		Data: proto.PacketMetricsStoreChunkRequest{
+			Batch:     chunk.Batch,
+			Timestamp: chunk.Timestamp,
+			Sequence:  chunk.Sequence,
+			Total:     chunk.Total,
+			Shard:     getPacketShard(),
+			Metrics:   req,
+		},
 	})
 }
 
+// getPacketShard returns the shard of the agent if the cluster is sharded
+func getPacketShard() *proto.PacketShard {
+	if !shard.IsSharded() {
+		return nil
+	}
+
+	value := shard.GetShard()
+	return &proto.PacketShard{
+		Index: value.Index,
+		Total: value.Total,
+	}
+}
+
 // InitMetrics init metrics source
 func InitMetrics(
 	client *client.Client,
@@ -286,101 +484,169 @@ func InitMetrics(
 	args map[string]interface{},
 ) error {
 	var (
-		metricsInterval = utils.MustParseDuration(args, "--metrics-interval")
-		failOnError     = false // whether the agent will fail to start if an error happened during init metric source
+		metricsInterval  = utils.MustParseDuration(args, "--metrics-interval")
+		metricsBatchSize = utils.MustParseInt(args, "--metrics-batch-size")
+		failOnError      = false // whether the agent will fail to start if an error happened during init metric source
 
 		metricsSources = map[string]interface{}{}
 		foundErrors    = make([]error, 0)
 	)
 
+	if metricsBatchSize <= 0 {
+		return karma.Format(
+			nil,
+			"metrics batch size should be positive, got %d",
+			metricsBatchSize,
+		)
+	}
+
 	metricsSourcesNames := []string{"alpha-cadvisor", "alpha-stats", "kubelet"}
 	if names, ok := args["--source"].([]string); ok && len(names) > 0 {
 		metricsSourcesNames = names
 		failOnError = true
 	}
 
-	kubeletClient, err := NewKubeletClient(client.Logger, scanner, kube, args)
+	addressTypes, err := kuber.ParseNodeAddressTypes(
+		args["--kubelet-preferred-address-types"].(string),
+	)
+	if err != nil {
+		return karma.Format(err, "invalid kubelet preferred address types")
+	}
+
+	kubeletClient, err := NewKubeletClient(
+		client.Logger,
+		scanner,
+		kube,
+		KubeletClientOptions{
+			Port:   args["--kubelet-port"].(string),
+			Secure: args["--kubelet-secure"].(bool),
+			Access: args["--kubelet-access"].(string),
+
+			RequestTimeout:  utils.MustParseDuration(args, "--kubelet-request-timeout"),
+			ResolveFailures: utils.MustParseInt(args, "--kubelet-resolve-failures"),
+			ResolveInterval: utils.MustParseDuration(args, "--kubelet-resolve-interval"),
+			IdleConnections: utils.MustParseInt(args, "--kubelet-idle-connections"),
+			IdleTimeout:     utils.MustParseDuration(args, "--kubelet-idle-timeout"),
+			AddressTypes:    addressTypes,
+		},
+	)
 	if err != nil {
 		foundErrors = append(foundErrors, err)
 		failOnError = true
 	}
 
-	for _, metricsSource := range metricsSourcesNames {
-		switch metricsSource {
-		case "kubelet":
-			client.Info("using kubelet as metrics source")
-
-			kubelet, err := NewKubelet(
-				kubeletClient,
-				client.Logger,
-				metricsInterval,
-				kubeletTimeouts{
-					backoff: backOff{
-						sleep:      utils.MustParseDuration(args, "--kubelet-backoff-sleep"),
-						maxRetries: utils.MustParseInt(args, "--kubelet-backoff-max-retries"),
-					},
-				},
-				optInAnalysisData,
-			)
-			if err != nil {
-				foundErrors = append(foundErrors, karma.Format(
-					err,
-					"unable to initialize kubelet source",
-				))
-				continue
-			}
-
-			metricsSources[metricsSource] = kubelet
-
-		case "alpha-cadvisor":
-			cAdvisor, err := NewCAdvisor(
-				kubeletClient,
-				client.Logger,
-				scanner,
-				utils.Backoff{
-					Sleep:      utils.MustParseDuration(args, "--kubelet-backoff-sleep"),
-					MaxRetries: utils.MustParseInt(args, "--kubelet-backoff-max-retries"),
-				},
-			)
+	include, _ := args["--metric-include"].([]string)
+	exclude, _ := args["--metric-exclude"].([]string)
+	filter, err := NewMeasurementsFilter(include, exclude)
+	if err != nil {
+		return karma.Format(err, "invalid metrics filter")
+	}
 
-			if err != nil {
-				foundErrors = append(foundErrors, karma.Format(
-					err,
-					"unable to initialize cAdvisor source",
-				))
-				continue
-			}
+	scanner.AddDeletionListener(purgeLastSamples)
+
+	options := SourceOptions{
+		Client:            client,
+		Scanner:           scanner,
+		Kube:              kube,
+		KubeletClient:     kubeletClient,
+		Interval:          metricsInterval,
+		OptInAnalysisData: optInAnalysisData,
+		Filter:            filter,
+		Args:              args,
+	}
 
-			metricsSources[metricsSource] = cAdvisor
+	for _, metricsSource := range metricsSourcesNames {
+		registered, ok := registeredSources[metricsSource]
+		if !ok {
+			foundErrors = append(foundErrors, karma.Format(
+				nil,
+				"unknown metrics source %q",
+				metricsSource,
+			))
+			continue
+		}
 
-		case "alpha-stats":
-			stats := NewStats(scanner, client.Logger)
+		client.Infof(nil, "using %s as metrics source", metricsSource)
 
-			metricsSources[metricsSource] = stats
+		source, err := registered.factory(options)
+		if err != nil {
+			foundErrors = append(foundErrors, karma.Format(
+				err,
+				"unable to initialize %s source",
+				metricsSource,
+			))
+			continue
 		}
+
+		metricsSources[metricsSource] = source
 	}
 
 	if len(foundErrors) > 0 && (failOnError || len(metricsSources) == 0) {
 		return karma.Format(foundErrors, "unable to init metric sources")
 	}
 
+	status.RegisterState("metrics/rejected-samples", getRejectedSamplesState)
+
+	var mapping *MetricsMapping
+	if path, ok := args["--metrics-mapping"].(string); ok && path != "" {
+		mapping, err = LoadMetricsMapping(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	sinksNames := []string{DefaultSink}
+	if names, ok := args["--sink"].([]string); ok && len(names) > 0 {
+		sinksNames = names
+	}
+
+	sinks, err := initSinks(sinksNames, SinkOptions{
+		Client: client,
+		Args:   args,
+	})
+	if err != nil {
+		return err
+	}
+
+	pricing, _ := args["--cost-pricing"].(string)
+	costs, err := newCostEstimator(
+		client.Logger,
+		args["--cost-metrics"].(bool),
+		pricing,
+	)
+	if err != nil {
+		return err
+	}
+
+	merged := newMergedSource(client.Logger)
 	promSources := map[string]Source{}
 	for sourceName, source := range metricsSources {
 		switch s := source.(type) {
 		case MetricsSource:
-			go watchMetrics(
-				client,
-				s,
-				scanner,
-				metricsInterval,
-			)
-			break
+			merged.add(sourceName, registeredSources[sourceName].fidelity, s)
 		case Source:
 			promSources[sourceName] = s
-			break
 		}
 	}
-	go watchMetricsProm(client, promSources, metricsInterval)
+
+	if len(merged.sources) > 0 {
+		go watchMetrics(
+			client,
+			merged,
+			scanner,
+			metricsInterval,
+			metricsBatchSize,
+			mapping,
+			sinks,
+			newCapacityReporter(
+				client,
+				utils.MustParseDuration(args, "--capacity-interval"),
+				filter,
+			),
+			costs,
+		)
+	}
+	go watchMetricsProm(client, promSources, metricsInterval, mapping, sinks)
 
 	return nil
 }
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/shard"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// MetricsServer metrics source which reads containers usage from the
+// metrics api, it reports less measurements than kubelet but doesn't need
+// access to nodes
+type MetricsServer struct {
+	*log.Logger
+
+	kube   *kuber.Kube
+	filter *MeasurementsFilter
+}
+
+// NewMetricsServer creates a new metrics-server source
+func NewMetricsServer(
+	kube *kuber.Kube,
+	logger *log.Logger,
+	filter *MeasurementsFilter,
+) *MetricsServer {
+	return &MetricsServer{
+		Logger: logger,
+		kube:   kube,
+		filter: filter,
+	}
+}
+
+// GetMetrics gets containers cpu and memory usage
+func (source *MetricsServer) GetMetrics(
+	scanner *scanner.Scanner,
+	tickTime time.Time,
+) ([]*Metrics, map[string]interface{}, error) {
+	podsMetrics, err := source.kube.GetPodsMetrics()
+	if err != nil {
+		return nil, nil, karma.Format(err, "{metrics-server} unable to get pods metrics")
+	}
+
+	if podsMetrics == nil {
+		return nil, nil, karma.Format(nil, "{metrics-server} metrics api isn't available")
+	}
+
+	nodes := map[string]uuid.UUID{}
+	for _, node := range scanner.GetNodes() {
+		nodes[node.Name] = node.ID
+	}
+
+	podsNodes := map[string]uuid.UUID{}
+	for _, pod := range scanner.GetPods() {
+		podsNodes[pod.Namespace+"/"+pod.Name] = nodes[pod.Spec.NodeName]
+	}
+
+	metrics := []*Metrics{}
+	for _, pod := range podsMetrics {
+		namespace, name := pod.Metadata.Namespace, pod.Metadata.Name
+		if !shard.OwnsNamespace(namespace) {
+			continue
+		}
+
+		timestamp := pod.Timestamp
+		if timestamp.IsZero() {
+			timestamp = tickTime
+		}
+
+		for _, container := range pod.Containers {
+			applicationID, serviceID, identified, ok := scanner.FindContainer(
+				namespace,
+				name,
+				container.Name,
+			)
+			if !ok {
+				continue
+			}
+
+			for _, measurement := range []struct {
+				Name  string
+				Value int64
+			}{
+				// NOTE: metrics api reports the usage rate, not the
+				// cumulative usage
+				{"cpu/usage_rate", container.Usage.Cpu().MilliValue()},
+				{"memory/rss", container.Usage.Memory().Value()},
+			} {
+				if !source.filter.Allows(measurement.Name) {
+					continue
+				}
+
+				metrics = append(metrics, &Metrics{
+					Name:        measurement.Name,
+					Type:        TypePodContainer,
+					Node:        podsNodes[namespace+"/"+name],
+					Application: applicationID,
+					Service:     serviceID,
+					Container:   identified.ID,
+					Timestamp:   timestamp,
+					Value:       measurement.Value,
+					PodName:     name,
+				})
+			}
+		}
+	}
+
+	return metrics, nil, nil
+}
@@ -1,8 +1,18 @@
 package metrics
 
 import (
-	"github.com/MagalixCorp/magalix-agent/scanner"
+	"sort"
+	"sync"
 	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixCorp/magalix-agent/status"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
 )
 
 // Deprecated: MetricsSource interface is deprecated and will be removed
@@ -16,3 +26,279 @@ type MetricsSource interface {
 type Source interface {
 	GetMetrics(time time.Time) (chan *MetricsBatch, error)
 }
+
+// SourceOptions dependencies passed to metrics sources factories
+type SourceOptions struct {
+	Client            *client.Client
+	Scanner           *scanner.Scanner
+	Kube              *kuber.Kube
+	KubeletClient     *KubeletClient
+	Interval          time.Duration
+	OptInAnalysisData bool
+	Filter            *MeasurementsFilter
+	Args              map[string]interface{}
+}
+
+// SourceFactory creates a metrics source, the returned source should
+// implement either MetricsSource or Source
+type SourceFactory func(options SourceOptions) (interface{}, error)
+
+type registeredSource struct {
+	fidelity int
+	factory  SourceFactory
+}
+
+var registeredSources = map[string]registeredSource{}
+
+// RegisterSource registers a metrics source which can be enabled by --source,
+// if several sources report the same measurement the one reported by the
+// source with the highest fidelity is sent
+func RegisterSource(name string, fidelity int, factory SourceFactory) {
+	registeredSources[name] = registeredSource{
+		fidelity: fidelity,
+		factory:  factory,
+	}
+}
+
+func init() {
+	RegisterSource("kubelet", 100, func(options SourceOptions) (interface{}, error) {
+		detectKubeletFeatures(options.KubeletClient, options.Scanner.GetNodes())
+		if !options.Kube.Capabilities.IsSupported(kuber.FeatureKubeletSummary) {
+			return nil, karma.Format(
+				nil,
+				"kubelet doesn't serve stats/summary endpoint, "+
+					"consider --source=cri or --source=metrics-server",
+			)
+		}
+
+		smoothing, _ := options.Args["--smooth-rate"].([]string)
+
+		kubelet, err := NewKubelet(
+			options.KubeletClient,
+			options.Client.Logger,
+			KubeletOptions{
+				Resolution:        options.Interval,
+				BackoffSleep:      utils.MustParseDuration(options.Args, "--kubelet-backoff-sleep"),
+				BackoffMaxRetries: utils.MustParseInt(options.Args, "--kubelet-backoff-max-retries"),
+				MaxConcurrency:    utils.MustParseInt(options.Args, "--kubelet-max-concurrency"),
+				BreakerFailures:   utils.MustParseInt(options.Args, "--kubelet-breaker-failures"),
+				BreakerSkipTicks:  utils.MustParseInt(options.Args, "--kubelet-breaker-skip-ticks"),
+				Smoothing:         smoothing,
+				Filter:            options.Filter,
+				OptInAnalysisData: options.OptInAnalysisData,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if path, ok := options.Args["--metrics-state"].(string); ok && path != "" {
+			err := kubelet.RestorePreviousValues(path)
+			if err != nil {
+				options.Client.Errorf(
+					err,
+					"unable to restore previous values of rate metrics",
+				)
+			}
+		}
+
+		options.Scanner.AddDeletionListener(kubelet.purgeDeletedPods)
+		options.Client.RegisterHealthCheck("kubelet", kubelet.getHealth)
+
+		status.RegisterState("metrics/kubelet", kubelet.GetState)
+
+		return kubelet, nil
+	})
+
+	RegisterSource("cri", 75, func(options SourceOptions) (interface{}, error) {
+		cri := NewCRI(
+			options.KubeletClient,
+			options.Client.Logger,
+			options.Interval,
+			utils.MustParseInt(options.Args, "--kubelet-max-concurrency"),
+			options.Filter,
+		)
+
+		options.Scanner.AddDeletionListener(cri.purgeDeletedPods)
+
+		return cri, nil
+	})
+
+	RegisterSource("metrics-server", 50, func(options SourceOptions) (interface{}, error) {
+		return NewMetricsServer(options.Kube, options.Client.Logger, options.Filter), nil
+	})
+
+	RegisterSource("alpha-cadvisor", 0, func(options SourceOptions) (interface{}, error) {
+		return NewCAdvisor(
+			options.KubeletClient,
+			options.Client.Logger,
+			options.Scanner,
+			utils.Backoff{
+				Sleep:      utils.MustParseDuration(options.Args, "--kubelet-backoff-sleep"),
+				MaxRetries: utils.MustParseInt(options.Args, "--kubelet-backoff-max-retries"),
+			},
+		)
+	})
+
+	RegisterSource("alpha-stats", 0, func(options SourceOptions) (interface{}, error) {
+		return NewStats(options.Scanner, options.Client.Logger), nil
+	})
+}
+
+// rankedSource a metrics source with its fidelity
+type rankedSource struct {
+	name     string
+	fidelity int
+	source   MetricsSource
+}
+
+// sourceMetrics metrics collected from a single source
+type sourceMetrics struct {
+	name     string
+	fidelity int
+	metrics  []*Metrics
+}
+
+type metricKey struct {
+	Name        string
+	Type        string
+	Node        uuid.UUID
+	Application uuid.UUID
+	Service     uuid.UUID
+	Container   uuid.UUID
+	PodName     string
+}
+
+// mergedSource collects metrics from several sources concurrently and
+// merges them into a single list
+type mergedSource struct {
+	logger  *log.Logger
+	sources []rankedSource
+}
+
+func newMergedSource(logger *log.Logger) *mergedSource {
+	return &mergedSource{
+		logger: logger,
+	}
+}
+
+func (merged *mergedSource) add(name string, fidelity int, source MetricsSource) {
+	merged.sources = append(merged.sources, rankedSource{
+		name:     name,
+		fidelity: fidelity,
+		source:   source,
+	})
+}
+
+// GetMetrics collects metrics from all sources, raw responses are grouped by
+// source name if there are several sources
+func (merged *mergedSource) GetMetrics(
+	scanner *scanner.Scanner,
+	tickTime time.Time,
+) ([]*Metrics, map[string]interface{}, error) {
+	var (
+		results = make([]sourceMetrics, len(merged.sources))
+		raws    = make([]map[string]interface{}, len(merged.sources))
+		errs    = make([]error, len(merged.sources))
+	)
+
+	wg := &sync.WaitGroup{}
+	for i, ranked := range merged.sources {
+		wg.Add(1)
+		go func(i int, ranked rankedSource) {
+			defer wg.Done()
+
+			metrics, raw, err := ranked.source.GetMetrics(scanner, tickTime)
+			if err != nil {
+				errs[i] = karma.Format(
+					err,
+					"unable to get metrics from %s source",
+					ranked.name,
+				)
+			}
+
+			results[i] = sourceMetrics{
+				name:     ranked.name,
+				fidelity: ranked.fidelity,
+				metrics:  metrics,
+			}
+			raws[i] = raw
+		}(i, ranked)
+	}
+
+	wg.Wait()
+
+	var raw map[string]interface{}
+	if len(merged.sources) == 1 {
+		raw = raws[0]
+	} else {
+		for i, ranked := range merged.sources {
+			if raws[i] == nil {
+				continue
+			}
+
+			if raw == nil {
+				raw = map[string]interface{}{}
+			}
+
+			raw[ranked.name] = raws[i]
+		}
+	}
+
+	var foundErrors []error
+	for _, err := range errs {
+		if err != nil {
+			foundErrors = append(foundErrors, err)
+		}
+	}
+
+	metrics := mergeMetrics(results)
+
+	if len(foundErrors) > 0 {
+		return metrics, raw, karma.Format(foundErrors, "unable to get metrics")
+	}
+
+	return metrics, raw, nil
+}
+
+// mergeMetrics merges metrics of several sources, a measurement reported by
+// several sources is taken from the source with the highest fidelity only
+func mergeMetrics(results []sourceMetrics) []*Metrics {
+	if len(results) == 1 {
+		return results[0].metrics
+	}
+
+	sorted := make([]sourceMetrics, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].fidelity > sorted[j].fidelity
+	})
+
+	owners := map[metricKey]string{}
+	merged := []*Metrics{}
+
+	for _, result := range sorted {
+		for _, metric := range result.metrics {
+			key := metricKey{
+				Name:        metric.Name,
+				Type:        metric.Type,
+				Node:        metric.Node,
+				Application: metric.Application,
+				Service:     metric.Service,
+				Container:   metric.Container,
+				PodName:     metric.PodName,
+			}
+
+			// NOTE: a source may report several values of the same
+			// measurement, all of them are kept
+			if owner, ok := owners[key]; ok && owner != result.name {
+				continue
+			}
+
+			owners[key] = result.name
+			merged = append(merged, metric)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChunkMetrics(t *testing.T) {
+	tickTime := time.Now()
+
+	metrics := make([]*Metrics, 25)
+	for i := range metrics {
+		metrics[i] = &Metrics{Value: int64(i)}
+	}
+
+	chunks := chunkMetrics(metrics, tickTime, 10)
+	if len(chunks) != 3 {
+		t.Fatalf("chunks = %d, want 3", len(chunks))
+	}
+
+	count := 0
+	for i, chunk := range chunks {
+		if chunk.Sequence != i {
+			t.Errorf("chunk %d sequence = %d", i, chunk.Sequence)
+		}
+		if chunk.Total != 3 {
+			t.Errorf("chunk %d total = %d, want 3", i, chunk.Total)
+		}
+		if chunk.Batch != chunks[0].Batch {
+			t.Errorf("chunk %d batch = %s, want %s", i, chunk.Batch, chunks[0].Batch)
+		}
+		for _, item := range chunk.Metrics {
+			if item.Value != int64(count) {
+				t.Errorf("metric %d value = %d", count, item.Value)
+			}
+			count++
+		}
+	}
+
+	if count != len(metrics) {
+		t.Errorf("chunked metrics = %d, want %d", count, len(metrics))
+	}
+
+	if chunks := chunkMetrics(nil, tickTime, 10); len(chunks) != 0 {
+		t.Errorf("chunks of empty metrics = %d, want 0", len(chunks))
+	}
+}
+
+func TestMergeMetrics(t *testing.T) {
+	merged := mergeMetrics([]sourceMetrics{
+		{
+			name:     "metrics-server",
+			fidelity: 50,
+			metrics: []*Metrics{
+				{Name: "memory/rss", Type: TypePodContainer, PodName: "a", Value: 1},
+				{Name: "memory/rss", Type: TypePodContainer, PodName: "b", Value: 2},
+			},
+		},
+		{
+			name:     "kubelet",
+			fidelity: 100,
+			metrics: []*Metrics{
+				{Name: "memory/rss", Type: TypePodContainer, PodName: "a", Value: 10},
+				{Name: "memory/rss", Type: TypePodContainer, PodName: "a", Value: 11},
+			},
+		},
+	})
+
+	values := []int64{}
+	for _, metric := range merged {
+		values = append(values, metric.Value)
+	}
+
+	expected := []int64{10, 11, 2}
+	if len(values) != len(expected) {
+		t.Fatalf("merged values = %v, want %v", values, expected)
+	}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Fatalf("merged values = %v, want %v", values, expected)
+		}
+	}
+}
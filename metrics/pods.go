@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"github.com/MagalixCorp/magalix-agent/shard"
+	kv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// MetricPodsPhase count of pods in a phase
+	MetricPodsPhase = "pods/phase"
+	// MetricContainersWaiting count of containers waiting for a reason
+	MetricContainersWaiting = "containers/waiting"
+)
+
+var (
+	// trackedPodsPhases phases which are always reported even if no pods
+	// are in them
+	trackedPodsPhases = []string{
+		string(kv1.PodPending),
+		string(kv1.PodRunning),
+		string(kv1.PodFailed),
+	}
+
+	// trackedWaitingReasons reasons of waiting containers which are counted
+	trackedWaitingReasons = []string{
+		"ImagePullBackOff",
+		"ErrImagePull",
+		"CrashLoopBackOff",
+	}
+)
+
+// podsStateKey key of a pods state count, an empty namespace is used for
+// cluster-level counts
+type podsStateKey struct {
+	Metric    string
+	Namespace string
+	State     string
+}
+
+// getPodsStateCounts counts pods phases and waiting containers reasons of the
+// cluster and of every namespace
+func getPodsStateCounts(pods []kv1.Pod) map[podsStateKey]int64 {
+	counts := map[podsStateKey]int64{}
+
+	initNamespace := func(namespace string) {
+		for _, phase := range trackedPodsPhases {
+			counts[podsStateKey{MetricPodsPhase, namespace, phase}] += 0
+		}
+		for _, reason := range trackedWaitingReasons {
+			counts[podsStateKey{MetricContainersWaiting, namespace, reason}] += 0
+		}
+	}
+
+	initNamespace("")
+
+	for _, pod := range pods {
+		initNamespace(pod.Namespace)
+
+		for _, namespace := range []string{"", pod.Namespace} {
+			counts[podsStateKey{MetricPodsPhase, namespace, string(pod.Status.Phase)}]++
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting == nil {
+				continue
+			}
+
+			key := podsStateKey{
+				Metric: MetricContainersWaiting,
+				State:  status.State.Waiting.Reason,
+			}
+			if _, ok := counts[key]; !ok {
+				continue
+			}
+
+			counts[key]++
+			key.Namespace = pod.Namespace
+			counts[key]++
+		}
+	}
+
+	return counts
+}
+
+// filterShardPods returns pods of namespaces handled by the shard of the agent
+func filterShardPods(pods []kv1.Pod) []kv1.Pod {
+	if !shard.IsSharded() {
+		return pods
+	}
+
+	filtered := []kv1.Pod{}
+	for _, pod := range pods {
+		if shard.OwnsNamespace(pod.Namespace) {
+			filtered = append(filtered, pod)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodsStateCounts(t *testing.T) {
+	waiting := func(reason string) kv1.ContainerStatus {
+		return kv1.ContainerStatus{
+			State: kv1.ContainerState{
+				Waiting: &kv1.ContainerStateWaiting{Reason: reason},
+			},
+		}
+	}
+
+	pods := []kv1.Pod{
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default"},
+			Status: kv1.PodStatus{
+				Phase: kv1.PodRunning,
+			},
+		},
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default"},
+			Status: kv1.PodStatus{
+				Phase: kv1.PodPending,
+				ContainerStatuses: []kv1.ContainerStatus{
+					waiting("ImagePullBackOff"),
+					waiting("ContainerCreating"),
+				},
+			},
+		},
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "kube-system"},
+			Status: kv1.PodStatus{
+				Phase: kv1.PodRunning,
+				ContainerStatuses: []kv1.ContainerStatus{
+					waiting("CrashLoopBackOff"),
+				},
+			},
+		},
+	}
+
+	counts := getPodsStateCounts(pods)
+
+	for key, want := range map[podsStateKey]int64{
+		{MetricPodsPhase, "", "Running"}:                             2,
+		{MetricPodsPhase, "", "Pending"}:                             1,
+		{MetricPodsPhase, "", "Failed"}:                              0,
+		{MetricPodsPhase, "default", "Running"}:                      1,
+		{MetricPodsPhase, "kube-system", "Pending"}:                  0,
+		{MetricContainersWaiting, "", "ImagePullBackOff"}:            1,
+		{MetricContainersWaiting, "default", "ImagePullBackOff"}:     1,
+		{MetricContainersWaiting, "kube-system", "CrashLoopBackOff"}: 1,
+		{MetricContainersWaiting, "default", "CrashLoopBackOff"}:     0,
+	} {
+		got, ok := counts[key]
+		if !ok || got != want {
+			t.Errorf("getPodsStateCounts()[%+v] = %d, want %d", key, got, want)
+		}
+	}
+
+	if _, ok := counts[podsStateKey{MetricContainersWaiting, "", "ContainerCreating"}]; ok {
+		t.Errorf("getPodsStateCounts() counted untracked waiting reason")
+	}
+}
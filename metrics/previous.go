@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+)
+
+// previousValuesMaxAge values older than the age are not restored, rates
+// over such gaps are meaningless
+const previousValuesMaxAge = time.Hour
+
+// loadPreviousValues reads previous values of rate metrics persisted by a
+// previous run, a missing file means no values
+func loadPreviousValues(path string, now time.Time) (map[string]KubeletValue, error) {
+	values := map[string]KubeletValue{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to read metrics state file %s",
+			path,
+		)
+	}
+
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode metrics state file %s",
+			path,
+		)
+	}
+
+	for key, value := range values {
+		if now.Sub(value.Timestamp) > previousValuesMaxAge {
+			delete(values, key)
+		}
+	}
+
+	return values, nil
+}
+
+// savePreviousValues persists previous values to the state file
+func savePreviousValues(path string, values map[string]KubeletValue) error {
+	return utils.WriteStateFile(path, values, "metrics")
+}
+
+// RestorePreviousValues restores previous values of rate metrics from the
+// file and persists them to the file after every tick, so rates are
+// calculated on the first tick after restart
+func (kubelet *Kubelet) RestorePreviousValues(path string) error {
+	kubelet.previousMutex.Lock()
+	defer kubelet.previousMutex.Unlock()
+
+	// NOTE: values are persisted even if the file can't be restored
+	kubelet.previousPath = path
+
+	values, err := loadPreviousValues(path, time.Now())
+	if err != nil {
+		return err
+	}
+
+	kubelet.previous = values
+
+	kubelet.Infof(
+		nil,
+		"{kubelet} restored %d previous values of rate metrics",
+		len(values),
+	)
+
+	return nil
+}
+
+func (kubelet *Kubelet) persistPreviousValues() {
+	if kubelet.previousPath == "" {
+		return
+	}
+
+	kubelet.previousMutex.Lock()
+	defer kubelet.previousMutex.Unlock()
+
+	err := savePreviousValues(kubelet.previousPath, kubelet.previous)
+	if err != nil {
+		kubelet.Errorf(err, "{kubelet} unable to persist previous values")
+	}
+}
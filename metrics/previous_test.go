@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPreviousValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	values, err := loadPreviousValues(path, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 0 {
+		t.Fatalf("expected no values, got %v", values)
+	}
+
+	err = savePreviousValues(path, map[string]KubeletValue{
+		"fresh": {Timestamp: now.Add(-time.Minute), Value: 10},
+		"stale": {Timestamp: now.Add(-2 * time.Hour), Value: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err = loadPreviousValues(path, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 1 {
+		t.Fatalf("expected only fresh value, got %v", values)
+	}
+
+	fresh := values["fresh"]
+	if fresh.Value != 10 || !fresh.Timestamp.Equal(now.Add(-time.Minute)) {
+		t.Errorf("unexpected restored value %v", fresh)
+	}
+}
+
+func TestGetPreviousValuePod(t *testing.T) {
+	for key, expected := range map[string]string{
+		"pod-network/rx_rate:default:api-1":                                 "default:api-1",
+		"pod_container-cpu/usage_rate:default:api-1:app":                    "default:api-1",
+		"pod_container-cpu/usage_rate:default:api-1:app:smoothed":           "default:api-1",
+		"node-network/rx_rate:6b6035fb-e6a9-11e8-a8ed-42010a8e0004":         "",
+		"node-cpu/usage_rate:6b6035fb-e6a9-11e8-a8ed-42010a8e0004:smoothed": "",
+	} {
+		if pod := getPreviousValuePod(key); pod != expected {
+			t.Errorf("key %q: expected pod %q, got %q", key, expected, pod)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+// quotaResource a resource of a ResourceQuota reported as used/hard metrics
+type quotaResource struct {
+	// Names quota resource names, the first one found in hard is used
+	Names []kv1.ResourceName
+	// Metric metric name prefix
+	Metric string
+	// Milli whether the value is reported in milli units
+	Milli bool
+}
+
+func (resource quotaResource) value(quantity kresource.Quantity) int64 {
+	if resource.Milli {
+		return quantity.MilliValue()
+	}
+
+	return quantity.Value()
+}
+
+var quotaResources = []quotaResource{
+	{
+		Names:  []kv1.ResourceName{kv1.ResourceRequestsCPU, kv1.ResourceCPU},
+		Metric: "quota/cpu",
+		Milli:  true,
+	},
+	{
+		Names:  []kv1.ResourceName{kv1.ResourceLimitsCPU},
+		Metric: "quota/cpu_limits",
+		Milli:  true,
+	},
+	{
+		Names:  []kv1.ResourceName{kv1.ResourceRequestsMemory, kv1.ResourceMemory},
+		Metric: "quota/memory",
+	},
+	{
+		Names:  []kv1.ResourceName{kv1.ResourceLimitsMemory},
+		Metric: "quota/memory_limits",
+	},
+}
+
+// quotaKey key of a quota value
+type quotaKey struct {
+	Metric    string
+	Namespace string
+	Quota     string
+}
+
+// getQuotasValues returns used and hard values of cpu and memory of the
+// given resource quotas, resources which are not constrained by a quota are
+// not reported
+func getQuotasValues(quotas []kv1.ResourceQuota) map[quotaKey]int64 {
+	values := map[quotaKey]int64{}
+
+	for _, quota := range quotas {
+		for _, resource := range quotaResources {
+			for _, name := range resource.Names {
+				hard, ok := quota.Status.Hard[name]
+				if !ok {
+					hard, ok = quota.Spec.Hard[name]
+				}
+				if !ok {
+					continue
+				}
+
+				used := quota.Status.Used[name]
+
+				values[quotaKey{
+					Metric:    resource.Metric + "_used",
+					Namespace: quota.Namespace,
+					Quota:     quota.Name,
+				}] = resource.value(used)
+				values[quotaKey{
+					Metric:    resource.Metric + "_hard",
+					Namespace: quota.Namespace,
+					Quota:     quota.Name,
+				}] = resource.value(hard)
+
+				break
+			}
+		}
+	}
+
+	return values
+}
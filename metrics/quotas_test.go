@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetQuotasValues(t *testing.T) {
+	quotas := []kv1.ResourceQuota{
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default", Name: "compute"},
+			Status: kv1.ResourceQuotaStatus{
+				Hard: kv1.ResourceList{
+					kv1.ResourceRequestsCPU:  kresource.MustParse("2"),
+					kv1.ResourceLimitsMemory: kresource.MustParse("1Gi"),
+					kv1.ResourcePods:         kresource.MustParse("10"),
+				},
+				Used: kv1.ResourceList{
+					kv1.ResourceRequestsCPU:  kresource.MustParse("1500m"),
+					kv1.ResourceLimitsMemory: kresource.MustParse("512Mi"),
+					kv1.ResourcePods:         kresource.MustParse("4"),
+				},
+			},
+		},
+	}
+
+	expected := map[quotaKey]int64{
+		{"quota/cpu_used", "default", "compute"}:           1500,
+		{"quota/cpu_hard", "default", "compute"}:           2000,
+		{"quota/memory_limits_used", "default", "compute"}: 512 * 1024 * 1024,
+		{"quota/memory_limits_hard", "default", "compute"}: 1024 * 1024 * 1024,
+	}
+
+	values := getQuotasValues(quotas)
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%v: expected %d, got %d", key, value, values[key])
+		}
+	}
+}
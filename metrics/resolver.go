@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+// nodeEndpoint address of kubelet of a node
+type nodeEndpoint struct {
+	IP   string
+	Port int32
+}
+
+func getNodeEndpoint(node kuber.Node) nodeEndpoint {
+	return nodeEndpoint{IP: node.IP, Port: node.KubeletPort}
+}
+
+// nodeOverride endpoint of a node resolved after scrape failures, it's used
+// while the scanner reports the stale endpoint
+type nodeOverride struct {
+	stale  nodeEndpoint
+	actual nodeEndpoint
+}
+
+// nodesResolver re-resolves kubelet endpoints of nodes which scrapes keep
+// failing, so a replaced node or a reassigned ip doesn't fail scrapes until
+// the next scan
+type nodesResolver struct {
+	// threshold consecutive failures which trigger re-resolution, zero
+	// disables re-resolution
+	threshold int
+	// interval min interval between re-resolutions of a node
+	interval time.Duration
+
+	resolve func(name string) (nodeEndpoint, error)
+
+	mutex      sync.Mutex
+	failures   map[string]int
+	resolvedAt map[string]time.Time
+	overrides  map[string]nodeOverride
+}
+
+func newNodesResolver(
+	threshold int,
+	interval time.Duration,
+	resolve func(name string) (nodeEndpoint, error),
+) *nodesResolver {
+	return &nodesResolver{
+		threshold:  threshold,
+		interval:   interval,
+		resolve:    resolve,
+		failures:   map[string]int{},
+		resolvedAt: map[string]time.Time{},
+		overrides:  map[string]nodeOverride{},
+	}
+}
+
+// apply returns the node with the resolved endpoint, the override is dropped
+// once the scanner reports an endpoint other than the stale one
+func (resolver *nodesResolver) apply(node kuber.Node) kuber.Node {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	override, ok := resolver.overrides[node.Name]
+	if !ok {
+		return node
+	}
+
+	if getNodeEndpoint(node) != override.stale {
+		delete(resolver.overrides, node.Name)
+		return node
+	}
+
+	node.IP = override.actual.IP
+	node.KubeletPort = override.actual.Port
+
+	return node
+}
+
+// observe records result of a request to kubelet of the node, the node is
+// re-resolved after consecutive failures unless it was re-resolved recently,
+// it returns the new endpoint if the endpoint has changed
+func (resolver *nodesResolver) observe(
+	node kuber.Node,
+	err error,
+) (*nodeEndpoint, error) {
+	if resolver.threshold <= 0 {
+		return nil, nil
+	}
+
+	resolver.mutex.Lock()
+	if err == nil {
+		delete(resolver.failures, node.Name)
+		resolver.mutex.Unlock()
+		return nil, nil
+	}
+
+	resolver.failures[node.Name]++
+	if resolver.failures[node.Name] < resolver.threshold ||
+		time.Since(resolver.resolvedAt[node.Name]) < resolver.interval {
+		resolver.mutex.Unlock()
+		return nil, nil
+	}
+
+	resolver.resolvedAt[node.Name] = time.Now()
+	resolver.mutex.Unlock()
+
+	// NOTE: resolving is done without the lock, so requests to other nodes
+	// aren't blocked by the api server
+	actual, err := resolver.resolve(node.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	current := getNodeEndpoint(node)
+	if actual == current {
+		return nil, nil
+	}
+
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	stale := current
+	if override, ok := resolver.overrides[node.Name]; ok {
+		stale = override.stale
+	}
+
+	resolver.overrides[node.Name] = nodeOverride{
+		stale:  stale,
+		actual: actual,
+	}
+	delete(resolver.failures, node.Name)
+
+	return &actual, nil
+}
+
+// forget drops state of nodes which aren't in the cluster anymore
+func (resolver *nodesResolver) forget(nodes []kuber.Node) {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	existing := map[string]struct{}{}
+	for _, node := range nodes {
+		existing[node.Name] = struct{}{}
+	}
+
+	for name := range resolver.failures {
+		if _, ok := existing[name]; !ok {
+			delete(resolver.failures, name)
+		}
+	}
+
+	for name := range resolver.resolvedAt {
+		if _, ok := existing[name]; !ok {
+			delete(resolver.resolvedAt, name)
+		}
+	}
+
+	for name := range resolver.overrides {
+		if _, ok := existing[name]; !ok {
+			delete(resolver.overrides, name)
+		}
+	}
+}
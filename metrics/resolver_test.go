@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+func TestNodesResolver(t *testing.T) {
+	actual := nodeEndpoint{IP: "10.0.0.2", Port: 10250}
+	resolves := 0
+
+	resolver := newNodesResolver(2, time.Hour, func(string) (nodeEndpoint, error) {
+		resolves++
+		return actual, nil
+	})
+
+	node := kuber.Node{Name: "a", IP: "10.0.0.1", KubeletPort: 10250}
+	failed := errors.New("failed")
+
+	endpoint, err := resolver.observe(resolver.apply(node), failed)
+	if endpoint != nil || err != nil || resolves != 0 {
+		t.Fatalf("resolved after a single failure")
+	}
+
+	endpoint, err = resolver.observe(resolver.apply(node), failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint == nil || *endpoint != actual || resolves != 1 {
+		t.Fatalf("not resolved after repeated failures: %v", endpoint)
+	}
+
+	if resolved := resolver.apply(node); resolved.IP != actual.IP {
+		t.Fatalf("resolved endpoint is not used: %s", resolved.IP)
+	}
+
+	resolver.observe(resolver.apply(node), failed)
+	resolver.observe(resolver.apply(node), failed)
+	if resolves != 1 {
+		t.Fatalf("resolved more often than interval allows: %d", resolves)
+	}
+
+	scanned := kuber.Node{Name: "a", IP: "10.0.0.3", KubeletPort: 10250}
+	if resolved := resolver.apply(scanned); resolved.IP != scanned.IP {
+		t.Fatalf("override is used with a new scanned endpoint: %s", resolved.IP)
+	}
+
+	if resolved := resolver.apply(node); resolved.IP != node.IP {
+		t.Fatalf("dropped override is used: %s", resolved.IP)
+	}
+
+	resolver.forget(nil)
+	if len(resolver.failures) != 0 || len(resolver.resolvedAt) != 0 {
+		t.Fatalf("state of deleted nodes is kept")
+	}
+}
+
+func TestNodesResolver_Disabled(t *testing.T) {
+	resolver := newNodesResolver(0, 0, func(string) (nodeEndpoint, error) {
+		t.Fatalf("resolved with disabled resolver")
+		return nodeEndpoint{}, nil
+	})
+
+	node := kuber.Node{Name: "a", IP: "10.0.0.1"}
+	for i := 0; i < 3; i++ {
+		resolver.observe(node, errors.New("failed"))
+	}
+}
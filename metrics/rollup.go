@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+// rollupMeasurements container usage measurements which are summed up across
+// all replicas of a service
+var rollupMeasurements = map[string]struct{}{
+	"cpu/usage_rate":   {},
+	"memory/rss":       {},
+	"filesystem/usage": {},
+}
+
+type rollupKey struct {
+	service     uuid.UUID
+	measurement string
+}
+
+type rollupValue struct {
+	application uuid.UUID
+	value       int64
+	pods        map[string]struct{}
+}
+
+// rollupServices sums containers usage of every service per tick,
+// so the backend doesn't have to join containers series
+func rollupServices(metrics []*Metrics, tickTime time.Time) []*Metrics {
+	rollups := map[rollupKey]*rollupValue{}
+
+	for _, metric := range metrics {
+		if metric.Type != TypePodContainer || metric.Service.IsNil() {
+			continue
+		}
+
+		if _, ok := rollupMeasurements[metric.Name]; !ok {
+			continue
+		}
+
+		key := rollupKey{
+			service:     metric.Service,
+			measurement: metric.Name,
+		}
+
+		rollup, ok := rollups[key]
+		if !ok {
+			rollup = &rollupValue{
+				application: metric.Application,
+				pods:        map[string]struct{}{},
+			}
+			rollups[key] = rollup
+		}
+
+		rollup.value += metric.Value
+		rollup.pods[metric.PodName] = struct{}{}
+	}
+
+	result := make([]*Metrics, 0, len(rollups))
+	for key, rollup := range rollups {
+		result = append(result, &Metrics{
+			Name:        key.measurement,
+			Type:        TypeService,
+			Application: rollup.application,
+			Service:     key.service,
+			Timestamp:   tickTime,
+			Value:       rollup.value,
+
+			AdditionalTags: map[string]interface{}{
+				"pods": len(rollup.pods),
+			},
+		})
+	}
+
+	return result
+}
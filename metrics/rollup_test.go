@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestRollupServices(t *testing.T) {
+	application := uuid.NewV4()
+	service := uuid.NewV4()
+	tickTime := time.Now()
+
+	metrics := []*Metrics{
+		{
+			Name:        "memory/rss",
+			Type:        TypePodContainer,
+			Application: application,
+			Service:     service,
+			Container:   uuid.NewV4(),
+			PodName:     "pod-1",
+			Value:       100,
+		},
+		{
+			Name:        "memory/rss",
+			Type:        TypePodContainer,
+			Application: application,
+			Service:     service,
+			Container:   uuid.NewV4(),
+			PodName:     "pod-2",
+			Value:       50,
+		},
+		{
+			Name:        "memory/limit",
+			Type:        TypePodContainer,
+			Application: application,
+			Service:     service,
+			Container:   uuid.NewV4(),
+			PodName:     "pod-2",
+			Value:       1000,
+		},
+		{
+			Name:  "memory/rss",
+			Type:  TypeNode,
+			Node:  uuid.NewV4(),
+			Value: 10000,
+		},
+	}
+
+	rollups := rollupServices(metrics, tickTime)
+	if len(rollups) != 1 {
+		t.Fatalf("rollupServices() returned %d metrics, want 1", len(rollups))
+	}
+
+	rollup := rollups[0]
+	if rollup.Type != TypeService || rollup.Service != service {
+		t.Errorf("rollupServices() = %+v, want service rollup", rollup)
+	}
+	if rollup.Value != 150 {
+		t.Errorf("rollupServices() value = %d, want 150", rollup.Value)
+	}
+	if pods := rollup.AdditionalTags["pods"]; pods != 2 {
+		t.Errorf("rollupServices() pods = %v, want 2", pods)
+	}
+	if !rollup.Timestamp.Equal(tickTime) {
+		t.Errorf("rollupServices() timestamp = %v, want %v", rollup.Timestamp, tickTime)
+	}
+}
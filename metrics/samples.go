@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+const (
+	// samplesHistorySize count of last samples kept of every container of a
+	// pod, the history is used to estimate trends of memory usage
+	samplesHistorySize = 12
+
+	// samplesMetric the only metric which samples are kept
+	samplesMetric = "memory/rss"
+)
+
+// Sample a single metric value
+type Sample struct {
+	Value     int64
+	Timestamp time.Time
+}
+
+// sampleKey container of a pod, containers of pods of the same service share
+// the container id
+type sampleKey struct {
+	container uuid.UUID
+	pod       string
+}
+
+var (
+	lastSamples      = map[sampleKey][]Sample{}
+	lastSamplesMutex sync.Mutex
+)
+
+// storeLastSamples keeps last memory rss samples of every container of a pod
+// ordered by timestamps
+func storeLastSamples(metrics []*Metrics) {
+	lastSamplesMutex.Lock()
+	defer lastSamplesMutex.Unlock()
+
+	for _, metric := range metrics {
+		if metric.Type != TypePodContainer || metric.Name != samplesMetric {
+			continue
+		}
+
+		key := sampleKey{metric.Container, metric.PodName}
+		sample := Sample{
+			Value:     metric.Value,
+			Timestamp: metric.Timestamp,
+		}
+
+		samples := lastSamples[key]
+		if len(samples) > 0 {
+			last := samples[len(samples)-1]
+			if last.Timestamp.After(metric.Timestamp) {
+				continue
+			}
+
+			if last.Timestamp.Equal(metric.Timestamp) {
+				samples[len(samples)-1] = sample
+				continue
+			}
+		}
+
+		samples = append(samples, sample)
+		if len(samples) > samplesHistorySize {
+			samples = samples[len(samples)-samplesHistorySize:]
+		}
+
+		lastSamples[key] = samples
+	}
+}
+
+// purgeLastSamples drops samples of deleted containers and pods, pods are
+// matched by names since samples aren't keyed by namespaces
+func purgeLastSamples(deletion scanner.Deletion) {
+	if len(deletion.Containers) == 0 && len(deletion.Pods) == 0 {
+		return
+	}
+
+	containers := map[uuid.UUID]struct{}{}
+	for _, container := range deletion.Containers {
+		containers[container] = struct{}{}
+	}
+
+	pods := map[string]struct{}{}
+	for _, pod := range deletion.Pods {
+		pods[pod.Name] = struct{}{}
+	}
+
+	lastSamplesMutex.Lock()
+	defer lastSamplesMutex.Unlock()
+
+	for key := range lastSamples {
+		_, deletedContainer := containers[key.container]
+		_, deletedPod := pods[key.pod]
+		if deletedContainer || deletedPod {
+			delete(lastSamples, key)
+		}
+	}
+}
+
+// GetLastContainerSample returns the last collected memory rss sample of a
+// container of the pod
+func GetLastContainerSample(container uuid.UUID, pod string) (Sample, bool) {
+	lastSamplesMutex.Lock()
+	defer lastSamplesMutex.Unlock()
+
+	samples := lastSamples[sampleKey{container, pod}]
+	if len(samples) == 0 {
+		return Sample{}, false
+	}
+
+	return samples[len(samples)-1], true
+}
+
+// GetContainerSamples returns last collected memory rss samples of a
+// container of the pod ordered by timestamps
+func GetContainerSamples(container uuid.UUID, pod string) []Sample {
+	lastSamplesMutex.Lock()
+	defer lastSamplesMutex.Unlock()
+
+	samples := lastSamples[sampleKey{container, pod}]
+
+	return append([]Sample(nil), samples...)
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestStoreLastSamples(t *testing.T) {
+	container := uuid.NewV4()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var metrics []*Metrics
+	for i := 0; i < samplesHistorySize+2; i++ {
+		metrics = append(metrics, &Metrics{
+			Name:      "memory/rss",
+			Type:      TypePodContainer,
+			Container: container,
+			PodName:   "api-1",
+			Timestamp: now.Add(time.Duration(i) * time.Minute),
+			Value:     int64(i),
+		})
+	}
+
+	storeLastSamples(metrics)
+
+	// NOTE: older samples are ignored and samples of the same time replace
+	// the previous ones
+	storeLastSamples([]*Metrics{
+		{
+			Name:      "memory/rss",
+			Type:      TypePodContainer,
+			Container: container,
+			PodName:   "api-1",
+			Timestamp: now,
+			Value:     100,
+		},
+		{
+			Name:      "memory/rss",
+			Type:      TypePodContainer,
+			Container: container,
+			PodName:   "api-1",
+			Timestamp: now.Add(time.Duration(samplesHistorySize+1) * time.Minute),
+			Value:     200,
+		},
+	})
+
+	samples := GetContainerSamples(container, "api-1")
+	if len(samples) != samplesHistorySize {
+		t.Fatalf("expected %d samples, got %d", samplesHistorySize, len(samples))
+	}
+
+	if samples[0].Value != 2 {
+		t.Errorf("expected oldest sample 2, got %d", samples[0].Value)
+	}
+
+	last, ok := GetLastContainerSample(container, "api-1")
+	if !ok {
+		t.Fatalf("last sample is not found")
+	}
+
+	expected := Sample{
+		Value:     200,
+		Timestamp: now.Add(time.Duration(samplesHistorySize+1) * time.Minute),
+	}
+	if !reflect.DeepEqual(last, expected) {
+		t.Errorf("expected last sample %+v, got %+v", expected, last)
+	}
+
+	if _, ok := GetLastContainerSample(uuid.NewV4(), "api-1"); ok {
+		t.Errorf("unexpected sample of unknown container")
+	}
+}
+
+func TestStoreLastSamplesPerPod(t *testing.T) {
+	container := uuid.NewV4()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	storeLastSamples([]*Metrics{
+		{
+			Name:      "memory/rss",
+			Type:      TypePodContainer,
+			Container: container,
+			PodName:   "api-1",
+			Timestamp: now,
+			Value:     100,
+		},
+		{
+			Name:      "memory/rss",
+			Type:      TypePodContainer,
+			Container: container,
+			PodName:   "api-2",
+			Timestamp: now,
+			Value:     200,
+		},
+		{
+			Name:      "cpu/usage",
+			Type:      TypePodContainer,
+			Container: container,
+			PodName:   "api-1",
+			Timestamp: now.Add(time.Minute),
+			Value:     300,
+		},
+	})
+
+	for pod, expected := range map[string]int64{"api-1": 100, "api-2": 200} {
+		samples := GetContainerSamples(container, pod)
+		if len(samples) != 1 || samples[0].Value != expected {
+			t.Errorf("expected sample %d of pod %s, got %+v", expected, pod, samples)
+		}
+	}
+
+	purgeLastSamples(scanner.Deletion{
+		Pods: []proto.PacketDeletedPod{{Namespace: "default", Name: "api-1"}},
+	})
+
+	if _, ok := GetLastContainerSample(container, "api-1"); ok {
+		t.Errorf("unexpected sample of deleted pod")
+	}
+
+	if _, ok := GetLastContainerSample(container, "api-2"); !ok {
+		t.Errorf("sample of another pod is purged")
+	}
+
+	purgeLastSamples(scanner.Deletion{Containers: []uuid.UUID{container}})
+
+	if _, ok := GetLastContainerSample(container, "api-2"); ok {
+		t.Errorf("unexpected sample of deleted container")
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+)
+
+// scrapeErrors errors of nodes scrapes, nil for successful scrapes
+type scrapeErrors []error
+
+// AllNil checks whether all scrapes succeeded
+func (errs scrapeErrors) AllNil() bool {
+	for _, err := range errs {
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// nodesScheduler scrapes nodes with limited concurrency, scrapes are spread
+// over a window with a random jitter to avoid scraping all nodes at once
+type nodesScheduler struct {
+	maxConcurrency int
+	window         time.Duration
+}
+
+// nodesScrape scheduled scrapes of nodes
+type nodesScrape struct {
+	scheduler *nodesScheduler
+	nodes     []kuber.Node
+	fn        func(node kuber.Node) error
+}
+
+func newNodesScheduler(maxConcurrency int, window time.Duration) *nodesScheduler {
+	return &nodesScheduler{
+		maxConcurrency: maxConcurrency,
+		window:         window,
+	}
+}
+
+func (scheduler *nodesScheduler) schedule(
+	nodes []kuber.Node,
+	fn func(node kuber.Node) error,
+) *nodesScrape {
+	return &nodesScrape{
+		scheduler: scheduler,
+		nodes:     nodes,
+		fn:        fn,
+	}
+}
+
+// getDelays returns start delay of each node, every node gets an equal slot
+// of the window and starts at a random point of its slot
+func (scheduler *nodesScheduler) getDelays(count int) []time.Duration {
+	delays := make([]time.Duration, count)
+	if count == 0 || scheduler.window <= 0 {
+		return delays
+	}
+
+	slot := scheduler.window / time.Duration(count)
+	for i := range delays {
+		delays[i] = slot * time.Duration(i)
+		if slot > 0 {
+			delays[i] += time.Duration(rand.Int63n(int64(slot)))
+		}
+	}
+
+	return delays
+}
+
+// Do scrapes all nodes, it blocks until all scrapes are finished
+func (scrape *nodesScrape) Do() scrapeErrors {
+	errs := make(scrapeErrors, len(scrape.nodes))
+
+	concurrency := scrape.scheduler.maxConcurrency
+	if concurrency <= 0 || concurrency > len(scrape.nodes) {
+		concurrency = len(scrape.nodes)
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+	delays := scrape.scheduler.getDelays(len(scrape.nodes))
+
+	wg := &sync.WaitGroup{}
+	for i, node := range scrape.nodes {
+		wg.Add(1)
+		go func(i int, node kuber.Node) {
+			defer wg.Done()
+
+			time.Sleep(delays[i])
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			errs[i] = scrape.fn(node)
+		}(i, node)
+	}
+
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+)
+
+// DefaultSink sink used if no sinks are specified by --sink
+const DefaultSink = "gateway"
+
+// Sink receives collected metrics, sinks are called sequentially from the
+// sending goroutine so slow sinks delay others but never collection
+type Sink interface {
+	// SendChunk sends a chunk of metrics collected by a MetricsSource
+	SendChunk(chunk *MetricsChunk) error
+	// SendBatch sends metrics families collected by a Source
+	SendBatch(batch *MetricsBatch) error
+}
+
+// SinkOptions dependencies passed to metrics sinks factories
+type SinkOptions struct {
+	Client *client.Client
+	Args   map[string]interface{}
+}
+
+// SinkFactory creates a metrics sink
+type SinkFactory func(options SinkOptions) (Sink, error)
+
+var registeredSinks = map[string]SinkFactory{}
+
+// RegisterSink registers a metrics sink which can be enabled by --sink
+func RegisterSink(name string, factory SinkFactory) {
+	registeredSinks[name] = factory
+}
+
+// getRegisteredSinks returns sorted names of registered sinks
+func getRegisteredSinks() []string {
+	names := make([]string, 0, len(registeredSinks))
+	for name := range registeredSinks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// initSinks creates sinks specified by names
+func initSinks(names []string, options SinkOptions) (map[string]Sink, error) {
+	sinks := map[string]Sink{}
+	for _, name := range names {
+		factory, ok := registeredSinks[name]
+		if !ok {
+			return nil, karma.
+				Describe("registered", getRegisteredSinks()).
+				Format(nil, "unknown metrics sink %q", name)
+		}
+
+		sink, err := factory(options)
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to initialize %s sink",
+				name,
+			)
+		}
+
+		sinks[name] = sink
+	}
+
+	return sinks, nil
+}
+
+// gatewaySink sends metrics to the gateway
+type gatewaySink struct {
+	client *client.Client
+}
+
+func (sink *gatewaySink) SendChunk(chunk *MetricsChunk) error {
+	sendMetricsBatch(sink.client, chunk)
+	return nil
+}
+
+func (sink *gatewaySink) SendBatch(batch *MetricsBatch) error {
+	sink.client.Pipe(client.Package{
+		Kind:        proto.PacketKindMetricsPromStoreRequest,
+		ExpiryTime:  utils.After(2 * time.Hour),
+		ExpiryCount: 100,
+		Priority:    4,
+		Retries:     10,
+		Data:        packetMetricsProm(batch),
+	})
+	return nil
+}
+
+func init() {
+	RegisterSink(DefaultSink, func(options SinkOptions) (Sink, error) {
+		return &gatewaySink{client: options.Client}, nil
+	})
+
+	RegisterSink("file", func(options SinkOptions) (Sink, error) {
+		path, _ := options.Args["--sink-file"].(string)
+		if path == "" {
+			return nil, karma.Format(nil, "--sink-file is required by file sink")
+		}
+
+		return NewFileSink(path)
+	})
+
+	RegisterSink("influxdb", func(options SinkOptions) (Sink, error) {
+		url, _ := options.Args["--sink-influxdb-url"].(string)
+		if url == "" {
+			return nil, karma.Format(nil, "--sink-influxdb-url is required by influxdb sink")
+		}
+
+		return NewInfluxDBSink(
+			url,
+			utils.MustParseDuration(options.Args, "--sink-timeout"),
+		), nil
+	})
+
+	RegisterSink("otlp", func(options SinkOptions) (Sink, error) {
+		url, _ := options.Args["--sink-otlp-url"].(string)
+		if url == "" {
+			return nil, karma.Format(nil, "--sink-otlp-url is required by otlp sink")
+		}
+
+		return NewOTLPSink(
+			url,
+			utils.MustParseDuration(options.Args, "--sink-timeout"),
+		), nil
+	})
+}
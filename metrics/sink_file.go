@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+)
+
+// FileSink appends metrics to a file, one JSON encoded chunk or batch per
+// line
+type FileSink struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileSink creates a sink writing to the specified file
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to open metrics file %s",
+			path,
+		)
+	}
+
+	return &FileSink{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (sink *FileSink) write(record interface{}) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	err := sink.encoder.Encode(record)
+	if err != nil {
+		return karma.Format(err, "unable to write metrics to file")
+	}
+
+	return nil
+}
+
+// SendChunk writes the chunk to the file
+func (sink *FileSink) SendChunk(chunk *MetricsChunk) error {
+	return sink.write(map[string]interface{}{
+		"chunk": chunk,
+	})
+}
+
+// SendBatch writes the batch to the file
+func (sink *FileSink) SendBatch(batch *MetricsBatch) error {
+	return sink.write(map[string]interface{}{
+		"batch": batch,
+	})
+}
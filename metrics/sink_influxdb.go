@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// InfluxDBSink writes metrics to InfluxDB using the line protocol, the url
+// should point to the write endpoint including the database, e.g.
+// http://influxdb:8086/write?db=magalix
+type InfluxDBSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewInfluxDBSink creates a sink writing to the specified url
+func NewInfluxDBSink(url string, timeout time.Duration) *InfluxDBSink {
+	return &InfluxDBSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// SendChunk writes metrics of the chunk
+func (sink *InfluxDBSink) SendChunk(chunk *MetricsChunk) error {
+	var buffer bytes.Buffer
+	for _, metric := range chunk.Metrics {
+		tags := map[string]string{"type": metric.Type}
+		setEntityTag(tags, "node", metric.Node)
+		setEntityTag(tags, "application", metric.Application)
+		setEntityTag(tags, "service", metric.Service)
+		setEntityTag(tags, "container", metric.Container)
+		if metric.PodName != "" {
+			tags["pod"] = metric.PodName
+		}
+		for key, value := range metric.AdditionalTags {
+			tags[key] = fmt.Sprint(value)
+		}
+
+		writeInfluxLine(
+			&buffer,
+			metric.Name,
+			tags,
+			fmt.Sprintf("%di", metric.Value),
+			metric.Timestamp,
+		)
+	}
+
+	return sink.write(buffer.Bytes())
+}
+
+// SendBatch writes metrics families of the batch
+func (sink *InfluxDBSink) SendBatch(batch *MetricsBatch) error {
+	var buffer bytes.Buffer
+	for _, family := range batch.Metrics {
+		for _, value := range family.Values {
+			tags := map[string]string{}
+			for key, tag := range value.Tags {
+				tags[key] = tag
+			}
+			if value.Entities != nil {
+				setEntityTagPointer(tags, "node", value.Node)
+				setEntityTagPointer(tags, "application", value.Application)
+				setEntityTagPointer(tags, "service", value.Service)
+				setEntityTagPointer(tags, "container", value.Container)
+			}
+
+			writeInfluxLine(
+				&buffer,
+				family.Name,
+				tags,
+				fmt.Sprint(value.Value),
+				batch.Timestamp,
+			)
+		}
+	}
+
+	return sink.write(buffer.Bytes())
+}
+
+func (sink *InfluxDBSink) write(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	response, err := sink.client.Post(sink.url, "text/plain", bytes.NewReader(data))
+	if err != nil {
+		return karma.Format(err, "unable to write metrics to influxdb")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return karma.
+			Describe("status", response.Status).
+			Describe("body", string(body)).
+			Format(nil, "influxdb rejected metrics")
+	}
+
+	return nil
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// writeInfluxLine writes a single point in the line protocol, tags are
+// sorted as recommended for write performance
+func writeInfluxLine(
+	buffer *bytes.Buffer,
+	measurement string,
+	tags map[string]string,
+	value string,
+	timestamp time.Time,
+) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		if tags[key] != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	buffer.WriteString(influxEscaper.Replace(measurement))
+	for _, key := range keys {
+		buffer.WriteString(",")
+		buffer.WriteString(influxEscaper.Replace(key))
+		buffer.WriteString("=")
+		buffer.WriteString(influxEscaper.Replace(tags[key]))
+	}
+	fmt.Fprintf(buffer, " value=%s %d\n", value, timestamp.UnixNano())
+}
+
+func setEntityTag(tags map[string]string, name string, id uuid.UUID) {
+	if id != uuid.Nil {
+		tags[name] = id.String()
+	}
+}
+
+func setEntityTagPointer(tags map[string]string, name string, id *uuid.UUID) {
+	if id != nil {
+		setEntityTag(tags, name, *id)
+	}
+}
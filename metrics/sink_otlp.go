@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/otlp"
+	"github.com/reconquest/karma-go"
+)
+
+// OTLPSink exports metrics as gauges to an OpenTelemetry collector using
+// OTLP over HTTP with JSON encoding, the url should point to the metrics
+// endpoint, e.g. http://collector:4318/v1/metrics
+type OTLPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewOTLPSink creates a sink exporting to the specified url
+func NewOTLPSink(url string, timeout time.Duration) *OTLPSink {
+	return &OTLPSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// SendChunk exports metrics of the chunk
+func (sink *OTLPSink) SendChunk(chunk *MetricsChunk) error {
+	metrics := map[string]*otlp.Metric{}
+	for _, metric := range chunk.Metrics {
+		tags := map[string]string{"type": metric.Type}
+		setEntityTag(tags, "node", metric.Node)
+		setEntityTag(tags, "application", metric.Application)
+		setEntityTag(tags, "service", metric.Service)
+		setEntityTag(tags, "container", metric.Container)
+		if metric.PodName != "" {
+			tags["pod"] = metric.PodName
+		}
+		for key, value := range metric.AdditionalTags {
+			tags[key] = fmt.Sprint(value)
+		}
+
+		value := strconv.FormatInt(metric.Value, 10)
+		addOTLPDataPoint(metrics, metric.Name, "", otlp.DataPoint{
+			Attributes:   otlp.GetAttributes(tags),
+			TimeUnixNano: strconv.FormatInt(metric.Timestamp.UnixNano(), 10),
+			AsInt:        &value,
+		})
+	}
+
+	return sink.export(metrics)
+}
+
+// SendBatch exports metrics families of the batch
+func (sink *OTLPSink) SendBatch(batch *MetricsBatch) error {
+	metrics := map[string]*otlp.Metric{}
+	for _, family := range batch.Metrics {
+		for _, value := range family.Values {
+			tags := map[string]string{}
+			for key, tag := range value.Tags {
+				tags[key] = tag
+			}
+			if value.Entities != nil {
+				setEntityTagPointer(tags, "node", value.Node)
+				setEntityTagPointer(tags, "application", value.Application)
+				setEntityTagPointer(tags, "service", value.Service)
+				setEntityTagPointer(tags, "container", value.Container)
+			}
+
+			number := value.Value
+			addOTLPDataPoint(metrics, family.Name, family.Help, otlp.DataPoint{
+				Attributes:   otlp.GetAttributes(tags),
+				TimeUnixNano: strconv.FormatInt(batch.Timestamp.UnixNano(), 10),
+				AsDouble:     &number,
+			})
+		}
+	}
+
+	return sink.export(metrics)
+}
+
+func addOTLPDataPoint(
+	metrics map[string]*otlp.Metric,
+	name string,
+	description string,
+	point otlp.DataPoint,
+) {
+	metric, ok := metrics[name]
+	if !ok {
+		metric = &otlp.Metric{Name: name, Description: description}
+		metrics[name] = metric
+	}
+
+	metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+}
+
+func (sink *OTLPSink) export(metrics map[string]*otlp.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	scope := otlp.ScopeMetrics{
+		Scope:   otlp.Scope{Name: otlp.ScopeName},
+		Metrics: make([]otlp.Metric, 0, len(metrics)),
+	}
+	for _, metric := range metrics {
+		scope.Metrics = append(scope.Metrics, *metric)
+	}
+
+	err := otlp.Export(sink.client, sink.url, otlp.MetricsRequest{
+		ResourceMetrics: []otlp.ResourceMetrics{
+			{ScopeMetrics: []otlp.ScopeMetrics{scope}},
+		},
+	})
+	if err != nil {
+		return karma.Format(err, "unable to export metrics to otlp collector")
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteInfluxLine(t *testing.T) {
+	var buffer bytes.Buffer
+	writeInfluxLine(
+		&buffer,
+		"cpu/usage_rate",
+		map[string]string{
+			"type":      TypePodContainer,
+			"pod":       "api 1",
+			"namespace": "",
+			"label":     "a,b=c",
+		},
+		"150i",
+		time.Unix(1, 5),
+	)
+
+	expected := `cpu/usage_rate,label=a\,b\=c,pod=api\ 1,type=pod_container value=150i 1000000005` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestInitSinksUnknown(t *testing.T) {
+	_, err := initSinks([]string{"unknown"}, SinkOptions{})
+	if err == nil {
+		t.Errorf("expected error for unknown sink")
+	}
+}
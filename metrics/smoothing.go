@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+// defaultSmoothingFactor weight of the last interval rate in the smoothed
+// rate when the factor isn't specified
+const defaultSmoothingFactor = 0.3
+
+// smoothedRateFamilies families of rate metrics which can be smoothed
+var smoothedRateFamilies = map[string]struct{}{
+	"cpu":     {},
+	"network": {},
+}
+
+// rateSmoothing factors of exponentially smoothed rates by metrics family
+type rateSmoothing map[string]float64
+
+// parseRateSmoothing parses smoothing specs formatted as family[:factor]
+func parseRateSmoothing(specs []string) (rateSmoothing, error) {
+	smoothing := rateSmoothing{}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+
+		family := parts[0]
+		if _, ok := smoothedRateFamilies[family]; !ok {
+			return nil, karma.Format(
+				nil,
+				"unsupported rate smoothing family %q, expected cpu or network",
+				family,
+			)
+		}
+
+		factor := defaultSmoothingFactor
+		if len(parts) == 2 {
+			var err error
+			factor, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return nil, karma.Format(
+					err,
+					"unable to parse rate smoothing factor of %s",
+					family,
+				)
+			}
+
+			if factor <= 0 || factor > 1 {
+				return nil, karma.Format(
+					nil,
+					"rate smoothing factor of %s should be in (0, 1], got %v",
+					family, factor,
+				)
+			}
+		}
+
+		smoothing[family] = factor
+	}
+
+	return smoothing, nil
+}
+
+// getFactor returns the smoothing factor of the measurement family
+func (smoothing rateSmoothing) getFactor(measurement string) (float64, bool) {
+	family := strings.SplitN(measurement, "/", 2)[0]
+
+	factor, ok := smoothing[family]
+	return factor, ok
+}
+
+// smoothRate returns exponentially weighted moving average of the rate
+func smoothRate(previous, rate int64, factor float64) int64 {
+	return int64(factor*float64(rate) + (1-factor)*float64(previous))
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestParseRateSmoothing(t *testing.T) {
+	smoothing, err := parseRateSmoothing([]string{"cpu", "network:0.5"})
+	if err != nil {
+		t.Fatalf("parseRateSmoothing() error = %v", err)
+	}
+
+	for measurement, want := range map[string]float64{
+		"cpu/usage_rate":  defaultSmoothingFactor,
+		"network/rx_rate": 0.5,
+	} {
+		factor, ok := smoothing.getFactor(measurement)
+		if !ok || factor != want {
+			t.Errorf("getFactor(%q) = %v, %v, want %v", measurement, factor, ok, want)
+		}
+	}
+
+	if _, ok := smoothing.getFactor("memory/rss"); ok {
+		t.Errorf("getFactor(\"memory/rss\") is enabled, want disabled")
+	}
+
+	for _, specs := range [][]string{
+		{"memory"},
+		{"cpu:0"},
+		{"cpu:1.5"},
+		{"cpu:fast"},
+	} {
+		if _, err := parseRateSmoothing(specs); err == nil {
+			t.Errorf("parseRateSmoothing(%q) succeeded, want error", specs)
+		}
+	}
+}
+
+func TestSmoothRate(t *testing.T) {
+	smoothed := int64(100)
+	for _, rate := range []int64{1000, 100} {
+		smoothed = smoothRate(smoothed, rate, 0.5)
+	}
+
+	if smoothed != 325 {
+		t.Errorf("smoothRate() = %d, want 325", smoothed)
+	}
+}
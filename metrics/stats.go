@@ -36,6 +36,10 @@ const (
 	NodeAllocatablePodsName = "kube_node_status_allocatable_pods"
 	NodeAllocatablePodsHelp = "The pod resources of a node that are available for scheduling."
 
+	NodeConditionName = "stats_node_status_condition"
+	NodeConditionHelp = "Whether the node has the condition, 1 if it does and 0 otherwise."
+	NodeConditionTag  = "condition"
+
 	ContainerRequestsCpuName = "stats_container_resource_requests_cpu_cores"
 	ContainerRequestsCpuHelp = "The number of requested cpu cores by a magalix container."
 
@@ -52,6 +56,8 @@ const (
 	NamespaceTag = "namespace"
 	PodTag       = "pod_name"
 	ContainerTag = "container_name"
+
+	EnvironmentTag = "environment"
 )
 
 var (
@@ -90,7 +96,7 @@ func (stats *Stats) GetMetrics(tickTime time.Time) (
 			"{stats} requesting metrics from scanner",
 		)
 
-		nodes := stats.scanner.GetNodes()
+		nodes := stats.scanner.GetShardNodes()
 
 		nodesMetrics := appendFamily(
 			map[string]*MetricFamily{},
@@ -99,13 +105,14 @@ func (stats *Stats) GetMetrics(tickTime time.Time) (
 			instanceGroups(nodes),
 		)
 		nodesMetrics = mergeFamilies(nodesMetrics, nodesResources(nodes))
+		nodesMetrics = appendFamily(nodesMetrics, nodesConditions(nodes))
 		nodesBatch := &MetricsBatch{
 			Timestamp: tickTime,
 			Metrics:   nodesMetrics,
 		}
 		batchPipe <- nodesBatch
 
-		apps := stats.scanner.GetApplications()
+		apps := stats.scanner.GetShardApplications()
 
 		appsMetrics := mergeFamilies(
 			map[string]*MetricFamily{},
@@ -132,7 +139,7 @@ func containersResources(apps []*scanner.Application) map[string]*MetricFamily {
 
 	// TODO: track the host node of the container
 	// TODO per replica tracking
-	containersTagsNames := []string{NamespaceTag, PodTag, ContainerTag}
+	containersTagsNames := []string{NamespaceTag, PodTag, ContainerTag, EnvironmentTag}
 	containersRequestsCpu := &MetricFamily{
 		Name:   ContainerRequestsCpuName,
 		Help:   ContainerRequestsCpuHelp,
@@ -173,6 +180,9 @@ func containersResources(apps []*scanner.Application) map[string]*MetricFamily {
 				containerTags[NamespaceTag] = app.Name
 				containerTags[PodTag] = service.Name
 				containerTags[ContainerTag] = container.Name
+				if container.Environment != "" {
+					containerTags[EnvironmentTag] = container.Environment
+				}
 
 				containersRequestsCpu.Values = append(
 					containersRequestsCpu.Values,
@@ -379,3 +389,46 @@ func nodesResources(nodes []kuber.Node) map[string]*MetricFamily {
 
 	return nodesResources
 }
+
+func nodesConditions(nodes []kuber.Node) *MetricFamily {
+	family := &MetricFamily{
+		Name: NodeConditionName,
+		Help: NodeConditionHelp,
+		Type: TypeGAUGE,
+		Tags: []string{NodeTag, NodeConditionTag},
+
+		Values: []*MetricValue{},
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+
+		for _, condition := range []struct {
+			Name   string
+			Status bool
+		}{
+			{"MemoryPressure", node.Conditions.MemoryPressure},
+			{"DiskPressure", node.Conditions.DiskPressure},
+			{"PIDPressure", node.Conditions.PIDPressure},
+			{"NotReady", node.Conditions.NotReady},
+		} {
+			value := 0.0
+			if condition.Status {
+				value = 1
+			}
+
+			family.Values = append(family.Values, &MetricValue{
+				Entities: &Entities{
+					Node: &node.ID,
+				},
+				Tags: map[string]string{
+					NodeTag:          node.Name,
+					NodeConditionTag: condition.Name,
+				},
+				Value: value,
+			})
+		}
+	}
+
+	return family
+}
@@ -0,0 +1,163 @@
+// Package otlp provides payloads of OTLP over HTTP with JSON encoding shared
+// by exporters of spans and metrics
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// ScopeName name of the instrumentation scope of exported spans and
+	// metrics
+	ScopeName = "magalix-agent"
+
+	// SpanKindInternal kind of spans of internal operations
+	SpanKindInternal = 1
+	// StatusCodeError status code of failed spans
+	StatusCodeError = 2
+)
+
+// TracesRequest request of the traces endpoint
+type TracesRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans spans of a resource
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+// MetricsRequest request of the metrics endpoint
+type MetricsRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+// ResourceMetrics metrics of a resource
+type ResourceMetrics struct {
+	Resource     *Resource      `json:"resource,omitempty"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+// Resource attributes of the exporting process
+type Resource struct {
+	Attributes []Attribute `json:"attributes"`
+}
+
+// Scope instrumentation scope
+type Scope struct {
+	Name string `json:"name"`
+}
+
+// ScopeSpans spans of an instrumentation scope
+type ScopeSpans struct {
+	Scope Scope  `json:"scope"`
+	Spans []Span `json:"spans"`
+}
+
+// ScopeMetrics metrics of an instrumentation scope
+type ScopeMetrics struct {
+	Scope   Scope    `json:"scope"`
+	Metrics []Metric `json:"metrics"`
+}
+
+// Span a finished span, timestamps are nanoseconds since epoch
+type Span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []Attribute `json:"attributes,omitempty"`
+	Status            *Status     `json:"status,omitempty"`
+}
+
+// Status status of a span
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Metric a gauge metric
+type Metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Gauge       Gauge  `json:"gauge"`
+}
+
+// Gauge data points of a gauge metric
+type Gauge struct {
+	DataPoints []DataPoint `json:"dataPoints"`
+}
+
+// DataPoint value of a metric, either AsInt or AsDouble is set
+type DataPoint struct {
+	Attributes   []Attribute `json:"attributes,omitempty"`
+	TimeUnixNano string      `json:"timeUnixNano"`
+	AsInt        *string     `json:"asInt,omitempty"`
+	AsDouble     *float64    `json:"asDouble,omitempty"`
+}
+
+// Attribute string attribute
+type Attribute struct {
+	Key   string         `json:"key"`
+	Value AttributeValue `json:"value"`
+}
+
+// AttributeValue value of a string attribute
+type AttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// GetAttributes returns attributes sorted by keys, empty values are skipped
+func GetAttributes(values map[string]string) []Attribute {
+	attributes := make([]Attribute, 0, len(values))
+	for key, value := range values {
+		if value == "" {
+			continue
+		}
+
+		attributes = append(attributes, Attribute{
+			Key:   key,
+			Value: AttributeValue{StringValue: value},
+		})
+	}
+
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Key < attributes[j].Key
+	})
+
+	return attributes
+}
+
+// Export posts the request to the collector url
+func Export(client *http.Client, url string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return karma.Format(err, "unable to encode otlp request")
+	}
+
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return karma.Format(err, "unable to post otlp request to %s", url)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(response.Body)
+		return karma.
+			Describe("status", response.Status).
+			Describe("body", string(data)).
+			Format(nil, "otlp collector rejected request")
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+const pingSessionTimeout = 30 * time.Second
+
+// runPing checks that the agent can reach and authorize in the gateway
+// using the given credentials
+func runPing(
+	args map[string]interface{},
+	logger *log.Logger,
+	credentials client.Credentials,
+) error {
+	session, err := client.NewSession(
+		args, version, startID, credentials, logger, pingSessionTimeout,
+	)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := session.Close("ping finished")
+		if err != nil {
+			logger.Errorf(err, "unable to close gateway session")
+		}
+	}()
+
+	latency, err := session.Ping()
+	if err != nil {
+		return karma.Format(err, "unable to send ping-pong request to gateway")
+	}
+
+	logger.Infof(
+		karma.
+			Describe("gateway", args["--gateway"]).
+			Describe("latency", latency),
+		"gateway is reachable and the agent is authorized",
+	)
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+// Engine keeps the policy, the policy is read from the file if it's
+// specified, e.g. a mounted ConfigMap, and reloaded when the file changes,
+// the policy specified by flags is used otherwise
+type Engine struct {
+	logger *log.Logger
+	path   string
+
+	mutex   sync.Mutex
+	modTime time.Time
+	policy  Policy
+}
+
+var engine *Engine
+
+// SetEngine sets the engine used by Get, decisions are never checked unless
+// an engine is set
+func SetEngine(value *Engine) {
+	engine = value
+}
+
+// Get returns the current policy, nil is returned if no engine is set
+func Get() *Policy {
+	if engine == nil {
+		return nil
+	}
+
+	policy := engine.GetPolicy()
+	return &policy
+}
+
+// NewEngine creates a new engine, the policy of the file replaces the policy
+// of flags as a whole
+func NewEngine(logger *log.Logger, policy Policy, path string) (*Engine, error) {
+	err := policy.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		logger: logger,
+		path:   path,
+		policy: policy,
+	}
+
+	if path == "" {
+		return engine, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, karma.Format(err, "unable to stat policy file %s", path)
+	}
+
+	policy, err = readPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.policy = policy
+	engine.modTime = stat.ModTime()
+
+	return engine, nil
+}
+
+// GetPolicy returns the current policy, the file is reloaded if it's
+// modified, the previous policy is kept if the file is broken
+func (engine *Engine) GetPolicy() Policy {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if engine.path == "" {
+		return engine.policy
+	}
+
+	stat, err := os.Stat(engine.path)
+	if err != nil {
+		engine.logger.Errorf(
+			err,
+			"{policy} unable to stat policy file, using previous policy",
+		)
+		return engine.policy
+	}
+
+	if stat.ModTime().Equal(engine.modTime) {
+		return engine.policy
+	}
+
+	policy, err := readPolicy(engine.path)
+	if err != nil {
+		engine.logger.Errorf(err, "{policy} using previous policy")
+		return engine.policy
+	}
+
+	engine.logger.Infof(
+		karma.Describe("path", engine.path),
+		"{policy} policy is reloaded",
+	)
+
+	engine.policy = policy
+	engine.modTime = stat.ModTime()
+
+	return engine.policy
+}
+
+func readPolicy(path string) (Policy, error) {
+	var policy Policy
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return policy, karma.Format(err, "unable to read policy file %s", path)
+	}
+
+	err = json.Unmarshal(data, &policy)
+	if err != nil {
+		return policy, karma.Format(err, "unable to decode policy file %s", path)
+	}
+
+	err = policy.compile()
+	if err != nil {
+		return policy, karma.Format(err, "invalid policy file %s", path)
+	}
+
+	return policy, nil
+}
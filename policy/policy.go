@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/freeze"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// ResourceCPU cpu in millicores
+	ResourceCPU = "cpu"
+	// ResourceMemory memory in mebibytes
+	ResourceMemory = "memory"
+)
+
+// Range bounds of a value, zero bounds are not checked
+type Range struct {
+	Min int64 `json:"min,omitempty"`
+	Max int64 `json:"max,omitempty"`
+}
+
+// Policy guardrails checked before execution of decisions, rules with zero
+// values are disabled
+type Policy struct {
+	// MaxChangePercent max change of replicas and resources relative to
+	// live values of the workload per decision
+	MaxChangePercent int64 `json:"max_change_percent,omitempty"`
+	// CPU bounds of decided cpu requests and limits in millicores
+	CPU Range `json:"cpu,omitempty"`
+	// Memory bounds of decided memory requests and limits in mebibytes
+	Memory Range `json:"memory,omitempty"`
+	// ForbiddenNamespaces decisions of workloads in the namespaces are
+	// never executed
+	ForbiddenNamespaces []string `json:"forbidden_namespaces,omitempty"`
+	// Windows decisions are executed only within the windows, specified
+	// like quiet hours, e.g. "09:00-17:00 Mon-Fri TZ=Europe/Berlin"
+	Windows string `json:"windows,omitempty"`
+
+	windows *freeze.QuietHours
+}
+
+// compile parses windows of the policy
+func (policy *Policy) compile() error {
+	policy.windows = nil
+	if policy.Windows == "" {
+		return nil
+	}
+
+	windows, err := freeze.ParseQuietHours(policy.Windows)
+	if err != nil {
+		return karma.Format(err, "unable to parse windows of policy")
+	}
+
+	policy.windows = windows
+
+	return nil
+}
+
+// CheckNamespace returns the violation if decisions of the namespace are
+// forbidden, empty string is returned otherwise
+func (policy *Policy) CheckNamespace(namespace string) string {
+	for _, forbidden := range policy.ForbiddenNamespaces {
+		if forbidden == namespace {
+			return fmt.Sprintf("namespace %s is forbidden", namespace)
+		}
+	}
+
+	return ""
+}
+
+// CheckTime returns the violation if the time is out of windows
+func (policy *Policy) CheckTime(now time.Time) string {
+	if policy.windows == nil || policy.windows.Contains(now) {
+		return ""
+	}
+
+	return fmt.Sprintf("execution is allowed only within %s", policy.windows)
+}
+
+// CheckResource returns the violation if the decided value of the resource
+// is out of bounds
+func (policy *Policy) CheckResource(name, resource string, value int64) string {
+	bounds := policy.CPU
+	if resource == ResourceMemory {
+		bounds = policy.Memory
+	}
+
+	if bounds.Min > 0 && value < bounds.Min {
+		return fmt.Sprintf("%s %d is below minimum %d", name, value, bounds.Min)
+	}
+
+	if bounds.Max > 0 && value > bounds.Max {
+		return fmt.Sprintf("%s %d is above maximum %d", name, value, bounds.Max)
+	}
+
+	return ""
+}
+
+// CheckChange returns the violation if the value changes the live value more
+// than allowed per decision, changes of unset values are not checked
+func (policy *Policy) CheckChange(name string, current, value int64) string {
+	if policy.MaxChangePercent <= 0 || current <= 0 {
+		return ""
+	}
+
+	change := value - current
+	if change < 0 {
+		change = -change
+	}
+
+	if change*100 > current*policy.MaxChangePercent {
+		return fmt.Sprintf(
+			"%s %d -> %d changes by more than %d%%",
+			name, current, value, policy.MaxChangePercent,
+		)
+	}
+
+	return ""
+}
+
+// ParseRange parses bounds specified as min-max, either bound can be
+// omitted, e.g. 100-4000 or -4000
+func ParseRange(spec string) (Range, error) {
+	var bounds Range
+	if spec == "" {
+		return bounds, nil
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return bounds, karma.
+			Describe("range", spec).
+			Format(nil, "range should be specified as min-max")
+	}
+
+	for i, target := range []*int64{&bounds.Min, &bounds.Max} {
+		if parts[i] == "" {
+			continue
+		}
+
+		value, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil || value < 0 {
+			return bounds, karma.
+				Describe("range", spec).
+				Format(err, "bounds of range should be non-negative integers")
+		}
+
+		*target = value
+	}
+
+	if bounds.Max > 0 && bounds.Min > bounds.Max {
+		return bounds, karma.
+			Describe("range", spec).
+			Format(nil, "minimum of range is above maximum")
+	}
+
+	return bounds, nil
+}
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	for spec, expected := range map[string]Range{
+		"":         {},
+		"100-4000": {Min: 100, Max: 4000},
+		"-4000":    {Max: 4000},
+		"100-":     {Min: 100},
+	} {
+		bounds, err := ParseRange(spec)
+		if err != nil {
+			t.Errorf("unexpected error of %q: %s", spec, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(bounds, expected) {
+			t.Errorf("expected range of %q %+v, got %+v", spec, expected, bounds)
+		}
+	}
+
+	for _, spec := range []string{"100", "a-b", "4000-100"} {
+		if _, err := ParseRange(spec); err == nil {
+			t.Errorf("expected error of %q", spec)
+		}
+	}
+}
+
+func TestPolicyChecks(t *testing.T) {
+	policy := Policy{
+		MaxChangePercent:    50,
+		CPU:                 Range{Min: 100, Max: 4000},
+		Memory:              Range{Max: 8192},
+		ForbiddenNamespaces: []string{"kube-system"},
+		Windows:             "09:00-17:00 Mon-Fri TZ=UTC",
+	}
+
+	err := policy.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if policy.CheckNamespace("kube-system") == "" {
+		t.Errorf("forbidden namespace is allowed")
+	}
+
+	if policy.CheckNamespace("default") != "" {
+		t.Errorf("namespace is forbidden")
+	}
+
+	monday := time.Date(2019, time.July, 1, 10, 0, 0, 0, time.UTC)
+	if policy.CheckTime(monday) != "" {
+		t.Errorf("time within windows is not allowed")
+	}
+
+	if policy.CheckTime(monday.Add(8*time.Hour)) == "" {
+		t.Errorf("time out of windows is allowed")
+	}
+
+	if policy.CheckResource("api requests.cpu", ResourceCPU, 50) == "" {
+		t.Errorf("cpu below minimum is allowed")
+	}
+
+	if policy.CheckResource("api limits.memory", ResourceMemory, 16384) == "" {
+		t.Errorf("memory above maximum is allowed")
+	}
+
+	if policy.CheckResource("api requests.memory", ResourceMemory, 10) != "" {
+		t.Errorf("memory without minimum is not allowed")
+	}
+
+	if policy.CheckChange("replicas", 4, 6) != "" {
+		t.Errorf("change within max change is not allowed")
+	}
+
+	if policy.CheckChange("replicas", 4, 1) == "" {
+		t.Errorf("change above max change is allowed")
+	}
+
+	if policy.CheckChange("api limits.cpu", 0, 1000) != "" {
+		t.Errorf("change of unset value is not allowed")
+	}
+}
@@ -1,7 +1,9 @@
 package proc
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -10,17 +12,16 @@ import (
 	"github.com/reconquest/health-go"
 	karma "github.com/reconquest/karma-go"
 	"github.com/reconquest/stats-go"
+	kapps "k8s.io/api/apps/v1"
 	kbeta2 "k8s.io/api/apps/v1beta2"
 	kbeta1 "k8s.io/api/batch/v1beta1"
 	kapi "k8s.io/api/core/v1"
-	kext "k8s.io/api/extensions/v1beta1"
 	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kfields "k8s.io/apimachinery/pkg/fields"
 	kruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	kutilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
-	beta2client "k8s.io/client-go/kubernetes/typed/apps/v1beta2"
 	beta1batchclient "k8s.io/client-go/kubernetes/typed/batch/v1beta1"
 	"k8s.io/client-go/rest"
 	kcache "k8s.io/client-go/tools/cache"
@@ -29,7 +30,8 @@ import (
 // Observer kubernets objects observer
 type Observer struct {
 	clientset     *kubernetes.Clientset
-	clientV1Beta2 *beta2client.AppsV1beta2Client
+	apps          rest.Interface
+	appsV1beta2   bool
 	batchV1Beta1  *beta1batchclient.BatchV1beta1Client
 	pods          chan Pod
 	replicas      chan ReplicaSpec
@@ -39,17 +41,20 @@ type Observer struct {
 	syncer *Syncer
 }
 
-// NewObserver creates a new observer
+// NewObserver creates a new observer, workloads are watched using the apps
+// client of the group version served by the cluster, apps/v1 or apps/v1beta2
 func NewObserver(
 	clientset *kubernetes.Clientset,
-	clientV1Beta2 *beta2client.AppsV1beta2Client,
+	apps rest.Interface,
+	appsVersion string,
 	batchV1Beta1 *beta1batchclient.BatchV1beta1Client,
 	identificator Identificator,
 	health *health.Health,
 ) *Observer {
 	observer := &Observer{
 		clientset:     clientset,
-		clientV1Beta2: clientV1Beta2,
+		apps:          apps,
+		appsV1beta2:   appsVersion == kbeta2.SchemeGroupVersion.String(),
 		batchV1Beta1:  batchV1Beta1,
 		pods:          make(chan Pod),
 		replicas:      make(chan ReplicaSpec),
@@ -214,16 +219,16 @@ func (observer *Observer) watchDeployments(
 ) {
 	infof(nil, "{kubernetes} starting observer of deployments")
 
-	observer.watch(
+	observer.watchApps(
 		watchers,
 		stopCh,
-		observer.clientset.ExtensionsV1beta1().RESTClient(),
 		"deployment",
-		&kext.Deployment{},
+		&kapps.Deployment{},
+		&kbeta2.Deployment{},
 
 		func(obj interface{}) {
 			err := observer.handleDeployment(
-				obj.(*kext.Deployment),
+				obj.(*kapps.Deployment),
 			)
 			if err != nil {
 				errorf(err, "{kubernetes} unable to handle deployment")
@@ -253,16 +258,16 @@ func (observer *Observer) watchStatefulSets(
 
 	infof(nil, "{kubernetes} starting observer of statefulSets")
 
-	observer.watch(
+	observer.watchApps(
 		watchers,
 		stopCh,
-		observer.clientV1Beta2.RESTClient(),
 		"statefulset",
+		&kapps.StatefulSet{},
 		&kbeta2.StatefulSet{},
 
 		func(obj interface{}) {
 			err := observer.handleStatefulSet(
-				obj.(*kbeta2.StatefulSet),
+				obj.(*kapps.StatefulSet),
 			)
 			if err != nil {
 				errorf(err, "{kubernetes} unable to handle statefulSet")
@@ -286,7 +291,7 @@ func (observer *Observer) watchStatefulSets(
 }
 
 func (observer *Observer) handleStatefulSet(
-	statefulset *kbeta2.StatefulSet,
+	statefulset *kapps.StatefulSet,
 ) error {
 	// specify until they fix it
 	// https://github.com/kubernetes/client-go/issues/413
@@ -339,16 +344,16 @@ func (observer *Observer) watchDaemonSets(
 
 	infof(nil, "{kubernetes} starting observer of daemonSets")
 
-	observer.watch(
+	observer.watchApps(
 		watchers,
 		stopCh,
-		observer.clientset.ExtensionsV1beta1().RESTClient(),
 		"daemonset",
-		&kext.DaemonSet{},
+		&kapps.DaemonSet{},
+		&kbeta2.DaemonSet{},
 
 		func(obj interface{}) {
 			err := observer.handleDaemonSet(
-				obj.(*kext.DaemonSet),
+				obj.(*kapps.DaemonSet),
 			)
 			if err != nil {
 				errorf(err, "{kubernetes} unable to handle daemonSet")
@@ -372,7 +377,7 @@ func (observer *Observer) watchDaemonSets(
 }
 
 func (observer *Observer) handleDaemonSet(
-	daemonset *kext.DaemonSet,
+	daemonset *kapps.DaemonSet,
 ) error {
 	// specify until they fix it
 	// https://github.com/kubernetes/client-go/issues/413
@@ -413,6 +418,45 @@ func (observer *Observer) handleDaemonSet(
 	return nil
 }
 
+// watchApps watches workloads using the apps client, objects of
+// apps/v1beta2 are converted into apps/v1 objects before processing as both
+// group versions share the same schema
+func (observer *Observer) watchApps(
+	watchers *sync.WaitGroup,
+	stopCh chan struct{},
+	resource string,
+	object kruntime.Object,
+	legacy kruntime.Object,
+	process func(interface{}),
+) {
+	if !observer.appsV1beta2 {
+		observer.watch(watchers, stopCh, observer.apps, resource, object, process)
+		return
+	}
+
+	observer.watch(
+		watchers,
+		stopCh,
+		observer.apps,
+		resource,
+		legacy,
+		func(obj interface{}) {
+			converted := reflect.New(reflect.TypeOf(object).Elem()).Interface()
+
+			data, err := json.Marshal(obj)
+			if err == nil {
+				err = json.Unmarshal(data, converted)
+			}
+			if err != nil {
+				errorf(err, "{kubernetes} unable to convert %s to apps/v1", resource)
+				return
+			}
+
+			process(converted)
+		},
+	)
+}
+
 func (observer *Observer) watch(
 	watchers *sync.WaitGroup,
 	stopCh chan struct{},
@@ -583,7 +627,7 @@ func (observer *Observer) handleReplicationController(
 }
 
 func (observer *Observer) handleDeployment(
-	deployment *kext.Deployment,
+	deployment *kapps.Deployment,
 ) error {
 	// specify until they fix it
 	// https://github.com/kubernetes/client-go/issues/413
@@ -727,20 +771,20 @@ func (observer *Observer) watchReplicaSets(
 
 	infof(nil, "{kubernetes} starting observer of replicaSets")
 
-	observer.watch(
+	observer.watchApps(
 		watchers,
 		stopCh,
-		observer.clientV1Beta2.RESTClient(),
 		"replicaset",
+		&kapps.ReplicaSet{},
 		&kbeta2.ReplicaSet{},
 
 		func(obj interface{}) {
 			// skip watching replica sets that are controlled of other controllers
-			if rs := obj.(*kbeta2.ReplicaSet); len(rs.OwnerReferences) > 0 {
+			if rs := obj.(*kapps.ReplicaSet); len(rs.OwnerReferences) > 0 {
 				return
 			}
 			err := observer.handleReplicaSet(
-				obj.(*kbeta2.ReplicaSet),
+				obj.(*kapps.ReplicaSet),
 			)
 			if err != nil {
 				errorf(err, "{kubernetes} unable to handle replicaSet")
@@ -764,7 +808,7 @@ func (observer *Observer) watchReplicaSets(
 }
 
 func (observer *Observer) handleReplicaSet(
-	replicaset *kbeta2.ReplicaSet,
+	replicaset *kapps.ReplicaSet,
 ) error {
 	// specify until they fix it
 	// https://github.com/kubernetes/client-go/issues/413
@@ -11,15 +11,32 @@ const (
 	PacketKindAuthorizationFailure  PacketKind = "authorization/failure"
 	PacketKindAuthorizationSuccess  PacketKind = "authorization/success"
 
-	PacketKindLogs PacketKind = "logs"
+	PacketKindClusterAttach PacketKind = "cluster/attach"
+	PacketKindClusterPacket PacketKind = "cluster/packet"
 
-	PacketKindMetricsStoreRequest     PacketKind = "metrics/store"
-	PacketKindMetricsPromStoreRequest PacketKind = "metrics/prom/store"
+	PacketKindLogs     PacketKind = "logs"
+	PacketKindLogLevel PacketKind = "logs/level"
+
+	PacketKindMetricsStoreRequest      PacketKind = "metrics/store"
+	PacketKindMetricsStoreChunkRequest PacketKind = "metrics/store/chunk"
+	PacketKindMetricsPromStoreRequest  PacketKind = "metrics/prom/store"
 
 	PacketKindApplicationsStoreRequest PacketKind = "applications/store"
+	PacketKindApplicationsDeltaRequest PacketKind = "applications/delta"
+	PacketKindEntitiesDeleted          PacketKind = "entities/deleted"
+	PacketKindEntitiesWarmup           PacketKind = "entities/warmup"
 
 	PacketKindNodesStoreRequest PacketKind = "nodes/store"
 
+	PacketKindVPARecommendationsStoreRequest PacketKind = "vpa/recommendations/store"
+
+	PacketKindNamespacesSummaryStoreRequest PacketKind = "namespaces/summary/store"
+
+	PacketKindClusterCapacity PacketKind = "cluster/capacity"
+
+	PacketKindKubernetesThrottling   PacketKind = "kubernetes/throttling"
+	PacketKindKubernetesCapabilities PacketKind = "kubernetes/capabilities"
+
 	PacketKindEventLastValueRequest PacketKind = "events/query/last_value"
 	PacketKindEventsStoreRequest    PacketKind = "events/store"
 
@@ -27,8 +44,20 @@ const (
 
 	PacketKindBye PacketKind = "bye"
 
-	PacketKindDecision PacketKind = "decision"
-	PacketKindRestart  PacketKind = "restart"
+	PacketKindAgentConfig PacketKind = "agent/config"
+
+	PacketKindDecision             PacketKind = "decision"
+	PacketKindDecisionDryRunResult PacketKind = "decision/dry-run/result"
+	PacketKindDecisionsQueue       PacketKind = "decisions/queue"
+	PacketKindDecisionsSummary     PacketKind = "decisions/summary"
+	PacketKindDecisionsResumed     PacketKind = "decisions/resumed"
+	PacketKindDecisionsRetried     PacketKind = "decisions/retried"
+	PacketKindDecisionsStaged      PacketKind = "decisions/staged"
+	PacketKindDecisionProgress     PacketKind = "decision/progress"
+	PacketKindDecisionReverted     PacketKind = "decision/reverted"
+	PacketKindRestart              PacketKind = "restart"
+	PacketKindFreeze               PacketKind = "freeze"
+	PacketKindScalarStatus         PacketKind = "scalar/status"
 
 	PacketKindRawStoreRequest PacketKind = "raw/store"
 )
@@ -12,8 +12,7 @@ import (
 	"github.com/MagalixTechnologies/uuid-go"
 	"github.com/kovetskiy/lorg"
 	satori "github.com/satori/go.uuid"
-	"k8s.io/api/apps/v1beta2"
-	"k8s.io/api/batch/v1beta1"
+	kapps "k8s.io/api/apps/v1"
 	kv1 "k8s.io/api/core/v1"
 )
 
@@ -31,12 +30,10 @@ var (
 		new(kv1.LimitRangeList),
 		new(kv1.PodList),
 
-		new(v1beta1.CronJobList),
-
-		new(v1beta2.DaemonSetList),
-		new(v1beta2.StatefulSetList),
-		new(v1beta2.ReplicaSetList),
-		new(v1beta2.DeploymentList),
+		new(kapps.DaemonSetList),
+		new(kapps.StatefulSetList),
+		new(kapps.ReplicaSetList),
+		new(kapps.DeploymentList),
 
 		new(map[string]interface{}),
 		new(interface{}),
@@ -47,10 +44,22 @@ var (
 type PacketHello struct {
 	Major     uint      `json:"major"`
 	Minor     uint      `json:"minor"`
+	MinMinor  uint      `json:"min_minor,omitempty"`
 	Build     string    `json:"build"`
 	StartID   string    `json:"start_id"`
 	AccountID uuid.UUID `json:"account_id"`
 	ClusterID uuid.UUID `json:"cluster_id"`
+
+	InstanceIdentity *PacketInstanceIdentity `json:"instance_identity,omitempty"`
+}
+
+// PacketInstanceIdentity signed cloud instance identity document which lets
+// the gateway verify where the agent runs
+type PacketInstanceIdentity struct {
+	Provider  string `json:"provider"`
+	Document  []byte `json:"document"`
+	Signature []byte `json:"signature,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
 }
 
 type PacketAuthorizationRequest struct {
@@ -70,6 +79,23 @@ type PacketAuthorizationFailure struct{}
 
 type PacketAuthorizationSuccess struct{}
 
+// PacketClusterAttach attaches an additional cluster of the account to the
+// authorized connection, packets of the cluster are wrapped into
+// PacketClusterEnvelope
+type PacketClusterAttach struct {
+	ClusterID uuid.UUID `json:"cluster_id"`
+}
+
+type PacketClusterAttachResponse struct{}
+
+// PacketClusterEnvelope packet of an attached cluster, the response is the
+// response of the wrapped packet
+type PacketClusterEnvelope struct {
+	ClusterID uuid.UUID  `json:"cluster_id"`
+	Kind      PacketKind `json:"kind"`
+	Data      []byte     `json:"data"`
+}
+
 type PacketBye struct {
 	Reason string `json:"reason,omitempty"`
 }
@@ -77,6 +103,18 @@ type PacketBye struct {
 type PacketPing struct {
 	Number  int       `json:"number,omitempty"`
 	Started time.Time `json:"started"`
+
+	// Health health of agent components, it is sent with heartbeats only
+	Health map[string]PacketComponentHealth `json:"health,omitempty"`
+}
+
+// PacketComponentHealth health of an agent component, values are component
+// specific gauges such as queue depth
+type PacketComponentHealth struct {
+	Healthy     bool             `json:"healthy"`
+	Message     string           `json:"message,omitempty"`
+	LastSuccess time.Time        `json:"last_success,omitempty"`
+	Values      map[string]int64 `json:"values,omitempty"`
 }
 
 type PacketPong struct {
@@ -90,25 +128,103 @@ type PacketLogItem struct {
 	Data  interface{} `json:"data"`
 }
 
+// PacketLogLevel temporarily raises verbosity of agent logs, the agent
+// reverts to its original level after the duration
+type PacketLogLevel struct {
+	Level    lorg.Level    `json:"level"`
+	Duration time.Duration `json:"duration"`
+}
+
+type PacketLogLevelResponse struct{}
+
+// PacketFreeze halts or resumes all changes of the cluster made by the
+// agent, a freeze without TTL lasts until an explicit unfreeze
+type PacketFreeze struct {
+	Frozen bool          `json:"frozen"`
+	Reason string        `json:"reason,omitempty"`
+	TTL    time.Duration `json:"ttl,omitempty"`
+}
+
+type PacketFreezeResponse struct{}
+
 type PacketRegisterEntityItem struct {
 	ID   uuid.UUID `json:"id"`
 	Name string    `json:"name"`
 	Kind string    `json:"kind,omitempty"`
 
 	Annotations map[string]string `json:"annotations,omitempty"`
+	Environment string            `json:"environment,omitempty"`
 }
 
 type PacketRegisterApplicationItem struct {
 	PacketRegisterEntityItem
 
-	LimitRanges []kv1.LimitRange            `json:"limit_ranges"`
-	Services    []PacketRegisterServiceItem `json:"services"`
+	LimitRanges    []kv1.LimitRange            `json:"limit_ranges"`
+	ResourceQuotas []kv1.ResourceQuota         `json:"resource_quotas"`
+	Services       []PacketRegisterServiceItem `json:"services"`
 }
 
 type PacketRegisterServiceItem struct {
 	PacketRegisterEntityItem
 	ReplicasStatus ReplicasStatus                `json:"replicas_status,omitempty"`
 	Containers     []PacketRegisterContainerItem `json:"containers"`
+
+	EphemeralContainers []PacketEphemeralContainerItem `json:"ephemeral_containers,omitempty"`
+
+	AutomationDisabled bool `json:"automation_disabled,omitempty"`
+
+	SLO *PacketServiceSLO `json:"slo,omitempty"`
+
+	NetworkPolicySelected *bool `json:"network_policy_selected,omitempty"`
+
+	QOSClass          kv1.PodQOSClass `json:"qos_class,omitempty"`
+	PriorityClassName string          `json:"priority_class_name,omitempty"`
+
+	RolloutStrategy *RolloutStrategy `json:"rollout_strategy,omitempty"`
+
+	CreatedAt       time.Time     `json:"created_at,omitempty"`
+	ResourceVersion string        `json:"resource_version,omitempty"`
+	Deploys         int           `json:"deploys"`
+	DeploysWindow   time.Duration `json:"deploys_window"`
+	LastDeployedAt  *time.Time    `json:"last_deployed_at,omitempty"`
+}
+
+// PacketServiceSLO service level objectives of a service
+type PacketServiceSLO struct {
+	Latency      *time.Duration `json:"latency,omitempty"`
+	Availability *float64       `json:"availability,omitempty"`
+	Class        string         `json:"class,omitempty"`
+}
+
+// PacketEphemeralContainerItem debug container attached to a pod of a service
+type PacketEphemeralContainerItem struct {
+	Name                string `json:"name"`
+	PodName             string `json:"pod_name"`
+	Image               string `json:"image"`
+	TargetContainerName string `json:"target_container_name,omitempty"`
+}
+
+// RolloutStrategy strategy used to replace pods of a workload on changes,
+// max surge and max unavailable are either a number or a percentage
+type RolloutStrategy struct {
+	Type           string `json:"type"`
+	MaxSurge       string `json:"max_surge,omitempty"`
+	MaxUnavailable string `json:"max_unavailable,omitempty"`
+	Partition      *int32 `json:"partition,omitempty"`
+}
+
+// RolloutEstimate estimated blast radius of rolling out a change of a
+// workload
+type RolloutEstimate struct {
+	Strategy string `json:"strategy"`
+	// PodsReplaced count of pods replaced by the rollout
+	PodsReplaced int32 `json:"pods_replaced"`
+	// MaxUnavailable count of pods which can be unavailable at once
+	MaxUnavailable int32 `json:"max_unavailable"`
+	// MaxSurge count of pods which can be created above desired replicas
+	MaxSurge int32 `json:"max_surge"`
+	// Batches count of steps needed to replace all pods
+	Batches int32 `json:"batches"`
 }
 
 type ReplicasStatus struct {
@@ -121,13 +237,19 @@ type ReplicasStatus struct {
 type PacketRegisterContainerItem struct {
 	PacketRegisterEntityItem
 
-	Image     string          `json:"image"`
-	Resources json.RawMessage `json:"resources"`
+	Image        string          `json:"image"`
+	ImageTag     string          `json:"image_tag,omitempty"`
+	ImageDigests []string        `json:"image_digests,omitempty"`
+	Resources    json.RawMessage `json:"resources"`
+	Init         bool            `json:"init,omitempty"`
 }
 
 type ContainerResourceRequirements struct {
 	kv1.ResourceRequirements
 	SpecResourceRequirements kv1.ResourceRequirements `json:"spec_resources_requirements,omitempty"`
+	// DeclaredResourceRequirements resources declared in the container spec
+	// before applying LimitRange defaults
+	DeclaredResourceRequirements kv1.ResourceRequirements `json:"declared_resources_requirements,omitempty"`
 
 	LimitsKinds   ResourcesRequirementsKind `json:"limits_kinds,omitempty"`
 	RequestsKinds ResourcesRequirementsKind `json:"requests_kinds,omitempty"`
@@ -145,6 +267,78 @@ type PacketApplicationsStoreRequest []PacketRegisterApplicationItem
 
 type PacketApplicationsStoreResponse struct{}
 
+// PacketApplicationsDeltaRequest entities added, updated or removed since
+// the previous packet, deltas apply on the full snapshot sent at Base in
+// order of Sequence, applications and services are sent without children
+type PacketApplicationsDeltaRequest struct {
+	Base      time.Time `json:"base"`
+	Sequence  int       `json:"sequence"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Applications PacketApplicationsDelta `json:"applications"`
+	Services     PacketServicesDelta     `json:"services"`
+	Containers   PacketContainersDelta   `json:"containers"`
+}
+
+type PacketApplicationsDelta struct {
+	Added   []PacketRegisterApplicationItem `json:"added,omitempty"`
+	Updated []PacketRegisterApplicationItem `json:"updated,omitempty"`
+	Removed []uuid.UUID                     `json:"removed,omitempty"`
+}
+
+type PacketServicesDelta struct {
+	Added   []PacketDeltaServiceItem `json:"added,omitempty"`
+	Updated []PacketDeltaServiceItem `json:"updated,omitempty"`
+	Removed []uuid.UUID              `json:"removed,omitempty"`
+}
+
+type PacketContainersDelta struct {
+	Added   []PacketDeltaContainerItem `json:"added,omitempty"`
+	Updated []PacketDeltaContainerItem `json:"updated,omitempty"`
+	Removed []uuid.UUID                `json:"removed,omitempty"`
+}
+
+type PacketDeltaServiceItem struct {
+	PacketRegisterServiceItem
+	ApplicationID uuid.UUID `json:"application_id"`
+}
+
+type PacketDeltaContainerItem struct {
+	PacketRegisterContainerItem
+	ServiceID uuid.UUID `json:"service_id"`
+}
+
+type PacketApplicationsDeltaResponse struct{}
+
+// PacketEntitiesDeleted tombstones of entities which disappeared since the
+// previous scan
+type PacketEntitiesDeleted struct {
+	Timestamp    time.Time          `json:"timestamp"`
+	Applications []uuid.UUID        `json:"applications,omitempty"`
+	Services     []uuid.UUID        `json:"services,omitempty"`
+	Containers   []uuid.UUID        `json:"containers,omitempty"`
+	Pods         []PacketDeletedPod `json:"pods,omitempty"`
+}
+
+type PacketDeletedPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type PacketEntitiesDeletedResponse struct{}
+
+// PacketEntitiesWarmup entities persisted by a previous run of the agent,
+// they are sent on start before the first scan completes and are stale,
+// entities scanned after Timestamp take precedence
+type PacketEntitiesWarmup struct {
+	Timestamp    time.Time                      `json:"timestamp"`
+	Stale        bool                           `json:"stale"`
+	Applications PacketApplicationsStoreRequest `json:"applications"`
+	Nodes        PacketNodesStoreRequest        `json:"nodes"`
+}
+
+type PacketEntitiesWarmupResponse struct{}
+
 type PacketMetricsStoreRequest []MetricStoreRequest
 
 type MetricStoreRequest struct {
@@ -164,6 +358,29 @@ type MetricStoreRequest struct {
 type PacketMetricsStoreResponse struct {
 }
 
+// PacketMetricsStoreChunkRequest a chunk of metrics collected at the same
+// tick, the backend reassembles chunks of the same batch using the sequence
+type PacketMetricsStoreChunkRequest struct {
+	Batch     uuid.UUID `json:"batch"`
+	Timestamp time.Time `json:"timestamp"`
+	Sequence  int       `json:"sequence"`
+	Total     int       `json:"total"`
+
+	// Shard is set if the cluster is partitioned across replicas of the
+	// agent, chunks of all shards make the whole batch
+	Shard *PacketShard `json:"shard,omitempty"`
+
+	Metrics PacketMetricsStoreRequest `json:"metrics"`
+}
+
+// PacketShard shard of the cluster handled by a replica of the agent
+type PacketShard struct {
+	Index int `json:"index"`
+	Total int `json:"total"`
+}
+
+type PacketMetricsStoreChunkResponse struct{}
+
 type PacketMetricValueItem struct {
 	Node        *uuid.UUID
 	Application *uuid.UUID
@@ -191,6 +408,102 @@ type PacketMetricsPromStoreRequest struct {
 type PacketMetricsPromStoreResponse struct {
 }
 
+// PacketNamespaceSummaryItem total requested and used resources of a
+// namespace, cpu is in millicores and memory is in bytes
+type PacketNamespaceSummaryItem struct {
+	ApplicationID uuid.UUID `json:"application_id"`
+	Namespace     string    `json:"namespace"`
+
+	RequestedCPU    int64 `json:"requested_cpu"`
+	RequestedMemory int64 `json:"requested_memory"`
+	UsedCPU         int64 `json:"used_cpu"`
+	UsedMemory      int64 `json:"used_memory"`
+	WastedCPU       int64 `json:"wasted_cpu"`
+	WastedMemory    int64 `json:"wasted_memory"`
+}
+
+type PacketNamespacesSummaryStoreRequest struct {
+	Timestamp  time.Time                    `json:"timestamp"`
+	Namespaces []PacketNamespaceSummaryItem `json:"namespaces"`
+}
+
+type PacketNamespacesSummaryStoreResponse struct{}
+
+// CapacityResources cpu in millicores and memory in bytes
+type CapacityResources struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+// PacketCapacityPoolItem resources of nodes of a node pool or an instance
+// group, requested and used resources are summed over pods of the nodes,
+// requested resources are nil if request metrics are excluded
+type PacketCapacityPoolItem struct {
+	Name  string `json:"name"`
+	Nodes int    `json:"nodes"`
+
+	Allocatable CapacityResources  `json:"allocatable"`
+	Requested   *CapacityResources `json:"requested,omitempty"`
+	Used        CapacityResources  `json:"used"`
+}
+
+// PacketCapacityNamespaceItem resources requested and used by pods of a
+// namespace, requested resources are nil if request metrics are excluded
+type PacketCapacityNamespaceItem struct {
+	ApplicationID uuid.UUID `json:"application_id"`
+	Namespace     string    `json:"namespace"`
+
+	Requested *CapacityResources `json:"requested,omitempty"`
+	Used      CapacityResources  `json:"used"`
+}
+
+// PacketClusterCapacity capacity planning snapshot of the cluster
+// aggregated from a metrics tick
+type PacketClusterCapacity struct {
+	Timestamp  time.Time                     `json:"timestamp"`
+	Pools      []PacketCapacityPoolItem      `json:"pools"`
+	Namespaces []PacketCapacityNamespaceItem `json:"namespaces"`
+	Shard      *PacketShard                  `json:"shard,omitempty"`
+}
+
+type PacketClusterCapacityResponse struct{}
+
+// PacketKubernetesThrottling throttling state of the api-server, the agent
+// lengthens its scan intervals by the factor while throttled
+type PacketKubernetesThrottling struct {
+	Timestamp time.Time `json:"timestamp"`
+	Throttled bool      `json:"throttled"`
+	Factor    int       `json:"factor"`
+	Responses int       `json:"responses"`
+	Since     time.Time `json:"since,omitempty"`
+}
+
+type PacketKubernetesThrottlingResponse struct{}
+
+// PacketKubernetesCapabilities version of the cluster and availability of
+// features the agent relies on, features are api group versions and
+// kubelet endpoints
+type PacketKubernetesCapabilities struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Version   string          `json:"version"`
+	Major     int             `json:"major"`
+	Minor     int             `json:"minor"`
+	Platform  string          `json:"platform,omitempty"`
+	Features  map[string]bool `json:"features"`
+}
+
+type PacketKubernetesCapabilitiesResponse struct{}
+
+// PacketAgentConfig effective configuration of the agent, flags are resolved
+// from defaults and environment variables, sensitive values are redacted
+type PacketAgentConfig struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Version   string                 `json:"version"`
+	Config    map[string]interface{} `json:"config"`
+}
+
+type PacketAgentConfigResponse struct{}
+
 type PacketRegisterNodeCapacityItem struct {
 	CPU              int `json:"cpu"`
 	Memory           int `json:"memory"`
@@ -204,14 +517,27 @@ type PacketRegisterNodeItem struct {
 	IP            string                                 `json:"ip"`
 	Region        string                                 `json:"region,omitempty"`
 	Provider      string                                 `json:"provider,omitempty"`
+	OS            string                                 `json:"os,omitempty"`
 	InstanceType  string                                 `json:"instance_type,omitempty"`
 	InstanceSize  string                                 `json:"instance_size,omitempty"`
+	Zone          string                                 `json:"zone,omitempty"`
+	Pool          string                                 `json:"pool,omitempty"`
+	Lifecycle     string                                 `json:"lifecycle,omitempty"`
+	OnDemandPrice float64                                `json:"on_demand_price,omitempty"`
+	Labels        map[string]string                      `json:"labels,omitempty"`
+	Taints        []PacketRegisterNodeTaintItem          `json:"taints,omitempty"`
 	Capacity      PacketRegisterNodeCapacityItem         `json:"capacity"`
 	Allocatable   PacketRegisterNodeCapacityItem         `json:"allocatable"`
 	Containers    int                                    `json:"containers,omitempty"`
 	ContainerList []*PacketRegisterNodeContainerListItem `json:"container_list,omitempty"`
 }
 
+type PacketRegisterNodeTaintItem struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
 type PacketRegisterNodeContainerListItem struct {
 	// cluster where host of container located in
 	Cluster string `json:"cluster"`
@@ -241,6 +567,34 @@ type PacketNodesStoreRequest []PacketRegisterNodeItem
 
 type PacketNodesStoreResponse struct{}
 
+type VPAResources struct {
+	CPU    *int64 `json:"cpu,omitempty"`
+	Memory *int64 `json:"memory,omitempty"`
+}
+
+type PacketVPAContainerRecommendationItem struct {
+	ContainerID    *uuid.UUID   `json:"container_id,omitempty"`
+	ContainerName  string       `json:"container_name"`
+	Target         VPAResources `json:"target"`
+	LowerBound     VPAResources `json:"lower_bound"`
+	UpperBound     VPAResources `json:"upper_bound"`
+	UncappedTarget VPAResources `json:"uncapped_target"`
+}
+
+type PacketVPARecommendationItem struct {
+	Name       string    `json:"name"`
+	Namespace  string    `json:"namespace"`
+	TargetKind string    `json:"target_kind"`
+	TargetName string    `json:"target_name"`
+	ServiceID  uuid.UUID `json:"service_id"`
+
+	Containers []PacketVPAContainerRecommendationItem `json:"containers"`
+}
+
+type PacketVPARecommendationsStoreRequest []PacketVPARecommendationItem
+
+type PacketVPARecommendationsStoreResponse struct{}
+
 type PacketLogs []PacketLogItem
 
 type PacketEventsStoreRequest []watcher.Event
@@ -282,9 +636,28 @@ type TotalResources struct {
 	Containers []ContainerResources `json:"containers"`
 }
 
+// DecisionType kind of change a decision applies
+type DecisionType string
+
+const (
+	// DecisionTypeResources changes replicas and containers resources, it is
+	// the type of decisions sent without a type
+	DecisionTypeResources DecisionType = ""
+	// DecisionTypePauseRollout pauses rollouts of a deployment
+	DecisionTypePauseRollout DecisionType = "pause-rollout"
+	// DecisionTypeResumeRollout resumes paused rollouts of a deployment
+	DecisionTypeResumeRollout DecisionType = "resume-rollout"
+	// DecisionTypeSuspendCronJob suspends schedules of a cron job, running
+	// jobs are not stopped
+	DecisionTypeSuspendCronJob DecisionType = "suspend-cronjob"
+	// DecisionTypeResumeCronJob resumes schedules of a suspended cron job
+	DecisionTypeResumeCronJob DecisionType = "resume-cronjob"
+)
+
 type Decision struct {
 	ID             uuid.UUID      `json:"id"`
 	ServiceId      uuid.UUID      `json:"service_id"`
+	Type           DecisionType   `json:"type,omitempty"`
 	TotalResources TotalResources `json:"total_resources"`
 }
 
@@ -296,6 +669,30 @@ const (
 	DecisionExecutionStatusSucceed DecisionExecutionStatus = "succeed"
 	DecisionExecutionStatusFailed  DecisionExecutionStatus = "failed"
 	DecisionExecutionStatusSkipped DecisionExecutionStatus = "skipped"
+	// DecisionExecutionStatusDeferred execution can't be done now without
+	// disrupting the service, the decision should be sent again later
+	DecisionExecutionStatusDeferred DecisionExecutionStatus = "deferred"
+	// DecisionExecutionStatusPolicyViolation the decision violates the local
+	// policy of the agent and is rejected
+	DecisionExecutionStatusPolicyViolation DecisionExecutionStatus = "policy-violation"
+	// DecisionExecutionStatusRetrying execution failed with a transient
+	// error, the agent retries it and reports the outcome later
+	DecisionExecutionStatusRetrying DecisionExecutionStatus = "retrying"
+	// DecisionExecutionStatusRetriesExhausted execution failed with
+	// transient errors on every attempt, the failure is terminal
+	DecisionExecutionStatusRetriesExhausted DecisionExecutionStatus = "failed-after-retries"
+	// DecisionExecutionStatusInProgress changes of the statefulset are
+	// rolled out in stages, the agent reports the outcome once all stages
+	// are done
+	DecisionExecutionStatusInProgress DecisionExecutionStatus = "in-progress"
+	// DecisionExecutionStatusProposed changes are committed to the git
+	// repository of manifests instead of the cluster, they are applied by a
+	// GitOps controller once they are merged
+	DecisionExecutionStatusProposed DecisionExecutionStatus = "proposed"
+	// DecisionExecutionStatusReverted applied changes were reverted by
+	// another controller, e.g. Argo CD or Flux syncing manifests of git, the
+	// decision shouldn't be sent again
+	DecisionExecutionStatusReverted DecisionExecutionStatus = "reverted-by-external-controller"
 )
 
 type DecisionExecutionResponse struct {
@@ -304,10 +701,195 @@ type DecisionExecutionResponse struct {
 	Message     string                  `json:"message"`
 	ServiceId   uuid.UUID               `json:"service_id"`
 	ContainerId *uuid.UUID              `json:"container_id"`
+
+	// Attempts count of failed attempts of executions which are retried
+	Attempts int `json:"attempts,omitempty"`
+
+	// CoalescedIds ids of decisions merged and executed together
+	CoalescedIds []uuid.UUID `json:"coalesced_ids,omitempty"`
+
+	// Rollout estimated rollout of applied changes
+	Rollout *RolloutEstimate `json:"rollout,omitempty"`
+
+	// Containers outcomes of containers of the decision
+	Containers []ContainerExecutionResult `json:"containers,omitempty"`
+
+	// AdmissionRejection is set if changes were denied by an admission
+	// webhook of the cluster
+	AdmissionRejection *AdmissionRejection `json:"admission_rejection,omitempty"`
+}
+
+// AdmissionRejection rejection of changes by an admission webhook, e.g. a
+// policy of OPA Gatekeeper
+type AdmissionRejection struct {
+	Webhook string `json:"webhook"`
+	Message string `json:"message"`
+}
+
+type ContainerExecutionStatus string
+
+const (
+	ContainerExecutionStatusApplied ContainerExecutionStatus = "applied"
+	// ContainerExecutionStatusSkipped the workload has the decided resources
+	// already, the container isn't patched
+	ContainerExecutionStatusSkipped ContainerExecutionStatus = "skipped-no-change"
+	ContainerExecutionStatusFailed  ContainerExecutionStatus = "failed"
+	// ContainerExecutionStatusClamped resources were changed to fit limit
+	// ranges of the namespace and applied
+	ContainerExecutionStatusClamped ContainerExecutionStatus = "clamped"
+)
+
+// ContainerExecutionResult outcome of a container of a decision, requests
+// and limits are values sent to the cluster
+type ContainerExecutionResult struct {
+	ContainerId uuid.UUID                `json:"container_id"`
+	Name        string                   `json:"name"`
+	Status      ContainerExecutionStatus `json:"status"`
+	Message     string                   `json:"message,omitempty"`
+	Requests    RequestLimit             `json:"requests"`
+	Limits      RequestLimit             `json:"limits"`
 }
 
 type PacketDecisionsResponse []DecisionExecutionResponse
 
+// DecisionDryRunContainer current and predicted resources of a container,
+// cpu is in millicores and memory is in mebibytes
+type DecisionDryRunContainer struct {
+	ContainerId uuid.UUID `json:"container_id"`
+	Name        string    `json:"name"`
+
+	OldRequests RequestLimit `json:"old_requests"`
+	OldLimits   RequestLimit `json:"old_limits"`
+	NewRequests RequestLimit `json:"new_requests"`
+	NewLimits   RequestLimit `json:"new_limits"`
+}
+
+// PacketDecisionsQueue state of the decisions executions queue, depth is the
+// count of decisions waiting for execution
+type PacketDecisionsQueue struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Depth          int       `json:"depth"`
+	Running        int       `json:"running"`
+	MaxConcurrency int       `json:"max_concurrency"`
+}
+
+type PacketDecisionsQueueResponse struct{}
+
+// NamespaceExecutionSummary counts of decisions executions outcomes in a
+// namespace
+type NamespaceExecutionSummary struct {
+	Namespace string `json:"namespace"`
+	Applied   int    `json:"applied"`
+	Failed    int    `json:"failed"`
+	Skipped   int    `json:"skipped"`
+	Deferred  int    `json:"deferred"`
+	Rejected  int    `json:"rejected"`
+	Retrying  int    `json:"retrying"`
+	Proposed  int    `json:"proposed"`
+	Reverted  int    `json:"reverted"`
+}
+
+// PacketDecisionsSummary outcomes of decisions executed within a period
+type PacketDecisionsSummary struct {
+	From       time.Time                   `json:"from"`
+	To         time.Time                   `json:"to"`
+	Namespaces []NamespaceExecutionSummary `json:"namespaces"`
+}
+
+type PacketDecisionsSummaryResponse struct{}
+
+// DecisionOriginScalar origin of decisions made by the in-agent scalar
+const DecisionOriginScalar = "scalar"
+
+// PacketDecisionDryRunResult changes which a decision would apply if
+// execution was enabled
+type PacketDecisionDryRunResult struct {
+	ID        uuid.UUID `json:"id"`
+	ServiceId uuid.UUID `json:"service_id"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+
+	// Origin origin of the decision, empty for decisions of the backend
+	Origin string `json:"origin,omitempty"`
+
+	OldReplicas *int                      `json:"old_replicas,omitempty"`
+	NewReplicas *int                      `json:"new_replicas,omitempty"`
+	Containers  []DecisionDryRunContainer `json:"containers"`
+
+	// Patch strategic merge patch which would be applied
+	Patch string `json:"patch"`
+
+	// Rollout estimated rollout of the changes
+	Rollout *RolloutEstimate `json:"rollout,omitempty"`
+}
+
+type PacketDecisionDryRunResultResponse struct{}
+
+// PacketDecisionProgress progress of a decision executed in stages, pods of
+// a statefulset with ordinals below the partition are not updated yet
+type PacketDecisionProgress struct {
+	ID        uuid.UUID `json:"id"`
+	ServiceId uuid.UUID `json:"service_id"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Partition       int32 `json:"partition"`
+	Replicas        int32 `json:"replicas"`
+	UpdatedReplicas int32 `json:"updated_replicas"`
+	ReadyReplicas   int32 `json:"ready_replicas"`
+	Done            bool  `json:"done"`
+}
+
+type PacketDecisionProgressResponse struct{}
+
+// statuses of decisions of the in-agent scalar
+const (
+	ScalarDecisionStatusApplied = "applied"
+	ScalarDecisionStatusDryRun  = "dry-run"
+	ScalarDecisionStatusSkipped = "skipped"
+	ScalarDecisionStatusFailed  = "failed"
+)
+
+// PacketScalarStatus status of the in-agent scalar, decisions are made since
+// the previous status
+type PacketScalarStatus struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Config    PacketScalarConfig     `json:"config"`
+	Decisions []PacketScalarDecision `json:"decisions"`
+}
+
+type PacketScalarConfig struct {
+	DryRun                bool               `json:"dry_run"`
+	MemoryIncreasePercent int64              `json:"memory_increase_percent"`
+	MaxMemory             int64              `json:"max_memory,omitempty"`
+	MemoryThreshold       int64              `json:"memory_threshold"`
+	MemoryGrowth          int64              `json:"memory_growth,omitempty"`
+	MinRestarts           int32              `json:"min_restarts"`
+	Cooldown              time.Duration      `json:"cooldown"`
+	Targets               []string           `json:"targets,omitempty"`
+	Weights               map[string]float64 `json:"weights,omitempty"`
+}
+
+// PacketScalarDecision decision of the in-agent scalar, the reason explains
+// why the change is made or skipped
+type PacketScalarDecision struct {
+	ID            uuid.UUID `json:"id"`
+	Timestamp     time.Time `json:"timestamp"`
+	ApplicationID uuid.UUID `json:"application_id"`
+	ServiceID     uuid.UUID `json:"service_id"`
+	ContainerID   uuid.UUID `json:"container_id"`
+	Namespace     string    `json:"namespace"`
+	Service       string    `json:"service"`
+	Container     string    `json:"container"`
+	Resource      string    `json:"resource"`
+	OldValue      int64     `json:"old_value"`
+	NewValue      int64     `json:"new_value"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason"`
+}
+
+type PacketScalarStatusResponse struct{}
+
 type PacketRestart struct {
 	Staus int `json:"status"`
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/executor"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/replay"
+	"github.com/MagalixCorp/magalix-agent/scalar"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// runReplay re-runs executor and scalar logic against a recording in
+// dry-run mode, nothing is sent to the gateway or applied to the cluster
+func runReplay(logger *log.Logger, path string) error {
+	records, err := replay.ReadRecords(path)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof(
+		karma.Describe("path", path),
+		"replaying %d records",
+		len(records),
+	)
+
+	entityScanner := scanner.NewStaticScanner(logger)
+	e := executor.NewDryRunExecutor(logger, entityScanner)
+	oomKills := scalar.NewOOMKillsProcessor(
+		logger, nil, entityScanner, nil, nil, time.Second,
+		scalar.Options{DryRun: true, MemoryIncreasePercent: 50, MinRestarts: 3},
+	)
+
+	replayed := map[uuid.UUID]proto.DecisionExecutionResponse{}
+
+	for i, record := range records {
+		ctx := karma.
+			Describe("record", i+1).
+			Describe("time", record.Time).
+			Describe("kind", record.Kind)
+
+		switch record.Type {
+		case replay.RecordTypePacket:
+			if record.Kind != proto.PacketKindDecision.String() {
+				logger.Debugf(ctx, "skipping packet")
+				continue
+			}
+
+			out, err := e.Listener(record.Packet)
+			if err != nil {
+				logger.Errorf(ctx.Reason(err), "unable to replay decisions")
+				continue
+			}
+
+			var responses proto.PacketDecisionsResponse
+			err = proto.Decode(out, &responses)
+			if err != nil {
+				return karma.Format(err, "unable to decode replayed responses")
+			}
+
+			for _, response := range responses {
+				replayed[response.ID] = response
+
+				logger.Infof(
+					ctx.
+						Describe("decision-id", response.ID).
+						Describe("status", response.Status).
+						Describe("message", response.Message),
+					"decision replayed",
+				)
+			}
+
+		case replay.RecordTypeTransition:
+			switch record.Kind {
+			case replay.TransitionApplications:
+				var apps []*scanner.Application
+				err := json.Unmarshal(record.Details, &apps)
+				if err != nil {
+					return karma.Format(err, "unable to decode applications")
+				}
+
+				entityScanner.SetApplications(apps)
+
+			case replay.TransitionDecisionsResponses:
+				var responses proto.PacketDecisionsResponse
+				err := json.Unmarshal(record.Details, &responses)
+				if err != nil {
+					return karma.Format(err, "unable to decode recorded responses")
+				}
+
+				for _, response := range responses {
+					ctx := ctx.
+						Describe("decision-id", response.ID).
+						Describe("recorded-status", response.Status).
+						Describe("recorded-message", response.Message)
+
+					if replayedResponse, ok := replayed[response.ID]; ok {
+						ctx = ctx.
+							Describe("replayed-status", replayedResponse.Status).
+							Describe("replayed-message", replayedResponse.Message)
+					}
+
+					logger.Infof(ctx, "recorded decision response")
+				}
+
+			case replay.TransitionContainerStatus:
+				var container scalar.IdentifiedContainer
+				err := json.Unmarshal(record.Details, &container)
+				if err != nil {
+					return karma.Format(err, "unable to decode container status")
+				}
+
+				if oomKills.Applicable(container) {
+					oomKills.Handle(container)
+				}
+			}
+		}
+	}
+
+	logger.Infof(nil, "replay finished")
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package replay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// ArchiveEntities file of the archive with recorded applications
+	ArchiveEntities = "entities.jsonl"
+	// ArchiveMetrics file of the archive with recorded metrics batches
+	ArchiveMetrics = "metrics.jsonl"
+	// ArchiveManifest file of the archive describing its contents
+	ArchiveManifest = "manifest.json"
+)
+
+// ArchiveManifestContent describes contents of an exported archive
+type ArchiveManifestContent struct {
+	CreatedAt time.Time `json:"created_at"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	Entities  int       `json:"entities"`
+	Metrics   int       `json:"metrics"`
+}
+
+// WriteArchive writes a gzipped tar archive of recorded entities and metrics
+// within the specified time range, other records are skipped
+func WriteArchive(
+	writer io.Writer,
+	records []Record,
+	since time.Time,
+	until time.Time,
+) (ArchiveManifestContent, error) {
+	manifest := ArchiveManifestContent{
+		CreatedAt: time.Now().UTC(),
+		Since:     since,
+		Until:     until,
+	}
+
+	var entities, metrics bytes.Buffer
+	entitiesEncoder := json.NewEncoder(&entities)
+	metricsEncoder := json.NewEncoder(&metrics)
+
+	for _, record := range records {
+		if record.Type != RecordTypeTransition {
+			continue
+		}
+
+		if record.Time.Before(since) || record.Time.After(until) {
+			continue
+		}
+
+		var err error
+		switch record.Kind {
+		case TransitionApplications:
+			err = entitiesEncoder.Encode(record)
+			manifest.Entities++
+		case TransitionMetrics:
+			err = metricsEncoder.Encode(record)
+			manifest.Metrics++
+		}
+		if err != nil {
+			return manifest, karma.Format(
+				err,
+				"unable to encode record %s at %s",
+				record.Kind,
+				record.Time,
+			)
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, karma.Format(err, "unable to encode manifest")
+	}
+
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range []struct {
+		Name string
+		Data []byte
+	}{
+		{ArchiveManifest, manifestData},
+		{ArchiveEntities, entities.Bytes()},
+		{ArchiveMetrics, metrics.Bytes()},
+	} {
+		err := tarWriter.WriteHeader(&tar.Header{
+			Name:    file.Name,
+			Mode:    0600,
+			Size:    int64(len(file.Data)),
+			ModTime: manifest.CreatedAt,
+		})
+		if err != nil {
+			return manifest, karma.Format(
+				err,
+				"unable to write archive header of %s",
+				file.Name,
+			)
+		}
+
+		_, err = tarWriter.Write(file.Data)
+		if err != nil {
+			return manifest, karma.Format(
+				err,
+				"unable to write archive file %s",
+				file.Name,
+			)
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return manifest, karma.Format(err, "unable to close archive")
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return manifest, karma.Format(err, "unable to close archive compression")
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,63 @@
+package replay
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteArchive(t *testing.T) {
+	now := time.Now().UTC()
+	since := now.Add(-time.Hour)
+
+	records := []Record{
+		{Time: now.Add(-2 * time.Hour), Type: RecordTypeTransition, Kind: TransitionApplications},
+		{Time: now.Add(-time.Minute), Type: RecordTypeTransition, Kind: TransitionApplications},
+		{Time: now.Add(-time.Minute), Type: RecordTypeTransition, Kind: TransitionMetrics},
+		{Time: now.Add(-time.Minute), Type: RecordTypeTransition, Kind: TransitionContainerStatus},
+		{Time: now.Add(-time.Minute), Type: RecordTypePacket, Kind: "decision"},
+	}
+
+	var buffer bytes.Buffer
+	manifest, err := WriteArchive(&buffer, records, since, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Entities != 1 || manifest.Metrics != 1 {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+
+	gzipReader, err := gzip.NewReader(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := map[string]int{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines[header.Name] = strings.Count(string(data), "\n")
+	}
+
+	if _, ok := lines[ArchiveManifest]; !ok {
+		t.Errorf("archive has no manifest")
+	}
+
+	if lines[ArchiveEntities] != 1 || lines[ArchiveMetrics] != 1 {
+		t.Errorf("unexpected archive contents: %v", lines)
+	}
+}
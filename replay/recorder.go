@@ -0,0 +1,176 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// RecordTypePacket inbound packet received from the gateway
+	RecordTypePacket = "packet"
+	// RecordTypeTransition internal transition of the agent
+	RecordTypeTransition = "transition"
+)
+
+const (
+	// TransitionApplications applications found by the scanner
+	TransitionApplications = "scanner/applications"
+	// TransitionDecisionsResponses responses of executed decisions
+	TransitionDecisionsResponses = "executor/responses"
+	// TransitionContainerStatus container status submitted to scalars
+	TransitionContainerStatus = "scalar/container"
+	// TransitionMetrics metrics collected by a metrics tick
+	TransitionMetrics = "metrics/batch"
+)
+
+// Record a single recorded packet or transition
+type Record struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	Kind string    `json:"kind"`
+
+	Packet  []byte          `json:"packet,omitempty"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// Recorder writes records to a file, one JSON encoded record per line
+type Recorder struct {
+	logger *log.Logger
+
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+var recorder *Recorder
+
+// SetRecorder sets the recorder used by Packet and Transition, nothing is
+// recorded unless a recorder is set
+func SetRecorder(value *Recorder) {
+	recorder = value
+}
+
+// NewRecorder creates a new recorder writing to the specified file
+func NewRecorder(logger *log.Logger, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to open recording file %s",
+			path,
+		)
+	}
+
+	return &Recorder{
+		logger:  logger,
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (recorder *Recorder) write(record Record) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	err := recorder.encoder.Encode(record)
+	if err != nil {
+		recorder.logger.Errorf(
+			karma.Describe("kind", record.Kind).Reason(err),
+			"{replay} unable to write record",
+		)
+	}
+}
+
+// Close closes the recording file
+func (recorder *Recorder) Close() error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.file.Close()
+}
+
+// Packet records an inbound packet
+func Packet(kind proto.PacketKind, in []byte) {
+	if recorder == nil {
+		return
+	}
+
+	recorder.write(Record{
+		Time:   time.Now().UTC(),
+		Type:   RecordTypePacket,
+		Kind:   kind.String(),
+		Packet: in,
+	})
+}
+
+// Transition records an internal transition with its details
+func Transition(name string, details interface{}) {
+	if recorder == nil {
+		return
+	}
+
+	data, err := json.Marshal(details)
+	if err != nil {
+		recorder.logger.Errorf(
+			karma.Describe("transition", name).Reason(err),
+			"{replay} unable to encode transition details",
+		)
+		return
+	}
+
+	recorder.write(Record{
+		Time:    time.Now().UTC(),
+		Type:    RecordTypeTransition,
+		Kind:    name,
+		Details: data,
+	})
+}
+
+// Listener wraps a packet listener to record its inbound packets
+func Listener(
+	kind proto.PacketKind,
+	listener func(in []byte) ([]byte, error),
+) func(in []byte) ([]byte, error) {
+	return func(in []byte) ([]byte, error) {
+		Packet(kind, in)
+		return listener(in)
+	}
+}
+
+// ReadRecords reads all records of a recording file
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to open recording file %s",
+			path,
+		)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var record Record
+		err := decoder.Decode(&record)
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to decode record #%d",
+				len(records)+1,
+			)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,60 @@
+package scalar
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+// Cooldowns last times of decisions of containers shared by processors, a
+// container isn't handled again within the cooldown whatever the decision
+// was, a nil value never cools down
+type Cooldowns struct {
+	duration time.Duration
+
+	mutex  sync.Mutex
+	scaled map[uuid.UUID]time.Time
+}
+
+// NewCooldowns creates new cooldowns, zero duration disables cooldowns
+func NewCooldowns(duration time.Duration) *Cooldowns {
+	return &Cooldowns{
+		duration: duration,
+		scaled:   map[uuid.UUID]time.Time{},
+	}
+}
+
+// IsActive checks whether the container was handled within the cooldown
+func (cooldowns *Cooldowns) IsActive(id uuid.UUID) bool {
+	if cooldowns == nil || cooldowns.duration <= 0 {
+		return false
+	}
+
+	cooldowns.mutex.Lock()
+	defer cooldowns.mutex.Unlock()
+
+	scaled, ok := cooldowns.scaled[id]
+	if !ok {
+		return false
+	}
+
+	if time.Since(scaled) >= cooldowns.duration {
+		delete(cooldowns.scaled, id)
+		return false
+	}
+
+	return true
+}
+
+// Set starts the cooldown of the container
+func (cooldowns *Cooldowns) Set(id uuid.UUID) {
+	if cooldowns == nil || cooldowns.duration <= 0 {
+		return
+	}
+
+	cooldowns.mutex.Lock()
+	defer cooldowns.mutex.Unlock()
+
+	cooldowns.scaled[id] = time.Now()
+}
@@ -0,0 +1,208 @@
+package scalar
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/executor"
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+// memoryActuator raises memory limits of containers decided by processors
+type memoryActuator struct {
+	logger    *log.Logger
+	kube      *kuber.Kube
+	scanner   *scanner.Scanner
+	status    *StatusReporter
+	cooldowns *Cooldowns
+
+	options Options
+}
+
+// getMemoryLimit returns memory limit of the container in Mi
+func getMemoryLimit(container IdentifiedContainer) int64 {
+	if container.Container.Resources == nil {
+		return 0
+	}
+
+	limits := container.Container.Resources.SpecResourceRequirements.Limits
+
+	return limits.Memory().Value() / 1024 / 1024
+}
+
+// scale raises the memory limit of the container for the reason, the
+// decision is reported whether it's applied or skipped
+func (actuator *memoryActuator) scale(
+	handler string,
+	status IdentifiedContainer,
+	reason string,
+) {
+	container := status.Container
+	service := status.Service
+	application := status.Application
+
+	currentMemLimits := getMemoryLimit(status)
+	newMemLimits := actuator.options.getMemoryIncrease(
+		application.Name,
+		service.Name,
+		currentMemLimits,
+	)
+
+	ctx := karma.
+		Describe("handler", handler).
+		Describe("reason", reason).
+		Describe("container", container.Name).
+		Describe("container-id", container.ID).
+		Describe("service", service.Name).
+		Describe("service-d", service.ID).
+		Describe("application", application.Name).
+		Describe("application-d", application.ID).
+		Describe("old value (Mi)", currentMemLimits).
+		Describe("new value (Mi)", newMemLimits).
+		Describe("dry run", actuator.options.DryRun)
+
+	decision := proto.PacketScalarDecision{
+		ID:            uuid.NewV4(),
+		Timestamp:     time.Now(),
+		ApplicationID: application.ID,
+		ServiceID:     service.ID,
+		ContainerID:   container.ID,
+		Namespace:     application.Name,
+		Service:       service.Name,
+		Container:     container.Name,
+		Resource:      "limits.memory",
+		OldValue:      currentMemLimits,
+		NewValue:      newMemLimits,
+		Status:        proto.ScalarDecisionStatusSkipped,
+		Reason:        reason,
+	}
+	defer func() {
+		actuator.cooldowns.Set(container.ID)
+		actuator.status.Add(decision)
+	}()
+
+	if currentMemLimits == 0 {
+		decision.Reason = "container has no memory limit"
+		actuator.logger.Infof(ctx, "no memory limit, skipping %s", handler)
+		return
+	}
+
+	if newMemLimits <= currentMemLimits {
+		decision.Reason = "memory limit is at maximum"
+		actuator.logger.Infof(ctx, "memory limit is at maximum, skipping %s", handler)
+		return
+	}
+
+	if service.AutomationDisabled {
+		decision.Reason = "automation is disabled"
+		actuator.logger.Infof(ctx, "automation is disabled, skipping %s", handler)
+		return
+	}
+
+	totalResources := kuber.TotalResources{
+		Containers: []kuber.ContainerResourcesRequirements{
+			{
+				Name: container.Name,
+				Limits: kuber.RequestLimit{
+					Memory: &newMemLimits,
+				},
+			},
+		},
+	}
+
+	if actuator.options.DryRun {
+		decision.Status = proto.ScalarDecisionStatusDryRun
+		//	log info about dryRun
+		actuator.logger.Infof(ctx, "dry-run enabled, skipping %s", handler)
+		actuator.sendDryRunResult(ctx, status, decision, totalResources)
+		return
+	}
+
+	if state, frozen := actuator.options.Freezer.IsFrozen(); frozen {
+		decision.Reason = "cluster changes are frozen: " + state.Reason
+		actuator.logger.Infof(
+			ctx.Describe("frozen", state.Reason),
+			"cluster changes are frozen, skipping %s",
+			handler,
+		)
+		return
+	}
+
+	skipped, err := actuator.kube.SetResources(
+		service.Kind, service.Name, application.Name, totalResources,
+	)
+
+	if err != nil {
+		decision.Reason = err.Error()
+		if skipped {
+			actuator.logger.Errorf(
+				ctx.Reason(err),
+				"skipping %s execution",
+				handler,
+			)
+		} else {
+			decision.Status = proto.ScalarDecisionStatusFailed
+			actuator.logger.Errorf(
+				ctx.Reason(err),
+				"unable to execute %s",
+				handler,
+			)
+		}
+
+		return
+	}
+
+	decision.Status = proto.ScalarDecisionStatusApplied
+
+	actuator.logger.Infof(ctx, "%s executed", handler)
+}
+
+// sendDryRunResult sends changes which the decision would apply, the same
+// way as results of decisions of the backend executed in dry-run mode
+func (actuator *memoryActuator) sendDryRunResult(
+	ctx *karma.Context,
+	status IdentifiedContainer,
+	decision proto.PacketScalarDecision,
+	totalResources kuber.TotalResources,
+) {
+	limit := decision.NewValue
+
+	result, err := executor.GetDryRunResult(
+		actuator.scanner,
+		proto.Decision{
+			ID:        decision.ID,
+			ServiceId: decision.ServiceID,
+			TotalResources: proto.TotalResources{
+				Containers: []proto.ContainerResources{
+					{
+						ContainerId: decision.ContainerID,
+						Limits: proto.RequestLimit{
+							Memory: &limit,
+						},
+					},
+				},
+			},
+		},
+		status.Application.Name,
+		status.Service.Name,
+		status.Service.Kind,
+		totalResources,
+	)
+	if err != nil {
+		actuator.logger.Errorf(ctx.Reason(err), "unable to predict scalar changes")
+		return
+	}
+
+	result.Origin = proto.DecisionOriginScalar
+
+	actuator.logger.Debugf(
+		ctx.Describe("patch", result.Patch),
+		"predicted scalar changes",
+	)
+
+	actuator.status.SendDryRunResult(result)
+}
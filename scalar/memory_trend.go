@@ -0,0 +1,179 @@
+package scalar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/metrics"
+	"github.com/MagalixCorp/magalix-agent/scanner"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+	"golang.org/x/net/context"
+)
+
+// memoryTrendMinSamples minimal count of memory samples to estimate the
+// trend of memory usage
+const memoryTrendMinSamples = 4
+
+// MemoryTrendProcessor raises memory limits of containers which memory rss
+// grows and approaches the limit before they are killed by the OOM killer
+type MemoryTrendProcessor struct {
+	logger    *log.Logger
+	actuator  *memoryActuator
+	cooldowns *Cooldowns
+
+	timeout time.Duration
+	pipe    chan IdentifiedContainer
+
+	options Options
+}
+
+func NewMemoryTrendProcessor(
+	logger *log.Logger,
+	kube *kuber.Kube,
+	scanner *scanner.Scanner,
+	status *StatusReporter,
+	cooldowns *Cooldowns,
+	timeout time.Duration,
+	options Options,
+) *MemoryTrendProcessor {
+	return &MemoryTrendProcessor{
+		logger: logger,
+		actuator: &memoryActuator{
+			logger:    logger,
+			kube:      kube,
+			scanner:   scanner,
+			status:    status,
+			cooldowns: cooldowns,
+			options:   options,
+		},
+		cooldowns: cooldowns,
+
+		timeout: timeout,
+		pipe:    make(chan IdentifiedContainer, 1000),
+
+		options: options,
+	}
+}
+
+func (p *MemoryTrendProcessor) Start() {
+	for s := range p.pipe {
+		p.handleContainer(s)
+	}
+}
+
+func (p *MemoryTrendProcessor) Stop() {
+	close(p.pipe)
+}
+
+func (p *MemoryTrendProcessor) Applicable(container IdentifiedContainer) bool {
+	if p.options.MemoryThreshold <= 0 {
+		return false
+	}
+
+	if !p.options.isScalable(container.Application.Name, container.Service.Name) {
+		return false
+	}
+
+	if container.Status.State.Running == nil {
+		return false
+	}
+
+	if p.cooldowns.IsActive(container.Container.ID) {
+		return false
+	}
+
+	_, _, ok := p.getTrend(container)
+	return ok
+}
+
+// getTrend returns usage of the memory limit (percent) by the last memory
+// rss sample and growth of memory rss (Mi per hour), false is returned if
+// memory rss grows slower than the minimal growth or its usage is below the
+// threshold
+func (p *MemoryTrendProcessor) getTrend(
+	container IdentifiedContainer,
+) (int64, float64, bool) {
+	limit := getMemoryLimit(container)
+	if limit == 0 {
+		return 0, 0, false
+	}
+
+	samples := metrics.GetContainerSamples(container.Container.ID, container.PodName)
+	if len(samples) < memoryTrendMinSamples {
+		return 0, 0, false
+	}
+
+	last := samples[len(samples)-1]
+	usage := last.Value / 1024 / 1024 * 100 / limit
+	if usage < p.options.MemoryThreshold {
+		return usage, 0, false
+	}
+
+	growth := getSamplesSlope(samples) * float64(time.Hour) / 1024 / 1024
+	if growth < float64(p.options.MemoryGrowth) {
+		return usage, growth, false
+	}
+
+	return usage, growth, true
+}
+
+// getSamplesSlope returns slope of values of samples per nanosecond estimated
+// by least squares
+func getSamplesSlope(samples []metrics.Sample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	origin := samples[0].Timestamp
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := float64(sample.Timestamp.Sub(origin))
+		y := float64(sample.Value)
+
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	count := float64(len(samples))
+	denominator := count*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+
+	return (count*sumXY - sumX*sumY) / denominator
+}
+
+func (p *MemoryTrendProcessor) Submit(container IdentifiedContainer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	select {
+	case p.pipe <- container:
+		cancel()
+	case <-ctx.Done():
+		return karma.Format(
+			karma.Describe("timeout", p.timeout).Reason(nil),
+			"timeout submitting container",
+		)
+	}
+	return nil
+}
+
+func (p *MemoryTrendProcessor) handleContainer(status IdentifiedContainer) {
+	usage, growth, ok := p.getTrend(status)
+	if !ok {
+		return
+	}
+
+	p.actuator.scale(
+		"memory trend handler",
+		status,
+		fmt.Sprintf(
+			"memory rss uses %d%% of the limit and grows by %.1fMi per hour",
+			usage, growth,
+		),
+	)
+}
@@ -1,9 +1,11 @@
 package scalar
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/scanner"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/reconquest/karma-go"
 	"golang.org/x/net/context"
@@ -12,29 +14,41 @@ import (
 const OOMKilledReason = "OOMKilled"
 
 type OOMKillsProcessor struct {
-	logger *log.Logger
-	kube   *kuber.Kube
+	logger    *log.Logger
+	actuator  *memoryActuator
+	cooldowns *Cooldowns
 
 	timeout time.Duration
 	pipe    chan IdentifiedContainer
 
-	dryRun bool
+	options Options
 }
 
 func NewOOMKillsProcessor(
 	logger *log.Logger,
 	kube *kuber.Kube,
+	scanner *scanner.Scanner,
+	status *StatusReporter,
+	cooldowns *Cooldowns,
 	timeout time.Duration,
-	dryRun bool,
+	options Options,
 ) *OOMKillsProcessor {
 	return &OOMKillsProcessor{
 		logger: logger,
-		kube:   kube,
+		actuator: &memoryActuator{
+			logger:    logger,
+			kube:      kube,
+			scanner:   scanner,
+			status:    status,
+			cooldowns: cooldowns,
+			options:   options,
+		},
+		cooldowns: cooldowns,
 
 		timeout: timeout,
 		pipe:    make(chan IdentifiedContainer, 1000),
 
-		dryRun: dryRun,
+		options: options,
 	}
 }
 
@@ -49,6 +63,14 @@ func (p *OOMKillsProcessor) Stop() {
 }
 
 func (p *OOMKillsProcessor) Applicable(container IdentifiedContainer) bool {
+	if !p.options.isScalable(container.Application.Name, container.Service.Name) {
+		return false
+	}
+
+	if p.cooldowns.IsActive(container.Container.ID) {
+		return false
+	}
+
 	containerStatus := container.Status
 
 	// if current status is OOMKilled then process it
@@ -58,11 +80,11 @@ func (p *OOMKillsProcessor) Applicable(container IdentifiedContainer) bool {
 	}
 
 	// if the old status is OOMKilled and it was terminated one minute ago
-	// and restarted 3 times at least then process it
+	// and restarted enough times then process it
 	if containerStatus.State.Running == nil &&
 		containerStatus.LastTerminationState.Terminated != nil &&
 		containerStatus.LastTerminationState.Terminated.Reason == OOMKilledReason {
-		return containerStatus.RestartCount > 2
+		return containerStatus.RestartCount >= p.options.MinRestarts
 	}
 
 	return false
@@ -82,61 +104,18 @@ func (p *OOMKillsProcessor) Submit(container IdentifiedContainer) error {
 	return nil
 }
 
-func (p *OOMKillsProcessor) handleContainer(status IdentifiedContainer) {
-	container := status.Container
-	service := status.Service
-	application := status.Application
-
-	currentMemLimits := container.Resources.SpecResourceRequirements.Limits.Memory().Value()
-	// convert to Mi
-	currentMemLimits = currentMemLimits / 1024 / 1024
-
-	newMemLimits := currentMemLimits * 3 / 2
-
-	ctx := karma.
-		Describe("container", container.Name).
-		Describe("container-id", container.ID).
-		Describe("service", service.Name).
-		Describe("service-d", service.ID).
-		Describe("application", application.Name).
-		Describe("application-d", application.ID).
-		Describe("old value (Mi)", currentMemLimits).
-		Describe("new value (Mi)", newMemLimits).
-		Describe("dry run", p.dryRun)
-
-	if p.dryRun {
-		//	log info about dryRun
-		p.logger.Infof(ctx, "dry-run enabled, skipping OOMKill handler")
-		return
-	}
-
-	skipped, err := p.kube.SetResources(service.Kind, service.Name, application.Name, kuber.TotalResources{
-		Containers: []kuber.ContainerResourcesRequirements{
-			{
-				Name: container.Name,
-				Limits: kuber.RequestLimit{
-					Memory: &newMemLimits,
-				},
-			},
-		},
-	})
-
-	if err != nil {
-		if skipped {
-			p.logger.Errorf(
-				ctx.Reason(err),
-				"skipping OOMMKill handler execution",
-			)
-		} else {
-			p.logger.Errorf(
-				ctx.Reason(err),
-				"unable to execute OOMKill handler",
-			)
-		}
-
-		return
-	}
-
-	p.logger.Infof(ctx, "OOMKill handler executed")
+// Handle handles the container synchronously
+func (p *OOMKillsProcessor) Handle(container IdentifiedContainer) {
+	p.handleContainer(container)
+}
 
+func (p *OOMKillsProcessor) handleContainer(status IdentifiedContainer) {
+	p.actuator.scale(
+		"OOMKill handler",
+		status,
+		fmt.Sprintf(
+			"container is OOM killed, restarted %d times",
+			status.Status.RestartCount,
+		),
+	)
 }
@@ -0,0 +1,207 @@
+package scalar
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/freeze"
+	"github.com/reconquest/karma-go"
+)
+
+// Options configuration of the in-agent scalar
+type Options struct {
+	DryRun bool
+
+	// MemoryIncreasePercent increase of memory limits of OOM killed
+	// containers
+	MemoryIncreasePercent int64
+	// MaxMemory memory limits are never raised above it (Mi), zero means no
+	// maximum
+	MaxMemory int64
+	// MemoryThreshold usage of memory limits (percent) by growing memory
+	// rss which triggers raising the limits, zero disables scaling by trends
+	// of memory usage
+	MemoryThreshold int64
+	// MemoryGrowth minimal growth of memory rss (Mi per hour) which
+	// triggers raising the limits by trends of memory usage
+	MemoryGrowth int64
+	// MinRestarts restarts of containers terminated by the OOM killer
+	// before the limits are raised
+	MinRestarts int32
+	// Cooldown containers are not scaled again within the cooldown, zero
+	// disables the cooldown
+	Cooldown time.Duration
+
+	// Targets workloads handled by the scalar, all workloads are handled if
+	// no targets are specified
+	Targets []Target
+	// Weights weights of increases of workloads, zero weight disables
+	// scaling of the workload
+	Weights []Weight
+
+	// StatusInterval interval of reporting decisions of the scalar
+	StatusInterval time.Duration
+
+	// Freezer freeze of changes of the cluster, nil freezer checks quiet
+	// hours only
+	Freezer *freeze.Freezer
+}
+
+// Target namespace or workload handled by the scalar, the name is empty if
+// the whole namespace is targeted
+type Target struct {
+	Namespace string
+	Name      string
+}
+
+// String returns the target in the format of ParseTargets
+func (target Target) String() string {
+	if target.Name == "" {
+		return target.Namespace
+	}
+
+	return target.Namespace + "/" + target.Name
+}
+
+// Weight weight of increases of limits of the target, the default weight is 1
+type Weight struct {
+	Target Target
+	Value  float64
+}
+
+// ParseTargets parses comma separated targets, every target is a namespace
+// or a workload in format <namespace>/<name>
+func ParseTargets(spec string) ([]Target, error) {
+	var targets []Target
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target, err := parseTarget(item)
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// ParseWeights parses comma separated weights in format <target>=<weight>
+func ParseWeights(spec string) ([]Weight, error) {
+	var weights []Weight
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, karma.
+				Describe("weight", item).
+				Reason("weight should be <target>=<weight>")
+		}
+
+		target, err := parseTarget(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || value < 0 {
+			return nil, karma.
+				Describe("weight", item).
+				Reason("weight should be a non-negative number")
+		}
+
+		weights = append(weights, Weight{Target: target, Value: value})
+	}
+
+	return weights, nil
+}
+
+func parseTarget(spec string) (Target, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) > 2 || parts[0] == "" ||
+		(len(parts) == 2 && parts[1] == "") {
+		return Target{}, karma.
+			Describe("target", spec).
+			Reason("target should be <namespace> or <namespace>/<name>")
+	}
+
+	target := Target{Namespace: parts[0]}
+	if len(parts) == 2 {
+		target.Name = parts[1]
+	}
+
+	return target, nil
+}
+
+// IsTarget checks whether the workload is handled by the scalar
+func (options Options) IsTarget(namespace string, name string) bool {
+	if len(options.Targets) == 0 {
+		return true
+	}
+
+	for _, target := range options.Targets {
+		if target.Namespace != namespace {
+			continue
+		}
+
+		if target.Name == "" || target.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isScalable checks whether the workload is a target with non-zero weight
+func (options Options) isScalable(namespace string, name string) bool {
+	return options.IsTarget(namespace, name) &&
+		options.GetWeight(namespace, name) > 0
+}
+
+// GetWeight returns weight of the workload, weights of workloads take
+// precedence over weights of namespaces
+func (options Options) GetWeight(namespace string, name string) float64 {
+	weight := 1.0
+	for _, item := range options.Weights {
+		if item.Target.Namespace != namespace {
+			continue
+		}
+
+		if item.Target.Name == name {
+			return item.Value
+		}
+
+		if item.Target.Name == "" {
+			weight = item.Value
+		}
+	}
+
+	return weight
+}
+
+// getMemoryIncrease returns the new memory limit of the workload, the limit
+// is capped by the max memory
+func (options Options) getMemoryIncrease(
+	namespace string,
+	name string,
+	limit int64,
+) int64 {
+	percent := float64(options.MemoryIncreasePercent) *
+		options.GetWeight(namespace, name)
+
+	increased := limit + int64(float64(limit)*percent/100)
+	if options.MaxMemory > 0 && increased > options.MaxMemory {
+		increased = options.MaxMemory
+	}
+
+	return increased
+}
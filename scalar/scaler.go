@@ -1,6 +1,7 @@
 package scalar
 
 import (
+	"github.com/MagalixCorp/magalix-agent/client"
 	"github.com/MagalixCorp/magalix-agent/kuber"
 	"github.com/MagalixCorp/magalix-agent/scanner"
 	"github.com/MagalixTechnologies/log-go"
@@ -9,16 +10,25 @@ import (
 
 func InitScalars(
 	logger *log.Logger,
+	client *client.Client,
 	scanner *scanner.Scanner,
 	kube *kuber.Kube,
-	dryRun bool,
+	options Options,
 ) {
 
+	status := NewStatusReporter(client, options)
+	status.Start()
+
+	cooldowns := NewCooldowns(options.Cooldown)
+
 	sl := NewScannerListener(logger, scanner)
-	oomKilledProcessor := NewOOMKillsProcessor(logger, kube, time.Second, dryRun)
+	oomKilledProcessor := NewOOMKillsProcessor(logger, kube, scanner, status, cooldowns, time.Second, options)
+	memoryTrendProcessor := NewMemoryTrendProcessor(logger, kube, scanner, status, cooldowns, time.Second, options)
 
 	sl.AddContainerListener(oomKilledProcessor)
+	sl.AddContainerListener(memoryTrendProcessor)
 
 	go oomKilledProcessor.Start()
+	go memoryTrendProcessor.Start()
 	go sl.Start()
 }
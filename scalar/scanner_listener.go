@@ -1,6 +1,7 @@
 package scalar
 
 import (
+	"github.com/MagalixCorp/magalix-agent/replay"
 	"github.com/MagalixCorp/magalix-agent/scanner"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/reconquest/karma-go"
@@ -14,6 +15,7 @@ type ContainerProcessor interface {
 }
 
 type IdentifiedContainer struct {
+	PodName     string
 	Container   scanner.Container
 	Service     scanner.Service
 	Application scanner.Application
@@ -91,6 +93,7 @@ func (sl *ScannerListener) processPods() {
 					sl.logger.Errorf(err, "unable to obtain containerStatus ID")
 				} else {
 					status := IdentifiedContainer{
+						PodName:     pod.Name,
 						Container:   *container,
 						Service:     *service,
 						Application: *application,
@@ -98,6 +101,8 @@ func (sl *ScannerListener) processPods() {
 					}
 					for _, listener := range sl.containersListeners {
 						if listener.Applicable(status) {
+							replay.Transition(replay.TransitionContainerStatus, status)
+
 							err := listener.Submit(status)
 							if err != nil {
 								sl.logger.Errorf(
@@ -0,0 +1,143 @@
+package scalar
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+)
+
+// StatusReporter collects decisions of the scalar and reports them with the
+// configuration of the scalar periodically, a nil reporter drops decisions
+type StatusReporter struct {
+	client  *client.Client
+	options Options
+
+	mutex     sync.Mutex
+	decisions []proto.PacketScalarDecision
+
+	// dryRuns patches of the last dry-run results sent per workload, so
+	// the same changes aren't sent again by every check of containers
+	dryRunsMutex sync.Mutex
+	dryRuns      map[string]string
+}
+
+// NewStatusReporter creates a new reporter
+func NewStatusReporter(client *client.Client, options Options) *StatusReporter {
+	return &StatusReporter{
+		client:  client,
+		options: options,
+		dryRuns: map[string]string{},
+	}
+}
+
+// Add adds the decision to the next status
+func (reporter *StatusReporter) Add(decision proto.PacketScalarDecision) {
+	if reporter == nil {
+		return
+	}
+
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+
+	reporter.decisions = append(reporter.decisions, decision)
+}
+
+// SendDryRunResult sends changes which the decision would apply if changes
+// of the scalar were enabled, the result isn't sent if the same changes of
+// the workload were sent already
+func (reporter *StatusReporter) SendDryRunResult(
+	result *proto.PacketDecisionDryRunResult,
+) {
+	if reporter == nil {
+		return
+	}
+
+	if !reporter.client.IsPacketKindSupported(proto.PacketKindDecisionDryRunResult) {
+		return
+	}
+
+	key := strings.ToLower(result.Kind) + "/" + result.Namespace + "/" + result.Name
+
+	reporter.dryRunsMutex.Lock()
+	sent, ok := reporter.dryRuns[key]
+	reporter.dryRuns[key] = result.Patch
+	reporter.dryRunsMutex.Unlock()
+
+	if ok && sent == result.Patch {
+		return
+	}
+
+	reporter.client.Pipe(client.Package{
+		Kind:        proto.PacketKindDecisionDryRunResult,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 100,
+		Priority:    3,
+		Retries:     10,
+		Data:        result,
+	})
+}
+
+// Start starts reporting
+func (reporter *StatusReporter) Start() {
+	ticker := utils.NewTicker(
+		reporter.client.Logger,
+		"scalar-status",
+		reporter.options.StatusInterval,
+		reporter.report,
+	)
+	ticker.Start(false, false, false)
+}
+
+// report sends collected decisions, decisions are dropped if the gateway
+// doesn't support statuses of the scalar, so they don't pile up
+func (reporter *StatusReporter) report(tickTime time.Time) {
+	reporter.mutex.Lock()
+	decisions := reporter.decisions
+	reporter.decisions = nil
+	reporter.mutex.Unlock()
+
+	if !reporter.client.IsPacketKindSupported(proto.PacketKindScalarStatus) {
+		return
+	}
+
+	var targets []string
+	for _, target := range reporter.options.Targets {
+		targets = append(targets, target.String())
+	}
+
+	var weights map[string]float64
+	for _, weight := range reporter.options.Weights {
+		if weights == nil {
+			weights = map[string]float64{}
+		}
+
+		weights[weight.Target.String()] = weight.Value
+	}
+
+	reporter.client.Pipe(client.Package{
+		Kind:        proto.PacketKindScalarStatus,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    6,
+		Retries:     10,
+		Data: proto.PacketScalarStatus{
+			Timestamp: tickTime,
+			Config: proto.PacketScalarConfig{
+				DryRun:                reporter.options.DryRun,
+				MemoryIncreasePercent: reporter.options.MemoryIncreasePercent,
+				MaxMemory:             reporter.options.MaxMemory,
+				MemoryThreshold:       reporter.options.MemoryThreshold,
+				MemoryGrowth:          reporter.options.MemoryGrowth,
+				MinRestarts:           reporter.options.MinRestarts,
+				Cooldown:              reporter.options.Cooldown,
+				Targets:               targets,
+				Weights:               weights,
+			},
+			Decisions: decisions,
+		},
+	})
+}
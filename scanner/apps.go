@@ -65,15 +65,39 @@ func PacketApplications(applications []*Application) proto.PacketApplicationsSto
 					proto.PacketRegisterContainerItem{
 						PacketRegisterEntityItem: proto.PacketRegisterEntityItem(container.Entity),
 						Image:                    container.Image,
+						ImageTag:                 container.ImageTag,
+						ImageDigests:             container.ImageDigests,
 						Resources:                b,
+						Init:                     container.Init,
 					},
 				)
 			}
 
+			ephemeralContainers := []proto.PacketEphemeralContainerItem{}
+			for _, container := range service.EphemeralContainers {
+				ephemeralContainers = append(
+					ephemeralContainers,
+					proto.PacketEphemeralContainerItem(container),
+				)
+			}
+
 			services = append(services, proto.PacketRegisterServiceItem{
 				PacketRegisterEntityItem: proto.PacketRegisterEntityItem(service.Entity),
 				ReplicasStatus:           service.ReplicasStatus,
 				Containers:               containers,
+				EphemeralContainers:      ephemeralContainers,
+				AutomationDisabled:       service.AutomationDisabled,
+				SLO:                      (*proto.PacketServiceSLO)(service.SLO),
+				NetworkPolicySelected:    service.NetworkPolicySelected,
+				QOSClass:                 service.QOSClass,
+				PriorityClassName:        service.PriorityClassName,
+				RolloutStrategy:          service.RolloutStrategy,
+
+				CreatedAt:       service.CreatedAt,
+				ResourceVersion: service.ResourceVersion,
+				Deploys:         service.Deploys,
+				DeploysWindow:   deploysWindow,
+				LastDeployedAt:  service.LastDeployedAt,
 			})
 		}
 
@@ -83,6 +107,7 @@ func PacketApplications(applications []*Application) proto.PacketApplicationsSto
 				PacketRegisterEntityItem: proto.PacketRegisterEntityItem(application.Entity),
 				Services:                 services,
 				LimitRanges:              application.LimitRanges,
+				ResourceQuotas:           application.ResourceQuotas,
 			},
 		)
 	}
@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"strings"
+)
+
+const (
+	// AnnotationAutomation annotation controlling automation of a workload
+	AnnotationAutomation = "magalix.com/automation"
+	// AutomationDisabled value of AnnotationAutomation which opts the
+	// workload out of automated changes
+	AutomationDisabled = "disabled"
+)
+
+// isAutomationDisabled checks whether the workload is opted out of
+// automated changes by its annotations
+func isAutomationDisabled(annotations map[string]string) bool {
+	value, ok := annotations[AnnotationAutomation]
+	if !ok {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSpace(value), AutomationDisabled)
+}
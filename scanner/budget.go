@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"github.com/reconquest/karma-go"
+)
+
+// maxBudgetFactor max multiplier of the scan interval while the api budget
+// is exceeded
+const maxBudgetFactor = 8
+
+// apiBudget budget of kubernetes api requests per scan, once a scan exceeds
+// the budget the scanner degrades by scanning less often and skipping
+// optional kinds until scans use at most half of the budget
+type apiBudget struct {
+	limit int64
+
+	factor   int
+	requests int64
+}
+
+func newAPIBudget(limit int64) *apiBudget {
+	return &apiBudget{
+		limit:  limit,
+		factor: 1,
+	}
+}
+
+// observe records requests done by a scan, it returns true if the factor
+// changed
+func (budget *apiBudget) observe(requests int64) bool {
+	budget.requests = requests
+
+	if budget.limit <= 0 {
+		return false
+	}
+
+	switch {
+	case requests > budget.limit:
+		if budget.factor < maxBudgetFactor {
+			budget.factor *= 2
+			return true
+		}
+
+	case requests <= budget.limit/2 && budget.factor > 1:
+		budget.factor /= 2
+		return true
+	}
+
+	return false
+}
+
+// isDegraded checks whether optional kinds should be skipped
+func (budget *apiBudget) isDegraded() bool {
+	return budget.factor > 1
+}
+
+// observeScanUsage records kubernetes api requests of the scanner done since
+// the scan started
+func (scanner *Scanner) observeScanUsage(started int64) {
+	requests := scanner.kube.Usage.Requests() - started
+
+	scanner.mutex.Lock()
+	changed := scanner.budget.observe(requests)
+	state := *scanner.budget
+	scanner.mutex.Unlock()
+
+	ctx := karma.
+		Describe("requests", requests).
+		Describe("budget", state.limit).
+		Describe("factor", state.factor)
+
+	if !changed {
+		scanner.logger.Debugf(ctx, "kubernetes api requests of the scan")
+		return
+	}
+
+	if state.isDegraded() {
+		scanner.logger.Warningf(
+			ctx,
+			"kubernetes api budget exceeded, scanning less often and "+
+				"skipping optional kinds",
+		)
+	} else {
+		scanner.logger.Infof(ctx, "kubernetes api usage is within budget")
+	}
+}
+
+// isDegraded checks whether the scanner skips optional kinds to stay within
+// the api budget
+func (scanner *Scanner) isDegraded() bool {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	return scanner.budget.isDegraded()
+}
+
+// getIntervalFactor returns the multiplier of the scan interval, scans are
+// done less often while the api-server is throttling requests or the api
+// budget is exceeded
+func (scanner *Scanner) getIntervalFactor() int {
+	factor := scanner.kube.Throttling.GetState().Factor
+
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	if scanner.budget.factor > factor {
+		factor = scanner.budget.factor
+	}
+
+	return factor
+}
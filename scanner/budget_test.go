@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"testing"
+)
+
+func TestAPIBudget(t *testing.T) {
+	budget := newAPIBudget(100)
+
+	for i, step := range []struct {
+		requests int64
+		changed  bool
+		factor   int
+	}{
+		{80, false, 1},
+		{120, true, 2},
+		{90, false, 2},
+		{300, true, 4},
+		{200, true, 8},
+		{200, false, 8},
+		{50, true, 4},
+		{40, true, 2},
+		{30, true, 1},
+		{10, false, 1},
+	} {
+		changed := budget.observe(step.requests)
+		if changed != step.changed || budget.factor != step.factor {
+			t.Errorf(
+				"step %d: expected changed %v factor %d, got %v %d",
+				i, step.changed, step.factor, changed, budget.factor,
+			)
+		}
+
+		if budget.isDegraded() != (step.factor > 1) {
+			t.Errorf("step %d: unexpected degraded state", i)
+		}
+	}
+
+	unlimited := newAPIBudget(0)
+	if unlimited.observe(1000) || unlimited.isDegraded() {
+		t.Errorf("zero budget should never degrade")
+	}
+}
@@ -0,0 +1,28 @@
+package scanner
+
+import (
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+)
+
+// Client sends scanned entities, it's implemented by the gateway client,
+// tools importing the scanner may use DiscardClient instead
+type Client interface {
+	Pipe(pack client.Package)
+	SendRaw(rawResources map[string]interface{})
+	IsPacketKindSupported(kind proto.PacketKind) bool
+}
+
+// DiscardClient client which drops scanned entities
+type DiscardClient struct{}
+
+// Pipe drops the package
+func (DiscardClient) Pipe(client.Package) {}
+
+// SendRaw drops raw resources
+func (DiscardClient) SendRaw(map[string]interface{}) {}
+
+// IsPacketKindSupported reports all packet kinds as supported
+func (DiscardClient) IsPacketKindSupported(proto.PacketKind) bool {
+	return true
+}
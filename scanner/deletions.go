@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/shard"
+	"github.com/MagalixTechnologies/uuid-go"
+	kv1 "k8s.io/api/core/v1"
+)
+
+// Deletion entities which disappeared since the previous scan
+type Deletion struct {
+	Applications []uuid.UUID
+	Services     []uuid.UUID
+	Containers   []uuid.UUID
+	Pods         []proto.PacketDeletedPod
+}
+
+// IsEmpty checks whether nothing was deleted
+func (deletion Deletion) IsEmpty() bool {
+	return len(deletion.Applications) == 0 &&
+		len(deletion.Services) == 0 &&
+		len(deletion.Containers) == 0 &&
+		len(deletion.Pods) == 0
+}
+
+// deletionsTracker remembers entities of the previous scan to find deleted
+// ones, nothing is deleted on the first scan
+type deletionsTracker struct {
+	scanned bool
+
+	applications map[uuid.UUID]struct{}
+	services     map[uuid.UUID]struct{}
+	containers   map[uuid.UUID]struct{}
+	pods         map[proto.PacketDeletedPod]struct{}
+
+	listenersMutex sync.Mutex
+	listeners      []func(Deletion)
+}
+
+func newDeletionsTracker() *deletionsTracker {
+	return &deletionsTracker{}
+}
+
+// observe remembers entities of the scan and returns entities of the
+// previous scan which are not found anymore
+func (tracker *deletionsTracker) observe(
+	apps []*Application,
+	pods []kv1.Pod,
+) Deletion {
+	applications := map[uuid.UUID]struct{}{}
+	services := map[uuid.UUID]struct{}{}
+	containers := map[uuid.UUID]struct{}{}
+	podsKeys := map[proto.PacketDeletedPod]struct{}{}
+
+	for _, app := range apps {
+		applications[app.ID] = struct{}{}
+		for _, service := range app.Services {
+			services[service.ID] = struct{}{}
+			for _, container := range service.Containers {
+				containers[container.ID] = struct{}{}
+			}
+		}
+	}
+
+	for _, pod := range pods {
+		podsKeys[proto.PacketDeletedPod{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+		}] = struct{}{}
+	}
+
+	var deletion Deletion
+	if tracker.scanned {
+		deletion = Deletion{
+			Applications: getDeletedIDs(tracker.applications, applications),
+			Services:     getDeletedIDs(tracker.services, services),
+			Containers:   getDeletedIDs(tracker.containers, containers),
+		}
+
+		for pod := range tracker.pods {
+			if _, ok := podsKeys[pod]; !ok {
+				deletion.Pods = append(deletion.Pods, pod)
+			}
+		}
+
+		sort.Slice(deletion.Pods, func(i, j int) bool {
+			if deletion.Pods[i].Namespace != deletion.Pods[j].Namespace {
+				return deletion.Pods[i].Namespace < deletion.Pods[j].Namespace
+			}
+
+			return deletion.Pods[i].Name < deletion.Pods[j].Name
+		})
+	}
+
+	tracker.scanned = true
+	tracker.applications = applications
+	tracker.services = services
+	tracker.containers = containers
+	tracker.pods = podsKeys
+
+	return deletion
+}
+
+func getDeletedIDs(previous, current map[uuid.UUID]struct{}) []uuid.UUID {
+	var deleted []uuid.UUID
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			deleted = append(deleted, id)
+		}
+	}
+
+	sort.Slice(deleted, func(i, j int) bool {
+		return deleted[i].String() < deleted[j].String()
+	})
+
+	return deleted
+}
+
+// AddDeletionListener adds a listener called with entities deleted since
+// the previous scan
+func (scanner *Scanner) AddDeletionListener(listener func(Deletion)) {
+	scanner.deletions.listenersMutex.Lock()
+	defer scanner.deletions.listenersMutex.Unlock()
+
+	scanner.deletions.listeners = append(scanner.deletions.listeners, listener)
+}
+
+// handleDeletions sends tombstones of deleted entities and notifies
+// listeners
+func (scanner *Scanner) handleDeletions(apps []*Application, pods []kv1.Pod) {
+	deletion := scanner.deletions.observe(apps, pods)
+	if deletion.IsEmpty() {
+		return
+	}
+
+	scanner.logger.Infof(
+		nil,
+		"found deleted entities: %d applications, %d services, "+
+			"%d containers, %d pods",
+		len(deletion.Applications),
+		len(deletion.Services),
+		len(deletion.Containers),
+		len(deletion.Pods),
+	)
+
+	// NOTE: every shard scans the whole cluster, so tombstones are sent by
+	// the primary shard only
+	if shard.IsPrimary() {
+		scanner.SendDeletion(deletion)
+	}
+
+	scanner.deletions.listenersMutex.Lock()
+	listeners := scanner.deletions.listeners
+	scanner.deletions.listenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(deletion)
+	}
+}
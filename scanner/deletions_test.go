@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeletionsTracker(t *testing.T) {
+	var (
+		appID       = uuid.NewV4()
+		apiID       = uuid.NewV4()
+		workerID    = uuid.NewV4()
+		apiAppID    = uuid.NewV4()
+		workerAppID = uuid.NewV4()
+	)
+
+	apps := func(workers bool) []*Application {
+		app := &Application{
+			Entity: Entity{ID: appID, Name: "default"},
+			Services: []*Service{
+				{
+					Entity: Entity{ID: apiID, Name: "api"},
+					Containers: []*Container{
+						{Entity: Entity{ID: apiAppID, Name: "app"}},
+					},
+				},
+			},
+		}
+
+		if workers {
+			app.Services = append(app.Services, &Service{
+				Entity: Entity{ID: workerID, Name: "worker"},
+				Containers: []*Container{
+					{Entity: Entity{ID: workerAppID, Name: "app"}},
+				},
+			})
+		}
+
+		return []*Application{app}
+	}
+
+	pod := func(name string) kv1.Pod {
+		return kv1.Pod{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default", Name: name},
+		}
+	}
+
+	tracker := newDeletionsTracker()
+
+	deletion := tracker.observe(apps(true), []kv1.Pod{pod("api-1"), pod("worker-1")})
+	if !deletion.IsEmpty() {
+		t.Fatalf("expected nothing deleted on the first scan, got %+v", deletion)
+	}
+
+	deletion = tracker.observe(apps(true), []kv1.Pod{pod("api-1"), pod("worker-1")})
+	if !deletion.IsEmpty() {
+		t.Fatalf("expected nothing deleted, got %+v", deletion)
+	}
+
+	deletion = tracker.observe(apps(false), []kv1.Pod{pod("api-2")})
+
+	expected := Deletion{
+		Services:   []uuid.UUID{workerID},
+		Containers: []uuid.UUID{workerAppID},
+		Pods: []proto.PacketDeletedPod{
+			{Namespace: "default", Name: "api-1"},
+			{Namespace: "default", Name: "worker-1"},
+		},
+	}
+	if !reflect.DeepEqual(deletion, expected) {
+		t.Errorf("expected deletion %+v, got %+v", expected, deletion)
+	}
+}
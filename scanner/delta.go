@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+// entitiesDelta tracks versions of entities sent to the gateway, so only
+// changed entities are sent between full resyncs. Version of a service is
+// derived from resource version of its workload and fields computed by the
+// scanner, such as replicas status and deploys
+type entitiesDelta struct {
+	resyncInterval time.Duration
+
+	// base time of the last full snapshot
+	base     time.Time
+	sequence int
+
+	applications map[uuid.UUID]string
+	services     map[uuid.UUID]string
+	containers   map[uuid.UUID]string
+}
+
+func newEntitiesDelta(resyncInterval time.Duration) *entitiesDelta {
+	return &entitiesDelta{
+		resyncInterval: resyncInterval,
+	}
+}
+
+// reset forces a full snapshot on the next scan
+func (delta *entitiesDelta) reset() {
+	delta.base = time.Time{}
+}
+
+// next remembers versions of entities of the snapshot and returns changes
+// since the previous snapshot, nil is returned if a full snapshot should be
+// sent instead, the sequence isn't advanced by empty deltas
+func (delta *entitiesDelta) next(
+	snapshot proto.PacketApplicationsStoreRequest,
+	now time.Time,
+) *proto.PacketApplicationsDeltaRequest {
+	applications := map[uuid.UUID]string{}
+	services := map[uuid.UUID]string{}
+	containers := map[uuid.UUID]string{}
+
+	packet := &proto.PacketApplicationsDeltaRequest{
+		Base:      delta.base,
+		Sequence:  delta.sequence + 1,
+		Timestamp: now,
+	}
+
+	for _, application := range snapshot {
+		item := application
+		item.Services = nil
+
+		version := getEntityVersion(item)
+		applications[item.ID] = version
+
+		if previous, ok := delta.applications[item.ID]; !ok {
+			packet.Applications.Added = append(packet.Applications.Added, item)
+		} else if previous != version {
+			packet.Applications.Updated = append(packet.Applications.Updated, item)
+		}
+
+		for _, service := range application.Services {
+			item := proto.PacketDeltaServiceItem{
+				PacketRegisterServiceItem: service,
+				ApplicationID:             application.ID,
+			}
+			item.Containers = nil
+
+			version := getEntityVersion(item)
+			services[item.ID] = version
+
+			if previous, ok := delta.services[item.ID]; !ok {
+				packet.Services.Added = append(packet.Services.Added, item)
+			} else if previous != version {
+				packet.Services.Updated = append(packet.Services.Updated, item)
+			}
+
+			for _, container := range service.Containers {
+				item := proto.PacketDeltaContainerItem{
+					PacketRegisterContainerItem: container,
+					ServiceID:                   service.ID,
+				}
+
+				version := getEntityVersion(item)
+				containers[item.ID] = version
+
+				if previous, ok := delta.containers[item.ID]; !ok {
+					packet.Containers.Added = append(packet.Containers.Added, item)
+				} else if previous != version {
+					packet.Containers.Updated = append(packet.Containers.Updated, item)
+				}
+			}
+		}
+	}
+
+	packet.Applications.Removed = getRemovedEntities(delta.applications, applications)
+	packet.Services.Removed = getRemovedEntities(delta.services, services)
+	packet.Containers.Removed = getRemovedEntities(delta.containers, containers)
+
+	delta.applications = applications
+	delta.services = services
+	delta.containers = containers
+
+	if delta.resyncInterval <= 0 || delta.base.IsZero() ||
+		now.Sub(delta.base) >= delta.resyncInterval {
+		delta.base = now
+		delta.sequence = 0
+
+		return nil
+	}
+
+	if !isDeltaEmpty(packet) {
+		delta.sequence = packet.Sequence
+	}
+
+	return packet
+}
+
+// isDeltaEmpty checks whether the delta has no changes
+func isDeltaEmpty(packet *proto.PacketApplicationsDeltaRequest) bool {
+	return len(packet.Applications.Added) == 0 &&
+		len(packet.Applications.Updated) == 0 &&
+		len(packet.Applications.Removed) == 0 &&
+		len(packet.Services.Added) == 0 &&
+		len(packet.Services.Updated) == 0 &&
+		len(packet.Services.Removed) == 0 &&
+		len(packet.Containers.Added) == 0 &&
+		len(packet.Containers.Updated) == 0 &&
+		len(packet.Containers.Removed) == 0
+}
+
+func getRemovedEntities(previous, current map[uuid.UUID]string) []uuid.UUID {
+	removed := []uuid.UUID{}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+
+	return removed
+}
+
+func getEntityVersion(item interface{}) string {
+	data, err := json.Marshal(item)
+	if err != nil {
+		// NOTE: entities which can't be encoded are always considered
+		// as changed
+		return uuid.NewV4().String()
+	}
+
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:])
+}
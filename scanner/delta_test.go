@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestEntitiesDelta(t *testing.T) {
+	applicationID := uuid.NewV4()
+	serviceID := uuid.NewV4()
+	containerID := uuid.NewV4()
+
+	getSnapshot := func(resourceVersion string, image string) proto.PacketApplicationsStoreRequest {
+		return proto.PacketApplicationsStoreRequest{
+			{
+				PacketRegisterEntityItem: proto.PacketRegisterEntityItem{
+					ID:   applicationID,
+					Name: "default",
+				},
+				Services: []proto.PacketRegisterServiceItem{
+					{
+						PacketRegisterEntityItem: proto.PacketRegisterEntityItem{
+							ID:   serviceID,
+							Name: "api",
+						},
+						ResourceVersion: resourceVersion,
+						Containers: []proto.PacketRegisterContainerItem{
+							{
+								PacketRegisterEntityItem: proto.PacketRegisterEntityItem{
+									ID:   containerID,
+									Name: "api",
+								},
+								Image: image,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	delta := newEntitiesDelta(time.Hour)
+	now := time.Now()
+
+	if packet := delta.next(getSnapshot("1", "api:1"), now); packet != nil {
+		t.Fatalf("expected full snapshot on the first scan")
+	}
+
+	packet := delta.next(getSnapshot("1", "api:1"), now.Add(time.Minute))
+	if packet == nil || !isDeltaEmpty(packet) {
+		t.Fatalf("expected empty delta, got %+v", packet)
+	}
+
+	packet = delta.next(getSnapshot("2", "api:2"), now.Add(2*time.Minute))
+	if packet == nil {
+		t.Fatalf("expected delta")
+	}
+	if packet.Sequence != 1 || !packet.Base.Equal(now) {
+		t.Fatalf("unexpected base %s and sequence %d", packet.Base, packet.Sequence)
+	}
+	if len(packet.Applications.Updated) != 0 {
+		t.Fatalf("expected application to be unchanged")
+	}
+	if len(packet.Services.Updated) != 1 || packet.Services.Updated[0].ApplicationID != applicationID {
+		t.Fatalf("expected service to be updated, got %+v", packet.Services)
+	}
+	if packet.Services.Updated[0].Containers != nil {
+		t.Fatalf("expected service to be sent without containers")
+	}
+	if len(packet.Containers.Updated) != 1 || packet.Containers.Updated[0].ServiceID != serviceID {
+		t.Fatalf("expected container to be updated, got %+v", packet.Containers)
+	}
+
+	packet = delta.next(proto.PacketApplicationsStoreRequest{}, now.Add(3*time.Minute))
+	if packet == nil || packet.Sequence != 2 {
+		t.Fatalf("expected second delta, got %+v", packet)
+	}
+	if len(packet.Applications.Removed) != 1 ||
+		len(packet.Services.Removed) != 1 ||
+		len(packet.Containers.Removed) != 1 {
+		t.Fatalf("expected all entities to be removed, got %+v", packet)
+	}
+
+	if packet := delta.next(getSnapshot("2", "api:2"), now.Add(time.Hour)); packet != nil {
+		t.Fatalf("expected full snapshot after resync interval")
+	}
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+// deploysWindow window of counted deploys of a service
+const deploysWindow = 7 * 24 * time.Hour
+
+// serviceDeploys observed deploys of a service
+type serviceDeploys struct {
+	templateHash   string
+	deploys        []time.Time
+	lastDeployedAt *time.Time
+}
+
+// deploysTracker detects deploys of services by changes of their pod
+// templates hashes between scans
+type deploysTracker struct {
+	window   time.Duration
+	services map[uuid.UUID]*serviceDeploys
+}
+
+func newDeploysTracker(window time.Duration) *deploysTracker {
+	return &deploysTracker{
+		window:   window,
+		services: map[uuid.UUID]*serviceDeploys{},
+	}
+}
+
+// observe records the current template hash of services, a deploy is
+// counted if the hash differs from the previous scan
+func (tracker *deploysTracker) observe(apps []*Application, now time.Time) {
+	seen := map[uuid.UUID]struct{}{}
+
+	for _, app := range apps {
+		for _, service := range app.Services {
+			seen[service.ID] = struct{}{}
+
+			deploys, ok := tracker.services[service.ID]
+			if !ok {
+				// NOTE: deploys happened before the agent started are unknown
+				tracker.services[service.ID] = &serviceDeploys{
+					templateHash: service.templateHash,
+				}
+				continue
+			}
+
+			if service.templateHash != "" &&
+				deploys.templateHash != service.templateHash {
+				deployedAt := now
+				deploys.templateHash = service.templateHash
+				deploys.deploys = append(deploys.deploys, now)
+				deploys.lastDeployedAt = &deployedAt
+			}
+
+			deploys.deploys = deploysWithin(deploys.deploys, now.Add(-tracker.window))
+
+			service.Deploys = len(deploys.deploys)
+			service.LastDeployedAt = deploys.lastDeployedAt
+		}
+	}
+
+	for id := range tracker.services {
+		if _, ok := seen[id]; !ok {
+			delete(tracker.services, id)
+		}
+	}
+}
+
+func deploysWithin(deploys []time.Time, since time.Time) []time.Time {
+	for i, deploy := range deploys {
+		if !deploy.Before(since) {
+			return deploys[i:]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MagalixTechnologies/uuid-go"
+)
+
+func TestDeploysTracker(t *testing.T) {
+	tracker := newDeploysTracker(time.Hour)
+	service := &Service{Entity: Entity{ID: uuid.NewV4()}}
+	apps := []*Application{{Services: []*Service{service}}}
+
+	now := time.Now()
+	for i, hash := range []string{"a", "a", "b", "c"} {
+		service.templateHash = hash
+		tracker.observe(apps, now.Add(time.Duration(i)*time.Minute))
+	}
+
+	if service.Deploys != 2 {
+		t.Errorf("deploys = %d, want 2", service.Deploys)
+	}
+
+	if service.LastDeployedAt == nil ||
+		!service.LastDeployedAt.Equal(now.Add(3*time.Minute)) {
+		t.Errorf("last deployed at = %v", service.LastDeployedAt)
+	}
+
+	tracker.observe(apps, now.Add(2*time.Hour))
+	if service.Deploys != 0 {
+		t.Errorf("deploys out of window = %d, want 0", service.Deploys)
+	}
+}
@@ -3,10 +3,12 @@ package scanner
 import (
 	"crypto/sha256"
 	"regexp"
+	"time"
 
 	"github.com/MagalixCorp/magalix-agent/proto"
 	"github.com/MagalixTechnologies/uuid-go"
 	kv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // Entity basic entity structure can be an application, a service or a container
@@ -16,6 +18,7 @@ type Entity struct {
 	Kind string
 
 	Annotations map[string]string
+	Environment string
 }
 
 // IdentifyEntity sets the id of an entity
@@ -33,8 +36,12 @@ func (entity *Entity) Identify(parent uuid.UUID) error {
 type Application struct {
 	Entity
 
-	Services    []*Service
-	LimitRanges []kv1.LimitRange
+	Services       []*Service
+	LimitRanges    []kv1.LimitRange
+	ResourceQuotas []kv1.ResourceQuota
+
+	// podServices services of pods resolved by owner references on scan
+	podServices map[string]*Service
 }
 
 // Service an abstraction layer representing a service
@@ -43,10 +50,47 @@ type Application struct {
 type Service struct {
 	Entity
 
-	PodRegexp      *regexp.Regexp
+	PodRegexp      *regexp.Regexp `json:"-"`
 	ReplicasStatus proto.ReplicasStatus
 
 	Containers []*Container
+
+	EphemeralContainers []EphemeralContainer
+
+	// AutomationDisabled the workload is opted out of automated changes
+	AutomationDisabled bool
+
+	// SLO service level objectives specified by annotations
+	SLO *SLO
+
+	// QOSClass QoS class of pods of the service
+	QOSClass kv1.PodQOSClass
+	// PriorityClassName priority class of pods of the service
+	PriorityClassName string
+	// RolloutStrategy strategy used to replace pods of the service
+	RolloutStrategy *proto.RolloutStrategy
+
+	// NetworkPolicySelected whether pods of the service are selected by a
+	// network policy, nil if network policies couldn't be scanned
+	NetworkPolicySelected *bool
+
+	// CreatedAt creation time of the workload
+	CreatedAt time.Time
+	// ResourceVersion resource version of the workload
+	ResourceVersion string
+	// Deploys count of deploys observed within the deploys window
+	Deploys int
+	// LastDeployedAt time of the last observed deploy
+	LastDeployedAt *time.Time
+
+	templateHash string
+	// uid uid of the workload
+	uid types.UID
+}
+
+// GetUID returns uid of the workload of the service
+func (service *Service) GetUID() types.UID {
+	return service.uid
 }
 
 // Container represents a single container controlled by a service
@@ -57,6 +101,14 @@ type Container struct {
 
 	Image     string
 	Resources *proto.ContainerResourceRequirements `json:"resources"`
+
+	// ImageTag tag of the image reference
+	ImageTag string
+	// ImageDigests digests of the image run by pods of the service
+	ImageDigests []string
+
+	// Init whether the container is an init container
+	Init bool
 }
 
 func IdentifyEntity(target string, parent uuid.UUID) (uuid.UUID, error) {
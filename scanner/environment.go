@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"strings"
+
+	"github.com/reconquest/karma-go"
+	"github.com/ryanuber/go-glob"
+)
+
+// EnvironmentRule classifies an entity into an environment when the entity
+// has a label or an annotation with the given key whose value matches the
+// glob pattern
+type EnvironmentRule struct {
+	Environment string
+	Key         string
+	Pattern     string
+}
+
+// ParseEnvironmentRules parses rules in the form of
+// <environment>:<key>=<pattern>, e.g. production:env=prod*
+// if the pattern is omitted, the presence of the key is enough to match
+func ParseEnvironmentRules(specs []string) ([]EnvironmentRule, error) {
+	rules := []EnvironmentRule{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, karma.
+				Describe("rule", spec).
+				Reason("environment rule should be <environment>:<key>=<pattern>")
+		}
+
+		rule := EnvironmentRule{
+			Environment: parts[0],
+			Pattern:     "*",
+		}
+
+		selector := strings.SplitN(parts[1], "=", 2)
+		rule.Key = selector[0]
+		if len(selector) == 2 {
+			rule.Pattern = selector[1]
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// classifyEnvironment returns the environment of the first matching rule,
+// labels are checked before annotations
+func classifyEnvironment(
+	rules []EnvironmentRule,
+	labels map[string]string,
+	annotations map[string]string,
+) string {
+	for _, rule := range rules {
+		for _, values := range []map[string]string{labels, annotations} {
+			value, ok := values[rule.Key]
+			if ok && glob.Glob(rule.Pattern, value) {
+				return rule.Environment
+			}
+		}
+	}
+
+	return ""
+}
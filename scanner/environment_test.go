@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvironmentRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []EnvironmentRule
+		wantErr bool
+	}{
+		{
+			name:  "rule with pattern",
+			specs: []string{"production:env=prod*"},
+			want: []EnvironmentRule{
+				{Environment: "production", Key: "env", Pattern: "prod*"},
+			},
+		},
+		{
+			name:  "rule without pattern",
+			specs: []string{"dev:example.com/dev"},
+			want: []EnvironmentRule{
+				{Environment: "dev", Key: "example.com/dev", Pattern: "*"},
+			},
+		},
+		{
+			name:    "rule without key",
+			specs:   []string{"staging"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEnvironmentRules(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseEnvironmentRules() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEnvironmentRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyEnvironment(t *testing.T) {
+	rules := []EnvironmentRule{
+		{Environment: "production", Key: "env", Pattern: "prod*"},
+		{Environment: "staging", Key: "stage", Pattern: "*"},
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        string
+	}{
+		{
+			name:   "matched by label",
+			labels: map[string]string{"env": "production"},
+			want:   "production",
+		},
+		{
+			name:        "matched by annotation",
+			annotations: map[string]string{"stage": "true"},
+			want:        "staging",
+		},
+		{
+			name:   "not matched",
+			labels: map[string]string{"env": "dev"},
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyEnvironment(rules, tt.labels, tt.annotations)
+			if got != tt.want {
+				t.Errorf("classifyEnvironment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
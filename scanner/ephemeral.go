@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/utils"
+)
+
+// EphemeralContainer a debug container attached to a pod of a service,
+// ephemeral containers don't get an identity so they are never bound to
+// metrics nor targeted by decisions
+type EphemeralContainer struct {
+	Name                string `json:"name"`
+	PodName             string `json:"pod_name"`
+	Image               string `json:"image"`
+	TargetContainerName string `json:"target_container_name,omitempty"`
+}
+
+// bindEphemeralContainers attaches ephemeral containers to services owning
+// their pods, it returns a set of ephemeral containers keys
+func bindEphemeralContainers(
+	apps []*Application,
+	containers []kuber.EphemeralContainer,
+	skipNamespaces []string,
+) map[string]struct{} {
+	keys := map[string]struct{}{}
+
+	for _, container := range containers {
+		if utils.InSkipNamespace(skipNamespaces, container.Namespace) {
+			continue
+		}
+
+		keys[getEphemeralContainerKey(
+			container.Namespace,
+			container.PodName,
+			container.Name,
+		)] = struct{}{}
+
+		service := findServiceByPod(apps, container.Namespace, container.PodName)
+		if service == nil {
+			continue
+		}
+
+		service.EphemeralContainers = append(
+			service.EphemeralContainers,
+			EphemeralContainer{
+				Name:                container.Name,
+				PodName:             container.PodName,
+				Image:               container.Image,
+				TargetContainerName: container.TargetContainerName,
+			},
+		)
+	}
+
+	return keys
+}
+
+func getEphemeralContainerKey(namespace, podName, containerName string) string {
+	return namespace + "/" + podName + "/" + containerName
+}
+
+// findServiceByPod finds a scanned service owning the given pod
+func findServiceByPod(
+	apps []*Application,
+	namespace string,
+	podName string,
+) *Service {
+	for _, app := range apps {
+		if app.Name != namespace {
+			continue
+		}
+
+		for _, service := range app.Services {
+			if app.OwnsPod(service, podName) {
+				return service
+			}
+		}
+
+		break
+	}
+
+	return nil
+}
+
+// IsEphemeralContainer checks whether the container of the given pod is an
+// ephemeral container found in the last scan
+func (scanner *Scanner) IsEphemeralContainer(
+	namespace string,
+	podName string,
+	containerName string,
+) bool {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	_, ok := scanner.ephemeralContainers[getEphemeralContainerKey(
+		namespace,
+		podName,
+		containerName,
+	)]
+	return ok
+}
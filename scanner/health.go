@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+)
+
+// maxMissedScans count of missed scans after which the scanner is unhealthy
+const maxMissedScans = 3
+
+// getHealth reports scanner health, the scanner is unhealthy if it didn't
+// finish a scan for several intervals
+func (scanner *Scanner) getHealth() proto.PacketComponentHealth {
+	now := time.Now().UTC()
+
+	appsAge := now.Sub(scanner.AppsLastScanTime())
+	nodesAge := now.Sub(scanner.NodesLastScanTime())
+
+	// NOTE: scans are skipped while the api-server is throttling requests
+	// or the api budget is exceeded
+	maxAge := intervalScanner * maxMissedScans *
+		time.Duration(scanner.getIntervalFactor())
+
+	scanner.mutex.Lock()
+	requests := scanner.budget.requests
+	scanner.mutex.Unlock()
+
+	health := proto.PacketComponentHealth{
+		Healthy:     appsAge <= maxAge && nodesAge <= maxAge,
+		LastSuccess: scanner.AppsLastScanTime(),
+		Values: map[string]int64{
+			"apps_age_seconds":      int64(appsAge.Seconds()),
+			"nodes_age_seconds":     int64(nodesAge.Seconds()),
+			"api_requests_per_scan": requests,
+		},
+	}
+
+	if !health.Healthy {
+		health.Message = "scanner didn't finish a scan for " +
+			appsAge.Round(time.Second).String()
+	}
+
+	return health
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"sort"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	kv1 "k8s.io/api/core/v1"
+)
+
+// bindImagesDigests sets digests of images run by pods of services to their
+// containers, pods of a service may run several digests during a rollout or
+// if a mutable tag was pulled at different times
+func bindImagesDigests(apps []*Application, pods []kv1.Pod) {
+	digests := map[*Container]map[string]struct{}{}
+
+	for _, pod := range pods {
+		service := findServiceByPod(apps, pod.Namespace, pod.Name)
+		if service == nil {
+			continue
+		}
+
+		statuses := append(
+			append([]kv1.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+			pod.Status.ContainerStatuses...,
+		)
+
+		for _, status := range statuses {
+			digest := kuber.GetImageDigest(status.ImageID)
+			if digest == "" {
+				continue
+			}
+
+			for _, container := range service.Containers {
+				if container.Name != status.Name {
+					continue
+				}
+
+				if digests[container] == nil {
+					digests[container] = map[string]struct{}{}
+				}
+
+				digests[container][digest] = struct{}{}
+			}
+		}
+	}
+
+	for container, set := range digests {
+		container.ImageDigests = make([]string, 0, len(set))
+		for digest := range set {
+			container.ImageDigests = append(container.ImageDigests, digest)
+		}
+
+		// NOTE: digests are sorted so entities versions don't change
+		// between scans
+		sort.Strings(container.ImageDigests)
+	}
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBindImagesDigests(t *testing.T) {
+	api := &Container{Entity: Entity{Name: "api"}}
+	sidecar := &Container{Entity: Entity{Name: "sidecar"}}
+
+	apps := []*Application{
+		{
+			Entity: Entity{Name: "default"},
+			Services: []*Service{
+				{
+					Entity:     Entity{Name: "api"},
+					PodRegexp:  regexp.MustCompile(`^api-[^-]+$`),
+					Containers: []*Container{api, sidecar},
+				},
+			},
+		},
+	}
+
+	pod := func(name, digest string) kv1.Pod {
+		return kv1.Pod{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default", Name: name},
+			Status: kv1.PodStatus{
+				ContainerStatuses: []kv1.ContainerStatus{
+					{Name: "api", ImageID: "docker-pullable://api@" + digest},
+					{Name: "sidecar", ImageID: ""},
+				},
+			},
+		}
+	}
+
+	bindImagesDigests(apps, []kv1.Pod{
+		pod("api-2", "sha256:two"),
+		pod("api-1", "sha256:one"),
+		pod("api-3", "sha256:two"),
+		pod("other-1", "sha256:three"),
+	})
+
+	expected := []string{"sha256:one", "sha256:two"}
+	if !reflect.DeepEqual(api.ImageDigests, expected) {
+		t.Errorf("expected digests %v, got %v", expected, api.ImageDigests)
+	}
+
+	if len(sidecar.ImageDigests) != 0 {
+		t.Errorf("expected no digests of sidecar, got %v", sidecar.ImageDigests)
+	}
+}
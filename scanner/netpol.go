@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	knetworkingv1 "k8s.io/api/networking/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// isSelectedByNetworkPolicy checks whether pods with the given labels in the
+// given namespace are selected by at least one of the network policies,
+// policies with invalid selectors are ignored
+func isSelectedByNetworkPolicy(
+	policies []knetworkingv1.NetworkPolicy,
+	namespace string,
+	podLabels map[string]string,
+) bool {
+	for index := range policies {
+		policy := &policies[index]
+		if policy.Namespace != namespace {
+			continue
+		}
+
+		selector, err := kmeta.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			continue
+		}
+
+		if selector.Matches(klabels.Set(podLabels)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package scanner
+
+import (
+	"testing"
+
+	knetworkingv1 "k8s.io/api/networking/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsSelectedByNetworkPolicy(t *testing.T) {
+	policies := []knetworkingv1.NetworkPolicy{
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "default", Name: "api"},
+			Spec: knetworkingv1.NetworkPolicySpec{
+				PodSelector: kmeta.LabelSelector{
+					MatchLabels: map[string]string{"app": "api"},
+				},
+			},
+		},
+		{
+			ObjectMeta: kmeta.ObjectMeta{Namespace: "locked", Name: "deny-all"},
+			Spec:       knetworkingv1.NetworkPolicySpec{},
+		},
+	}
+
+	testcases := []struct {
+		namespace string
+		labels    map[string]string
+		expected  bool
+	}{
+		{"default", map[string]string{"app": "api", "tier": "backend"}, true},
+		{"default", map[string]string{"app": "web"}, false},
+		{"default", nil, false},
+		{"locked", map[string]string{"app": "web"}, true},
+		{"other", map[string]string{"app": "api"}, false},
+	}
+
+	for _, testcase := range testcases {
+		selected := isSelectedByNetworkPolicy(
+			policies,
+			testcase.namespace,
+			testcase.labels,
+		)
+		if selected != testcase.expected {
+			t.Errorf(
+				"%s %v: expected %v, got %v",
+				testcase.namespace,
+				testcase.labels,
+				testcase.expected,
+				selected,
+			)
+		}
+	}
+}
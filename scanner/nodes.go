@@ -30,14 +30,21 @@ func PacketNodes(nodes []kuber.Node) proto.PacketNodesStoreRequest {
 		packet = append(
 			packet,
 			proto.PacketRegisterNodeItem{
-				ID:           node.ID,
-				Name:         node.Name,
-				IP:           node.IP,
-				Provider:     node.Provider,
-				Region:       node.Region,
-				InstanceType: node.InstanceType,
-				InstanceSize: node.InstanceSize,
-				Containers:   node.Containers,
+				ID:            node.ID,
+				Name:          node.Name,
+				IP:            node.IP,
+				Provider:      node.Provider,
+				OS:            node.OS,
+				Region:        node.Region,
+				InstanceType:  node.InstanceType,
+				InstanceSize:  node.InstanceSize,
+				Zone:          node.Zone,
+				Pool:          node.Pool,
+				Lifecycle:     node.Lifecycle,
+				OnDemandPrice: node.OnDemandPrice,
+				Labels:        node.Labels,
+				Taints:        packetNodeTaints(node.Taints),
+				Containers:    node.Containers,
 				Capacity: proto.PacketRegisterNodeCapacityItem(
 					node.Capacity,
 				),
@@ -51,6 +58,17 @@ func PacketNodes(nodes []kuber.Node) proto.PacketNodesStoreRequest {
 	return packet
 }
 
+func packetNodeTaints(taints []kuber.NodeTaint) []proto.PacketRegisterNodeTaintItem {
+	if taints == nil {
+		return nil
+	}
+	var res []proto.PacketRegisterNodeTaintItem
+	for _, taint := range taints {
+		res = append(res, proto.PacketRegisterNodeTaintItem(taint))
+	}
+	return res
+}
+
 func packetContainerList(containerList []*kuber.Container) []*proto.PacketRegisterNodeContainerListItem {
 	if containerList == nil {
 		return nil
@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	kv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// indexPodServices resolves services of pods by owner references, so pods
+// with custom names are attributed to their workloads
+func indexPodServices(
+	apps map[string]*Application,
+	pods []kv1.Pod,
+	owners kuber.OwnersIndex,
+) {
+	for _, app := range apps {
+		services := map[types.UID]*Service{}
+		for _, service := range app.Services {
+			if service.uid != "" {
+				services[service.uid] = service
+			}
+		}
+
+		app.podServices = map[string]*Service{}
+		for _, pod := range pods {
+			if pod.Namespace != app.Name {
+				continue
+			}
+
+			service, ok := services[owners.ResolvePod(pod)]
+			if ok {
+				app.podServices[pod.Name] = service
+			}
+		}
+	}
+}
+
+// OwnsPod checks whether the pod belongs to the service, pods are resolved
+// by owner references on scan, names of pods created after the scan or
+// owned by objects which aren't scanned, e.g. jobs, are matched by patterns
+// of services
+func (app *Application) OwnsPod(service *Service, podName string) bool {
+	if owner, ok := app.podServices[podName]; ok {
+		return owner == service
+	}
+
+	return service.PodRegexp.MatchString(podName)
+}
@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	kv1 "k8s.io/api/core/v1"
+	kmeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplicationOwnsPod(t *testing.T) {
+	controller := true
+
+	api := &Service{
+		Entity:    Entity{Name: "api"},
+		PodRegexp: regexp.MustCompile(`^api-[^-]+-[^-]+$`),
+		uid:       "api",
+	}
+	apiWorker := &Service{
+		Entity:    Entity{Name: "api-worker"},
+		PodRegexp: regexp.MustCompile(`^api-worker-[^-]+-[^-]+$`),
+		uid:       "api-worker",
+	}
+
+	app := &Application{
+		Entity:   Entity{Name: "default"},
+		Services: []*Service{api, apiWorker},
+	}
+
+	owners := kuber.OwnersIndex{}
+	pods := []kv1.Pod{
+		{
+			ObjectMeta: kmeta.ObjectMeta{
+				Namespace: "default",
+				// NOTE: the name matches the pattern of api
+				Name: "api-worker-custom",
+				OwnerReferences: []kmeta.OwnerReference{
+					{UID: "api-worker", Controller: &controller},
+				},
+			},
+		},
+	}
+
+	indexPodServices(map[string]*Application{"default": app}, pods, owners)
+
+	if app.OwnsPod(api, "api-worker-custom") {
+		t.Errorf("pod resolved by owners is attributed to api")
+	}
+
+	if !app.OwnsPod(apiWorker, "api-worker-custom") {
+		t.Errorf("pod resolved by owners is not attributed to api-worker")
+	}
+
+	if !app.OwnsPod(api, "api-5d8f7b-x2k4q") {
+		t.Errorf("unresolved pod is not matched by pattern")
+	}
+}
@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	kv1 "k8s.io/api/core/v1"
+)
+
+// getQOSClass returns the QoS class kubernetes assigns to pods of a service,
+// it is evaluated on effective resources of containers, i.e. after applying
+// LimitRange defaults, the same way it's done on pod admission
+func getQOSClass(containers []*Container) kv1.PodQOSClass {
+	if len(containers) == 0 {
+		return ""
+	}
+
+	names := []kv1.ResourceName{kv1.ResourceCPU, kv1.ResourceMemory}
+
+	bestEffort := true
+	guaranteed := true
+
+	for _, container := range containers {
+		if container.Resources == nil {
+			guaranteed = false
+			continue
+		}
+
+		resources := container.Resources.SpecResourceRequirements
+
+		for _, name := range names {
+			request, requestSet := resources.Requests[name]
+			limit, limitSet := resources.Limits[name]
+
+			if requestSet && !request.IsZero() || limitSet && !limit.IsZero() {
+				bestEffort = false
+			}
+
+			if !limitSet || limit.IsZero() {
+				guaranteed = false
+				continue
+			}
+
+			if requestSet && request.Cmp(limit) != 0 {
+				guaranteed = false
+			}
+		}
+	}
+
+	switch {
+	case bestEffort:
+		return kv1.PodQOSBestEffort
+	case guaranteed:
+		return kv1.PodQOSGuaranteed
+	default:
+		return kv1.PodQOSBurstable
+	}
+}
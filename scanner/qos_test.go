@@ -0,0 +1,87 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetQOSClass(t *testing.T) {
+	container := func(requests, limits kv1.ResourceList) *Container {
+		return &Container{
+			Resources: &proto.ContainerResourceRequirements{
+				SpecResourceRequirements: kv1.ResourceRequirements{
+					Requests: requests,
+					Limits:   limits,
+				},
+			},
+		}
+	}
+
+	resources := func(cpu, memory string) kv1.ResourceList {
+		list := kv1.ResourceList{}
+		if cpu != "" {
+			list[kv1.ResourceCPU] = kresource.MustParse(cpu)
+		}
+		if memory != "" {
+			list[kv1.ResourceMemory] = kresource.MustParse(memory)
+		}
+		return list
+	}
+
+	testcases := []struct {
+		name       string
+		containers []*Container
+		expected   kv1.PodQOSClass
+	}{
+		{
+			"no containers",
+			nil,
+			"",
+		},
+		{
+			"no resources",
+			[]*Container{container(nil, nil)},
+			kv1.PodQOSBestEffort,
+		},
+		{
+			"requests equal limits",
+			[]*Container{
+				container(resources("100m", "128Mi"), resources("100m", "128Mi")),
+				container(nil, resources("1", "1Gi")),
+			},
+			kv1.PodQOSGuaranteed,
+		},
+		{
+			"requests lower than limits",
+			[]*Container{
+				container(resources("100m", "128Mi"), resources("200m", "128Mi")),
+			},
+			kv1.PodQOSBurstable,
+		},
+		{
+			"missing memory limit",
+			[]*Container{
+				container(resources("100m", ""), resources("100m", "")),
+			},
+			kv1.PodQOSBurstable,
+		},
+		{
+			"one container without resources",
+			[]*Container{
+				container(resources("100m", "128Mi"), resources("100m", "128Mi")),
+				container(nil, nil),
+			},
+			kv1.PodQOSBurstable,
+		},
+	}
+
+	for _, testcase := range testcases {
+		qos := getQOSClass(testcase.containers)
+		if qos != testcase.expected {
+			t.Errorf("%s: expected %q, got %q", testcase.name, testcase.expected, qos)
+		}
+	}
+}
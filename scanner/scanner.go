@@ -1,17 +1,22 @@
 package scanner
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/cloud"
 	"github.com/MagalixCorp/magalix-agent/kuber"
 	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/replay"
 	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/MagalixCorp/magalix-agent/webhook"
 	"github.com/MagalixTechnologies/log-go"
 	"github.com/MagalixTechnologies/uuid-go"
 	"github.com/reconquest/karma-go"
 	kv1 "k8s.io/api/core/v1"
+	knetworkingv1 "k8s.io/api/networking/v1"
 	kresource "k8s.io/apimachinery/pkg/api/resource"
 )
 
@@ -24,30 +29,66 @@ const (
 type Scanner struct {
 	*utils.Ticker
 
-	client         *client.Client
+	client         Client
 	logger         *log.Logger
 	kube           *kuber.Kube
 	skipNamespaces []string
 	accountID      uuid.UUID
 	clusterID      uuid.UUID
 
+	// optionalKube kube of optional kinds with the low priority, nil if the
+	// scanner isn't started
+	optionalKube *kuber.Kube
+
+	environmentRules []EnvironmentRule
+
 	apps         []*Application
 	appsLastScan time.Time
 
 	pods []kv1.Pod
 
+	ephemeralContainers map[string]struct{}
+
 	nodes         []kuber.Node
 	nodesLastScan time.Time
 
 	history History
 	mutex   *sync.Mutex
 
+	deploys *deploysTracker
+
+	delta     *entitiesDelta
+	deletions *deletionsTracker
+
+	throttlingFactor int
+	skippedScans     int
+
+	budget *apiBudget
+
 	optInAnalysisData  bool
 	analysisDataSender func(args ...interface{})
 
+	// statePath file entities are persisted to after every scan
+	statePath string
+
 	dones []chan struct{}
 }
 
+// Options options of the scanner
+type Options struct {
+	SkipNamespaces         []string
+	AccountID              uuid.UUID
+	ClusterID              uuid.UUID
+	EnvironmentRules       []EnvironmentRule
+	OptInAnalysisData      bool
+	AnalysisDataInterval   time.Duration
+	EntitiesResyncInterval time.Duration
+	KubeAPIBudget          int
+	// EntitiesState file entities are persisted to after every scan, they
+	// are sent by Warmup on the next start
+	EntitiesState string
+}
+
 // InitScanner creates a new scanner then Start it
 func InitScanner(
 	client *client.Client,
@@ -55,27 +96,101 @@ func InitScanner(
 	skipNamespaces []string,
 	accountID uuid.UUID,
 	clusterID uuid.UUID,
+	environmentRules []EnvironmentRule,
 	optInAnalysisData bool,
 	analysisDataInterval time.Duration,
+	entitiesResyncInterval time.Duration,
+	kubeAPIBudget int,
+	entitiesState string,
+) *Scanner {
+	// NOTE: the api budget counts requests of the scanner only, kube is
+	// shared with metrics, events and other components
+	scannerKube, err := kube.WithUsage()
+	if err != nil {
+		client.Errorf(err, "unable to create kubernetes client of the scanner")
+	} else {
+		kube = scannerKube
+	}
+
+	scanner := NewScanner(client, client.Logger, kube, Options{
+		SkipNamespaces:         skipNamespaces,
+		AccountID:              accountID,
+		ClusterID:              clusterID,
+		EnvironmentRules:       environmentRules,
+		OptInAnalysisData:      optInAnalysisData,
+		AnalysisDataInterval:   analysisDataInterval,
+		EntitiesResyncInterval: entitiesResyncInterval,
+		KubeAPIBudget:          kubeAPIBudget,
+		EntitiesState:          entitiesState,
+	})
+
+	// NOTE: optional kinds are listed with the low priority, so they yield
+	// to lists of workloads while requests are rate limited
+	optionalKube, err := kube.WithPriority(kuber.PriorityLow)
+	if err != nil {
+		scanner.logger.Errorf(err, "unable to create kubernetes client of optional kinds")
+	} else {
+		scanner.optionalKube = optionalKube
+	}
+
+	err = scanner.Warmup()
+	if err != nil {
+		scanner.logger.Errorf(err, "unable to send persisted entities")
+	}
+
+	client.RegisterHealthCheck("scanner", scanner.getHealth)
+
+	scanner.Ticker = utils.NewTicker(scanner.logger, "scanner", intervalScanner, func(_ time.Time) {
+		scanner.scan()
+	})
+	// Note: we set immediate to true so that the scanner blocks for the first
+	// run. Other components depends on scanner having a history to function correctly.
+	// The other solution is to let the dependent components to wait for scanner
+	// ticks which will make code more coupled and complex.
+	scanner.Start(true, false, false)
+
+	client.AddShutdownHook("scanner", scanner.Stop)
+
+	return scanner
+}
+
+// NewScanner creates a new scanner which isn't started, the cluster is
+// scanned only by Scan calls
+func NewScanner(
+	client Client,
+	logger *log.Logger,
+	kube *kuber.Kube,
+	options Options,
 ) *Scanner {
 	scanner := &Scanner{
 		client:         client,
-		logger:         client.Logger,
+		logger:         logger,
 		kube:           kube,
-		skipNamespaces: skipNamespaces,
-		accountID:      accountID,
-		clusterID:      clusterID,
+		skipNamespaces: options.SkipNamespaces,
+		accountID:      options.AccountID,
+		clusterID:      options.ClusterID,
 		history:        NewHistory(),
+		deploys:        newDeploysTracker(deploysWindow),
+		delta:          newEntitiesDelta(options.EntitiesResyncInterval),
+		deletions:      newDeletionsTracker(),
+
+		throttlingFactor: 1,
+		budget:           newAPIBudget(int64(options.KubeAPIBudget)),
 
-		optInAnalysisData: optInAnalysisData,
+		environmentRules: options.EnvironmentRules,
+
+		optInAnalysisData: options.OptInAnalysisData,
+
+		statePath: options.EntitiesState,
 
 		mutex: &sync.Mutex{},
 		dones: make([]chan struct{}, 0),
 	}
-	if optInAnalysisData {
+	if options.OptInAnalysisData {
 		scanner.analysisDataSender = utils.Throttle(
+			scanner.logger,
 			"analysis-data",
-			analysisDataInterval,
+			options.AnalysisDataInterval,
 			2, // we call analysisDataSender twice in each tick
 			func(args ...interface{}) {
 				if data, ok := args[0].(map[string]interface{}); ok {
@@ -91,18 +206,50 @@ func InitScanner(
 		// noop function
 		scanner.analysisDataSender = func(args ...interface{}) {}
 	}
-	scanner.Ticker = utils.NewTicker("scanner", intervalScanner, func(_ time.Time) {
-		scanner.scan()
-	})
-	// Note: we set immediate to true so that the scanner blocks for the first
-	// run. Other components depends on scanner having a history to function correctly.
-	// The other solution is to let the dependent components to wait for scanner
-	// ticks which will make code more coupled and complex.
-	scanner.Start(true, false, false)
+
 	return scanner
 }
 
+// Scan scans nodes and applications once, requests are cancelled once the
+// context is done. Unlike scans of the started scanner, failed requests
+// aren't retried and entities aren't sent.
+func (scanner *Scanner) Scan(ctx context.Context) error {
+	kube, err := scanner.kube.WithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	nodes, _, err := scanner.getNodes(kube)
+	if err != nil {
+		return karma.Format(err, "unable to scan kubernetes nodes")
+	}
+
+	apps, _, err := scanner.getApplications(kube)
+	if err != nil {
+		return karma.Format(err, "unable to scan kubernetes applications")
+	}
+
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	now := time.Now().UTC()
+
+	scanner.nodes = nodes
+	scanner.nodesLastScan = now
+	scanner.apps = apps
+	scanner.appsLastScan = now
+
+	return nil
+}
+
 func (scanner *Scanner) scan() {
+	if scanner.shouldSkipScan() {
+		scanner.logger.Infof(nil, "kubernetes api is throttled or over budget, skipping scan")
+		return
+	}
+
+	started := scanner.kube.Usage.Requests()
+
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 	go func() {
@@ -114,16 +261,20 @@ func (scanner *Scanner) scan() {
 		wg.Done()
 	}()
 	wg.Wait()
+
+	scanner.persistEntities()
+
+	scanner.observeScanUsage(started)
 }
 
 func (scanner *Scanner) scanNodes() {
 	for {
 		scanner.logger.Infof(nil, "scanning kubernetes nodes")
 
-		nodes, nodeList, err := scanner.getNodes()
+		nodes, nodeList, err := scanner.getNodes(scanner.kube)
 		if err != nil {
 			scanner.logger.Errorf(err, "unable to scan kubernetes nodes")
-			time.Sleep(timeoutScannerBackoff)
+			time.Sleep(scanner.getScanBackoff())
 			continue
 		}
 
@@ -136,7 +287,7 @@ func (scanner *Scanner) scanNodes() {
 		scanner.nodes = nodes
 		scanner.nodesLastScan = time.Now().UTC()
 
-		scanner.SendNodes(nodes)
+		scanner.SendNodes(filterShardNodes(nodes))
 		scanner.SendAnalysisData(map[string]interface{}{
 			"nodes": nodeList,
 		})
@@ -149,19 +300,19 @@ func (scanner *Scanner) scanNodes() {
 	}
 }
 
-func (scanner *Scanner) getNodes() ([]kuber.Node, *kv1.NodeList, error) {
-	nodeList, err := scanner.kube.GetNodes()
+func (scanner *Scanner) getNodes(kube *kuber.Kube) ([]kuber.Node, *kv1.NodeList, error) {
+	nodeList, err := kube.GetNodes()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	podList, err := scanner.kube.GetPods()
+	podList, err := kube.GetPods()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	nodes := kuber.UpdateNodesContainers(
-		kuber.GetNodes(nodeList.Items),
+		cloud.EnrichNodes(kuber.GetNodes(nodeList.Items)),
 		kuber.GetContainersByNode(podList.Items),
 	)
 
@@ -188,10 +339,10 @@ func (scanner *Scanner) scanApplications() {
 	for {
 		scanner.logger.Infof(nil, "scanning kubernetes applications")
 
-		apps, rawResources, err := scanner.getApplications()
+		apps, rawResources, err := scanner.getApplications(scanner.kube)
 		if err != nil {
 			scanner.logger.Errorf(err, "unable to scan kubernetes applications")
-			time.Sleep(timeoutScannerBackoff)
+			time.Sleep(scanner.getScanBackoff())
 			continue
 		}
 
@@ -209,8 +360,19 @@ func (scanner *Scanner) scanApplications() {
 		scanner.apps = apps
 		scanner.appsLastScan = time.Now().UTC()
 
-		scanner.SendApplications(apps)
+		replay.Transition(replay.TransitionApplications, apps)
+		webhook.Publish(webhook.EventEntities, map[string]interface{}{
+			"applications": apps,
+			"nodes":        scanner.GetNodes(),
+		})
+
+		scanner.SendApplications(filterShardApplications(apps))
 		scanner.SendAnalysisData(rawResources)
+		scanner.handleDeletions(apps, scanner.GetPods())
+
+		if !scanner.isDegraded() {
+			scanner.scanVerticalPodAutoscalers(apps)
+		}
 
 		scanner.logger.Infof(
 			nil,
@@ -220,10 +382,10 @@ func (scanner *Scanner) scanApplications() {
 	}
 }
 
-func (scanner *Scanner) getApplications() (
+func (scanner *Scanner) getApplications(kube *kuber.Kube) (
 	[]*Application, map[string]interface{}, error,
 ) {
-	pods, limitRanges, resources, rawResources, err := scanner.kube.GetResources()
+	pods, limitRanges, resourceQuotas, resources, rawResources, owners, ephemeralContainers, err := kube.GetResources()
 	if err != nil {
 		return nil, nil, karma.Format(
 			err,
@@ -241,13 +403,39 @@ func (scanner *Scanner) getApplications() (
 	scanner.pods = pods
 	scanner.mutex.Unlock()
 
+	namespacesEnvironments, err := scanner.getNamespacesEnvironments(kube)
+	if err != nil {
+		return nil, nil, karma.Format(
+			err,
+			"can't classify namespaces environments",
+		)
+	}
+
+	// NOTE: network policies are optional, services are reported without
+	// the flag if they can't be listed, aren't served by the cluster or the
+	// api budget is exceeded
+	degraded := scanner.isDegraded()
+
+	var networkPolicies []knetworkingv1.NetworkPolicy
+	networkPoliciesScanned := false
+	if !degraded &&
+		kube.Capabilities.IsSupported(kuber.FeatureNetworkPolicies) {
+		networkPoliciesList, err := scanner.getOptionalKube(kube).GetNetworkPolicies()
+		if err != nil {
+			scanner.logger.Errorf(err, "unable to scan network policies")
+		} else if networkPoliciesList != nil {
+			networkPolicies = networkPoliciesList.Items
+		}
+		networkPoliciesScanned = err == nil
+	}
+
 	var apps []*Application
 
 	namespaces := map[string]*Application{}
 
 	for _, resource := range resources {
 		if utils.InSkipNamespace(scanner.skipNamespaces, resource.Namespace) {
-			scanner.client.Tracef(
+			scanner.logger.Tracef(
 				nil,
 				"skipping namespace %q: resource %q",
 				resource.Namespace,
@@ -263,12 +451,17 @@ func (scanner *Scanner) getApplications() (
 		if app, ok = namespaces[resource.Namespace]; !ok {
 			app = &Application{
 				Entity: Entity{
-					Name: resource.Namespace,
+					Name:        resource.Namespace,
+					Environment: namespacesEnvironments[resource.Namespace],
 				},
 				LimitRanges: getLimitRangesForNamespace(
 					limitRanges,
 					resource.Namespace,
 				),
+				ResourceQuotas: getResourceQuotasForNamespace(
+					resourceQuotas,
+					resource.Namespace,
+				),
 			}
 
 			namespaces[resource.Namespace] = app
@@ -278,15 +471,57 @@ func (scanner *Scanner) getApplications() (
 
 		defaultRequests, defaultLimits := getDefaultResources(app.LimitRanges)
 
+		environment := classifyEnvironment(
+			scanner.environmentRules,
+			resource.Labels,
+			resource.Annotations,
+		)
+		if environment == "" {
+			environment = app.Environment
+		}
+
 		service := &Service{
 			Entity: Entity{
 				Name:        resource.Name,
 				Kind:        resource.Kind,
 				Annotations: resource.Annotations,
+				Environment: environment,
 			},
 			ReplicasStatus: resource.ReplicasStatus,
 
 			PodRegexp: resource.PodRegexp,
+
+			AutomationDisabled: isAutomationDisabled(resource.Annotations),
+
+			PriorityClassName: resource.PriorityClassName,
+			RolloutStrategy:   resource.RolloutStrategy,
+
+			CreatedAt:       resource.CreatedAt,
+			ResourceVersion: resource.ResourceVersion,
+			templateHash:    resource.TemplateHash,
+			uid:             resource.UID,
+		}
+
+		slo, errs := parseSLO(resource.Kind, resource.Annotations)
+		for _, err := range errs {
+			scanner.logger.Warningf(
+				karma.
+					Describe("application", app.Name).
+					Describe("service", service.Name).
+					Reason(err),
+				"ignoring invalid slo annotation",
+			)
+		}
+
+		service.SLO = slo
+
+		if networkPoliciesScanned {
+			selected := isSelectedByNetworkPolicy(
+				networkPolicies,
+				resource.Namespace,
+				resource.PodLabels,
+			)
+			service.NetworkPolicySelected = &selected
 		}
 
 		// NOTE: we consider the default value is the neutral multiplier `1`
@@ -297,32 +532,48 @@ func (scanner *Scanner) getApplications() (
 			replicas = int64(*resource.ReplicasStatus.Current)
 		}
 
-		for _, container := range resource.Containers {
+		addContainer := func(container kv1.Container, init bool) {
 			resources := withDefaultResources(container.Resources, defaultRequests, defaultLimits)
 			resources.ResourceRequirements = applyReplicas(resources.SpecResourceRequirements, replicas)
 
 			service.Containers = append(service.Containers, &Container{
 				Entity: Entity{
-					Name: container.Name,
+					Name:        container.Name,
+					Environment: service.Environment,
 				},
 
 				Image:     container.Image,
+				ImageTag:  kuber.GetImageTag(container.Image),
 				Resources: resources,
+				Init:      init,
 			})
 
 			scanner.logger.Tracef(
 				karma.
 					Describe("application", app.Name).
-					Describe("service", service.Name),
+					Describe("service", service.Name).
+					Describe("init", init),
 				"found container %q %q",
 				container.Name,
 				container.Image,
 			)
 		}
 
+		for _, container := range resource.InitContainers {
+			addContainer(container, true)
+		}
+
+		for _, container := range resource.Containers {
+			addContainer(container, false)
+		}
+
+		service.QOSClass = getQOSClass(service.Containers)
+
 		app.Services = append(app.Services, service)
 	}
 
+	indexPodServices(namespaces, pods, owners)
+
 	err = identifyApplications(apps, scanner.clusterID)
 	if err != nil {
 		return nil, nil, karma.Format(
@@ -331,9 +582,57 @@ func (scanner *Scanner) getApplications() (
 		)
 	}
 
+	scanner.deploys.observe(apps, time.Now())
+
+	ephemeralContainersKeys := bindEphemeralContainers(
+		apps,
+		ephemeralContainers,
+		scanner.skipNamespaces,
+	)
+
+	scanner.mutex.Lock()
+	scanner.ephemeralContainers = ephemeralContainersKeys
+	scanner.mutex.Unlock()
+
+	bindImagesDigests(apps, pods)
+
 	return apps, rawResources, nil
 }
 
+// getOptionalKube returns kube of optional kinds of scans of the started
+// scanner, the given kube is returned otherwise
+func (scanner *Scanner) getOptionalKube(kube *kuber.Kube) *kuber.Kube {
+	if kube == scanner.kube && scanner.optionalKube != nil {
+		return scanner.optionalKube
+	}
+
+	return kube
+}
+
+// getNamespacesEnvironments classifies namespaces into environments by their
+// labels and annotations, it doesn't query namespaces if no rules specified
+func (scanner *Scanner) getNamespacesEnvironments(kube *kuber.Kube) (map[string]string, error) {
+	environments := map[string]string{}
+	if len(scanner.environmentRules) == 0 {
+		return environments, nil
+	}
+
+	namespaces, err := kube.GetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespace := range namespaces.Items {
+		environments[namespace.Name] = classifyEnvironment(
+			scanner.environmentRules,
+			namespace.Labels,
+			namespace.Annotations,
+		)
+	}
+
+	return environments, nil
+}
+
 // getLimitRangesForNamespace returns all LimitRanges for a specific namespace.
 func getLimitRangesForNamespace(
 	limitRanges []kv1.LimitRange,
@@ -350,6 +649,27 @@ func getLimitRangesForNamespace(
 	return ranges
 }
 
+// getResourceQuotasForNamespace returns all ResourceQuotas for a specific
+// namespace.
+func getResourceQuotasForNamespace(
+	resourceQuotas []kv1.ResourceQuota,
+	namespace string,
+) []kv1.ResourceQuota {
+	var quotas []kv1.ResourceQuota
+
+	for index, quota := range resourceQuotas {
+		if quota.GetNamespace() == namespace {
+			quotas = append(quotas, resourceQuotas[index])
+		}
+	}
+
+	return quotas
+}
+
+// withDefaultResources materializes effective resources of a container the
+// same way kubernetes does on admission: missing limits are taken from
+// LimitRange defaults, missing requests are taken from explicit limits, then
+// from LimitRange default requests, then from defaulted limits.
 func withDefaultResources(
 	resources kv1.ResourceRequirements,
 	defaultRequests kv1.ResourceList,
@@ -357,66 +677,46 @@ func withDefaultResources(
 ) *proto.ContainerResourceRequirements {
 	limitsKinds := proto.ResourcesRequirementsKind{}
 	requestsKinds := proto.ResourcesRequirementsKind{}
-	limits := resources.Limits
-	if limits == nil {
-		limits = kv1.ResourceList{}
-	}
-	requests := resources.Requests
-	if requests == nil {
-		requests = kv1.ResourceList{}
-	}
-	if !quantityHasValue(requests, kv1.ResourceCPU) {
-		if quantityHasValue(limits, kv1.ResourceCPU) {
-			limit := limits.Cpu()
-			requests[kv1.ResourceCPU] = *limit
-			requestsKinds[kv1.ResourceCPU] = proto.ResourceRequirementKindDefaultFromLimits
-		} else if quantityHasValue(defaultRequests, kv1.ResourceCPU) {
-			request := defaultRequests.Cpu()
-			requests[kv1.ResourceCPU] = *request
-			requestsKinds[kv1.ResourceCPU] = proto.ResourceRequirementKindDefaultsLimitRange
-		}
-	} else {
-		requestsKinds[kv1.ResourceCPU] = proto.ResourceRequirementKindSet
-	}
-	if !quantityHasValue(requests, kv1.ResourceMemory) {
-		if quantityHasValue(limits, kv1.ResourceMemory) {
-			limit := limits.Memory()
-			requests[kv1.ResourceMemory] = *limit
-			requestsKinds[kv1.ResourceMemory] = proto.ResourceRequirementKindDefaultFromLimits
-		} else if quantityHasValue(defaultRequests, kv1.ResourceMemory) {
-			request := defaultRequests.Memory()
-			requests[kv1.ResourceMemory] = *request
-			requestsKinds[kv1.ResourceMemory] = proto.ResourceRequirementKindDefaultsLimitRange
-		}
-	} else {
-		requestsKinds[kv1.ResourceMemory] = proto.ResourceRequirementKindSet
+	limits := kv1.ResourceList{}
+	for name, quantity := range resources.Limits {
+		limits[name] = quantity
+	}
+	requests := kv1.ResourceList{}
+	for name, quantity := range resources.Requests {
+		requests[name] = quantity
 	}
 
-	if !quantityHasValue(limits, kv1.ResourceCPU) {
-		if quantityHasValue(defaultLimits, kv1.ResourceCPU) {
-			limit := defaultLimits.Cpu()
-			limits[kv1.ResourceCPU] = *limit
-			limitsKinds[kv1.ResourceCPU] = proto.ResourceRequirementKindDefaultsLimitRange
+	for _, name := range []kv1.ResourceName{kv1.ResourceCPU, kv1.ResourceMemory} {
+		limitSet := quantityHasValue(limits, name)
+		if limitSet {
+			limitsKinds[name] = proto.ResourceRequirementKindSet
+		} else if quantityHasValue(defaultLimits, name) {
+			limits[name] = defaultLimits[name]
+			limitsKinds[name] = proto.ResourceRequirementKindDefaultsLimitRange
 		}
-	} else {
-		limitsKinds[kv1.ResourceCPU] = proto.ResourceRequirementKindSet
-	}
-	if !quantityHasValue(limits, kv1.ResourceMemory) {
-		if quantityHasValue(defaultLimits, kv1.ResourceMemory) {
-			limit := defaultLimits.Memory()
-			limits[kv1.ResourceMemory] = *limit
-			limitsKinds[kv1.ResourceMemory] = proto.ResourceRequirementKindDefaultsLimitRange
+
+		if quantityHasValue(requests, name) {
+			requestsKinds[name] = proto.ResourceRequirementKindSet
+		} else if limitSet {
+			requests[name] = limits[name]
+			requestsKinds[name] = proto.ResourceRequirementKindDefaultFromLimits
+		} else if quantityHasValue(defaultRequests, name) {
+			requests[name] = defaultRequests[name]
+			requestsKinds[name] = proto.ResourceRequirementKindDefaultsLimitRange
+		} else if quantityHasValue(limits, name) {
+			requests[name] = limits[name]
+			requestsKinds[name] = proto.ResourceRequirementKindDefaultsLimitRange
 		}
-	} else {
-		limitsKinds[kv1.ResourceMemory] = proto.ResourceRequirementKindSet
 	}
+
 	return &proto.ContainerResourceRequirements{
 		SpecResourceRequirements: kv1.ResourceRequirements{
 			Limits:   limits,
 			Requests: requests,
 		},
-		LimitsKinds:   limitsKinds,
-		RequestsKinds: requestsKinds,
+		DeclaredResourceRequirements: *resources.DeepCopy(),
+		LimitsKinds:                  limitsKinds,
+		RequestsKinds:                requestsKinds,
 	}
 }
 
@@ -463,13 +763,21 @@ func getDefaultResources(limitRanges []kv1.LimitRange) (kv1.ResourceList, kv1.Re
 		for _, limitItem := range limitRange.Spec.Limits {
 			if limitItem.Type == kv1.LimitTypeContainer {
 
+				// NOTE: kubernetes defaults LimitRange default requests
+				// to default limits
 				if quantityHasValue(limitItem.DefaultRequest, kv1.ResourceCPU) {
 					cpu := limitItem.DefaultRequest.Cpu()
 					defaultRequests[kv1.ResourceCPU] = *cpu
+				} else if quantityHasValue(limitItem.Default, kv1.ResourceCPU) {
+					cpu := limitItem.Default.Cpu()
+					defaultRequests[kv1.ResourceCPU] = *cpu
 				}
 				if quantityHasValue(limitItem.DefaultRequest, kv1.ResourceMemory) {
 					memory := limitItem.DefaultRequest.Memory()
 					defaultRequests[kv1.ResourceMemory] = *memory
+				} else if quantityHasValue(limitItem.Default, kv1.ResourceMemory) {
+					memory := limitItem.Default.Memory()
+					defaultRequests[kv1.ResourceMemory] = *memory
 				}
 
 				if quantityHasValue(limitItem.Default, kv1.ResourceCPU) {
@@ -508,7 +816,7 @@ func (scanner *Scanner) findContainer(
 		appID = app.ID
 
 		for _, service := range app.Services {
-			if !service.PodRegexp.MatchString(podName) {
+			if !app.OwnsPod(service, podName) {
 				continue
 			}
 
@@ -556,7 +864,7 @@ func (scanner *Scanner) findService(
 		appID = app.ID
 
 		for _, service := range app.Services {
-			if !service.PodRegexp.MatchString(podName) {
+			if !app.OwnsPod(service, podName) {
 				continue
 			}
 
@@ -573,6 +881,27 @@ func (scanner *Scanner) findService(
 	return appID, serviceID, found
 }
 
+// NewStaticScanner creates a scanner which doesn't scan the cluster, it
+// serves applications set by SetApplications, used to replay recordings
+func NewStaticScanner(logger *log.Logger) *Scanner {
+	return &Scanner{
+		logger:    logger,
+		history:   NewHistory(),
+		budget:    newAPIBudget(0),
+		deletions: newDeletionsTracker(),
+		mutex:     &sync.Mutex{},
+	}
+}
+
+// SetApplications replaces scanned applications
+func (scanner *Scanner) SetApplications(apps []*Application) {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	scanner.apps = apps
+	scanner.history = NewHistory()
+}
+
 // GetApplications get scanned applications
 func (scanner *Scanner) GetApplications() []*Application {
 	scanner.mutex.Lock()
@@ -682,6 +1011,40 @@ func (scanner *Scanner) FindContainer(
 	return appID, serviceID, container, found
 }
 
+// FindInitContainers returns init containers of the service of a pod
+func (scanner *Scanner) FindInitContainers(
+	namespace string,
+	podName string,
+) []*Container {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	containers := []*Container{}
+	for _, app := range scanner.apps {
+		if app.Name != namespace {
+			continue
+		}
+
+		for _, service := range app.Services {
+			if !app.OwnsPod(service, podName) {
+				continue
+			}
+
+			for _, container := range service.Containers {
+				if container.Init {
+					containers = append(containers, container)
+				}
+			}
+
+			break
+		}
+
+		break
+	}
+
+	return containers
+}
+
 // FindServiceByID returns namespace, name and kind of a service by service id
 func (scanner *Scanner) FindServiceByID(
 	apps []*Application,
@@ -759,7 +1122,7 @@ func (scanner *Scanner) FindContainerWithParents(
 		}
 
 		for _, service := range app.Services {
-			if !service.PodRegexp.MatchString(podName) {
+			if !app.OwnsPod(service, podName) {
 				continue
 			}
 
@@ -827,6 +1190,34 @@ func (scanner *Scanner) FindContainerByPodUIDContainerName(
 	return
 }
 
+// GetState returns counts of scanned entities for debugging
+func (scanner *Scanner) GetState() interface{} {
+	scanner.mutex.Lock()
+	defer scanner.mutex.Unlock()
+
+	services, containers := 0, 0
+	for _, app := range scanner.apps {
+		services += len(app.Services)
+		for _, service := range app.Services {
+			containers += len(service.Containers)
+		}
+	}
+
+	return map[string]interface{}{
+		"applications":         len(scanner.apps),
+		"services":             services,
+		"containers":           containers,
+		"ephemeral_containers": len(scanner.ephemeralContainers),
+		"pods":                 len(scanner.pods),
+		"nodes":                len(scanner.nodes),
+		"apps_last_scan":       scanner.appsLastScan,
+		"nodes_last_scan":      scanner.nodesLastScan,
+		"api_requests":         scanner.budget.requests,
+		"api_budget":           scanner.budget.limit,
+		"api_budget_factor":    scanner.budget.factor,
+	}
+}
+
 func (scanner *Scanner) NodesLastScanTime() time.Time {
 	return scanner.nodesLastScan
 }
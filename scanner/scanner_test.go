@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestWithDefaultResources(t *testing.T) {
+	defaultRequests := kv1.ResourceList{
+		kv1.ResourceCPU:    kresource.MustParse("100m"),
+		kv1.ResourceMemory: kresource.MustParse("128Mi"),
+	}
+	defaultLimits := kv1.ResourceList{
+		kv1.ResourceCPU:    kresource.MustParse("500m"),
+		kv1.ResourceMemory: kresource.MustParse("512Mi"),
+	}
+
+	tests := []struct {
+		name          string
+		resources     kv1.ResourceRequirements
+		wantRequests  kv1.ResourceList
+		wantLimits    kv1.ResourceList
+		wantCPUKind   string
+		wantLimitKind string
+	}{
+		{
+			name:         "nothing set",
+			resources:    kv1.ResourceRequirements{},
+			wantRequests: defaultRequests,
+			wantLimits:   defaultLimits,
+
+			wantCPUKind:   proto.ResourceRequirementKindDefaultsLimitRange,
+			wantLimitKind: proto.ResourceRequirementKindDefaultsLimitRange,
+		},
+		{
+			name: "only limits set",
+			resources: kv1.ResourceRequirements{
+				Limits: kv1.ResourceList{
+					kv1.ResourceCPU:    kresource.MustParse("1"),
+					kv1.ResourceMemory: kresource.MustParse("1Gi"),
+				},
+			},
+			wantRequests: kv1.ResourceList{
+				kv1.ResourceCPU:    kresource.MustParse("1"),
+				kv1.ResourceMemory: kresource.MustParse("1Gi"),
+			},
+			wantLimits: kv1.ResourceList{
+				kv1.ResourceCPU:    kresource.MustParse("1"),
+				kv1.ResourceMemory: kresource.MustParse("1Gi"),
+			},
+
+			wantCPUKind:   proto.ResourceRequirementKindDefaultFromLimits,
+			wantLimitKind: proto.ResourceRequirementKindSet,
+		},
+		{
+			name: "only requests set",
+			resources: kv1.ResourceRequirements{
+				Requests: kv1.ResourceList{
+					kv1.ResourceCPU:    kresource.MustParse("200m"),
+					kv1.ResourceMemory: kresource.MustParse("256Mi"),
+				},
+			},
+			wantRequests: kv1.ResourceList{
+				kv1.ResourceCPU:    kresource.MustParse("200m"),
+				kv1.ResourceMemory: kresource.MustParse("256Mi"),
+			},
+			wantLimits: defaultLimits,
+
+			wantCPUKind:   proto.ResourceRequirementKindSet,
+			wantLimitKind: proto.ResourceRequirementKindDefaultsLimitRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withDefaultResources(tt.resources, defaultRequests, defaultLimits)
+
+			for name, want := range tt.wantRequests {
+				value := got.SpecResourceRequirements.Requests[name]
+				if value.Cmp(want) != 0 {
+					t.Errorf("request %s = %s, want %s", name, value.String(), want.String())
+				}
+			}
+			for name, want := range tt.wantLimits {
+				value := got.SpecResourceRequirements.Limits[name]
+				if value.Cmp(want) != 0 {
+					t.Errorf("limit %s = %s, want %s", name, value.String(), want.String())
+				}
+			}
+
+			declared := got.DeclaredResourceRequirements
+			if len(declared.Requests) != len(tt.resources.Requests) ||
+				len(declared.Limits) != len(tt.resources.Limits) {
+				t.Errorf("declared resources = %v, want %v", declared, tt.resources)
+			}
+
+			if kind := got.RequestsKinds[kv1.ResourceCPU]; kind != tt.wantCPUKind {
+				t.Errorf("cpu request kind = %s, want %s", kind, tt.wantCPUKind)
+			}
+			if kind := got.LimitsKinds[kv1.ResourceCPU]; kind != tt.wantLimitKind {
+				t.Errorf("cpu limit kind = %s, want %s", kind, tt.wantLimitKind)
+			}
+		})
+	}
+}
@@ -1,20 +1,49 @@
 package scanner
 
 import (
+	"time"
+
 	"github.com/MagalixCorp/magalix-agent/client"
 	"github.com/MagalixCorp/magalix-agent/kuber"
 	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
 )
 
-// SendApplications sends scanned applications
+// SendApplications sends scanned applications, only changed entities are
+// sent between full resyncs if the gateway supports deltas
 func (scanner *Scanner) SendApplications(applications []*Application) {
+	snapshot := PacketApplications(applications)
+
+	if scanner.client.IsPacketKindSupported(proto.PacketKindApplicationsDeltaRequest) {
+		delta := scanner.delta.next(snapshot, time.Now().UTC())
+		if delta != nil {
+			if isDeltaEmpty(delta) {
+				scanner.logger.Debugf(nil, "applications are not changed")
+				return
+			}
+
+			scanner.client.Pipe(client.Package{
+				Kind:        proto.PacketKindApplicationsDeltaRequest,
+				ExpiryTime:  nil,
+				ExpiryCount: 0,
+				Priority:    2,
+				Retries:     10,
+				Data:        delta,
+			})
+
+			return
+		}
+	} else {
+		scanner.delta.reset()
+	}
+
 	scanner.client.Pipe(client.Package{
 		Kind:        proto.PacketKindApplicationsStoreRequest,
 		ExpiryTime:  nil,
 		ExpiryCount: 1,
 		Priority:    2,
 		Retries:     10,
-		Data:        PacketApplications(applications),
+		Data:        snapshot,
 	})
 }
 
@@ -30,7 +59,95 @@ func (scanner *Scanner) SendNodes(nodes []kuber.Node) {
 	})
 }
 
+// SendNamespacesSummary sends namespaces requested and used resources
+func (scanner *Scanner) SendNamespacesSummary(
+	packet proto.PacketNamespacesSummaryStoreRequest,
+) {
+	scanner.client.Pipe(client.Package{
+		Kind:        proto.PacketKindNamespacesSummaryStoreRequest,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    5,
+		Retries:     10,
+		Data:        packet,
+	})
+}
+
+// SendVerticalPodAutoscalers sends vertical pod autoscalers recommendations
+func (scanner *Scanner) SendVerticalPodAutoscalers(
+	packet proto.PacketVPARecommendationsStoreRequest,
+) {
+	scanner.client.Pipe(client.Package{
+		Kind:        proto.PacketKindVPARecommendationsStoreRequest,
+		ExpiryTime:  nil,
+		ExpiryCount: 1,
+		Priority:    3,
+		Retries:     10,
+		Data:        packet,
+	})
+}
+
 // SendAnalysisData sends analysis data if the user opts in
 func (scanner *Scanner) SendAnalysisData(data map[string]interface{}) {
 	scanner.analysisDataSender(data)
 }
+
+// SendThrottling sends throttling state of the api-server
+func (scanner *Scanner) SendThrottling(state kuber.ThrottlingState) {
+	scanner.client.Pipe(client.Package{
+		Kind:        proto.PacketKindKubernetesThrottling,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    3,
+		Retries:     10,
+		Data: proto.PacketKubernetesThrottling{
+			Timestamp: time.Now().UTC(),
+			Throttled: state.Throttled,
+			Factor:    state.Factor,
+			Responses: state.Responses,
+			Since:     state.Since,
+		},
+	})
+}
+
+// SendCapabilities sends detected version and features of the cluster
+func (scanner *Scanner) SendCapabilities() {
+	capabilities := scanner.kube.Capabilities
+	if capabilities == nil {
+		return
+	}
+
+	scanner.client.Pipe(client.Package{
+		Kind:        proto.PacketKindKubernetesCapabilities,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    3,
+		Retries:     10,
+		Data: proto.PacketKubernetesCapabilities{
+			Timestamp: time.Now().UTC(),
+			Version:   capabilities.Version,
+			Major:     capabilities.Major,
+			Minor:     capabilities.Minor,
+			Platform:  capabilities.Platform,
+			Features:  capabilities.GetFeatures(),
+		},
+	})
+}
+
+// SendDeletion sends tombstones of deleted entities to the gateway
+func (scanner *Scanner) SendDeletion(deletion Deletion) {
+	scanner.client.Pipe(client.Package{
+		Kind:        proto.PacketKindEntitiesDeleted,
+		ExpiryTime:  utils.After(time.Hour),
+		ExpiryCount: 10,
+		Priority:    2,
+		Retries:     10,
+		Data: proto.PacketEntitiesDeleted{
+			Timestamp:    time.Now().UTC(),
+			Applications: deletion.Applications,
+			Services:     deletion.Services,
+			Containers:   deletion.Containers,
+			Pods:         deletion.Pods,
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/shard"
+)
+
+// GetShardApplications get scanned applications of namespaces handled by the
+// shard of the agent, all applications are still scanned to identify
+// containers of pods on nodes of the shard
+func (scanner *Scanner) GetShardApplications() []*Application {
+	return filterShardApplications(scanner.GetApplications())
+}
+
+// GetShardNodes get scanned nodes handled by the shard of the agent
+func (scanner *Scanner) GetShardNodes() []kuber.Node {
+	return filterShardNodes(scanner.GetNodes())
+}
+
+func filterShardApplications(apps []*Application) []*Application {
+	if !shard.IsSharded() {
+		return apps
+	}
+
+	filtered := []*Application{}
+	for _, app := range apps {
+		if shard.OwnsNamespace(app.Name) {
+			filtered = append(filtered, app)
+		}
+	}
+
+	return filtered
+}
+
+func filterShardNodes(nodes []kuber.Node) []kuber.Node {
+	if !shard.IsSharded() {
+		return nodes
+	}
+
+	filtered := []kuber.Node{}
+	for _, node := range nodes {
+		if shard.OwnsNode(node.Name) {
+			filtered = append(filtered, node)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// AnnotationSLOLatency target latency of the workload, e.g. 200ms
+	AnnotationSLOLatency = "magalix.com/slo-latency"
+	// AnnotationSLOAvailability target availability in percents, e.g. 99.9
+	AnnotationSLOAvailability = "magalix.com/slo-availability"
+	// AnnotationSLOClass class of the workload: critical, standard or batch
+	AnnotationSLOClass = "magalix.com/slo-class"
+
+	SLOClassCritical = "critical"
+	SLOClassStandard = "standard"
+	SLOClassBatch    = "batch"
+)
+
+// SLO service level objectives of a workload
+type SLO struct {
+	Latency      *time.Duration
+	Availability *float64
+	Class        string
+}
+
+// parseSLO parses SLO annotations of a workload, jobs are considered batch
+// workloads unless a class is specified. Invalid annotations are ignored and
+// returned as errors.
+func parseSLO(kind string, annotations map[string]string) (*SLO, []error) {
+	var (
+		slo  = &SLO{}
+		errs []error
+	)
+
+	if value, ok := annotations[AnnotationSLOLatency]; ok {
+		latency, err := time.ParseDuration(strings.TrimSpace(value))
+		if err == nil && latency <= 0 {
+			err = karma.Format(nil, "latency should be positive")
+		}
+
+		if err != nil {
+			errs = append(errs, karma.Describe("annotation", AnnotationSLOLatency).
+				Describe("value", value).
+				Format(err, "invalid slo latency"))
+		} else {
+			slo.Latency = &latency
+		}
+	}
+
+	if value, ok := annotations[AnnotationSLOAvailability]; ok {
+		availability, err := strconv.ParseFloat(
+			strings.TrimSuffix(strings.TrimSpace(value), "%"),
+			64,
+		)
+		if err == nil && (availability <= 0 || availability > 100) {
+			err = karma.Format(nil, "availability should be in (0, 100]")
+		}
+
+		if err != nil {
+			errs = append(errs, karma.Describe("annotation", AnnotationSLOAvailability).
+				Describe("value", value).
+				Format(err, "invalid slo availability"))
+		} else {
+			slo.Availability = &availability
+		}
+	}
+
+	if value, ok := annotations[AnnotationSLOClass]; ok {
+		class := strings.ToLower(strings.TrimSpace(value))
+		switch class {
+		case SLOClassCritical, SLOClassStandard, SLOClassBatch:
+			slo.Class = class
+		default:
+			errs = append(errs, karma.Describe("annotation", AnnotationSLOClass).
+				Describe("value", value).
+				Format(nil, "invalid slo class"))
+		}
+	}
+
+	if slo.Class == "" {
+		switch strings.ToLower(kind) {
+		case "cronjob", "job":
+			slo.Class = SLOClassBatch
+		}
+	}
+
+	if slo.Latency == nil && slo.Availability == nil && slo.Class == "" {
+		return nil, errs
+	}
+
+	return slo, errs
+}
@@ -0,0 +1,42 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSLO(t *testing.T) {
+	slo, errs := parseSLO("Deployment", map[string]string{
+		AnnotationSLOLatency:      "200ms",
+		AnnotationSLOAvailability: "99.9%",
+		AnnotationSLOClass:        "Critical",
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if slo.Latency == nil || *slo.Latency != 200*time.Millisecond {
+		t.Errorf("latency = %v, want 200ms", slo.Latency)
+	}
+	if slo.Availability == nil || *slo.Availability != 99.9 {
+		t.Errorf("availability = %v, want 99.9", slo.Availability)
+	}
+	if slo.Class != SLOClassCritical {
+		t.Errorf("class = %q, want %q", slo.Class, SLOClassCritical)
+	}
+
+	slo, errs = parseSLO("CronJob", map[string]string{
+		AnnotationSLOLatency:      "fast",
+		AnnotationSLOAvailability: "101",
+	})
+	if len(errs) != 2 {
+		t.Errorf("errors = %d, want 2", len(errs))
+	}
+	if slo == nil || slo.Class != SLOClassBatch {
+		t.Errorf("slo = %+v, want batch class", slo)
+	}
+
+	if slo, _ := parseSLO("Deployment", nil); slo != nil {
+		t.Errorf("slo without annotations = %+v, want nil", slo)
+	}
+}
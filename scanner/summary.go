@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	kv1 "k8s.io/api/core/v1"
+)
+
+// StartNamespacesSummary periodically sends requested vs used resources of
+// namespaces, it doesn't depend on metrics sources so it works even if
+// metrics are disabled
+func (scanner *Scanner) StartNamespacesSummary(interval time.Duration) {
+	ticker := utils.NewTicker(scanner.logger, "namespaces-summary", interval, func(tickTime time.Time) {
+		scanner.sendNamespacesSummary(tickTime)
+	})
+
+	ticker.Start(false, false, false)
+}
+
+func (scanner *Scanner) sendNamespacesSummary(tickTime time.Time) {
+	podsMetrics, err := scanner.kube.GetPodsMetrics()
+	if err != nil {
+		scanner.logger.Errorf(err, "unable to get pods metrics")
+		return
+	}
+
+	if podsMetrics == nil {
+		scanner.logger.Warningf(
+			nil,
+			"metrics api isn't available, skipping namespaces summary",
+		)
+		return
+	}
+
+	packet := proto.PacketNamespacesSummaryStoreRequest{
+		Timestamp: tickTime,
+		Namespaces: PacketNamespacesSummary(
+			scanner.GetShardApplications(),
+			podsMetrics,
+			scanner.skipNamespaces,
+		),
+	}
+
+	scanner.logger.Infof(
+		nil,
+		"sending summary of %d namespaces",
+		len(packet.Namespaces),
+	)
+
+	scanner.SendNamespacesSummary(packet)
+}
+
+// PacketNamespacesSummary sums requested resources of scanned containers
+// and current usage of pods per namespace
+func PacketNamespacesSummary(
+	apps []*Application,
+	podsMetrics []kuber.PodMetrics,
+	skipNamespaces []string,
+) []proto.PacketNamespaceSummaryItem {
+	used := map[string]*proto.PacketNamespaceSummaryItem{}
+	for _, pod := range podsMetrics {
+		namespace := pod.Metadata.Namespace
+
+		item, ok := used[namespace]
+		if !ok {
+			item = &proto.PacketNamespaceSummaryItem{}
+			used[namespace] = item
+		}
+
+		for _, container := range pod.Containers {
+			item.UsedCPU += getQuantityValue(container.Usage, kv1.ResourceCPU)
+			item.UsedMemory += getQuantityValue(container.Usage, kv1.ResourceMemory)
+		}
+	}
+
+	items := []proto.PacketNamespaceSummaryItem{}
+	for _, app := range apps {
+		if utils.InSkipNamespace(skipNamespaces, app.Name) {
+			continue
+		}
+
+		item := proto.PacketNamespaceSummaryItem{
+			ApplicationID: app.ID,
+			Namespace:     app.Name,
+		}
+
+		for _, service := range app.Services {
+			for _, container := range service.Containers {
+				if container.Resources == nil {
+					continue
+				}
+
+				// NOTE: requests are already multiplied by replicas
+				requests := container.Resources.Requests
+				item.RequestedCPU += getQuantityValue(requests, kv1.ResourceCPU)
+				item.RequestedMemory += getQuantityValue(requests, kv1.ResourceMemory)
+			}
+		}
+
+		if usage, ok := used[app.Name]; ok {
+			item.UsedCPU = usage.UsedCPU
+			item.UsedMemory = usage.UsedMemory
+		}
+
+		item.WastedCPU = max64(item.RequestedCPU-item.UsedCPU, 0)
+		item.WastedMemory = max64(item.RequestedMemory-item.UsedMemory, 0)
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// getQuantityValue returns cpu in millicores and other resources in units
+func getQuantityValue(resources kv1.ResourceList, name kv1.ResourceName) int64 {
+	quantity, ok := resources[name]
+	if !ok {
+		return 0
+	}
+
+	if name == kv1.ResourceCPU {
+		return quantity.MilliValue()
+	}
+
+	return quantity.Value()
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
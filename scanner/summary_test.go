@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	kv1 "k8s.io/api/core/v1"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPacketNamespacesSummary(t *testing.T) {
+	apps := []*Application{
+		{
+			Entity: Entity{Name: "default"},
+			Services: []*Service{
+				{
+					Containers: []*Container{
+						{
+							Resources: &proto.ContainerResourceRequirements{
+								ResourceRequirements: kv1.ResourceRequirements{
+									Requests: kv1.ResourceList{
+										kv1.ResourceCPU:    kresource.MustParse("1"),
+										kv1.ResourceMemory: kresource.MustParse("1Gi"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Entity: Entity{Name: "kube-system"},
+		},
+	}
+
+	pod := kuber.PodMetrics{}
+	pod.Metadata.Namespace = "default"
+	pod.Containers = append(pod.Containers, struct {
+		Name  string           `json:"name"`
+		Usage kv1.ResourceList `json:"usage"`
+	}{
+		Name: "app",
+		Usage: kv1.ResourceList{
+			kv1.ResourceCPU:    kresource.MustParse("250m"),
+			kv1.ResourceMemory: kresource.MustParse("2Gi"),
+		},
+	})
+
+	items := PacketNamespacesSummary(apps, []kuber.PodMetrics{pod}, []string{"kube-*"})
+	if len(items) != 1 {
+		t.Fatalf("namespaces = %d, want 1", len(items))
+	}
+
+	item := items[0]
+	if item.RequestedCPU != 1000 || item.UsedCPU != 250 || item.WastedCPU != 750 {
+		t.Errorf(
+			"cpu requested/used/wasted = %d/%d/%d, want 1000/250/750",
+			item.RequestedCPU, item.UsedCPU, item.WastedCPU,
+		)
+	}
+
+	if item.WastedMemory != 0 {
+		t.Errorf("wasted memory = %d, want 0 when usage exceeds requests", item.WastedMemory)
+	}
+}
@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+// shouldSkipScan checks whether the current scan should be skipped, while
+// the api-server is throttling requests or the api budget is exceeded only
+// every n-th scan is done where n is the interval factor
+func (scanner *Scanner) shouldSkipScan() bool {
+	state := scanner.kube.Throttling.GetState()
+
+	if state.Factor != scanner.throttlingFactor {
+		scanner.logger.Warningf(
+			karma.
+				Describe("factor", state.Factor).
+				Describe("responses", state.Responses).
+				Describe("since", state.Since),
+			"kubernetes api throttling changed",
+		)
+
+		scanner.throttlingFactor = state.Factor
+		scanner.SendThrottling(state)
+	}
+
+	factor := scanner.getIntervalFactor()
+	if factor == 1 {
+		scanner.skippedScans = 0
+		return false
+	}
+
+	if scanner.skippedScans+1 < factor {
+		scanner.skippedScans++
+		return true
+	}
+
+	scanner.skippedScans = 0
+	return false
+}
+
+// getScanBackoff returns the delay before retrying a failed scan, it is
+// lengthened while the api-server is throttling requests
+func (scanner *Scanner) getScanBackoff() time.Duration {
+	factor := scanner.kube.Throttling.GetState().Factor
+	return timeoutScannerBackoff * time.Duration(factor)
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"github.com/MagalixCorp/magalix-agent/kuber"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	kv1 "k8s.io/api/core/v1"
+)
+
+func (scanner *Scanner) scanVerticalPodAutoscalers(apps []*Application) {
+	scanner.logger.Infof(nil, "scanning vertical pod autoscalers")
+
+	vpas, err := scanner.kube.GetVerticalPodAutoscalers()
+	if err != nil {
+		scanner.logger.Errorf(err, "unable to scan vertical pod autoscalers")
+		return
+	}
+
+	if len(vpas) == 0 {
+		scanner.logger.Debugf(nil, "no vertical pod autoscalers found")
+		return
+	}
+
+	packet := PacketVerticalPodAutoscalers(
+		vpas,
+		apps,
+		scanner.skipNamespaces,
+	)
+
+	scanner.logger.Infof(
+		nil,
+		"found %d vertical pod autoscalers recommendations, sending to the gateway",
+		len(packet),
+	)
+
+	scanner.SendVerticalPodAutoscalers(packet)
+}
+
+// PacketVerticalPodAutoscalers converts VPA objects to the packet, binding
+// targets to scanned services and containers
+func PacketVerticalPodAutoscalers(
+	vpas []kuber.VerticalPodAutoscaler,
+	apps []*Application,
+	skipNamespaces []string,
+) proto.PacketVPARecommendationsStoreRequest {
+	packet := proto.PacketVPARecommendationsStoreRequest{}
+
+	for _, vpa := range vpas {
+		if utils.InSkipNamespace(skipNamespaces, vpa.Metadata.Namespace) {
+			continue
+		}
+
+		if vpa.Status.Recommendation == nil {
+			continue
+		}
+
+		service := findServiceByName(
+			apps,
+			vpa.Metadata.Namespace,
+			vpa.Spec.TargetRef.Kind,
+			vpa.Spec.TargetRef.Name,
+		)
+		if service == nil {
+			continue
+		}
+
+		item := proto.PacketVPARecommendationItem{
+			Name:       vpa.Metadata.Name,
+			Namespace:  vpa.Metadata.Namespace,
+			TargetKind: vpa.Spec.TargetRef.Kind,
+			TargetName: vpa.Spec.TargetRef.Name,
+			ServiceID:  service.ID,
+		}
+
+		for _, recommendation := range vpa.Status.Recommendation.ContainerRecommendations {
+			containerItem := proto.PacketVPAContainerRecommendationItem{
+				ContainerName:  recommendation.ContainerName,
+				Target:         packetVPAResources(recommendation.Target),
+				LowerBound:     packetVPAResources(recommendation.LowerBound),
+				UpperBound:     packetVPAResources(recommendation.UpperBound),
+				UncappedTarget: packetVPAResources(recommendation.UncappedTarget),
+			}
+
+			for _, container := range service.Containers {
+				if container.Name == recommendation.ContainerName {
+					id := container.ID
+					containerItem.ContainerID = &id
+					break
+				}
+			}
+
+			item.Containers = append(item.Containers, containerItem)
+		}
+
+		packet = append(packet, item)
+	}
+
+	return packet
+}
+
+func packetVPAResources(resources kv1.ResourceList) proto.VPAResources {
+	result := proto.VPAResources{}
+
+	if cpu, ok := resources[kv1.ResourceCPU]; ok {
+		value := cpu.MilliValue()
+		result.CPU = &value
+	}
+
+	if memory, ok := resources[kv1.ResourceMemory]; ok {
+		value := memory.Value()
+		result.Memory = &value
+	}
+
+	return result
+}
+
+// findServiceByName finds a scanned service by its namespace, kind and name
+func findServiceByName(
+	apps []*Application,
+	namespace string,
+	kind string,
+	name string,
+) *Service {
+	for _, app := range apps {
+		if app.Name != namespace {
+			continue
+		}
+
+		for _, service := range app.Services {
+			if service.Kind == kind && service.Name == name {
+				return service
+			}
+		}
+
+		break
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/client"
+	"github.com/MagalixCorp/magalix-agent/proto"
+	"github.com/MagalixCorp/magalix-agent/utils"
+	"github.com/reconquest/karma-go"
+)
+
+// entitiesStateMaxAge persisted entities older than the age are not sent,
+// they likely don't describe the cluster anymore
+const entitiesStateMaxAge = time.Hour * 24
+
+// entitiesState entities sent by the last scan
+type entitiesState struct {
+	Timestamp    time.Time                            `json:"timestamp"`
+	Applications proto.PacketApplicationsStoreRequest `json:"applications"`
+	Nodes        proto.PacketNodesStoreRequest        `json:"nodes"`
+}
+
+// loadEntitiesState reads entities persisted by a previous run, nil is
+// returned if the file is missing or too old
+func loadEntitiesState(path string, now time.Time) (*entitiesState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, karma.Format(
+			err,
+			"unable to read entities state file %s",
+			path,
+		)
+	}
+
+	var state entitiesState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode entities state file %s",
+			path,
+		)
+	}
+
+	if now.Sub(state.Timestamp) > entitiesStateMaxAge {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+// saveEntitiesState persists scanned entities to the state file
+func saveEntitiesState(path string, state entitiesState) error {
+	return utils.WriteStateFile(path, state, "entities")
+}
+
+// Warmup sends entities persisted by a previous run marked as stale, so the
+// cluster state is reported before the first scan completes
+func (scanner *Scanner) Warmup() error {
+	if scanner.statePath == "" {
+		return nil
+	}
+
+	if !scanner.client.IsPacketKindSupported(proto.PacketKindEntitiesWarmup) {
+		return nil
+	}
+
+	state, err := loadEntitiesState(scanner.statePath, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if state == nil {
+		return nil
+	}
+
+	scanner.logger.Infof(
+		karma.Describe("scanned-at", state.Timestamp),
+		"sending %d persisted applications and %d nodes",
+		len(state.Applications),
+		len(state.Nodes),
+	)
+
+	// NOTE: warmup is useless once the first scan is sent, so it's not kept
+	// in the queue longer than a scan interval
+	scanner.client.Pipe(client.Package{
+		Kind:        proto.PacketKindEntitiesWarmup,
+		ExpiryTime:  utils.After(intervalScanner),
+		ExpiryCount: 1,
+		Priority:    1,
+		Retries:     10,
+		Data: proto.PacketEntitiesWarmup{
+			Timestamp:    state.Timestamp,
+			Stale:        true,
+			Applications: state.Applications,
+			Nodes:        state.Nodes,
+		},
+	})
+
+	return nil
+}
+
+// persistEntities saves entities of the last scan, so they are sent on the
+// next start
+func (scanner *Scanner) persistEntities() {
+	if scanner.statePath == "" {
+		return
+	}
+
+	scanner.mutex.Lock()
+	state := entitiesState{
+		Timestamp:    scanner.appsLastScan,
+		Applications: PacketApplications(filterShardApplications(scanner.apps)),
+		Nodes:        PacketNodes(filterShardNodes(scanner.nodes)),
+	}
+	scanner.mutex.Unlock()
+
+	err := saveEntitiesState(scanner.statePath, state)
+	if err != nil {
+		scanner.logger.Errorf(err, "unable to persist entities")
+	}
+}
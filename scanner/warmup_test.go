@@ -0,0 +1,61 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/proto"
+)
+
+func TestEntitiesState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "entities-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	state, err := loadEntitiesState(path, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != nil {
+		t.Fatalf("expected no state, got %v", state)
+	}
+
+	application := proto.PacketRegisterApplicationItem{}
+	application.Name = "default"
+
+	err = saveEntitiesState(path, entitiesState{
+		Timestamp:    now.Add(-time.Minute),
+		Applications: proto.PacketApplicationsStoreRequest{application},
+		Nodes:        proto.PacketNodesStoreRequest{{Name: "node-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = loadEntitiesState(path, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state == nil ||
+		len(state.Applications) != 1 || state.Applications[0].Name != "default" ||
+		len(state.Nodes) != 1 || state.Nodes[0].Name != "node-1" {
+		t.Fatalf("unexpected restored state %v", state)
+	}
+
+	state, err = loadEntitiesState(path, now.Add(entitiesStateMaxAge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != nil {
+		t.Fatalf("expected too old state to be dropped, got %v", state)
+	}
+}
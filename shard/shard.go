@@ -0,0 +1,80 @@
+package shard
+
+import (
+	"hash/fnv"
+	"strconv"
+)
+
+// Shard part of the cluster handled by a replica of the agent, nodes and
+// namespaces are distributed across shards by rendezvous hashing of their
+// names, so every replica computes the same distribution and changing the
+// total only moves names of added or removed shards
+type Shard struct {
+	Index int `json:"index"`
+	Total int `json:"total"`
+}
+
+var current = Shard{Index: 0, Total: 1}
+
+// SetShard sets the shard of the agent, the agent handles the whole cluster
+// unless a shard is set
+func SetShard(value Shard) {
+	current = value
+}
+
+// GetShard returns the shard of the agent
+func GetShard() Shard {
+	return current
+}
+
+// IsSharded checks whether the cluster is partitioned across replicas
+func IsSharded() bool {
+	return current.Total > 1
+}
+
+// IsPrimary checks whether the agent handles cluster-wide work such as
+// decisions, events and deletions, which is done by the first shard only
+func IsPrimary() bool {
+	return current.Index == 0
+}
+
+// OwnsNode checks whether the node is handled by the shard of the agent
+func OwnsNode(name string) bool {
+	return current.Owns("node/" + name)
+}
+
+// OwnsNamespace checks whether the namespace is handled by the shard of the
+// agent
+func OwnsNamespace(name string) bool {
+	return current.Owns("namespace/" + name)
+}
+
+// Owns checks whether the key is handled by the shard
+func (shard Shard) Owns(key string) bool {
+	if shard.Total <= 1 {
+		return true
+	}
+
+	return getOwner(key, shard.Total) == shard.Index
+}
+
+// getOwner returns index of the shard with the highest weight of the key
+func getOwner(key string, total int) int {
+	var (
+		owner  int
+		weight uint64
+	)
+
+	for index := 0; index < total; index++ {
+		hash := fnv.New64a()
+		hash.Write([]byte(strconv.Itoa(index) + "/" + key))
+
+		value := hash.Sum64()
+		if index == 0 || value > weight {
+			owner = index
+			weight = value
+		}
+	}
+
+	return owner
+}
@@ -0,0 +1,61 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardOwnsKeyOnce(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("node-%d", i)
+
+		owners := 0
+		for index := 0; index < 3; index++ {
+			if (Shard{Index: index, Total: 3}).Owns(key) {
+				owners++
+			}
+		}
+
+		if owners != 1 {
+			t.Errorf("key %q is owned by %d shards", key, owners)
+		}
+	}
+}
+
+func TestShardKeepsOwnersOnGrowth(t *testing.T) {
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("namespace-%d", i)
+
+		before, after := getOwner(key, 3), getOwner(key, 4)
+		if before != after {
+			if after != 3 {
+				t.Errorf("key %q moved from %d to existing shard %d", key, before, after)
+			}
+			moved++
+		}
+	}
+
+	if moved == 0 || moved > 400 {
+		t.Errorf("unexpected number of moved keys: %d", moved)
+	}
+}
+
+func TestShardWithoutTotalOwnsEverything(t *testing.T) {
+	if !(Shard{}).Owns("node-1") {
+		t.Errorf("unsharded agent doesn't own the key")
+	}
+}
+
+func TestIsPrimary(t *testing.T) {
+	defer SetShard(Shard{Index: 0, Total: 1})
+
+	if !IsPrimary() {
+		t.Errorf("unsharded agent isn't primary")
+	}
+
+	SetShard(Shard{Index: 1, Total: 3})
+	if IsPrimary() {
+		t.Errorf("agent of the second shard is primary")
+	}
+}
@@ -0,0 +1,65 @@
+package status
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+)
+
+var (
+	states      = map[string]func() interface{}{}
+	statesMutex = &sync.Mutex{}
+)
+
+// RegisterState registers a function dumping internal state of a component,
+// it is served by the /debug/state endpoint
+func RegisterState(name string, state func() interface{}) {
+	statesMutex.Lock()
+	defer statesMutex.Unlock()
+
+	states[name] = state
+}
+
+// GetState dumps internal states of all registered components
+func GetState() map[string]interface{} {
+	statesMutex.Lock()
+	defer statesMutex.Unlock()
+
+	result := map[string]interface{}{}
+	for name, state := range states {
+		result[name] = state()
+	}
+
+	return result
+}
+
+// HandleDebug registers pprof endpoints and /debug/state
+func (server *Server) HandleDebug() {
+	server.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
+	server.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	server.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+	server.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	server.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+
+	RegisterState("runtime", getRuntimeState)
+
+	server.HandleJSON("/debug/state", func() (interface{}, error) {
+		return GetState(), nil
+	})
+}
+
+func getRuntimeState() interface{} {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	return map[string]interface{}{
+		"goroutines":     runtime.NumGoroutine(),
+		"heap_alloc":     memory.HeapAlloc,
+		"heap_inuse":     memory.HeapInuse,
+		"heap_objects":   memory.HeapObjects,
+		"sys":            memory.Sys,
+		"num_gc":         memory.NumGC,
+		"pause_total_ns": memory.PauseTotalNs,
+	}
+}
@@ -0,0 +1,103 @@
+package status
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+// Server local http server exposing agent state in JSON for in-cluster
+// consumers (kubectl plugins, operators scripts)
+type Server struct {
+	logger  *log.Logger
+	address string
+	token   string
+	mux     *http.ServeMux
+}
+
+// NewServer creates a new status server, every request should be
+// authenticated using the token as a bearer token
+func NewServer(logger *log.Logger, address string, token string) *Server {
+	return &Server{
+		logger:  logger,
+		address: address,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+}
+
+// HandleJSON registers a handler which response is encoded as JSON
+func (server *Server) HandleJSON(
+	path string,
+	handler func() (interface{}, error),
+) {
+	server.mux.HandleFunc(path, server.authorize(
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodGet {
+				http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+
+			data, err := handler()
+			if err != nil {
+				server.logger.Errorf(
+					karma.Describe("path", path).Reason(err),
+					"{status} unable to handle request",
+				)
+				http.Error(writer, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			writer.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(writer).Encode(data)
+			if err != nil {
+				server.logger.Errorf(
+					karma.Describe("path", path).Reason(err),
+					"{status} unable to write response",
+				)
+			}
+		},
+	))
+}
+
+// Handle registers a raw http handler
+func (server *Server) Handle(path string, handler http.Handler) {
+	server.mux.HandleFunc(path, server.authorize(handler.ServeHTTP))
+}
+
+func (server *Server) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		header := request.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if header == token ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(server.token)) != 1 {
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(writer, request)
+	}
+}
+
+// Start starts listening, it blocks until the server fails
+func (server *Server) Start() error {
+	server.logger.Infof(
+		karma.Describe("address", server.address),
+		"{status} starting status server",
+	)
+
+	err := http.ListenAndServe(server.address, server.mux)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to listen on %s",
+			server.address,
+		)
+	}
+
+	return nil
+}
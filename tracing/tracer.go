@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/otlp"
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	tracerQueueSize     = 4096
+	tracerBatchSize     = 512
+	tracerFlushInterval = 5 * time.Second
+)
+
+// Tracer exports spans to an OpenTelemetry collector using OTLP over HTTP
+// with JSON encoding, spans are exported in batches and dropped if the
+// collector can't keep up
+type Tracer struct {
+	logger   *log.Logger
+	url      string
+	client   *http.Client
+	resource map[string]string
+
+	queue chan *Span
+}
+
+// NewTracer creates a tracer exporting spans to the collector endpoint,
+// e.g. http://collector:4318, resource attributes are attached to all spans
+func NewTracer(
+	logger *log.Logger,
+	endpoint string,
+	timeout time.Duration,
+	resource map[string]string,
+) *Tracer {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+
+	return &Tracer{
+		logger:   logger,
+		url:      url,
+		client:   &http.Client{Timeout: timeout},
+		resource: resource,
+
+		queue: make(chan *Span, tracerQueueSize),
+	}
+}
+
+// Start starts exporting ended spans
+func (tracer *Tracer) Start() {
+	go tracer.watch()
+}
+
+func (tracer *Tracer) add(span *Span) {
+	select {
+	case tracer.queue <- span:
+	default:
+		tracer.logger.Tracef(
+			karma.Describe("span", span.name),
+			"{tracing} spans queue is full, dropping span",
+		)
+	}
+}
+
+func (tracer *Tracer) watch() {
+	ticker := time.NewTicker(tracerFlushInterval)
+	defer ticker.Stop()
+
+	spans := []*Span{}
+	for {
+		select {
+		case span := <-tracer.queue:
+			spans = append(spans, span)
+			if len(spans) < tracerBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(spans) == 0 {
+				continue
+			}
+		}
+
+		err := tracer.export(spans)
+		if err != nil {
+			tracer.logger.Errorf(
+				karma.Describe("spans", len(spans)).Reason(err),
+				"{tracing} unable to export spans",
+			)
+		}
+
+		spans = []*Span{}
+	}
+}
+
+func (tracer *Tracer) export(spans []*Span) error {
+	items := make([]otlp.Span, 0, len(spans))
+	for _, span := range spans {
+		items = append(items, getOTLPSpan(span))
+	}
+
+	err := otlp.Export(tracer.client, tracer.url, otlp.TracesRequest{
+		ResourceSpans: []otlp.ResourceSpans{
+			{
+				Resource: otlp.Resource{
+					Attributes: otlp.GetAttributes(tracer.resource),
+				},
+				ScopeSpans: []otlp.ScopeSpans{
+					{
+						Scope: otlp.Scope{Name: otlp.ScopeName},
+						Spans: items,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return karma.Format(err, "unable to export spans")
+	}
+
+	return nil
+}
+
+func getOTLPSpan(span *Span) otlp.Span {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	item := otlp.Span{
+		TraceID:           span.traceID,
+		SpanID:            span.spanID,
+		ParentSpanID:      span.parentID,
+		Name:              span.name,
+		Kind:              otlp.SpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        otlp.GetAttributes(span.attributes),
+	}
+
+	if span.err != nil {
+		item.Status = &otlp.Status{
+			Code:    otlp.StatusCodeError,
+			Message: span.err.Error(),
+		}
+	}
+
+	return item
+}
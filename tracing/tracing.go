@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Span a timed operation of an agent pipeline, spans are exported to an
+// OpenTelemetry collector when they end. All methods of a nil span are
+// no-op, so pipelines are instrumented regardless of whether tracing is
+// enabled
+type Span struct {
+	tracer *Tracer
+
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+
+	start time.Time
+	end   time.Time
+
+	mutex      sync.Mutex
+	attributes map[string]string
+	err        error
+}
+
+var tracer *Tracer
+
+// SetTracer sets the tracer used by Start, spans are not created unless a
+// tracer is set
+func SetTracer(value *Tracer) {
+	tracer = value
+}
+
+// Start starts a root span of a new trace, nil is returned if tracing is
+// disabled
+func Start(name string) *Span {
+	if tracer == nil {
+		return nil
+	}
+
+	return &Span{
+		tracer:  tracer,
+		name:    name,
+		traceID: newID(16),
+		spanID:  newID(8),
+		start:   time.Now(),
+	}
+}
+
+// Child starts a span of the same trace as the span
+func (span *Span) Child(name string) *Span {
+	if span == nil {
+		return nil
+	}
+
+	return &Span{
+		tracer:   span.tracer,
+		name:     name,
+		traceID:  span.traceID,
+		spanID:   newID(8),
+		parentID: span.spanID,
+		start:    time.Now(),
+	}
+}
+
+// SetAttribute sets an attribute of the span
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	if span.attributes == nil {
+		span.attributes = map[string]string{}
+	}
+
+	span.attributes[key] = fmt.Sprint(value)
+}
+
+// SetError marks the span as failed
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	span.err = err
+}
+
+// End ends the span and queues it to be exported
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	span.end = time.Now()
+	span.mutex.Unlock()
+
+	span.tracer.add(span)
+}
+
+func newID(size int) string {
+	id := make([]byte, size)
+
+	// NOTE: crypto/rand never fails on supported platforms, a zero id is
+	// dropped by the collector
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
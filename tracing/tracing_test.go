@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MagalixCorp/magalix-agent/otlp"
+	"github.com/MagalixTechnologies/log-go"
+)
+
+func TestNilSpan(t *testing.T) {
+	SetTracer(nil)
+
+	span := Start("tick")
+	if span != nil {
+		t.Fatalf("expected no span without tracer")
+	}
+
+	child := span.Child("scrape")
+	child.SetAttribute("metrics", 1)
+	child.SetError(errors.New("failed"))
+	child.End()
+	span.End()
+}
+
+func TestTracerExport(t *testing.T) {
+	requests := make(chan otlp.TracesRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Path != "/v1/traces" {
+				t.Errorf("unexpected path %s", request.URL.Path)
+			}
+
+			body, _ := ioutil.ReadAll(request.Body)
+
+			var traces otlp.TracesRequest
+			err := json.Unmarshal(body, &traces)
+			if err != nil {
+				t.Errorf("unable to decode request: %s", err)
+			}
+
+			requests <- traces
+		},
+	))
+	defer server.Close()
+
+	tracer := NewTracer(
+		log.New(true, false, "/dev/stderr"),
+		server.URL,
+		time.Second,
+		map[string]string{"service.name": "magalix-agent"},
+	)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	span := Start("tick")
+	child := span.Child("scrape")
+	child.SetAttribute("source", "kubelet")
+	child.SetError(errors.New("timeout"))
+	child.End()
+	span.End()
+
+	err := tracer.export([]*Span{<-tracer.queue, <-tracer.queue})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traces := <-requests
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	scrape, tick := spans[0], spans[1]
+	if scrape.TraceID != tick.TraceID || scrape.ParentSpanID != tick.SpanID {
+		t.Fatalf("expected scrape to be a child of tick: %+v %+v", scrape, tick)
+	}
+	if len(tick.TraceID) != 32 || len(tick.SpanID) != 16 {
+		t.Fatalf("unexpected ids: %+v", tick)
+	}
+	if scrape.Status == nil || scrape.Status.Code != otlp.StatusCodeError {
+		t.Fatalf("expected scrape to be failed: %+v", scrape)
+	}
+	if len(scrape.Attributes) != 1 || scrape.Attributes[0].Value.StringValue != "kubelet" {
+		t.Fatalf("unexpected attributes: %+v", scrape.Attributes)
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	defaultLogDedupInterval = 10 * time.Minute
+	defaultLogDedupLimit    = 1000
+)
+
+// LogDeduplicator suppresses repeated log messages of the same fingerprint
+// (level and message format), suppressed messages are counted and reported
+// on Flush. A message which keeps repeating is still logged once every
+// interval so persistent failures (e.g. retried requests) stay visible.
+type LogDeduplicator struct {
+	logger   *log.Logger
+	interval time.Duration
+	limit    int
+
+	mutex   sync.Mutex
+	entries map[string]*logDedupEntry
+}
+
+type logDedupEntry struct {
+	level     string
+	format    string
+	message   string
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+	loggedAt  time.Time
+}
+
+// NewLogDeduplicator creates a new deduplicator on top of the given logger,
+// zero interval and limit fall back to defaults
+func NewLogDeduplicator(
+	logger *log.Logger,
+	interval time.Duration,
+	limit int,
+) *LogDeduplicator {
+	if interval <= 0 {
+		interval = defaultLogDedupInterval
+	}
+	if limit <= 0 {
+		limit = defaultLogDedupLimit
+	}
+
+	return &LogDeduplicator{
+		logger:   logger,
+		interval: interval,
+		limit:    limit,
+		entries:  map[string]*logDedupEntry{},
+	}
+}
+
+// Warningf logs a warning unless the same warning was logged recently
+func (dedup *LogDeduplicator) Warningf(
+	reason interface{},
+	format string,
+	args ...interface{},
+) {
+	if dedup.shouldLog("warning", format, args) {
+		dedup.logger.Warningf(reason, format, args...)
+	}
+}
+
+// Errorf logs an error unless the same error was logged recently
+func (dedup *LogDeduplicator) Errorf(
+	reason interface{},
+	format string,
+	args ...interface{},
+) {
+	if dedup.shouldLog("error", format, args) {
+		dedup.logger.Errorf(reason, format, args...)
+	}
+}
+
+func (dedup *LogDeduplicator) shouldLog(
+	level string,
+	format string,
+	args []interface{},
+) bool {
+	dedup.mutex.Lock()
+	defer dedup.mutex.Unlock()
+
+	now := time.Now()
+	fingerprint := level + ":" + format
+
+	entry, ok := dedup.entries[fingerprint]
+	if !ok {
+		if len(dedup.entries) >= dedup.limit {
+			// too many distinct messages, log without tracking
+			return true
+		}
+
+		dedup.entries[fingerprint] = &logDedupEntry{
+			level:     level,
+			format:    format,
+			firstSeen: now,
+			lastSeen:  now,
+			loggedAt:  now,
+		}
+		return true
+	}
+
+	entry.lastSeen = now
+	if now.Sub(entry.loggedAt) >= dedup.interval {
+		entry.loggedAt = now
+		return true
+	}
+
+	if entry.count == 0 {
+		entry.firstSeen = now
+	}
+	entry.count++
+	entry.message = fmt.Sprintf(format, args...)
+	return false
+}
+
+// Flush reports counts of suppressed messages and forgets messages which
+// were not seen during the last interval
+func (dedup *LogDeduplicator) Flush() {
+	dedup.mutex.Lock()
+	defer dedup.mutex.Unlock()
+
+	now := time.Now()
+	for fingerprint, entry := range dedup.entries {
+		if entry.count > 0 {
+			dedup.logger.Warningf(
+				karma.
+					Describe("level", entry.level).
+					Describe("format", entry.format).
+					Describe("count", entry.count).
+					Describe("first_seen", entry.firstSeen).
+					Describe("last_seen", entry.lastSeen),
+				"{dedup} message repeated %d times, last: %s",
+				entry.count,
+				entry.message,
+			)
+
+			entry.count = 0
+			continue
+		}
+
+		if now.Sub(entry.lastSeen) >= dedup.interval {
+			delete(dedup.entries, fingerprint)
+		}
+	}
+}
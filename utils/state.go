@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/reconquest/karma-go"
+)
+
+// WriteStateFile writes the value encoded as json to a temporary file,
+// syncs it to the disk and renames it, so the state file is never left
+// partially written even after a power loss, name describes the state in
+// errors
+func WriteStateFile(path string, value interface{}, name string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return karma.Format(err, "unable to encode %s state", name)
+	}
+
+	temporary := path + ".tmp"
+	err = writeSynced(temporary, data)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to write %s state file %s",
+			name,
+			temporary,
+		)
+	}
+
+	err = os.Rename(temporary, path)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to replace %s state file %s",
+			name,
+			path,
+		)
+	}
+
+	return nil
+}
+
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
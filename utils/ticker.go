@@ -3,10 +3,13 @@ package utils
 import (
 	"sync"
 	"time"
+
+	"github.com/MagalixTechnologies/log-go"
 )
 
 type Ticker struct {
-	name string
+	name   string
+	logger *log.Logger
 
 	interval time.Duration
 	fn       func(tickTime time.Time)
@@ -15,16 +18,31 @@ type Ticker struct {
 
 	waitChannels map[int64][]chan struct{}
 	lastTick time.Time
+
+	started bool
+	ticks   *sync.WaitGroup
+	stop    chan struct{}
+	stopped chan struct{}
 }
 
-func NewTicker(name string, interval time.Duration, fn func(time.Time)) *Ticker {
+func NewTicker(
+	logger *log.Logger,
+	name string,
+	interval time.Duration,
+	fn func(time.Time),
+) *Ticker {
 	return &Ticker{
 		name:     name,
+		logger:   logger,
 		interval: interval,
 		fn:       fn,
 
 		mutex: &sync.Mutex{},
 		waitChannels: map[int64][]chan struct{}{},
+
+		ticks:   &sync.WaitGroup{},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
 	}
 }
 
@@ -35,10 +53,10 @@ func (ticker *Ticker) nextTick() <-chan time.Time {
 		// TODO: sub seconds
 		nanos := time.Second*time.Duration(now.Second()) + time.Minute*time.Duration(now.Minute())
 		next := interval - nanos%interval
-		stderr.Infof(nil, "{%s ticker} next tick after %v", ticker.name, next)
+		ticker.logger.Infof(nil, "{%s ticker} next tick after %v", ticker.name, next)
 		return time.After(next)
 	}
-	stderr.Infof(nil, "{%s ticker} next tick after interval %v", ticker.name, interval)
+	ticker.logger.Infof(nil, "{%s ticker} next tick after interval %v", ticker.name, interval)
 	return time.After(interval)
 }
 
@@ -80,13 +98,30 @@ func (ticker *Ticker) tick() {
 // the tick interval to finish. So please consider timeouts if consistent ticks
 // are needed.
 func (ticker *Ticker) Start(immediate, async, block bool) {
+	ticker.mutex.Lock()
+	ticker.started = true
+	ticker.mutex.Unlock()
+
 	tickerFn := func() {
+		defer close(ticker.stopped)
+
 		tick := ticker.nextTick()
 		for {
-			ticker.lastTick = <-tick
+			select {
+			case ticker.lastTick = <-tick:
+			case <-ticker.stop:
+				// wait for async ticks, so a blocking Start returns only
+				// once nothing is ticking anymore
+				ticker.ticks.Wait()
+				return
+			}
 
 			if async {
-				go ticker.tick()
+				ticker.ticks.Add(1)
+				go func() {
+					defer ticker.ticks.Done()
+					ticker.tick()
+				}()
 			} else {
 				ticker.tick()
 			}
@@ -106,6 +141,23 @@ func (ticker *Ticker) Start(immediate, async, block bool) {
 	}
 }
 
+// Stop stops ticker and waits for the running tick to finish. A blocking
+// Start returns once the ticker is stopped.
+func (ticker *Ticker) Stop() {
+	ticker.mutex.Lock()
+	select {
+	case <-ticker.stop:
+	default:
+		close(ticker.stop)
+	}
+	started := ticker.started
+	ticker.mutex.Unlock()
+
+	if started {
+		<-ticker.stopped
+	}
+}
+
 // WaitForNextTick returns a signal channel that gets unblocked after the next tick
 // Example usage:
 //  <- ticker.WaitForNextTick()
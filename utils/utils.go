@@ -197,6 +197,7 @@ func InSkipNamespace(skipNamespacePatterns []string, namespace string) bool {
 }
 
 func Throttle(
+	logger *log.Logger,
 	name string,
 	interval time.Duration,
 	tickLimit int32,
@@ -211,14 +212,14 @@ func Throttle(
 
 	nextTick := getNextTick()
 
-	stderr.Info("{%s throttler} next tick at %s", name, nextTick.Format(time.RFC3339))
+	logger.Infof(nil, "{%s throttler} next tick at %s", name, nextTick.Format(time.RFC3339))
 
 	var tickFires int32 = 0
 
 	return func(args ...interface{}) {
 		now := time.Now()
 		if now.After(nextTick) || now.Equal(nextTick) {
-			stderr.Infof(nil, "{%s throttler} ticking", name)
+			logger.Infof(nil, "{%s throttler} ticking", name)
 			fn(args...)
 
 			atomic.AddInt32(&tickFires, 1)
@@ -227,13 +228,13 @@ func Throttle(
 				nextTick = getNextTick()
 			}
 
-			stderr.Infof(nil,
+			logger.Infof(nil,
 				"{%s throttler} next tick at %s",
 				name,
 				nextTick.Format(time.RFC3339),
 			)
 		} else {
-			stderr.Infof(nil, "{%s throttler} throttled", name)
+			logger.Infof(nil, "{%s throttler} throttled", name)
 		}
 	}
 }
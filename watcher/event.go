@@ -28,6 +28,12 @@ type Event struct {
 	Origin        string      `json:"origin,omitempty" bson:"origin,omitempty"`
 	Source        interface{} `json:"source,omitempty" bson:"source,omitempty"`
 	Meta          interface{} `json:"meta,omitempty" bson:"meta,omitempty"`
+
+	// Count occurrences of the event aggregated within a batch, timestamps
+	// of the first and the last occurrences are set if it's more than one
+	Count          int        `json:"count,omitempty" bson:"count,omitempty"`
+	FirstTimestamp *time.Time `json:"first_timestamp,omitempty" bson:"first_timestamp,omitempty"`
+	LastTimestamp  *time.Time `json:"last_timestamp,omitempty" bson:"last_timestamp,omitempty"`
 }
 
 // NewEvent creates a new event should be deprecated in favor of NewEventWithSource
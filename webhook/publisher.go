@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/MagalixTechnologies/log-go"
+	"github.com/MagalixTechnologies/uuid-go"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// EventEntities snapshot of entities found by the scanner
+	EventEntities = "entities"
+	// EventDecisionApplied decision applied to the cluster
+	EventDecisionApplied = "decision/applied"
+)
+
+// queueSize max count of notifications waiting to be posted, newer
+// notifications are dropped while the queue is full
+const queueSize = 100
+
+// Notification body posted to webhooks
+type Notification struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	AccountID uuid.UUID   `json:"account_id"`
+	ClusterID uuid.UUID   `json:"cluster_id"`
+	Data      interface{} `json:"data"`
+}
+
+// Publisher posts notifications to in-cluster webhooks
+type Publisher struct {
+	logger *log.Logger
+	client *http.Client
+	urls   []string
+
+	accountID uuid.UUID
+	clusterID uuid.UUID
+
+	queue chan Notification
+}
+
+var publisher *Publisher
+
+// SetPublisher sets the publisher used by Publish, nothing is published
+// unless a publisher is set
+func SetPublisher(value *Publisher) {
+	publisher = value
+}
+
+// NewPublisher creates a new publisher posting to the specified urls and
+// starts it
+func NewPublisher(
+	logger *log.Logger,
+	urls []string,
+	timeout time.Duration,
+	accountID uuid.UUID,
+	clusterID uuid.UUID,
+) *Publisher {
+	publisher := &Publisher{
+		logger: logger,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		urls: urls,
+
+		accountID: accountID,
+		clusterID: clusterID,
+
+		queue: make(chan Notification, queueSize),
+	}
+
+	go publisher.run()
+
+	return publisher
+}
+
+// Publish posts the event to all webhooks asynchronously
+func Publish(event string, data interface{}) {
+	if publisher == nil {
+		return
+	}
+
+	notification := Notification{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		AccountID: publisher.accountID,
+		ClusterID: publisher.clusterID,
+		Data:      data,
+	}
+
+	select {
+	case publisher.queue <- notification:
+	default:
+		publisher.logger.Warningf(
+			karma.Describe("event", event),
+			"{webhook} queue is full, dropping notification",
+		)
+	}
+}
+
+func (publisher *Publisher) run() {
+	for notification := range publisher.queue {
+		body, err := json.Marshal(notification)
+		if err != nil {
+			publisher.logger.Errorf(
+				karma.Describe("event", notification.Event).Reason(err),
+				"{webhook} unable to encode notification",
+			)
+			continue
+		}
+
+		for _, url := range publisher.urls {
+			err := publisher.post(url, body)
+			if err != nil {
+				publisher.logger.Errorf(
+					karma.
+						Describe("event", notification.Event).
+						Describe("url", url).
+						Reason(err),
+					"{webhook} unable to post notification",
+				)
+			}
+		}
+	}
+}
+
+func (publisher *Publisher) post(url string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return karma.Format(err, "unable to create request")
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "magalix-agent")
+
+	response, err := publisher.client.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// ParseURLs validates webhook urls, only http and https urls are supported
+func ParseURLs(urls []string) ([]string, error) {
+	for _, value := range urls {
+		parsed, err := neturl.Parse(value)
+		if err != nil {
+			return nil, karma.Format(err, "unable to parse webhook url %q", value)
+		}
+
+		if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, karma.Format(
+				nil,
+				"webhook url %q should be an absolute http or https url",
+				value,
+			)
+		}
+	}
+
+	return urls, nil
+}